@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/kebairia/backup/internal/operations"
+	"github.com/spf13/cobra"
+)
+
+var auditOpts operations.AuditOptions
+
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Cross-check the catalog against local and remote copies",
+	Long: `Cross-check the catalog against the local backup directory and the storage
+backend. Reported are:
+
+  missing            restorable backups whose remote copy is not in storage,
+                     or that have no copy left at all
+  size_mismatch      copies whose size differs from the catalog
+  checksum_mismatch  copies whose SHA-256 differs from the catalog
+  orphan             artifacts in storage no catalog entry references
+
+Local copies are always checksummed; --read-data also downloads remote copies
+to checksum them. --repair uploads intact local copies over missing or
+damaged remote ones. Orphans are only reported.
+
+The command exits non-zero when problems remain.`,
+	Example: `  bacli audit
+  bacli audit --read-data --repair`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		result, err := operations.Audit(cmd.Context(), ConfigFile, auditOpts)
+		if err != nil && !errors.Is(err, operations.ErrAudit) {
+			return err
+		}
+		if jsonOutput() {
+			if perr := printJSON(result); perr != nil {
+				return perr
+			}
+			return err
+		}
+		if len(result.Findings) > 0 {
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "KIND\tLOCATION\tENGINE\tDATABASE\tPATH\tDETAIL")
+			for _, f := range result.Findings {
+				detail := f.Detail
+				if f.Repaired {
+					detail += " (repaired)"
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+					f.Kind, f.Location, f.Engine, f.Database, f.Path, detail)
+			}
+			if ferr := w.Flush(); ferr != nil {
+				return ferr
+			}
+			fmt.Println()
+		}
+		fmt.Printf("%d backups and %d stored objects audited, %d findings\n",
+			result.Records, result.Objects, len(result.Findings))
+		return err
+	},
+}
+
+func init() {
+	auditCmd.Flags().
+		BoolVar(&auditOpts.ReadData, "read-data", false, "download remote copies to verify their checksum")
+	auditCmd.Flags().
+		BoolVar(&auditOpts.Repair, "repair", false, "re-upload intact local copies over missing or damaged remote ones")
+}
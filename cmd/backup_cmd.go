@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"os"
 
@@ -8,22 +9,72 @@ import (
 	"github.com/spf13/cobra"
 )
 
+var (
+	backupOpts operations.BackupOptions
+	// backupStdout streams the dump of a single database to stdout.
+	backupStdout bool
+)
+
 var backupCmd = &cobra.Command{
 	Use:   "backup",
 	Short: "Backup all databases as per config",
+	Example: `  bacli backup
+  bacli backup --engine postgres --label reason=pre-migration
+  bacli backup --instance postgres:billing
+  bacli backup --engine mongodb --incremental
+  bacli backup --instance postgres:main --stdout | aws s3 cp - s3://bucket/main.dump`,
 	Run: func(cmd *cobra.Command, args []string) {
 		if ConfigFile == "" {
-			fmt.Errorf("Config file is required (-f flag)")
+			fmt.Fprintln(os.Stderr, "ERROR: config file is required (-c flag)")
+			os.Exit(1)
+		}
+		if backupStdout {
+			if err := streamBackup(cmd); err != nil {
+				fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+				os.Exit(exitCode(cmd.Context(), err))
+			}
+			return
 		}
-		if err := operations.BackupAll(ConfigFile); err != nil {
+		report, err := operations.BackupAll(cmd.Context(), ConfigFile, backupOpts)
+		if jsonOutput() && (err == nil || len(report.Results) > 0) {
+			if perr := printJSON(report); perr != nil {
+				fmt.Fprintf(os.Stderr, "ERROR: %v\n", perr)
+				os.Exit(1)
+			}
+		}
+		if err != nil {
 			fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
-			os.Exit(1)
+			os.Exit(exitCode(cmd.Context(), err))
 		}
 	},
 }
 
+// streamBackup writes the dump of the selected database to stdout, refusing
+// to print a binary dump on a terminal.
+func streamBackup(cmd *cobra.Command) error {
+	if info, err := os.Stdout.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+		return errors.New("--stdout writes a binary dump: pipe or redirect stdout")
+	}
+	_, err := operations.BackupTo(cmd.Context(), ConfigFile, backupOpts, os.Stdout)
+	return err
+}
+
 func init() {
-	// Bind the config file flag to the global variable.
 	backupCmd.Flags().
-		StringVarP(&ConfigFile, "config", "c", "./configs/config.yaml", "path to YAML config file")
+		StringVar(&backupOpts.Engine, "engine", "", "back up only databases of this engine")
+	backupCmd.Flags().
+		StringVar(&backupOpts.Instance, "instance", "", "back up only this instance (name or engine:name); other instances are not initialized")
+	backupCmd.Flags().
+		StringVar(&backupOpts.Database, "database", "", "back up only this database")
+	_ = backupCmd.RegisterFlagCompletionFunc("database", completeDatabases)
+	backupCmd.Flags().
+		BoolVar(&backupOpts.KeepPartial, "keep-partial", false, "keep partial artifacts of failed backups for debugging")
+	backupCmd.Flags().
+		BoolVar(&backupOpts.IgnoreWindow, "ignore-window", false, "back up even outside backup windows and on blackout dates")
+	backupCmd.Flags().
+		BoolVar(&backupStdout, "stdout", false, "stream the dump of a single database to stdout instead of the catalog (logs go to stderr)")
+	backupCmd.Flags().
+		BoolVar(&backupOpts.Incremental, "incremental", false, "back up the changes since the latest backup where supported (MongoDB oplog captures), a full backup elsewhere")
+	backupCmd.Flags().
+		StringToStringVar(&backupOpts.Labels, "label", nil, "label every backup of this run (key=value, repeatable; overrides instance labels)")
 }
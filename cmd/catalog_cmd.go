@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/kebairia/backup/internal/operations"
+	"github.com/spf13/cobra"
+)
+
+var catalogCmd = &cobra.Command{
+	Use:   "catalog",
+	Short: "Export, import or rebuild the backup catalog",
+	Long: `Manage the catalog itself: the metadata and history written next to every
+artifact in the backup directory, and the run manifests. Export it to a file
+or to the storage backend so that a fresh host can import it after a
+disaster, or rebuild it from the metadata stored next to the artifacts.`,
+}
+
+var catalogExportCmd = &cobra.Command{
+	Use:   "export [file|-|remote]",
+	Short: "Write the catalog to a file, stdout or the storage backend",
+	Long: `Write the whole catalog as one JSON document to a file, to stdout ("-", the
+default), or to catalog/catalog.json on the storage backend ("remote").`,
+	Example: `  bacli catalog export catalog.json
+  bacli catalog export remote`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dest := "-"
+		if len(args) == 1 {
+			dest = args[0]
+		}
+		result, err := operations.ExportCatalog(cmd.Context(), ConfigFile, dest)
+		if err != nil || dest == "-" {
+			return err
+		}
+		return printCatalogResult(cmd, "exported", result)
+	},
+}
+
+var catalogImportCmd = &cobra.Command{
+	Use:   "import <file|-|remote>",
+	Short: "Merge an exported catalog into the local catalog",
+	Long: `Merge a catalog written by "bacli catalog export" into the local catalog.
+Paths are moved from the exporting host's backup directory to the local one,
+and records the local catalog already holds are kept.`,
+	Example: "  bacli catalog import remote",
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		result, err := operations.ImportCatalog(cmd.Context(), ConfigFile, args[0])
+		if err != nil {
+			return err
+		}
+		return printCatalogResult(cmd, "imported", result)
+	},
+}
+
+var catalogRebuildCmd = &cobra.Command{
+	Use:   "rebuild",
+	Short: "Reconstruct the catalog from the storage backend",
+	Long: `Reconstruct the catalog from the metadata stored on the storage backend
+next to the artifacts: the last record of every database, and the run
+manifests for the earlier runs. Records of successful runs whose artifact is
+gone are skipped; records the local catalog already holds are kept.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		result, err := operations.RebuildCatalog(cmd.Context(), ConfigFile)
+		if err != nil {
+			return err
+		}
+		if err := printCatalogResult(cmd, "rebuilt", result); err != nil || jsonOutput() {
+			return err
+		}
+		if result.Skipped > 0 {
+			fmt.Fprintf(cmd.OutOrStdout(), "%d records skipped, their artifacts are gone\n", result.Skipped)
+		}
+		return nil
+	},
+}
+
+// printCatalogResult prints what a catalog command covered.
+func printCatalogResult(cmd *cobra.Command, verb string, result operations.CatalogResult) error {
+	if jsonOutput() {
+		return printJSON(result)
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "%d records of %d databases and %d runs %s\n",
+		result.Records, result.Databases, result.Runs, verb)
+	return nil
+}
+
+func init() {
+	catalogCmd.AddCommand(catalogExportCmd, catalogImportCmd, catalogRebuildCmd)
+}
@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kebairia/backup/internal/operations"
+	"github.com/spf13/cobra"
+)
+
+var chainCmd = &cobra.Command{
+	Use:   "chain",
+	Short: "Inspect backup chains of incremental backups",
+	Long: `Inspect backup chains: a full backup and the incremental backups that apply
+on it. Restoring an incremental backup needs every backup before it in its
+chain, so prune never expires a backup that a kept incremental depends on.`,
+}
+
+var chainShowCmd = &cobra.Command{
+	Use:   "show <database>",
+	Short: "Show the backup chains of a database",
+	Long: `Show the backup chains of a database, oldest first, with the size and time of
+every link. A chain whose base backup failed or was pruned is flagged BROKEN:
+its incrementals cannot be restored.`,
+	Example:           "  bacli chain show billing",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeDatabases,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		chains, err := operations.Chains(ConfigFile, args[0])
+		if err != nil {
+			return err
+		}
+		if jsonOutput() {
+			return printJSON(chains)
+		}
+		if len(chains) == 0 {
+			return fmt.Errorf("no backups of %q in the catalog", args[0])
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for i, chain := range chains {
+			state := "OK"
+			if chain.Broken != "" {
+				state = "BROKEN: " + chain.Broken
+			}
+			if i > 0 {
+				fmt.Fprintln(w)
+			}
+			fmt.Fprintf(w, "%s/%s chain %d: %d backups, %s, %s\n",
+				chain.Engine, chain.Database, i+1, len(chain.Links), formatBytes(chain.SizeBytes()), state)
+			for j, link := range chain.Links {
+				kind := "full"
+				if link.Incremental {
+					kind = "incremental"
+				}
+				branch := "├─"
+				if j == len(chain.Links)-1 {
+					branch = "└─"
+				}
+				fmt.Fprintf(w, "%s %s\t%s\t%s\t%s\n",
+					branch,
+					kind,
+					link.StartedAt.Local().Format(time.DateTime),
+					formatBytes(link.SizeBytes),
+					link.FilePath,
+				)
+			}
+		}
+		return w.Flush()
+	},
+}
+
+func init() {
+	chainCmd.AddCommand(chainShowCmd)
+}
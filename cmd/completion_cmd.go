@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/kebairia/backup/internal/config"
+	"github.com/kebairia/backup/internal/operations"
+	"github.com/spf13/cobra"
+)
+
+var completionCmd = &cobra.Command{
+	Use:   "completion bash|zsh|fish|powershell",
+	Short: "Generate shell completion scripts",
+	Long: `Generate shell completion scripts for bacli.
+
+  bash:  source <(bacli completion bash)
+  zsh:   bacli completion zsh > "${fpath[1]}/_bacli"
+  fish:  bacli completion fish > ~/.config/fish/completions/bacli.fish
+
+Database names for --database are completed from the backup catalog.`,
+	ValidArgs:             []string{"bash", "zsh", "fish", "powershell"},
+	Args:                  cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+	DisableFlagsInUseLine: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out := os.Stdout
+		switch args[0] {
+		case "bash":
+			return rootCmd.GenBashCompletionV2(out, true)
+		case "zsh":
+			return rootCmd.GenZshCompletion(out)
+		case "fish":
+			return rootCmd.GenFishCompletion(out, true)
+		case "powershell":
+			return rootCmd.GenPowerShellCompletionWithDesc(out)
+		}
+		return fmt.Errorf("unsupported shell %q", args[0])
+	},
+}
+
+// completeDatabases completes database names from the backup catalog.
+// Completion runs without the root pre-run hook, so the profile and tenant
+// are applied here.
+func completeDatabases(
+	cmd *cobra.Command,
+	args []string,
+	toComplete string,
+) ([]string, cobra.ShellCompDirective) {
+	config.UseProfile(Profile)
+	config.UseTenant(Tenant)
+	names, err := operations.CatalogDatabases(ConfigFile)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/cobra/doc"
+)
+
+var manDir string
+
+var docsCmd = &cobra.Command{
+	Use:   "docs",
+	Short: "Generate documentation",
+}
+
+var docsManCmd = &cobra.Command{
+	Use:   "man",
+	Short: "Generate man pages for every command",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := os.MkdirAll(manDir, 0o755); err != nil {
+			return fmt.Errorf("create man directory: %w", err)
+		}
+		header := &doc.GenManHeader{
+			Title:   "BACLI",
+			Section: "1",
+			Source:  "bacli",
+			Manual:  "bacli manual",
+		}
+		rootCmd.DisableAutoGenTag = true
+		if err := doc.GenManTree(rootCmd, header, manDir); err != nil {
+			return fmt.Errorf("generate man pages: %w", err)
+		}
+		fmt.Fprintf(os.Stdout, "man pages written to %s\n", manDir)
+		return nil
+	},
+}
+
+func init() {
+	docsManCmd.Flags().
+		StringVar(&manDir, "dir", "./man", "directory to write man pages to")
+	docsCmd.AddCommand(docsManCmd)
+}
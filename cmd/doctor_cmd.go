@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/kebairia/backup/internal/operations"
+	"github.com/spf13/cobra"
+)
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check client tools, Vault access and database connectivity",
+	Long: `Check client tools, Vault access and database connectivity.
+
+For every engine with configured instances, doctor looks up the client
+binaries bacli shells out to (pg_dump/pg_restore, mongodump/mongorestore,
+mysqldump, redis-cli) and reports their versions. It then logs in to Vault,
+fetches credentials for every instance, connects with them, and fails when
+a client tool is older than the server it has to dump.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		checks, err := operations.Doctor(cmd.Context(), ConfigFile)
+		if err != nil {
+			return err
+		}
+
+		if jsonOutput() {
+			if err := printJSON(checks); err != nil {
+				return err
+			}
+		} else {
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "CHECK\tENGINE\tNAME\tVERSION\tSTATUS\tDETAIL")
+			for _, c := range checks {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+					c.Check,
+					dash(c.Engine),
+					dash(c.Name),
+					dash(c.Version),
+					c.Status,
+					c.Detail,
+				)
+			}
+			if err := w.Flush(); err != nil {
+				return err
+			}
+		}
+
+		failed := 0
+		for _, c := range checks {
+			if c.Status == operations.DoctorFail {
+				failed++
+			}
+		}
+		if failed > 0 {
+			return fmt.Errorf("%w: %d checks failed", operations.ErrDoctor, failed)
+		}
+		return nil
+	},
+}
+
+// dash renders empty table cells as "-".
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
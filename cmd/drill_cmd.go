@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kebairia/backup/internal/operations"
+	"github.com/spf13/cobra"
+)
+
+var (
+	drillOpts operations.DrillOptions
+	// drillHTML is where the HTML report is written.
+	drillHTML string
+)
+
+var drillCmd = &cobra.Command{
+	Use:   "drill",
+	Short: "Rehearse restores and check recovery time objectives",
+	Long: `Restore the latest backup of each database onto the staging instance
+(drill.host), measure how long each recovery takes, fetching and decrypting
+the artifact included, and compare it with the database's recovery time
+objective (rto of the instance, its engine, or drill.rto).
+
+The staging databases are dropped afterwards unless drill.keep is set. Every
+drill is recorded under <backup.directory>/drills and sent to the notifiers;
+--html also renders it as a page to hand to auditors. The command fails when
+a restore fails or misses its RTO, so it can run from cron.`,
+	Example: `  bacli drill
+  bacli drill --engine postgres --html drill.html
+  bacli drill -o json > drill.json`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		report, err := operations.Drill(cmd.Context(), ConfigFile, drillOpts)
+		if report.ID == "" {
+			return err
+		}
+		if drillHTML != "" {
+			if herr := writeDrillHTML(report); herr != nil {
+				return herr
+			}
+		}
+		if jsonOutput() {
+			if perr := printJSON(report); perr != nil {
+				return perr
+			}
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ENGINE\tDATABASE\tBACKUP\tRESTORE TIME\tRTO\tRESULT")
+		for _, r := range report.Results {
+			backup, rto, result := "-", "-", "passed"
+			if !r.BackupStartedAt.IsZero() {
+				backup = r.BackupStartedAt.Local().Format(time.DateTime)
+			}
+			if r.RTO > 0 {
+				rto = r.RTO.String()
+			}
+			if !r.MetRTO() {
+				result = "failed: " + r.Error
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+				r.Engine, r.Database, backup, r.Duration.Round(time.Second), rto, result)
+		}
+		if ferr := w.Flush(); ferr != nil {
+			return ferr
+		}
+		return err
+	},
+}
+
+// writeDrillHTML writes the HTML report to --html.
+func writeDrillHTML(report operations.DrillReport) error {
+	file, err := os.Create(drillHTML)
+	if err != nil {
+		return fmt.Errorf("write drill report: %w", err)
+	}
+	if err := report.WriteHTML(file); err != nil {
+		file.Close()
+		return fmt.Errorf("write drill report: %w", err)
+	}
+	return file.Close()
+}
+
+func init() {
+	drillCmd.Flags().
+		StringVar(&drillOpts.Engine, "engine", "", "drill only databases of this engine")
+	drillCmd.Flags().
+		StringVar(&drillOpts.Instance, "instance", "", "drill only this instance (name or engine:name)")
+	drillCmd.Flags().
+		StringVar(&drillOpts.Database, "database", "", "drill only this database")
+	_ = drillCmd.RegisterFlagCompletionFunc("database", completeDatabases)
+	drillCmd.Flags().
+		StringVar(&drillHTML, "html", "", "also write the report as an HTML page to this file")
+}
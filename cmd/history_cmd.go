@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kebairia/backup/internal/operations"
+	"github.com/spf13/cobra"
+)
+
+var (
+	historyDatabase string
+	historyLimit    int
+)
+
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "List recorded backup runs, newest first",
+	Long: `List recorded backup runs, newest first.
+
+Every run is appended to history.jsonl next to the artifacts of its database,
+so failed runs never hide earlier restore points. Restores and verification
+use the newest successful run.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		runs, err := operations.History(ConfigFile, historyDatabase, historyLimit)
+		if err != nil {
+			return err
+		}
+		if jsonOutput() {
+			return printJSON(runs)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ENGINE\tDATABASE\tSTARTED\tSTATUS\tSIZE\tDURATION\tFILE")
+		for _, run := range runs {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+				run.Engine,
+				run.Database,
+				run.StartedAt.Local().Format(time.DateTime),
+				run.Status,
+				formatBytes(run.SizeBytes),
+				run.Duration.Round(time.Second),
+				run.FilePath,
+			)
+		}
+		return w.Flush()
+	},
+}
+
+func init() {
+	historyCmd.Flags().
+		StringVar(&historyDatabase, "database", "", "only list runs of this database")
+	historyCmd.Flags().
+		IntVar(&historyLimit, "limit", 10, "runs to list per database (0 lists all)")
+	_ = historyCmd.RegisterFlagCompletionFunc("database", completeDatabases)
+}
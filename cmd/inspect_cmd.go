@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kebairia/backup/internal/operations"
+	"github.com/spf13/cobra"
+)
+
+var inspectCmd = &cobra.Command{
+	Use:   "inspect <path|uri>",
+	Short: "Describe a single backup artifact",
+	Long: `Print everything known about one artifact: its catalog record, whether its
+checksum still matches the catalog, how it is compressed and encrypted, the
+dump tool and format that wrote it (pg_dump custom, plain, tar or directory,
+mongodump archive or directory, ...), and the commands that would restore
+it.
+
+The artifact is a local file or directory, or an object on storage as
+s3://bucket/key or rclone:remote/key. It is found in the catalog by path,
+storage URI or checksum, so a copy handed over outside bacli is recognized
+too. Encrypted artifacts are decrypted with backup.encryption to detect
+their format. Nothing is restored.
+
+The command exits non-zero when the checksum does not match the catalog.`,
+	Example: `  bacli inspect ./backups/postgres/billing/2025-05-07_22-00-00-billing.dump.zst
+  bacli inspect s3://backups/prod/postgres/billing/2025-05-07_22-00-00-billing.dump.zst
+  bacli inspect ~/Downloads/mystery.dump`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		in, err := operations.Inspect(cmd.Context(), ConfigFile, args[0])
+		if err != nil && !errors.Is(err, operations.ErrChecksumMismatch) {
+			return err
+		}
+		if jsonOutput() {
+			if perr := printJSON(in); perr != nil {
+				return perr
+			}
+			return err
+		}
+		if perr := printInspection(in); perr != nil {
+			return perr
+		}
+		return err
+	},
+}
+
+// printInspection prints an inspection as aligned fields.
+func printInspection(in operations.Inspection) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	field := func(name, format string, args ...any) {
+		fmt.Fprintf(w, "%s:\t%s\n", name, fmt.Sprintf(format, args...))
+	}
+	field("Artifact", "%s", in.Artifact)
+	kind := "file"
+	if in.Directory {
+		kind = "directory"
+	}
+	field("Size", "%s (%s)", formatBytes(in.SizeBytes), kind)
+	format := in.Format
+	if in.Engine != "" {
+		format += " (" + in.Engine + ")"
+	}
+	field("Format", "%s", format)
+	compression := in.Compression
+	if compression == "" {
+		compression = "none"
+	}
+	field("Compression", "%s", compression)
+	field("Encrypted", "%t", in.Encrypted)
+	switch in.ChecksumStatus {
+	case operations.ChecksumOK:
+		field("Checksum", "%s (matches the catalog)", in.Checksum)
+	case operations.ChecksumMismatch:
+		field("Checksum", "%s (MISMATCH, the catalog records %s)", in.Checksum, in.Record.Checksum)
+	default:
+		if in.Checksum != "" {
+			field("Checksum", "%s (not recorded)", in.Checksum)
+		}
+	}
+	if record := in.Record; record != nil {
+		field("Backup", "%s/%s, %s, started %s", record.Engine, record.Database, record.Status,
+			record.StartedAt.Local().Format(time.DateTime))
+		if record.RunID != "" {
+			field("Run", "%s", record.RunID)
+		}
+		if record.DumpTool != nil {
+			field("Dump tool", "%s %s", record.DumpTool.Name, record.DumpTool.Version)
+		}
+		if record.ServerVersion != "" {
+			field("Server", "%s", record.ServerVersion)
+		}
+	} else {
+		field("Backup", "not in the catalog")
+	}
+	if in.RestoreCommand != "" {
+		field("Restore", "%s", in.RestoreCommand)
+	}
+	if in.BacliCommand != "" {
+		field("Restore (bacli)", "%s", in.BacliCommand)
+	}
+	if in.Note != "" {
+		field("Note", "%s", in.Note)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,159 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kebairia/backup/internal/operations"
+	"github.com/spf13/cobra"
+)
+
+var (
+	listRuns  bool
+	listUsage bool
+	listLimit int
+)
+
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List restorable backups, or backup runs with --runs",
+	Long: `List restorable backups, newest first.
+
+With --runs, list backup runs instead. Every run writes a manifest to
+<backup.directory>/runs/<run id>.json with its start and end, the hash of the
+configuration it ran with and the status of every artifact. Restore all the
+databases of a run with ` + "`bacli restore --run <run id>`" + `.
+
+With --usage, list the storage used by the backups of every database: on
+local disk, and on the storage backend as recorded in the catalog, against
+the quota of its instance and of the backup directory.`,
+	Example: `  bacli list
+  bacli list --runs --limit 5
+  bacli list --usage`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if listRuns {
+			return printRuns()
+		}
+		if listUsage {
+			return printUsage()
+		}
+		runs, err := operations.History(ConfigFile, "", 0)
+		if err != nil {
+			return err
+		}
+		runs = slices.DeleteFunc(runs, func(run operations.Metadata) bool { return !run.Restorable() })
+		if listLimit > 0 && len(runs) > listLimit {
+			runs = runs[:listLimit]
+		}
+		if jsonOutput() {
+			return printJSON(runs)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ENGINE\tDATABASE\tSTARTED\tSIZE\tRUN\tFILE")
+		for _, run := range runs {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+				run.Engine,
+				run.Database,
+				run.StartedAt.Local().Format(time.DateTime),
+				formatBytes(run.SizeBytes),
+				dash(run.RunID),
+				run.FilePath,
+			)
+		}
+		return w.Flush()
+	},
+}
+
+// printRuns prints the run manifests, newest first.
+func printRuns() error {
+	runs, err := operations.Runs(ConfigFile, listLimit)
+	if err != nil {
+		return err
+	}
+	if jsonOutput() {
+		return printJSON(runs)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "RUN\tSTARTED\tDURATION\tARTIFACTS\tFAILED\tSIZE\tCONFIG")
+	for _, run := range runs {
+		var size int64
+		for _, artifact := range run.Artifacts {
+			size += artifact.SizeBytes
+		}
+		failed := fmt.Sprint(run.Failed())
+		if run.Cancelled {
+			failed += " (cancelled)"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%s\t%.12s\n",
+			run.ID,
+			run.StartedAt.Local().Format(time.DateTime),
+			run.CompletedAt.Sub(run.StartedAt).Round(time.Second),
+			len(run.Artifacts),
+			failed,
+			formatBytes(size),
+			run.ConfigHash,
+		)
+	}
+	return w.Flush()
+}
+
+// printUsage prints the storage used by every database and its quota state.
+func printUsage() error {
+	report, err := operations.Usage(ConfigFile)
+	if err != nil {
+		return err
+	}
+	if jsonOutput() {
+		return printJSON(report)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ENGINE\tDATABASE\tBACKUPS\tLOCAL\tREMOTE\tQUOTA\tSTATUS")
+	for _, usage := range report.Databases {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\t%s\t%s\n",
+			usage.Engine,
+			usage.Database,
+			usage.Backups,
+			formatBytes(usage.LocalBytes),
+			formatBytes(usage.RemoteBytes),
+			formatQuota(usage.WarnBytes, usage.LimitBytes),
+			dash(usage.Quota),
+		)
+	}
+	fmt.Fprintf(w, "total\t\t\t%s\t%s\t%s\t%s\n",
+		formatBytes(report.LocalBytes),
+		formatBytes(report.RemoteBytes),
+		formatQuota(report.WarnBytes, report.LimitBytes),
+		dash(report.Quota),
+	)
+	return w.Flush()
+}
+
+// formatQuota renders a quota as "warn/limit", with "-" for unset values.
+func formatQuota(warn, limit int64) string {
+	if warn == 0 && limit == 0 {
+		return "-"
+	}
+	format := func(n int64) string {
+		if n == 0 {
+			return "-"
+		}
+		return formatBytes(n)
+	}
+	return format(warn) + "/" + format(limit)
+}
+
+func init() {
+	listCmd.Flags().
+		BoolVar(&listRuns, "runs", false, "list backup runs instead of backups")
+	listCmd.Flags().
+		BoolVar(&listUsage, "usage", false, "list storage usage and quotas per database instead of backups")
+	listCmd.MarkFlagsMutuallyExclusive("runs", "usage")
+	listCmd.Flags().
+		IntVar(&listLimit, "limit", 20, "entries to list (0 lists all)")
+}
@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/kebairia/backup/internal/operations"
+	"github.com/spf13/cobra"
+)
+
+var migrateOpts operations.MigrateOptions
+
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Copy a database from one instance to another",
+	Long: `Dump one database of the --from instance and restore it into the --to
+instance of the same engine, e.g. to refresh staging from production.
+
+The dump is staged in the source's artifact directory and removed after the
+restore, or piped straight into the restore with --no-disk (PostgreSQL
+pg_dump formats other than directory, MongoDB archives). Nothing is
+recorded in the catalog.
+Targets that already hold data need --force or allow_overwrite.`,
+	Example: `  bacli migrate --from postgres:prod --to postgres:staging
+  bacli migrate --from postgres:prod --to postgres:staging --no-disk --force
+  bacli migrate --from postgres:cluster --database billing --to postgres:staging --target-database billing_copy`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		result, err := operations.Migrate(cmd.Context(), ConfigFile, migrateOpts)
+		if err != nil {
+			return err
+		}
+		if jsonOutput() {
+			return printJSON(result)
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "migrated %s to %s (%d bytes in %s)\n",
+			result.Source, result.Target, result.Bytes, result.DurationString)
+		return nil
+	},
+}
+
+func init() {
+	migrateCmd.Flags().
+		StringVar(&migrateOpts.From, "from", "", "source instance (engine:name)")
+	migrateCmd.Flags().
+		StringVar(&migrateOpts.To, "to", "", "target instance of the same engine (engine:name)")
+	migrateCmd.Flags().
+		StringVar(&migrateOpts.Database, "database", "", "source database, for instances backing up every database")
+	migrateCmd.Flags().
+		StringVar(&migrateOpts.TargetDatabase, "target-database", "", "restore into this database instead of the target's own")
+	migrateCmd.Flags().
+		BoolVar(&migrateOpts.NoDisk, "no-disk", false, "pipe the dump into the restore without staging it on disk")
+	migrateCmd.Flags().
+		BoolVar(&migrateOpts.Force, "force", false, "restore even into a target that already holds data")
+	_ = migrateCmd.MarkFlagRequired("from")
+	_ = migrateCmd.MarkFlagRequired("to")
+	_ = migrateCmd.RegisterFlagCompletionFunc("database", completeDatabases)
+}
@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"github.com/kebairia/backup/internal/operations"
+	"github.com/spf13/cobra"
+)
+
+var (
+	migrateFrom string
+	migrateTo   string
+)
+
+var migrateStorageCmd = &cobra.Command{
+	Use:   "migrate-storage",
+	Short: "Copy all cataloged artifacts to another storage backend",
+	Long: `Copy every artifact and metadata file from one storage backend to another,
+verifying each object and updating the catalog with the new location.
+Then point the storage configuration at the new backend: restores and
+audits read the configured one.
+
+Backends: local, local:/path, remote, s3://bucket/prefix, rclone:name:path`,
+	Example: "  bacli migrate-storage --from local --to s3://bacli/nightly",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return operations.MigrateStorage(cmd.Context(), ConfigFile, migrateFrom, migrateTo)
+	},
+}
+
+func init() {
+	migrateStorageCmd.Flags().StringVar(&migrateFrom, "from", "local", "source storage backend")
+	migrateStorageCmd.Flags().StringVar(&migrateTo, "to", "", "target storage backend")
+	_ = migrateStorageCmd.MarkFlagRequired("to")
+}
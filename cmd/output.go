@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Output formats accepted by --output.
+const (
+	outputText = "text"
+	outputJSON = "json"
+)
+
+// outputFormat holds the global --output flag.
+var outputFormat string
+
+// validateOutput checks the --output flag.
+func validateOutput() error {
+	switch outputFormat {
+	case outputText, outputJSON:
+		return nil
+	default:
+		return fmt.Errorf("invalid --output %q (want %s or %s)", outputFormat, outputText, outputJSON)
+	}
+}
+
+// jsonOutput reports whether commands should print JSON documents.
+func jsonOutput() bool {
+	return outputFormat == outputJSON
+}
+
+// printJSON writes v to stdout as an indented JSON document.
+func printJSON(v any) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// formatBytes renders a byte count with a binary unit, e.g. "1.5 GiB".
+func formatBytes(n int64) string {
+	const unit = 1024
+	sign := ""
+	if n < 0 {
+		sign, n = "-", -n
+	}
+	if n < unit {
+		return fmt.Sprintf("%s%d B", sign, n)
+	}
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%s%.1f %ciB", sign, float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// formatAge renders an age in days and hours past a day, e.g. "3d4h", and
+// to the minute below, e.g. "5h12m".
+func formatAge(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+	if d >= 24*time.Hour {
+		days := d / (24 * time.Hour)
+		return fmt.Sprintf("%dd%dh", days, (d-days*24*time.Hour)/time.Hour)
+	}
+	d = d.Round(time.Minute)
+	return fmt.Sprintf("%dh%dm", d/time.Hour, (d%time.Hour)/time.Minute)
+}
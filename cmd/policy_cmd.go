@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/kebairia/backup/internal/operations"
+	"github.com/kebairia/backup/internal/policy"
+	"github.com/spf13/cobra"
+)
+
+var policyCmd = &cobra.Command{
+	Use:   "policy",
+	Short: "Evaluate backup coverage policies",
+}
+
+var policyCheckCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Check the effective configuration against the declared policies",
+	Long: `Check the effective configuration against the rules declared under
+"policies" and exit non-zero when any instance violates one, so CI/CD
+pipelines can reject changes that weaken backup posture.`,
+	Example: "  bacli policy check --profile production",
+	Args:    cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		policies, violations, err := operations.CheckPolicies(ConfigFile)
+		if err != nil {
+			return err
+		}
+
+		if jsonOutput() {
+			if violations == nil {
+				violations = []policy.Violation{}
+			}
+			if err := printJSON(violations); err != nil {
+				return err
+			}
+		} else if len(violations) == 0 {
+			fmt.Printf("%d policies checked, no violations\n", policies)
+		} else {
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "POLICY\tENGINE\tINSTANCE\tRULE\tMESSAGE")
+			for _, v := range violations {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", v.Policy, v.Engine, v.Instance, v.Rule, v.Message)
+			}
+			if err := w.Flush(); err != nil {
+				return err
+			}
+		}
+
+		if len(violations) > 0 {
+			return fmt.Errorf("%w: %d violations", policy.ErrViolation, len(violations))
+		}
+		return nil
+	},
+}
+
+func init() {
+	policyCmd.AddCommand(policyCheckCmd)
+}
@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kebairia/backup/internal/operations"
+	"github.com/spf13/cobra"
+)
+
+var pruneOpts operations.PruneOptions
+
+var pruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Remove unneeded backup artifacts",
+	Long: `Remove unneeded backup artifacts from the local backup directory.
+
+--orphans removes artifacts that no successful catalog entry accounts for:
+leftovers of failed or interrupted runs, and artifacts of databases without
+a metadata record.
+
+--retention applies retention.local.keep to the local copies and
+retention.remote.keep to the copies in storage. Local copies are removed
+only once uploaded; restore downloads them again when needed.
+
+Use --dry-run to print the deletion plan without removing anything: every
+local file and remote object (with the replicas holding copies of it), its
+age and size, and the space reclaimed per location.`,
+	Example: `  bacli prune --orphans --dry-run
+  bacli prune --retention`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		result, err := operations.Prune(cmd.Context(), ConfigFile, pruneOpts)
+		if jsonOutput() && result.Candidates != nil {
+			if perr := printJSON(result); perr != nil {
+				return perr
+			}
+			return err
+		}
+		if err != nil && result.Candidates == nil {
+			return err
+		}
+
+		if len(result.Candidates) == 0 {
+			fmt.Println("nothing to prune")
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ENGINE\tDATABASE\tLOCATION\tAGE\tSIZE\tPATH\tREASON")
+		now := time.Now()
+		for _, c := range result.Candidates {
+			location := c.Location
+			if len(c.Replicas) > 0 {
+				location += " (+" + strings.Join(c.Replicas, ", ") + ")"
+			}
+			size := formatBytes(c.SizeBytes)
+			if c.Location == operations.LocationRepository {
+				size = "-" // known once the repository is pruned
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+				c.Engine, c.Database, location, formatAge(now.Sub(c.CreatedAt)), size, c.Path, c.Reason)
+		}
+		if ferr := w.Flush(); ferr != nil {
+			return ferr
+		}
+		if result.DryRun {
+			fmt.Printf("\n%d artifacts would be removed (dry run), %s reclaimed%s\n",
+				len(result.Candidates), formatBytes(result.ReclaimBytes), reclaimByLocation(result.Candidates))
+		} else {
+			fmt.Printf("\n%d artifacts removed, %s freed\n", result.Removed, formatBytes(result.FreedBytes))
+		}
+		return err
+	},
+}
+
+// reclaimByLocation renders the space candidates take per location, e.g.
+// " (local 1.2 GiB, remote 3.4 GiB)", when they span several.
+func reclaimByLocation(candidates []operations.PruneCandidate) string {
+	var locations []string
+	bytes := make(map[string]int64)
+	for _, c := range candidates {
+		if c.Location == operations.LocationRepository {
+			continue
+		}
+		if _, ok := bytes[c.Location]; !ok {
+			locations = append(locations, c.Location)
+		}
+		bytes[c.Location] += c.Reclaim()
+	}
+	if len(locations) < 2 {
+		return ""
+	}
+	parts := make([]string, len(locations))
+	for i, location := range locations {
+		parts[i] = location + " " + formatBytes(bytes[location])
+	}
+	return " (" + strings.Join(parts, ", ") + ")"
+}
+
+func init() {
+	pruneCmd.Flags().
+		BoolVar(&pruneOpts.Orphans, "orphans", false, "remove artifacts without a successful catalog entry")
+	pruneCmd.Flags().
+		BoolVar(&pruneOpts.Retention, "retention", false, "remove local and remote copies beyond retention")
+	pruneCmd.Flags().
+		BoolVar(&pruneOpts.DryRun, "dry-run", false, "list what would be removed without removing it")
+}
@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/kebairia/backup/internal/operations"
+	"github.com/spf13/cobra"
+)
+
+var replicateOpts operations.ReplicateOptions
+
+var replicateCmd = &cobra.Command{
+	Use:   "replicate",
+	Short: "Copy uploaded artifacts to the storage replicas",
+	Long: `Copy the artifacts uploaded to the storage backend to the replicas listed
+under storage.replicas, recording each copy in the catalog.
+
+Backups replicate as they upload; a replica that was unreachable or added
+later misses artifacts. --missing copies only those, downloading them from
+the primary backend when the local copy was pruned.
+
+Use --dry-run to list what would be copied without copying anything.`,
+	Example: `  bacli replicate --missing --dry-run
+  bacli replicate --missing --replica eu-west`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		result, err := operations.Replicate(cmd.Context(), ConfigFile, replicateOpts)
+		if jsonOutput() {
+			if perr := printJSON(result); perr != nil {
+				return perr
+			}
+			return err
+		}
+		if len(result.Copies) == 0 {
+			if err == nil {
+				fmt.Println("nothing to replicate")
+			}
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ENGINE\tDATABASE\tREPLICA\tSIZE\tKEY\tSTATUS")
+		for _, c := range result.Copies {
+			status := c.Status
+			if result.DryRun {
+				status = "pending"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+				c.Engine, c.Database, c.Replica, formatBytes(c.SizeBytes), c.Key, status)
+		}
+		if ferr := w.Flush(); ferr != nil {
+			return ferr
+		}
+		if result.DryRun {
+			fmt.Printf("\n%d copies would be made (dry run)\n", len(result.Copies))
+		} else {
+			fmt.Printf("\n%d copies made, %d failed\n", len(result.Copies)-result.Failed, result.Failed)
+		}
+		return err
+	},
+}
+
+func init() {
+	replicateCmd.Flags().
+		BoolVar(&replicateOpts.Missing, "missing", false, "copy only artifacts not yet replicated")
+	replicateCmd.Flags().
+		StringVar(&replicateOpts.Replica, "replica", "", "copy to this replica only")
+	replicateCmd.Flags().
+		BoolVar(&replicateOpts.DryRun, "dry-run", false, "list what would be copied without copying it")
+}
@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/kebairia/backup/internal/operations"
+	"github.com/spf13/cobra"
+)
+
+var repoCheckReadData bool
+
+var repoCmd = &cobra.Command{
+	Use:   "repo",
+	Short: "Manage the deduplicating chunk repository",
+	Long: `Manage the chunk repository configured under "repository". File artifacts
+are split into content-defined chunks and only chunks no earlier backup
+stored are written, so successive dumps of a database that changes little
+take little more space than one.`,
+}
+
+var repoInitCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Create the chunk repository",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := operations.RepoInit(cmd.Context(), ConfigFile); err != nil {
+			return err
+		}
+		if !jsonOutput() {
+			fmt.Println("repository initialized")
+		}
+		return nil
+	},
+}
+
+var repoCheckCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Check the chunk repository for missing or damaged data",
+	Long: `Check that every chunk of every snapshot is indexed and that every pack is
+stored whole. --read-data also downloads every pack and verifies each chunk
+against its checksum, which reads the whole repository.`,
+	Example: "  bacli repo check --read-data",
+	Args:    cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		result, err := operations.RepoCheck(cmd.Context(), ConfigFile, repoCheckReadData)
+		if jsonOutput() {
+			if perr := printJSON(result); perr != nil {
+				return perr
+			}
+			return err
+		}
+		for _, problem := range result.Errors {
+			fmt.Println(problem)
+		}
+		if err == nil {
+			fmt.Printf("%d snapshots, %d packs and %d chunks checked, no errors\n",
+				result.Snapshots, result.Packs, result.Chunks)
+		}
+		return err
+	},
+}
+
+var repoStatsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show how much the chunk repository deduplicates",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		stats, err := operations.RepoStats(cmd.Context(), ConfigFile)
+		if err != nil {
+			return err
+		}
+		if jsonOutput() {
+			return printJSON(stats)
+		}
+		fmt.Printf("snapshots:     %d\n", stats.Snapshots)
+		fmt.Printf("chunks:        %d in %d packs\n", stats.Chunks, stats.Packs)
+		fmt.Printf("logical size:  %s\n", formatBytes(stats.LogicalBytes))
+		fmt.Printf("unique data:   %s\n", formatBytes(stats.UniqueBytes))
+		fmt.Printf("stored size:   %s\n", formatBytes(stats.StoredBytes))
+		fmt.Printf("dedup ratio:   %.2fx\n", stats.Ratio())
+		return nil
+	},
+}
+
+var repoPruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Delete packs no snapshot uses any more",
+	Long: `Delete the packs of the chunk repository that no snapshot uses any more.
+"bacli prune --retention" forgets the snapshots of expired backups and prunes
+the repository itself; this command is only needed after a failed prune. Do
+not run it while backups are running.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		result, err := operations.RepoPrune(cmd.Context(), ConfigFile)
+		if err != nil {
+			return err
+		}
+		if jsonOutput() {
+			return printJSON(result)
+		}
+		fmt.Printf("%d packs deleted, %s freed\n", result.PacksDeleted, formatBytes(result.FreedBytes))
+		return nil
+	},
+}
+
+func init() {
+	repoCheckCmd.Flags().
+		BoolVar(&repoCheckReadData, "read-data", false, "download every pack and verify every chunk")
+	repoCmd.AddCommand(repoInitCmd, repoCheckCmd, repoStatsCmd, repoPruneCmd)
+}
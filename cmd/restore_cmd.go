@@ -1,29 +1,113 @@
 package cmd
 
 import (
+	"bufio"
+	"errors"
 	"fmt"
+	"time"
 
-	"github.com/kebairia/backup/internal/config"
 	"github.com/kebairia/backup/internal/operations"
 	"github.com/spf13/cobra"
 )
 
+var (
+	restoreOpts operations.RestoreOptions
+	// restoreAt selects a backup by start time (see parseRestoreAt).
+	restoreAt string
+	// restoreTargetTime restores to a point in time (see parseRestoreAt).
+	restoreTargetTime string
+	// restoreInteractive picks the backup from the catalog with prompts.
+	restoreInteractive bool
+	// restoreStdin restores a single database from an artifact piped on stdin.
+	restoreStdin bool
+)
+
 var restoreCmd = &cobra.Command{
 	Use:   "restore",
 	Short: "Restore all databases based on config",
+	Example: `  bacli restore
+  bacli restore --database billing --target-database billing_restore_test
+  bacli restore --database billing --at 2025-04-24T21:00:00Z
+  bacli restore --engine mongodb --database app --target-time "2025-04-25 09:41:00"
+  bacli restore --run 01966999-a880-7c3e-9f1a-2b5d8e4c7a10
+  bacli restore -i
+  aws s3 cp s3://bucket/main.dump - | bacli restore --instance postgres:main --stdin`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		// Load config to derive default output directory
-		var config config.Config
-		if err := config.Load(ConfigFile); err != nil {
-			return fmt.Errorf("failed to load config: %w", err)
+		if restoreAt != "" {
+			at, err := parseRestoreAt("--at", restoreAt)
+			if err != nil {
+				return err
+			}
+			restoreOpts.At = at
+		}
+		if restoreTargetTime != "" {
+			at, err := parseRestoreAt("--target-time", restoreTargetTime)
+			if err != nil {
+				return err
+			}
+			restoreOpts.TargetTime = at
+		}
+		if restoreStdin {
+			if restoreInteractive {
+				return errors.New("--stdin and --interactive are exclusive")
+			}
+			return operations.RestoreFrom(cmd.Context(), ConfigFile, restoreOpts, cmd.InOrStdin())
+		}
+		if restoreInteractive {
+			p := prompter{in: bufio.NewReader(cmd.InOrStdin()), out: cmd.ErrOrStderr()}
+			if err := pickRestore(p, &restoreOpts); err != nil {
+				return err
+			}
 		}
-		if err := operations.RestoreAll(ConfigFile); err != nil {
+		report, err := operations.RestoreAll(cmd.Context(), ConfigFile, restoreOpts)
+		if jsonOutput() && (err == nil || len(report.Results) > 0) {
+			if perr := printJSON(report); perr != nil {
+				return perr
+			}
 		}
-		return nil
+		return err
 	},
 }
 
+// parseRestoreAt parses the time given to flag as RFC 3339 or as the local
+// "2006-01-02 15:04:05" shown by `bacli history`.
+func parseRestoreAt(flag, value string) (time.Time, error) {
+	if at, err := time.Parse(time.RFC3339, value); err == nil {
+		return at, nil
+	}
+	at, err := time.ParseInLocation(time.DateTime, value, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s %q: use RFC 3339 or %q", flag, value, time.DateTime)
+	}
+	return at, nil
+}
+
 func init() {
+	restoreCmd.Flags().
+		BoolVarP(&restoreInteractive, "interactive", "i", false, "pick the engine, database and backup from the catalog, then confirm")
+	restoreCmd.Flags().
+		StringVar(&restoreOpts.Engine, "engine", "", "restore only databases of this engine")
+	restoreCmd.Flags().
+		StringVar(&restoreOpts.Instance, "instance", "", "restore only this instance (name or engine:name)")
+	restoreCmd.Flags().
+		BoolVar(&restoreStdin, "stdin", false, "restore a single database from an artifact piped on stdin (see bacli backup --stdout)")
+	restoreCmd.Flags().
+		StringVar(&restoreAt, "at", "", "restore the backup started at this time (see bacli history) instead of the latest")
+	restoreCmd.Flags().
+		StringVar(&restoreTargetTime, "target-time", "", "restore as of this time, replaying incremental backups (MongoDB oplog captures) up to it")
+	restoreCmd.Flags().
+		StringVar(&restoreOpts.Run, "run", "", "restore every database backed up by this run (see bacli list --runs)")
 	restoreCmd.Flags().
 		StringP("source", "s", "", "path to backup source (defaults to <outuptu_dir>)")
+	restoreCmd.Flags().
+		StringVar(&restoreOpts.Database, "database", "", "restore only this database")
+	restoreCmd.Flags().
+		StringVar(&restoreOpts.TargetDatabase, "target-database", "", "restore into this database name instead of the original")
+	restoreCmd.Flags().
+		StringVar(&restoreOpts.TargetHost, "target-host", "", "restore onto this host instead of the original")
+	restoreCmd.Flags().
+		BoolVar(&restoreOpts.Force, "force", false, "restore even into targets that already hold data")
+	restoreCmd.Flags().
+		BoolVar(&restoreOpts.VerifyOnly, "verify-only", false, "validate artifacts (decompress, list contents) without restoring")
+	_ = restoreCmd.RegisterFlagCompletionFunc("database", completeDatabases)
 }
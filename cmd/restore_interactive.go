@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kebairia/backup/internal/operations"
+)
+
+// errRestoreAborted is returned when the operator declines the confirmation.
+var errRestoreAborted = errors.New("restore aborted")
+
+// prompter asks questions on out and reads answers from in.
+type prompter struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+// pickRestore lets the operator choose an engine, a database and one of its
+// successful backups from the catalog, then confirms the restore. It fills
+// opts with the selection.
+func pickRestore(p prompter, opts *operations.RestoreOptions) error {
+	runs, err := operations.History(ConfigFile, opts.Database, 0)
+	if err != nil {
+		return err
+	}
+	runs = slices.DeleteFunc(runs, func(run operations.Metadata) bool {
+		return !run.Restorable() || (opts.Engine != "" && run.Engine != opts.Engine)
+	})
+	if len(runs) == 0 {
+		return fmt.Errorf("%w in the catalog", operations.ErrNoRestorePoint)
+	}
+
+	engines := distinct(runs, func(run operations.Metadata) string { return run.Engine })
+	engine, err := choose(p, "Engine", engines, func(e string) string { return e })
+	if err != nil {
+		return err
+	}
+	runs = slices.DeleteFunc(runs, func(run operations.Metadata) bool { return run.Engine != engine })
+
+	databases := distinct(runs, func(run operations.Metadata) string { return run.Database })
+	name, err := choose(p, "Database", databases, func(d string) string { return d })
+	if err != nil {
+		return err
+	}
+	runs = slices.DeleteFunc(runs, func(run operations.Metadata) bool { return run.Database != name })
+
+	// runs are newest first
+	run, err := choose(p, "Backup", runs, func(run operations.Metadata) string {
+		return fmt.Sprintf("%s  %8s  %s",
+			run.StartedAt.Local().Format(time.DateTime),
+			formatBytes(run.SizeBytes),
+			run.FilePath,
+		)
+	})
+	if err != nil {
+		return err
+	}
+	opts.Engine, opts.Database, opts.At = run.Engine, run.Database, run.StartedAt
+
+	if opts.VerifyOnly {
+		return nil
+	}
+	target := run.Database
+	if opts.TargetDatabase != "" {
+		target = opts.TargetDatabase
+	}
+	host := "its configured host"
+	if opts.TargetHost != "" {
+		host = opts.TargetHost
+	}
+	fmt.Fprintf(p.out, "\nRestoring %s/%s from %s into %q on %s.\n",
+		run.Engine, run.Database, run.StartedAt.Local().Format(time.DateTime), target, host)
+	fmt.Fprintln(p.out, "Existing objects in the target are dropped and replaced.")
+	answer, err := p.ask(fmt.Sprintf("Type the target database name (%s) to continue: ", target))
+	if err != nil {
+		return err
+	}
+	if answer != target {
+		return errRestoreAborted
+	}
+	fmt.Fprintf(p.out, "Equivalent command: bacli restore --engine %s --database %s --at %s\n\n",
+		run.Engine, run.Database, run.StartedAt.Format(time.RFC3339))
+	return nil
+}
+
+// choose prints options numbered from 1 and returns the one picked. A single
+// option is picked without asking.
+func choose[T any](p prompter, title string, options []T, label func(T) string) (T, error) {
+	var zero T
+	if len(options) == 1 {
+		fmt.Fprintf(p.out, "%s: %s\n", title, label(options[0]))
+		return options[0], nil
+	}
+	fmt.Fprintf(p.out, "%s:\n", title)
+	for i, option := range options {
+		fmt.Fprintf(p.out, "  %2d) %s\n", i+1, label(option))
+	}
+	for {
+		answer, err := p.ask(fmt.Sprintf("Select %s [1-%d]: ", strings.ToLower(title), len(options)))
+		if err != nil {
+			return zero, err
+		}
+		n, err := strconv.Atoi(answer)
+		if err == nil && n >= 1 && n <= len(options) {
+			return options[n-1], nil
+		}
+		fmt.Fprintf(p.out, "Enter a number between 1 and %d.\n", len(options))
+	}
+}
+
+// ask prints question and returns the trimmed answer.
+func (p prompter) ask(question string) (string, error) {
+	fmt.Fprint(p.out, question)
+	answer, err := p.in.ReadString('\n')
+	switch {
+	case errors.Is(err, io.EOF) && answer == "":
+		return "", errRestoreAborted // input closed
+	case err != nil && !errors.Is(err, io.EOF):
+		return "", err
+	}
+	return strings.TrimSpace(answer), nil
+}
+
+// distinct returns the sorted distinct keys of runs.
+func distinct(runs []operations.Metadata, key func(operations.Metadata) string) []string {
+	var keys []string
+	for _, run := range runs {
+		if k := key(run); !slices.Contains(keys, k) {
+			keys = append(keys, k)
+		}
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -1,32 +1,140 @@
 package cmd
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/kebairia/backup/internal/config"
+	"github.com/kebairia/backup/internal/events"
 	"github.com/kebairia/backup/internal/logger"
+	"github.com/kebairia/backup/internal/operations"
+	"github.com/kebairia/backup/internal/progress"
+	"github.com/kebairia/backup/internal/redact"
+	"github.com/kebairia/backup/internal/telemetry"
 	"github.com/spf13/cobra"
 )
 
 // ConfigFile is the path to the YAML configuration.
 var (
 	ConfigFile string
+	// Profile selects a named config profile (overrides BACLI_PROFILE).
+	Profile string
+	// Tenant scopes every command to one tenant (overrides BACLI_TENANT).
+	Tenant string
+	// eventsTarget is the file or "unix:" socket lifecycle events are
+	// streamed to.
+	eventsTarget string
+	// logOptions holds the global output flags.
+	logOptions logger.Options
 	// rootCmd is the base command for bacli.
 	rootCmd = &cobra.Command{
 		Use:   "bacli",
 		Short: "CLI tool for database backup and restore",
 		Long: `bacli provides subcommands to back up and restore
 databases based on your YAML configuration file.`,
+		SilenceUsage: true,
+		// errors are printed by Execute, with credentials redacted
+		SilenceErrors: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := validateOutput(); err != nil {
+				return err
+			}
+			config.UseProfile(Profile)
+			config.UseTenant(Tenant)
+			// keep stdout clean for machine-readable documents and streams
+			logOptions.Stderr = jsonOutput() || backupStdout
+			// interactive runs show transfer progress on stderr; logs and
+			// tool output are written around it
+			if !logOptions.Quiet && !jsonOutput() && progress.Enable(os.Stderr) {
+				logOptions.Wrap = progress.Writer
+			}
+			logger.Configure(logOptions)
+			if _, err := logger.Init(); err != nil {
+				fmt.Fprintf(os.Stderr, "ERROR: logger init: %v\n", err)
+			}
+			if eventsTarget != "" {
+				return events.Open(eventsTarget)
+			}
+			return nil
+		},
 	}
 )
 
-// Execute runs the root command.
+// exitCancelled is the exit status of a run interrupted by SIGINT/SIGTERM,
+// following the shell convention of 128+SIGINT.
+const exitCancelled = 130
+
+// Execute runs the root command. SIGINT and SIGTERM cancel the command's
+// context so running backups stop, clean up and are recorded as failed.
 func Execute() {
-	log, err := logger.Init()
-	_ = err
-	if err := rootCmd.Execute(); err != nil {
-		log.Error("Error executing command: %v", err)
+	defer logger.Cleanup()
+	defer events.Close()
+	defer telemetry.Shutdown()
+	defer progress.Stop()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := rootCmd.ExecuteContext(ctx); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", redact.String(err.Error()))
+		code := exitCode(ctx, err) // before stop, which cancels ctx
+		stop()
+		progress.Stop()
+		telemetry.Shutdown()
+		events.Close()
+		logger.Cleanup()
+		os.Exit(code)
+	}
+}
+
+// exitCode maps a command error to the process exit status.
+func exitCode(ctx context.Context, err error) int {
+	if errors.Is(err, operations.ErrCancelled) || ctx.Err() != nil {
+		return exitCancelled
 	}
+	return 1
 }
 
 func init() {
+	rootCmd.PersistentFlags().
+		StringVarP(&ConfigFile, "config", "c", "./configs/config.yaml", "path to YAML config file, or a directory of *.yaml files")
+	rootCmd.PersistentFlags().
+		StringVar(&Profile, "profile", "", "config profile to apply (defaults to $BACLI_PROFILE)")
+	rootCmd.PersistentFlags().
+		StringVar(&Tenant, "tenant", "", "tenant to operate on (defaults to $BACLI_TENANT)")
+	rootCmd.PersistentFlags().
+		BoolVarP(&logOptions.Quiet, "quiet", "q", false, "only log errors, without progress or client tool output (for cron)")
+	rootCmd.PersistentFlags().
+		BoolVarP(&logOptions.Verbose, "verbose", "v", false, "log debug messages and run client tools verbosely")
+	rootCmd.MarkFlagsMutuallyExclusive("quiet", "verbose")
+	rootCmd.PersistentFlags().
+		StringVar(&eventsTarget, "events", "", "stream lifecycle events as JSON lines to a file, or a Unix socket as unix:/path")
+	rootCmd.PersistentFlags().
+		BoolVar(&logOptions.NoColor, "no-color", false, "disable colored output (also honors NO_COLOR)")
+	rootCmd.PersistentFlags().
+		StringVarP(&outputFormat, "output", "o", outputText, "output format: text or json (logs go to stderr with json)")
 	rootCmd.AddCommand(backupCmd)
 	rootCmd.AddCommand(restoreCmd)
+	rootCmd.AddCommand(migrateCmd)
+	rootCmd.AddCommand(migrateStorageCmd)
+	rootCmd.AddCommand(replicateCmd)
+	rootCmd.AddCommand(repoCmd)
+	rootCmd.AddCommand(verifyCmd)
+	rootCmd.AddCommand(drillCmd)
+	rootCmd.AddCommand(statusCmd)
+	rootCmd.AddCommand(statsCmd)
+	rootCmd.AddCommand(historyCmd)
+	rootCmd.AddCommand(chainCmd)
+	rootCmd.AddCommand(listCmd)
+	rootCmd.AddCommand(serveCmd)
+	rootCmd.AddCommand(policyCmd)
+	rootCmd.AddCommand(doctorCmd)
+	rootCmd.AddCommand(pruneCmd)
+	rootCmd.AddCommand(auditCmd)
+	rootCmd.AddCommand(catalogCmd)
+	rootCmd.AddCommand(inspectCmd)
+	rootCmd.AddCommand(completionCmd)
+	rootCmd.AddCommand(docsCmd)
 }
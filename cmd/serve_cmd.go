@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"github.com/kebairia/backup/internal/api"
+	"github.com/kebairia/backup/internal/config"
+	"github.com/spf13/cobra"
+)
+
+// serveListen overrides serve.listen.
+var serveListen string
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve backups and restores over an authenticated HTTP API",
+	Long: `Serve backups and restores over an authenticated HTTP API.
+
+Endpoints (JSON, "Authorization: Bearer <token>" required):
+
+  POST /backup   {"engine": "...", "database": "...", "labels": {...}}
+  POST /restore  {"database": "...", "at": "...", "run": "...", "target_database": "...", ...}
+  GET  /status   last run and lock of each database
+  GET  /backups  recorded backups, newest first (?database=&limit=)
+
+Backup and restore requests answer when the run ends, with the run report:
+200 when every database succeeded, 500 otherwise. One run executes at a
+time; concurrent requests get 409. The token is read from serve.token_file
+or $BACLI_API_TOKEN (see serve.token_env).
+
+The configuration file and its includes are reloaded when they change:
+runs started afterwards use the new settings, and an invalid edit is logged
+and ignored. The serve settings and the token apply at startup only.`,
+	Example: `  BACLI_API_TOKEN=s3cret bacli serve --listen :8080
+  curl -X POST -H "Authorization: Bearer s3cret" -d '{"database":"billing"}' http://backup-host:8080/backup`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var cfg config.Config
+		if err := cfg.Load(ConfigFile); err != nil {
+			return err
+		}
+		if serveListen != "" {
+			cfg.Serve.Listen = serveListen
+		}
+		return api.Serve(cmd.Context(), ConfigFile, cfg.Serve)
+	},
+}
+
+func init() {
+	serveCmd.Flags().
+		StringVar(&serveListen, "listen", "", "address to listen on (overrides serve.listen, default :8080)")
+}
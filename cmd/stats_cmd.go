@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kebairia/backup/internal/operations"
+	"github.com/spf13/cobra"
+)
+
+var statsDays int
+
+var statsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show backup growth trends and projected storage needs",
+	Long: `Show backup growth trends and projected storage needs.
+
+For every database in the catalog the artifacts on disk are used to compute
+the size trend (least-squares growth per day) and the space needed --days
+from now to keep retention.local.keep artifacts of the projected size.
+
+Use -o json to feed capacity planning spreadsheets.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		report, err := operations.Stats(ConfigFile, statsDays)
+		if err != nil {
+			return err
+		}
+		if jsonOutput() {
+			return printJSON(report)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ENGINE\tDATABASE\tBACKUPS\tLAST BACKUP\tLATEST\tON DISK\tGROWTH/DAY\tPROJECTED")
+		for _, s := range report.Databases {
+			lastBackup := "-"
+			if !s.LastBackup.IsZero() {
+				lastBackup = s.LastBackup.Local().Format(time.DateTime)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\t%s\t%s\t%s\n",
+				s.Engine,
+				s.Database,
+				s.Backups,
+				lastBackup,
+				formatBytes(s.LatestBytes),
+				formatBytes(s.TotalBytes),
+				formatBytes(int64(s.GrowthPerDay)),
+				formatBytes(s.ProjectedBytes),
+			)
+		}
+		fmt.Fprintf(w, "TOTAL\t\t\t\t\t%s\t\t%s\n",
+			formatBytes(report.TotalBytes),
+			formatBytes(report.ProjectedBytes),
+		)
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		fmt.Printf("\nProjection: %d days ahead, keeping %d backups per database.\n",
+			report.HorizonDays, report.Keep)
+		return nil
+	},
+}
+
+func init() {
+	statsCmd.Flags().
+		IntVar(&statsDays, "days", 90, "how many days ahead to project storage needs")
+}
@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kebairia/backup/internal/notify"
+	"github.com/kebairia/backup/internal/operations"
+	"github.com/spf13/cobra"
+)
+
+// Formats accepted by status --format besides the table.
+const (
+	statusNagios = "nagios"
+	statusZabbix = "zabbix"
+)
+
+var (
+	statusFormat       string
+	statusWarning      time.Duration
+	statusCritical     time.Duration
+	statusZabbixServer string
+	statusZabbixHost   string
+)
+
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the last run and lock of each database",
+	Long: `Show the last run and lock of each database.
+
+State is read from the configured state backend. With state.backend set to
+"vault" the table reflects every bacli host sharing the same Vault mount.
+
+--format nagios prints a Nagios plugin line, e.g.
+
+  BACKUP WARNING - 1 of 2 databases: postgres/billing older than 26h0m0s | ...
+
+with age, size and duration perfdata per database, and exits 0, 1, 2 or 3
+for OK, WARNING, CRITICAL or UNKNOWN. A database is CRITICAL without a
+successful backup younger than --critical and WARNING past --warning or
+when its last run failed.
+
+--format zabbix reports the same checks as trapper items
+bacli.{state,age,size,duration}[<engine>/<database>]: sent to
+--zabbix-server when set, printed in zabbix_sender input format otherwise.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		statuses, err := operations.Status(cmd.Context(), ConfigFile)
+		if statusFormat == statusNagios {
+			if err != nil {
+				fmt.Printf("BACKUP UNKNOWN - %v\n", err)
+				os.Exit(operations.FreshnessUnknown)
+			}
+			checks := operations.CheckFreshness(statuses, time.Now(), statusWarning, statusCritical)
+			fmt.Println(operations.NagiosReport(checks, statusWarning, statusCritical))
+			os.Exit(operations.WorstFreshness(checks))
+		}
+		if err != nil {
+			return err
+		}
+		switch statusFormat {
+		case statusZabbix:
+			return sendZabbix(cmd, operations.CheckFreshness(statuses, time.Now(), statusWarning, statusCritical))
+		case outputText:
+		default:
+			return fmt.Errorf("invalid --format %q (want %s, %s or %s)",
+				statusFormat, outputText, statusNagios, statusZabbix)
+		}
+		if jsonOutput() {
+			return printJSON(statuses)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ENGINE\tDATABASE\tSTATUS\tLAST RUN\tHOST\tLOCKED BY")
+		for _, s := range statuses {
+			lockedBy := s.LockedBy
+			if lockedBy == "" {
+				lockedBy = "-"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+				s.Engine,
+				s.Database,
+				s.Status,
+				s.CompletedAt.Local().Format(time.DateTime),
+				s.Host,
+				lockedBy,
+			)
+		}
+		return w.Flush()
+	},
+}
+
+// sendZabbix reports checks to --zabbix-server, or prints them for
+// zabbix_sender when no server is given.
+func sendZabbix(cmd *cobra.Command, checks []operations.Freshness) error {
+	host := statusZabbixHost
+	if host == "" {
+		host, _ = os.Hostname()
+	}
+	items := operations.ZabbixItems(host, checks)
+	if statusZabbixServer == "" {
+		return notify.WriteZabbixItems(os.Stdout, items)
+	}
+	sender, err := notify.NewZabbixSender(statusZabbixServer)
+	if err != nil {
+		return err
+	}
+	info, err := sender.Send(cmd.Context(), items)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Sent %d items to %s: %s\n", len(items), sender.Address, info)
+	return nil
+}
+
+func init() {
+	statusCmd.Flags().
+		StringVar(&statusFormat, "format", outputText, "output format: text, nagios or zabbix")
+	statusCmd.Flags().
+		DurationVar(&statusWarning, "warning", 26*time.Hour, "age of the latest successful backup that is WARNING (0 disables)")
+	statusCmd.Flags().
+		DurationVar(&statusCritical, "critical", 50*time.Hour, "age of the latest successful backup that is CRITICAL (0 disables)")
+	statusCmd.Flags().
+		StringVar(&statusZabbixServer, "zabbix-server", "", "Zabbix server or proxy to send items to with --format zabbix (host[:port])")
+	statusCmd.Flags().
+		StringVar(&statusZabbixHost, "zabbix-host", "", "Zabbix host the items belong to (defaults to the hostname)")
+}
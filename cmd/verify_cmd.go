@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"github.com/kebairia/backup/internal/operations"
+	"github.com/spf13/cobra"
+)
+
+var verifyOpts operations.VerifyOptions
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Verify the latest backup of each database",
+	Long: `Verify the latest backup of each database.
+
+By default only the artifact is checked. With --deep the backup is restored
+into a throwaway database on the verification instance (verify.host) and
+the restored tables/collections are counted. With --signatures the artifact
+is checksummed and its signed checksum verified (backup.signing), to confirm
+its provenance before restoring it.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		report, err := operations.VerifyAll(cmd.Context(), ConfigFile, verifyOpts)
+		if jsonOutput() && len(report.Results) > 0 {
+			if perr := printJSON(report); perr != nil {
+				return perr
+			}
+		}
+		return err
+	},
+}
+
+func init() {
+	verifyCmd.Flags().
+		BoolVar(&verifyOpts.Deep, "deep", false, "restore into a throwaway database and run sanity queries")
+	verifyCmd.Flags().
+		BoolVar(&verifyOpts.Signatures, "signatures", false, "verify the signature of each artifact's checksum")
+	verifyCmd.Flags().
+		StringVar(&verifyOpts.Database, "database", "", "verify only this database")
+	_ = verifyCmd.RegisterFlagCompletionFunc("database", completeDatabases)
+}
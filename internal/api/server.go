@@ -0,0 +1,330 @@
+// Package api serves bacli operations over HTTP so that CI pipelines and
+// other services can trigger backups and restores without shell access to
+// the backup host.
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kebairia/backup/internal/config"
+	"github.com/kebairia/backup/internal/logger"
+	"github.com/kebairia/backup/internal/operations"
+	"github.com/kebairia/backup/internal/redact"
+)
+
+// ErrNoToken indicates that no API token is configured; the server refuses
+// to start unauthenticated.
+var ErrNoToken = errors.New("no API token configured")
+
+const (
+	defaultListen   = ":8080"
+	defaultTokenEnv = "BACLI_API_TOKEN"
+	// shutdownTimeout bounds the wait for running requests on shutdown.
+	// Backups still running by then were cancelled with the server context.
+	shutdownTimeout = 30 * time.Second
+)
+
+// Server handles the API requests. Backups and restores run one at a time;
+// a request arriving while one runs gets 409 Conflict.
+type Server struct {
+	ctx        context.Context // cancels running operations on shutdown
+	configPath string
+	token      string
+	log        logger.Logger
+
+	running sync.Mutex // held while a backup or restore runs
+}
+
+// New returns a Server running operations with the configuration at
+// configPath and accepting requests that carry token. Operations are
+// cancelled with ctx, not when the client disconnects.
+func New(ctx context.Context, configPath, token string) *Server {
+	return &Server{ctx: ctx, configPath: configPath, token: token, log: logger.Global()}
+}
+
+// Handler returns the routes of the API, all behind token authentication:
+//
+//	POST /backup   run a backup (body: BackupRequest)
+//	POST /restore  run a restore (body: RestoreRequest)
+//	GET  /status   last run and lock of each database
+//	GET  /backups  recorded backups, newest first (?database=&limit=)
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /backup", s.backup)
+	mux.HandleFunc("POST /restore", s.restore)
+	mux.HandleFunc("GET /status", s.status)
+	mux.HandleFunc("GET /backups", s.backups)
+	return s.authenticate(mux)
+}
+
+// BackupRequest selects what POST /backup backs up. Empty fields select
+// every configured database.
+type BackupRequest struct {
+	Engine   string            `json:"engine,omitempty"`
+	Database string            `json:"database,omitempty"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	// IgnoreWindow backs up outside backup windows and on blackout dates.
+	IgnoreWindow bool `json:"ignore_window,omitempty"`
+}
+
+// RestoreRequest mirrors the flags of `bacli restore`.
+type RestoreRequest struct {
+	Engine         string    `json:"engine,omitempty"`
+	Database       string    `json:"database,omitempty"`
+	At             time.Time `json:"at,omitzero"`
+	Run            string    `json:"run,omitempty"`
+	TargetDatabase string    `json:"target_database,omitempty"`
+	TargetHost     string    `json:"target_host,omitempty"`
+	VerifyOnly     bool      `json:"verify_only,omitempty"`
+	Force          bool      `json:"force,omitempty"`
+}
+
+func (s *Server) backup(w http.ResponseWriter, r *http.Request) {
+	var req BackupRequest
+	if !decode(w, r, &req) || !s.acquire(w) {
+		return
+	}
+	defer s.running.Unlock()
+
+	report, err := operations.BackupAll(s.ctx, s.configPath, operations.BackupOptions{
+		Engine:       req.Engine,
+		Database:     req.Database,
+		Labels:       req.Labels,
+		IgnoreWindow: req.IgnoreWindow,
+	})
+	if err != nil && !errors.Is(err, operations.ErrRunFailed) {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, reportStatus(report.Failed()), report.Redacted())
+}
+
+func (s *Server) restore(w http.ResponseWriter, r *http.Request) {
+	var req RestoreRequest
+	if !decode(w, r, &req) || !s.acquire(w) {
+		return
+	}
+	defer s.running.Unlock()
+
+	report, err := operations.RestoreAll(s.ctx, s.configPath, operations.RestoreOptions{
+		Engine:         req.Engine,
+		Database:       req.Database,
+		At:             req.At,
+		Run:            req.Run,
+		TargetDatabase: req.TargetDatabase,
+		TargetHost:     req.TargetHost,
+		VerifyOnly:     req.VerifyOnly,
+		Force:          req.Force,
+	})
+	if err != nil && !errors.Is(err, operations.ErrRunFailed) {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, reportStatus(report.Failed()), report.Redacted())
+}
+
+func (s *Server) status(w http.ResponseWriter, r *http.Request) {
+	statuses, err := operations.Status(r.Context(), s.configPath)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, statuses)
+}
+
+func (s *Server) backups(w http.ResponseWriter, r *http.Request) {
+	limit := 0
+	if value := r.URL.Query().Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			writeJSON(w, http.StatusBadRequest, errorBody{Error: "invalid limit " + strconv.Quote(value)})
+			return
+		}
+		limit = n
+	}
+	runs, err := operations.History(s.configPath, r.URL.Query().Get("database"), limit)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, runs)
+}
+
+// acquire takes the run slot, or answers 409 Conflict when another backup
+// or restore is running.
+func (s *Server) acquire(w http.ResponseWriter) bool {
+	if !s.running.TryLock() {
+		writeJSON(w, http.StatusConflict, errorBody{Error: "another backup or restore is running"})
+		return false
+	}
+	return true
+}
+
+// authenticate rejects requests without the bearer token.
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="bacli"`)
+			writeJSON(w, http.StatusUnauthorized, errorBody{Error: "unauthorized"})
+			return
+		}
+		s.log.Info("api request",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"remote", r.RemoteAddr,
+		)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Serve listens on cfg.Listen until ctx is cancelled, then shuts down.
+//
+// The configuration file and its includes are watched while serving: a
+// valid new configuration applies to the runs started after it is saved,
+// and an invalid one is logged and ignored. The serve settings themselves
+// and the token are read once, at startup.
+func Serve(ctx context.Context, configPath string, cfg config.ServeConfig) error {
+	token, err := readToken(cfg)
+	if err != nil {
+		return err
+	}
+	watcher, err := config.NewWatcher(configPath, logReload)
+	if err != nil {
+		return err
+	}
+	go func() {
+		if err := watcher.Run(ctx); err != nil {
+			logger.Global().Warn("config hot-reload disabled", "error", err.Error())
+		}
+	}()
+	listen := cfg.Listen
+	if listen == "" {
+		listen = defaultListen
+	}
+	srv := &http.Server{
+		Addr:              listen,
+		Handler:           New(ctx, configPath, token).Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	errc := make(chan error, 1)
+	go func() {
+		if cfg.TLSCert != "" && cfg.TLSKey != "" {
+			errc <- srv.ListenAndServeTLS(cfg.TLSCert, cfg.TLSKey)
+		} else {
+			errc <- srv.ListenAndServe()
+		}
+	}()
+	logger.Global().Info("api listening", "address", listen, "tls", cfg.TLSCert != "")
+
+	select {
+	case err := <-errc:
+		return fmt.Errorf("api server: %w", err)
+	case <-ctx.Done():
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("api shutdown: %w", err)
+	}
+	return nil
+}
+
+// logReload logs the outcome of a configuration reload.
+func logReload(changes []config.Change, err error) {
+	log := logger.Global()
+	if err != nil {
+		log.Error("config reload rejected, keeping the previous configuration", "error", err.Error())
+		return
+	}
+	for _, change := range changes {
+		log.Info("config setting changed", "key", change.Key, "old", change.Old, "new", change.New)
+	}
+	log.Info("config reloaded", "changes", len(changes))
+}
+
+// readToken returns the API token from token_file or the token_env variable.
+func readToken(cfg config.ServeConfig) (string, error) {
+	if cfg.TokenFile != "" {
+		data, err := os.ReadFile(cfg.TokenFile)
+		if err != nil {
+			return "", fmt.Errorf("read API token: %w", err)
+		}
+		if token := strings.TrimSpace(string(data)); token != "" {
+			return token, nil
+		}
+		return "", fmt.Errorf("%w: %s is empty", ErrNoToken, cfg.TokenFile)
+	}
+	env := cfg.TokenEnv
+	if env == "" {
+		env = defaultTokenEnv
+	}
+	if token := os.Getenv(env); token != "" {
+		return token, nil
+	}
+	return "", fmt.Errorf("%w: set serve.token_file or $%s", ErrNoToken, env)
+}
+
+// errorBody is the JSON body of error responses.
+type errorBody struct {
+	Error string `json:"error"`
+}
+
+// decode reads the JSON request body into v. An empty body leaves v as is.
+func decode(w http.ResponseWriter, r *http.Request, v any) bool {
+	if r.ContentLength == 0 {
+		return true
+	}
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorBody{Error: "invalid request body: " + err.Error()})
+		return false
+	}
+	return true
+}
+
+// reportStatus answers 200 when every database succeeded and 500 otherwise;
+// the report is sent either way.
+func reportStatus(failed int) int {
+	if failed > 0 {
+		return http.StatusInternalServerError
+	}
+	return http.StatusOK
+}
+
+// writeError maps err to a status code and writes it as JSON.
+func writeError(w http.ResponseWriter, err error) {
+	code := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, operations.ErrNoDatabase),
+		errors.Is(err, operations.ErrRestoreTarget):
+		code = http.StatusBadRequest
+	case errors.Is(err, operations.ErrUnknownRun),
+		errors.Is(err, operations.ErrNoRestorePoint):
+		code = http.StatusNotFound
+	case errors.Is(err, operations.ErrCancelled):
+		code = http.StatusServiceUnavailable
+	}
+	writeJSON(w, code, errorBody{Error: redact.String(err.Error())})
+}
+
+// writeJSON writes v as the JSON body of a response with status code.
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(v)
+}
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kebairia/backup/internal/config"
+	"github.com/kebairia/backup/internal/logger"
+)
+
+func TestAuthenticate(t *testing.T) {
+	if _, err := logger.Init(); err != nil {
+		t.Fatal(err)
+	}
+	handler := New(context.Background(), "unused.yaml", "s3cret").Handler()
+	tests := []struct {
+		name   string
+		header string
+		want   int
+	}{
+		{"missing", "", http.StatusUnauthorized},
+		{"wrong token", "Bearer nope", http.StatusUnauthorized},
+		{"wrong scheme", "Basic s3cret", http.StatusUnauthorized},
+		{"valid", "Bearer s3cret", http.StatusBadRequest}, // reaches the handler
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/backups?limit=x", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadTokenRequiresToken(t *testing.T) {
+	t.Setenv(defaultTokenEnv, "")
+	if _, err := readToken(config.ServeConfig{}); err == nil {
+		t.Fatal("readToken succeeded without a token")
+	}
+	t.Setenv(defaultTokenEnv, "s3cret")
+	if token, err := readToken(config.ServeConfig{}); err != nil || token != "s3cret" {
+		t.Fatalf("readToken = %q, %v", token, err)
+	}
+}
@@ -0,0 +1,91 @@
+// Package chunkstore implements a content-addressed repository for backup
+// artifacts. Artifacts are split into chunks at content-defined boundaries,
+// so that data unchanged between two dumps yields the same chunks even when
+// bytes were inserted or removed before it; each distinct chunk is stored
+// once, compressed, in pack files on a storage backend.
+package chunkstore
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"io"
+)
+
+// Chunk size bounds. Boundaries fall where the rolling hash matches
+// chunkMask, on average every AvgChunkSize bytes past MinChunkSize.
+const (
+	MinChunkSize = 512 << 10
+	AvgChunkSize = 1 << 20
+	MaxChunkSize = 8 << 20
+)
+
+// chunkMask selects the 20 high bits of the hash (log2 AvgChunkSize); the
+// high bits depend on the last 64 bytes read.
+const chunkMask = uint64(AvgChunkSize-1) << 44
+
+// gear maps each byte to a pseudo-random value. It is derived from SHA-256
+// so that every build chunks identically; changing it would stop new
+// chunks from deduplicating against stored ones.
+var gear = func() (table [256]uint64) {
+	for i := range table {
+		sum := sha256.Sum256([]byte{byte(i)})
+		table[i] = binary.LittleEndian.Uint64(sum[:8])
+	}
+	return table
+}()
+
+// Chunker splits a stream with a gear-based rolling hash.
+type Chunker struct {
+	r     io.Reader
+	buf   []byte // read so far; buf[start:] is not yet returned
+	start int
+	eof   bool
+}
+
+// NewChunker returns a Chunker reading r.
+func NewChunker(r io.Reader) *Chunker {
+	return &Chunker{r: r, buf: make([]byte, 0, 2*MaxChunkSize)}
+}
+
+// Next returns the next chunk, or io.EOF after the last one. The chunk is
+// only valid until the next call.
+func (c *Chunker) Next() ([]byte, error) {
+	if len(c.buf)-c.start < MaxChunkSize && !c.eof {
+		c.buf = c.buf[:copy(c.buf, c.buf[c.start:])]
+		c.start = 0
+		for len(c.buf) < cap(c.buf) && !c.eof {
+			n, err := c.r.Read(c.buf[len(c.buf):cap(c.buf)])
+			c.buf = c.buf[:len(c.buf)+n]
+			if err == io.EOF {
+				c.eof = true
+			} else if err != nil {
+				return nil, err
+			}
+		}
+	}
+	data := c.buf[c.start:]
+	if len(data) == 0 {
+		return nil, io.EOF
+	}
+	n := cut(data)
+	c.start += n
+	return data[:n], nil
+}
+
+// cut returns the length of the chunk at the start of data.
+func cut(data []byte) int {
+	if len(data) <= MinChunkSize {
+		return len(data)
+	}
+	limit := min(len(data), MaxChunkSize)
+	var hash uint64
+	// the hash only depends on the last 64 bytes, so start just before
+	// the minimum size
+	for i := MinChunkSize - 64; i < limit; i++ {
+		hash = hash<<1 + gear[data[i]]
+		if i >= MinChunkSize && hash&chunkMask == 0 {
+			return i + 1
+		}
+	}
+	return limit
+}
@@ -0,0 +1,67 @@
+package chunkstore
+
+import (
+	"bytes"
+	"io"
+	"math/rand/v2"
+	"testing"
+)
+
+// randomBytes returns n reproducible pseudo-random bytes.
+func randomBytes(seed uint64, n int) []byte {
+	data := make([]byte, n)
+	rng := rand.NewChaCha8([32]byte{byte(seed)})
+	_, _ = rng.Read(data)
+	return data
+}
+
+func chunks(t *testing.T, data []byte) [][]byte {
+	t.Helper()
+	var out [][]byte
+	chunker := NewChunker(bytes.NewReader(data))
+	for {
+		chunk, err := chunker.Next()
+		if err == io.EOF {
+			return out
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, bytes.Clone(chunk))
+	}
+}
+
+func TestChunker(t *testing.T) {
+	data := randomBytes(1, 24<<20)
+	got := chunks(t, data)
+	if joined := bytes.Join(got, nil); !bytes.Equal(joined, data) {
+		t.Fatal("chunks do not add up to the input")
+	}
+	for i, chunk := range got {
+		if len(chunk) > MaxChunkSize || (len(chunk) < MinChunkSize && i < len(got)-1) {
+			t.Errorf("chunk %d has %d bytes", i, len(chunk))
+		}
+	}
+
+	// bytes inserted near the start only change the chunks around them
+	edited := append(append(bytes.Clone(data[:1000]), "inserted"...), data[1000:]...)
+	seen := make(map[string]bool)
+	for _, chunk := range got {
+		seen[string(chunk)] = true
+	}
+	shared := 0
+	for _, chunk := range chunks(t, edited) {
+		if seen[string(chunk)] {
+			shared++
+		}
+	}
+	if shared < len(got)-2 {
+		t.Errorf("%d of %d chunks survive an insertion", shared, len(got))
+	}
+}
+
+func TestChunkerEmpty(t *testing.T) {
+	if got := chunks(t, nil); len(got) != 0 {
+		t.Errorf("empty input gives %d chunks", len(got))
+	}
+}
@@ -0,0 +1,759 @@
+package chunkstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kebairia/backup/internal/storage"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Repository layout, as keys of the storage backend:
+//
+//	config.json             repository format and chunker parameters
+//	data/<xx>/<pack id>     packs: concatenated zstd-compressed chunks
+//	index/<id>.json         where the chunks of some packs are stored
+//	snapshots/<id>.json     the chunk list of one stored artifact
+//
+// Pack ids are the SHA-256 of the pack, chunk ids the SHA-256 of the chunk;
+// <xx> is the first two hex digits of the pack id.
+const (
+	configKey      = "config.json"
+	dataPrefix     = "data/"
+	indexPrefix    = "index/"
+	snapshotPrefix = "snapshots/"
+
+	formatVersion = 1
+)
+
+// DefaultPackSize is the size above which a pack is closed and uploaded.
+const DefaultPackSize = 16 << 20
+
+// orphanGrace is how old a pack missing from every index must be before
+// Prune deletes it: younger packs may belong to a save still running.
+const orphanGrace = 24 * time.Hour
+
+var (
+	// ErrNotInitialized indicates that the backend holds no repository.
+	ErrNotInitialized = errors.New("chunk repository not initialized")
+	// ErrInitialized indicates that Init found an existing repository.
+	ErrInitialized = errors.New("chunk repository already initialized")
+	// ErrSnapshotNotFound indicates an unknown snapshot id.
+	ErrSnapshotNotFound = errors.New("snapshot not found")
+	// ErrCorrupt indicates missing or damaged repository data.
+	ErrCorrupt = errors.New("chunk repository is corrupt")
+)
+
+// repoConfig is config.json. The chunker parameters are recorded so that a
+// repository is never written with different boundaries by mistake.
+type repoConfig struct {
+	Version      int       `json:"version"`
+	ID           string    `json:"id"`
+	CreatedAt    time.Time `json:"created_at"`
+	MinChunkSize int       `json:"min_chunk_size"`
+	AvgChunkSize int       `json:"avg_chunk_size"`
+	MaxChunkSize int       `json:"max_chunk_size"`
+}
+
+// Snapshot is one artifact stored in the repository.
+type Snapshot struct {
+	ID   string    `json:"id"`
+	Name string    `json:"name"` // e.g. the artifact's path under the backup directory
+	Time time.Time `json:"time"`
+	Size int64     `json:"size"` // of the artifact
+	// Added is the compressed size of the chunks the save stored; the rest
+	// of the artifact deduplicated against earlier saves.
+	Added  int64    `json:"added"`
+	Chunks []string `json:"chunks"`
+}
+
+// packIndex lists the chunks of one pack.
+type packIndex struct {
+	Pack  string `json:"pack"`
+	Blobs []blob `json:"blobs"`
+}
+
+// blob is a compressed chunk within a pack.
+type blob struct {
+	ID     string `json:"id"`
+	Offset int64  `json:"offset"`
+	Length int64  `json:"length"` // compressed
+	Size   int64  `json:"size"`   // uncompressed
+}
+
+// indexFile is index/<id>.json.
+type indexFile struct {
+	Packs []packIndex `json:"packs"`
+}
+
+// location is where a chunk is stored.
+type location struct {
+	pack   string
+	offset int64
+	length int64
+	size   int64
+}
+
+// Repository is a chunk repository on a storage backend. It is safe for
+// concurrent saves.
+type Repository struct {
+	backend  storage.Backend
+	scratch  string
+	packSize int
+	encoder  *zstd.Encoder
+	decoder  *zstd.Decoder
+
+	mu    sync.Mutex          // guards index
+	index map[string]location // chunk id to location
+	packs map[string]int64    // pack id to indexed size
+}
+
+// Option configures a Repository.
+type Option func(*Repository)
+
+// WithPackSize sets the size above which packs are uploaded.
+func WithPackSize(size int) Option {
+	return func(r *Repository) {
+		if size > 0 {
+			r.packSize = size
+		}
+	}
+}
+
+// WithScratchDir sets the directory holding packs and index files while
+// they are uploaded (default: the system temporary directory).
+func WithScratchDir(dir string) Option {
+	return func(r *Repository) { r.scratch = dir }
+}
+
+func newRepository(backend storage.Backend, opts ...Option) (*Repository, error) {
+	r := &Repository{
+		backend:  backend,
+		packSize: DefaultPackSize,
+		index:    make(map[string]location),
+		packs:    make(map[string]int64),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	var err error
+	if r.encoder, err = zstd.NewWriter(nil); err != nil {
+		return nil, fmt.Errorf("zstd encoder: %w", err)
+	}
+	if r.decoder, err = zstd.NewReader(nil); err != nil {
+		return nil, fmt.Errorf("zstd decoder: %w", err)
+	}
+	return r, nil
+}
+
+// Init creates an empty repository on backend.
+func Init(ctx context.Context, backend storage.Backend, opts ...Option) (*Repository, error) {
+	r, err := newRepository(backend, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if exists, err := r.exists(ctx, configKey); err != nil {
+		return nil, err
+	} else if exists {
+		return nil, fmt.Errorf("%w on %s", ErrInitialized, backend.Name())
+	}
+	cfg := repoConfig{
+		Version:      formatVersion,
+		ID:           newID(),
+		CreatedAt:    time.Now().UTC(),
+		MinChunkSize: MinChunkSize,
+		AvgChunkSize: AvgChunkSize,
+		MaxChunkSize: MaxChunkSize,
+	}
+	if err := r.putJSON(ctx, configKey, cfg); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Open opens the repository on backend and loads its index.
+func Open(ctx context.Context, backend storage.Backend, opts ...Option) (*Repository, error) {
+	r, err := newRepository(backend, opts...)
+	if err != nil {
+		return nil, err
+	}
+	var cfg repoConfig
+	if err := r.getJSON(ctx, configKey, &cfg); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, fmt.Errorf("%w on %s (run `bacli repo init`)", ErrNotInitialized, backend.Name())
+		}
+		return nil, err
+	}
+	if cfg.Version != formatVersion {
+		return nil, fmt.Errorf("%w: format version %d is not supported", ErrCorrupt, cfg.Version)
+	}
+	if cfg.MinChunkSize != MinChunkSize || cfg.AvgChunkSize != AvgChunkSize || cfg.MaxChunkSize != MaxChunkSize {
+		return nil, fmt.Errorf("%w: chunk sizes %d/%d/%d differ from this build's",
+			ErrCorrupt, cfg.MinChunkSize, cfg.AvgChunkSize, cfg.MaxChunkSize)
+	}
+	if err := r.loadIndex(ctx); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// loadIndex reads every index file.
+func (r *Repository) loadIndex(ctx context.Context) error {
+	files, err := r.indexFiles(ctx)
+	if err != nil {
+		return err
+	}
+	index := make(map[string]location)
+	packs := make(map[string]int64)
+	for _, key := range files {
+		var file indexFile
+		if err := r.getJSON(ctx, key, &file); err != nil {
+			return err
+		}
+		for _, p := range file.Packs {
+			addPack(index, packs, p)
+		}
+	}
+	r.mu.Lock()
+	r.index, r.packs = index, packs
+	r.mu.Unlock()
+	return nil
+}
+
+func addPack(index map[string]location, packs map[string]int64, p packIndex) {
+	var size int64
+	for _, b := range p.Blobs {
+		index[b.ID] = location{pack: p.Pack, offset: b.Offset, length: b.Length, size: b.Size}
+		size = max(size, b.Offset+b.Length)
+	}
+	packs[p.Pack] = size
+}
+
+// Save splits the artifact read from rd into chunks, stores the chunks the
+// repository does not hold yet and records the artifact as a snapshot
+// named name.
+func (r *Repository) Save(ctx context.Context, name string, rd io.Reader) (Snapshot, error) {
+	snapshot := Snapshot{ID: newID(), Name: name, Time: time.Now().UTC(), Chunks: []string{}}
+	var (
+		packed  []packIndex
+		pack    bytes.Buffer
+		blobs   []blob
+		pending = make(map[string]bool) // stored by this save, not yet indexed
+	)
+	flush := func() error {
+		if pack.Len() == 0 {
+			return nil
+		}
+		sum := sha256.Sum256(pack.Bytes())
+		id := hex.EncodeToString(sum[:])
+		if err := r.putBytes(ctx, packKey(id), pack.Bytes()); err != nil {
+			return err
+		}
+		packed = append(packed, packIndex{Pack: id, Blobs: blobs})
+		pack.Reset()
+		blobs = nil
+		return nil
+	}
+
+	chunker := NewChunker(rd)
+	for {
+		if err := ctx.Err(); err != nil {
+			return snapshot, err
+		}
+		chunk, err := chunker.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return snapshot, fmt.Errorf("read artifact: %w", err)
+		}
+		sum := sha256.Sum256(chunk)
+		id := hex.EncodeToString(sum[:])
+		snapshot.Chunks = append(snapshot.Chunks, id)
+		snapshot.Size += int64(len(chunk))
+		if pending[id] || r.has(id) {
+			continue
+		}
+		compressed := r.encoder.EncodeAll(chunk, nil)
+		blobs = append(blobs, blob{
+			ID:     id,
+			Offset: int64(pack.Len()),
+			Length: int64(len(compressed)),
+			Size:   int64(len(chunk)),
+		})
+		pack.Write(compressed)
+		pending[id] = true
+		snapshot.Added += int64(len(compressed))
+		if pack.Len() >= r.packSize {
+			if err := flush(); err != nil {
+				return snapshot, err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return snapshot, err
+	}
+
+	// the index goes before the snapshot, so that a snapshot never refers
+	// to chunks missing from the index
+	if len(packed) > 0 {
+		if err := r.putJSON(ctx, indexPrefix+snapshot.ID+".json", indexFile{Packs: packed}); err != nil {
+			return snapshot, err
+		}
+		r.mu.Lock()
+		for _, p := range packed {
+			addPack(r.index, r.packs, p)
+		}
+		r.mu.Unlock()
+	}
+	if err := r.putJSON(ctx, snapshotKey(snapshot.ID), snapshot); err != nil {
+		return snapshot, err
+	}
+	return snapshot, nil
+}
+
+func (r *Repository) has(id string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.index[id]
+	return ok
+}
+
+func (r *Repository) lookup(id string) (location, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	loc, ok := r.index[id]
+	return loc, ok
+}
+
+// Restore writes the artifact of snapshot id to w, verifying every chunk.
+func (r *Repository) Restore(ctx context.Context, id string, w io.Writer) error {
+	snapshot, err := r.Snapshot(ctx, id)
+	if err != nil {
+		return err
+	}
+	var (
+		packID string // packs are mostly read in order, so keep the last one
+		pack   []byte
+	)
+	for _, chunkID := range snapshot.Chunks {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		loc, ok := r.lookup(chunkID)
+		if !ok {
+			return fmt.Errorf("%w: chunk %s of snapshot %s is not indexed", ErrCorrupt, chunkID, id)
+		}
+		if loc.pack != packID {
+			if pack, err = r.getBytes(ctx, packKey(loc.pack)); err != nil {
+				return err
+			}
+			packID = loc.pack
+		}
+		chunk, err := r.readBlob(pack, chunkID, loc)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(chunk); err != nil {
+			return fmt.Errorf("write artifact: %w", err)
+		}
+	}
+	return nil
+}
+
+// readBlob decompresses the chunk id stored at loc of pack and checks it.
+func (r *Repository) readBlob(pack []byte, id string, loc location) ([]byte, error) {
+	if loc.offset+loc.length > int64(len(pack)) {
+		return nil, fmt.Errorf("%w: chunk %s lies past the end of pack %s", ErrCorrupt, id, loc.pack)
+	}
+	chunk, err := r.decoder.DecodeAll(pack[loc.offset:loc.offset+loc.length], nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: chunk %s in pack %s: %v", ErrCorrupt, id, loc.pack, err)
+	}
+	if sum := sha256.Sum256(chunk); hex.EncodeToString(sum[:]) != id {
+		return nil, fmt.Errorf("%w: chunk %s in pack %s does not match its id", ErrCorrupt, id, loc.pack)
+	}
+	return chunk, nil
+}
+
+// Snapshot returns the snapshot id.
+func (r *Repository) Snapshot(ctx context.Context, id string) (Snapshot, error) {
+	var snapshot Snapshot
+	if err := r.getJSON(ctx, snapshotKey(id), &snapshot); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return snapshot, fmt.Errorf("%w: %s", ErrSnapshotNotFound, id)
+		}
+		return snapshot, err
+	}
+	return snapshot, nil
+}
+
+// Snapshots returns every snapshot, oldest first.
+func (r *Repository) Snapshots(ctx context.Context) ([]Snapshot, error) {
+	objects, err := r.backend.List(ctx, snapshotPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("list snapshots: %w", err)
+	}
+	snapshots := make([]Snapshot, 0, len(objects))
+	for _, object := range objects {
+		var snapshot Snapshot
+		if err := r.getJSON(ctx, object.Key, &snapshot); err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	slices.SortFunc(snapshots, func(a, b Snapshot) int { return a.Time.Compare(b.Time) })
+	return snapshots, nil
+}
+
+// Forget removes snapshot id. Its chunks stay stored until Prune finds them
+// unreferenced.
+func (r *Repository) Forget(ctx context.Context, id string) error {
+	if exists, err := r.exists(ctx, snapshotKey(id)); err != nil {
+		return err
+	} else if !exists {
+		return fmt.Errorf("%w: %s", ErrSnapshotNotFound, id)
+	}
+	if err := r.backend.Delete(ctx, snapshotKey(id)); err != nil {
+		return fmt.Errorf("delete snapshot %s: %w", id, err)
+	}
+	return nil
+}
+
+// PruneResult describes a Prune.
+type PruneResult struct {
+	PacksDeleted int   `json:"packs_deleted"`
+	FreedBytes   int64 `json:"freed_bytes"`
+}
+
+// Prune deletes the packs none of whose chunks is referenced by a snapshot,
+// and packs left unindexed by a failed save, then rewrites the index as a
+// single file. Packs holding some referenced chunks are kept whole. It must
+// not run while saves are in progress.
+func (r *Repository) Prune(ctx context.Context) (PruneResult, error) {
+	var result PruneResult
+	snapshots, err := r.Snapshots(ctx)
+	if err != nil {
+		return result, err
+	}
+	if err := r.loadIndex(ctx); err != nil {
+		return result, err
+	}
+	oldIndex, err := r.indexFiles(ctx)
+	if err != nil {
+		return result, err
+	}
+	var file indexFile
+	for _, key := range oldIndex {
+		var f indexFile
+		if err := r.getJSON(ctx, key, &f); err != nil {
+			return result, err
+		}
+		file.Packs = append(file.Packs, f.Packs...)
+	}
+
+	referenced := make(map[string]bool)
+	for _, snapshot := range snapshots {
+		for _, id := range snapshot.Chunks {
+			referenced[id] = true
+		}
+	}
+	used := make(map[string]bool)
+	kept := indexFile{Packs: []packIndex{}}
+	for _, p := range file.Packs {
+		if used[p.Pack] {
+			continue
+		}
+		if slices.ContainsFunc(p.Blobs, func(b blob) bool { return referenced[b.ID] }) {
+			used[p.Pack] = true
+			kept.Packs = append(kept.Packs, p)
+		}
+	}
+
+	objects, err := r.backend.List(ctx, dataPrefix)
+	if err != nil {
+		return result, fmt.Errorf("list packs: %w", err)
+	}
+	var doomed []storage.Object
+	for _, object := range objects {
+		id := path.Base(object.Key)
+		_, indexed := r.packs[id]
+		if used[id] || (!indexed && time.Since(object.ModTime) < orphanGrace) {
+			continue
+		}
+		doomed = append(doomed, object)
+	}
+
+	// rewrite the index before deleting, so that it never lists a deleted pack
+	if err := r.putJSON(ctx, indexPrefix+newID()+".json", kept); err != nil {
+		return result, err
+	}
+	for _, key := range oldIndex {
+		if err := r.backend.Delete(ctx, key); err != nil {
+			return result, fmt.Errorf("delete %s: %w", key, err)
+		}
+	}
+	for _, object := range doomed {
+		if err := r.backend.Delete(ctx, object.Key); err != nil {
+			return result, fmt.Errorf("delete %s: %w", object.Key, err)
+		}
+		result.PacksDeleted++
+		result.FreedBytes += object.Size
+	}
+	return result, r.loadIndex(ctx)
+}
+
+// CheckResult describes a Check.
+type CheckResult struct {
+	Snapshots int      `json:"snapshots"`
+	Packs     int      `json:"packs"`
+	Chunks    int      `json:"chunks"`
+	ReadData  bool     `json:"read_data"`
+	Errors    []string `json:"errors"`
+}
+
+// Check verifies that every chunk of every snapshot is indexed and that
+// every indexed pack is stored whole. With readData, every pack is also
+// downloaded and every chunk decompressed and checked against its id.
+// ErrCorrupt is returned along with the result when a problem was found.
+func (r *Repository) Check(ctx context.Context, readData bool) (CheckResult, error) {
+	result := CheckResult{ReadData: readData, Errors: []string{}}
+	if err := r.loadIndex(ctx); err != nil {
+		return result, err
+	}
+	snapshots, err := r.Snapshots(ctx)
+	if err != nil {
+		return result, err
+	}
+	result.Snapshots = len(snapshots)
+	for _, snapshot := range snapshots {
+		missing := 0
+		for _, id := range snapshot.Chunks {
+			if !r.has(id) {
+				missing++
+			}
+		}
+		if missing > 0 {
+			result.Errors = append(result.Errors,
+				fmt.Sprintf("snapshot %s (%s): %d chunks are not indexed", snapshot.ID, snapshot.Name, missing))
+		}
+	}
+
+	objects, err := r.backend.List(ctx, dataPrefix)
+	if err != nil {
+		return result, fmt.Errorf("list packs: %w", err)
+	}
+	stored := make(map[string]int64, len(objects))
+	for _, object := range objects {
+		stored[path.Base(object.Key)] = object.Size
+	}
+	r.mu.Lock()
+	packs := make(map[string]int64, len(r.packs))
+	for id, size := range r.packs {
+		packs[id] = size
+	}
+	byPack := make(map[string][]string)
+	for id, loc := range r.index {
+		byPack[loc.pack] = append(byPack[loc.pack], id)
+	}
+	r.mu.Unlock()
+	result.Packs = len(packs)
+	for id, size := range packs {
+		result.Chunks += len(byPack[id])
+		got, ok := stored[id]
+		switch {
+		case !ok:
+			result.Errors = append(result.Errors, fmt.Sprintf("pack %s is missing", id))
+			continue
+		case got < size:
+			result.Errors = append(result.Errors, fmt.Sprintf("pack %s is truncated: %d of %d bytes", id, got, size))
+			continue
+		}
+		if !readData {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		data, err := r.getBytes(ctx, packKey(id))
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("pack %s: %v", id, err))
+			continue
+		}
+		if sum := sha256.Sum256(data); hex.EncodeToString(sum[:]) != id {
+			result.Errors = append(result.Errors, fmt.Sprintf("pack %s does not match its id", id))
+		}
+		for _, chunkID := range byPack[id] {
+			loc, _ := r.lookup(chunkID)
+			if _, err := r.readBlob(data, chunkID, loc); err != nil {
+				result.Errors = append(result.Errors, err.Error())
+			}
+		}
+	}
+	slices.Sort(result.Errors)
+	if len(result.Errors) > 0 {
+		return result, fmt.Errorf("%w: %d problems found", ErrCorrupt, len(result.Errors))
+	}
+	return result, nil
+}
+
+// Stats summarizes what the repository stores.
+type Stats struct {
+	Snapshots int `json:"snapshots"`
+	Chunks    int `json:"chunks"` // distinct chunks referenced by snapshots
+	Packs     int `json:"packs"`
+	// LogicalBytes is the total size of the snapshots' artifacts, what they
+	// would take stored one by one.
+	LogicalBytes int64 `json:"logical_bytes"`
+	// UniqueBytes is the uncompressed size of the distinct chunks.
+	UniqueBytes int64 `json:"unique_bytes"`
+	// StoredBytes is the size of the packs on the backend.
+	StoredBytes int64 `json:"stored_bytes"`
+}
+
+// Ratio returns how many logical bytes each stored byte holds.
+func (s Stats) Ratio() float64 {
+	if s.StoredBytes == 0 {
+		return 0
+	}
+	return float64(s.LogicalBytes) / float64(s.StoredBytes)
+}
+
+// Stats returns the repository's statistics.
+func (r *Repository) Stats(ctx context.Context) (Stats, error) {
+	var stats Stats
+	if err := r.loadIndex(ctx); err != nil {
+		return stats, err
+	}
+	snapshots, err := r.Snapshots(ctx)
+	if err != nil {
+		return stats, err
+	}
+	stats.Snapshots = len(snapshots)
+	seen := make(map[string]bool)
+	for _, snapshot := range snapshots {
+		stats.LogicalBytes += snapshot.Size
+		for _, id := range snapshot.Chunks {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			if loc, ok := r.lookup(id); ok {
+				stats.UniqueBytes += loc.size
+			}
+		}
+	}
+	stats.Chunks = len(seen)
+	objects, err := r.backend.List(ctx, dataPrefix)
+	if err != nil {
+		return stats, fmt.Errorf("list packs: %w", err)
+	}
+	stats.Packs = len(objects)
+	for _, object := range objects {
+		stats.StoredBytes += object.Size
+	}
+	return stats, nil
+}
+
+func (r *Repository) indexFiles(ctx context.Context) ([]string, error) {
+	objects, err := r.backend.List(ctx, indexPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("list index: %w", err)
+	}
+	keys := make([]string, 0, len(objects))
+	for _, object := range objects {
+		if strings.HasSuffix(object.Key, ".json") {
+			keys = append(keys, object.Key)
+		}
+	}
+	return keys, nil
+}
+
+// exists reports whether the backend holds key.
+func (r *Repository) exists(ctx context.Context, key string) (bool, error) {
+	objects, err := r.backend.List(ctx, key)
+	if err != nil {
+		return false, fmt.Errorf("list %s: %w", key, err)
+	}
+	return slices.ContainsFunc(objects, func(o storage.Object) bool { return o.Key == key }), nil
+}
+
+func (r *Repository) putJSON(ctx context.Context, key string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode %s: %w", key, err)
+	}
+	return r.putBytes(ctx, key, data)
+}
+
+func (r *Repository) getJSON(ctx context.Context, key string, v any) error {
+	data, err := r.getBytes(ctx, key)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("%w: decode %s: %v", ErrCorrupt, key, err)
+	}
+	return nil
+}
+
+// putBytes uploads data as key through a file in the scratch directory.
+func (r *Repository) putBytes(ctx context.Context, key string, data []byte) error {
+	file, err := os.CreateTemp(r.scratch, ".chunkstore-*")
+	if err != nil {
+		return fmt.Errorf("stage %s: %w", key, err)
+	}
+	defer os.Remove(file.Name())
+	_, err = file.Write(data)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("stage %s: %w", key, err)
+	}
+	if err := r.backend.Upload(ctx, file.Name(), key); err != nil {
+		return fmt.Errorf("upload %s: %w", key, err)
+	}
+	return nil
+}
+
+// getBytes downloads key through a file in the scratch directory.
+func (r *Repository) getBytes(ctx context.Context, key string) ([]byte, error) {
+	dir, err := os.MkdirTemp(r.scratch, ".chunkstore-*")
+	if err != nil {
+		return nil, fmt.Errorf("stage %s: %w", key, err)
+	}
+	defer os.RemoveAll(dir)
+	local := dir + string(os.PathSeparator) + path.Base(key)
+	if err := r.backend.Download(ctx, key, local); err != nil {
+		return nil, fmt.Errorf("download %s: %w", key, err)
+	}
+	data, err := os.ReadFile(local)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", key, err)
+	}
+	return data, nil
+}
+
+func packKey(id string) string     { return dataPrefix + id[:2] + "/" + id }
+func snapshotKey(id string) string { return snapshotPrefix + id + ".json" }
+
+// newID returns a random 128-bit hex id.
+func newID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
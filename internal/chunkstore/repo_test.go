@@ -0,0 +1,139 @@
+package chunkstore
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kebairia/backup/internal/storage"
+)
+
+func newTestRepo(t *testing.T) (*Repository, string) {
+	t.Helper()
+	root := t.TempDir()
+	backend, err := storage.NewLocal(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if _, err := Open(ctx, backend); !errors.Is(err, ErrNotInitialized) {
+		t.Fatalf("Open before Init: err = %v, want ErrNotInitialized", err)
+	}
+	if _, err := Init(ctx, backend); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Init(ctx, backend); !errors.Is(err, ErrInitialized) {
+		t.Fatalf("second Init: err = %v, want ErrInitialized", err)
+	}
+	repo, err := Open(ctx, backend, WithPackSize(4<<20), WithScratchDir(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return repo, root
+}
+
+func TestRepository(t *testing.T) {
+	ctx := context.Background()
+	repo, _ := newTestRepo(t)
+
+	first := randomBytes(2, 12<<20)
+	// the next dump changed a few bytes in the middle
+	second := bytes.Clone(first)
+	copy(second[6<<20:], "changed")
+
+	a, err := repo.Save(ctx, "postgres/app/app.dump", bytes.NewReader(first))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := repo.Save(ctx, "postgres/app/app.dump", bytes.NewReader(second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Added >= a.Added/2 {
+		t.Errorf("second save added %d bytes, first %d: chunks were not deduplicated", b.Added, a.Added)
+	}
+
+	for _, s := range []struct {
+		snapshot Snapshot
+		want     []byte
+	}{{a, first}, {b, second}} {
+		var out bytes.Buffer
+		if err := repo.Restore(ctx, s.snapshot.ID, &out); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(out.Bytes(), s.want) {
+			t.Errorf("snapshot %s restored differently", s.snapshot.ID)
+		}
+	}
+
+	stats, err := repo.Stats(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Snapshots != 2 || stats.LogicalBytes != int64(len(first)+len(second)) {
+		t.Errorf("stats = %+v", stats)
+	}
+	if stats.Ratio() < 1.5 {
+		t.Errorf("dedup ratio = %.2f, want about 2", stats.Ratio())
+	}
+	if _, err := repo.Check(ctx, true); err != nil {
+		t.Fatal(err)
+	}
+
+	// forgetting the first snapshot frees nothing its successor shares
+	if err := repo.Forget(ctx, a.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Prune(ctx); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := repo.Restore(ctx, b.ID, &out); err != nil || !bytes.Equal(out.Bytes(), second) {
+		t.Fatalf("restore after prune: %v", err)
+	}
+	if err := repo.Restore(ctx, a.ID, &out); !errors.Is(err, ErrSnapshotNotFound) {
+		t.Errorf("restore of a forgotten snapshot: err = %v", err)
+	}
+
+	if err := repo.Forget(ctx, b.ID); err != nil {
+		t.Fatal(err)
+	}
+	result, err := repo.Prune(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats, _ := repo.Stats(ctx); stats.Packs != 0 || result.PacksDeleted == 0 {
+		t.Errorf("after forgetting everything: %d packs left, %d deleted", stats.Packs, result.PacksDeleted)
+	}
+}
+
+func TestRepositoryCheck(t *testing.T) {
+	ctx := context.Background()
+	repo, root := newTestRepo(t)
+	if _, err := repo.Save(ctx, "dump", bytes.NewReader(randomBytes(3, 2<<20))); err != nil {
+		t.Fatal(err)
+	}
+	packs, err := filepath.Glob(filepath.Join(root, "data", "*", "*"))
+	if err != nil || len(packs) != 1 {
+		t.Fatalf("packs = %v, %v", packs, err)
+	}
+	data, err := os.ReadFile(packs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[len(data)/2] ^= 0xff
+	if err := os.WriteFile(packs[0], data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := repo.Check(ctx, false); err != nil {
+		t.Errorf("check without reading data: %v", err)
+	}
+	result, err := repo.Check(ctx, true)
+	if !errors.Is(err, ErrCorrupt) || len(result.Errors) == 0 {
+		t.Errorf("check of a damaged pack: err = %v, errors = %v", err, result.Errors)
+	}
+}
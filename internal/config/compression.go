@@ -0,0 +1,148 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Compression modes of engines and instances (compression). Each artifact
+// is compressed once, either by bacli or by the dump tool.
+const (
+	// CompressionZstd compresses file artifacts with zstd after the dump;
+	// dump tools that compress on their own are told not to.
+	CompressionZstd = "zstd"
+	// CompressionNative leaves compression to the dump tool: pg_dump's
+	// custom and directory formats, mongodump --gzip.
+	CompressionNative = "native"
+	// CompressionNone keeps artifacts uncompressed.
+	CompressionNone = "none"
+)
+
+// compressionMode normalizes a compression setting. true and false, from
+// configurations written when compression was a boolean, read as zstd and
+// none (the loader decodes them as "1" and "0").
+func compressionMode(mode string) string {
+	switch strings.ToLower(mode) {
+	case "1", "true":
+		return CompressionZstd
+	case "0", "false":
+		return CompressionNone
+	}
+	return strings.ToLower(mode)
+}
+
+// Compression returns the compression mode of the instance named instance
+// of engine: its own, the engine's, or else native for methods compressing
+// on their own and zstd or none as backup.compression says. Methods named
+// after their compression (mongodb's "archive-gzip") are always native.
+func (c *Config) Compression(engine, instance string) string {
+	group, _ := c.EngineGroup(engine)
+	mode, method := group.Compression, group.Method
+	for _, inst := range group.Instances {
+		if inst.Name != instance {
+			continue
+		}
+		if inst.Compression != "" {
+			mode = inst.Compression
+		}
+		if inst.Method != "" {
+			method = inst.Method
+		}
+		break
+	}
+	mode = compressionMode(mode)
+	switch {
+	case gzipMethod(engine, method):
+		return CompressionNative
+	case mode != "":
+		return mode
+	case nativeCompression(engine, method):
+		return CompressionNative
+	case c.Backup.Compression && directoryMethod(engine, method):
+		// bacli cannot compress directories, mongodump gzips them
+		return CompressionNative
+	case c.Backup.Compression:
+		return CompressionZstd
+	}
+	return CompressionNone
+}
+
+// nativeCompression reports whether method of engine compresses its output
+// unless told otherwise.
+func nativeCompression(engine, method string) bool {
+	switch engine {
+	case "postgres":
+		return method == "custom" || method == "c" || directoryMethod(engine, method)
+	case "mongodb":
+		return gzipMethod(engine, method)
+	}
+	return false
+}
+
+// gzipMethod reports whether method is a mongodb method compressing with
+// gzip by name.
+func gzipMethod(engine, method string) bool {
+	return engine == "mongodb" && strings.HasSuffix(method, "-gzip")
+}
+
+// directoryMethod reports whether method of engine writes a directory,
+// which bacli cannot compress.
+func directoryMethod(engine, method string) bool {
+	switch engine {
+	case "postgres":
+		return method == "directory" || method == "d"
+	case "mongodb":
+		return method == "directory" || method == "directory-gzip"
+	}
+	return false
+}
+
+// checkCompression checks the compression modes of the group of engine
+// against the methods they apply to, so that no artifact is compressed
+// twice, or not at all when a mode was asked for.
+func (g DBGroupConfig) checkCompression(engine string) []error {
+	var errs []error
+	check := func(where, mode, method string) {
+		mode = compressionMode(mode)
+		switch mode {
+		case "", CompressionNone:
+		case CompressionZstd:
+			if gzipMethod(engine, method) {
+				errs = append(errs, fmt.Errorf("%s: compression zstd would compress the %s method's gzip output again, use compression native or a method without gzip",
+					where, method))
+			} else if directoryMethod(engine, method) {
+				errs = append(errs, fmt.Errorf("%s: compression zstd applies to file artifacts, the %s method writes a directory: use compression native",
+					where, method))
+			}
+		case CompressionNative:
+			if engine != "mongodb" && !nativeCompression(engine, method) {
+				tool := engine
+				if method != "" {
+					tool = "the " + method + " method of " + engine
+				}
+				errs = append(errs, fmt.Errorf("%s: %s does not compress natively, use compression zstd", where, tool))
+			}
+		default:
+			errs = append(errs, fmt.Errorf("%s: compression %q: use %q, %q or %q",
+				where, mode, CompressionZstd, CompressionNative, CompressionNone))
+		}
+		if mode == CompressionNone && gzipMethod(engine, method) {
+			errs = append(errs, fmt.Errorf("%s: compression none contradicts the %s method", where, method))
+		}
+	}
+	check(engine, g.Compression, g.Method)
+	for i, instance := range g.Instances {
+		mode, method := instance.Compression, instance.Method
+		if method == "" {
+			method = g.Method
+		}
+		if mode == "" {
+			if instance.Method == "" {
+				continue // checked with the engine
+			}
+			mode = g.Compression
+		}
+		check(engine+" instance "+instanceLabel(instance, i), mode, method)
+	}
+	return errs
+}
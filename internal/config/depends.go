@@ -0,0 +1,123 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// InstanceRef names an instance of an engine.
+type InstanceRef struct {
+	Engine string
+	Name   string
+}
+
+func (r InstanceRef) String() string { return r.Engine + ":" + r.Name }
+
+// Dependencies returns the instances the instance named instance of engine
+// depends on (depends_on): restores of its databases wait for theirs.
+func (c *Config) Dependencies(engine, instance string) ([]InstanceRef, error) {
+	group, ok := c.EngineGroup(engine)
+	if !ok {
+		return nil, nil
+	}
+	for _, inst := range group.Instances {
+		if inst.Name != instance {
+			continue
+		}
+		refs := make([]InstanceRef, 0, len(inst.DependsOn))
+		for _, dep := range inst.DependsOn {
+			ref, err := c.resolveInstance(engine, dep)
+			if err != nil {
+				return nil, fmt.Errorf("%s instance %s: depends_on: %w", engine, instance, err)
+			}
+			refs = append(refs, ref)
+		}
+		return refs, nil
+	}
+	return nil, nil
+}
+
+// resolveInstance resolves a depends_on entry of an instance of engine:
+// "name" for an instance of engine, or "engine:name", as with --instance.
+func (c *Config) resolveInstance(engine, ref string) (InstanceRef, error) {
+	target := InstanceRef{Engine: engine, Name: ref}
+	if prefix, name, ok := strings.Cut(ref, ":"); ok {
+		if _, known := c.EngineGroup(prefix); known {
+			target = InstanceRef{Engine: prefix, Name: name}
+		}
+	}
+	group, _ := c.EngineGroup(target.Engine)
+	for _, inst := range group.Instances {
+		if inst.Name == target.Name {
+			return target, nil
+		}
+	}
+	return InstanceRef{}, fmt.Errorf("no instance %s", target)
+}
+
+// checkDependencies rejects depends_on entries naming unknown instances and
+// dependency cycles, which would keep restores waiting for each other.
+func (c *Config) checkDependencies(engines []string) []error {
+	var errs []error
+	deps := make(map[InstanceRef][]InstanceRef)
+	var order []InstanceRef
+	for _, engine := range engines {
+		group, _ := c.EngineGroup(engine)
+		for _, inst := range group.Instances {
+			if len(inst.DependsOn) == 0 {
+				continue
+			}
+			refs, err := c.Dependencies(engine, inst.Name)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			from := InstanceRef{Engine: engine, Name: inst.Name}
+			deps[from] = refs
+			order = append(order, from)
+		}
+	}
+
+	// depth-first search; an instance met again while on the path closes
+	// a cycle
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := make(map[InstanceRef]int)
+	var path []InstanceRef
+	var visit func(ref InstanceRef) bool
+	visit = func(ref InstanceRef) bool {
+		switch state[ref] {
+		case visiting:
+			cycle := []string{ref.String()}
+			for i := len(path) - 1; i >= 0 && path[i] != ref; i-- {
+				cycle = append(cycle, path[i].String())
+			}
+			cycle = append(cycle, ref.String())
+			for i, j := 0, len(cycle)-1; i < j; i, j = i+1, j-1 {
+				cycle[i], cycle[j] = cycle[j], cycle[i]
+			}
+			errs = append(errs, fmt.Errorf("depends_on cycle: %s", strings.Join(cycle, " -> ")))
+			return false
+		case visited:
+			return true
+		}
+		state[ref] = visiting
+		path = append(path, ref)
+		ok := true
+		for _, dep := range deps[ref] {
+			if !visit(dep) {
+				ok = false
+				break
+			}
+		}
+		path = path[:len(path)-1]
+		state[ref] = visited
+		return ok
+	}
+	for _, ref := range order {
+		visit(ref)
+	}
+	return errs
+}
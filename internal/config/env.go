@@ -0,0 +1,23 @@
+package config
+
+import (
+	"os"
+	"regexp"
+)
+
+// envPattern matches ${VAR} and ${VAR:-default}.
+var envPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// expandEnv replaces ${VAR} references with the value of the environment
+// variable VAR. ${VAR:-default} falls back to default when VAR is unset or
+// empty. References to unset variables without a default expand to "".
+func expandEnv(data []byte) []byte {
+	return envPattern.ReplaceAllFunc(data, func(match []byte) []byte {
+		groups := envPattern.FindSubmatch(match)
+		value := os.Getenv(string(groups[1]))
+		if value == "" && groups[2] != nil {
+			value = string(groups[3])
+		}
+		return []byte(value)
+	})
+}
@@ -0,0 +1,47 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"slices"
+)
+
+// Filter selects objects (collections, schemas, tables) by name: those
+// matching an Include pattern, or all when Include is empty, except those
+// matching an Exclude pattern. Patterns use path.Match syntax ("audit_*").
+type Filter struct {
+	Include []string `mapstructure:"include" yaml:"include,omitempty"`
+	Exclude []string `mapstructure:"exclude" yaml:"exclude,omitempty"`
+}
+
+// Empty reports whether the filter selects everything.
+func (f Filter) Empty() bool {
+	return len(f.Include) == 0 && len(f.Exclude) == 0
+}
+
+// Match reports whether the filter selects name.
+func (f Filter) Match(name string) bool {
+	if len(f.Include) > 0 && !matchAny(f.Include, name) {
+		return false
+	}
+	return !matchAny(f.Exclude, name)
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// check rejects malformed patterns.
+func (f Filter) check() error {
+	for _, pattern := range append(slices.Clip(f.Include), f.Exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
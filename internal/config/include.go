@@ -0,0 +1,176 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// ErrIncludeCycle indicates that a file (transitively) includes itself.
+var ErrIncludeCycle = errors.New("config include cycle")
+
+// loadFile reads path, recursively merges the files it includes, and returns
+// the resulting settings.
+//
+// Include semantics:
+//   - relative include paths are resolved against the directory of the file
+//     that declares them, not the working directory;
+//   - entries may be globs (e.g. "conf.d/*.yaml"); matches are merged in
+//     lexical order and a glob matching nothing is not an error, whereas a
+//     missing literal path is;
+//   - includes are merged in the order listed, so later includes override
+//     earlier ones, and the including file's own settings override all of
+//     its includes;
+//   - a file that includes itself, directly or through other files, is
+//     rejected with ErrIncludeCycle.
+//
+// chain holds the absolute paths of the files currently being loaded.
+func loadFile(path string, chain []string) (map[string]any, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: resolve %s: %v", ErrLoadConfig, path, err)
+	}
+	if slices.Contains(chain, abs) {
+		return nil, fmt.Errorf("%w: %s", ErrIncludeCycle,
+			strings.Join(append(chain, abs), " -> "))
+	}
+	chain = append(chain, abs)
+
+	data, err := os.ReadFile(abs)
+	if err != nil {
+		return nil, fmt.Errorf("%w: read config %s: %v", ErrLoadConfig, path, err)
+	}
+	own := viper.New()
+	own.SetConfigType("yaml")
+	if err := own.ReadConfig(bytes.NewReader(expandEnv(data))); err != nil {
+		return nil, fmt.Errorf("%w: parse config %s: %v", ErrLoadConfig, path, err)
+	}
+
+	includes, err := resolveIncludes(filepath.Dir(abs), own.GetStringSlice("include"))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrLoadConfig, path, err)
+	}
+
+	merged := viper.New()
+	for _, inc := range includes {
+		settings, err := loadFile(inc, chain)
+		if err != nil {
+			return nil, err
+		}
+		delete(settings, "include")
+		if err := merged.MergeConfigMap(settings); err != nil {
+			return nil, fmt.Errorf("%w: merge include %s: %v", ErrLoadConfig, inc, err)
+		}
+	}
+	if err := merged.MergeConfigMap(own.AllSettings()); err != nil {
+		return nil, fmt.Errorf("%w: merge %s: %v", ErrLoadConfig, path, err)
+	}
+	return merged.AllSettings(), nil
+}
+
+// loadPath loads the configuration at path: a file and its includes (see
+// loadFile), or a directory of files (see loadDir).
+func loadPath(path string) (map[string]any, error) {
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		return loadDir(path)
+	}
+	return loadFile(path, nil)
+}
+
+// loadDir merges the *.yaml files of dir, e.g. /etc/bacli/conf.d, each
+// loaded with its includes as by loadFile. Files are merged in lexical
+// order, so later files override earlier ones, except for the instances of
+// the engine groups (top-level and per tenant): those are appended, so that
+// every file can add its own databases.
+func loadDir(dir string) (map[string]any, error) {
+	files, err := dirFiles(dir)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrLoadConfig, err)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("%w: no *.yaml files in %s", ErrLoadConfig, dir)
+	}
+	merged := viper.New()
+	instances := make(map[string][]any) // "postgres", "tenants.<name>.postgres"
+	var order []string
+	for _, file := range files {
+		settings, err := loadFile(file, nil)
+		if err != nil {
+			return nil, err
+		}
+		delete(settings, "include")
+		groups := map[string]map[string]any{"": settings}
+		if tenants, ok := settings["tenants"].(map[string]any); ok {
+			for name, tenant := range tenants {
+				if tenant, ok := tenant.(map[string]any); ok {
+					groups["tenants."+name+"."] = tenant
+				}
+			}
+		}
+		for prefix, parent := range groups {
+			for _, engine := range engineKeys {
+				group, _ := parent[engine].(map[string]any)
+				list, ok := group["instances"].([]any)
+				if !ok {
+					continue
+				}
+				key := prefix + engine
+				if _, seen := instances[key]; !seen {
+					order = append(order, key)
+				}
+				instances[key] = append(instances[key], list...)
+			}
+		}
+		if err := merged.MergeConfigMap(settings); err != nil {
+			return nil, fmt.Errorf("%w: merge %s: %v", ErrLoadConfig, file, err)
+		}
+	}
+	for _, key := range order {
+		merged.Set(key+".instances", instances[key])
+	}
+	return merged.AllSettings(), nil
+}
+
+// dirFiles returns the *.yaml files of dir in lexical order, leaving out
+// hidden files such as editor swap files.
+func dirFiles(dir string) ([]string, error) {
+	matches, err := filepath.Glob(filepath.Join(dir, "*.yaml"))
+	if err != nil {
+		return nil, err
+	}
+	matches = slices.DeleteFunc(matches, func(file string) bool {
+		return strings.HasPrefix(filepath.Base(file), ".")
+	})
+	slices.Sort(matches)
+	return matches, nil
+}
+
+// resolveIncludes expands include entries relative to dir into a list of files.
+func resolveIncludes(dir string, patterns []string) ([]string, error) {
+	var files []string
+	for _, pattern := range patterns {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(dir, pattern)
+		}
+		if !strings.ContainsAny(pattern, "*?[") {
+			if _, err := os.Stat(pattern); err != nil {
+				return nil, fmt.Errorf("include %s: %w", pattern, err)
+			}
+			files = append(files, pattern)
+			continue
+		}
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("include %s: %w", pattern, err)
+		}
+		slices.Sort(matches)
+		files = append(files, matches...)
+	}
+	return files, nil
+}
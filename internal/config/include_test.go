@@ -0,0 +1,153 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeFiles creates files (relative path -> content) under a temp directory.
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLoadConfig_ResolvesIncludesRelativeToParent(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"etc/config.yaml": `
+include:
+  - "conf.d/*.yaml"
+  - "engines/postgres.yaml"
+backup:
+  directory: "/from/main"
+`,
+		"etc/conf.d/10-backup.yaml": `
+backup:
+  directory: "/from/10"
+  timestamp_fmt: "from-10"
+`,
+		"etc/conf.d/20-backup.yaml": `
+backup:
+  timestamp_fmt: "from-20"
+`,
+		"etc/engines/postgres.yaml": `
+include: ["../shared/defaults.yaml"]
+postgres:
+  host: "pg.lan"
+`,
+		"etc/shared/defaults.yaml": `
+postgres:
+  host: "overridden"
+  port: "5432"
+`,
+	})
+
+	// Load from another working directory to prove resolution is file-relative
+	t.Chdir(t.TempDir())
+
+	var cfg Config
+	if err := cfg.Load(filepath.Join(dir, "etc", "config.yaml")); err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if got, want := cfg.Backup.Directory, "/from/main"; got != want {
+		t.Errorf("Backup.Directory = %q, want %q (parent overrides includes)", got, want)
+	}
+	if got, want := cfg.Backup.TimestampFmt, "from-20"; got != want {
+		t.Errorf("Backup.TimestampFmt = %q, want %q (later glob match wins)", got, want)
+	}
+	if got, want := cfg.Postgres.Host, "pg.lan"; got != want {
+		t.Errorf("Postgres.Host = %q, want %q (nested include overridden)", got, want)
+	}
+	if got, want := cfg.Postgres.Port, "5432"; got != want {
+		t.Errorf("Postgres.Port = %q, want %q (nested include applied)", got, want)
+	}
+}
+
+func TestLoadConfig_DetectsIncludeCycle(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"a.yaml": `include: ["b.yaml"]`,
+		"b.yaml": `include: ["a.yaml"]`,
+	})
+
+	var cfg Config
+	err := cfg.Load(filepath.Join(dir, "a.yaml"))
+	if !errors.Is(err, ErrIncludeCycle) {
+		t.Fatalf("Load error = %v, want %v", err, ErrIncludeCycle)
+	}
+}
+
+func TestLoadConfig_MissingLiteralIncludeFails(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"config.yaml": `include: ["missing.yaml", "conf.d/*.yaml"]`,
+	})
+
+	var cfg Config
+	if err := cfg.Load(filepath.Join(dir, "config.yaml")); !errors.Is(err, ErrLoadConfig) {
+		t.Fatalf("Load error = %v, want %v", err, ErrLoadConfig)
+	}
+}
+
+func TestLoadConfig_Directory(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"conf.d/00-base.yaml": `
+backup:
+  directory: "/from/00"
+  timestamp_fmt: "from-00"
+postgres:
+  host: "pg.lan"
+`,
+		"conf.d/10-billing.yaml": `
+backup:
+  timestamp_fmt: "from-10"
+postgres:
+  instances:
+    - name: "billing"
+      database: "billing"
+`,
+		"conf.d/20-crm.yaml": `
+include: ["../shared/crm.yaml"]
+`,
+		"shared/crm.yaml": `
+postgres:
+  instances:
+    - name: "crm"
+      database: "crm"
+`,
+		"conf.d/.20-crm.yaml.swp": "not: [yaml",
+		"conf.d/README":           "not yaml either",
+	})
+
+	var cfg Config
+	if err := cfg.Load(filepath.Join(dir, "conf.d")); err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if got, want := cfg.Backup.Directory, "/from/00"; got != want {
+		t.Errorf("Backup.Directory = %q, want %q", got, want)
+	}
+	if got, want := cfg.Backup.TimestampFmt, "from-10"; got != want {
+		t.Errorf("Backup.TimestampFmt = %q, want %q (later file wins)", got, want)
+	}
+	var names []string
+	for _, inst := range cfg.Postgres.Instances {
+		names = append(names, inst.Name)
+	}
+	if len(names) != 2 || names[0] != "billing" || names[1] != "crm" {
+		t.Errorf("Postgres instances = %v, want [billing crm] (appended in file order)", names)
+	}
+
+	empty := t.TempDir()
+	if err := cfg.Load(empty); !errors.Is(err, ErrLoadConfig) {
+		t.Errorf("Load of an empty directory: err = %v, want %v", err, ErrLoadConfig)
+	}
+}
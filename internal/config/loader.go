@@ -1,10 +1,10 @@
 package config
 
 import (
-	"bytes"
 	"errors"
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/spf13/viper"
@@ -17,6 +17,12 @@ import (
 // ErrLoadConfig indicates a failure to read or parse the YAML configuration.
 var ErrLoadConfig = errors.New("config load failed")
 
+// ErrUnknownProfile indicates that the selected profile is not defined.
+var ErrUnknownProfile = errors.New("unknown config profile")
+
+// ErrUnknownTenant indicates that the selected tenant is not defined.
+var ErrUnknownTenant = errors.New("unknown tenant")
+
 // ErrValidateConfig indicates that the loaded configuration is invalid.
 var ErrValidateConfig = errors.New("configuration validation failed")
 
@@ -27,15 +33,42 @@ var ErrValidateConfig = errors.New("configuration validation failed")
 // Config represents the top-level YAML configuration file.
 type Config struct {
 	Include   []string        `mapstructure:"include"   yaml:"include,omitempty"`
+	Profile   string          `mapstructure:"profile"   yaml:"profile,omitempty"`
+	Tenant    string          `mapstructure:"tenant"    yaml:"tenant,omitempty"`
 	Vault     VaultConfig     `mapstructure:"vault"     yaml:"vault"`
 	Backup    BackupConfig    `mapstructure:"backup"    yaml:"backup"`
 	Retention RetentionConfig `mapstructure:"retention" yaml:"retention"`
+	Notify    NotifyConfig    `mapstructure:"notify"    yaml:"notify"`
+	Storage   StorageConfig   `mapstructure:"storage"   yaml:"storage"`
+	Verify    VerifyConfig    `mapstructure:"verify"    yaml:"verify"`
+	Drill     DrillConfig     `mapstructure:"drill"     yaml:"drill"`
+	Restore   RestoreConfig   `mapstructure:"restore"   yaml:"restore"`
+	State     StateConfig     `mapstructure:"state"     yaml:"state"`
+	Tracing   TracingConfig   `mapstructure:"tracing"   yaml:"tracing"`
+	Serve     ServeConfig     `mapstructure:"serve"     yaml:"serve"`
+	Policies  []PolicyConfig  `mapstructure:"policies"  yaml:"policies,omitempty"`
+	// Quota is the storage budget of the whole backup directory; under a
+	// tenant, of the tenant's own directory.
+	Quota QuotaConfig `mapstructure:"quota" yaml:"quota,omitempty"`
+
+	// Deduplicating chunk store for artifacts (see RepositoryConfig).
+	Repository RepositoryConfig `mapstructure:"repository" yaml:"repository"`
 
 	// Per-engine groups
 	Postgres DBGroupConfig `mapstructure:"postgres" yaml:"postgres"`
 	MongoDB  DBGroupConfig `mapstructure:"mongodb"  yaml:"mongodb"`
 	MySQL    DBGroupConfig `mapstructure:"mysql"    yaml:"mysql"`
 	Redis    DBGroupConfig `mapstructure:"redis"    yaml:"redis"`
+
+	ClickHouse DBGroupConfig `mapstructure:"clickhouse" yaml:"clickhouse"`
+	Cassandra  DBGroupConfig `mapstructure:"cassandra"  yaml:"cassandra"`
+	InfluxDB   DBGroupConfig `mapstructure:"influxdb"   yaml:"influxdb"`
+	CouchDB    DBGroupConfig `mapstructure:"couchdb"    yaml:"couchdb"`
+
+	// Named overlays merged over the settings above (see Load).
+	Profiles map[string]any `mapstructure:"profiles" yaml:"profiles,omitempty"`
+	// Teams sharing the deployment, each with its own databases (see tenant.go).
+	Tenants map[string]any `mapstructure:"tenants" yaml:"tenants,omitempty"`
 }
 
 // -----------------------------------------------------------------------------
@@ -46,11 +79,43 @@ type Config struct {
 type VaultConfig struct {
 	Address string `mapstructure:"address" yaml:"address"`
 	Approle string `mapstructure:"approle" yaml:"approle,omitempty"`
+	// Namespace is the Vault Enterprise namespace of every request.
+	Namespace   string                 `mapstructure:"namespace"   yaml:"namespace,omitempty"`
+	TLS         VaultTLSConfig         `mapstructure:"tls"         yaml:"tls,omitempty"`
+	Credentials VaultCredentialsConfig `mapstructure:"credentials" yaml:"credentials,omitempty"`
+}
+
+// VaultCredentialsConfig controls the requests for dynamic database
+// credentials made when instances are initialized.
+type VaultCredentialsConfig struct {
+	// Reuse shares the credentials of a role path between the instances
+	// using it during a run, instead of requesting a user for each.
+	Reuse bool `mapstructure:"reuse"    yaml:"reuse,omitempty"`
+	// Prefetch is the number of credentials requested in parallel before
+	// the instances are initialized; 0 or 1 requests them one at a time.
+	Prefetch int `mapstructure:"prefetch" yaml:"prefetch,omitempty"`
+	// KeepLeases leaves the leases of dynamic credentials to expire,
+	// instead of revoking each once its databases are backed up, for
+	// leases shared with other clients.
+	KeepLeases bool `mapstructure:"keep_leases" yaml:"keep_leases,omitempty"`
+}
+
+// VaultTLSConfig configures the TLS connection to Vault; unset fields fall
+// back to the VAULT_CACERT, VAULT_CLIENT_CERT, ... environment variables.
+type VaultTLSConfig struct {
+	CACert     string `mapstructure:"ca_cert"     yaml:"ca_cert,omitempty"`
+	ClientCert string `mapstructure:"client_cert" yaml:"client_cert,omitempty"`
+	ClientKey  string `mapstructure:"client_key"  yaml:"client_key,omitempty"`
+	ServerName string `mapstructure:"server_name" yaml:"server_name,omitempty"`
+	SkipVerify bool   `mapstructure:"skip_verify" yaml:"skip_verify,omitempty"`
 }
 
 // VaultPaths holds the Vault path prefixes for DB credentials.
 type VaultPaths struct {
 	CredsPath string `mapstructure:"creds_path" yaml:"creds_path"`
+	// TokenPath is the KV secret holding the API token (key "token") of
+	// InfluxDB 2.x instances without a static one.
+	TokenPath string `mapstructure:"token_path" yaml:"token_path,omitempty"`
 }
 
 // -----------------------------------------------------------------------------
@@ -63,6 +128,122 @@ type BackupConfig struct {
 	Compression  bool          `mapstructure:"compression"   yaml:"compression"`
 	TimestampFmt string        `mapstructure:"timestamp_fmt" yaml:"timestamp_fmt"`
 	Timeout      time.Duration `mapstructure:"timeout"       yaml:"timeout"`
+	// WorkDir holds dumps while they are written, checked, compressed and
+	// encrypted, and artifacts unpacked for restores, e.g. on fast local
+	// disk when Directory is network storage. Finished artifacts are moved
+	// into Directory. Empty works in the hidden .partial directory of each
+	// database directory (see database.WorkPath).
+	WorkDir string `mapstructure:"work_dir" yaml:"work_dir,omitempty"`
+	// NameTemplate names artifacts (without extension); see
+	// DefaultNameTemplate for the fields available.
+	NameTemplate string `mapstructure:"name_template" yaml:"name_template,omitempty"`
+	// DirTemplate names the directory of each database's artifacts under
+	// <directory>/<engine>; see DefaultDirTemplate and ArtifactDir.
+	DirTemplate string `mapstructure:"dir_template" yaml:"dir_template,omitempty"`
+	// NotifyInterrupted sends a "recovery" report when runs killed before
+	// finishing are found and marked failed.
+	NotifyInterrupted bool `mapstructure:"notify_interrupted" yaml:"notify_interrupted,omitempty"`
+	// Preflight checks free space against the engine's size estimate.
+	Preflight PreflightConfig `mapstructure:"preflight" yaml:"preflight"`
+	// Encryption encrypts artifacts at rest before they are uploaded.
+	Encryption EncryptionConfig `mapstructure:"encryption" yaml:"encryption"`
+	// Signing signs the checksum of every file artifact.
+	Signing SigningConfig `mapstructure:"signing" yaml:"signing"`
+	// Pipeline bounds how many backups run each step at once.
+	Pipeline PipelineConfig `mapstructure:"pipeline" yaml:"pipeline"`
+	// Stderr keeps the stderr of client tools for diagnosing failures.
+	Stderr StderrConfig `mapstructure:"stderr" yaml:"stderr"`
+	// OutsideWindow is what a run does with databases outside their backup
+	// window: OutsideWindowSkip (default) or OutsideWindowWait.
+	OutsideWindow string `mapstructure:"outside_window" yaml:"outside_window,omitempty"`
+}
+
+// StderrConfig controls how much of the client tools' stderr is kept. The
+// tail is attached to the error, metadata record and notifications of a
+// failed backup or restore; stderr is still echoed to the terminal.
+type StderrConfig struct {
+	// TailKB is the size of the tail in KiB (default 8).
+	TailKB int `mapstructure:"tail_kb" yaml:"tail_kb,omitempty"`
+	// Log keeps the full stderr of every dump that wrote any, as
+	// <database dir>/<start>.stderr.log next to the artifacts.
+	Log bool `mapstructure:"log" yaml:"log,omitempty"`
+}
+
+// PipelineConfig caps the backups in each step of a run. Every database
+// moves through dump, compress (and encrypt) and upload in order; a backup
+// leaves a step's slot as soon as the step ends, so the next database can
+// dump while earlier artifacts are still uploading. Zero means no limit.
+type PipelineConfig struct {
+	Dumps    int `mapstructure:"dumps"    yaml:"dumps,omitempty"`
+	Compress int `mapstructure:"compress" yaml:"compress,omitempty"`
+	Uploads  int `mapstructure:"uploads"  yaml:"uploads,omitempty"`
+	// Weights shares the dump slots between the engines waiting for one,
+	// in proportion to their weight (default 1), so that an engine with
+	// many databases does not hold back the others. Only used when Dumps
+	// is set.
+	Weights map[string]int `mapstructure:"weights" yaml:"weights,omitempty"`
+}
+
+// EncryptionConfig selects how artifacts are encrypted at rest.
+type EncryptionConfig struct {
+	// Type is empty (no encryption) or "vault-transit".
+	Type string `mapstructure:"type" yaml:"type,omitempty"`
+	// Mount is the transit secrets engine mount (default "transit").
+	Mount string `mapstructure:"mount" yaml:"mount,omitempty"`
+	// Key is the transit key that wraps each artifact's data key.
+	Key string `mapstructure:"key" yaml:"key,omitempty"`
+}
+
+// SigningConfig selects how artifact checksums are signed; see package
+// signing.
+type SigningConfig struct {
+	// Type is empty (no signing), "minisign" or "gpg".
+	Type string `mapstructure:"type" yaml:"type,omitempty"`
+	// Key is the minisign secret key file, or the gpg key id to sign with
+	// (gpg's default key when empty). Hosts that only verify leave it out.
+	Key string `mapstructure:"key" yaml:"key,omitempty"`
+	// PublicKey is the minisign public key file signatures are verified
+	// with; gpg verifies against its keyring.
+	PublicKey string `mapstructure:"public_key" yaml:"public_key,omitempty"`
+	// GPGHome is the gpg home directory holding the keyring.
+	GPGHome string `mapstructure:"gpg_home" yaml:"gpg_home,omitempty"`
+	// PassphraseFile and PassphraseEnv hold the passphrase of the secret
+	// key, if it has one.
+	PassphraseFile string `mapstructure:"passphrase_file" yaml:"passphrase_file,omitempty"`
+	PassphraseEnv  string `mapstructure:"passphrase_env"  yaml:"passphrase_env,omitempty"`
+	// VerifyRestore refuses to restore a file artifact whose signature is
+	// missing or does not verify.
+	VerifyRestore bool `mapstructure:"verify_restore" yaml:"verify_restore,omitempty"`
+}
+
+// Passphrase returns the passphrase of the secret key, from
+// passphrase_file or passphrase_env, or "" when neither is set.
+func (s SigningConfig) Passphrase() (string, error) {
+	switch {
+	case s.PassphraseFile != "":
+		data, err := os.ReadFile(s.PassphraseFile)
+		if err != nil {
+			return "", fmt.Errorf("read signing passphrase: %w", err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	case s.PassphraseEnv != "":
+		passphrase, ok := os.LookupEnv(s.PassphraseEnv)
+		if !ok {
+			return "", fmt.Errorf("signing passphrase: $%s is not set", s.PassphraseEnv)
+		}
+		return passphrase, nil
+	}
+	return "", nil
+}
+
+// PreflightConfig controls the free-space check run before each dump.
+type PreflightConfig struct {
+	Enabled bool `mapstructure:"enabled" yaml:"enabled"`
+	// Margin multiplies the size estimate; the backup is aborted when less
+	// free space remains (default 1.0).
+	Margin float64 `mapstructure:"margin" yaml:"margin,omitempty"`
+	// WarnMargin logs a warning when free space is below estimate × WarnMargin.
+	WarnMargin float64 `mapstructure:"warn_margin" yaml:"warn_margin,omitempty"`
 }
 
 // -----------------------------------------------------------------------------
@@ -70,9 +251,296 @@ type BackupConfig struct {
 // -----------------------------------------------------------------------------
 
 // RetentionConfig specifies how many backups to keep and cleanup interval.
+// Local and Remote override Keep for the copies in backup.directory and in
+// storage, e.g. a few recent backups on disk and a month of them remotely.
 type RetentionConfig struct {
 	Keep     int           `mapstructure:"keep"     yaml:"keep"`
 	Interval time.Duration `mapstructure:"interval" yaml:"interval"`
+	Local    RetentionTier `mapstructure:"local"    yaml:"local,omitempty"`
+	Remote   RetentionTier `mapstructure:"remote"   yaml:"remote,omitempty"`
+}
+
+// RetentionTier is the retention of one copy of the backups. Zero inherits
+// retention.keep.
+type RetentionTier struct {
+	Keep int `mapstructure:"keep" yaml:"keep,omitempty"`
+}
+
+// LocalKeep returns how many backups keep their local copy; 0 keeps all.
+func (r RetentionConfig) LocalKeep() int {
+	if r.Local.Keep > 0 {
+		return r.Local.Keep
+	}
+	return r.Keep
+}
+
+// RemoteKeep returns how many backups keep their remote copy; 0 keeps all.
+func (r RetentionConfig) RemoteKeep() int {
+	if r.Remote.Keep > 0 {
+		return r.Remote.Keep
+	}
+	return r.Keep
+}
+
+// MaxKeep returns how many backups keep at least one of their copies.
+func (r RetentionConfig) MaxKeep() int {
+	return max(r.LocalKeep(), r.RemoteKeep())
+}
+
+// -----------------------------------------------------------------------------
+// Storage
+// -----------------------------------------------------------------------------
+
+// StorageConfig selects the remote backend artifacts are copied to after a backup.
+// An empty Type keeps artifacts on local disk only.
+type StorageConfig struct {
+	Type   string       `mapstructure:"type"   yaml:"type,omitempty"` // local|s3|rclone
+	Local  LocalConfig  `mapstructure:"local"  yaml:"local"`
+	S3     S3Config     `mapstructure:"s3"     yaml:"s3"`
+	Rclone RcloneConfig `mapstructure:"rclone" yaml:"rclone"`
+	// Replicas receive a copy of every artifact uploaded to the backend
+	// above, e.g. a bucket in a second region.
+	Replicas []ReplicaConfig `mapstructure:"replicas" yaml:"replicas,omitempty"`
+}
+
+// ReplicaConfig is a secondary backend artifacts are replicated to. It takes
+// the same settings as the primary backend, under a name that identifies it
+// in the catalog.
+type ReplicaConfig struct {
+	Name   string       `mapstructure:"name"   yaml:"name"`
+	Type   string       `mapstructure:"type"   yaml:"type"` // local|s3|rclone
+	Local  LocalConfig  `mapstructure:"local"  yaml:"local"`
+	S3     S3Config     `mapstructure:"s3"     yaml:"s3"`
+	Rclone RcloneConfig `mapstructure:"rclone" yaml:"rclone"`
+}
+
+// Backend returns the replica's backend settings.
+func (r ReplicaConfig) Backend() StorageConfig {
+	return StorageConfig{Type: r.Type, Local: r.Local, S3: r.S3, Rclone: r.Rclone}
+}
+
+// RepositoryConfig enables the deduplicating chunk repository: file
+// artifacts are split into content-defined chunks and only the chunks no
+// earlier backup stored are written, compressed, to the repository's
+// backend, instead of uploading each artifact whole to storage. It takes the
+// same backend settings as storage.
+type RepositoryConfig struct {
+	Type   string       `mapstructure:"type"   yaml:"type,omitempty"` // local|s3|rclone; empty disables
+	Local  LocalConfig  `mapstructure:"local"  yaml:"local"`
+	S3     S3Config     `mapstructure:"s3"     yaml:"s3"`
+	Rclone RcloneConfig `mapstructure:"rclone" yaml:"rclone"`
+	// PackSizeMB is the size of the pack files chunks are grouped in
+	// (default 16).
+	PackSizeMB int `mapstructure:"pack_size_mb" yaml:"pack_size_mb,omitempty"`
+}
+
+// Backend returns the repository's backend settings.
+func (r RepositoryConfig) Backend() StorageConfig {
+	return StorageConfig{Type: r.Type, Local: r.Local, S3: r.S3, Rclone: r.Rclone}
+}
+
+// LocalConfig holds settings for a mounted filesystem backend (NFS, SMB, ...).
+type LocalConfig struct {
+	Path string `mapstructure:"path" yaml:"path"`
+}
+
+// S3Config holds settings for AWS S3 and S3-compatible stores (MinIO, Ceph RGW).
+type S3Config struct {
+	Bucket         string `mapstructure:"bucket"           yaml:"bucket"`
+	Prefix         string `mapstructure:"prefix"           yaml:"prefix,omitempty"`
+	Region         string `mapstructure:"region"           yaml:"region,omitempty"`
+	Endpoint       string `mapstructure:"endpoint"         yaml:"endpoint,omitempty"`
+	ForcePathStyle bool   `mapstructure:"force_path_style" yaml:"force_path_style,omitempty"`
+	AccessKey      string `mapstructure:"access_key"       yaml:"access_key,omitempty"`
+	SecretKey      string `mapstructure:"secret_key"       yaml:"secret_key,omitempty"`
+	// VaultPath points to a KV secret holding "access_key" and "secret_key".
+	VaultPath  string `mapstructure:"vault_path"  yaml:"vault_path,omitempty"`
+	CABundle   string `mapstructure:"ca_bundle"   yaml:"ca_bundle,omitempty"`
+	SkipVerify bool   `mapstructure:"skip_verify" yaml:"skip_verify,omitempty"`
+	// Artifacts of at least MultipartThresholdMB (default 100, negative
+	// disables) are uploaded in parts of PartSizeMB (default 16),
+	// UploadConcurrency parts at a time (default 4). Failed parts are
+	// retried alone and an interrupted upload resumes on the next attempt.
+	MultipartThresholdMB int `mapstructure:"multipart_threshold_mb" yaml:"multipart_threshold_mb,omitempty"`
+	PartSizeMB           int `mapstructure:"part_size_mb"           yaml:"part_size_mb,omitempty"`
+	UploadConcurrency    int `mapstructure:"upload_concurrency"     yaml:"upload_concurrency,omitempty"`
+}
+
+// RcloneConfig holds settings for any rclone remote (Google Drive, Azure
+// Blob, SFTP, B2, ...). Remotes are defined in rclone's own configuration.
+type RcloneConfig struct {
+	Remote  string   `mapstructure:"remote"  yaml:"remote"` // name:path
+	Config  string   `mapstructure:"config"  yaml:"config,omitempty"`
+	BWLimit string   `mapstructure:"bwlimit" yaml:"bwlimit,omitempty"`
+	Flags   []string `mapstructure:"flags"   yaml:"flags,omitempty"`
+	Binary  string   `mapstructure:"binary"  yaml:"binary,omitempty"`
+}
+
+// -----------------------------------------------------------------------------
+// Restore
+// -----------------------------------------------------------------------------
+
+// RestoreConfig contains global restore options.
+type RestoreConfig struct {
+	// MaxPerHost caps concurrent restores against the same target host (default 1).
+	MaxPerHost int `mapstructure:"max_per_host" yaml:"max_per_host,omitempty"`
+	// Stream pipes compressed or encrypted artifacts into the restore tool
+	// as they are unpacked, for engines that restore from stdin (PostgreSQL
+	// pg_dump formats other than directory, MongoDB archives), instead of
+	// writing the plaintext next to the artifact first. PostgreSQL plain
+	// dumps are streamed regardless.
+	Stream bool `mapstructure:"stream" yaml:"stream,omitempty"`
+}
+
+// -----------------------------------------------------------------------------
+// State
+// -----------------------------------------------------------------------------
+
+// StateConfig selects where run markers and per-database locks are kept.
+type StateConfig struct {
+	Backend string        `mapstructure:"backend"  yaml:"backend,omitempty"`  // "local" (default) or "vault"
+	Path    string        `mapstructure:"path"     yaml:"path,omitempty"`     // local: defaults to <backup.directory>/.state
+	Mount   string        `mapstructure:"mount"    yaml:"mount,omitempty"`    // vault: KV v2 mount (default "secret")
+	Prefix  string        `mapstructure:"prefix"   yaml:"prefix,omitempty"`   // vault: path under the mount (default "bacli/state")
+	LockTTL time.Duration `mapstructure:"lock_ttl" yaml:"lock_ttl,omitempty"` // how long a lock survives a crashed run (default 6h)
+}
+
+// -----------------------------------------------------------------------------
+// Verification
+// -----------------------------------------------------------------------------
+
+// VerifyConfig describes where deep verification restores backups to.
+type VerifyConfig struct {
+	// Host of the verification instance; empty restores next to the source database.
+	Host string `mapstructure:"host"   yaml:"host,omitempty"`
+	// Suffix appended to the database name to build the throwaway target.
+	Suffix string `mapstructure:"suffix" yaml:"suffix,omitempty"`
+	// Keep leaves the throwaway database in place after verification.
+	Keep bool `mapstructure:"keep"   yaml:"keep,omitempty"`
+}
+
+// DrillConfig describes the restore rehearsals of `bacli drill`: where the
+// latest backups are restored to, and the recovery time objective each
+// restore must meet. Engines and instances may set their own rto.
+type DrillConfig struct {
+	// Host of the staging instance; empty falls back to verify.host.
+	Host string `mapstructure:"host"   yaml:"host,omitempty"`
+	// Suffix appended to the database name to build the staging target
+	// (default "_drill").
+	Suffix string `mapstructure:"suffix" yaml:"suffix,omitempty"`
+	// Keep leaves the staging databases in place after the drill.
+	Keep bool `mapstructure:"keep"   yaml:"keep,omitempty"`
+	// RTO is the default recovery time objective; zero sets none.
+	RTO time.Duration `mapstructure:"rto"    yaml:"rto,omitempty"`
+}
+
+// RTO returns the recovery time objective of the instance named instance of
+// engine: its own, the engine's, or drill.rto. Zero means none is set.
+func (c *Config) RTO(engine, instance string) time.Duration {
+	group, ok := c.EngineGroup(engine)
+	if !ok {
+		return c.Drill.RTO
+	}
+	for _, inst := range group.Instances {
+		if inst.Name == instance && inst.RTO > 0 {
+			return inst.RTO
+		}
+	}
+	if group.RTO > 0 {
+		return group.RTO
+	}
+	return c.Drill.RTO
+}
+
+// -----------------------------------------------------------------------------
+// Tracing
+// -----------------------------------------------------------------------------
+
+// TracingConfig enables OpenTelemetry spans exported over OTLP/HTTP.
+type TracingConfig struct {
+	Enabled bool `mapstructure:"enabled"      yaml:"enabled"`
+	// Endpoint is the OTLP/HTTP URL, e.g. "http://otel-collector:4318".
+	// Empty falls back to OTEL_EXPORTER_OTLP_ENDPOINT.
+	Endpoint    string  `mapstructure:"endpoint"     yaml:"endpoint,omitempty"`
+	Insecure    bool    `mapstructure:"insecure"     yaml:"insecure,omitempty"`
+	ServiceName string  `mapstructure:"service_name" yaml:"service_name,omitempty"` // default "bacli"
+	SampleRatio float64 `mapstructure:"sample_ratio" yaml:"sample_ratio,omitempty"` // 0 or 1 samples every run
+}
+
+// -----------------------------------------------------------------------------
+// HTTP API
+// -----------------------------------------------------------------------------
+
+// ServeConfig configures the HTTP API started by `bacli serve`.
+type ServeConfig struct {
+	Listen string `mapstructure:"listen" yaml:"listen,omitempty"` // default ":8080"
+	// Clients authenticate with "Authorization: Bearer <token>". The token
+	// is read from TokenFile, or from the TokenEnv environment variable
+	// (default BACLI_API_TOKEN).
+	TokenFile string `mapstructure:"token_file" yaml:"token_file,omitempty"`
+	TokenEnv  string `mapstructure:"token_env"  yaml:"token_env,omitempty"`
+	// TLSCert and TLSKey serve HTTPS when both are set.
+	TLSCert string `mapstructure:"tls_cert" yaml:"tls_cert,omitempty"`
+	TLSKey  string `mapstructure:"tls_key"  yaml:"tls_key,omitempty"`
+}
+
+// -----------------------------------------------------------------------------
+// Notifications
+// -----------------------------------------------------------------------------
+
+// NotifyConfig groups the notification channels fired after each run.
+type NotifyConfig struct {
+	Email       EmailConfig       `mapstructure:"email"       yaml:"email"`
+	Ping        PingConfig        `mapstructure:"ping"        yaml:"ping"`
+	Annotations AnnotationsConfig `mapstructure:"annotations" yaml:"annotations"`
+}
+
+// AnnotationsConfig posts backup events to Grafana annotations or a generic
+// events API, to overlay backup windows on database dashboards.
+type AnnotationsConfig struct {
+	Enabled bool   `mapstructure:"enabled" yaml:"enabled"`
+	URL     string `mapstructure:"url"     yaml:"url"`
+	// Format is "grafana" (default; POST <url>/api/annotations) or
+	// "generic" (POST <url> with a JSON event).
+	Format string `mapstructure:"format" yaml:"format,omitempty"`
+	// Token is sent as a bearer token, e.g. a Grafana service account token.
+	Token string `mapstructure:"token" yaml:"token,omitempty"`
+	// VaultPath points to a KV secret holding "token".
+	VaultPath string `mapstructure:"vault_path" yaml:"vault_path,omitempty"`
+	// DashboardUID attaches Grafana annotations to one dashboard instead of
+	// the whole organization.
+	DashboardUID string   `mapstructure:"dashboard_uid" yaml:"dashboard_uid,omitempty"`
+	Tags         []string `mapstructure:"tags"          yaml:"tags,omitempty"`
+}
+
+// PingConfig holds dead man's switch settings (Healthchecks.io, Dead Man's Snitch).
+type PingConfig struct {
+	Enabled bool   `mapstructure:"enabled" yaml:"enabled"`
+	URL     string `mapstructure:"url"     yaml:"url"`
+	// Start pings <url>/start when a run begins.
+	Start bool `mapstructure:"start"    yaml:"start,omitempty"`
+	// Failures pings <url>/fail when a run fails instead of staying silent.
+	Failures bool `mapstructure:"failures" yaml:"failures,omitempty"`
+}
+
+// EmailConfig holds SMTP settings for run summary emails.
+type EmailConfig struct {
+	Enabled  bool   `mapstructure:"enabled"    yaml:"enabled"`
+	Host     string `mapstructure:"host"       yaml:"host"`
+	Port     int    `mapstructure:"port"       yaml:"port"`
+	TLS      string `mapstructure:"tls"        yaml:"tls"` // none|starttls|tls
+	From     string `mapstructure:"from"       yaml:"from"`
+	Username string `mapstructure:"username"   yaml:"username,omitempty"`
+	Password string `mapstructure:"password"   yaml:"password,omitempty"`
+	// VaultPath points to a KV secret holding "username" and "password".
+	VaultPath  string          `mapstructure:"vault_path" yaml:"vault_path,omitempty"`
+	Recipients EmailRecipients `mapstructure:"recipients" yaml:"recipients"`
+}
+
+// EmailRecipients splits recipients by the runs they want to hear about.
+type EmailRecipients struct {
+	All      []string `mapstructure:"all"      yaml:"all,omitempty"`      // every run
+	Failures []string `mapstructure:"failures" yaml:"failures,omitempty"` // runs with failures only
 }
 
 // -----------------------------------------------------------------------------
@@ -85,53 +553,416 @@ type EngineDefaults struct {
 	Port        string        `mapstructure:"port"        yaml:"port,omitempty"`
 	Timeout     time.Duration `mapstructure:"timeout"     yaml:"timeout,omitempty"`
 	Role        string        `mapstructure:"role"        yaml:"role,omitempty"`
-	Compression bool          `mapstructure:"compression" yaml:"compression,omitempty"`
+	Compression string        `mapstructure:"compression" yaml:"compression,omitempty"` // see Config.Compression
 	Format      string        `mapstructure:"format"      yaml:"format,omitempty"`
 	Method      string        `mapstructure:"format"      yaml:"format,omitempty"`
+	// Jobs runs pg_dump/pg_restore with this many parallel workers
+	// (postgres directory format).
+	Jobs int `mapstructure:"jobs" yaml:"jobs,omitempty"`
+	// Globals also backs up the roles and tablespaces of every postgres
+	// instance with pg_dumpall --globals-only, restored first.
+	Globals bool `mapstructure:"globals" yaml:"globals,omitempty"`
+	// DataDir is the server data directory physical MySQL restores
+	// (xtrabackup/mariabackup) copy back into.
+	DataDir string `mapstructure:"datadir" yaml:"datadir,omitempty"`
+	// BackupDisk is the ClickHouse server disk (local or S3) the "backup"
+	// format writes to; it must be listed in the server's backups.allowed_disk.
+	BackupDisk string `mapstructure:"backup_disk" yaml:"backup_disk,omitempty"`
+	// RestoreMethod selects how Cassandra snapshots are restored:
+	// "sstableloader" (default) or "refresh".
+	RestoreMethod string `mapstructure:"restore_method" yaml:"restore_method,omitempty"`
+	// Version is the InfluxDB major version, "1" or "2"; empty detects it
+	// from the server.
+	Version string `mapstructure:"version" yaml:"version,omitempty"`
+	// Org is the InfluxDB 2.x organization owning the buckets.
+	Org string `mapstructure:"org" yaml:"org,omitempty"`
+	// Window and Blackout restrict when the engine's instances are backed
+	// up; see BackupWindow.
+	Window   string   `mapstructure:"window"   yaml:"window,omitempty"`
+	Blackout []string `mapstructure:"blackout" yaml:"blackout,omitempty"`
+	// RTO is the recovery time objective checked by `bacli drill`.
+	RTO time.Duration `mapstructure:"rto" yaml:"rto,omitempty"`
+	// ReadPreference is the MongoDB read preference of dumps, e.g.
+	// "secondary" to keep them off the primary.
+	ReadPreference string `mapstructure:"read_preference" yaml:"read_preference,omitempty"`
+	// Oplog dumps MongoDB with --oplog for a point-in-time consistent
+	// snapshot, replayed on restore (see DBInstance.Oplog).
+	Oplog bool `mapstructure:"oplog" yaml:"oplog,omitempty"`
 }
 
 // DBGroupConfig groups engine-level defaults and Vault prefixes.
 type DBGroupConfig struct {
 	EngineDefaults `mapstructure:",squash" yaml:",inline"` // inline default fields
 
+	// Enabled set to false skips every instance of the engine without
+	// removing its configuration; unset means enabled.
+	Enabled   *bool        `mapstructure:"enabled"   yaml:"enabled,omitempty"`
 	Vault     VaultPaths   `mapstructure:"vault"     yaml:"vault"`
 	Instances []DBInstance `mapstructure:"instances" yaml:"instances"`
 }
 
+// IsEnabled reports whether the instances of g are initialized.
+func (g DBGroupConfig) IsEnabled() bool {
+	return g.Enabled == nil || *g.Enabled
+}
+
+// AllDatabases as an instance's database backs up every database found on
+// the server at run time, each as its own artifact.
+const AllDatabases = "*"
+
 // DBInstance represents a single database within a group.
 type DBInstance struct {
-	Name        string `mapstructure:"name"        yaml:"name"`
+	Name string `mapstructure:"name" yaml:"name"`
+	// Enabled set to false skips the instance, e.g. while its server is
+	// down, without removing its configuration; unset means enabled.
+	Enabled     *bool  `mapstructure:"enabled"     yaml:"enabled,omitempty"`
 	Host        string `mapstructure:"host"        yaml:"host,omitempty"`
 	Port        string `mapstructure:"port"        yaml:"port,omitempty"`
 	Database    string `mapstructure:"database"    yaml:"database,omitempty"`
 	Role        string `mapstructure:"role"        yaml:"role,omitempty"`
 	Format      string `mapstructure:"format"      yaml:"format,omitempty"`
-	Compression bool   `mapstructure:"compression" yaml:"compression,omitempty"`
+	Compression string `mapstructure:"compression" yaml:"compression,omitempty"`
 	Method      string `mapstructure:"format"      yaml:"format,omitempty"`
+	Jobs        int    `mapstructure:"jobs"        yaml:"jobs,omitempty"`
+	DataDir     string `mapstructure:"datadir"     yaml:"datadir,omitempty"`
+	// Exclude lists databases skipped when Database is AllDatabases, on top
+	// of the engine's system databases.
+	Exclude []string `mapstructure:"exclude" yaml:"exclude,omitempty"`
+	// Tables limits the backup to these tables of Database (ClickHouse,
+	// PostgreSQL). ExcludeTables leaves tables out, and Schemas and
+	// ExcludeSchemas select schemas (PostgreSQL), with pg_dump's own
+	// patterns ("audit_*", "sales.order_*").
+	Tables         []string `mapstructure:"tables"          yaml:"tables,omitempty"`
+	ExcludeTables  []string `mapstructure:"exclude_tables"  yaml:"exclude_tables,omitempty"`
+	Schemas        []string `mapstructure:"schemas"         yaml:"schemas,omitempty"`
+	ExcludeSchemas []string `mapstructure:"exclude_schemas" yaml:"exclude_schemas,omitempty"`
+	// Tags classify the instance (e.g. "prod") for policy rules.
+	Tags []string `mapstructure:"tags" yaml:"tags,omitempty"`
+	// Labels are recorded in backup metadata and applied as object tags by
+	// storage backends that support them. Keys are lower-cased on load.
+	Labels map[string]string `mapstructure:"labels" yaml:"labels,omitempty"`
+	// AllowOverwrite lets restores drop and replace a target database that
+	// already holds data without --force (PostgreSQL, MongoDB, MySQL,
+	// ClickHouse, Cassandra).
+	AllowOverwrite bool `mapstructure:"allow_overwrite" yaml:"allow_overwrite,omitempty"`
+	// Username selects static credentials instead of a Vault role. The
+	// password comes from Password, PasswordFile or PasswordEnv (at most one).
+	Username     string `mapstructure:"username"      yaml:"username,omitempty"`
+	Password     string `mapstructure:"password"      yaml:"password,omitempty"`
+	PasswordFile string `mapstructure:"password_file" yaml:"password_file,omitempty"`
+	PasswordEnv  string `mapstructure:"password_env"  yaml:"password_env,omitempty"`
+	// Window overrides the engine's backup window; Blackout adds dates to
+	// the engine's (see BackupWindow).
+	Window   string   `mapstructure:"window"   yaml:"window,omitempty"`
+	Blackout []string `mapstructure:"blackout" yaml:"blackout,omitempty"`
+	// RTO is the recovery time objective checked by `bacli drill`.
+	RTO time.Duration `mapstructure:"rto" yaml:"rto,omitempty"`
+	// DependsOn names the instances restored before this one by `bacli
+	// restore`: "name" for an instance of the same engine, "engine:name"
+	// for another engine's (see Dependencies).
+	DependsOn []string `mapstructure:"depends_on" yaml:"depends_on,omitempty"`
+	// Exec runs the instance's client tools inside its pod or container
+	// instead of on the backup host (PostgreSQL, MongoDB and MySQL).
+	Exec ExecConfig `mapstructure:"exec" yaml:"exec,omitempty"`
+	// URI is a MongoDB connection string, e.g. a replica set
+	// "mongodb://h1:27017,h2:27017/?replicaSet=rs0" or an SRV record
+	// "mongodb+srv://cluster0.example.mongodb.net/", used instead of Host
+	// and Port. Credentials still come from Vault or Username.
+	URI string `mapstructure:"uri" yaml:"uri,omitempty"`
+	// TLS connects the MongoDB tools over TLS.
+	TLS MongoTLSConfig `mapstructure:"tls" yaml:"tls,omitempty"`
+	// ReadPreference overrides the engine's MongoDB read preference.
+	ReadPreference string `mapstructure:"read_preference" yaml:"read_preference,omitempty"`
+	// Oplog dumps with mongodump --oplog, which only takes whole
+	// deployments: the artifact holds every database as of the end of the
+	// dump, and restores replay the oplog into Database.
+	Oplog bool `mapstructure:"oplog" yaml:"oplog,omitempty"`
+	// Collections selects the MongoDB collections dumped.
+	Collections Filter `mapstructure:"collections" yaml:"collections,omitempty"`
+	// Content limits PostgreSQL dumps to definitions or data (see
+	// ContentSchemaOnly); empty dumps both.
+	Content string `mapstructure:"content" yaml:"content,omitempty"`
+	// Flavor adapts PostgreSQL backups and restores to an extension that
+	// changes how the database must be dumped or loaded: FlavorTimescaleDB
+	// or FlavorCitus.
+	Flavor string `mapstructure:"flavor" yaml:"flavor,omitempty"`
+	// Extensions lists the PostgreSQL extensions the restore target must
+	// provide; restores fail before loading anything without them.
+	Extensions []string `mapstructure:"extensions" yaml:"extensions,omitempty"`
+	// Transform rewrites plain SQL dumps before they are restored.
+	Transform TransformConfig `mapstructure:"transform" yaml:"transform,omitempty"`
+	// Webhook is called after each successful backup of the instance's
+	// databases.
+	Webhook WebhookConfig `mapstructure:"webhook" yaml:"webhook,omitempty"`
+	// Quota is the storage budget of each database of the instance.
+	Quota QuotaConfig `mapstructure:"quota" yaml:"quota,omitempty"`
+}
+
+// IsEnabled reports whether i is initialized, its engine group aside.
+func (i DBInstance) IsEnabled() bool {
+	return i.Enabled == nil || *i.Enabled
+}
+
+// IsEngineEnabled reports whether the instances of engine are initialized;
+// unknown engines are not.
+func (c *Config) IsEngineEnabled(engine string) bool {
+	group, ok := c.EngineGroup(engine)
+	return ok && group.IsEnabled()
+}
+
+// MongoTLSConfig connects the MongoDB tools to clusters that require TLS,
+// such as Atlas (mongodb+srv:// URIs turn TLS on by themselves). With exec,
+// the files are read inside the pod or container.
+type MongoTLSConfig struct {
+	Enabled bool `mapstructure:"enabled" yaml:"enabled,omitempty"`
+	// CACert verifies the server certificate instead of the system roots.
+	CACert string `mapstructure:"ca_cert" yaml:"ca_cert,omitempty"`
+	// ClientCert is a PEM file holding the client certificate and its
+	// key, for servers that require one (e.g. X.509 authentication).
+	ClientCert string `mapstructure:"client_cert" yaml:"client_cert,omitempty"`
+	// SkipVerify accepts server certificates that fail verification.
+	SkipVerify bool `mapstructure:"skip_verify" yaml:"skip_verify,omitempty"`
+}
+
+// QuotaConfig is a storage budget for the artifacts kept in the local
+// backup directory. Backups that would take the usage over LimitMB fail in
+// preflight; over WarnMB they log a warning. Zero disables either check.
+type QuotaConfig struct {
+	WarnMB  int64 `mapstructure:"warn_mb"  yaml:"warn_mb,omitempty"`
+	LimitMB int64 `mapstructure:"limit_mb" yaml:"limit_mb,omitempty"`
+}
+
+// Enabled reports whether q sets a warning threshold or a limit.
+func (q QuotaConfig) Enabled() bool {
+	return q.WarnMB > 0 || q.LimitMB > 0
+}
+
+// InstanceQuota returns the quota of the instance named instance of engine.
+func (c *Config) InstanceQuota(engine, instance string) QuotaConfig {
+	group, _ := c.EngineGroup(engine)
+	for _, inst := range group.Instances {
+		if inst.Name == instance {
+			return inst.Quota
+		}
+	}
+	return QuotaConfig{}
+}
+
+// WebhookConfig posts a JSON event naming the artifact, its checksum and
+// the duration of the dump after each database of an instance is backed
+// up, e.g. to refresh the analytics fed from that database.
+type WebhookConfig struct {
+	URL string `mapstructure:"url" yaml:"url,omitempty"` // http:// or https://
+	// Token is sent as a bearer token.
+	Token string `mapstructure:"token" yaml:"token,omitempty"`
+}
+
+// Webhook returns the webhook of the instance named instance of engine;
+// its URL is empty when none is configured.
+func (c *Config) Webhook(engine, instance string) WebhookConfig {
+	group, _ := c.EngineGroup(engine)
+	for _, inst := range group.Instances {
+		if inst.Name == instance {
+			return inst.Webhook
+		}
+	}
+	return WebhookConfig{}
+}
+
+// TransformConfig rewrites the plain SQL dumps of an instance (PostgreSQL
+// plain, MySQL dump) before they are restored, e.g. to scrub personal data
+// out of production backups restored into staging. Restores of instances
+// with a transform refuse other artifacts.
+type TransformConfig struct {
+	// Filters are shell commands run in order, each reading the dump on
+	// stdin and writing the rewritten dump to stdout.
+	Filters []string `mapstructure:"filters" yaml:"filters,omitempty"`
+	// Mask replaces column values in the COPY data of PostgreSQL dumps,
+	// before the filters run.
+	Mask []MaskRule `mapstructure:"mask" yaml:"mask,omitempty"`
+}
+
+// Enabled reports whether t rewrites anything.
+func (t TransformConfig) Enabled() bool {
+	return len(t.Filters) > 0 || len(t.Mask) > 0
+}
+
+// Transform returns the transform of the instance named instance of engine.
+func (c *Config) Transform(engine, instance string) TransformConfig {
+	group, ok := c.EngineGroup(engine)
+	if !ok {
+		return TransformConfig{}
+	}
+	for _, inst := range group.Instances {
+		if inst.Name == instance {
+			return inst.Transform
+		}
+	}
+	return TransformConfig{}
+}
+
+// MaskRule replaces every value of a column.
+type MaskRule struct {
+	Table  string `mapstructure:"table"  yaml:"table"` // "schema.table", as in the dump
+	Column string `mapstructure:"column" yaml:"column"`
+	// Value replaces the values: MaskNull, MaskHash, or a literal.
+	Value string `mapstructure:"value" yaml:"value"`
+}
+
+// Special MaskRule values.
+const (
+	// MaskNull replaces values with NULL.
+	MaskNull = "null"
+	// MaskHash replaces values with a digest of the original, so that equal
+	// values stay equal (joins and unique constraints still hold).
+	MaskHash = "hash"
+)
+
+// PostgreSQL flavors (flavor).
+const (
+	FlavorTimescaleDB = "timescaledb"
+	FlavorCitus       = "citus"
+)
+
+// PostgreSQL dump contents (content).
+const (
+	ContentSchemaOnly = "schema-only"
+	ContentDataOnly   = "data-only"
+)
+
+// Exec driver types (exec.type).
+const (
+	ExecLocal      = "local"      // run client tools on the backup host (default)
+	ExecKubernetes = "kubernetes" // kubectl exec into the database pod
+	ExecDocker     = "docker"     // docker exec into the database container
+)
+
+// ExecConfig selects where an instance's client tools run, for databases
+// that are not reachable from the backup host or whose client tools are not
+// installed on it. Dumps and restores then stream over the exec session;
+// host in the instance is as seen from inside the pod (usually localhost).
+type ExecConfig struct {
+	Type string `mapstructure:"type" yaml:"type,omitempty"`
+	// Namespace, and Pod or a label Selector picking the first running
+	// pod, locate the database pod; Container selects its container
+	// (default: the pod's default container). Docker only uses Container,
+	// and Context.
+	Namespace string `mapstructure:"namespace" yaml:"namespace,omitempty"`
+	Pod       string `mapstructure:"pod"       yaml:"pod,omitempty"`
+	Selector  string `mapstructure:"selector"  yaml:"selector,omitempty"`
+	Container string `mapstructure:"container" yaml:"container,omitempty"`
+	// Context and Kubeconfig select the cluster (default: kubectl's), or
+	// Context the Docker context (default: docker's).
+	Context    string `mapstructure:"context"    yaml:"context,omitempty"`
+	Kubeconfig string `mapstructure:"kubeconfig" yaml:"kubeconfig,omitempty"`
+}
+
+// Remote reports whether client tools run away from the backup host.
+func (e ExecConfig) Remote() bool {
+	return e.Type != "" && e.Type != ExecLocal
+}
+
+// -----------------------------------------------------------------------------
+// Policies
+// -----------------------------------------------------------------------------
+
+// PolicyConfig is a backup coverage rule evaluated by `bacli policy check`.
+// It applies to every instance of Engine (any engine when empty) that
+// carries all of Tags.
+type PolicyConfig struct {
+	Name    string             `mapstructure:"name"    yaml:"name"`
+	Engine  string             `mapstructure:"engine"  yaml:"engine,omitempty"`
+	Tags    []string           `mapstructure:"tags"    yaml:"tags,omitempty"`
+	Require PolicyRequirements `mapstructure:"require" yaml:"require"`
+}
+
+// PolicyRequirements lists what a matching instance's effective
+// configuration must provide. Zero values are not checked.
+type PolicyRequirements struct {
+	MinKeep int `mapstructure:"min_keep" yaml:"min_keep,omitempty"`
+	// MinRetention is compared with retention.keep × retention.interval.
+	MinRetention  time.Duration `mapstructure:"min_retention"  yaml:"min_retention,omitempty"`
+	Compression   bool          `mapstructure:"compression"    yaml:"compression,omitempty"`
+	Encryption    bool          `mapstructure:"encryption"     yaml:"encryption,omitempty"`
+	RemoteStorage bool          `mapstructure:"remote_storage" yaml:"remote_storage,omitempty"`
+}
+
+// ProfileEnv selects a profile when no profile was chosen explicitly.
+const ProfileEnv = "BACLI_PROFILE"
+
+// selectedProfile is the profile chosen on the command line (see UseProfile).
+var selectedProfile string
+
+// UseProfile selects the profile applied by every subsequent Load call.
+// An empty name falls back to the BACLI_PROFILE environment variable.
+func UseProfile(name string) {
+	selectedProfile = name
 }
 
 // Load reads the configuration from the given YAML file using Viper,
 // merges any included files, and unmarshals into the Config struct.
+// ${VAR} and ${VAR:-default} references are expanded from the environment
+// in the base file and every include before parsing.
+//
+// Includes are resolved as described in loadFile. path may also be a
+// directory, whose *.yaml files are merged as described in loadDir.
+//
+// When a profile is selected (UseProfile or BACLI_PROFILE), the settings
+// under profiles.<name> are merged over the shared top-level settings.
+//
+// When a tenant is selected (UseTenant or BACLI_TENANT), the result is then
+// scoped to it as described in scopeTenant.
+//
+// The result is checked with Validate before Load returns.
+//
+// A path kept loaded by a running Watcher is served from its current
+// snapshot instead of being read again.
 func (c *Config) Load(path string) error {
+	if c.loadWatched(path) {
+		return nil
+	}
+	return c.load(path)
+}
+
+// load reads, merges and validates the configuration at path (see Load).
+func (c *Config) load(path string) error {
 	v := viper.New()
-	v.SetConfigFile(path)
 	v.SetConfigType("yaml")
-	v.SetConfigType("yml")
 	v.AutomaticEnv()
 
-	// Read base configuration
-	if err := v.ReadInConfig(); err != nil {
-		return fmt.Errorf("%w: read base config %s: %v", ErrLoadConfig, path, err)
+	// Read base configuration together with its includes
+	settings, err := loadPath(path)
+	if err != nil {
+		return err
+	}
+	if err := v.MergeConfigMap(settings); err != nil {
+		return fmt.Errorf("%w: merge %s: %v", ErrLoadConfig, path, err)
 	}
 
-	// Merge include files (if any)
-	for _, inc := range v.GetStringSlice("include") {
-		data, err := os.ReadFile(inc)
-		if err != nil {
-			return fmt.Errorf("%w: read include %s: %v", ErrLoadConfig, inc, err)
+	// Overlay the selected profile
+	profile := selectedProfile
+	if profile == "" {
+		profile = os.Getenv(ProfileEnv)
+	}
+	if profile != "" {
+		key := "profiles." + profile
+		if !v.IsSet(key) {
+			return fmt.Errorf("%w: %q", ErrUnknownProfile, profile)
 		}
-		if err := v.MergeConfig(bytes.NewReader(data)); err != nil {
-			return fmt.Errorf("%w: merge include %s: %v", ErrLoadConfig, inc, err)
+		if err := v.MergeConfigMap(v.Sub(key).AllSettings()); err != nil {
+			return fmt.Errorf("%w: merge profile %s: %v", ErrLoadConfig, profile, err)
+		}
+		v.Set("profile", profile)
+	}
+
+	// Scope to the selected tenant
+	tenant := selectedTenant
+	if tenant == "" {
+		tenant = os.Getenv(TenantEnv)
+	}
+	if tenant != "" {
+		if v, err = scopeTenant(v, tenant); err != nil {
+			return err
 		}
 	}
 
@@ -139,6 +970,9 @@ func (c *Config) Load(path string) error {
 	if err := v.UnmarshalExact(c); err != nil {
 		return fmt.Errorf("%w: unmarshal config: %v", ErrLoadConfig, err)
 	}
+	for _, dir := range c.localDirs() {
+		*dir = volumeRoot(*dir)
+	}
 
-	return nil
+	return c.Validate()
 }
@@ -1,24 +1,27 @@
 package config
 
 import (
+	"errors"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestLoadConfig_ParsesBackupTimeout(t *testing.T) {
 	yaml := `
 backup:
-  output_dir: "/tmp/backups"
-  compress: true
-  timestamp_format: "2006-01-02_15-04-05"
-defaults:
-  postgres:
-    host: "db.example.com"
-    port: "5432"
-    method: "plain"
-  mongodb:
-    host: "mongo.example.com"
-    port: "27017"
+  directory: "/tmp/backups"
+  compression: true
+  timestamp_fmt: "2006-01-02_15-04-05"
+  timeout: 30m
+postgres:
+  host: "db.example.com"
+  port: "5432"
+  format: "plain"
+mongodb:
+  host: "mongo.example.com"
+  port: "27017"
 `
 	// Write it to a temp file
 	tmp, err := os.CreateTemp("", "cfg-*.yaml")
@@ -33,8 +36,154 @@ defaults:
 	tmp.Close()
 	// Load it
 	var cfg Config
-	err = cfg.LoadConfig(tmp.Name())
+	err = cfg.Load(tmp.Name())
 	if err != nil {
-		t.Fatalf("LoadConfig returned error: %v", err)
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if cfg.Backup.Timeout != 30*time.Minute {
+		t.Errorf("Backup.Timeout = %v, want %v", cfg.Backup.Timeout, 30*time.Minute)
+	}
+}
+
+func TestLoadConfig_ExpandsEnvironmentVariables(t *testing.T) {
+	t.Setenv("BACLI_TEST_VAULT_ADDR", "https://vault.example.com:8200")
+	yaml := `
+vault:
+  address: "${BACLI_TEST_VAULT_ADDR}"
+backup:
+  directory: "${BACLI_TEST_UNSET_DIR:-/var/backups}"
+postgres:
+  host: "${BACLI_TEST_UNSET_HOST}"
+`
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(yaml), 0o644); err != nil {
+		t.Fatalf("failed to write YAML: %v", err)
+	}
+
+	var cfg Config
+	if err := cfg.Load(path); err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if got, want := cfg.Vault.Address, "https://vault.example.com:8200"; got != want {
+		t.Errorf("Vault.Address = %q, want %q", got, want)
+	}
+	if got, want := cfg.Backup.Directory, "/var/backups"; got != want {
+		t.Errorf("Backup.Directory = %q, want %q", got, want)
+	}
+	if got := cfg.Postgres.Host; got != "" {
+		t.Errorf("Postgres.Host = %q, want empty", got)
+	}
+}
+
+func TestLoadConfig_AppliesProfileOverlay(t *testing.T) {
+	yaml := `
+backup:
+  directory: "/var/backups"
+  timeout: 30m
+postgres:
+  host: "localhost"
+profiles:
+  prod:
+    backup:
+      directory: "/mnt/prod-backups"
+    postgres:
+      host: "pg.prod.lan"
+`
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(yaml), 0o644); err != nil {
+		t.Fatalf("failed to write YAML: %v", err)
+	}
+
+	t.Setenv(ProfileEnv, "prod")
+	var cfg Config
+	if err := cfg.Load(path); err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if got, want := cfg.Backup.Directory, "/mnt/prod-backups"; got != want {
+		t.Errorf("Backup.Directory = %q, want %q", got, want)
+	}
+	if got, want := cfg.Postgres.Host, "pg.prod.lan"; got != want {
+		t.Errorf("Postgres.Host = %q, want %q", got, want)
+	}
+	if got, want := cfg.Backup.Timeout, 30*time.Minute; got != want {
+		t.Errorf("Backup.Timeout = %v, want shared default %v", got, want)
+	}
+
+	t.Setenv(ProfileEnv, "staging")
+	if err := new(Config).Load(path); !errors.Is(err, ErrUnknownProfile) {
+		t.Errorf("Load with unknown profile error = %v, want %v", err, ErrUnknownProfile)
+	}
+}
+
+func TestLoadConfig_ScopesTenant(t *testing.T) {
+	yaml := `
+backup:
+  directory: "/var/backups"
+retention:
+  keep: 7
+storage:
+  type: s3
+  s3:
+    bucket: "backups"
+  replicas:
+    - name: "dr"
+      type: s3
+      s3:
+        bucket: "backups-dr"
+        prefix: "bacli"
+postgres:
+  host: "localhost"
+  instances:
+    - name: "shared"
+      database: "shared"
+tenants:
+  payments:
+    retention:
+      keep: 14
+    postgres:
+      instances:
+        - name: "ledger"
+          database: "ledger"
+`
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(yaml), 0o644); err != nil {
+		t.Fatalf("failed to write YAML: %v", err)
+	}
+
+	t.Setenv(TenantEnv, "payments")
+	var cfg Config
+	if err := cfg.Load(path); err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if got, want := cfg.Tenant, "payments"; got != want {
+		t.Errorf("Tenant = %q, want %q", got, want)
+	}
+	if got := cfg.Postgres.Instances; len(got) != 1 || got[0].Database != "ledger" {
+		t.Errorf("Postgres.Instances = %+v, want only the tenant's ledger", got)
+	}
+	if got, want := cfg.Postgres.Host, "localhost"; got != want {
+		t.Errorf("Postgres.Host = %q, want shared default %q", got, want)
+	}
+	if got, want := cfg.Retention.Keep, 14; got != want {
+		t.Errorf("Retention.Keep = %d, want %d", got, want)
+	}
+	if got, want := cfg.Backup.Directory, filepath.Join("/var/backups", "tenants", "payments"); got != want {
+		t.Errorf("Backup.Directory = %q, want %q", got, want)
+	}
+	if got, want := cfg.Storage.S3.Prefix, "tenants/payments"; got != want {
+		t.Errorf("Storage.S3.Prefix = %q, want %q", got, want)
+	}
+	if got, want := cfg.Storage.Replicas[0].S3.Prefix, "bacli/tenants/payments"; got != want {
+		t.Errorf("Storage.Replicas[0].S3.Prefix = %q, want %q", got, want)
+	}
+	if got, want := cfg.State.Prefix, "bacli/state/tenants/payments"; got != want {
+		t.Errorf("State.Prefix = %q, want %q", got, want)
+	}
+
+	for _, tenant := range []string{"billing", "../payments"} {
+		t.Setenv(TenantEnv, tenant)
+		if err := new(Config).Load(path); !errors.Is(err, ErrUnknownTenant) {
+			t.Errorf("Load with tenant %q error = %v, want %v", tenant, err, ErrUnknownTenant)
+		}
 	}
 }
@@ -0,0 +1,81 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// DefaultNameTemplate is the artifact name used when backup.name_template is
+// empty, e.g. "2025-04-24_21-00-00-mydb".
+const DefaultNameTemplate = "{{.Timestamp}}-{{.Database}}"
+
+// ArtifactName holds the fields available to backup.name_template. The
+// engine appends its own extension (".dump", ".sql", ...) to the result.
+type ArtifactName struct {
+	Engine    string
+	Database  string
+	Host      string
+	Method    string
+	Timestamp string    // Time formatted with backup.timestamp_fmt
+	Time      time.Time // for custom layouts, e.g. {{.Time.Format "20060102"}}
+}
+
+// Render executes tmpl (DefaultNameTemplate when empty) for n. Artifacts
+// always live in the directory of their database (see ArtifactDir), where
+// the catalog, history and retention look for them, so the name must not
+// contain path separators.
+func (n ArtifactName) Render(tmpl string) (string, error) {
+	if tmpl == "" {
+		tmpl = DefaultNameTemplate
+	}
+	return renderFileName("name", tmpl, n)
+}
+
+// DefaultDirTemplate names the directory of a database's artifacts when
+// backup.dir_template is empty: the database name.
+const DefaultDirTemplate = "{{.Database}}"
+
+// ArtifactDir holds the fields available to backup.dir_template, which
+// names the directory under <backup.directory>/<engine> holding the
+// artifacts, metadata and history of one database of one instance.
+type ArtifactDir struct {
+	Engine   string
+	Instance string // the instance name
+	Host     string
+	Database string
+}
+
+// Render executes tmpl (DefaultDirTemplate when empty) for d. The result is
+// a single directory name.
+func (d ArtifactDir) Render(tmpl string) (string, error) {
+	if tmpl == "" {
+		tmpl = DefaultDirTemplate
+	}
+	return renderFileName("directory", tmpl, d)
+}
+
+// renderFileName executes the kind template tmpl for data and checks that
+// the result can be used as a file or directory name.
+func renderFileName(kind, tmpl string, data any) (string, error) {
+	t, err := template.New(kind).Option("missingkey=error").Parse(tmpl)
+	if err != nil {
+		return "", fmt.Errorf("parse %s template: %w", kind, err)
+	}
+	var name strings.Builder
+	if err := t.Execute(&name, data); err != nil {
+		return "", fmt.Errorf("render %s template: %w", kind, err)
+	}
+	switch result := name.String(); {
+	case strings.TrimSpace(result) == "":
+		return "", fmt.Errorf("%s template %q renders an empty name", kind, tmpl)
+	case strings.ContainsAny(result, `/\`):
+		return "", fmt.Errorf("%s template %q renders %q, which is not a file name", kind, tmpl, result)
+	case strings.HasPrefix(result, "."):
+		// hidden files are temporary or bookkeeping files
+		return "", fmt.Errorf("%s template %q renders hidden file name %q", kind, tmpl, result)
+	default:
+		return result, nil
+	}
+}
@@ -0,0 +1,30 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestArtifactNameRender(t *testing.T) {
+	n := ArtifactName{
+		Engine: "postgres", Database: "shop", Host: "db1", Method: "custom",
+		Timestamp: "2025-04-24_21-00-00", Time: time.Date(2025, 4, 24, 21, 0, 0, 0, time.UTC),
+	}
+	tests := []struct {
+		tmpl, want string
+		wantErr    bool
+	}{
+		{"", "2025-04-24_21-00-00-shop", false},
+		{`{{.Host}}_{{.Database}}_{{.Time.Format "20060102"}}_{{.Method}}`, "db1_shop_20250424_custom", false},
+		{"{{.Engine}}/{{.Database}}", "", true},
+		{".{{.Database}}", "", true},
+		{"{{.Nope}}", "", true},
+		{"{{.Database", "", true},
+	}
+	for _, tt := range tests {
+		got, err := n.Render(tt.tmpl)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("Render(%q) = %q, %v; want %q, error %v", tt.tmpl, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package config
+
+import "path/filepath"
+
+// localDirs returns the local directories set in the configuration.
+func (c *Config) localDirs() []*string {
+	dirs := []*string{&c.Backup.Directory, &c.State.Path, &c.Storage.Local.Path}
+	for i := range c.Storage.Replicas {
+		dirs = append(dirs, &c.Storage.Replicas[i].Local.Path)
+	}
+	return dirs
+}
+
+// volumeRoot turns a bare Windows volume ("D:") into its root ("D:\"):
+// filepath.Join("D:", "postgres") is "D:postgres", a path relative to the
+// current directory of drive D. Other paths are returned as is.
+func volumeRoot(dir string) string {
+	if dir != "" && filepath.VolumeName(dir) == dir {
+		return dir + string(filepath.Separator)
+	}
+	return dir
+}
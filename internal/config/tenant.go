@@ -0,0 +1,131 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// TenantEnv selects a tenant when no tenant was chosen explicitly.
+const TenantEnv = "BACLI_TENANT"
+
+// TenantsDirname is the directory under the shared backup directory holding
+// one backup directory per tenant.
+const TenantsDirname = "tenants"
+
+// defaultStatePrefix mirrors the default of state.NewVault.
+const defaultStatePrefix = "bacli/state"
+
+// engineKeys are the top-level keys of the per-engine groups.
+var engineKeys = []string{"postgres", "mongodb", "mysql", "redis", "clickhouse", "cassandra", "influxdb", "couchdb"}
+
+// tenantName restricts tenant names to what is safe in paths and keys.
+var tenantName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// selectedTenant is the tenant chosen on the command line (see UseTenant).
+var selectedTenant string
+
+// UseTenant selects the tenant every subsequent Load call is scoped to.
+// An empty name falls back to the BACLI_TENANT environment variable.
+func UseTenant(name string) {
+	selectedTenant = name
+}
+
+// scopeTenant returns the settings of v scoped to tenant:
+//
+//   - the instances of every engine group come from tenants.<name> only;
+//     the shared groups still provide engine defaults and Vault prefixes
+//   - any other setting under tenants.<name> (retention, notify, vault, ...)
+//     is merged over the shared one
+//   - unless the tenant sets them, the backup and work directories, the
+//     storage locations (primary and replicas) and the state path or Vault
+//     prefix get a tenants/<name> suffix, so that tenants never see each other's
+//     artifacts or locks
+func scopeTenant(v *viper.Viper, tenant string) (*viper.Viper, error) {
+	if !tenantName.MatchString(tenant) {
+		return nil, fmt.Errorf("%w: %q (use lowercase letters, digits, - and _)", ErrUnknownTenant, tenant)
+	}
+	overlay := v.Sub("tenants." + tenant)
+	if overlay == nil {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownTenant, tenant)
+	}
+
+	shared := v.AllSettings()
+	for _, engine := range engineKeys {
+		if group, ok := shared[engine].(map[string]any); ok {
+			delete(group, "instances")
+		}
+	}
+	scoped := viper.New()
+	scoped.SetConfigType("yaml")
+	scoped.AutomaticEnv()
+	if err := scoped.MergeConfigMap(shared); err != nil {
+		return nil, fmt.Errorf("%w: scope tenant %s: %v", ErrLoadConfig, tenant, err)
+	}
+	if err := scoped.MergeConfigMap(overlay.AllSettings()); err != nil {
+		return nil, fmt.Errorf("%w: merge tenant %s: %v", ErrLoadConfig, tenant, err)
+	}
+
+	sub := path.Join(TenantsDirname, tenant)
+	if !overlay.IsSet("backup.directory") {
+		scoped.Set("backup.directory", filepath.Join(scoped.GetString("backup.directory"), TenantsDirname, tenant))
+	}
+	if settings, ok := scoped.Get("storage").(map[string]any); ok && !overlay.IsSet("storage") {
+		scopeStorage(settings, sub)
+		if replicas, ok := settings["replicas"].([]any); ok {
+			for _, replica := range replicas {
+				if replica, ok := replica.(map[string]any); ok {
+					scopeStorage(replica, sub)
+				}
+			}
+		}
+		scoped.Set("storage", settings)
+	}
+	if settings, ok := scoped.Get("repository").(map[string]any); ok && !overlay.IsSet("repository") {
+		scopeStorage(settings, sub)
+		scoped.Set("repository", settings)
+	}
+	if dir := scoped.GetString("backup.work_dir"); dir != "" && !overlay.IsSet("backup.work_dir") {
+		scoped.Set("backup.work_dir", filepath.Join(dir, TenantsDirname, tenant))
+	}
+	if dir := scoped.GetString("state.path"); dir != "" && !overlay.IsSet("state.path") {
+		scoped.Set("state.path", filepath.Join(dir, filepath.FromSlash(sub)))
+	}
+	if !overlay.IsSet("state.prefix") {
+		prefix := scoped.GetString("state.prefix")
+		if prefix == "" {
+			prefix = defaultStatePrefix
+		}
+		scoped.Set("state.prefix", path.Join(prefix, sub))
+	}
+	scoped.Set("tenant", tenant)
+	return scoped, nil
+}
+
+// scopeStorage moves the backend described by settings (storage, one of
+// storage.replicas, or repository) down to its sub directory.
+func scopeStorage(settings map[string]any, sub string) {
+	if local, ok := settings["local"].(map[string]any); ok {
+		if dir, _ := local["path"].(string); dir != "" {
+			local["path"] = filepath.Join(dir, filepath.FromSlash(sub))
+		}
+	}
+	if s3, ok := settings["s3"].(map[string]any); ok {
+		if bucket, _ := s3["bucket"].(string); bucket != "" {
+			prefix, _ := s3["prefix"].(string)
+			s3["prefix"] = path.Join(prefix, sub)
+		}
+	}
+	if rclone, ok := settings["rclone"].(map[string]any); ok {
+		if remote, _ := rclone["remote"].(string); remote != "" {
+			if !strings.HasSuffix(remote, ":") {
+				remote = strings.TrimSuffix(remote, "/") + "/" // root of the remote otherwise
+			}
+			rclone["remote"] = remote + sub
+		}
+	}
+}
@@ -0,0 +1,355 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Validate checks the loaded configuration for settings that would make a
+// run misbehave.
+//
+// Every instance writes its artifacts to
+// <backup.directory>/<engine>/<backup.dir_template>, by default the database
+// name, so two instances of the same engine rendering the same directory
+// (e.g. the same database on two hosts) would overwrite or interleave each
+// other's artifacts and metadata. Such collisions are rejected; putting
+// {{.Instance}} or {{.Host}} in the template tells them apart. Instances
+// covering AllDatabases are resolved at run time and skip databases already
+// claimed by another instance. The artifact name and directory templates
+// must render plain file names, and an instance may set only one password
+// source. Storage replicas need a primary backend and distinct names, and
+// backup windows and blackout dates must parse. A Vault client certificate
+// needs its key. The chunk repository stores chunks in clear, so it cannot be
+// combined with backup.encryption. MongoDB read preferences must name a mode,
+// URIs a mongodb scheme, and TLS settings need TLS enabled; oplog dumps
+// cover the whole deployment, so they cannot be repeated for every database
+// of a "*" instance. Exec drivers are available to the engines that can
+// stream their artifacts. Restore dependencies must name configured
+// instances and must not form a cycle.
+func (c *Config) Validate() error {
+	var errs []error
+	sample := ArtifactName{
+		Engine: "postgres", Database: "db", Host: "localhost", Method: "custom",
+		Timestamp: time.Now().Format(c.Backup.TimestampFmt), Time: time.Now(),
+	}
+	if _, err := sample.Render(c.Backup.NameTemplate); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := (ArtifactDir{Engine: "postgres", Instance: "main", Host: "localhost", Database: "db"}).
+		Render(c.Backup.DirTemplate); err != nil {
+		// every instance would fail the same way
+		errs = append(errs, err)
+		return fmt.Errorf("%w: %w", ErrValidateConfig, errors.Join(errs...))
+	}
+	groups := []struct {
+		engine string
+		group  DBGroupConfig
+	}{
+		{"postgres", c.Postgres},
+		{"mongodb", c.MongoDB},
+		{"mysql", c.MySQL},
+		{"redis", c.Redis},
+		{"clickhouse", c.ClickHouse},
+		{"cassandra", c.Cassandra},
+		{"influxdb", c.InfluxDB},
+		{"couchdb", c.CouchDB},
+	}
+	for _, g := range groups {
+		if _, err := ParseBackupWindow(g.group.Window, g.group.Blackout); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", g.engine, err))
+		}
+		owners := make(map[string][]string)
+		var order []string
+		for i, instance := range g.group.Instances {
+			label := instanceLabel(instance, i)
+			if err := checkPassword(instance); err != nil {
+				errs = append(errs, fmt.Errorf("%s instance %s: %w", g.engine, label, err))
+			}
+			if _, err := ParseBackupWindow(instance.Window, instance.Blackout); err != nil {
+				errs = append(errs, fmt.Errorf("%s instance %s: %w", g.engine, label, err))
+			}
+			if err := checkExec(g.engine, instance.Exec); err != nil {
+				errs = append(errs, fmt.Errorf("%s instance %s: %w", g.engine, label, err))
+			}
+			if url := instance.Webhook.URL; url != "" && !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+				errs = append(errs, fmt.Errorf("%s instance %s: webhook.url must start with http:// or https://", g.engine, label))
+			}
+			fields := ArtifactDir{Engine: g.engine, Instance: instance.Name, Host: instance.Host}
+			if fields.Host == "" {
+				fields.Host = g.group.EngineDefaults.Host
+			}
+			if instance.Database == AllDatabases {
+				// every database found needs a directory of its own
+				fields.Database = "a"
+				a, errA := fields.Render(c.Backup.DirTemplate)
+				fields.Database = "b"
+				b, errB := fields.Render(c.Backup.DirTemplate)
+				if err := errors.Join(errA, errB); err != nil {
+					errs = append(errs, fmt.Errorf("%s instance %s: %w", g.engine, label, err))
+				} else if a == b {
+					errs = append(errs, fmt.Errorf("%s instance %s covers every database, but dir template %q renders %q for all of them",
+						g.engine, label, c.Backup.DirTemplate, a))
+				}
+				continue
+			}
+			fields.Database = instance.Database
+			dir, err := fields.Render(c.Backup.DirTemplate)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s instance %s: %w", g.engine, label, err))
+				continue
+			}
+			if _, ok := owners[dir]; !ok {
+				order = append(order, dir)
+			}
+			owners[dir] = append(owners[dir], label)
+		}
+		for _, dir := range order {
+			if len(owners[dir]) < 2 {
+				continue
+			}
+			errs = append(errs, fmt.Errorf(
+				"%s instances %s would write to the same artifact path %s/%s/%s (set backup.dir_template, e.g. %q)",
+				g.engine, strings.Join(owners[dir], ", "), c.Backup.Directory, g.engine, dir,
+				"{{.Instance}}-{{.Database}}",
+			))
+		}
+	}
+	switch c.Backup.OutsideWindow {
+	case "", OutsideWindowSkip, OutsideWindowWait:
+	default:
+		errs = append(errs, fmt.Errorf("backup.outside_window %q: use %q or %q",
+			c.Backup.OutsideWindow, OutsideWindowSkip, OutsideWindowWait))
+	}
+	if (c.Vault.TLS.ClientCert == "") != (c.Vault.TLS.ClientKey == "") {
+		errs = append(errs, errors.New("vault.tls: client_cert and client_key must be set together"))
+	}
+	if c.Vault.Credentials.Prefetch < 0 {
+		errs = append(errs, errors.New("vault.credentials.prefetch must not be negative"))
+	}
+	errs = append(errs, c.Storage.checkReplicas()...)
+	errs = append(errs, c.MongoDB.checkMongo()...)
+	errs = append(errs, c.Postgres.checkPostgres()...)
+	for _, g := range groups {
+		errs = append(errs, g.group.checkTransforms(g.engine)...)
+		errs = append(errs, g.group.checkCompression(g.engine)...)
+	}
+	switch c.InfluxDB.Version {
+	case "", "1", "2":
+	default:
+		errs = append(errs, fmt.Errorf("influxdb.version %q: use \"1\", \"2\" or leave empty to detect it",
+			c.InfluxDB.Version))
+	}
+	engines := make([]string, len(groups))
+	for i, g := range groups {
+		engines[i] = g.engine
+	}
+	errs = append(errs, c.checkDependencies(engines)...)
+	for _, engine := range slices.Sorted(maps.Keys(c.Backup.Pipeline.Weights)) {
+		weight := c.Backup.Pipeline.Weights[engine]
+		if !slices.Contains(engines, engine) {
+			errs = append(errs, fmt.Errorf("backup.pipeline.weights: unknown engine %q", engine))
+		} else if weight <= 0 {
+			errs = append(errs, fmt.Errorf("backup.pipeline.weights.%s must be positive", engine))
+		}
+	}
+	switch signing := c.Backup.Signing; signing.Type {
+	case "", "gpg":
+	case "minisign":
+		if signing.Key == "" && signing.PublicKey == "" {
+			errs = append(errs, errors.New("backup.signing: minisign needs key, public_key or both"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("backup.signing.type %q: use minisign or gpg", signing.Type))
+	}
+	if c.Repository.Type != "" && c.Backup.Encryption.Type != "" {
+		errs = append(errs, errors.New("repository cannot be combined with backup.encryption"))
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%w: %w", ErrValidateConfig, errors.Join(errs...))
+	}
+	return nil
+}
+
+// checkReplicas rejects replicas without a primary backend to replicate
+// from, without a name or type, or sharing a name.
+func (s StorageConfig) checkReplicas() []error {
+	if len(s.Replicas) == 0 {
+		return nil
+	}
+	var errs []error
+	if s.Type == "" {
+		errs = append(errs, errors.New("storage.replicas needs storage.type"))
+	}
+	seen := make(map[string]bool)
+	for i, replica := range s.Replicas {
+		switch {
+		case replica.Name == "":
+			errs = append(errs, fmt.Errorf("storage replica #%d has no name", i+1))
+		case seen[replica.Name]:
+			errs = append(errs, fmt.Errorf("storage replica name %q is used twice", replica.Name))
+		}
+		seen[replica.Name] = true
+		if replica.Type == "" {
+			errs = append(errs, fmt.Errorf("storage replica #%d has no type", i+1))
+		}
+	}
+	return errs
+}
+
+// mongoReadPreferences are the read preference modes of MongoDB.
+var mongoReadPreferences = []string{"primary", "primaryPreferred", "secondary", "secondaryPreferred", "nearest"}
+
+// checkMongo checks the replica set settings of the MongoDB group.
+func (g DBGroupConfig) checkMongo() []error {
+	var errs []error
+	checkPreference := func(where, preference string) {
+		// a JSON document carries tag sets, mongodump checks it
+		if preference == "" || strings.HasPrefix(preference, "{") || slices.Contains(mongoReadPreferences, preference) {
+			return
+		}
+		errs = append(errs, fmt.Errorf("%s: read_preference %q: use one of %s",
+			where, preference, strings.Join(mongoReadPreferences, ", ")))
+	}
+	checkPreference("mongodb", g.ReadPreference)
+	for i, instance := range g.Instances {
+		where := "mongodb instance " + instanceLabel(instance, i)
+		checkPreference(where, instance.ReadPreference)
+		if instance.URI != "" && !strings.HasPrefix(instance.URI, "mongodb://") &&
+			!strings.HasPrefix(instance.URI, "mongodb+srv://") {
+			errs = append(errs, fmt.Errorf("%s: uri must start with mongodb:// or mongodb+srv://", where))
+		}
+		if tls := instance.TLS; !tls.Enabled && (tls.CACert != "" || tls.ClientCert != "" || tls.SkipVerify) {
+			errs = append(errs, fmt.Errorf("%s: tls settings are ignored without tls.enabled: true", where))
+		}
+		if err := instance.Collections.check(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: collections: %w", where, err))
+		}
+		if (instance.Oplog || g.Oplog) && !instance.Collections.Empty() {
+			errs = append(errs, fmt.Errorf("%s: oplog dumps cover every collection, collections cannot be filtered", where))
+		}
+		if (instance.Oplog || g.Oplog) && instance.Database == AllDatabases {
+			errs = append(errs, fmt.Errorf("%s: oplog dumps cover every database, name one database to restore instead of %q",
+				where, AllDatabases))
+		}
+	}
+	return errs
+}
+
+// checkPostgres checks the object selection of the PostgreSQL group: the
+// native method dumps whole databases.
+func (g DBGroupConfig) checkPostgres() []error {
+	var errs []error
+	for i, instance := range g.Instances {
+		where := "postgres instance " + instanceLabel(instance, i)
+		switch instance.Content {
+		case "", ContentSchemaOnly, ContentDataOnly:
+		default:
+			errs = append(errs, fmt.Errorf("%s: content %q: use %q or %q",
+				where, instance.Content, ContentSchemaOnly, ContentDataOnly))
+		}
+		method := instance.Method
+		if method == "" {
+			method = g.EngineDefaults.Method
+		}
+		partial := len(instance.Schemas) > 0 || len(instance.ExcludeSchemas) > 0 ||
+			len(instance.Tables) > 0 || len(instance.ExcludeTables) > 0 || instance.Content != ""
+		if method == "native" && partial {
+			errs = append(errs, fmt.Errorf("%s: schemas, tables and content need a pg_dump format, not native", where))
+		}
+		switch instance.Flavor {
+		case "":
+		case FlavorTimescaleDB, FlavorCitus:
+			if method == "native" {
+				errs = append(errs, fmt.Errorf("%s: flavor %q needs a pg_dump format, not native", where, instance.Flavor))
+			}
+		default:
+			errs = append(errs, fmt.Errorf("%s: flavor %q: use %q or %q",
+				where, instance.Flavor, FlavorTimescaleDB, FlavorCitus))
+		}
+	}
+	return errs
+}
+
+// checkTransforms checks the restore transforms of the instances of
+// engine: only PostgreSQL and MySQL write plain SQL dumps, and masks only
+// apply to PostgreSQL's COPY data.
+func (g DBGroupConfig) checkTransforms(engine string) []error {
+	var errs []error
+	for i, instance := range g.Instances {
+		transform := instance.Transform
+		if !transform.Enabled() {
+			continue
+		}
+		where := engine + " instance " + instanceLabel(instance, i)
+		if engine != "postgres" && engine != "mysql" {
+			errs = append(errs, fmt.Errorf("%s: transform needs plain SQL dumps (postgres or mysql)", where))
+			continue
+		}
+		if len(transform.Mask) > 0 && engine != "postgres" {
+			errs = append(errs, fmt.Errorf("%s: transform.mask only applies to postgres dumps; use filters", where))
+		}
+		for j, rule := range transform.Mask {
+			if rule.Table == "" || rule.Column == "" {
+				errs = append(errs, fmt.Errorf("%s: transform.mask[%d] needs table and column", where, j))
+			}
+		}
+		for j, filter := range transform.Filters {
+			if strings.TrimSpace(filter) == "" {
+				errs = append(errs, fmt.Errorf("%s: transform.filters[%d] is empty", where, j))
+			}
+		}
+	}
+	return errs
+}
+
+// execEngines are the engines whose client tools can run through an exec
+// driver.
+var execEngines = []string{"postgres", "mongodb", "mysql"}
+
+// checkExec checks the exec driver of an instance of engine.
+func checkExec(engine string, exec ExecConfig) error {
+	switch exec.Type {
+	case "", ExecLocal:
+		return nil
+	case ExecKubernetes:
+		if (exec.Pod == "") == (exec.Selector == "") {
+			return errors.New("exec: set one of pod and selector")
+		}
+	case ExecDocker:
+		if exec.Container == "" {
+			return errors.New("exec: docker needs a container")
+		}
+	default:
+		return fmt.Errorf("exec: unknown type %q (use %s, %s or %s)", exec.Type, ExecLocal, ExecKubernetes, ExecDocker)
+	}
+	if !slices.Contains(execEngines, engine) {
+		return fmt.Errorf("exec: %s tools cannot run through an exec driver", engine)
+	}
+	return nil
+}
+
+// checkPassword rejects instances with more than one password source.
+func checkPassword(instance DBInstance) error {
+	sources := 0
+	for _, source := range []string{instance.Password, instance.PasswordFile, instance.PasswordEnv} {
+		if source != "" {
+			sources++
+		}
+	}
+	if sources > 1 {
+		return errors.New("set only one of password, password_file and password_env")
+	}
+	return nil
+}
+
+// instanceLabel names an instance in validation errors.
+func instanceLabel(instance DBInstance, index int) string {
+	if instance.Name != "" {
+		return fmt.Sprintf("%q", instance.Name)
+	}
+	return fmt.Sprintf("#%d (%s)", index+1, instance.Host)
+}
@@ -0,0 +1,393 @@
+package config
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfig_RejectsArtifactCollisions(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"config.yaml": `
+backup:
+  directory: "/backups"
+postgres:
+  instances:
+    - name: "app-primary"
+      host: "pg1.lan"
+      database: "app"
+    - name: "app-legacy"
+      host: "pg2.lan"
+      database: "app"
+mongodb:
+  instances:
+    - name: "app"
+      database: "app"
+`,
+	})
+
+	var cfg Config
+	err := cfg.Load(filepath.Join(dir, "config.yaml"))
+	if !errors.Is(err, ErrValidateConfig) {
+		t.Fatalf("expected ErrValidateConfig, got %v", err)
+	}
+	if !strings.Contains(err.Error(), `"app-primary", "app-legacy"`) {
+		t.Errorf("error does not name the colliding instances: %v", err)
+	}
+	if strings.Contains(err.Error(), "mongodb") {
+		t.Errorf("different engines must not collide: %v", err)
+	}
+}
+
+func TestLoadConfig_DirTemplateSeparatesInstances(t *testing.T) {
+	instances := `
+postgres:
+  instances:
+    - name: "app-primary"
+      host: "pg1.lan"
+      database: "app"
+    - name: "app-legacy"
+      host: "pg2.lan"
+      database: "app"
+    - name: "reporting"
+      host: "pg3.lan"
+      database: "*"
+`
+	dir := writeFiles(t, map[string]string{
+		"config.yaml":   "backup:\n  dir_template: \"{{.Instance}}-{{.Database}}\"\n" + instances,
+		"wildcard.yaml": "backup:\n  dir_template: \"{{.Host}}\"\n" + instances,
+	})
+
+	var cfg Config
+	if err := cfg.Load(filepath.Join(dir, "config.yaml")); err != nil {
+		t.Fatalf("instances in separate directories rejected: %v", err)
+	}
+	err := new(Config).Load(filepath.Join(dir, "wildcard.yaml"))
+	if !errors.Is(err, ErrValidateConfig) || !strings.Contains(err.Error(), `"reporting" covers every database`) {
+		t.Errorf("expected the wildcard instance to be rejected, got %v", err)
+	}
+}
+
+func TestLoadConfig_RejectsSeveralPasswordSources(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"config.yaml": `
+backup:
+  directory: "/backups"
+mysql:
+  instances:
+    - name: "legacy"
+      database: "legacy"
+      username: "backup"
+      password: "secret"
+      password_env: "LEGACY_PASSWORD"
+`,
+	})
+
+	var cfg Config
+	err := cfg.Load(filepath.Join(dir, "config.yaml"))
+	if !errors.Is(err, ErrValidateConfig) {
+		t.Fatalf("expected ErrValidateConfig, got %v", err)
+	}
+	if !strings.Contains(err.Error(), `"legacy"`) {
+		t.Errorf("error does not name the instance: %v", err)
+	}
+}
+
+func TestLoadConfig_VaultTLS(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"config.yaml": `
+vault:
+  address: "https://vault.example.com:8200"
+  namespace: "ops/backups"
+  tls:
+    ca_cert: "/etc/bacli/vault-ca.pem"
+    client_cert: "/etc/bacli/client.pem"
+    client_key: "/etc/bacli/client-key.pem"
+`,
+		"nokey.yaml": `
+vault:
+  address: "https://vault.example.com:8200"
+  tls:
+    client_cert: "/etc/bacli/client.pem"
+`,
+	})
+
+	var cfg Config
+	if err := cfg.Load(filepath.Join(dir, "config.yaml")); err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if cfg.Vault.Namespace != "ops/backups" || cfg.Vault.TLS.ClientKey != "/etc/bacli/client-key.pem" {
+		t.Errorf("Vault = %+v", cfg.Vault)
+	}
+	var nokey Config
+	if err := nokey.Load(filepath.Join(dir, "nokey.yaml")); !errors.Is(err, ErrValidateConfig) {
+		t.Errorf("client_cert without client_key: err = %v, want ErrValidateConfig", err)
+	}
+}
+
+func TestLoadConfig_MongoReplicaSet(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"config.yaml": `
+mongodb:
+  read_preference: "secondary"
+  instances:
+    - name: "rs"
+      uri: "mongodb://m1:27017,m2:27017/?replicaSet=rs0"
+      database: "app"
+      oplog: true
+    - name: "atlas"
+      uri: "mongodb+srv://cluster0.example.mongodb.net/"
+      database: "orders"
+      tls:
+        enabled: true
+        client_cert: "/etc/bacli/atlas.pem"
+`,
+		"invalid.yaml": `
+mongodb:
+  instances:
+    - name: "rs"
+      uri: "m1:27017"
+      database: "*"
+      oplog: true
+      read_preference: "secondaries"
+      collections:
+        exclude: ["audit_["]
+      tls:
+        ca_cert: "/etc/bacli/ca.pem"
+`,
+	})
+
+	var cfg Config
+	if err := cfg.Load(filepath.Join(dir, "config.yaml")); err != nil {
+		t.Fatalf("replica set instance rejected: %v", err)
+	}
+	if got := cfg.MongoDB.Instances[0]; !got.Oplog || got.URI == "" || cfg.MongoDB.ReadPreference != "secondary" {
+		t.Errorf("replica set settings not loaded: %+v", got)
+	}
+	if got := cfg.MongoDB.Instances[1].TLS; !got.Enabled || got.ClientCert != "/etc/bacli/atlas.pem" {
+		t.Errorf("tls settings not loaded: %+v", got)
+	}
+
+	err := (&Config{}).Load(filepath.Join(dir, "invalid.yaml"))
+	if !errors.Is(err, ErrValidateConfig) {
+		t.Fatalf("expected ErrValidateConfig, got %v", err)
+	}
+	for _, want := range []string{"read_preference", "uri", "oplog", "collections cannot be filtered", "syntax error in pattern", "tls.enabled"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error does not mention %s: %v", want, err)
+		}
+	}
+}
+
+func TestLoadConfig_Exec(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"config.yaml": `
+postgres:
+  instances:
+    - name: "billing"
+      host: "localhost"
+      database: "billing"
+      exec:
+        type: "kubernetes"
+        namespace: "billing"
+        selector: "app=billing-db"
+`,
+		"invalid.yaml": `
+postgres:
+  instances:
+    - name: "billing"
+      database: "billing"
+      exec:
+        type: "kubernetes"
+mysql:
+  instances:
+    - name: "shop"
+      database: "shop"
+      exec:
+        type: "docker"
+redis:
+  instances:
+    - name: "cache"
+      exec:
+        type: "kubernetes"
+        pod: "redis-0"
+`,
+	})
+
+	var cfg Config
+	if err := cfg.Load(filepath.Join(dir, "config.yaml")); err != nil {
+		t.Fatalf("kubernetes exec rejected: %v", err)
+	}
+	if got := cfg.Postgres.Instances[0].Exec; !got.Remote() || got.Selector != "app=billing-db" {
+		t.Errorf("exec settings not loaded: %+v", got)
+	}
+
+	err := (&Config{}).Load(filepath.Join(dir, "invalid.yaml"))
+	if !errors.Is(err, ErrValidateConfig) {
+		t.Fatalf("expected ErrValidateConfig, got %v", err)
+	}
+	for _, want := range []string{"pod and selector", "needs a container", "redis tools"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error does not mention %s: %v", want, err)
+		}
+	}
+}
+
+func TestLoadConfig_DependsOn(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"config.yaml": `
+postgres:
+  instances:
+    - name: "auth"
+      database: "auth"
+    - name: "app"
+      database: "app"
+      depends_on: ["auth", "mongodb:events"]
+mongodb:
+  instances:
+    - name: "events"
+      database: "events"
+`,
+		"invalid.yaml": `
+postgres:
+  instances:
+    - name: "a"
+      database: "a"
+      depends_on: ["b"]
+    - name: "b"
+      database: "b"
+      depends_on: ["a"]
+    - name: "c"
+      database: "c"
+      depends_on: ["mysql:missing"]
+`,
+	})
+
+	var cfg Config
+	if err := cfg.Load(filepath.Join(dir, "config.yaml")); err != nil {
+		t.Fatalf("depends_on rejected: %v", err)
+	}
+	deps, err := cfg.Dependencies("postgres", "app")
+	if err != nil || len(deps) != 2 || deps[1] != (InstanceRef{Engine: "mongodb", Name: "events"}) {
+		t.Errorf("Dependencies = %v, %v", deps, err)
+	}
+
+	err = (&Config{}).Load(filepath.Join(dir, "invalid.yaml"))
+	if !errors.Is(err, ErrValidateConfig) {
+		t.Fatalf("expected ErrValidateConfig, got %v", err)
+	}
+	for _, want := range []string{"postgres:a -> postgres:b -> postgres:a", "no instance mysql:missing"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error does not mention %s: %v", want, err)
+		}
+	}
+}
+
+func TestLoadConfig_PostgresObjects(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"config.yaml": `
+postgres:
+  instances:
+    - name: "crm"
+      database: "crm"
+      schemas: ["public"]
+      exclude_tables: ["public.audit_*"]
+      content: "schema-only"
+`,
+		"invalid.yaml": `
+postgres:
+  instances:
+    - name: "crm"
+      database: "crm"
+      format: "native"
+      tables: ["public.users"]
+      content: "schema"
+`,
+	})
+
+	var cfg Config
+	if err := cfg.Load(filepath.Join(dir, "config.yaml")); err != nil {
+		t.Fatalf("partial dump rejected: %v", err)
+	}
+	if got := cfg.Postgres.Instances[0]; got.Content != ContentSchemaOnly || len(got.ExcludeTables) != 1 {
+		t.Errorf("partial dump settings not loaded: %+v", got)
+	}
+
+	err := (&Config{}).Load(filepath.Join(dir, "invalid.yaml"))
+	if !errors.Is(err, ErrValidateConfig) {
+		t.Fatalf("expected ErrValidateConfig, got %v", err)
+	}
+	for _, want := range []string{`content "schema"`, "not native"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error does not mention %s: %v", want, err)
+		}
+	}
+}
+
+func TestLoadConfig_Compression(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"config.yaml": `
+backup:
+  compression: true
+postgres:
+  format: "custom"
+  instances:
+    - name: "crm"
+      database: "crm"
+    - name: "billing"
+      database: "billing"
+      compression: "zstd"
+    - name: "reports"
+      database: "reports"
+      format: "plain"
+mongodb:
+  compression: false
+  format: "archive"
+  instances:
+    - name: "events"
+      database: "events"
+      format: "directory"
+      compression: "native"
+`,
+		"invalid.yaml": `
+mongodb:
+  format: "archive-gzip"
+  compression: "zstd"
+  instances:
+    - name: "events"
+      database: "events"
+mysql:
+  instances:
+    - name: "shop"
+      database: "shop"
+      compression: "native"
+`,
+	})
+
+	var cfg Config
+	if err := cfg.Load(filepath.Join(dir, "config.yaml")); err != nil {
+		t.Fatalf("compression modes rejected: %v", err)
+	}
+	for _, want := range []struct{ engine, instance, mode string }{
+		{"postgres", "crm", CompressionNative}, // pg_dump compresses custom dumps
+		{"postgres", "billing", CompressionZstd},
+		{"postgres", "reports", CompressionZstd}, // backup.compression
+		{"mongodb", "events", CompressionNative},
+		{"mongodb", "other", CompressionNone}, // legacy false
+	} {
+		if got := cfg.Compression(want.engine, want.instance); got != want.mode {
+			t.Errorf("Compression(%s, %s) = %q, want %q", want.engine, want.instance, got, want.mode)
+		}
+	}
+
+	err := (&Config{}).Load(filepath.Join(dir, "invalid.yaml"))
+	if !errors.Is(err, ErrValidateConfig) {
+		t.Fatalf("expected ErrValidateConfig, got %v", err)
+	}
+	for _, want := range []string{"gzip output again", "mysql does not compress natively"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error does not mention %s: %v", want, err)
+		}
+	}
+}
@@ -0,0 +1,253 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+)
+
+// reloadDelay coalesces the bursts of events editors produce when saving.
+const reloadDelay = 500 * time.Millisecond
+
+// watched maps the absolute path of every watched configuration to its
+// Watcher; Load serves those paths from the watcher's current snapshot.
+var watched sync.Map
+
+// Change is a setting that differs between two configurations. Old and New
+// are empty for lists and maps, which are reported as a whole, and for
+// secrets.
+type Change struct {
+	Key string `json:"key"`
+	Old string `json:"old,omitempty"`
+	New string `json:"new,omitempty"`
+}
+
+// Watcher keeps a configuration file loaded for a long-running process and
+// reloads it when the file or any file it includes changes, or any file of
+// a configuration directory (see loadDir). A new configuration replaces the
+// current one only when it loads and validates; until then the last good
+// one stays in effect.
+//
+// While a Watcher runs, Load of the same path returns its current snapshot,
+// so operations started after a reload use the new settings and operations
+// already running keep theirs.
+type Watcher struct {
+	path     string
+	current  atomic.Pointer[Config]
+	onReload func(changes []Change, err error)
+}
+
+// NewWatcher loads the configuration at path. onReload is called after each
+// reload attempt with the settings that changed, or with the error that kept
+// the previous configuration in effect.
+func NewWatcher(path string, onReload func(changes []Change, err error)) (*Watcher, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: resolve %s: %v", ErrLoadConfig, path, err)
+	}
+	var cfg Config
+	if err := cfg.load(abs); err != nil {
+		return nil, err
+	}
+	w := &Watcher{path: abs, onReload: onReload}
+	w.current.Store(&cfg)
+	return w, nil
+}
+
+// Current returns the configuration in effect.
+func (w *Watcher) Current() Config {
+	return *w.current.Load()
+}
+
+// Run watches the configuration until ctx is cancelled.
+func (w *Watcher) Run(ctx context.Context) error {
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("watch config: %w", err)
+	}
+	defer fsw.Close()
+	watched.Store(w.path, w)
+	defer watched.Delete(w.path)
+
+	dirs, err := w.watch(fsw, nil)
+	if err != nil {
+		return err
+	}
+	var timer <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-fsw.Events:
+			if !ok {
+				return nil
+			}
+			if event.Has(fsnotify.Chmod) {
+				continue
+			}
+			timer = time.After(reloadDelay)
+		case err, ok := <-fsw.Errors:
+			if !ok {
+				return nil
+			}
+			w.onReload(nil, fmt.Errorf("watch config: %w", err))
+		case <-timer:
+			timer = nil
+			w.reload()
+			// includes may have been added or removed
+			if dirs, err = w.watch(fsw, dirs); err != nil {
+				w.onReload(nil, err)
+			}
+		}
+	}
+}
+
+// watch adds the directories holding the configuration and its includes to
+// fsw. Directories rather than files are watched, since editors replace
+// files on save. It returns the directories now watched.
+func (w *Watcher) watch(fsw *fsnotify.Watcher, watching []string) ([]string, error) {
+	files, err := includedFiles(w.path, nil)
+	if err != nil {
+		// keep the directories already watched; the file may be mid-save
+		files = []string{w.path}
+	}
+	for _, file := range files {
+		dir := filepath.Dir(file)
+		if info, err := os.Stat(file); err == nil && info.IsDir() {
+			dir = file // a configuration directory: watch for new files
+		}
+		if slices.Contains(watching, dir) {
+			continue
+		}
+		if err := fsw.Add(dir); err != nil {
+			return watching, fmt.Errorf("watch %s: %w", dir, err)
+		}
+		watching = append(watching, dir)
+	}
+	return watching, nil
+}
+
+// reload loads the configuration again and swaps it in when it is valid.
+func (w *Watcher) reload() {
+	var next Config
+	if err := next.load(w.path); err != nil {
+		w.onReload(nil, err)
+		return
+	}
+	previous := w.current.Swap(&next)
+	if changes := Diff(*previous, next); len(changes) > 0 {
+		w.onReload(changes, nil)
+	}
+}
+
+// loadWatched fills c from the Watcher of path, if one runs.
+func (c *Config) loadWatched(path string) bool {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	w, ok := watched.Load(abs)
+	if !ok {
+		return false
+	}
+	*c = w.(*Watcher).Current()
+	return true
+}
+
+// includedFiles returns path and every file it includes, recursively; for a
+// configuration directory, the directory and its files.
+func includedFiles(path string, seen []string) ([]string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	if slices.Contains(seen, abs) {
+		return seen, nil
+	}
+	seen = append(seen, abs)
+	if info, err := os.Stat(abs); err == nil && info.IsDir() {
+		files, err := dirFiles(abs)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			if seen, err = includedFiles(file, seen); err != nil {
+				return nil, err
+			}
+		}
+		return seen, nil
+	}
+	data, err := os.ReadFile(abs)
+	if err != nil {
+		return nil, err
+	}
+	v := viper.New()
+	v.SetConfigType("yaml")
+	if err := v.ReadConfig(bytes.NewReader(expandEnv(data))); err != nil {
+		return nil, err
+	}
+	includes, err := resolveIncludes(filepath.Dir(abs), v.GetStringSlice("include"))
+	if err != nil {
+		return nil, err
+	}
+	for _, inc := range includes {
+		if seen, err = includedFiles(inc, seen); err != nil {
+			return nil, err
+		}
+	}
+	return seen, nil
+}
+
+// secretKeys are settings whose values Diff never reports; ping URLs embed
+// the check's secret.
+var secretKeys = []string{"password", "access_key", "secret_key", "url", "token"}
+
+// Diff returns the settings that differ between old and new, keyed by their
+// dotted YAML path (e.g. "retention.keep").
+func Diff(old, new Config) []Change {
+	var changes []Change
+	diffValue("", reflect.ValueOf(old), reflect.ValueOf(new), &changes)
+	return changes
+}
+
+func diffValue(key string, old, new reflect.Value, changes *[]Change) {
+	if old.Kind() == reflect.Struct && old.Type() != reflect.TypeOf(time.Time{}) {
+		for i := range old.NumField() {
+			field := old.Type().Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+			if name == "" || name == "-" {
+				name = strings.ToLower(field.Name)
+			}
+			if key != "" {
+				name = key + "." + name
+			}
+			diffValue(name, old.Field(i), new.Field(i), changes)
+		}
+		return
+	}
+	if reflect.DeepEqual(old.Interface(), new.Interface()) {
+		return
+	}
+	change := Change{Key: key}
+	leaf := key[strings.LastIndex(key, ".")+1:]
+	composite := old.Kind() == reflect.Slice || old.Kind() == reflect.Map
+	if !composite && !slices.Contains(secretKeys, leaf) {
+		change.Old = fmt.Sprint(old.Interface())
+		change.New = fmt.Sprint(new.Interface())
+	}
+	*changes = append(*changes, change)
+}
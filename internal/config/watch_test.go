@@ -0,0 +1,83 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatcherReloadsIncludes(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"config.yaml": `
+include: ["retention.yaml"]
+backup:
+  directory: "/backups"
+`,
+		"retention.yaml": `
+retention:
+  keep: 7
+`,
+	})
+	path := filepath.Join(dir, "config.yaml")
+
+	reloaded := make(chan []Change, 1)
+	rejected := make(chan error, 1)
+	w, err := NewWatcher(path, func(changes []Change, err error) {
+		if err != nil {
+			rejected <- err
+			return
+		}
+		reloaded <- changes
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go w.Run(ctx)
+	// wait until Load is served by the watcher
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		if _, ok := watched.Load(w.path); ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("watcher did not start")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("retention.yaml", "retention:\n  keep: 14\n")
+	select {
+	case changes := <-reloaded:
+		if len(changes) != 1 || changes[0] != (Change{Key: "retention.keep", Old: "7", New: "14"}) {
+			t.Errorf("changes = %+v, want retention.keep 7 -> 14", changes)
+		}
+	case err := <-rejected:
+		t.Fatalf("reload rejected: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no reload after an include changed")
+	}
+	var cfg Config
+	if err := cfg.Load(path); err != nil || cfg.Retention.Keep != 14 {
+		t.Errorf("Load = keep %d, %v; want the reloaded 14", cfg.Retention.Keep, err)
+	}
+
+	// an invalid file keeps the previous configuration
+	write("config.yaml", "backup: [\n")
+	select {
+	case <-rejected:
+	case <-time.After(5 * time.Second):
+		t.Fatal("invalid configuration was not reported")
+	}
+	if w.Current().Retention.Keep != 14 {
+		t.Errorf("invalid reload replaced the configuration")
+	}
+}
@@ -0,0 +1,197 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrInvalidWindow indicates a malformed backup window or blackout date.
+	ErrInvalidWindow = errors.New("invalid backup window")
+	// ErrOutsideWindow indicates that a backup may not start now.
+	ErrOutsideWindow = errors.New("outside the backup window")
+)
+
+// Outside-window policies (backup.outside_window).
+const (
+	OutsideWindowSkip = "skip" // skip the database until the next run (default)
+	OutsideWindowWait = "wait" // wait for the window to open, at most a day
+)
+
+// dateLayout is the layout of blackout dates.
+const dateLayout = time.DateOnly
+
+// BackupWindow restricts when an instance may be backed up: every day
+// between two times of the day, and never on blackout dates. Times and dates
+// are in the local time zone of the host running bacli. The zero value
+// allows every moment.
+type BackupWindow struct {
+	start, end time.Duration // offsets into the day; equal when unrestricted
+	blackout   []dateRange
+}
+
+// dateRange is a blackout period, from the start of from to the end of to.
+type dateRange struct {
+	from, to string // dateLayout, so they compare as strings
+}
+
+// ParseBackupWindow parses a daily window "HH:MM-HH:MM", which may cross
+// midnight ("22:00-04:00"), and blackout dates "2006-01-02" or inclusive
+// ranges "2006-01-02/2006-01-05". An empty window allows every time of day.
+func ParseBackupWindow(window string, blackout []string) (BackupWindow, error) {
+	var w BackupWindow
+	if window != "" {
+		from, to, ok := strings.Cut(window, "-")
+		start, errStart := parseClock(from)
+		end, errEnd := parseClock(to)
+		if !ok || errStart != nil || errEnd != nil {
+			return w, fmt.Errorf("%w: %q (use HH:MM-HH:MM)", ErrInvalidWindow, window)
+		}
+		if start == end {
+			return w, fmt.Errorf("%w: %q is empty", ErrInvalidWindow, window)
+		}
+		w.start, w.end = start, end
+	}
+	for _, entry := range blackout {
+		from, to, isRange := strings.Cut(strings.TrimSpace(entry), "/")
+		if !isRange {
+			to = from
+		}
+		_, errFrom := time.Parse(dateLayout, from)
+		_, errTo := time.Parse(dateLayout, to)
+		if errFrom != nil || errTo != nil {
+			return w, fmt.Errorf("%w: blackout %q (use YYYY-MM-DD or YYYY-MM-DD/YYYY-MM-DD)",
+				ErrInvalidWindow, entry)
+		}
+		if to < from {
+			return w, fmt.Errorf("%w: blackout %q ends before it starts", ErrInvalidWindow, entry)
+		}
+		w.blackout = append(w.blackout, dateRange{from: from, to: to})
+	}
+	return w, nil
+}
+
+// parseClock parses "HH:MM" as an offset into the day.
+func parseClock(value string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(value))
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// Check returns nil when a backup may start at t, or ErrOutsideWindow
+// saying why not.
+func (w BackupWindow) Check(t time.Time) error {
+	t = t.Local()
+	day := t.Format(dateLayout)
+	for _, r := range w.blackout {
+		if r.from <= day && day <= r.to {
+			if r.from == r.to {
+				return fmt.Errorf("%w: %s is a blackout date", ErrOutsideWindow, day)
+			}
+			return fmt.Errorf("%w: %s is in the blackout period %s to %s", ErrOutsideWindow, day, r.from, r.to)
+		}
+	}
+	if !w.inWindow(t) {
+		return fmt.Errorf("%w %s (now %s)", ErrOutsideWindow, w, t.Format("15:04"))
+	}
+	return nil
+}
+
+// Blackout reports whether t falls on a blackout date.
+func (w BackupWindow) Blackout(t time.Time) bool {
+	day := t.Local().Format(dateLayout)
+	for _, r := range w.blackout {
+		if r.from <= day && day <= r.to {
+			return true
+		}
+	}
+	return false
+}
+
+// Opens returns when the daily window next opens after t, or t itself when
+// it is open. Blackout dates are not considered.
+func (w BackupWindow) Opens(t time.Time) time.Time {
+	t = t.Local()
+	if w.inWindow(t) {
+		return t
+	}
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	open := midnight.Add(w.start)
+	if !open.After(t) {
+		open = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location()).Add(w.start)
+	}
+	return open
+}
+
+// inWindow reports whether the time of day of t is within the daily window.
+func (w BackupWindow) inWindow(t time.Time) bool {
+	if w.start == w.end {
+		return true
+	}
+	now := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute +
+		time.Duration(t.Second())*time.Second
+	if w.start < w.end {
+		return w.start <= now && now < w.end
+	}
+	return now >= w.start || now < w.end // crosses midnight
+}
+
+// String returns the window as configured, "HH:MM-HH:MM".
+func (w BackupWindow) String() string {
+	if w.start == w.end {
+		return "always"
+	}
+	clock := func(d time.Duration) string {
+		return fmt.Sprintf("%02d:%02d", int(d.Hours()), int(d.Minutes())%60)
+	}
+	return clock(w.start) + "-" + clock(w.end)
+}
+
+// BackupWindow returns the backup window of the instance named instance of
+// engine: its own window, or the engine's, and the blackout dates of both.
+// Databases of unknown engines are never restricted.
+func (c *Config) BackupWindow(engine, instance string) (BackupWindow, error) {
+	group, ok := c.EngineGroup(engine)
+	if !ok {
+		return BackupWindow{}, nil
+	}
+	window, blackout := group.Window, group.Blackout
+	for _, inst := range group.Instances {
+		if inst.Name != instance {
+			continue
+		}
+		if inst.Window != "" {
+			window = inst.Window
+		}
+		blackout = append(blackout[:len(blackout):len(blackout)], inst.Blackout...)
+		break
+	}
+	return ParseBackupWindow(window, blackout)
+}
+
+// EngineGroup returns the group configuring engine.
+func (c *Config) EngineGroup(engine string) (DBGroupConfig, bool) {
+	switch engine {
+	case "postgres":
+		return c.Postgres, true
+	case "mongodb":
+		return c.MongoDB, true
+	case "mysql":
+		return c.MySQL, true
+	case "redis":
+		return c.Redis, true
+	case "clickhouse":
+		return c.ClickHouse, true
+	case "cassandra":
+		return c.Cassandra, true
+	case "influxdb":
+		return c.InfluxDB, true
+	case "couchdb":
+		return c.CouchDB, true
+	}
+	return DBGroupConfig{}, false
+}
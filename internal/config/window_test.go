@@ -0,0 +1,57 @@
+package config
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBackupWindow(t *testing.T) {
+	w, err := ParseBackupWindow("22:00-04:00", []string{"2025-12-24", "2025-12-30/2026-01-02"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(s string) time.Time {
+		parsed, err := time.ParseInLocation(time.DateTime, s, time.Local)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return parsed
+	}
+	for _, ok := range []string{"2025-12-01 23:30:00", "2025-12-02 03:59:00", "2025-12-01 22:00:00"} {
+		if err := w.Check(at(ok)); err != nil {
+			t.Errorf("%s: %v", ok, err)
+		}
+	}
+	for _, outside := range []string{
+		"2025-12-01 04:00:00", // window closed
+		"2025-12-01 12:00:00",
+		"2025-12-24 23:00:00", // blackout date
+		"2026-01-01 01:00:00", // blackout period
+	} {
+		if err := w.Check(at(outside)); !errors.Is(err, ErrOutsideWindow) {
+			t.Errorf("%s: err = %v, want ErrOutsideWindow", outside, err)
+		}
+	}
+	if got, want := w.Opens(at("2025-12-01 12:00:00")), at("2025-12-01 22:00:00"); !got.Equal(want) {
+		t.Errorf("Opens at noon = %v, want %v", got, want)
+	}
+	if got, want := w.Opens(at("2025-12-01 23:00:00")), at("2025-12-01 23:00:00"); !got.Equal(want) {
+		t.Errorf("Opens when open = %v, want %v", got, want)
+	}
+
+	for _, bad := range []struct {
+		window   string
+		blackout []string
+	}{
+		{"22:00", nil},
+		{"25:00-04:00", nil},
+		{"04:00-04:00", nil},
+		{"", []string{"24/12/2025"}},
+		{"", []string{"2026-01-02/2025-12-30"}},
+	} {
+		if _, err := ParseBackupWindow(bad.window, bad.blackout); !errors.Is(err, ErrInvalidWindow) {
+			t.Errorf("ParseBackupWindow(%q, %q): err = %v, want ErrInvalidWindow", bad.window, bad.blackout, err)
+		}
+	}
+}
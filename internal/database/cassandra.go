@@ -0,0 +1,821 @@
+package database
+
+import (
+	"archive/tar"
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/kebairia/backup/internal/config"
+	"github.com/kebairia/backup/internal/logger"
+)
+
+// EngineCassandra backs up Apache Cassandra and ScyllaDB keyspaces, which
+// share the nodetool/cqlsh/sstableloader tooling.
+const EngineCassandra = "cassandra"
+
+// Cassandra restore methods. Backups are always node snapshots.
+const (
+	// CassandraRestoreLoader streams the SSTables to the cluster with
+	// sstableloader; it works from any host that can reach the cluster.
+	CassandraRestoreLoader = "sstableloader"
+	// CassandraRestoreRefresh copies the SSTables into the table directories
+	// of the local node and loads them with `nodetool refresh`.
+	CassandraRestoreRefresh = "refresh"
+)
+
+const (
+	cassandraExt      = ".cassandra.tar"
+	cassandraManifest = "manifest.json"
+	cassandraSchema   = "schema.cql"
+)
+
+// clearSnapshotTimeout bounds `nodetool clearsnapshot` after a backup.
+const clearSnapshotTimeout = time.Minute
+
+// CassandraOption lets you override default settings on a Cassandra.
+type CassandraOption func(*Cassandra)
+
+// Cassandra holds configuration for backing up and restoring a Cassandra or
+// ScyllaDB keyspace. Backups snapshot the node bacli runs on, so bacli must
+// run on every node whose data is to be kept.
+type Cassandra struct {
+	Username      string
+	Password      string
+	Keyspace      string
+	Host          string // CQL host for cqlsh and sstableloader
+	Port          string // CQL port
+	DataDir       string // node data directory holding <keyspace>/<table>-<id>
+	RestoreMethod string // "sstableloader" or "refresh"
+	OutputDir     string
+	WorkDir       string // dumps are written here first, see WorkPath
+	TimeStampFmt  string
+	NameTemplate  string // artifact name, see config.ArtifactName
+	Instance      string // instance name, see config.ArtifactDir
+	DirTemplate   string // artifact directory, see config.ArtifactDir
+	Timeout       time.Duration
+	Labels        map[string]string
+	Logger        logger.Logger
+	// AllowOverwrite lets restores replace a target that holds data
+	AllowOverwrite bool
+
+	// ctx cancels running client commands (see WithCassandraContext)
+	ctx context.Context
+	// Vault lease of the dynamic credentials (see LeaseHolder)
+	credentialLease
+	// stderr of client commands (see StderrSetter)
+	stderrSink
+	// runs client commands on the backup host (see toolExec)
+	toolExec
+}
+
+// cassandraArchive is the manifest of a keyspace snapshot archive.
+type cassandraArchive struct {
+	Keyspace string   `json:"keyspace"`
+	Snapshot string   `json:"snapshot"` // nodetool snapshot tag
+	Tables   []string `json:"tables"`   // each under data/<table>/
+}
+
+// NewCassandra returns a Cassandra configured from cfg plus any overrides.
+func NewCassandra(cfg config.Config, opts ...CassandraOption) (*Cassandra, error) {
+	log, err := logger.Init()
+	if err != nil {
+		return nil, fmt.Errorf("logger init failed: %w", err)
+	}
+	c := &Cassandra{
+		Host:          cfg.Cassandra.EngineDefaults.Host,
+		Port:          cfg.Cassandra.EngineDefaults.Port,
+		DataDir:       cfg.Cassandra.EngineDefaults.DataDir,
+		RestoreMethod: cfg.Cassandra.EngineDefaults.RestoreMethod,
+		OutputDir:     cfg.Backup.Directory,
+		WorkDir:       cfg.Backup.WorkDir,
+		TimeStampFmt:  cfg.Backup.TimestampFmt,
+		NameTemplate:  cfg.Backup.NameTemplate,
+		DirTemplate:   cfg.Backup.DirTemplate,
+		Timeout:       cfg.Backup.Timeout,
+		Logger:        log,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.Logger = logger.FromContext(c.ctx, c.Logger)
+	if c.RestoreMethod == "" {
+		c.RestoreMethod = CassandraRestoreLoader
+	}
+	if c.RestoreMethod != CassandraRestoreLoader && c.RestoreMethod != CassandraRestoreRefresh {
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedRestoreMethod, c.RestoreMethod)
+	}
+	return c, nil
+}
+
+// WithCassandraCredentials sets the CQL username and password.
+func WithCassandraCredentials(user, pass string) CassandraOption {
+	return func(c *Cassandra) {
+		if user != "" {
+			c.Username = user
+		}
+		if pass != "" {
+			c.Password = pass
+		}
+	}
+}
+
+// WithCassandraHost overrides the CQL host.
+func WithCassandraHost(host string) CassandraOption {
+	return func(c *Cassandra) {
+		if host != "" {
+			c.Host = host
+		}
+	}
+}
+
+// WithCassandraPort overrides the CQL port.
+func WithCassandraPort(port string) CassandraOption {
+	return func(c *Cassandra) {
+		if port != "" {
+			c.Port = port
+		}
+	}
+}
+
+// WithCassandraKeyspace sets the keyspace.
+func WithCassandraKeyspace(keyspace string) CassandraOption {
+	return func(c *Cassandra) {
+		if keyspace != "" {
+			c.Keyspace = keyspace
+		}
+	}
+}
+
+// WithCassandraDataDir overrides the node data directory.
+func WithCassandraDataDir(dir string) CassandraOption {
+	return func(c *Cassandra) {
+		if dir != "" {
+			c.DataDir = dir
+		}
+	}
+}
+
+// WithCassandraRestoreMethod overrides how snapshots are restored.
+func WithCassandraRestoreMethod(method string) CassandraOption {
+	return func(c *Cassandra) {
+		if method != "" {
+			c.RestoreMethod = method
+		}
+	}
+}
+
+// WithCassandraOutputDir overrides where backups are written.
+func WithCassandraOutputDir(dir string) CassandraOption {
+	return func(c *Cassandra) {
+		if dir != "" {
+			c.OutputDir = dir
+		}
+	}
+}
+
+// WithCassandraInstance sets the instance name, available to backup.dir_template.
+func WithCassandraInstance(name string) CassandraOption {
+	return func(c *Cassandra) {
+		c.Instance = name
+	}
+}
+
+// WithCassandraContext makes ctx cancel the size estimate, keyspace listing
+// and version queries, e.g. when the run is interrupted.
+func WithCassandraContext(ctx context.Context) CassandraOption {
+	return func(c *Cassandra) {
+		c.ctx = ctx
+	}
+}
+
+// WithCassandraLabels sets the labels recorded with every backup.
+func WithCassandraLabels(labels map[string]string) CassandraOption {
+	return func(c *Cassandra) {
+		c.Labels = labels
+	}
+}
+
+// WithCassandraAllowOverwrite lets restores replace a target holding data.
+func WithCassandraAllowOverwrite(allow bool) CassandraOption {
+	return func(c *Cassandra) {
+		c.AllowOverwrite = allow
+	}
+}
+
+// WithCassandraTimestampFormat overrides timestamp format.
+func WithCassandraTimestampFormat(format string) CassandraOption {
+	return func(c *Cassandra) {
+		if format != "" {
+			c.TimeStampFmt = format
+		}
+	}
+}
+
+// artifactName renders the configured name template for a backup taken now.
+func (c *Cassandra) artifactName() (string, error) {
+	now := time.Now()
+	return config.ArtifactName{
+		Engine:    EngineCassandra,
+		Database:  c.Keyspace,
+		Host:      c.Host,
+		Method:    "snapshot",
+		Timestamp: now.Format(c.TimeStampFmt),
+		Time:      now,
+	}.Render(c.NameTemplate)
+}
+
+// Backup snapshots the keyspace with `nodetool snapshot` and collects the
+// snapshot directories of its tables, with the keyspace schema, into a tar
+// archive. The snapshot is cleared afterwards.
+func (c *Cassandra) Backup(ctx context.Context) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	backupsDir := WorkPath(c.OutputDir, c.WorkDir, c.GetPath())
+	if err := os.MkdirAll(backupsDir, 0o755); err != nil {
+		return "", fmt.Errorf("mkdir %q: %w", backupsDir, err)
+	}
+	name, err := c.artifactName()
+	if err != nil {
+		return "", err
+	}
+	backupPath := filepath.Join(backupsDir, name+cassandraExt)
+
+	c.Logger.Info("backup started",
+		"database", c.Keyspace,
+		"engine", EngineCassandra,
+		"path", backupPath,
+	)
+	start := time.Now()
+
+	// The schema goes first: tables created after the snapshot are harmless
+	schema, err := c.cqlsh(ctx, c.Host, "DESCRIBE KEYSPACE "+cqlIdent(c.Keyspace))
+	if err != nil {
+		return "", fmt.Errorf("describe keyspace: %w", err)
+	}
+
+	tag := "bacli-" + start.UTC().Format("20060102T150405")
+	if _, err := c.nodetool(ctx, "snapshot", "-t", tag, c.Keyspace); err != nil {
+		return "", fmt.Errorf("nodetool snapshot failed: %w", err)
+	}
+	defer func() {
+		// Snapshots are hard links that pin disk space until cleared
+		clearCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), clearSnapshotTimeout)
+		defer cancel()
+		if _, cerr := c.nodetool(clearCtx, "clearsnapshot", "-t", tag, c.Keyspace); cerr != nil {
+			c.Logger.Warn("failed to clear snapshot", "tag", tag, "error", cerr.Error())
+		}
+	}()
+
+	dirs, err := c.snapshotDirs(tag)
+	if err != nil {
+		return "", err
+	}
+	if err := c.writeArchive(backupPath, tag, schema, dirs); err != nil {
+		return backupPath, err
+	}
+	c.Logger.Info("backup completed",
+		"tables", len(dirs),
+		"duration", time.Since(start).String(),
+	)
+	return backupPath, nil
+}
+
+// snapshotDirs returns the snapshot directory of every table of the keyspace
+// for tag, by table name.
+func (c *Cassandra) snapshotDirs(tag string) (map[string]string, error) {
+	pattern := filepath.Join(c.DataDir, c.Keyspace, "*", "snapshots", tag)
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("%w: no snapshot %q under %s (is bacli running on the node?)",
+			ErrBackupFailed, tag, filepath.Join(c.DataDir, c.Keyspace))
+	}
+	dirs := make(map[string]string, len(matches))
+	for _, dir := range matches {
+		// <data>/<keyspace>/<table>-<id>/snapshots/<tag>
+		tableDir := filepath.Base(filepath.Dir(filepath.Dir(dir)))
+		dirs[cassandraTable(tableDir)] = dir
+	}
+	return dirs, nil
+}
+
+// writeArchive writes the manifest, schema and snapshot files of the
+// keyspace into a tar archive at path.
+func (c *Cassandra) writeArchive(path, tag, schema string, dirs map[string]string) (err error) {
+	out, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("create %q: %w", path, err)
+	}
+	defer func() {
+		if cerr := out.Close(); err == nil && cerr != nil {
+			err = fmt.Errorf("close %q: %w", path, cerr)
+		}
+	}()
+
+	tw := tar.NewWriter(out)
+	archive := cassandraArchive{Keyspace: c.Keyspace, Snapshot: tag}
+	for table := range dirs {
+		archive.Tables = append(archive.Tables, table)
+	}
+	slices.Sort(archive.Tables)
+	manifest, err := json.MarshalIndent(archive, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeEntry(tw, cassandraManifest, manifest); err != nil {
+		return err
+	}
+	if err := writeEntry(tw, cassandraSchema, []byte(schema)); err != nil {
+		return err
+	}
+	for _, table := range archive.Tables {
+		if err := addTree(tw, dirs[table], "data/"+table); err != nil {
+			return fmt.Errorf("archive %s: %w", table, err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("write %q: %w", path, err)
+	}
+	return nil
+}
+
+// Restore recreates the keyspace from the archived schema when it does not
+// exist, truncates the archived tables and loads their SSTables with the
+// configured restore method.
+func (c *Cassandra) Restore(ctx context.Context, backupFile string) error {
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	archive, err := readCassandraManifest(backupFile)
+	if err != nil {
+		return err
+	}
+	// sstableloader expects <dir>/<keyspace>/<table>
+	scratch, err := os.MkdirTemp(filepath.Dir(backupFile), ".cassandra-restore-*")
+	if err != nil {
+		return fmt.Errorf("create scratch directory: %w", err)
+	}
+	defer os.RemoveAll(scratch)
+	if err := extractTar(backupFile, scratch); err != nil {
+		return err
+	}
+
+	c.Logger.Info("restore started",
+		"database", c.Keyspace,
+		"engine", EngineCassandra,
+		"method", c.RestoreMethod,
+		"source", backupFile,
+	)
+	start := time.Now()
+
+	exists, err := c.keyspaceExists(ctx)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		if _, err := c.cqlsh(ctx, c.Host, "", "-f", filepath.Join(scratch, cassandraSchema)); err != nil {
+			return fmt.Errorf("create keyspace from schema: %w", err)
+		}
+	}
+	for _, table := range archive.Tables {
+		qualified := cqlIdent(archive.Keyspace) + "." + cqlIdent(table)
+		if _, err := c.cqlsh(ctx, c.Host, "TRUNCATE "+qualified); err != nil {
+			return fmt.Errorf("truncate %s: %w", table, err)
+		}
+		dir := filepath.Join(scratch, "data", table)
+		if c.RestoreMethod == CassandraRestoreRefresh {
+			err = c.refresh(ctx, archive.Keyspace, table, dir)
+		} else {
+			err = c.load(ctx, archive.Keyspace, table, dir)
+		}
+		if err != nil {
+			return fmt.Errorf("restore %s: %w", table, err)
+		}
+	}
+	c.Logger.Info("restore completed",
+		"tables", len(archive.Tables),
+		"duration", time.Since(start).String(),
+	)
+	return nil
+}
+
+// load streams the SSTables in dir to the cluster with sstableloader.
+func (c *Cassandra) load(ctx context.Context, keyspace, table, dir string) error {
+	staged := filepath.Join(filepath.Dir(filepath.Dir(dir)), keyspace, table)
+	if err := os.MkdirAll(filepath.Dir(staged), 0o755); err != nil {
+		return err
+	}
+	if err := os.Rename(dir, staged); err != nil {
+		return err
+	}
+	args := []string{"-d", c.Host}
+	if c.Username != "" {
+		// username and password, as in a JMX password file
+		path, cleanup, err := c.secretFile(ctx, "bacli-cassandra-*.pw", c.Username+" "+c.Password+"\n")
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+		args = append(args, "-u", c.Username, "-pwf", path)
+	}
+	cmd, err := c.command(ctx, nil, "sstableloader", append(args, staged)...)
+	if err != nil {
+		return err
+	}
+	cmd.Stdout = io.Discard
+	cmd.Stderr = c.stderr()
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("sstableloader failed: %w", err)
+	}
+	return nil
+}
+
+// refresh copies the SSTables in dir into the live table directory of the
+// local node and loads them with `nodetool refresh`.
+func (c *Cassandra) refresh(ctx context.Context, keyspace, table, dir string) error {
+	live, err := c.tableDir(keyspace, table)
+	if err != nil {
+		return err
+	}
+	err = filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		return copyFile(path, filepath.Join(live, rel))
+	})
+	if err != nil {
+		return fmt.Errorf("copy sstables into %s: %w", live, err)
+	}
+	if _, err := c.nodetool(ctx, "refresh", keyspace, table); err != nil {
+		return fmt.Errorf("nodetool refresh failed: %w", err)
+	}
+	return nil
+}
+
+// tableDir returns the live data directory of table on the local node. When
+// a table was recreated, the newest directory is the live one.
+func (c *Cassandra) tableDir(keyspace, table string) (string, error) {
+	matches, err := filepath.Glob(filepath.Join(c.DataDir, keyspace, table+"-*"))
+	if err != nil {
+		return "", err
+	}
+	var (
+		live   string
+		newest time.Time
+	)
+	for _, dir := range matches {
+		info, err := os.Stat(dir)
+		if err != nil || !info.IsDir() || cassandraTable(filepath.Base(dir)) != table {
+			continue
+		}
+		if live == "" || info.ModTime().After(newest) {
+			live, newest = dir, info.ModTime()
+		}
+	}
+	if live == "" {
+		return "", fmt.Errorf("%w: no data directory for %s.%s under %s",
+			ErrRestoreFailed, keyspace, table, c.DataDir)
+	}
+	return live, nil
+}
+
+// keyspaceExists reports whether the keyspace is defined on the cluster.
+func (c *Cassandra) keyspaceExists(ctx context.Context) (bool, error) {
+	out, err := c.cqlsh(ctx, c.Host, "DESCRIBE KEYSPACES")
+	if err != nil {
+		return false, err
+	}
+	for _, name := range strings.Fields(out) {
+		if name == c.Keyspace {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// ValidateArtifact checks that a snapshot archive has a manifest, a schema
+// and SSTable data for every listed table.
+func (c *Cassandra) ValidateArtifact(path string) ([]string, error) {
+	archive, err := readCassandraManifest(path)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("backup file %q not found: %w", path, err)
+	}
+	defer file.Close()
+	var hasSchema bool
+	hasData := make(map[string]bool)
+	tr := tar.NewReader(file)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: read %s: %v", ErrInvalidArtifact, path, err)
+		}
+		if header.Name == cassandraSchema {
+			hasSchema = true
+		}
+		if rest, ok := strings.CutPrefix(header.Name, "data/"); ok && strings.HasSuffix(rest, "-Data.db") {
+			table, _, _ := strings.Cut(rest, "/")
+			hasData[table] = true
+		}
+	}
+	if !hasSchema {
+		return nil, fmt.Errorf("%w: %s has no %s", ErrInvalidArtifact, path, cassandraSchema)
+	}
+	tables := make([]string, 0, len(archive.Tables))
+	for _, table := range archive.Tables {
+		entry := table
+		if !hasData[table] {
+			entry += " (empty)" // nothing flushed to disk
+		}
+		tables = append(tables, entry)
+	}
+	return tables, nil
+}
+
+// EstimateSize returns the live disk space used by the keyspace as reported
+// by `nodetool tablestats`.
+func (c *Cassandra) EstimateSize() (int64, error) {
+	ctx, cancel := context.WithTimeout(orBackground(c.ctx), c.Timeout)
+	defer cancel()
+	out, err := c.nodetool(ctx, "tablestats", c.Keyspace)
+	if err != nil {
+		return 0, err
+	}
+	var total int64
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if value, ok := strings.CutPrefix(line, "Space used (live): "); ok {
+			size, err := parseSize(value)
+			if err != nil {
+				return 0, err
+			}
+			total += size
+		}
+	}
+	return total, nil
+}
+
+// TargetObjects returns the tables of the keyspace, which restores
+// truncate; none when it does not exist.
+func (c *Cassandra) TargetObjects() ([]string, error) {
+	ctx, cancel := context.WithTimeout(orBackground(c.ctx), c.Timeout)
+	defer cancel()
+	exists, err := c.keyspaceExists(ctx)
+	if err != nil || !exists {
+		return nil, err
+	}
+	out, err := c.cqlsh(ctx, c.Host, "DESCRIBE TABLES", "-k", c.Keyspace)
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(out), nil
+}
+
+// OverwriteAllowed reports whether the instance sets allow_overwrite.
+func (c *Cassandra) OverwriteAllowed() bool { return c.AllowOverwrite }
+
+// ListDatabases returns the keyspaces on the cluster.
+func (c *Cassandra) ListDatabases() ([]string, error) {
+	ctx, cancel := context.WithTimeout(orBackground(c.ctx), c.Timeout)
+	defer cancel()
+	out, err := c.cqlsh(ctx, c.Host, "DESCRIBE KEYSPACES")
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(out), nil
+}
+
+// ServerVersion returns the release version of the local node.
+func (c *Cassandra) ServerVersion() (string, error) {
+	ctx, cancel := context.WithTimeout(orBackground(c.ctx), c.Timeout)
+	defer cancel()
+	out, err := c.nodetool(ctx, "version")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(out), "ReleaseVersion:")), nil
+}
+
+// cqlsh runs statement (or the extra arguments, e.g. -f file) on host. The
+// credentials are passed in a cqlshrc only the current user can read.
+func (c *Cassandra) cqlsh(ctx context.Context, host, statement string, extra ...string) (string, error) {
+	args := []string{host, c.Port}
+	if c.Username != "" {
+		path, cleanup, err := c.secretFile(ctx, "bacli-cqlshrc-*",
+			"[authentication]\nusername = "+c.Username+"\npassword = "+c.Password+"\n")
+		if err != nil {
+			return "", err
+		}
+		defer cleanup()
+		args = append(args, "--cqlshrc="+path)
+	}
+	if statement != "" {
+		args = append(args, "-e", statement)
+	}
+	cmd, err := c.command(ctx, nil, "cqlsh", append(args, extra...)...)
+	if err != nil {
+		return "", err
+	}
+	cmd.Stderr = c.stderr()
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("cqlsh failed: %w", err)
+	}
+	return string(out), nil
+}
+
+// nodetool runs a nodetool command against the local node.
+func (c *Cassandra) nodetool(ctx context.Context, args ...string) (string, error) {
+	cmd, err := c.command(ctx, nil, "nodetool", args...)
+	if err != nil {
+		return "", err
+	}
+	cmd.Stderr = c.stderr()
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("nodetool %s: %w", args[0], err)
+	}
+	return string(out), nil
+}
+
+// readCassandraManifest reads the manifest of a snapshot archive.
+func readCassandraManifest(path string) (cassandraArchive, error) {
+	var archive cassandraArchive
+	file, err := os.Open(path)
+	if err != nil {
+		return archive, fmt.Errorf("backup file %q not found: %w", path, err)
+	}
+	defer file.Close()
+	// The manifest is written first
+	tr := tar.NewReader(file)
+	header, err := tr.Next()
+	if err != nil || header.Name != cassandraManifest {
+		return archive, fmt.Errorf("%w: %s does not start with %s", ErrInvalidArtifact, path, cassandraManifest)
+	}
+	if err := json.NewDecoder(tr).Decode(&archive); err != nil {
+		return archive, fmt.Errorf("%w: decode %s: %v", ErrInvalidArtifact, cassandraManifest, err)
+	}
+	return archive, nil
+}
+
+// addTree adds the files under root to tw, named prefix/<relative path>.
+func addTree(tw *tar.Writer, root, prefix string) error {
+	return filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || !entry.Type().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = prefix + "/" + filepath.ToSlash(rel)
+		if err := tw.WriteHeader(header); err != nil {
+			return fmt.Errorf("write %s: %w", header.Name, err)
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		if _, err := io.Copy(tw, file); err != nil {
+			return fmt.Errorf("write %s: %w", header.Name, err)
+		}
+		return nil
+	})
+}
+
+// extractTar writes the regular files of the tar archive at path under dir.
+// Entries escaping dir are rejected.
+func extractTar(path, dir string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("backup file %q not found: %w", path, err)
+	}
+	defer file.Close()
+	tr := tar.NewReader(file)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%w: read %s: %v", ErrInvalidArtifact, path, err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		if !filepath.IsLocal(header.Name) {
+			return fmt.Errorf("%w: %s has unsafe entry %q", ErrInvalidArtifact, path, header.Name)
+		}
+		target := filepath.Join(dir, header.Name)
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			return err
+		}
+		if err := writeFile(target, tr); err != nil {
+			return err
+		}
+	}
+}
+
+// copyFile copies the file at src to dst, creating dst's directory.
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	return writeFile(dst, in)
+}
+
+// writeFile creates path with the content of r.
+func writeFile(path string, r io.Reader) error {
+	out, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, r); err != nil {
+		out.Close()
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+	return out.Close()
+}
+
+// cassandraTable returns the table name of a <table>-<id> data directory.
+func cassandraTable(dir string) string {
+	if i := strings.LastIndex(dir, "-"); i > 0 {
+		return dir[:i]
+	}
+	return dir
+}
+
+// cqlIdent quotes a CQL identifier.
+func cqlIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// GetName returns the keyspace name.
+func (c *Cassandra) GetName() string { return c.Keyspace }
+
+// GetEngine returns engine name.
+func (c *Cassandra) GetEngine() string { return EngineCassandra }
+
+// GetPath returns the directory holding the artifacts and catalog of the
+// database, <output dir>/<engine>/<backup.dir_template>.
+func (c *Cassandra) GetPath() string {
+	return artifactDir(c.OutputDir, c.DirTemplate, config.ArtifactDir{
+		Engine:   EngineCassandra,
+		Instance: c.Instance,
+		Host:     c.Host,
+		Database: c.Keyspace,
+	})
+}
+
+// GetInstance returns the name of the configured instance.
+func (c *Cassandra) GetInstance() string { return c.Instance }
+
+// ArtifactExt returns the extension of the single-file artifacts of the
+// configured method.
+func (c *Cassandra) ArtifactExt() (string, bool) {
+	return cassandraExt, true
+}
+
+// GetHost returns the CQL host.
+func (c *Cassandra) GetHost() string { return c.Host }
+
+// GetLabels returns the labels recorded with every backup.
+func (c *Cassandra) GetLabels() map[string]string { return c.Labels }
@@ -0,0 +1,43 @@
+package database
+
+import (
+	"archive/tar"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCassandraTable(t *testing.T) {
+	tests := map[string]string{
+		"users-5f3a9c00e1a211eeb1c3f7d1a1c0e9a4":       "users",
+		"user_events-5f3a9c00e1a211eeb1c3f7d1a1c0e9a4": "user_events",
+		"plain": "plain",
+	}
+	for dir, want := range tests {
+		if got := cassandraTable(dir); got != want {
+			t.Errorf("cassandraTable(%q) = %q, want %q", dir, got, want)
+		}
+	}
+}
+
+func TestExtractTarRejectsUnsafeEntries(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "evil.tar")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tw := tar.NewWriter(file)
+	if err := writeEntry(tw, "../escape", []byte("x")); err != nil {
+		t.Fatal(err)
+	}
+	tw.Close()
+	file.Close()
+
+	if err := extractTar(path, filepath.Join(dir, "out")); err == nil {
+		t.Fatal("extractTar accepted an entry outside the target directory")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "escape")); err == nil {
+		t.Fatal("entry was written outside the target directory")
+	}
+}
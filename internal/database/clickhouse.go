@@ -0,0 +1,860 @@
+package database
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/kebairia/backup/internal/config"
+	"github.com/kebairia/backup/internal/logger"
+)
+
+const EngineClickHouse = "clickhouse"
+
+const (
+	// ClickHouseMethodDump exports every table with clickhouse-client into
+	// a local tar archive: its CREATE statement and its rows in Native format.
+	ClickHouseMethodDump = "dump"
+	// ClickHouseMethodBackup runs the server's BACKUP command into a backup
+	// disk (local or S3). The local artifact only records where it went.
+	ClickHouseMethodBackup = "backup"
+
+	clickhouseDumpExt   = ".ch.tar"
+	clickhouseBackupExt = ".chbackup.json"
+	clickhouseManifest  = "manifest.json"
+)
+
+// ClickHouseOption lets you override default settings on a ClickHouse.
+type ClickHouseOption func(*ClickHouse)
+
+// ClickHouse holds configuration for backing up and restoring a ClickHouse
+// database, or a selection of its tables.
+type ClickHouse struct {
+	Username     string
+	Password     string
+	Database     string
+	Tables       []string // empty backs up the whole database
+	Host         string
+	Port         string
+	Method       string // "dump" (clickhouse-client) or "backup" (BACKUP SQL)
+	BackupDisk   string // server disk BACKUP writes to, for the backup method
+	OutputDir    string
+	WorkDir      string // dumps are written here first, see WorkPath
+	TimeStampFmt string
+	NameTemplate string // artifact name, see config.ArtifactName
+	Instance     string // instance name, see config.ArtifactDir
+	DirTemplate  string // artifact directory, see config.ArtifactDir
+	Timeout      time.Duration
+	Labels       map[string]string
+	Logger       logger.Logger
+	// AllowOverwrite lets restores replace a target that holds data
+	AllowOverwrite bool
+
+	// ctx cancels running client commands (see WithClickHouseContext)
+	ctx context.Context
+	// Vault lease of the dynamic credentials (see LeaseHolder)
+	credentialLease
+	// stderr of client commands (see StderrSetter)
+	stderrSink
+	// runs client commands on the backup host (see toolExec)
+	toolExec
+
+	// Restore target overrides (see Retarget)
+	RestoreHost     string
+	RestoreDatabase string
+}
+
+// clickhouseArchive is the manifest of a dump archive.
+type clickhouseArchive struct {
+	Database string            `json:"database"`
+	Tables   []clickhouseTable `json:"tables"`
+}
+
+// clickhouseTable locates the entries of one table in a dump archive.
+type clickhouseTable struct {
+	Name   string `json:"name"`
+	Schema string `json:"schema"` // CREATE statement
+	Data   string `json:"data"`   // rows in Native format
+}
+
+// clickhouseBackup is the artifact of the backup method: where the server
+// wrote the backup and what it holds.
+type clickhouseBackup struct {
+	ID       string   `json:"id"`
+	Database string   `json:"database"`
+	Tables   []string `json:"tables,omitempty"`
+	Disk     string   `json:"disk"`
+	Path     string   `json:"path"`
+}
+
+// destination returns the BACKUP/RESTORE target clause of b.
+func (b clickhouseBackup) destination() string {
+	return fmt.Sprintf("Disk(%s, %s)", chString(b.Disk), chString(b.Path))
+}
+
+// NewClickHouse returns a ClickHouse configured from cfg plus any overrides.
+func NewClickHouse(cfg config.Config, opts ...ClickHouseOption) (*ClickHouse, error) {
+	log, err := logger.Init()
+	if err != nil {
+		return nil, fmt.Errorf("logger init failed: %w", err)
+	}
+	c := &ClickHouse{
+		Host:         cfg.ClickHouse.EngineDefaults.Host,
+		Port:         cfg.ClickHouse.EngineDefaults.Port,
+		Method:       cfg.ClickHouse.EngineDefaults.Method,
+		BackupDisk:   cfg.ClickHouse.EngineDefaults.BackupDisk,
+		OutputDir:    cfg.Backup.Directory,
+		WorkDir:      cfg.Backup.WorkDir,
+		TimeStampFmt: cfg.Backup.TimestampFmt,
+		NameTemplate: cfg.Backup.NameTemplate,
+		DirTemplate:  cfg.Backup.DirTemplate,
+		Timeout:      cfg.Backup.Timeout,
+		Logger:       log,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.Logger = logger.FromContext(c.ctx, c.Logger)
+	if c.Method == "" {
+		c.Method = ClickHouseMethodDump
+	}
+	if c.Method != ClickHouseMethodDump && c.Method != ClickHouseMethodBackup {
+		return nil, fmt.Errorf("unknown clickhouse format %q", c.Method)
+	}
+	return c, nil
+}
+
+// WithClickHouseCredentials sets username and password.
+func WithClickHouseCredentials(user, pass string) ClickHouseOption {
+	return func(c *ClickHouse) {
+		if user != "" {
+			c.Username = user
+		}
+		if pass != "" {
+			c.Password = pass
+		}
+	}
+}
+
+// WithClickHouseHost overrides the host.
+func WithClickHouseHost(host string) ClickHouseOption {
+	return func(c *ClickHouse) {
+		if host != "" {
+			c.Host = host
+		}
+	}
+}
+
+// WithClickHousePort overrides the native protocol port.
+func WithClickHousePort(port string) ClickHouseOption {
+	return func(c *ClickHouse) {
+		if port != "" {
+			c.Port = port
+		}
+	}
+}
+
+// WithClickHouseDatabase sets the database name.
+func WithClickHouseDatabase(db string) ClickHouseOption {
+	return func(c *ClickHouse) {
+		if db != "" {
+			c.Database = db
+		}
+	}
+}
+
+// WithClickHouseTables limits backups to the given tables of the database.
+func WithClickHouseTables(tables []string) ClickHouseOption {
+	return func(c *ClickHouse) {
+		c.Tables = tables
+	}
+}
+
+// WithClickHouseMethod overrides the backup method.
+func WithClickHouseMethod(method string) ClickHouseOption {
+	return func(c *ClickHouse) {
+		if method != "" {
+			c.Method = method
+		}
+	}
+}
+
+// WithClickHouseBackupDisk overrides the server disk used by the backup method.
+func WithClickHouseBackupDisk(disk string) ClickHouseOption {
+	return func(c *ClickHouse) {
+		if disk != "" {
+			c.BackupDisk = disk
+		}
+	}
+}
+
+// WithClickHouseOutputDir overrides where backups are written.
+func WithClickHouseOutputDir(dir string) ClickHouseOption {
+	return func(c *ClickHouse) {
+		if dir != "" {
+			c.OutputDir = dir
+		}
+	}
+}
+
+// WithClickHouseInstance sets the instance name, available to backup.dir_template.
+func WithClickHouseInstance(name string) ClickHouseOption {
+	return func(c *ClickHouse) {
+		c.Instance = name
+	}
+}
+
+// WithClickHouseContext makes ctx cancel the clickhouse-client queries
+// behind checks and verification, e.g. when the run is interrupted.
+func WithClickHouseContext(ctx context.Context) ClickHouseOption {
+	return func(c *ClickHouse) {
+		c.ctx = ctx
+	}
+}
+
+// WithClickHouseLabels sets the labels recorded with every backup.
+func WithClickHouseLabels(labels map[string]string) ClickHouseOption {
+	return func(c *ClickHouse) {
+		c.Labels = labels
+	}
+}
+
+// WithClickHouseAllowOverwrite lets restores replace a target holding data.
+func WithClickHouseAllowOverwrite(allow bool) ClickHouseOption {
+	return func(c *ClickHouse) {
+		c.AllowOverwrite = allow
+	}
+}
+
+// WithClickHouseTimestampFormat overrides timestamp format.
+func WithClickHouseTimestampFormat(format string) ClickHouseOption {
+	return func(c *ClickHouse) {
+		if format != "" {
+			c.TimeStampFmt = format
+		}
+	}
+}
+
+// artifactName renders the configured name template for a backup taken now.
+func (c *ClickHouse) artifactName() (string, error) {
+	now := time.Now()
+	return config.ArtifactName{
+		Engine:    EngineClickHouse,
+		Database:  c.Database,
+		Host:      c.Host,
+		Method:    c.Method,
+		Timestamp: now.Format(c.TimeStampFmt),
+		Time:      now,
+	}.Render(c.NameTemplate)
+}
+
+// Backup dumps the database (or the configured tables) into a .ch.tar
+// archive, or runs BACKUP on the server and records it in a .chbackup.json
+// file (see Method).
+func (c *ClickHouse) Backup(ctx context.Context) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	backupsDir := WorkPath(c.OutputDir, c.WorkDir, c.GetPath())
+	if err := os.MkdirAll(backupsDir, 0o755); err != nil {
+		return "", fmt.Errorf("mkdir %q: %w", backupsDir, err)
+	}
+	name, err := c.artifactName()
+	if err != nil {
+		return "", err
+	}
+
+	c.Logger.Info("backup started",
+		"database", c.Database,
+		"engine", EngineClickHouse,
+		"method", c.Method,
+	)
+	start := time.Now()
+	var backupPath string
+	if c.Method == ClickHouseMethodBackup {
+		backupPath, err = c.serverBackup(ctx, filepath.Join(backupsDir, name))
+	} else {
+		backupPath, err = c.dump(ctx, filepath.Join(backupsDir, name+clickhouseDumpExt))
+	}
+	if err != nil {
+		return backupPath, err
+	}
+	c.Logger.Info("backup completed",
+		"path", backupPath,
+		"duration", time.Since(start).String(),
+	)
+	return backupPath, nil
+}
+
+// dump writes the CREATE statement and the rows of every table into a tar
+// archive at backupPath. Views and dictionaries are not exported.
+func (c *ClickHouse) dump(ctx context.Context, backupPath string) (_ string, err error) {
+	tables, err := c.tables(ctx)
+	if err != nil {
+		return "", err
+	}
+	out, err := os.Create(backupPath)
+	if err != nil {
+		return "", fmt.Errorf("create %q: %w", backupPath, err)
+	}
+	defer func() {
+		if cerr := out.Close(); err == nil && cerr != nil {
+			err = fmt.Errorf("close %q: %w", backupPath, cerr)
+		}
+	}()
+
+	tw := tar.NewWriter(out)
+	archive := clickhouseArchive{Database: c.Database}
+	for i, table := range tables {
+		entry := clickhouseTable{
+			Name:   table,
+			Schema: fmt.Sprintf("schema/%05d.sql", i),
+			Data:   fmt.Sprintf("data/%05d.native", i),
+		}
+		qualified := chIdent(c.Database) + "." + chIdent(table)
+		schema, err := c.clickhouse(ctx, c.Host, "SHOW CREATE TABLE "+qualified)
+		if err != nil {
+			return backupPath, fmt.Errorf("schema of %s: %w", table, err)
+		}
+		if err := writeEntry(tw, entry.Schema, []byte(schema)); err != nil {
+			return backupPath, err
+		}
+		err = spoolEntry(tw, filepath.Dir(backupPath), entry.Data, func(w io.Writer) error {
+			cmd, err := c.client(ctx, c.Host, "SELECT * FROM "+qualified+" FORMAT Native")
+			if err != nil {
+				return err
+			}
+			cmd.Stdout = w
+			return cmd.Run()
+		})
+		if err != nil {
+			return backupPath, fmt.Errorf("export %s: %w", table, err)
+		}
+		archive.Tables = append(archive.Tables, entry)
+	}
+	manifest, err := json.MarshalIndent(archive, "", "  ")
+	if err != nil {
+		return backupPath, err
+	}
+	if err := writeEntry(tw, clickhouseManifest, manifest); err != nil {
+		return backupPath, err
+	}
+	if err := tw.Close(); err != nil {
+		return backupPath, fmt.Errorf("write %q: %w", backupPath, err)
+	}
+	return backupPath, nil
+}
+
+// serverBackup runs BACKUP into the configured backup disk and writes the
+// description of the result to base + ".chbackup.json".
+func (c *ClickHouse) serverBackup(ctx context.Context, base string) (string, error) {
+	if c.BackupDisk == "" {
+		return "", fmt.Errorf("%w: the %s format needs clickhouse.backup_disk",
+			ErrBackupFailed, ClickHouseMethodBackup)
+	}
+	record := clickhouseBackup{
+		Database: c.Database,
+		Tables:   c.Tables,
+		Disk:     c.BackupDisk,
+		Path:     c.Database + "/" + filepath.Base(base) + ".zip",
+	}
+	out, err := c.clickhouse(ctx, c.Host, fmt.Sprintf("BACKUP %s TO %s",
+		backupObjects(record.Database, record.Tables, ""), record.destination()))
+	if err != nil {
+		return "", fmt.Errorf("clickhouse backup failed: %w", err)
+	}
+	id, status, _ := strings.Cut(strings.TrimSpace(out), "\t")
+	if status != "BACKUP_CREATED" {
+		return "", fmt.Errorf("%w: BACKUP ended with status %q", ErrBackupFailed, status)
+	}
+	record.ID = id
+
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	backupPath := base + clickhouseBackupExt
+	if err := os.WriteFile(backupPath, data, 0o644); err != nil {
+		return backupPath, fmt.Errorf("write %q: %w", backupPath, err)
+	}
+	return backupPath, nil
+}
+
+// Restore loads a dump archive or runs RESTORE from the backup disk into the
+// restore target. Tables present in the backup are dropped first; restoring
+// a whole-database server backup drops the target database.
+func (c *ClickHouse) Restore(ctx context.Context, backupFile string) error {
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	if _, err := os.Stat(backupFile); err != nil {
+		return fmt.Errorf("backup file %q not found: %w", backupFile, err)
+	}
+	host, database := c.restoreTarget()
+	c.Logger.Info("restore started",
+		"database", c.Database,
+		"engine", EngineClickHouse,
+		"target_host", host,
+		"target_database", database,
+	)
+	start := time.Now()
+	var err error
+	if strings.HasSuffix(backupFile, clickhouseBackupExt) {
+		err = c.serverRestore(ctx, backupFile)
+	} else {
+		err = c.dumpRestore(ctx, backupFile)
+	}
+	if err != nil {
+		return fmt.Errorf("clickhouse restore failed: %w", err)
+	}
+	c.Logger.Info("restore completed", "duration", time.Since(start).String())
+	return nil
+}
+
+// dumpRestore recreates every table of a dump archive in the restore target
+// and inserts its rows.
+func (c *ClickHouse) dumpRestore(ctx context.Context, backupFile string) error {
+	archive, err := readClickHouseManifest(backupFile)
+	if err != nil {
+		return err
+	}
+	host, database := c.restoreTarget()
+	if _, err := c.clickhouse(ctx, host, "CREATE DATABASE IF NOT EXISTS "+chIdent(database)); err != nil {
+		return err
+	}
+	entries := make(map[string]clickhouseTable)
+	for _, table := range archive.Tables {
+		entries[table.Schema] = table
+		entries[table.Data] = table
+	}
+
+	file, err := os.Open(backupFile)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	tr := tar.NewReader(file)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%w: read %s: %v", ErrInvalidArtifact, backupFile, err)
+		}
+		table, ok := entries[header.Name]
+		if !ok {
+			continue
+		}
+		qualified := chIdent(database) + "." + chIdent(table.Name)
+		if header.Name == table.Schema {
+			schema, err := io.ReadAll(tr)
+			if err != nil {
+				return fmt.Errorf("%w: read %s: %v", ErrInvalidArtifact, header.Name, err)
+			}
+			if _, err := c.clickhouse(ctx, host, "DROP TABLE IF EXISTS "+qualified+" SYNC"); err != nil {
+				return err
+			}
+			create := retargetSchema(string(schema), archive.Database, database)
+			if _, err := c.clickhouse(ctx, host, create); err != nil {
+				return fmt.Errorf("create %s: %w", table.Name, err)
+			}
+			continue
+		}
+		cmd, err := c.client(ctx, host, "INSERT INTO "+qualified+" FORMAT Native")
+		if err != nil {
+			return err
+		}
+		cmd.Stdin = tr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("load %s: %w", table.Name, err)
+		}
+	}
+}
+
+// serverRestore runs RESTORE from the backup disk recorded in backupFile.
+func (c *ClickHouse) serverRestore(ctx context.Context, backupFile string) error {
+	record, err := readClickHouseBackup(backupFile)
+	if err != nil {
+		return err
+	}
+	host, database := c.restoreTarget()
+	drops := []string{"DROP DATABASE IF EXISTS " + chIdent(database) + " SYNC"}
+	if len(record.Tables) > 0 {
+		drops = []string{"CREATE DATABASE IF NOT EXISTS " + chIdent(database)}
+		for _, table := range record.Tables {
+			drops = append(drops, "DROP TABLE IF EXISTS "+chIdent(database)+"."+chIdent(table)+" SYNC")
+		}
+	}
+	for _, sql := range drops {
+		if _, err := c.clickhouse(ctx, host, sql); err != nil {
+			return err
+		}
+	}
+	out, err := c.clickhouse(ctx, host, fmt.Sprintf("RESTORE %s FROM %s",
+		backupObjects(record.Database, record.Tables, database), record.destination()))
+	if err != nil {
+		return err
+	}
+	if _, status, _ := strings.Cut(strings.TrimSpace(out), "\t"); status != "RESTORED" {
+		return fmt.Errorf("%w: RESTORE ended with status %q", ErrRestoreFailed, status)
+	}
+	return nil
+}
+
+// Retarget redirects subsequent restores to another host and/or database.
+func (c *ClickHouse) Retarget(host, database string) {
+	c.RestoreHost = host
+	c.RestoreDatabase = database
+}
+
+// restoreTarget returns the host and database a restore should write to.
+func (c *ClickHouse) restoreTarget() (host, database string) {
+	host, database = c.Host, c.Database
+	if c.RestoreHost != "" {
+		host = c.RestoreHost
+	}
+	if c.RestoreDatabase != "" {
+		database = c.RestoreDatabase
+	}
+	return host, database
+}
+
+// ValidateArtifact checks that a dump archive has a manifest and the schema
+// and data of every listed table, without connecting to a server. For
+// server backups only the local record can be checked.
+func (c *ClickHouse) ValidateArtifact(path string) ([]string, error) {
+	if strings.HasSuffix(path, clickhouseBackupExt) {
+		record, err := readClickHouseBackup(path)
+		if err != nil {
+			return nil, err
+		}
+		return []string{backupObjects(record.Database, record.Tables, "") + " on " + record.destination()}, nil
+	}
+
+	archive, err := readClickHouseManifest(path)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("backup file %q not found: %w", path, err)
+	}
+	defer file.Close()
+	present := make(map[string]bool)
+	tr := tar.NewReader(file)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: read %s: %v", ErrInvalidArtifact, path, err)
+		}
+		present[header.Name] = true
+	}
+	tables := make([]string, 0, len(archive.Tables))
+	for _, table := range archive.Tables {
+		if !present[table.Schema] || !present[table.Data] {
+			return nil, fmt.Errorf("%w: %s is missing entries of %s", ErrInvalidArtifact, path, table.Name)
+		}
+		tables = append(tables, table.Name)
+	}
+	return tables, nil
+}
+
+// PrepareTarget is a no-op: restores create the target database.
+func (c *ClickHouse) PrepareTarget() error { return nil }
+
+// CountObjects returns the row count of every table in the restore target.
+func (c *ClickHouse) CountObjects() (map[string]int64, error) {
+	host, database := c.restoreTarget()
+	out, err := c.query(host, fmt.Sprintf(
+		"SELECT name, total_rows FROM system.tables WHERE database = %s AND total_rows IS NOT NULL",
+		chString(database),
+	))
+	if err != nil {
+		return nil, err
+	}
+	return parseCounts(out)
+}
+
+// TargetObjects returns the tables of the restore target database; none
+// when it does not exist.
+func (c *ClickHouse) TargetObjects() ([]string, error) {
+	host, database := c.restoreTarget()
+	out, err := c.query(host, "SELECT name FROM system.tables WHERE database = "+chString(database))
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(out), nil
+}
+
+// OverwriteAllowed reports whether the instance sets allow_overwrite.
+func (c *ClickHouse) OverwriteAllowed() bool { return c.AllowOverwrite }
+
+// DropTarget drops the restore target database.
+func (c *ClickHouse) DropTarget() error {
+	host, database := c.restoreTarget()
+	if database == c.Database {
+		return fmt.Errorf("%w: refusing to drop source database %q", ErrVerifyFailed, database)
+	}
+	_, err := c.query(host, "DROP DATABASE IF EXISTS "+chIdent(database)+" SYNC")
+	return err
+}
+
+// EstimateSize returns the on-disk size of the active parts of the source
+// tables. Server backups are written to the backup disk, not locally, so
+// they report no estimate.
+func (c *ClickHouse) EstimateSize() (int64, error) {
+	if c.Method == ClickHouseMethodBackup {
+		return 0, nil
+	}
+	sql := "SELECT sum(bytes_on_disk) FROM system.parts WHERE active AND database = " + chString(c.Database)
+	if len(c.Tables) > 0 {
+		names := make([]string, len(c.Tables))
+		for i, table := range c.Tables {
+			names[i] = chString(table)
+		}
+		sql += " AND table IN (" + strings.Join(names, ", ") + ")"
+	}
+	out, err := c.query(c.Host, sql)
+	if err != nil {
+		return 0, err
+	}
+	return parseSize(out)
+}
+
+// ListDatabases returns the databases on the server.
+func (c *ClickHouse) ListDatabases() ([]string, error) {
+	out, err := c.query(c.Host, "SHOW DATABASES")
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(out), nil
+}
+
+// ServerVersion connects with the configured credentials and returns the
+// server version.
+func (c *ClickHouse) ServerVersion() (string, error) {
+	out, err := c.query(c.Host, "SELECT version()")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// tables returns the configured tables, or every table of the database.
+func (c *ClickHouse) tables(ctx context.Context) ([]string, error) {
+	if len(c.Tables) > 0 {
+		return c.Tables, nil
+	}
+	out, err := c.clickhouse(ctx, c.Host, fmt.Sprintf(
+		"SELECT name FROM system.tables WHERE database = %s AND NOT is_temporary "+
+			"AND engine NOT IN ('View', 'MaterializedView', 'LiveView', 'WindowView', 'Dictionary') ORDER BY name",
+		chString(c.Database),
+	))
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(out), nil
+}
+
+// query runs sql on host with the engine timeout.
+func (c *ClickHouse) query(host, sql string) (string, error) {
+	ctx, cancel := context.WithTimeout(orBackground(c.ctx), c.Timeout)
+	defer cancel()
+	return c.clickhouse(ctx, host, sql)
+}
+
+// clickhouse runs sql on host and returns the raw tab-separated output.
+func (c *ClickHouse) clickhouse(ctx context.Context, host, sql string) (string, error) {
+	cmd, err := c.client(ctx, host, sql, "--format", "TabSeparatedRaw")
+	if err != nil {
+		return "", err
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("clickhouse query failed: %w", err)
+	}
+	return string(out), nil
+}
+
+// client returns a clickhouse-client command running sql on host. The
+// password is passed through CLICKHOUSE_PASSWORD rather than the command line.
+func (c *ClickHouse) client(ctx context.Context, host, sql string, args ...string) (*exec.Cmd, error) {
+	args = append([]string{"--host", host, "--port", c.Port, "--query", sql}, args...)
+	if c.Username != "" {
+		args = append(args, "--user", c.Username)
+	}
+	cmd, err := c.command(ctx, []string{"CLICKHOUSE_PASSWORD=" + c.Password}, "clickhouse-client", args...)
+	if err != nil {
+		return nil, err
+	}
+	cmd.Stderr = c.stderr()
+	return cmd, nil
+}
+
+// readClickHouseManifest reads the manifest of a dump archive.
+func readClickHouseManifest(path string) (clickhouseArchive, error) {
+	var archive clickhouseArchive
+	file, err := os.Open(path)
+	if err != nil {
+		return archive, fmt.Errorf("backup file %q not found: %w", path, err)
+	}
+	defer file.Close()
+	tr := tar.NewReader(file)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return archive, fmt.Errorf("%w: %s has no %s", ErrInvalidArtifact, path, clickhouseManifest)
+		}
+		if err != nil {
+			return archive, fmt.Errorf("%w: read %s: %v", ErrInvalidArtifact, path, err)
+		}
+		if header.Name != clickhouseManifest {
+			continue
+		}
+		if err := json.NewDecoder(tr).Decode(&archive); err != nil {
+			return archive, fmt.Errorf("%w: decode %s: %v", ErrInvalidArtifact, clickhouseManifest, err)
+		}
+		return archive, nil
+	}
+}
+
+// readClickHouseBackup reads the record of a server backup.
+func readClickHouseBackup(path string) (clickhouseBackup, error) {
+	var record clickhouseBackup
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return record, fmt.Errorf("backup file %q not found: %w", path, err)
+	}
+	if err := json.Unmarshal(data, &record); err != nil {
+		return record, fmt.Errorf("%w: decode %s: %v", ErrInvalidArtifact, path, err)
+	}
+	if record.Disk == "" || record.Path == "" {
+		return record, fmt.Errorf("%w: %s has no backup location", ErrInvalidArtifact, path)
+	}
+	return record, nil
+}
+
+// spoolEntry adds the output of fill to tw as name. The output is spooled to
+// a hidden file in dir first because tar headers need the size up front.
+func spoolEntry(tw *tar.Writer, dir, name string, fill func(io.Writer) error) error {
+	spool, err := os.CreateTemp(dir, ".spool-*")
+	if err != nil {
+		return fmt.Errorf("create spool file: %w", err)
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+
+	if err := fill(spool); err != nil {
+		return err
+	}
+	size, err := spool.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	header := &tar.Header{Name: name, Mode: 0o644, Size: size, ModTime: time.Now()}
+	if err := tw.WriteHeader(header); err != nil {
+		return fmt.Errorf("write %s: %w", name, err)
+	}
+	if _, err := io.Copy(tw, spool); err != nil {
+		return fmt.Errorf("write %s: %w", name, err)
+	}
+	return nil
+}
+
+// backupObjects returns the BACKUP/RESTORE object list for tables of
+// database (the whole database when tables is empty), renamed to target
+// when it is set.
+func backupObjects(database string, tables []string, target string) string {
+	if len(tables) == 0 {
+		objects := "DATABASE " + chIdent(database)
+		if target != "" && target != database {
+			objects += " AS " + chIdent(target)
+		}
+		return objects
+	}
+	objects := make([]string, len(tables))
+	for i, table := range tables {
+		objects[i] = "TABLE " + chIdent(database) + "." + chIdent(table)
+		if target != "" && target != database {
+			objects[i] += " AS " + chIdent(target) + "." + chIdent(table)
+		}
+	}
+	return strings.Join(objects, ", ")
+}
+
+// retargetSchema rewrites the database of a SHOW CREATE TABLE statement from
+// source to target.
+func retargetSchema(schema, source, target string) string {
+	if source == target {
+		return schema
+	}
+	for _, prefix := range []string{
+		"CREATE TABLE " + source + ".",
+		"CREATE TABLE " + chIdent(source) + ".",
+	} {
+		if rest, ok := strings.CutPrefix(schema, prefix); ok {
+			return "CREATE TABLE " + chIdent(target) + "." + rest
+		}
+	}
+	return schema
+}
+
+// chIdent quotes a ClickHouse identifier.
+func chIdent(name string) string {
+	return "`" + strings.NewReplacer(`\`, `\\`, "`", "\\`").Replace(name) + "`"
+}
+
+// chString quotes a ClickHouse string literal.
+func chString(value string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(value) + "'"
+}
+
+// GetName returns database name.
+func (c *ClickHouse) GetName() string { return c.Database }
+
+// GetEngine returns engine name.
+func (c *ClickHouse) GetEngine() string { return EngineClickHouse }
+
+// GetPath returns the directory holding the artifacts and catalog of the
+// database, <output dir>/<engine>/<backup.dir_template>.
+func (c *ClickHouse) GetPath() string {
+	return artifactDir(c.OutputDir, c.DirTemplate, config.ArtifactDir{
+		Engine:   EngineClickHouse,
+		Instance: c.Instance,
+		Host:     c.Host,
+		Database: c.Database,
+	})
+}
+
+// GetInstance returns the name of the configured instance.
+func (c *ClickHouse) GetInstance() string { return c.Instance }
+
+// ArtifactExt returns the extension of the single-file artifacts of the
+// configured method.
+func (c *ClickHouse) ArtifactExt() (string, bool) {
+	if c.Method == ClickHouseMethodBackup {
+		return "", false
+	}
+	return clickhouseDumpExt, true
+}
+
+// GetHost returns the database host.
+func (c *ClickHouse) GetHost() string { return c.Host }
+
+// GetLabels returns the labels recorded with every backup.
+func (c *ClickHouse) GetLabels() map[string]string { return c.Labels }
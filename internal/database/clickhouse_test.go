@@ -0,0 +1,34 @@
+package database
+
+import "testing"
+
+func TestBackupObjects(t *testing.T) {
+	tests := []struct {
+		tables []string
+		target string
+		want   string
+	}{
+		{nil, "", "DATABASE `db`"},
+		{nil, "db_copy", "DATABASE `db` AS `db_copy`"},
+		{[]string{"a", "b"}, "", "TABLE `db`.`a`, TABLE `db`.`b`"},
+		{[]string{"a"}, "db_copy", "TABLE `db`.`a` AS `db_copy`.`a`"},
+	}
+	for _, tt := range tests {
+		if got := backupObjects("db", tt.tables, tt.target); got != tt.want {
+			t.Errorf("backupObjects(%v, %q) = %q, want %q", tt.tables, tt.target, got, tt.want)
+		}
+	}
+}
+
+func TestRetargetSchema(t *testing.T) {
+	tests := []struct{ schema, want string }{
+		{"CREATE TABLE db.t\n(\n    `id` UInt64\n)", "CREATE TABLE `copy`.t\n(\n    `id` UInt64\n)"},
+		{"CREATE TABLE `db`.`t` (id UInt64)", "CREATE TABLE `copy`.`t` (id UInt64)"},
+		{"CREATE TABLE other.t (id UInt64)", "CREATE TABLE other.t (id UInt64)"},
+	}
+	for _, tt := range tests {
+		if got := retargetSchema(tt.schema, "db", "copy"); got != tt.want {
+			t.Errorf("retargetSchema(%q) = %q, want %q", tt.schema, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,637 @@
+package database
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/kebairia/backup/internal/config"
+	"github.com/kebairia/backup/internal/logger"
+)
+
+const EngineCouchDB = "couchdb"
+
+const (
+	// couchExt is the extension of CouchDB artifacts: a header line, then
+	// one document per line with its revision and inline attachments.
+	couchExt = ".couch.ndjson"
+	// couchBatch is the number of documents sent per _bulk_docs request.
+	couchBatch = 500
+	// couchMaxLine bounds a document line, attachments included.
+	couchMaxLine = 256 << 20
+)
+
+// CouchDBOption lets you override default settings on a CouchDB.
+type CouchDBOption func(*CouchDB)
+
+// CouchDB holds configuration for backing up and restoring a CouchDB
+// database through its HTTP API; no client tools are needed.
+type CouchDB struct {
+	Username     string
+	Password     string
+	Database     string
+	Host         string // host name, or base URL of the HTTP API
+	Port         string
+	OutputDir    string
+	WorkDir      string // dumps are written here first, see WorkPath
+	TimeStampFmt string
+	NameTemplate string // artifact name, see config.ArtifactName
+	Instance     string // instance name, see config.ArtifactDir
+	DirTemplate  string // artifact directory, see config.ArtifactDir
+	Timeout      time.Duration
+	Labels       map[string]string
+	Logger       logger.Logger
+
+	// ctx cancels running requests (see WithCouchDBContext)
+	ctx context.Context
+	// Vault lease of the dynamic credentials (see LeaseHolder)
+	credentialLease
+
+	// Restore target overrides (see Retarget)
+	RestoreHost     string
+	RestoreDatabase string
+}
+
+// couchHeader is the first line of a CouchDB artifact.
+type couchHeader struct {
+	Database string `json:"database"`
+	Server   string `json:"server,omitempty"` // CouchDB version
+}
+
+// NewCouchDB returns a CouchDB configured from cfg plus any overrides.
+func NewCouchDB(cfg config.Config, opts ...CouchDBOption) (*CouchDB, error) {
+	log, err := logger.Init()
+	if err != nil {
+		return nil, fmt.Errorf("logger init failed: %w", err)
+	}
+	c := &CouchDB{
+		Host:         cfg.CouchDB.EngineDefaults.Host,
+		Port:         cfg.CouchDB.EngineDefaults.Port,
+		OutputDir:    cfg.Backup.Directory,
+		WorkDir:      cfg.Backup.WorkDir,
+		TimeStampFmt: cfg.Backup.TimestampFmt,
+		NameTemplate: cfg.Backup.NameTemplate,
+		DirTemplate:  cfg.Backup.DirTemplate,
+		Timeout:      cfg.Backup.Timeout,
+		Logger:       log,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.Logger = logger.FromContext(c.ctx, c.Logger)
+	if c.Port == "" {
+		c.Port = "5984"
+	}
+	return c, nil
+}
+
+// WithCouchDBCredentials sets username and password.
+func WithCouchDBCredentials(user, pass string) CouchDBOption {
+	return func(c *CouchDB) {
+		if user != "" {
+			c.Username = user
+		}
+		if pass != "" {
+			c.Password = pass
+		}
+	}
+}
+
+// WithCouchDBHost overrides the host.
+func WithCouchDBHost(host string) CouchDBOption {
+	return func(c *CouchDB) {
+		if host != "" {
+			c.Host = host
+		}
+	}
+}
+
+// WithCouchDBPort overrides the port.
+func WithCouchDBPort(port string) CouchDBOption {
+	return func(c *CouchDB) {
+		if port != "" {
+			c.Port = port
+		}
+	}
+}
+
+// WithCouchDBDatabase sets the database.
+func WithCouchDBDatabase(db string) CouchDBOption {
+	return func(c *CouchDB) {
+		if db != "" {
+			c.Database = db
+		}
+	}
+}
+
+// WithCouchDBOutputDir overrides where backups are written.
+func WithCouchDBOutputDir(dir string) CouchDBOption {
+	return func(c *CouchDB) {
+		if dir != "" {
+			c.OutputDir = dir
+		}
+	}
+}
+
+// WithCouchDBInstance sets the instance name, available to backup.dir_template.
+func WithCouchDBInstance(name string) CouchDBOption {
+	return func(c *CouchDB) {
+		c.Instance = name
+	}
+}
+
+// WithCouchDBContext makes ctx cancel lookups and the deletion of throwaway
+// databases, e.g. when the run is interrupted.
+func WithCouchDBContext(ctx context.Context) CouchDBOption {
+	return func(c *CouchDB) {
+		c.ctx = ctx
+	}
+}
+
+// WithCouchDBLabels sets the labels recorded with every backup.
+func WithCouchDBLabels(labels map[string]string) CouchDBOption {
+	return func(c *CouchDB) {
+		c.Labels = labels
+	}
+}
+
+// WithCouchDBTimestampFormat overrides timestamp format.
+func WithCouchDBTimestampFormat(format string) CouchDBOption {
+	return func(c *CouchDB) {
+		if format != "" {
+			c.TimeStampFmt = format
+		}
+	}
+}
+
+// artifactName renders the configured name template for a backup taken now.
+func (c *CouchDB) artifactName() (string, error) {
+	now := time.Now()
+	return config.ArtifactName{
+		Engine:    EngineCouchDB,
+		Database:  c.Database,
+		Host:      c.Host,
+		Method:    "all_docs",
+		Timestamp: now.Format(c.TimeStampFmt),
+		Time:      now,
+	}.Render(c.NameTemplate)
+}
+
+// Backup streams every document of the database, with its current revision
+// and inline attachments, from _all_docs into a .couch.ndjson file.
+func (c *CouchDB) Backup(ctx context.Context) (_ string, err error) {
+	ctx, cancel := context.WithTimeoutCause(ctx, c.Timeout, ErrTimeout)
+	defer cancel()
+
+	backupsDir := WorkPath(c.OutputDir, c.WorkDir, c.GetPath())
+	if err := os.MkdirAll(backupsDir, 0o755); err != nil {
+		return "", fmt.Errorf("mkdir %q: %w", backupsDir, err)
+	}
+	name, err := c.artifactName()
+	if err != nil {
+		return "", err
+	}
+	backupPath := filepath.Join(backupsDir, name+couchExt)
+	server, err := c.ServerVersion()
+	if err != nil {
+		return "", err
+	}
+
+	c.Logger.Info("backup started",
+		"database", c.Database,
+		"engine", EngineCouchDB,
+		"path", backupPath,
+	)
+	start := time.Now()
+	resp, err := c.do(ctx, http.MethodGet, c.Host,
+		"/"+url.PathEscape(c.Database)+"/_all_docs?include_docs=true&attachments=true", nil)
+	if err != nil {
+		return "", fmt.Errorf("read _all_docs: %w", err)
+	}
+	defer resp.Body.Close()
+
+	out, err := os.Create(backupPath)
+	if err != nil {
+		return "", fmt.Errorf("create %q: %w", backupPath, err)
+	}
+	defer func() {
+		if cerr := out.Close(); err == nil && cerr != nil {
+			err = fmt.Errorf("close %q: %w", backupPath, cerr)
+		}
+	}()
+	w := bufio.NewWriter(out)
+	if err := writeLine(w, couchHeader{Database: c.Database, Server: server}); err != nil {
+		return backupPath, err
+	}
+	docs, err := copyAllDocs(resp.Body, w)
+	if err != nil {
+		return backupPath, fmt.Errorf("read _all_docs: %w", err)
+	}
+	if err := w.Flush(); err != nil {
+		return backupPath, fmt.Errorf("write %q: %w", backupPath, err)
+	}
+	c.Logger.Info("backup completed",
+		"path", backupPath,
+		"documents", docs,
+		"duration", time.Since(start).String(),
+	)
+	return backupPath, nil
+}
+
+// copyAllDocs writes the doc of every row of an _all_docs response to w,
+// one per line, without holding the response in memory.
+func copyAllDocs(r io.Reader, w io.Writer) (int, error) {
+	dec := json.NewDecoder(r)
+	if _, err := dec.Token(); err != nil { // {
+		return 0, err
+	}
+	docs := 0
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return docs, err
+		}
+		if key != "rows" {
+			var skipped json.RawMessage
+			if err := dec.Decode(&skipped); err != nil {
+				return docs, err
+			}
+			continue
+		}
+		if _, err := dec.Token(); err != nil { // [
+			return docs, err
+		}
+		for dec.More() {
+			var row struct {
+				Doc json.RawMessage `json:"doc"`
+			}
+			if err := dec.Decode(&row); err != nil {
+				return docs, err
+			}
+			if len(row.Doc) == 0 || string(row.Doc) == "null" {
+				continue
+			}
+			var line bytes.Buffer
+			if err := json.Compact(&line, row.Doc); err != nil {
+				return docs, err
+			}
+			line.WriteByte('\n')
+			if _, err := w.Write(line.Bytes()); err != nil {
+				return docs, err
+			}
+			docs++
+		}
+		if _, err := dec.Token(); err != nil { // ]
+			return docs, err
+		}
+	}
+	return docs, nil
+}
+
+// Restore creates the restore target if needed and loads the documents of
+// backupFile into it with _bulk_docs. new_edits=false keeps their revisions,
+// so restoring over an existing database merges the backed-up revisions into
+// the documents' histories instead of conflicting with them.
+func (c *CouchDB) Restore(ctx context.Context, backupFile string) error {
+	ctx, cancel := context.WithTimeoutCause(ctx, c.Timeout, ErrTimeout)
+	defer cancel()
+
+	file, err := os.Open(backupFile)
+	if err != nil {
+		return fmt.Errorf("backup file %q not found: %w", backupFile, err)
+	}
+	defer file.Close()
+	scanner := newCouchScanner(file)
+	if _, err := readCouchHeader(scanner, backupFile); err != nil {
+		return err
+	}
+
+	host, database := c.restoreTarget()
+	c.Logger.Info("restore started",
+		"database", c.Database,
+		"engine", EngineCouchDB,
+		"target_host", host,
+		"target_database", database,
+	)
+	start := time.Now()
+	resp, err := c.do(ctx, http.MethodPut, host, "/"+url.PathEscape(database), nil)
+	if err != nil && !errors.Is(err, errCouchExists) {
+		return fmt.Errorf("create database %q: %w", database, err)
+	}
+	if resp != nil {
+		resp.Body.Close()
+	}
+
+	var batch []json.RawMessage
+	docs := 0
+	for scanner.Scan() {
+		batch = append(batch, json.RawMessage(bytes.Clone(scanner.Bytes())))
+		if len(batch) == couchBatch {
+			if err := c.bulkDocs(ctx, host, database, batch); err != nil {
+				return err
+			}
+			docs += len(batch)
+			batch = batch[:0]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("read %q: %w", backupFile, err)
+	}
+	if len(batch) > 0 {
+		if err := c.bulkDocs(ctx, host, database, batch); err != nil {
+			return err
+		}
+		docs += len(batch)
+	}
+	c.Logger.Info("restore completed",
+		"documents", docs,
+		"duration", time.Since(start).String(),
+	)
+	return nil
+}
+
+// bulkDocs writes docs, with their revisions, into database on host.
+func (c *CouchDB) bulkDocs(ctx context.Context, host, database string, docs []json.RawMessage) error {
+	body, err := json.Marshal(struct {
+		Docs     []json.RawMessage `json:"docs"`
+		NewEdits bool              `json:"new_edits"`
+	}{docs, false})
+	if err != nil {
+		return err
+	}
+	resp, err := c.do(ctx, http.MethodPost, host, "/"+url.PathEscape(database)+"/_bulk_docs", body)
+	if err != nil {
+		return fmt.Errorf("_bulk_docs: %w", err)
+	}
+	defer resp.Body.Close()
+	var results []struct {
+		ID     string `json:"id"`
+		Error  string `json:"error"`
+		Reason string `json:"reason"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&results); err != nil {
+		return fmt.Errorf("decode _bulk_docs response: %w", err)
+	}
+	for _, result := range results {
+		if result.Error != "" {
+			return fmt.Errorf("_bulk_docs: document %q: %s: %s", result.ID, result.Error, result.Reason)
+		}
+	}
+	return nil
+}
+
+// Retarget redirects subsequent restores to another host and/or database.
+func (c *CouchDB) Retarget(host, database string) {
+	c.RestoreHost = host
+	c.RestoreDatabase = database
+}
+
+// restoreTarget returns the host and database a restore should write to.
+func (c *CouchDB) restoreTarget() (host, database string) {
+	host, database = c.Host, c.Database
+	if c.RestoreHost != "" {
+		host = c.RestoreHost
+	}
+	if c.RestoreDatabase != "" {
+		database = c.RestoreDatabase
+	}
+	return host, database
+}
+
+// ValidateArtifact checks that every line of an artifact is a document with
+// an id and a revision, without connecting to a server, and returns the ids.
+func (c *CouchDB) ValidateArtifact(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("backup file %q not found: %w", path, err)
+	}
+	defer file.Close()
+	scanner := newCouchScanner(file)
+	if _, err := readCouchHeader(scanner, path); err != nil {
+		return nil, err
+	}
+	var ids []string
+	for line := 2; scanner.Scan(); line++ {
+		var doc struct {
+			ID  string `json:"_id"`
+			Rev string `json:"_rev"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &doc); err != nil || doc.ID == "" || doc.Rev == "" {
+			return nil, fmt.Errorf("%w: %s line %d is not a document", ErrInvalidArtifact, path, line)
+		}
+		ids = append(ids, doc.ID)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%w: read %s: %v", ErrInvalidArtifact, path, err)
+	}
+	return ids, nil
+}
+
+// PrepareTarget is a no-op: restores create the target database.
+func (c *CouchDB) PrepareTarget() error { return nil }
+
+// CountObjects returns the document count of the restore target.
+func (c *CouchDB) CountObjects() (map[string]int64, error) {
+	host, database := c.restoreTarget()
+	info, err := c.info(host, database)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]int64{database: info.DocCount}, nil
+}
+
+// DropTarget deletes the restore target database.
+func (c *CouchDB) DropTarget() error {
+	host, database := c.restoreTarget()
+	if database == c.Database {
+		return fmt.Errorf("%w: refusing to drop source database %q", ErrVerifyFailed, database)
+	}
+	resp, err := c.do(orBackground(c.ctx), http.MethodDelete, host, "/"+url.PathEscape(database), nil)
+	if err != nil {
+		return fmt.Errorf("delete database %q: %w", database, err)
+	}
+	return resp.Body.Close()
+}
+
+// EstimateSize returns the uncompressed size of the documents and
+// attachments of the source database.
+func (c *CouchDB) EstimateSize() (int64, error) {
+	info, err := c.info(c.Host, c.Database)
+	if err != nil {
+		return 0, err
+	}
+	return info.Sizes.External, nil
+}
+
+// ListDatabases returns the databases on the server.
+func (c *CouchDB) ListDatabases() ([]string, error) {
+	var names []string
+	if err := c.get(c.Host, "/_all_dbs", &names); err != nil {
+		return nil, err
+	}
+	return names, nil
+}
+
+// ServerVersion connects with the configured credentials and returns the
+// server version.
+func (c *CouchDB) ServerVersion() (string, error) {
+	var welcome struct {
+		Version string `json:"version"`
+	}
+	if err := c.get(c.Host, "/", &welcome); err != nil {
+		return "", err
+	}
+	return welcome.Version, nil
+}
+
+// couchInfo is the part of GET /{db} used by bacli.
+type couchInfo struct {
+	DocCount int64 `json:"doc_count"`
+	Sizes    struct {
+		External int64 `json:"external"`
+	} `json:"sizes"`
+}
+
+// info returns the information of database on host.
+func (c *CouchDB) info(host, database string) (couchInfo, error) {
+	var info couchInfo
+	err := c.get(host, "/"+url.PathEscape(database), &info)
+	return info, err
+}
+
+// get decodes the JSON response of GET path on host into v.
+func (c *CouchDB) get(host, path string, v any) error {
+	ctx, cancel := context.WithTimeoutCause(orBackground(c.ctx), c.Timeout, ErrTimeout)
+	defer cancel()
+	resp, err := c.do(ctx, http.MethodGet, host, path, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("decode %s: %w", path, err)
+	}
+	return nil
+}
+
+// errCouchExists is returned by do for 412 Precondition Failed, which
+// CouchDB answers to PUT /{db} when the database exists.
+var errCouchExists = errors.New("database exists")
+
+// do sends a request with basic credentials to the HTTP API of host and
+// returns the response of a successful one; the caller closes its body.
+func (c *CouchDB) do(ctx context.Context, method, host, path string, body []byte) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL(host)+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.Username != "" {
+		req.SetBasicAuth(c.Username, c.Password)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 == 2 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusPreconditionFailed {
+		return nil, errCouchExists
+	}
+	var reply struct {
+		Error  string `json:"error"`
+		Reason string `json:"reason"`
+	}
+	if json.NewDecoder(resp.Body).Decode(&reply) == nil && reply.Reason != "" {
+		return nil, fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, reply.Reason)
+	}
+	return nil, fmt.Errorf("%s %s: %s", method, path, resp.Status)
+}
+
+// baseURL returns the HTTP API URL of host: host itself when it has a
+// scheme, else http://host:port.
+func (c *CouchDB) baseURL(host string) string {
+	if strings.Contains(host, "://") {
+		return strings.TrimRight(host, "/")
+	}
+	return "http://" + host + ":" + c.Port
+}
+
+// newCouchScanner returns a scanner over the lines of an artifact.
+func newCouchScanner(r io.Reader) *bufio.Scanner {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64<<10), couchMaxLine)
+	return scanner
+}
+
+// readCouchHeader reads the header line of the artifact at path.
+func readCouchHeader(scanner *bufio.Scanner, path string) (couchHeader, error) {
+	var header couchHeader
+	if !scanner.Scan() {
+		return header, fmt.Errorf("%w: %s is empty", ErrInvalidArtifact, path)
+	}
+	if err := json.Unmarshal(scanner.Bytes(), &header); err != nil || header.Database == "" {
+		return header, fmt.Errorf("%w: %s has no CouchDB header", ErrInvalidArtifact, path)
+	}
+	return header, nil
+}
+
+// writeLine writes v as a line of JSON.
+func writeLine(w io.Writer, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// GetName returns the database name.
+func (c *CouchDB) GetName() string { return c.Database }
+
+// GetEngine returns engine name.
+func (c *CouchDB) GetEngine() string { return EngineCouchDB }
+
+// GetPath returns the directory holding the artifacts and catalog of the
+// database, <output dir>/<engine>/<backup.dir_template>.
+func (c *CouchDB) GetPath() string {
+	return artifactDir(c.OutputDir, c.DirTemplate, config.ArtifactDir{
+		Engine:   EngineCouchDB,
+		Instance: c.Instance,
+		Host:     c.Host,
+		Database: c.Database,
+	})
+}
+
+// GetInstance returns the name of the configured instance.
+func (c *CouchDB) GetInstance() string { return c.Instance }
+
+// ArtifactExt returns the extension of the single-file artifacts.
+func (c *CouchDB) ArtifactExt() (string, bool) {
+	return couchExt, true
+}
+
+// GetHost returns the database host.
+func (c *CouchDB) GetHost() string { return c.Host }
+
+// GetLabels returns the labels recorded with every backup.
+func (c *CouchDB) GetLabels() map[string]string { return c.Labels }
@@ -0,0 +1,89 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kebairia/backup/internal/config"
+)
+
+// fakeCouch serves the CouchDB endpoints used by the engine from memory.
+type fakeCouch struct {
+	mu  sync.Mutex
+	dbs map[string][]json.RawMessage
+}
+
+func (f *fakeCouch) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case r.URL.Path == "/":
+		_, _ = w.Write([]byte(`{"couchdb":"Welcome","version":"3.3.3"}`))
+	case r.URL.Path == "/orders/_all_docs":
+		rows := []map[string]json.RawMessage{}
+		for _, doc := range f.dbs["orders"] {
+			rows = append(rows, map[string]json.RawMessage{"doc": doc})
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"total_rows": len(rows), "offset": 0, "rows": rows})
+	case r.URL.Path == "/orders_copy" && r.Method == http.MethodPut:
+		f.dbs["orders_copy"] = nil
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	case r.URL.Path == "/orders_copy/_bulk_docs":
+		var body struct {
+			Docs     []json.RawMessage `json:"docs"`
+			NewEdits *bool             `json:"new_edits"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.NewEdits == nil || *body.NewEdits {
+			http.Error(w, `{"error":"bad_request","reason":"new_edits must be false"}`, http.StatusBadRequest)
+			return
+		}
+		f.dbs["orders_copy"] = append(f.dbs["orders_copy"], body.Docs...)
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`[]`))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestCouchDBBackupRestore(t *testing.T) {
+	couch := &fakeCouch{dbs: map[string][]json.RawMessage{"orders": {
+		json.RawMessage(`{"_id":"a","_rev":"1-x","total":3}`),
+		json.RawMessage(`{"_id":"_design/reports","_rev":"2-y","views":{}}`),
+	}}}
+	server := httptest.NewServer(couch)
+	defer server.Close()
+
+	var cfg config.Config
+	cfg.Backup.Directory = t.TempDir()
+	cfg.Backup.Timeout = time.Minute
+	c, err := NewCouchDB(cfg, WithCouchDBHost(server.URL), WithCouchDBDatabase("orders"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	backupPath, err := c.Backup(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids, err := c.ValidateArtifact(backupPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a", "_design/reports"}; !slices.Equal(ids, want) {
+		t.Errorf("ValidateArtifact() = %v, want %v", ids, want)
+	}
+
+	c.Retarget("", "orders_copy")
+	if err := c.Restore(context.Background(), backupPath); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(couch.dbs["orders_copy"]); got != 2 {
+		t.Errorf("restored %d documents, want 2", got)
+	}
+}
@@ -0,0 +1,162 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/kebairia/backup/internal/config"
+	"github.com/kebairia/backup/internal/redact"
+	"github.com/kebairia/backup/internal/vault"
+)
+
+// ErrNoCredentials indicates that an instance has neither static credentials
+// nor a Vault client to request dynamic ones from.
+var ErrNoCredentials = errors.New("no credentials configured")
+
+// CredentialSource issues dynamic database credentials and reads static
+// secrets such as API tokens. It is implemented by *vault.Client; tests
+// substitute fakes.
+type CredentialSource interface {
+	GetDynamicCredentials(ctx context.Context, role string) (vault.DynamicCredentials, error)
+	Prefetch(ctx context.Context, roles []string, concurrency int) error
+	GetSecret(ctx context.Context, path string) (map[string]any, error)
+}
+
+// credentials returns the username and password for instance, and the Vault
+// lease of dynamic credentials. Instances with a username use their static
+// credentials, without a lease; the others get dynamic credentials from the
+// Vault role at credsPath/role. The password is registered for redaction
+// from logs and records (see redact).
+func credentials(
+	ctx context.Context,
+	vaultClient CredentialSource,
+	instance config.DBInstance,
+	credsPath, role string,
+) (username, password string, lease credentialLease, err error) {
+	defer func() { redact.Register(password) }()
+	if instance.Username != "" {
+		password, err := staticPassword(instance)
+		if err != nil {
+			return "", "", lease, err
+		}
+		return instance.Username, password, lease, nil
+	}
+	if vaultClient == nil {
+		return "", "", lease, fmt.Errorf("%w: instance %q has no username and Vault is not configured",
+			ErrNoCredentials, instance.Name)
+	}
+	creds, err := vaultClient.GetDynamicCredentials(ctx, path.Join(credsPath, role))
+	if err != nil {
+		return "", "", lease, fmt.Errorf("vault read: %w", err)
+	}
+	return creds.Username, creds.Password, credentialLease{id: creds.LeaseID}, nil
+}
+
+// credentialLease is embedded by engines to implement LeaseHolder.
+type credentialLease struct {
+	id string
+}
+
+// GetLeaseID returns the Vault lease of the dynamic credentials, or "" for
+// static credentials.
+func (l credentialLease) GetLeaseID() string { return l.id }
+
+// influxToken returns the API token of an InfluxDB 2.x instance: its static
+// password when one is set, else the "token" key of the KV secret at
+// tokenPath. The token is registered for redaction.
+func influxToken(
+	ctx context.Context,
+	vaultClient CredentialSource,
+	instance config.DBInstance,
+	tokenPath string,
+) (token string, err error) {
+	defer func() { redact.Register(token) }()
+	if token, err = staticPassword(instance); err != nil || token != "" {
+		return token, err
+	}
+	if tokenPath == "" {
+		return "", nil // e.g. a 1.x server without authentication
+	}
+	if vaultClient == nil {
+		return "", fmt.Errorf("%w: instance %q has a token path and Vault is not configured",
+			ErrNoCredentials, instance.Name)
+	}
+	secret, err := vaultClient.GetSecret(ctx, tokenPath)
+	if err != nil {
+		return "", fmt.Errorf("vault read: %w", err)
+	}
+	token, _ = secret["token"].(string)
+	if token == "" {
+		return "", fmt.Errorf("%w: no token at %s", ErrNoCredentials, tokenPath)
+	}
+	return token, nil
+}
+
+// prefetchCredentials requests the dynamic credentials of every instance
+// using Vault, vault.credentials.prefetch at a time, so that the
+// initializers find them ready instead of waiting for each in turn.
+func prefetchCredentials(ctx context.Context, cfg config.Config, vaultClient CredentialSource) error {
+	concurrency := cfg.Vault.Credentials.Prefetch
+	if vaultClient == nil || concurrency < 2 {
+		return nil
+	}
+	var roles []string
+	roles = append(roles, dynamicRoles(cfg.Postgres, cfg.Postgres.Role)...)
+	roles = append(roles, dynamicRoles(cfg.MongoDB, "")...)
+	roles = append(roles, dynamicRoles(cfg.MySQL, cfg.MySQL.Role)...)
+	roles = append(roles, dynamicRoles(cfg.ClickHouse, cfg.ClickHouse.Role)...)
+	roles = append(roles, dynamicRoles(cfg.Cassandra, cfg.Cassandra.Role)...)
+	roles = append(roles, dynamicRoles(cfg.CouchDB, cfg.CouchDB.Role)...)
+	if err := vaultClient.Prefetch(ctx, roles, concurrency); err != nil {
+		return fmt.Errorf("prefetch credentials: %w", err)
+	}
+	return nil
+}
+
+// dynamicRoles returns the Vault role path of each enabled instance of group
+// without static credentials, as credentials requests it; fallback is the
+// group's default role.
+func dynamicRoles(group config.DBGroupConfig, fallback string) []string {
+	if !group.IsEnabled() {
+		return nil
+	}
+	var roles []string
+	for _, instance := range group.Instances {
+		if instance.Username != "" || !instance.IsEnabled() {
+			continue
+		}
+		role := instance.Role
+		if role == "" {
+			role = fallback
+		}
+		roles = append(roles, path.Join(group.Vault.CredsPath, role))
+	}
+	return roles
+}
+
+// staticPassword reads the password of instance from the first source set:
+// password, password_file or password_env.
+func staticPassword(instance config.DBInstance) (string, error) {
+	switch {
+	case instance.Password != "":
+		return instance.Password, nil
+	case instance.PasswordFile != "":
+		data, err := os.ReadFile(instance.PasswordFile)
+		if err != nil {
+			return "", fmt.Errorf("read password file of %q: %w", instance.Name, err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	case instance.PasswordEnv != "":
+		password, ok := os.LookupEnv(instance.PasswordEnv)
+		if !ok {
+			return "", fmt.Errorf("%w: $%s for %q is not set", ErrNoCredentials, instance.PasswordEnv, instance.Name)
+		}
+		return password, nil
+	default:
+		return "", nil // e.g. trust or peer authentication
+	}
+}
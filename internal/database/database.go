@@ -1,18 +1,190 @@
 package database
 
-import "errors"
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
 
 var (
+	ErrVerifyFailed             = errors.New("verification failed")
+	ErrInvalidArtifact          = errors.New("invalid backup artifact")
 	ErrTimeout                  = errors.New("operation timed out")
 	ErrBackupFailed             = errors.New("backup failed")
 	ErrRestoreFailed            = errors.New("restore failed")
 	ErrUnsupportedRestoreMethod = errors.New("unsupported restore method")
+	ErrMissingExtension         = errors.New("missing extension")
+	ErrNotPipeable              = errors.New("method cannot be piped")
 )
 
+// Database is one database that bacli backs up and restores.
+//
+// Backup and Restore run under the context they are given. The other calls
+// engines take part in (streams, checks, verification, size estimates) run
+// under the context set by the engine's With*Context option, and are not
+// cancelled when none was set.
 type Database interface {
 	GetName() string
 	GetEngine() string
+	GetHost() string
+	// GetPath returns the directory holding the database's artifacts,
+	// metadata and history (see config.ArtifactDir).
 	GetPath() string
-	Backup() (backupPath string, err error)
-	Restore(filename string) error
+	// Backup returns the artifact path. On failure the path of any partial
+	// artifact is still returned so callers can clean it up. Cancelling ctx
+	// stops the dump; the engine's timeout applies within it.
+	Backup(ctx context.Context) (backupPath string, err error)
+	// Restore restores the artifact at filename, stopping when ctx is
+	// cancelled; the engine's timeout applies within it.
+	Restore(ctx context.Context, filename string) error
+}
+
+// Retargeter is implemented by engines that can restore into a database or
+// host other than the one the backup was taken from.
+type Retargeter interface {
+	Retarget(host, database string)
+}
+
+// Verifier is implemented by engines that can prepare a throwaway restore
+// target, count the objects restored into it, and drop it afterwards.
+type Verifier interface {
+	Retargeter
+	PrepareTarget() error
+	CountObjects() (map[string]int64, error)
+	DropTarget() error
+}
+
+// OverwriteGuard is implemented by engines whose restore replaces the objects
+// already in the target (pg_restore -c, mongorestore --drop, the DROP TABLE
+// of mysqldump and ClickHouse restores, Cassandra's TRUNCATE), so restores
+// into a target holding data can be refused unless allowed. CouchDB restores
+// merge revisions and InfluxDB refuses an existing bucket, so neither needs
+// one.
+type OverwriteGuard interface {
+	// TargetObjects returns the user tables or collections in the restore
+	// target; none when it is empty or does not exist.
+	TargetObjects() ([]string, error)
+	// OverwriteAllowed reports whether the instance sets allow_overwrite.
+	OverwriteAllowed() bool
+}
+
+// StderrSetter is implemented by engines that can route the stderr of the
+// client tools they run, e.g. into a buffer kept for diagnostics. A nil
+// writer restores the default, the process stderr.
+type StderrSetter interface {
+	SetStderr(w io.Writer)
+}
+
+// Validator is implemented by engines that can check an artifact without
+// touching any database, e.g. by listing its table of contents.
+// It returns the objects (tables, collections, TOC entries) found.
+type Validator interface {
+	ValidateArtifact(path string) ([]string, error)
+}
+
+// SizeEstimator is implemented by engines that can report the approximate
+// size of the source database before it is dumped.
+type SizeEstimator interface {
+	EstimateSize() (int64, error)
+}
+
+// BackupChecker is implemented by engines that can check a fresh artifact
+// with their own tooling right after it is written, e.g. a dry-run restore.
+// CheckBackup returns the check performed, or "" when no check applies to
+// the configured method.
+type BackupChecker interface {
+	CheckBackup(path string) (check string, err error)
+}
+
+// ServerVersioner is implemented by engines that can connect to their server
+// with the configured credentials and report its version.
+type ServerVersioner interface {
+	ServerVersion() (string, error)
+}
+
+// ToolUser is implemented by engines that shell out to client tools. It
+// names the tools a backup and a restore of the configured method run, ""
+// when none does (e.g. the PostgreSQL native method).
+type ToolUser interface {
+	DumpTool() string
+	RestoreTool() string
+}
+
+// ToolLocator is implemented by engines whose client tools may run away
+// from the backup host (see CommandRunner): LookupTool reports the tool
+// where they run.
+type ToolLocator interface {
+	LookupTool(name string) (Tool, error)
+}
+
+// Lister is implemented by engines that can enumerate the databases on their
+// server, for instances configured with config.AllDatabases.
+type Lister interface {
+	ListDatabases() ([]string, error)
+}
+
+// Labeler is implemented by engines that carry user-defined labels (e.g.
+// env, team, compliance tier) to be recorded with their backups.
+type Labeler interface {
+	GetLabels() map[string]string
+}
+
+// Instancer is implemented by engines that know the name of the configured
+// instance they belong to.
+type Instancer interface {
+	GetInstance() string
+}
+
+// LeaseHolder is implemented by engines whose dynamic credentials come with
+// a Vault lease, revoked once the database is backed up.
+type LeaseHolder interface {
+	GetLeaseID() string
+}
+
+// ClusterScoped is implemented by artifacts of a whole instance rather than
+// of one database, such as Postgres roles and tablespaces, which restores
+// replay before the databases of the instance.
+type ClusterScoped interface {
+	ClusterScoped() bool
+}
+
+// Streamer is implemented by engines whose artifacts can be piped out of
+// and back into bacli (see `bacli backup --stdout`). ArtifactExt returns the
+// extension the engine gives, and expects on restore, to the artifacts of
+// its configured method; ok is false when the method writes a directory or
+// a server-side backup rather than a single file.
+type Streamer interface {
+	ArtifactExt() (ext string, ok bool)
+}
+
+// SQLDumper is implemented by engines whose artifacts may be plain SQL
+// scripts. PlainSQL reports whether the configured method writes one, which
+// restores can rewrite (see config.TransformConfig).
+type SQLDumper interface {
+	PlainSQL() bool
+}
+
+// Piper is implemented by engines that can dump into a writer and restore
+// from a reader, so that a database is copied to another instance without
+// staging the dump on disk (see `bacli migrate --no-disk`). Methods that
+// cannot be piped return ErrNotPipeable.
+type Piper interface {
+	DumpTo(w io.Writer) error
+	RestoreFrom(r io.Reader) error
+}
+
+// Incrementer is implemented by engines that can take incremental backups
+// of the changes made since an earlier backup, such as MongoDB oplog
+// captures, and replay them up to a point in time.
+type Incrementer interface {
+	// IncrementalSupported reports whether the configured method allows
+	// incremental backups.
+	IncrementalSupported() bool
+	// BackupIncremental returns the path of an artifact holding the
+	// changes made since since, the start of the backup it applies on.
+	BackupIncremental(ctx context.Context, since time.Time) (backupPath string, err error)
+	// RestoreIncremental replays the artifact at path over a restored
+	// backup, stopping before until when it is not zero.
+	RestoreIncremental(ctx context.Context, path string, until time.Time) error
 }
@@ -0,0 +1,283 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/kebairia/backup/internal/config"
+)
+
+// ErrExecUnsupported indicates a method that cannot run through a remote
+// exec driver, e.g. one writing a directory on the database host.
+var ErrExecUnsupported = errors.New("not supported by the exec driver")
+
+// CommandRunner builds the commands running an engine's client tools. The
+// local executor runs them on the backup host; remote executors run them
+// inside the database's pod or container, out of reach of the backup host's
+// files, so engines stream artifacts over stdin and stdout there (see
+// Remote).
+type CommandRunner interface {
+	// Command returns a command running name with args, with env
+	// ("KEY=value") added to its environment.
+	Command(ctx context.Context, env []string, name string, args ...string) (*exec.Cmd, error)
+	// Remote reports whether the tools run away from the backup host.
+	Remote() bool
+}
+
+// NewCommandRunner returns the executor of an instance's exec settings.
+func NewCommandRunner(cfg config.ExecConfig) (CommandRunner, error) {
+	switch cfg.Type {
+	case "", config.ExecLocal:
+		return localExecutor{}, nil
+	case config.ExecKubernetes:
+		if (cfg.Pod == "") == (cfg.Selector == "") {
+			return nil, errors.New("kubernetes exec: set one of pod and selector")
+		}
+		return &Kubernetes{
+			Namespace:  cfg.Namespace,
+			Pod:        cfg.Pod,
+			Selector:   cfg.Selector,
+			Container:  cfg.Container,
+			Context:    cfg.Context,
+			Kubeconfig: cfg.Kubeconfig,
+		}, nil
+	case config.ExecDocker:
+		if cfg.Container == "" {
+			return nil, errors.New("docker exec: no container")
+		}
+		return &Docker{Container: cfg.Container, Context: cfg.Context}, nil
+	}
+	return nil, fmt.Errorf("unknown exec driver %q", cfg.Type)
+}
+
+// toolExec is embedded by engines running their client tools through an
+// CommandRunner; the zero value runs them locally.
+type toolExec struct {
+	executor CommandRunner
+}
+
+// command returns a command running the client tool name.
+func (t *toolExec) command(ctx context.Context, env []string, name string, args ...string) (*exec.Cmd, error) {
+	if t.executor == nil {
+		return localExecutor{}.Command(ctx, env, name, args...)
+	}
+	return t.executor.Command(ctx, env, name, args...)
+}
+
+// remote reports whether client tools run away from the backup host.
+func (t *toolExec) remote() bool {
+	return t.executor != nil && t.executor.Remote()
+}
+
+// LookupTool reports the client tool name where the engine runs it. Tools
+// of remote executors have no path on the backup host.
+func (t *toolExec) LookupTool(name string) (Tool, error) {
+	if !t.remote() {
+		return LookupTool(name)
+	}
+	tool := Tool{Name: name}
+	ctx, cancel := context.WithTimeoutCause(context.Background(), toolVersionTimeout, ErrTimeout)
+	defer cancel()
+	cmd, err := t.command(ctx, nil, name, "--version")
+	if err != nil {
+		return tool, err
+	}
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return tool, fmt.Errorf("%s --version: %w", name, err)
+	}
+	tool.Version = versionPattern.FindString(string(out))
+	return tool, nil
+}
+
+// secretFile writes content to a temporary file only its owner can read
+// where the client tools run: in the pod or container of remote executors,
+// through the tool's stdin, and on the backup host otherwise (see
+// secretFile). Call the returned func once the command ran.
+func (t *toolExec) secretFile(ctx context.Context, pattern, content string) (string, func(), error) {
+	if !t.remote() {
+		return secretFile(pattern, content)
+	}
+	cmd, err := t.command(ctx, nil, "sh", "-c", writeSecretScript)
+	if err != nil {
+		return "", nil, err
+	}
+	path, err := writeRemoteSecret(cmd, content)
+	if err != nil {
+		return "", nil, err
+	}
+	remove := func() {
+		if cmd, err := t.command(context.WithoutCancel(ctx), nil, "rm", "-f", path); err == nil {
+			_ = cmd.Run()
+		}
+	}
+	return path, remove, nil
+}
+
+// writeSecretScript copies stdin into a new file only its owner can read
+// and prints the file's path.
+const writeSecretScript = `umask 077 && f=$(mktemp) && cat > "$f" && echo "$f"`
+
+// writeRemoteSecret runs cmd, a shell running writeSecretScript away from
+// the backup host, with content on its stdin, and returns the path of the
+// file written.
+func writeRemoteSecret(cmd *exec.Cmd, content string) (string, error) {
+	var stderr strings.Builder
+	cmd.Stdin = strings.NewReader(content)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("write credentials file: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	path := strings.TrimSpace(string(out))
+	if path == "" {
+		return "", errors.New("write credentials file: no path printed")
+	}
+	return path, nil
+}
+
+// shellQuote quotes s for a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// localExecutor runs tools on the backup host.
+type localExecutor struct{}
+
+func (localExecutor) Command(ctx context.Context, env []string, name string, args ...string) (*exec.Cmd, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	return cmd, nil
+}
+
+func (localExecutor) Remote() bool { return false }
+
+// Kubernetes runs tools with `kubectl exec` in a container of the database
+// pod. kubectl cannot pass an environment, so it is streamed over stdin into
+// a file in the container that the tool's shell reads and removes before
+// running it; values never show on the kubectl command line.
+type Kubernetes struct {
+	Namespace  string
+	Pod        string // pod name, or empty to pick one with Selector
+	Selector   string // label selector; the first running pod is used
+	Container  string // empty for the pod's default container
+	Context    string
+	Kubeconfig string
+}
+
+// Command returns `kubectl exec -i <pod> -- name args...`. With env, the
+// environment is first written to a file in the container (see
+// writeRemoteSecret) and the command becomes
+// `sh -c '<source and remove file>; exec "$@"' <file> name args...`.
+func (k *Kubernetes) Command(ctx context.Context, env []string, name string, args ...string) (*exec.Cmd, error) {
+	pod, err := k.pod(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(env) > 0 {
+		var b strings.Builder
+		for _, kv := range env {
+			key, value, _ := strings.Cut(kv, "=")
+			fmt.Fprintf(&b, "%s=%s\n", key, shellQuote(value))
+		}
+		path, err := writeRemoteSecret(k.exec(ctx, pod, "sh", "-c", writeSecretScript), b.String())
+		if err != nil {
+			return nil, err
+		}
+		args = append([]string{"-c", sourceEnvScript, path, name}, args...)
+		name = "sh"
+	}
+	return k.exec(ctx, pod, name, args...), nil
+}
+
+// sourceEnvScript exports the variables of the file $0, removes it and runs
+// the remaining arguments.
+const sourceEnvScript = `set -a && . "$0" && rm -f "$0" && set +a && exec "$@"`
+
+// exec returns `kubectl exec -i <pod> -- name args...`.
+func (k *Kubernetes) exec(ctx context.Context, pod, name string, args ...string) *exec.Cmd {
+	kargs := append(k.globalArgs(), "exec", "-i", pod)
+	if k.Container != "" {
+		kargs = append(kargs, "-c", k.Container)
+	}
+	kargs = append(append(kargs, "--", name), args...)
+	return exec.CommandContext(ctx, "kubectl", kargs...)
+}
+
+func (k *Kubernetes) Remote() bool { return true }
+
+// globalArgs returns the kubectl flags selecting the cluster and namespace.
+func (k *Kubernetes) globalArgs() []string {
+	var args []string
+	if k.Kubeconfig != "" {
+		args = append(args, "--kubeconfig="+k.Kubeconfig)
+	}
+	if k.Context != "" {
+		args = append(args, "--context="+k.Context)
+	}
+	if k.Namespace != "" {
+		args = append(args, "--namespace="+k.Namespace)
+	}
+	return args
+}
+
+// pod returns the configured pod, or the first running pod matching the
+// selector. It is looked up for every command, as pods are replaced.
+func (k *Kubernetes) pod(ctx context.Context) (string, error) {
+	if k.Pod != "" {
+		return k.Pod, nil
+	}
+	args := append(k.globalArgs(), "get", "pods",
+		"--selector="+k.Selector,
+		"--field-selector=status.phase=Running",
+		"--output=jsonpath={.items[*].metadata.name}",
+	)
+	var stderr strings.Builder
+	cmd := exec.CommandContext(ctx, "kubectl", args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("kubectl get pods: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	pods := strings.Fields(string(out))
+	if len(pods) == 0 {
+		return "", fmt.Errorf("no running pod matches selector %q", k.Selector)
+	}
+	return pods[0], nil
+}
+
+// Docker runs tools with `docker exec` in a running container. The
+// environment is named on the command line and its values are passed
+// through the docker client's own environment, so they do not show in the
+// process list.
+type Docker struct {
+	Container string
+	Context   string // empty for docker's current context
+}
+
+// Command returns `docker exec -i [-e KEY...] <container> name args...`.
+func (d *Docker) Command(ctx context.Context, env []string, name string, args ...string) (*exec.Cmd, error) {
+	var dargs []string
+	if d.Context != "" {
+		dargs = append(dargs, "--context="+d.Context)
+	}
+	dargs = append(dargs, "exec", "-i")
+	for _, kv := range env {
+		key, _, _ := strings.Cut(kv, "=")
+		dargs = append(dargs, "-e", key)
+	}
+	dargs = append(append(dargs, d.Container, name), args...)
+	cmd := exec.CommandContext(ctx, "docker", dargs...)
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	return cmd, nil
+}
+
+func (d *Docker) Remote() bool { return true }
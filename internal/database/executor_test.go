@@ -0,0 +1,89 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/kebairia/backup/internal/config"
+)
+
+func TestKubernetesCommand(t *testing.T) {
+	// a kubectl that keeps its stdin and prints where it was written
+	dir := t.TempDir()
+	stdin := filepath.Join(dir, "stdin")
+	script := "#!/bin/sh\ncat > " + stdin + "\necho /tmp/tmp.env\n"
+	if err := os.WriteFile(filepath.Join(dir, "kubectl"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	k := &Kubernetes{Namespace: "billing", Pod: "billing-db-0", Container: "postgres"}
+	cmd, err := k.Command(context.Background(), []string{"PGPASSWORD=it's secret"}, "pg_dump", "-d", "billing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"kubectl", "--namespace=billing", "exec", "-i", "billing-db-0", "-c", "postgres",
+		"--", "sh", "-c", sourceEnvScript, "/tmp/tmp.env", "pg_dump", "-d", "billing"}
+	if !slices.Equal(cmd.Args, want) {
+		t.Errorf("args = %q, want %q", cmd.Args, want)
+	}
+	for _, arg := range cmd.Args {
+		if strings.Contains(arg, "secret") {
+			t.Errorf("the password shows on the kubectl command line: %q", arg)
+		}
+	}
+	written, err := os.ReadFile(stdin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(written) != `PGPASSWORD='it'\''s secret'`+"\n" {
+		t.Errorf("environment file = %q", written)
+	}
+}
+
+func TestDockerCommand(t *testing.T) {
+	d := &Docker{Container: "mysql-shop"}
+	cmd, err := d.Command(context.Background(), []string{"MYSQL_PWD=secret"}, "mysqldump", "shop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"docker", "exec", "-i", "-e", "MYSQL_PWD", "mysql-shop", "mysqldump", "shop"}
+	if !slices.Equal(cmd.Args, want) {
+		t.Errorf("args = %q, want %q", cmd.Args, want)
+	}
+	for _, arg := range cmd.Args {
+		if strings.Contains(arg, "secret") {
+			t.Errorf("the password shows on the docker command line: %q", arg)
+		}
+	}
+	// the password goes through the environment, not the command line
+	if !slices.Contains(cmd.Env, "MYSQL_PWD=secret") {
+		t.Error("MYSQL_PWD not set in the docker client's environment")
+	}
+}
+
+func TestRemoteDirectoryUnsupported(t *testing.T) {
+	p := &Postgres{Method: "directory", toolExec: toolExec{executor: &Kubernetes{Pod: "db-0"}}}
+	if _, err := p.Backup(context.Background()); !errors.Is(err, ErrExecUnsupported) {
+		t.Errorf("Backup() = %v, want ErrExecUnsupported", err)
+	}
+	m := &MongoDB{Method: MethodDir, toolExec: toolExec{executor: &Kubernetes{Pod: "db-0"}}}
+	if _, err := m.Backup(context.Background()); !errors.Is(err, ErrExecUnsupported) {
+		t.Errorf("Backup() = %v, want ErrExecUnsupported", err)
+	}
+}
+
+func TestNewCommandRunner(t *testing.T) {
+	e, err := NewCommandRunner(config.ExecConfig{})
+	if err != nil || e.Remote() {
+		t.Errorf("default executor = %v, %v; want local", e, err)
+	}
+	if _, err := NewCommandRunner(config.ExecConfig{Type: config.ExecKubernetes}); err == nil {
+		t.Error("kubernetes executor without pod or selector accepted")
+	}
+}
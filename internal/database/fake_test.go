@@ -0,0 +1,250 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kebairia/backup/internal/config"
+	"github.com/kebairia/backup/internal/vault"
+)
+
+// fakeExecutor runs every client tool as the test binary itself (see
+// TestHelperProcess) and records the commands, so engines can be exercised
+// without the tools installed.
+type fakeExecutor struct {
+	remote bool
+	stdout string // written to stdout by every command
+	stdin  string // file receiving the stdin of every command, if set
+	exit   int    // exit status of every command
+
+	mu    sync.Mutex
+	calls [][]string // name and args of each command
+	env   [][]string
+}
+
+func (f *fakeExecutor) Command(ctx context.Context, env []string, name string, args ...string) (*exec.Cmd, error) {
+	f.mu.Lock()
+	f.calls = append(f.calls, append([]string{name}, args...))
+	f.env = append(f.env, env)
+	f.mu.Unlock()
+	cmd := exec.CommandContext(ctx, os.Args[0], "-test.run=^TestHelperProcess$")
+	cmd.Env = append(os.Environ(),
+		"BACLI_HELPER_PROCESS=1",
+		"BACLI_HELPER_STDOUT="+f.stdout,
+		"BACLI_HELPER_STDIN="+f.stdin,
+		"BACLI_HELPER_EXIT="+strconv.Itoa(f.exit),
+	)
+	cmd.Env = append(cmd.Env, env...)
+	return cmd, nil
+}
+
+func (f *fakeExecutor) Remote() bool { return f.remote }
+
+// TestHelperProcess is the client tool run by fakeExecutor, not a test.
+func TestHelperProcess(t *testing.T) {
+	if os.Getenv("BACLI_HELPER_PROCESS") != "1" {
+		return
+	}
+	if target := os.Getenv("BACLI_HELPER_STDIN"); target != "" {
+		data, _ := io.ReadAll(os.Stdin)
+		_ = os.WriteFile(target, data, 0o644)
+	}
+	fmt.Print(os.Getenv("BACLI_HELPER_STDOUT"))
+	code, _ := strconv.Atoi(os.Getenv("BACLI_HELPER_EXIT"))
+	os.Exit(code)
+}
+
+// fakeCredentials issues a numbered user per request.
+type fakeCredentials struct {
+	mu       sync.Mutex
+	requests []string // role paths requested
+}
+
+func (f *fakeCredentials) GetDynamicCredentials(_ context.Context, role string) (vault.DynamicCredentials, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests = append(f.requests, role)
+	return vault.DynamicCredentials{
+		Username: fmt.Sprintf("v-%s-%d", path.Base(role), len(f.requests)),
+		Password: "secret",
+	}, nil
+}
+
+func (f *fakeCredentials) Prefetch(context.Context, []string, int) error { return nil }
+
+func (f *fakeCredentials) GetSecret(_ context.Context, path string) (map[string]any, error) {
+	return map[string]any{"token": "token-of-" + path}, nil
+}
+
+func fakePostgres(t *testing.T, executor CommandRunner) *Postgres {
+	t.Helper()
+	var cfg config.Config
+	cfg.Backup.Directory = t.TempDir()
+	cfg.Backup.Timeout = time.Minute
+	p, err := NewPostgres(cfg,
+		WithPostgresHost("db.test"),
+		WithPostgresPort("5432"),
+		WithPostgresCredentials("app", "secret"),
+		WithPostgresDatabase("billing"),
+		WithPostgresMethod("custom"),
+		WithPostgresExecutor(executor),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestPostgresBackupRestoreWithFakeExecutor(t *testing.T) {
+	executor := &fakeExecutor{remote: true, stdout: "PGDMP archive"}
+	p := fakePostgres(t, executor)
+
+	backupPath, err := p.Backup(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(backupPath); err != nil || string(data) != "PGDMP archive" {
+		t.Fatalf("artifact = %q, %v; want the streamed dump", data, err)
+	}
+	dump := executor.calls[0]
+	if dump[0] != "pg_dump" || !slices.Contains(dump, "-U") || slices.Contains(dump, "-f") {
+		t.Errorf("dump command = %v, want pg_dump streaming to stdout", dump)
+	}
+	if !slices.Contains(executor.env[0], "PGPASSWORD=secret") {
+		t.Errorf("dump environment = %v, want PGPASSWORD", executor.env[0])
+	}
+
+	executor.stdin = filepath.Join(t.TempDir(), "restored")
+	if err := p.Restore(context.Background(), backupPath); err != nil {
+		t.Fatal(err)
+	}
+	if restore := executor.calls[1]; restore[0] != "pg_restore" {
+		t.Errorf("restore command = %v, want pg_restore", restore)
+	}
+	if data, err := os.ReadFile(executor.stdin); err != nil || string(data) != "PGDMP archive" {
+		t.Errorf("restore read %q, %v; want the artifact on stdin", data, err)
+	}
+
+	executor.exit = 1
+	if _, err := p.Backup(context.Background()); err == nil {
+		t.Error("Backup() succeeded with a failing pg_dump")
+	}
+}
+
+func TestPostgresScratchDatabaseQuoting(t *testing.T) {
+	executor := &fakeExecutor{}
+	p := fakePostgres(t, executor)
+	p.RestoreDatabase = `billing" WITH TEMPLATE "app`
+	if err := p.PrepareTarget(); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.DropTarget(); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		`DROP DATABASE IF EXISTS "billing"" WITH TEMPLATE ""app"`,
+		`CREATE DATABASE "billing"" WITH TEMPLATE ""app"`,
+		`DROP DATABASE IF EXISTS "billing"" WITH TEMPLATE ""app"`,
+	}
+	for i, call := range executor.calls {
+		if sql := call[len(call)-1]; i >= len(want) || sql != want[i] {
+			t.Errorf("statement %d = %s", i, sql)
+		}
+	}
+}
+
+func TestInitializeWithCredentialSource(t *testing.T) {
+	var cfg config.Config
+	cfg.Backup.Directory = t.TempDir()
+	cfg.Postgres.Role = "backup"
+	cfg.Postgres.Vault.CredsPath = "database/creds"
+	cfg.Postgres.Instances = []config.DBInstance{
+		{Name: "main", Database: "main"},
+		{Name: "billing", Database: "billing", Role: "billing"},
+		{Name: "static", Database: "legacy", Username: "legacy", Password: "pw"},
+	}
+	creds := &fakeCredentials{}
+	dbs, err := InitializeDatabases(context.Background(), cfg, creds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"database/creds/backup", "database/creds/billing"}
+	if !slices.Equal(creds.requests, want) {
+		t.Errorf("requested %v, want %v", creds.requests, want)
+	}
+	users := make(map[string]string)
+	for _, db := range dbs {
+		users[db.GetName()] = db.(*Postgres).Username
+	}
+	if users["main"] != "v-backup-1" || users["billing"] != "v-billing-2" || users["legacy"] != "legacy" {
+		t.Errorf("users = %v", users)
+	}
+
+	// Vault not configured: no credential source
+	_, err = InitializeDatabases(context.Background(), cfg, nil)
+	if !errors.Is(err, ErrNoCredentials) {
+		t.Errorf("InitializeDatabases(nil client) error = %v, want ErrNoCredentials", err)
+	}
+}
+
+func TestInitializeSkipsDisabled(t *testing.T) {
+	disabled := false
+	var cfg config.Config
+	cfg.Backup.Directory = t.TempDir()
+	cfg.Postgres.Role = "backup"
+	cfg.Postgres.Vault.CredsPath = "database/creds"
+	cfg.Postgres.Instances = []config.DBInstance{
+		{Name: "main", Database: "main"},
+		{Name: "flaky", Database: "flaky", Role: "flaky", Enabled: &disabled},
+	}
+	cfg.MySQL.Enabled = &disabled
+	cfg.MySQL.Instances = []config.DBInstance{{Name: "shop", Database: "shop"}}
+
+	creds := &fakeCredentials{}
+	dbs, err := InitializeDatabases(context.Background(), cfg, creds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dbs) != 1 || dbs[0].GetName() != "main" {
+		t.Errorf("initialized %d databases, want main alone", len(dbs))
+	}
+	if want := []string{"database/creds/backup"}; !slices.Equal(creds.requests, want) {
+		t.Errorf("requested %v, want %v", creds.requests, want)
+	}
+}
+
+func TestClientPasswordsOffCommandLine(t *testing.T) {
+	executor := &fakeExecutor{}
+	m := &MongoDB{Host: "db.test", Port: "27017", Database: "app", Username: "backup", Password: "s3cr3t",
+		Timeout: time.Minute, toolExec: toolExec{executor: executor}}
+	if _, err := m.mongosh(m.Host, m.Database, "print(db.version())"); err != nil {
+		t.Fatal(err)
+	}
+	c := &Cassandra{Host: "db.test", Port: "9042", Keyspace: "app", Username: "backup", Password: "s3cr3t",
+		Timeout: time.Minute, toolExec: toolExec{executor: executor}}
+	if _, err := c.ListDatabases(); err != nil {
+		t.Fatal(err)
+	}
+	for _, call := range executor.calls {
+		if slices.ContainsFunc(call, func(arg string) bool { return strings.Contains(arg, "s3cr3t") }) {
+			t.Errorf("%s has the password on its command line: %q", call[0], call)
+		}
+	}
+	if len(executor.calls) != 2 ||
+		!slices.ContainsFunc(executor.calls[0], func(arg string) bool { return strings.HasPrefix(arg, "load(") }) ||
+		!slices.ContainsFunc(executor.calls[1], func(arg string) bool { return strings.HasPrefix(arg, "--cqlshrc=") }) {
+		t.Errorf("calls = %q, want mongosh loading its credentials and cqlsh reading a cqlshrc", executor.calls)
+	}
+}
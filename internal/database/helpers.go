@@ -0,0 +1,175 @@
+package database
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/kebairia/backup/internal/config"
+	"github.com/kebairia/backup/internal/logger"
+)
+
+// artifactDir returns <outputDir>/<engine>/<tmpl rendered for dir>. A
+// template that fails to render (config validation rejects those) falls
+// back to the database name.
+func artifactDir(outputDir, tmpl string, dir config.ArtifactDir) string {
+	name, err := dir.Render(tmpl)
+	if err != nil {
+		name = dir.Database
+	}
+	return filepath.Join(outputDir, dir.Engine, name)
+}
+
+// PartialDirname is the hidden directory of a database directory that
+// dumps are written to until they are finished, without backup.work_dir.
+const PartialDirname = ".partial"
+
+// WorkPath returns where the dumps of path, a database directory under
+// outputDir, are written before the operator moves the finished artifacts
+// into path: its counterpart under workDir (backup.work_dir), or its
+// PartialDirname without one. Catalogs, retention and storage syncers
+// never see artifacts under their final name before they are complete.
+func WorkPath(outputDir, workDir, path string) string {
+	if workDir != "" {
+		rel, err := filepath.Rel(outputDir, path)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return filepath.Join(workDir, rel)
+		}
+	}
+	return filepath.Join(path, PartialDirname)
+}
+
+// parseCounts parses "name<TAB>count" lines produced by engine query tools.
+func parseCounts(out string) (map[string]int64, error) {
+	counts := make(map[string]int64)
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(line, "\t")
+		if !ok {
+			return nil, fmt.Errorf("unexpected count line %q", line)
+		}
+		n, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("parse count for %q: %w", name, err)
+		}
+		counts[name] = n
+	}
+	return counts, nil
+}
+
+// scanSQLDump checks that a plain SQL dump starts with header and contains
+// footer (written by the dump tool only on success), and returns the tables
+// created by the dump.
+func scanSQLDump(path, header, footer string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidArtifact, err)
+	}
+	defer file.Close()
+
+	var (
+		tables              []string
+		hasHeader, complete bool
+		lineNo              int
+	)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		lineNo++
+		switch {
+		case strings.HasPrefix(line, "--") && strings.Contains(line, footer):
+			complete = true
+		case lineNo <= 10 && strings.Contains(line, header):
+			hasHeader = true
+		case strings.HasPrefix(line, "CREATE TABLE "):
+			name := strings.Fields(strings.TrimPrefix(line, "CREATE TABLE "))
+			if len(name) > 0 {
+				tables = append(tables, strings.Trim(name[0], "`\""))
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%w: read %s: %v", ErrInvalidArtifact, path, err)
+	}
+	if !hasHeader {
+		return nil, fmt.Errorf("%w: %s has no %q header", ErrInvalidArtifact, path, header)
+	}
+	if !complete {
+		return nil, fmt.Errorf("%w: %s is truncated (no %q marker)", ErrInvalidArtifact, path, footer)
+	}
+	return tables, nil
+}
+
+// stderrSink is embedded by engines to implement StderrSetter.
+type stderrSink struct {
+	w io.Writer
+}
+
+// SetStderr routes the stderr of subsequent client commands to w.
+func (s *stderrSink) SetStderr(w io.Writer) { s.w = w }
+
+// stderr returns the writer for the stderr of client commands.
+func (s *stderrSink) stderr() io.Writer {
+	if s.w == nil {
+		return os.Stderr
+	}
+	return s.w
+}
+
+// secretFile writes content to a new temporary file that only the current
+// user can read, for client tools reading credentials from a file rather
+// than from their command line, and returns its path and a func removing
+// it. pattern names the file as in os.CreateTemp.
+func secretFile(pattern, content string) (string, func(), error) {
+	file, err := os.CreateTemp("", pattern) // created 0600
+	if err != nil {
+		return "", nil, fmt.Errorf("create credentials file: %w", err)
+	}
+	remove := func() { os.Remove(file.Name()) }
+	if _, err := io.WriteString(file, content); err != nil {
+		file.Close()
+		remove()
+		return "", nil, fmt.Errorf("write credentials file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		remove()
+		return "", nil, fmt.Errorf("write credentials file: %w", err)
+	}
+	return file.Name(), remove, nil
+}
+
+// verboseArgs returns the flags making client tools report what they do,
+// with --verbose (see logger.Verbose).
+func verboseArgs() []string {
+	if logger.Verbose() {
+		return []string{"--verbose"}
+	}
+	return nil
+}
+
+// orBackground returns ctx, or context.Background() when ctx is nil.
+func orBackground(ctx context.Context) context.Context {
+	if ctx == nil {
+		return context.Background()
+	}
+	return ctx
+}
+
+// parseSize parses a single byte count printed by a client tool.
+func parseSize(out string) (int64, error) {
+	value := strings.TrimSpace(out)
+	size, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parse size %q: %w", value, err)
+	}
+	return int64(size), nil
+}
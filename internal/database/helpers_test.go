@@ -0,0 +1,96 @@
+package database
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestScanSQLDump_DetectsTruncatedDump(t *testing.T) {
+	complete := "-- MySQL dump 10.13\n" +
+		"CREATE TABLE `users` (\n  id int\n);\n" +
+		"CREATE TABLE `orders` (\n  id int\n);\n" +
+		"-- Dump completed on 2025-05-07 10:00:00\n"
+	truncated := "-- MySQL dump 10.13\nCREATE TABLE `users` (\n"
+
+	dir := t.TempDir()
+	completePath := filepath.Join(dir, "complete.sql")
+	truncatedPath := filepath.Join(dir, "truncated.sql")
+	if err := os.WriteFile(completePath, []byte(complete), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(truncatedPath, []byte(truncated), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tables, err := scanSQLDump(completePath, "MySQL dump", "Dump completed")
+	if err != nil {
+		t.Fatalf("scanSQLDump returned error: %v", err)
+	}
+	if len(tables) != 2 || tables[0] != "users" || tables[1] != "orders" {
+		t.Errorf("tables = %v, want [users orders]", tables)
+	}
+
+	if _, err := scanSQLDump(truncatedPath, "MySQL dump", "Dump completed"); !errors.Is(err, ErrInvalidArtifact) {
+		t.Errorf("truncated dump error = %v, want %v", err, ErrInvalidArtifact)
+	}
+}
+
+func TestCredentialFiles(t *testing.T) {
+	p := &Postgres{Password: `p:a\ss`}
+	env, cleanup, err := p.passwordEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	path, ok := strings.CutPrefix(env[0], "PGPASSFILE=")
+	if !ok {
+		t.Fatalf("env = %q, want PGPASSFILE", env)
+	}
+	info, err := os.Stat(path)
+	if err != nil || info.Mode().Perm() != 0o600 {
+		t.Fatalf("password file %s: %v, mode %v, want 0600", path, err, info.Mode())
+	}
+	if data, _ := os.ReadFile(path); string(data) != `*:*:*:*:p\:a\\ss`+"\n" {
+		t.Errorf("password file = %q", data)
+	}
+	cleanup()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("password file left behind: %v", err)
+	}
+
+	path, cleanup, err = mysqlOptionFile(`s"e\c`, "client", "xtrabackup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+	want := "[client]\npassword=\"s\\\"e\\\\c\"\n[xtrabackup]\npassword=\"s\\\"e\\\\c\"\n"
+	if data, _ := os.ReadFile(path); string(data) != want {
+		t.Errorf("option file = %q, want %q", data, want)
+	}
+}
+
+func TestWorkPath(t *testing.T) {
+	out, work := filepath.FromSlash("/srv/backups"), filepath.FromSlash("/scratch")
+	for path, want := range map[string]string{
+		"/srv/backups/postgres/billing": "/scratch/postgres/billing",
+		"/elsewhere/postgres/billing":   "/elsewhere/postgres/billing/.partial",
+	} {
+		if got := WorkPath(out, work, filepath.FromSlash(path)); got != filepath.FromSlash(want) {
+			t.Errorf("WorkPath(%s) = %s, want %s", path, got, want)
+		}
+	}
+	if got := WorkPath(out, "", filepath.FromSlash("/srv/backups/postgres/billing")); got != filepath.FromSlash("/srv/backups/postgres/billing/.partial") {
+		t.Errorf("WorkPath without a work directory = %s", got)
+	}
+}
+
+func TestOverwriteGuards(t *testing.T) {
+	// engines whose restores drop or truncate what is in the target
+	for _, db := range []Database{&Postgres{}, &MongoDB{}, &MySQL{}, &ClickHouse{}, &Cassandra{}} {
+		if _, ok := db.(OverwriteGuard); !ok {
+			t.Errorf("%T does not guard against overwriting a target holding data", db)
+		}
+	}
+}
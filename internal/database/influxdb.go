@@ -0,0 +1,665 @@
+package database
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kebairia/backup/internal/config"
+	"github.com/kebairia/backup/internal/logger"
+)
+
+const EngineInfluxDB = "influxdb"
+
+const (
+	// InfluxDBVersion1 backs up with `influxd backup -portable` over the
+	// RPC service of the server (influxRPCPort).
+	InfluxDBVersion1 = "1"
+	// InfluxDBVersion2 backs up with `influx backup` over the HTTP API,
+	// authenticated with an API token.
+	InfluxDBVersion2 = "2"
+
+	influxExt      = ".influx.tar"
+	influxManifest = "manifest.json"
+	// influxRPCPort is the default bind address port of the 1.x backup and
+	// restore service.
+	influxRPCPort = "8088"
+	// influxHTTPTimeout bounds requests to the HTTP API.
+	influxHTTPTimeout = 30 * time.Second
+)
+
+// InfluxDBOption lets you override default settings on an InfluxDB.
+type InfluxDBOption func(*InfluxDB)
+
+// InfluxDB holds configuration for backing up and restoring an InfluxDB 2.x
+// bucket or 1.x database. The server version is detected from the HTTP API
+// unless configured.
+type InfluxDB struct {
+	Username     string // 1.x user, for the HTTP API
+	Password     string
+	Token        string // 2.x API token
+	Org          string // 2.x organization
+	Database     string // 2.x bucket or 1.x database
+	Host         string // host name, or base URL of the HTTP API
+	Port         string // HTTP API port
+	Version      string // "1", "2", or "" to detect it
+	OutputDir    string
+	WorkDir      string // dumps are written here first, see WorkPath
+	TimeStampFmt string
+	NameTemplate string // artifact name, see config.ArtifactName
+	Instance     string // instance name, see config.ArtifactDir
+	DirTemplate  string // artifact directory, see config.ArtifactDir
+	Timeout      time.Duration
+	Labels       map[string]string
+	Logger       logger.Logger
+
+	// ctx cancels running client commands (see WithInfluxDBContext)
+	ctx context.Context
+	// stderr of client commands (see StderrSetter)
+	stderrSink
+	// runs client commands; the zero value runs them on the backup host,
+	// where the backup tools write their files
+	toolExec
+
+	versionOnce   sync.Once // detects serverVersion, see detect
+	serverVersion string
+	versionErr    error
+
+	// Restore target overrides (see Retarget)
+	RestoreHost     string
+	RestoreDatabase string
+}
+
+// influxArchive is the manifest of an InfluxDB archive; the files written
+// by the backup tool are stored under data/.
+type influxArchive struct {
+	Version  string `json:"version"` // major version of the backup tool
+	Database string `json:"database"`
+	Server   string `json:"server,omitempty"`
+}
+
+// NewInfluxDB returns an InfluxDB configured from cfg plus any overrides.
+func NewInfluxDB(cfg config.Config, opts ...InfluxDBOption) (*InfluxDB, error) {
+	log, err := logger.Init()
+	if err != nil {
+		return nil, fmt.Errorf("logger init failed: %w", err)
+	}
+	i := &InfluxDB{
+		Host:         cfg.InfluxDB.EngineDefaults.Host,
+		Port:         cfg.InfluxDB.EngineDefaults.Port,
+		Version:      cfg.InfluxDB.EngineDefaults.Version,
+		Org:          cfg.InfluxDB.EngineDefaults.Org,
+		OutputDir:    cfg.Backup.Directory,
+		WorkDir:      cfg.Backup.WorkDir,
+		TimeStampFmt: cfg.Backup.TimestampFmt,
+		NameTemplate: cfg.Backup.NameTemplate,
+		DirTemplate:  cfg.Backup.DirTemplate,
+		Timeout:      cfg.Backup.Timeout,
+		Logger:       log,
+	}
+	for _, opt := range opts {
+		opt(i)
+	}
+	i.Logger = logger.FromContext(i.ctx, i.Logger)
+	if i.Port == "" {
+		i.Port = "8086"
+	}
+	switch i.Version {
+	case "", InfluxDBVersion1, InfluxDBVersion2:
+	default:
+		return nil, fmt.Errorf("unknown influxdb version %q", i.Version)
+	}
+	return i, nil
+}
+
+// WithInfluxDBCredentials sets the 1.x username and password.
+func WithInfluxDBCredentials(user, pass string) InfluxDBOption {
+	return func(i *InfluxDB) {
+		if user != "" {
+			i.Username = user
+		}
+		if pass != "" {
+			i.Password = pass
+		}
+	}
+}
+
+// WithInfluxDBToken sets the 2.x API token.
+func WithInfluxDBToken(token string) InfluxDBOption {
+	return func(i *InfluxDB) {
+		i.Token = token
+	}
+}
+
+// WithInfluxDBHost overrides the host.
+func WithInfluxDBHost(host string) InfluxDBOption {
+	return func(i *InfluxDB) {
+		if host != "" {
+			i.Host = host
+		}
+	}
+}
+
+// WithInfluxDBPort overrides the HTTP API port.
+func WithInfluxDBPort(port string) InfluxDBOption {
+	return func(i *InfluxDB) {
+		if port != "" {
+			i.Port = port
+		}
+	}
+}
+
+// WithInfluxDBDatabase sets the bucket (2.x) or database (1.x).
+func WithInfluxDBDatabase(db string) InfluxDBOption {
+	return func(i *InfluxDB) {
+		if db != "" {
+			i.Database = db
+		}
+	}
+}
+
+// WithInfluxDBOutputDir overrides where backups are written.
+func WithInfluxDBOutputDir(dir string) InfluxDBOption {
+	return func(i *InfluxDB) {
+		if dir != "" {
+			i.OutputDir = dir
+		}
+	}
+}
+
+// WithInfluxDBInstance sets the instance name, available to backup.dir_template.
+func WithInfluxDBInstance(name string) InfluxDBOption {
+	return func(i *InfluxDB) {
+		i.Instance = name
+	}
+}
+
+// WithInfluxDBContext makes ctx cancel requests to the HTTP API, e.g. when
+// the run is interrupted.
+func WithInfluxDBContext(ctx context.Context) InfluxDBOption {
+	return func(i *InfluxDB) {
+		i.ctx = ctx
+	}
+}
+
+// WithInfluxDBLabels sets the labels recorded with every backup.
+func WithInfluxDBLabels(labels map[string]string) InfluxDBOption {
+	return func(i *InfluxDB) {
+		i.Labels = labels
+	}
+}
+
+// WithInfluxDBTimestampFormat overrides timestamp format.
+func WithInfluxDBTimestampFormat(format string) InfluxDBOption {
+	return func(i *InfluxDB) {
+		if format != "" {
+			i.TimeStampFmt = format
+		}
+	}
+}
+
+// artifactName renders the configured name template for a backup taken now.
+func (i *InfluxDB) artifactName(version string) (string, error) {
+	now := time.Now()
+	return config.ArtifactName{
+		Engine:    EngineInfluxDB,
+		Database:  i.Database,
+		Host:      i.Host,
+		Method:    "v" + version,
+		Timestamp: now.Format(i.TimeStampFmt),
+		Time:      now,
+	}.Render(i.NameTemplate)
+}
+
+// Backup runs the backup tool of the server version into a scratch
+// directory and archives its files into a .influx.tar.
+func (i *InfluxDB) Backup(ctx context.Context) (string, error) {
+	ctx, cancel := context.WithTimeoutCause(ctx, i.Timeout, ErrTimeout)
+	defer cancel()
+
+	version, err := i.majorVersion()
+	if err != nil {
+		return "", err
+	}
+	backupsDir := WorkPath(i.OutputDir, i.WorkDir, i.GetPath())
+	if err := os.MkdirAll(backupsDir, 0o755); err != nil {
+		return "", fmt.Errorf("mkdir %q: %w", backupsDir, err)
+	}
+	name, err := i.artifactName(version)
+	if err != nil {
+		return "", err
+	}
+	backupPath := filepath.Join(backupsDir, name+influxExt)
+	scratch, err := os.MkdirTemp(backupsDir, ".influx-*")
+	if err != nil {
+		return "", fmt.Errorf("create backup directory: %w", err)
+	}
+	defer os.RemoveAll(scratch)
+
+	i.Logger.Info("backup started",
+		"database", i.Database,
+		"engine", EngineInfluxDB,
+		"version", version,
+		"path", backupPath,
+	)
+	start := time.Now()
+	tool, args, env := i.backupArgs(version, scratch)
+	if err := i.run(ctx, env, tool, args...); err != nil {
+		return "", fmt.Errorf("%s backup failed: %w", tool, err)
+	}
+	archive := influxArchive{Version: version, Database: i.Database, Server: i.serverVersion}
+	if err := writeInfluxArchive(backupPath, archive, scratch); err != nil {
+		return backupPath, err
+	}
+	i.Logger.Info("backup completed",
+		"path", backupPath,
+		"duration", time.Since(start).String(),
+	)
+	return backupPath, nil
+}
+
+// backupArgs returns the tool, arguments and environment backing up the
+// database of a version server into dir. The 2.x token is passed through
+// INFLUX_TOKEN rather than the command line.
+func (i *InfluxDB) backupArgs(version, dir string) (tool string, args, env []string) {
+	if version == InfluxDBVersion1 {
+		return InfluxDBTool(version), []string{
+			"backup", "-portable",
+			"-host", i.hostname(i.Host) + ":" + influxRPCPort,
+			"-database", i.Database,
+			dir,
+		}, nil
+	}
+	args = []string{"backup", "--host", i.baseURL(i.Host), "--bucket", i.Database}
+	if i.Org != "" {
+		args = append(args, "--org", i.Org)
+	}
+	return "influx", append(args, dir), []string{"INFLUX_TOKEN=" + i.Token}
+}
+
+// Restore restores the archived bucket or database into the restore
+// target, which must not exist: the restore tools refuse to overwrite one.
+func (i *InfluxDB) Restore(ctx context.Context, backupFile string) error {
+	ctx, cancel := context.WithTimeoutCause(ctx, i.Timeout, ErrTimeout)
+	defer cancel()
+
+	archive, err := readInfluxManifest(backupFile)
+	if err != nil {
+		return err
+	}
+	scratch, err := os.MkdirTemp(filepath.Dir(backupFile), ".restore-*")
+	if err != nil {
+		return fmt.Errorf("create restore directory: %w", err)
+	}
+	defer os.RemoveAll(scratch)
+	if err := extractTar(backupFile, scratch); err != nil {
+		return err
+	}
+
+	host, database := i.restoreTarget()
+	i.Logger.Info("restore started",
+		"database", archive.Database,
+		"engine", EngineInfluxDB,
+		"version", archive.Version,
+		"target_host", host,
+		"target_database", database,
+	)
+	start := time.Now()
+	tool, args, env := i.restoreArgs(archive, filepath.Join(scratch, "data"))
+	if err := i.run(ctx, env, tool, args...); err != nil {
+		return fmt.Errorf("%s restore failed: %w", tool, err)
+	}
+	i.Logger.Info("restore completed", "duration", time.Since(start).String())
+	return nil
+}
+
+// restoreArgs returns the tool, arguments and environment restoring the
+// files of archive in dir into the restore target.
+func (i *InfluxDB) restoreArgs(archive influxArchive, dir string) (tool string, args, env []string) {
+	host, database := i.restoreTarget()
+	if archive.Version == InfluxDBVersion1 {
+		args = []string{
+			"restore", "-portable",
+			"-host", i.hostname(host) + ":" + influxRPCPort,
+			"-db", archive.Database,
+		}
+		if database != archive.Database {
+			args = append(args, "-newdb", database)
+		}
+		return "influxd", append(args, dir), nil
+	}
+	args = []string{"restore", "--host", i.baseURL(host), "--bucket", archive.Database}
+	if database != archive.Database {
+		args = append(args, "--new-bucket", database)
+	}
+	if i.Org != "" {
+		args = append(args, "--org", i.Org)
+	}
+	return "influx", append(args, dir), []string{"INFLUX_TOKEN=" + i.Token}
+}
+
+// Retarget redirects subsequent restores to another host and/or bucket.
+func (i *InfluxDB) Retarget(host, database string) {
+	i.RestoreHost = host
+	i.RestoreDatabase = database
+}
+
+// restoreTarget returns the host and database a restore should write to.
+func (i *InfluxDB) restoreTarget() (host, database string) {
+	host, database = i.Host, i.Database
+	if i.RestoreHost != "" {
+		host = i.RestoreHost
+	}
+	if i.RestoreDatabase != "" {
+		database = i.RestoreDatabase
+	}
+	return host, database
+}
+
+// ValidateArtifact checks that an archive has a manifest and backup files,
+// without connecting to a server, and returns the files.
+func (i *InfluxDB) ValidateArtifact(path string) ([]string, error) {
+	archive, err := readInfluxManifest(path)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("backup file %q not found: %w", path, err)
+	}
+	defer file.Close()
+	var files []string
+	tr := tar.NewReader(file)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: read %s: %v", ErrInvalidArtifact, path, err)
+		}
+		if rest, ok := strings.CutPrefix(header.Name, "data/"); ok {
+			files = append(files, rest)
+		}
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("%w: %s holds no backup of %s", ErrInvalidArtifact, path, archive.Database)
+	}
+	return files, nil
+}
+
+// ListDatabases returns the buckets (2.x) or databases (1.x) on the server.
+func (i *InfluxDB) ListDatabases() ([]string, error) {
+	version, err := i.majorVersion()
+	if err != nil {
+		return nil, err
+	}
+	if version == InfluxDBVersion1 {
+		var result struct {
+			Results []struct {
+				Series []struct {
+					Values [][]any `json:"values"`
+				} `json:"series"`
+			} `json:"results"`
+		}
+		if err := i.get("/query?q="+url.QueryEscape("SHOW DATABASES"), &result); err != nil {
+			return nil, err
+		}
+		var names []string
+		for _, r := range result.Results {
+			for _, series := range r.Series {
+				for _, value := range series.Values {
+					if len(value) == 0 {
+						continue
+					}
+					if name, ok := value[0].(string); ok {
+						names = append(names, name)
+					}
+				}
+			}
+		}
+		return names, nil
+	}
+	var result struct {
+		Buckets []struct {
+			Name string `json:"name"`
+		} `json:"buckets"`
+	}
+	query := url.Values{"limit": {"100"}}
+	if i.Org != "" {
+		query.Set("org", i.Org)
+	}
+	if err := i.get("/api/v2/buckets?"+query.Encode(), &result); err != nil {
+		return nil, err
+	}
+	names := make([]string, len(result.Buckets))
+	for n, bucket := range result.Buckets {
+		names[n] = bucket.Name
+	}
+	return names, nil
+}
+
+// ServerVersion returns the version the server reports on /ping.
+func (i *InfluxDB) ServerVersion() (string, error) {
+	i.detect()
+	return i.serverVersion, i.versionErr
+}
+
+// DumpTool returns the backup tool of the server version.
+func (i *InfluxDB) DumpTool() string {
+	version, _ := i.majorVersion()
+	return InfluxDBTool(version)
+}
+
+// InfluxDBTool returns the backup and restore tool of an InfluxDB major
+// version: influxd for 1.x, influx for 2.x.
+func InfluxDBTool(version string) string {
+	if version == InfluxDBVersion1 {
+		return "influxd"
+	}
+	return "influx"
+}
+
+// RestoreTool returns the restore tool, the same as the backup tool.
+func (i *InfluxDB) RestoreTool() string { return i.DumpTool() }
+
+// majorVersion returns the configured version, or the major version of the
+// server.
+func (i *InfluxDB) majorVersion() (string, error) {
+	if i.Version != "" {
+		return i.Version, nil
+	}
+	i.detect()
+	if i.versionErr != nil {
+		return "", fmt.Errorf("detect influxdb version: %w", i.versionErr)
+	}
+	major, _, _ := strings.Cut(strings.TrimPrefix(i.serverVersion, "v"), ".")
+	switch major {
+	case InfluxDBVersion1, InfluxDBVersion2:
+		return major, nil
+	}
+	return "", fmt.Errorf("unsupported influxdb version %q", i.serverVersion)
+}
+
+// detect reads the server version from the X-Influxdb-Version header of
+// /ping, once.
+func (i *InfluxDB) detect() {
+	i.versionOnce.Do(func() {
+		resp, err := i.request("/ping")
+		if err != nil {
+			i.versionErr = err
+			return
+		}
+		resp.Body.Close()
+		i.serverVersion = resp.Header.Get("X-Influxdb-Version")
+		if i.serverVersion == "" {
+			i.versionErr = fmt.Errorf("%s/ping reports no version", i.baseURL(i.Host))
+		}
+	})
+}
+
+// get decodes the JSON response of the HTTP API at path into v.
+func (i *InfluxDB) get(path string, v any) error {
+	resp, err := i.request(path)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("decode %s: %w", path, err)
+	}
+	return nil
+}
+
+// request sends GET path to the HTTP API of the source server, with the
+// token (2.x) or basic credentials (1.x). The caller closes the body.
+func (i *InfluxDB) request(path string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(orBackground(i.ctx), http.MethodGet, i.baseURL(i.Host)+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case i.Token != "":
+		req.Header.Set("Authorization", "Token "+i.Token)
+	case i.Username != "":
+		req.SetBasicAuth(i.Username, i.Password)
+	}
+	client := &http.Client{Timeout: influxHTTPTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("GET %s: %s", path, resp.Status)
+	}
+	return resp, nil
+}
+
+// baseURL returns the HTTP API URL of host: host itself when it has a
+// scheme, else http://host:port.
+func (i *InfluxDB) baseURL(host string) string {
+	if strings.Contains(host, "://") {
+		return strings.TrimRight(host, "/")
+	}
+	return "http://" + host + ":" + i.Port
+}
+
+// hostname returns the host name of host, which may be a URL.
+func (i *InfluxDB) hostname(host string) string {
+	if u, err := url.Parse(host); err == nil && u.Hostname() != "" && strings.Contains(host, "://") {
+		return u.Hostname()
+	}
+	return host
+}
+
+// run runs a client tool, streaming its output to stderr.
+func (i *InfluxDB) run(ctx context.Context, env []string, name string, args ...string) error {
+	cmd, err := i.command(ctx, env, name, args...)
+	if err != nil {
+		return err
+	}
+	cmd.Stdout = i.stderr()
+	cmd.Stderr = i.stderr()
+	return cmd.Run()
+}
+
+// writeInfluxArchive writes the manifest and the files under dir into a tar
+// archive at path.
+func writeInfluxArchive(path string, archive influxArchive, dir string) (err error) {
+	out, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("create %q: %w", path, err)
+	}
+	defer func() {
+		if cerr := out.Close(); err == nil && cerr != nil {
+			err = fmt.Errorf("close %q: %w", path, cerr)
+		}
+	}()
+	tw := tar.NewWriter(out)
+	manifest, err := json.MarshalIndent(archive, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeEntry(tw, influxManifest, manifest); err != nil {
+		return err
+	}
+	if err := addTree(tw, dir, "data"); err != nil {
+		return fmt.Errorf("archive %s: %w", dir, err)
+	}
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("write %q: %w", path, err)
+	}
+	return nil
+}
+
+// readInfluxManifest reads the manifest of an InfluxDB archive.
+func readInfluxManifest(path string) (influxArchive, error) {
+	var archive influxArchive
+	file, err := os.Open(path)
+	if err != nil {
+		return archive, fmt.Errorf("backup file %q not found: %w", path, err)
+	}
+	defer file.Close()
+	tr := tar.NewReader(file)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return archive, fmt.Errorf("%w: %s has no %s", ErrInvalidArtifact, path, influxManifest)
+		}
+		if err != nil {
+			return archive, fmt.Errorf("%w: read %s: %v", ErrInvalidArtifact, path, err)
+		}
+		if header.Name != influxManifest {
+			continue
+		}
+		if err := json.NewDecoder(tr).Decode(&archive); err != nil {
+			return archive, fmt.Errorf("%w: decode %s: %v", ErrInvalidArtifact, influxManifest, err)
+		}
+		if archive.Version != InfluxDBVersion1 && archive.Version != InfluxDBVersion2 {
+			return archive, fmt.Errorf("%w: %s has unknown version %q", ErrInvalidArtifact, path, archive.Version)
+		}
+		return archive, nil
+	}
+}
+
+// GetName returns the bucket or database name.
+func (i *InfluxDB) GetName() string { return i.Database }
+
+// GetEngine returns engine name.
+func (i *InfluxDB) GetEngine() string { return EngineInfluxDB }
+
+// GetPath returns the directory holding the artifacts and catalog of the
+// database, <output dir>/<engine>/<backup.dir_template>.
+func (i *InfluxDB) GetPath() string {
+	return artifactDir(i.OutputDir, i.DirTemplate, config.ArtifactDir{
+		Engine:   EngineInfluxDB,
+		Instance: i.Instance,
+		Host:     i.Host,
+		Database: i.Database,
+	})
+}
+
+// GetInstance returns the name of the configured instance.
+func (i *InfluxDB) GetInstance() string { return i.Instance }
+
+// ArtifactExt returns the extension of the single-file artifacts.
+func (i *InfluxDB) ArtifactExt() (string, bool) {
+	return influxExt, true
+}
+
+// GetHost returns the database host.
+func (i *InfluxDB) GetHost() string { return i.Host }
+
+// GetLabels returns the labels recorded with every backup.
+func (i *InfluxDB) GetLabels() map[string]string { return i.Labels }
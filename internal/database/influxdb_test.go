@@ -0,0 +1,78 @@
+package database
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/kebairia/backup/internal/config"
+)
+
+func TestInfluxDBDetectsVersion(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Token s3cr3t" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/ping":
+			w.Header().Set("X-Influxdb-Version", "v2.7.1")
+			w.WriteHeader(http.StatusNoContent)
+		case "/api/v2/buckets":
+			_, _ = w.Write([]byte(`{"buckets":[{"name":"metrics"},{"name":"_monitoring"}]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	var cfg config.Config
+	cfg.Backup.Directory = t.TempDir()
+	cfg.Backup.Timeout = time.Minute
+	i, err := NewInfluxDB(cfg,
+		WithInfluxDBHost(server.URL),
+		WithInfluxDBToken("s3cr3t"),
+		WithInfluxDBDatabase("metrics"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tool := i.DumpTool(); tool != "influx" {
+		t.Errorf("DumpTool() = %q, want influx", tool)
+	}
+	names, err := i.ListDatabases()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"metrics", "_monitoring"}; !slices.Equal(names, want) {
+		t.Errorf("ListDatabases() = %v, want %v", names, want)
+	}
+
+	tool, args, env := i.backupArgs(InfluxDBVersion2, "/tmp/out")
+	if want := []string{"backup", "--host", server.URL, "--bucket", "metrics", "/tmp/out"}; tool != "influx" ||
+		!slices.Equal(args, want) {
+		t.Errorf("backupArgs() = %s %v, want influx %v", tool, args, want)
+	}
+	if !slices.Equal(env, []string{"INFLUX_TOKEN=s3cr3t"}) {
+		t.Errorf("backup env = %v, the token should be passed in INFLUX_TOKEN", env)
+	}
+}
+
+func TestInfluxDBRestoreArgs(t *testing.T) {
+	var cfg config.Config
+	i, err := NewInfluxDB(cfg, WithInfluxDBHost("influx1.test"), WithInfluxDBDatabase("telegraf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	i.Retarget("influx2.test", "telegraf_restored")
+	tool, args, _ := i.restoreArgs(influxArchive{Version: InfluxDBVersion1, Database: "telegraf"}, "/tmp/data")
+	want := []string{
+		"restore", "-portable", "-host", "influx2.test:8088",
+		"-db", "telegraf", "-newdb", "telegraf_restored", "/tmp/data",
+	}
+	if tool != "influxd" || !slices.Equal(args, want) {
+		t.Errorf("restoreArgs() = %s %v, want influxd %v", tool, args, want)
+	}
+}
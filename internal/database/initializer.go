@@ -3,22 +3,25 @@ package database
 import (
 	"context"
 	"fmt"
-	"path/filepath"
 
 	"github.com/kebairia/backup/internal/config"
-	"github.com/kebairia/backup/internal/vault"
+	"github.com/kebairia/backup/internal/redact"
 )
 
-var engines = []string{"postgres", "mongodb", "mysql", "redis"}
+var engines = []string{"postgres", "mongodb", "mysql", "redis", "clickhouse", "cassandra", "influxdb", "couchdb"}
 
 var initializers = map[string]func(
 	ctx context.Context,
 	cfg config.Config,
-	vaultClient *vault.Client,
+	vaultClient CredentialSource,
 ) ([]Database, error){
-	"postgres": InitPostgresInstances,
-	"mongodb":  InitMongoDBInstances,
-	// "mysql":    InitMySQLInstances,
+	"postgres":       InitPostgresInstances,
+	"mongodb":        InitMongoDBInstances,
+	"mysql":          InitMySQLInstances,
+	EngineClickHouse: InitClickHouseInstances,
+	EngineCassandra:  InitCassandraInstances,
+	EngineInfluxDB:   InitInfluxDBInstances,
+	EngineCouchDB:    InitCouchDBInstances,
 	// "redis":    InitRedisInstances,
 }
 
@@ -26,35 +29,69 @@ var initializers = map[string]func(
 func InitPostgresInstances(
 	ctx context.Context,
 	cfg config.Config,
-	vaultClient *vault.Client,
+	vaultClient CredentialSource,
 ) ([]Database, error) {
 	var dbs []Database
+	claimed := claimedDatabases(cfg.Postgres)
 	for _, instance := range cfg.Postgres.Instances {
+		if !instance.IsEnabled() {
+			continue
+		}
 		// Resolve role path
 		roleName := instance.Role
 		if roleName == "" {
 			roleName = cfg.Postgres.Role
 		}
-		rolePath := filepath.Join(cfg.Postgres.Vault.CredsPath, roleName)
-		creds, err := vaultClient.GetDynamicCredentials(ctx, rolePath)
+		username, password, lease, err := credentials(ctx, vaultClient, instance, cfg.Postgres.Vault.CredsPath, roleName)
+		if err != nil {
+			return nil, fmt.Errorf("postgres instance %q: %w", instance.Name, err)
+		}
+		executor, err := NewCommandRunner(instance.Exec)
 		if err != nil {
-			return nil, fmt.Errorf("vault read :%w", err)
+			return nil, fmt.Errorf("postgres instance %q: %w", instance.Name, err)
 		}
 		opts := []PostgresOption{
 			WithPostgresHost(instance.Host),
+			WithPostgresExecutor(executor),
 			WithPostgresPort(instance.Port),
-			WithPostgresCredentials(creds.Username, creds.Password),
+			WithPostgresCredentials(username, password),
 			WithPostgresDatabase(instance.Database),
 			WithPostgresMethod(instance.Method),
+			WithPostgresJobs(instance.Jobs),
+			WithPostgresLabels(instance.Labels),
+			WithPostgresAllowOverwrite(instance.AllowOverwrite),
+			WithPostgresObjects(
+				config.Filter{Include: instance.Schemas, Exclude: instance.ExcludeSchemas},
+				config.Filter{Include: instance.Tables, Exclude: instance.ExcludeTables},
+			),
+			WithPostgresContent(instance.Content),
+			WithPostgresFlavor(instance.Flavor, instance.Extensions),
+			WithPostgresContext(ctx),
+			WithPostgresInstance(instance.Name),
 			WithPostgresOutputDir(cfg.Backup.Directory),
 			WithPostgresTimestampFormat(cfg.Backup.TimestampFmt),
-			WithPostgresCompress(true),
+			WithPostgresCompression(cfg.Compression("postgres", instance.Name)),
 		}
-		db, err := NewPostgres(cfg, opts...)
+		probe, err := NewPostgres(cfg, opts...)
 		if err != nil {
 			return nil, fmt.Errorf("failed to initialize postgres instance: %w", err)
 		}
-		dbs = append(dbs, db)
+		names, err := expandDatabases(probe, instance, claimed)
+		if err != nil {
+			return nil, fmt.Errorf("postgres instance %q: %w", instance.Name, err)
+		}
+		if cfg.Postgres.Globals {
+			probe.credentialLease = lease
+			dbs = append(dbs, NewPostgresGlobals(probe))
+		}
+		for _, name := range names {
+			db, err := NewPostgres(cfg, append(opts, WithPostgresDatabase(name))...)
+			if err != nil {
+				return nil, fmt.Errorf("failed to initialize postgres instance: %w", err)
+			}
+			db.credentialLease = lease
+			dbs = append(dbs, db)
+		}
 	}
 	return dbs, nil
 }
@@ -63,66 +100,336 @@ func InitPostgresInstances(
 func InitMongoDBInstances(
 	ctx context.Context,
 	cfg config.Config,
-	vaultClient *vault.Client,
+	vaultClient CredentialSource,
 ) ([]Database, error) {
 	var dbs []Database
+	claimed := claimedDatabases(cfg.MongoDB)
 	for _, instance := range cfg.MongoDB.Instances {
-		rolePath := filepath.Join(cfg.MongoDB.Vault.CredsPath, instance.Role)
-		secrets, err := vaultClient.GetDynamicCredentials(ctx, rolePath)
+		if !instance.IsEnabled() {
+			continue
+		}
+		username, password, lease, err := credentials(ctx, vaultClient, instance, cfg.MongoDB.Vault.CredsPath, instance.Role)
 		if err != nil {
-			return nil, fmt.Errorf("vault read :%w", err)
+			return nil, fmt.Errorf("mongodb instance %q: %w", instance.Name, err)
+		}
+		executor, err := NewCommandRunner(instance.Exec)
+		if err != nil {
+			return nil, fmt.Errorf("mongodb instance %q: %w", instance.Name, err)
 		}
 		opts := []MongoDBOption{
 			WithMongoHost(instance.Host),
+			WithMongoExecutor(executor),
 			WithMongoPort(instance.Port),
-			WithMongoCredentials(secrets.Username, secrets.Password),
+			WithMongoCredentials(username, password),
 			WithMongoDatabase(instance.Database),
+			WithMongoURI(instance.URI),
+			WithMongoTLS(instance.TLS),
+			WithMongoReadPreference(instance.ReadPreference),
+			WithMongoOplog(instance.Oplog),
+			WithMongoCollections(instance.Collections),
 			WithMongoMethod(instance.Method),
+			WithMongoCompression(cfg.Compression("mongodb", instance.Name)),
+			WithMongoLabels(instance.Labels),
+			WithMongoAllowOverwrite(instance.AllowOverwrite),
+			WithMongoContext(ctx),
+			WithMongoInstance(instance.Name),
 			WithMongoOutputDir(cfg.Backup.Directory),
 			WithMongoTimestampFormat(cfg.Backup.TimestampFmt),
 		}
-		db, err := NewMongoDB(cfg, opts...)
+		probe, err := NewMongoDB(cfg, opts...)
 		if err != nil {
 			return nil, fmt.Errorf("failed to initialize mongodb instance: %w", err)
 		}
-		dbs = append(dbs, db)
+		names, err := expandDatabases(probe, instance, claimed)
+		if err != nil {
+			return nil, fmt.Errorf("mongodb instance %q: %w", instance.Name, err)
+		}
+		for _, name := range names {
+			db, err := NewMongoDB(cfg, append(opts, WithMongoDatabase(name))...)
+			if err != nil {
+				return nil, fmt.Errorf("failed to initialize mongodb instance: %w", err)
+			}
+			db.credentialLease = lease
+			dbs = append(dbs, db)
+		}
+	}
+	return dbs, nil
+}
+
+// InitMySQLInstances initializes MySQL instances.
+func InitMySQLInstances(
+	ctx context.Context,
+	cfg config.Config,
+	vaultClient CredentialSource,
+) ([]Database, error) {
+	var dbs []Database
+	claimed := claimedDatabases(cfg.MySQL)
+	for _, instance := range cfg.MySQL.Instances {
+		if !instance.IsEnabled() {
+			continue
+		}
+		roleName := instance.Role
+		if roleName == "" {
+			roleName = cfg.MySQL.Role
+		}
+		username, password, lease, err := credentials(ctx, vaultClient, instance, cfg.MySQL.Vault.CredsPath, roleName)
+		if err != nil {
+			return nil, fmt.Errorf("mysql instance %q: %w", instance.Name, err)
+		}
+		executor, err := NewCommandRunner(instance.Exec)
+		if err != nil {
+			return nil, fmt.Errorf("mysql instance %q: %w", instance.Name, err)
+		}
+		opts := []MySQLOption{
+			WithMySQLCredentials(username, password),
+			WithMySQLExecutor(executor),
+			WithMySQLHost(instance.Host),
+			WithMySQLPort(instance.Port),
+			WithMySQLDatabase(instance.Database),
+			WithMySQLMethod(instance.Method),
+			WithMySQLDataDir(instance.DataDir),
+			WithMySQLLabels(instance.Labels),
+			WithMySQLAllowOverwrite(instance.AllowOverwrite),
+			WithMySQLContext(ctx),
+			WithMySQLInstance(instance.Name),
+			WithMySQLOutputDir(cfg.Backup.Directory),
+			WithMySQLTimestampFormat(cfg.Backup.TimestampFmt),
+		}
+		probe, err := NewMySQL(cfg, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("create mysql instance %q: %w", instance.Name, err)
+		}
+		names, err := expandDatabases(probe, instance, claimed)
+		if err != nil {
+			return nil, fmt.Errorf("mysql instance %q: %w", instance.Name, err)
+		}
+		for _, name := range names {
+			db, err := NewMySQL(cfg, append(opts, WithMySQLDatabase(name))...)
+			if err != nil {
+				return nil, fmt.Errorf("create mysql instance %q: %w", instance.Name, err)
+			}
+			db.credentialLease = lease
+			dbs = append(dbs, db)
+		}
+	}
+	return dbs, nil
+}
+
+// InitClickHouseInstances initializes ClickHouse instances.
+func InitClickHouseInstances(
+	ctx context.Context,
+	cfg config.Config,
+	vaultClient CredentialSource,
+) ([]Database, error) {
+	var dbs []Database
+	claimed := claimedDatabases(cfg.ClickHouse)
+	for _, instance := range cfg.ClickHouse.Instances {
+		if !instance.IsEnabled() {
+			continue
+		}
+		roleName := instance.Role
+		if roleName == "" {
+			roleName = cfg.ClickHouse.Role
+		}
+		username, password, lease, err := credentials(ctx, vaultClient, instance, cfg.ClickHouse.Vault.CredsPath, roleName)
+		if err != nil {
+			return nil, fmt.Errorf("clickhouse instance %q: %w", instance.Name, err)
+		}
+		opts := []ClickHouseOption{
+			WithClickHouseCredentials(username, password),
+			WithClickHouseHost(instance.Host),
+			WithClickHousePort(instance.Port),
+			WithClickHouseDatabase(instance.Database),
+			WithClickHouseTables(instance.Tables),
+			WithClickHouseMethod(instance.Method),
+			WithClickHouseLabels(instance.Labels),
+			WithClickHouseAllowOverwrite(instance.AllowOverwrite),
+			WithClickHouseContext(ctx),
+			WithClickHouseInstance(instance.Name),
+			WithClickHouseOutputDir(cfg.Backup.Directory),
+			WithClickHouseTimestampFormat(cfg.Backup.TimestampFmt),
+		}
+		probe, err := NewClickHouse(cfg, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("create clickhouse instance %q: %w", instance.Name, err)
+		}
+		names, err := expandDatabases(probe, instance, claimed)
+		if err != nil {
+			return nil, fmt.Errorf("clickhouse instance %q: %w", instance.Name, err)
+		}
+		for _, name := range names {
+			db, err := NewClickHouse(cfg, append(opts, WithClickHouseDatabase(name))...)
+			if err != nil {
+				return nil, fmt.Errorf("create clickhouse instance %q: %w", instance.Name, err)
+			}
+			db.credentialLease = lease
+			dbs = append(dbs, db)
+		}
+	}
+	return dbs, nil
+}
+
+// InitCassandraInstances initializes Cassandra/ScyllaDB keyspaces.
+func InitCassandraInstances(
+	ctx context.Context,
+	cfg config.Config,
+	vaultClient CredentialSource,
+) ([]Database, error) {
+	var dbs []Database
+	claimed := claimedDatabases(cfg.Cassandra)
+	for _, instance := range cfg.Cassandra.Instances {
+		if !instance.IsEnabled() {
+			continue
+		}
+		roleName := instance.Role
+		if roleName == "" {
+			roleName = cfg.Cassandra.Role
+		}
+		username, password, lease, err := credentials(ctx, vaultClient, instance, cfg.Cassandra.Vault.CredsPath, roleName)
+		if err != nil {
+			return nil, fmt.Errorf("cassandra instance %q: %w", instance.Name, err)
+		}
+		opts := []CassandraOption{
+			WithCassandraCredentials(username, password),
+			WithCassandraHost(instance.Host),
+			WithCassandraPort(instance.Port),
+			WithCassandraKeyspace(instance.Database),
+			WithCassandraDataDir(instance.DataDir),
+			WithCassandraLabels(instance.Labels),
+			WithCassandraAllowOverwrite(instance.AllowOverwrite),
+			WithCassandraContext(ctx),
+			WithCassandraInstance(instance.Name),
+			WithCassandraOutputDir(cfg.Backup.Directory),
+			WithCassandraTimestampFormat(cfg.Backup.TimestampFmt),
+		}
+		probe, err := NewCassandra(cfg, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("create cassandra instance %q: %w", instance.Name, err)
+		}
+		names, err := expandDatabases(probe, instance, claimed)
+		if err != nil {
+			return nil, fmt.Errorf("cassandra instance %q: %w", instance.Name, err)
+		}
+		for _, name := range names {
+			db, err := NewCassandra(cfg, append(opts, WithCassandraKeyspace(name))...)
+			if err != nil {
+				return nil, fmt.Errorf("create cassandra instance %q: %w", instance.Name, err)
+			}
+			db.credentialLease = lease
+			dbs = append(dbs, db)
+		}
+	}
+	return dbs, nil
+}
+
+// InitInfluxDBInstances initializes InfluxDB buckets (2.x) and databases
+// (1.x). 1.x instances with a username use static credentials; the others
+// authenticate with an API token (see influxToken).
+func InitInfluxDBInstances(
+	ctx context.Context,
+	cfg config.Config,
+	vaultClient CredentialSource,
+) ([]Database, error) {
+	var dbs []Database
+	claimed := claimedDatabases(cfg.InfluxDB)
+	for _, instance := range cfg.InfluxDB.Instances {
+		if !instance.IsEnabled() {
+			continue
+		}
+		opts := []InfluxDBOption{
+			WithInfluxDBHost(instance.Host),
+			WithInfluxDBPort(instance.Port),
+			WithInfluxDBDatabase(instance.Database),
+			WithInfluxDBLabels(instance.Labels),
+			WithInfluxDBContext(ctx),
+			WithInfluxDBInstance(instance.Name),
+			WithInfluxDBOutputDir(cfg.Backup.Directory),
+			WithInfluxDBTimestampFormat(cfg.Backup.TimestampFmt),
+		}
+		if instance.Username != "" {
+			password, err := staticPassword(instance)
+			if err != nil {
+				return nil, fmt.Errorf("influxdb instance %q: %w", instance.Name, err)
+			}
+			redact.Register(password)
+			opts = append(opts, WithInfluxDBCredentials(instance.Username, password))
+		} else {
+			token, err := influxToken(ctx, vaultClient, instance, cfg.InfluxDB.Vault.TokenPath)
+			if err != nil {
+				return nil, fmt.Errorf("influxdb instance %q: %w", instance.Name, err)
+			}
+			opts = append(opts, WithInfluxDBToken(token))
+		}
+		probe, err := NewInfluxDB(cfg, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("create influxdb instance %q: %w", instance.Name, err)
+		}
+		names, err := expandDatabases(probe, instance, claimed)
+		if err != nil {
+			return nil, fmt.Errorf("influxdb instance %q: %w", instance.Name, err)
+		}
+		for _, name := range names {
+			db, err := NewInfluxDB(cfg, append(opts, WithInfluxDBDatabase(name))...)
+			if err != nil {
+				return nil, fmt.Errorf("create influxdb instance %q: %w", instance.Name, err)
+			}
+			dbs = append(dbs, db)
+		}
+	}
+	return dbs, nil
+}
+
+// InitCouchDBInstances initializes CouchDB databases.
+func InitCouchDBInstances(
+	ctx context.Context,
+	cfg config.Config,
+	vaultClient CredentialSource,
+) ([]Database, error) {
+	var dbs []Database
+	claimed := claimedDatabases(cfg.CouchDB)
+	for _, instance := range cfg.CouchDB.Instances {
+		if !instance.IsEnabled() {
+			continue
+		}
+		roleName := instance.Role
+		if roleName == "" {
+			roleName = cfg.CouchDB.Role
+		}
+		username, password, lease, err := credentials(ctx, vaultClient, instance, cfg.CouchDB.Vault.CredsPath, roleName)
+		if err != nil {
+			return nil, fmt.Errorf("couchdb instance %q: %w", instance.Name, err)
+		}
+		opts := []CouchDBOption{
+			WithCouchDBCredentials(username, password),
+			WithCouchDBHost(instance.Host),
+			WithCouchDBPort(instance.Port),
+			WithCouchDBDatabase(instance.Database),
+			WithCouchDBLabels(instance.Labels),
+			WithCouchDBContext(ctx),
+			WithCouchDBInstance(instance.Name),
+			WithCouchDBOutputDir(cfg.Backup.Directory),
+			WithCouchDBTimestampFormat(cfg.Backup.TimestampFmt),
+		}
+		probe, err := NewCouchDB(cfg, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("create couchdb instance %q: %w", instance.Name, err)
+		}
+		names, err := expandDatabases(probe, instance, claimed)
+		if err != nil {
+			return nil, fmt.Errorf("couchdb instance %q: %w", instance.Name, err)
+		}
+		for _, name := range names {
+			db, err := NewCouchDB(cfg, append(opts, WithCouchDBDatabase(name))...)
+			if err != nil {
+				return nil, fmt.Errorf("create couchdb instance %q: %w", instance.Name, err)
+			}
+			db.credentialLease = lease
+			dbs = append(dbs, db)
+		}
 	}
 	return dbs, nil
 }
 
-// initMySQLInstances initializes MySQL instances.
-// func initMySQLInstances(
-// 	ctx context.Context,
-// 	cfg config.Config,
-// 	vaultClient *vault.Client,
-// ) ([]Database, error) {
-// 	var dbs []Database
-// 	for _, inst := range cfg.MySQL.Instances {
-// 		rolePath := filepath.Join(cfg.MySQL.Vault.RoleBase, inst.RoleName)
-// 		creds, err := vaultClient.GetDynamicCredentials(ctx, rolePath)
-// 		if err != nil {
-// 			return nil, fmt.Errorf("vault read for mysql %q: %w", inst.Name, err)
-// 		}
-//
-// 		opts := []MySQLOption{
-// 			WithMySQLCredentials(creds.Username, creds.Password),
-// 			WithMySQLHost(inst.Host),
-// 			WithMySQLPort(inst.Port),
-// 			WithMySQLDatabase(inst.Database),
-// 			WithMySQLMethod(inst.Method),
-// 			WithMySQLOutputDir(cfg.Backup.OutputDirectory),
-// 			WithMySQLTimestampFormat(cfg.Backup.TimestampFormat),
-// 		}
-//
-// 		my, err := NewMySQL(cfg, opts...)
-// 		if err != nil {
-// 			return nil, fmt.Errorf("create mysql instance %q: %w", inst.Name, err)
-// 		}
-// 		dbs = append(dbs, my)
-// 	}
-// 	return dbs, nil
-// }
-//
 // // initRedisInstances initializes Redis instances.
 // func initRedisInstances(
 // 	ctx context.Context,
@@ -160,13 +467,19 @@ func InitMongoDBInstances(
 func InitializeDatabases(
 	ctx context.Context,
 	config config.Config,
-	vaultClient *vault.Client,
+	vaultClient CredentialSource,
 ) ([]Database, error) {
 	dbs := make([]Database, 0)
+	if err := prefetchCredentials(ctx, config, vaultClient); err != nil {
+		return nil, err
+	}
 
-	// NOTE: I can add `if !config.IsEngineEnabled(engine) { continue }` in the initializers
-	// 			 to check first if the engine is enabled, I need to see if this is necessary or not.
+	// disabled engines and instances keep their configuration but are
+	// skipped; disabled instances still claim their database from "*" ones
 	for engine, initializer := range initializers {
+		if !config.IsEngineEnabled(engine) {
+			continue
+		}
 		instances, err := initializer(ctx, config, vaultClient)
 		if err != nil {
 			return nil, fmt.Errorf("initialize %s instance: %w", engine, err)
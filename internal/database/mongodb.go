@@ -1,12 +1,18 @@
 package database
 
 import (
+	"compress/gzip"
 	"context"
+	"encoding/binary"
+	"encoding/json"
 	"fmt"
 	"io"
+	"io/fs"
+	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/kebairia/backup/internal/config"
@@ -33,9 +39,41 @@ type MongoDB struct {
 	Port         string
 	Method       string
 	OutputDir    string
+	WorkDir      string // dumps are written here first, see WorkPath
 	TimestampFmt string
+	NameTemplate string // artifact name, see config.ArtifactName
+	Instance     string // instance name, see config.ArtifactDir
+	DirTemplate  string // artifact directory, see config.ArtifactDir
 	Timeout      time.Duration
+	Labels       map[string]string
 	Logger       logger.Logger
+	// AllowOverwrite lets restores replace a target that holds data
+	AllowOverwrite bool
+	// URI is a connection string (e.g. a replica set) used instead of
+	// Host and Port; see connArgs.
+	URI string
+	// TLS connects the tools over TLS; see tlsArgs.
+	TLS config.MongoTLSConfig
+	// ReadPreference is passed to mongodump, e.g. "secondary".
+	ReadPreference string
+	// Oplog dumps the whole deployment with --oplog and replays it on
+	// restore, for a snapshot consistent as of the end of the dump.
+	Oplog bool
+	// Collections selects the collections dumped (see collectionArgs).
+	Collections config.Filter
+
+	// ctx cancels running client commands (see WithMongoContext)
+	ctx context.Context
+	// Vault lease of the dynamic credentials (see LeaseHolder)
+	credentialLease
+	// stderr of client commands (see StderrSetter)
+	stderrSink
+	// runs client commands (see WithMongoExecutor)
+	toolExec
+
+	// Restore target overrides (see Retarget)
+	RestoreHost     string
+	RestoreDatabase string
 }
 
 // NewMongoDB creates a new MongoDB instance based on config defaults and supplied options.
@@ -47,18 +85,24 @@ func NewMongoDB(cfg config.Config, opts ...MongoDBOption) (*MongoDB, error) {
 
 	m := &MongoDB{
 		// Username:        cfg.Defaults.MongoDB.Username,
-		Host:         cfg.MongoDB.EngineDefaults.Host,
-		Port:         cfg.MongoDB.EngineDefaults.Port,
-		Method:       cfg.MongoDB.EngineDefaults.Method,
-		OutputDir:    cfg.Backup.Directory,
-		TimestampFmt: cfg.Backup.TimestampFmt,
-		Timeout:      cfg.Backup.Timeout,
-		Logger:       log,
+		Host:           cfg.MongoDB.EngineDefaults.Host,
+		Port:           cfg.MongoDB.EngineDefaults.Port,
+		ReadPreference: cfg.MongoDB.EngineDefaults.ReadPreference,
+		Oplog:          cfg.MongoDB.EngineDefaults.Oplog,
+		Method:         cfg.MongoDB.EngineDefaults.Method,
+		OutputDir:      cfg.Backup.Directory,
+		WorkDir:        cfg.Backup.WorkDir,
+		TimestampFmt:   cfg.Backup.TimestampFmt,
+		NameTemplate:   cfg.Backup.NameTemplate,
+		DirTemplate:    cfg.Backup.DirTemplate,
+		Timeout:        cfg.Backup.Timeout,
+		Logger:         log,
 	}
 
 	for _, opt := range opts {
 		opt(m)
 	}
+	m.Logger = logger.FromContext(m.ctx, m.Logger)
 	return m, nil
 }
 
@@ -80,6 +124,44 @@ func WithMongoPort(port string) MongoDBOption {
 	}
 }
 
+// WithMongoURI connects through a connection string instead of host and port.
+func WithMongoURI(uri string) MongoDBOption {
+	return func(m *MongoDB) {
+		m.URI = uri
+	}
+}
+
+// WithMongoTLS connects over TLS with the given certificates.
+func WithMongoTLS(tls config.MongoTLSConfig) MongoDBOption {
+	return func(m *MongoDB) {
+		m.TLS = tls
+	}
+}
+
+// WithMongoReadPreference overrides the read preference of dumps.
+func WithMongoReadPreference(preference string) MongoDBOption {
+	return func(m *MongoDB) {
+		if preference != "" {
+			m.ReadPreference = preference
+		}
+	}
+}
+
+// WithMongoOplog enables oplog-consistent dumps; the engine default can
+// only be turned on, not off, per instance.
+func WithMongoOplog(oplog bool) MongoDBOption {
+	return func(m *MongoDB) {
+		m.Oplog = m.Oplog || oplog
+	}
+}
+
+// WithMongoCollections selects the collections dumped.
+func WithMongoCollections(filter config.Filter) MongoDBOption {
+	return func(m *MongoDB) {
+		m.Collections = filter
+	}
+}
+
 // WithMongoCredentials overrides the username and password.
 func WithMongoCredentials(username, password string) MongoDBOption {
 	return func(m *MongoDB) {
@@ -110,6 +192,23 @@ func WithMongoMethod(method string) MongoDBOption {
 	}
 }
 
+// WithMongoCompression applies the compression mode (see
+// config.Compression): native switches the method to its gzip variant.
+// Apply it after WithMongoMethod.
+func WithMongoCompression(mode string) MongoDBOption {
+	return func(m *MongoDB) {
+		if mode != config.CompressionNative {
+			return
+		}
+		switch m.Method {
+		case MethodDir:
+			m.Method = MethodDirGzip
+		case MethodArchive:
+			m.Method = MethodArchiveGzip
+		}
+	}
+}
+
 // WithMongoOutputDir overrides the output directory.
 func WithMongoOutputDir(dir string) MongoDBOption {
 	return func(m *MongoDB) {
@@ -119,6 +218,43 @@ func WithMongoOutputDir(dir string) MongoDBOption {
 	}
 }
 
+// WithMongoInstance sets the instance name, available to backup.dir_template.
+func WithMongoInstance(name string) MongoDBOption {
+	return func(m *MongoDB) {
+		m.Instance = name
+	}
+}
+
+// WithMongoContext makes ctx cancel streamed dumps and restores, archive
+// checks and mongosh queries, e.g. when the run is interrupted.
+func WithMongoContext(ctx context.Context) MongoDBOption {
+	return func(m *MongoDB) {
+		m.ctx = ctx
+	}
+}
+
+// WithMongoExecutor runs mongodump, mongorestore and mongosh through e,
+// e.g. inside the database's pod.
+func WithMongoExecutor(e CommandRunner) MongoDBOption {
+	return func(m *MongoDB) {
+		m.executor = e
+	}
+}
+
+// WithMongoLabels sets the labels recorded with every backup.
+func WithMongoLabels(labels map[string]string) MongoDBOption {
+	return func(m *MongoDB) {
+		m.Labels = labels
+	}
+}
+
+// WithMongoAllowOverwrite lets restores replace a target holding data.
+func WithMongoAllowOverwrite(allow bool) MongoDBOption {
+	return func(m *MongoDB) {
+		m.AllowOverwrite = allow
+	}
+}
+
 // WithMongoTimestampFormat overrides the timestamp format.
 func WithMongoTimestampFormat(format string) MongoDBOption {
 	return func(m *MongoDB) {
@@ -129,66 +265,56 @@ func WithMongoTimestampFormat(format string) MongoDBOption {
 }
 
 // Backup creates a backup of the MongoDB database using mongodump.
-func (m *MongoDB) Backup() (backupPath string, err error) {
+func (m *MongoDB) Backup(ctx context.Context) (backupPath string, err error) {
 	log := m.Logger
-	ctx, cancel := context.WithTimeoutCause(context.Background(), m.Timeout, ErrTimeout)
+	ctx, cancel := context.WithTimeoutCause(ctx, m.Timeout, ErrTimeout)
 	defer cancel()
+	// directory dumps cannot be streamed out of a remote mongodump
+	if m.remote() && !m.isArchive() {
+		return "", fmt.Errorf("%s method: %w", m.Method, ErrExecUnsupported)
+	}
 
-	timestamp := time.Now().Format(m.TimestampFmt)
-	backupPath = filepath.Join(
-		m.OutputDir,
-		EngineMongoDB,
-		m.Database,
-		fmt.Sprintf("%s-%s.dump", timestamp, m.Database),
-	)
+	now := time.Now()
+	name, err := config.ArtifactName{
+		Engine:    EngineMongoDB,
+		Database:  m.Database,
+		Host:      m.Host,
+		Method:    m.Method,
+		Timestamp: now.Format(m.TimestampFmt),
+		Time:      now,
+	}.Render(m.NameTemplate)
+	if err != nil {
+		return "", err
+	}
+	backupPath = filepath.Join(WorkPath(m.OutputDir, m.WorkDir, m.GetPath()), name+".dump")
 
 	// FIX: Use EnsureDirExists function from helpers
 	if err := os.MkdirAll(filepath.Dir(backupPath), 0o755); err != nil {
 		return "", fmt.Errorf("failed to create backup directory: %w", err)
 	}
 
-	var args []string
-
-	base := []string{
-		"--host=" + m.Host,
-		"--port=" + m.Port,
-		"--username=" + m.Username,
-		"--password=" + m.Password,
-		"--authenticationDatabase=admin",
-		"--db=" + m.Database,
-		"--quiet",
-	}
-	switch m.Method {
-	case MethodDir:
-		args = append(base,
-			"--out="+backupPath,
-		)
-	case MethodDirGzip:
-		args = append(base,
-			"--out="+backupPath,
-			"--gzip",
-		)
-
-	case MethodArchive:
-		args = append(base,
-			"--archive="+backupPath,
-		)
-	case MethodArchiveGzip:
-		args = append(base,
-			"--archive="+backupPath,
-			"--gzip",
-		)
-
+	cmd, cleanup, err := m.dumpCommand(ctx, backupPath)
+	if err != nil {
+		return "", err
 	}
-
-	cmd := exec.CommandContext(ctx, "mongodump", args...)
+	defer cleanup()
 	cmd.Stdout = io.Discard
-	cmd.Stderr = os.Stderr
+	cmd.Stderr = m.stderr()
+	if m.remote() {
+		out, err := os.Create(backupPath)
+		if err != nil {
+			return "", fmt.Errorf("create %q: %w", backupPath, err)
+		}
+		defer out.Close()
+		cmd.Stdout = out
+	}
 
 	log.Info("backup started",
 		"database", m.Database,
 		"engine", EngineMongoDB,
 		"method", m.Method,
+		"read_preference", m.ReadPreference,
+		"oplog", m.Oplog,
 		"path", backupPath,
 	)
 	startTime := time.Now()
@@ -199,7 +325,7 @@ func (m *MongoDB) Backup() (backupPath string, err error) {
 			"path", backupPath,
 			"error", err.Error(),
 		)
-		return "", fmt.Errorf("mongodump failed: %w", err)
+		return backupPath, fmt.Errorf("mongodump failed: %w", err)
 	}
 	executionDuration := time.Since(startTime)
 
@@ -212,63 +338,118 @@ func (m *MongoDB) Backup() (backupPath string, err error) {
 	return backupPath, nil
 }
 
-// Restore restores a MongoDB database from a backup directory using mongorestore.
-func (m *MongoDB) Restore(sourceDir string) error {
-	log := m.Logger
-	ctx, cancel := context.WithTimeoutCause(context.Background(), m.Timeout, ErrTimeout)
-	defer cancel()
+// dumpCommand returns the mongodump command dumping into path, or writing
+// the archive to stdout when path is empty. Call the returned func once the
+// command ran.
+func (m *MongoDB) dumpCommand(ctx context.Context, path string) (*exec.Cmd, func(), error) {
+	var args []string
 
-	// FIX: Use EnsureDirExists function from helpers
-	// 			I need to check if the metadata file exists
-	if _, err := os.Stat(sourceDir); err != nil {
-		return fmt.Errorf("backup source %q not found: %w", sourceDir, err)
+	filterArgs, err := m.collectionArgs()
+	if err != nil {
+		return nil, nil, err
 	}
-
-	// NOTE: Add other options "--dir=" + sourceDir,
-	var cmd *exec.Cmd
-	base := []string{
-		"--host=" + m.Host,
-		"--port=" + m.Port,
-		"--username=" + m.Username,
-		"--password=" + m.Password,
-		"--authenticationDatabase=admin",
-		"--nsInclude=" + m.Database + ".*", // restore only this DB’s namespaces
-		"--drop",                           // replace collections if they already exist
-		"--quiet",
+	conn, cleanup, err := m.connArgs(ctx, m.Host)
+	if err != nil {
+		return nil, nil, err
 	}
-	var args []string
+	base := append(append(conn, m.dumpArgs()...), filterArgs...)
 	switch m.Method {
 	case MethodDir:
 		args = append(base,
-			"--dir="+sourceDir,
+			"--out="+path,
 		)
-
 	case MethodDirGzip:
 		args = append(base,
-			"--dir="+sourceDir,
+			"--out="+path,
 			"--gzip",
 		)
+
 	case MethodArchive:
 		args = append(base,
-			"--archive="+sourceDir, // read .archive file
+			m.archiveArg(path),
 		)
 	case MethodArchiveGzip:
 		args = append(base,
-			"--archive="+sourceDir,
+			m.archiveArg(path),
 			"--gzip",
 		)
 
 	}
 
-	cmd = exec.CommandContext(ctx, "mongorestore", args...)
+	cmd, err := m.command(ctx, nil, "mongodump", args...)
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	return cmd, cleanup, nil
+}
+
+// DumpTo runs mongodump and writes the archive to w. Only the archive
+// methods can be piped.
+func (m *MongoDB) DumpTo(w io.Writer) error {
+	if !m.isArchive() {
+		return fmt.Errorf("%s method: %w", m.Method, ErrNotPipeable)
+	}
+	ctx, cancel := context.WithTimeoutCause(orBackground(m.ctx), m.Timeout, ErrTimeout)
+	defer cancel()
+	cmd, cleanup, err := m.dumpCommand(ctx, "")
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	cmd.Stdout = w
+	cmd.Stderr = m.stderr()
+	m.Logger.Info("dump started",
+		"database", m.Database,
+		"engine", EngineMongoDB,
+		"method", m.Method,
+	)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("mongodump failed: %w", err)
+	}
+	return nil
+}
+
+// Restore restores a MongoDB database from a backup directory using mongorestore.
+func (m *MongoDB) Restore(ctx context.Context, sourceDir string) error {
+	log := m.Logger
+	ctx, cancel := context.WithTimeoutCause(ctx, m.Timeout, ErrTimeout)
+	defer cancel()
+
+	// FIX: Use EnsureDirExists function from helpers
+	// 			I need to check if the metadata file exists
+	if _, err := os.Stat(sourceDir); err != nil {
+		return fmt.Errorf("backup source %q not found: %w", sourceDir, err)
+	}
+
+	if m.remote() && !m.isArchive() {
+		return fmt.Errorf("restore %q: %w", sourceDir, ErrExecUnsupported)
+	}
+
+	host, database := m.restoreTarget()
+	cmd, cleanup, err := m.restoreCommand(ctx, host, database, sourceDir)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
 	cmd.Stdout = io.Discard
-	cmd.Stderr = os.Stderr
+	cmd.Stderr = m.stderr()
+	if m.remote() {
+		in, err := os.Open(sourceDir)
+		if err != nil {
+			return fmt.Errorf("open %q: %w", sourceDir, err)
+		}
+		defer in.Close()
+		cmd.Stdin = in
+	}
 
 	log.Info("restore started",
 		"database", m.Database,
 		"engine", EngineMongoDB,
 		"method", m.Method,
 		"source", sourceDir,
+		"target_host", host,
+		"target_database", database,
 	)
 	startTime := time.Now()
 	if err := cmd.Run(); err != nil {
@@ -291,6 +472,507 @@ func (m *MongoDB) Restore(sourceDir string) error {
 	return nil
 }
 
+// RestoreFrom restores the archive read from r into the restore target.
+// Only the archive methods can be piped.
+func (m *MongoDB) RestoreFrom(r io.Reader) error {
+	if !m.isArchive() {
+		return fmt.Errorf("%s method: %w", m.Method, ErrNotPipeable)
+	}
+	ctx, cancel := context.WithTimeoutCause(orBackground(m.ctx), m.Timeout, ErrTimeout)
+	defer cancel()
+	host, database := m.restoreTarget()
+	cmd, cleanup, err := m.restoreCommand(ctx, host, database, "")
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	cmd.Stdin = r
+	cmd.Stdout = io.Discard
+	cmd.Stderr = m.stderr()
+	m.Logger.Info("restore started",
+		"database", m.Database,
+		"engine", EngineMongoDB,
+		"method", m.Method,
+		"source", "pipe",
+		"target_host", host,
+		"target_database", database,
+	)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("mongorestore failed: %w", err)
+	}
+	return nil
+}
+
+// restoreCommand returns the mongorestore command restoring the dump at
+// path, or the archive read from stdin when path is empty, into database on
+// host. Call the returned func once the command ran.
+func (m *MongoDB) restoreCommand(ctx context.Context, host, database, path string) (*exec.Cmd, func(), error) {
+	log := m.Logger
+	conn, cleanup, err := m.connArgs(ctx, host)
+	if err != nil {
+		return nil, nil, err
+	}
+	// NOTE: Add other options "--dir=" + sourceDir,
+	base := append(conn,
+		"--nsInclude="+m.Database+".*", // restore only this DB’s namespaces
+		"--drop",                       // replace collections if they already exist
+		mongoVerbosity(),
+	)
+	if database != m.Database {
+		// rename namespaces into the target database
+		base = append(base,
+			"--nsFrom="+m.Database+".*",
+			"--nsTo="+database+".*",
+		)
+	}
+	switch {
+	case m.Oplog && database == m.Database:
+		base = append(base, "--oplogReplay")
+	case m.Oplog:
+		// oplog entries name the source namespaces, which are not renamed
+		log.Warn("oplog not replayed into a renamed database",
+			"database", m.Database,
+			"target_database", database,
+		)
+	}
+	var args []string
+	switch m.Method {
+	case MethodDir:
+		args = append(base,
+			"--dir="+path,
+		)
+
+	case MethodDirGzip:
+		args = append(base,
+			"--dir="+path,
+			"--gzip",
+		)
+	case MethodArchive:
+		args = append(base,
+			m.archiveArg(path), // read .archive file
+		)
+	case MethodArchiveGzip:
+		args = append(base,
+			m.archiveArg(path),
+			"--gzip",
+		)
+
+	}
+
+	cmd, err := m.command(ctx, nil, "mongorestore", args...)
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	return cmd, cleanup, nil
+}
+
+// Retarget redirects subsequent restores to another host and/or database.
+// Namespaces are renamed with --nsFrom/--nsTo.
+func (m *MongoDB) Retarget(host, database string) {
+	m.RestoreHost = host
+	m.RestoreDatabase = database
+}
+
+// restoreTarget returns the host and database a restore should write to.
+func (m *MongoDB) restoreTarget() (host, database string) {
+	host, database = m.Host, m.Database
+	if m.RestoreHost != "" {
+		host = m.RestoreHost
+	}
+	if m.RestoreDatabase != "" {
+		database = m.RestoreDatabase
+	}
+	return host, database
+}
+
+// MongoArchiveMagic is the little-endian magic number opening mongodump
+// archives.
+const MongoArchiveMagic = 0x8199e26d
+
+// ValidateArtifact checks a dump without connecting to a server.
+// Directory dumps are listed by collection; archives are checked for the
+// mongodump magic number (through gzip when the method is gzipped).
+func (m *MongoDB) ValidateArtifact(path string) ([]string, error) {
+	gzipped := m.Method == MethodDirGzip || m.Method == MethodArchiveGzip
+	if isOplogCapture(path) {
+		return []string{mongoOplogFile}, nil
+	}
+
+	switch m.Method {
+	case MethodDir, MethodDirGzip:
+		var collections []string
+		err := filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			name := strings.TrimSuffix(d.Name(), ".gz")
+			if strings.HasSuffix(name, ".bson") {
+				collections = append(collections, strings.TrimSuffix(name, ".bson"))
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidArtifact, err)
+		}
+		if len(collections) == 0 {
+			return nil, fmt.Errorf("%w: no collections in %s", ErrInvalidArtifact, path)
+		}
+		return collections, nil
+	default:
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidArtifact, err)
+		}
+		defer file.Close()
+		var r io.Reader = file
+		if gzipped {
+			gz, err := gzip.NewReader(file)
+			if err != nil {
+				return nil, fmt.Errorf("%w: gzip: %v", ErrInvalidArtifact, err)
+			}
+			defer gz.Close()
+			r = gz
+		}
+		var magic uint32
+		if err := binary.Read(r, binary.LittleEndian, &magic); err != nil {
+			return nil, fmt.Errorf("%w: read archive header: %v", ErrInvalidArtifact, err)
+		}
+		if magic != MongoArchiveMagic {
+			return nil, fmt.Errorf("%w: %s is not a mongodump archive", ErrInvalidArtifact, path)
+		}
+		return []string{filepath.Base(path)}, nil
+	}
+}
+
+// CheckBackup reads an archive back with `mongorestore --dryRun` (gzip-aware)
+// so an unreadable archive is caught at backup time. Directory dumps and
+// oplog captures are not checked.
+func (m *MongoDB) CheckBackup(path string) (string, error) {
+	if !m.isArchive() || isOplogCapture(path) {
+		return "", nil
+	}
+	const check = "mongorestore --dryRun"
+
+	ctx, cancel := context.WithTimeoutCause(orBackground(m.ctx), m.Timeout, ErrTimeout)
+	defer cancel()
+
+	conn, cleanup, err := m.connArgs(ctx, m.Host)
+	if err != nil {
+		return check, err
+	}
+	defer cleanup()
+	args := append(conn,
+		"--nsInclude="+m.Database+".*",
+		m.archiveArg(path),
+		"--dryRun",
+		"--quiet",
+	)
+	if m.Method == MethodArchiveGzip {
+		args = append(args, "--gzip")
+	}
+	var stderr strings.Builder
+	cmd, err := m.command(ctx, nil, "mongorestore", args...)
+	if err != nil {
+		return check, err
+	}
+	cmd.Stdout = io.Discard
+	cmd.Stderr = &stderr
+	if m.remote() {
+		in, err := os.Open(path)
+		if err != nil {
+			return check, fmt.Errorf("open %q: %w", path, err)
+		}
+		defer in.Close()
+		cmd.Stdin = in
+	}
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return check, fmt.Errorf("%w: %s: %v: %s", ErrInvalidArtifact, check, err, msg)
+		}
+		return check, fmt.Errorf("%w: %s: %v", ErrInvalidArtifact, check, err)
+	}
+	return check, nil
+}
+
+// EstimateSize returns the uncompressed data size of the source database
+// from dbStats.
+func (m *MongoDB) EstimateSize() (int64, error) {
+	out, err := m.mongosh(m.Host, m.Database, "print(db.stats().dataSize)")
+	if err != nil {
+		return 0, err
+	}
+	return parseSize(out)
+}
+
+// PrepareTarget is a no-op: mongorestore creates the target database.
+func (m *MongoDB) PrepareTarget() error { return nil }
+
+// CountObjects returns the document count of every collection in the restore target.
+func (m *MongoDB) CountObjects() (map[string]int64, error) {
+	out, err := m.eval(`db.getCollectionNames().forEach(function (c) {
+  print(c + "\t" + db.getCollection(c).countDocuments({}));
+})`)
+	if err != nil {
+		return nil, err
+	}
+	return parseCounts(out)
+}
+
+// TargetObjects returns the user collections of the restore target database.
+func (m *MongoDB) TargetObjects() ([]string, error) {
+	out, err := m.eval(`db.getCollectionNames().forEach(function (c) {
+  if (!c.startsWith("system.")) print(c);
+})`)
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(out), nil
+}
+
+// OverwriteAllowed reports whether the instance sets allow_overwrite.
+func (m *MongoDB) OverwriteAllowed() bool { return m.AllowOverwrite }
+
+// DropTarget drops the restore target database.
+func (m *MongoDB) DropTarget() error {
+	_, database := m.restoreTarget()
+	if database == m.Database {
+		return fmt.Errorf("%w: refusing to drop source database %q", ErrVerifyFailed, database)
+	}
+	_, err := m.eval("db.dropDatabase()")
+	return err
+}
+
+// eval runs a mongosh script against the restore target and returns its output.
+func (m *MongoDB) eval(script string) (string, error) {
+	host, database := m.restoreTarget()
+	return m.mongosh(host, database, script)
+}
+
+// mongosh runs script against database on host and returns its output.
+// mongosh reads no credentials from a --config file, so the script first
+// loads one only the current user can read, where mongosh runs, which
+// authenticates against admin; the password stays off the command line.
+func (m *MongoDB) mongosh(host, database, script string) (string, error) {
+	ctx, cancel := context.WithTimeoutCause(orBackground(m.ctx), m.Timeout, ErrTimeout)
+	defer cancel()
+
+	target := database
+	args := []string{"--host=" + host, "--port=" + m.Port}
+	if m.URI != "" && host == m.Host {
+		uri, err := mongoURIWithDatabase(m.URI, database)
+		if err != nil {
+			return "", err
+		}
+		args, target = nil, uri
+	}
+	args = append(args, m.tlsArgs(true)...)
+	if m.Username != "" {
+		username, _ := json.Marshal(m.Username)
+		password, _ := json.Marshal(m.Password)
+		path, cleanup, err := m.secretFile(ctx, "bacli-mongosh-*.js",
+			fmt.Sprintf("db.getSiblingDB(\"admin\").auth(%s, %s);\n", username, password))
+		if err != nil {
+			return "", err
+		}
+		defer cleanup()
+		quoted, _ := json.Marshal(path)
+		script = fmt.Sprintf("load(%s);\n%s", quoted, script)
+	}
+	args = append(args, "--quiet", "--eval", script, target)
+	cmd, err := m.command(ctx, nil, "mongosh", args...)
+	if err != nil {
+		return "", err
+	}
+	cmd.Stderr = m.stderr()
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("mongosh eval failed: %w", err)
+	}
+	return string(out), nil
+}
+
+// DumpTool returns mongodump.
+func (m *MongoDB) DumpTool() string { return "mongodump" }
+
+// RestoreTool returns mongorestore.
+func (m *MongoDB) RestoreTool() string { return "mongorestore" }
+
+// isArchive reports whether the method writes a single archive file.
+func (m *MongoDB) isArchive() bool {
+	return m.Method == MethodArchive || m.Method == MethodArchiveGzip
+}
+
+// archiveArg returns the --archive flag for path, or for stdin or stdout
+// when path is empty. Remote tools cannot see the backup host's files, so
+// they stream the archive over stdin or stdout.
+func (m *MongoDB) archiveArg(path string) string {
+	if m.remote() || path == "" {
+		return "--archive"
+	}
+	return "--archive=" + path
+}
+
+// connArgs returns the flags connecting the MongoDB tools to host: the
+// configured URI, unless a restore was retargeted to another host, or host
+// and port, and the TLS flags (see tlsArgs).
+//
+// The password, and the URI, which may embed credentials, are passed in a
+// --config file only the current user can read, where the tools run, so
+// that they stay off the command line. Call the returned func once the
+// command ran, to remove the file.
+func (m *MongoDB) connArgs(ctx context.Context, host string) ([]string, func(), error) {
+	uri := m.URI != "" && host == m.Host
+	args := []string{"--host=" + host, "--port=" + m.Port}
+	if uri {
+		args = nil
+	}
+	args = append(args, m.tlsArgs(false)...)
+	args = append(args,
+		"--username="+m.Username,
+		"--authenticationDatabase=admin",
+	)
+	settings := mongoToolConfig{Password: m.Password}
+	if uri {
+		settings.URI = m.URI
+	}
+	data, err := json.Marshal(settings)
+	if err != nil {
+		return nil, nil, fmt.Errorf("encode mongodb tool config: %w", err)
+	}
+	path, cleanup, err := m.secretFile(ctx, "bacli-mongo-*.yaml", string(data))
+	if err != nil {
+		return nil, nil, err
+	}
+	return append(args, "--config="+path), cleanup, nil
+}
+
+// tlsArgs returns the flags connecting over TLS: the --ssl flags of the
+// database tools, or the --tls flags of mongosh. None without TLS, which
+// mongodb+srv:// URIs still turn on by themselves.
+func (m *MongoDB) tlsArgs(mongosh bool) []string {
+	if !m.TLS.Enabled {
+		return nil
+	}
+	enable, caFile, certFile, insecure := "--ssl", "--sslCAFile=", "--sslPEMKeyFile=", "--sslAllowInvalidCertificates"
+	if mongosh {
+		enable, caFile, certFile, insecure = "--tls", "--tlsCAFile=", "--tlsCertificateKeyFile=", "--tlsAllowInvalidCertificates"
+	}
+	args := []string{enable}
+	if m.TLS.CACert != "" {
+		args = append(args, caFile+m.TLS.CACert)
+	}
+	if m.TLS.ClientCert != "" {
+		args = append(args, certFile+m.TLS.ClientCert)
+	}
+	if m.TLS.SkipVerify {
+		args = append(args, insecure)
+	}
+	return args
+}
+
+// mongoToolConfig holds the settings of a --config file of the MongoDB
+// database tools. The file is YAML; JSON is valid YAML.
+type mongoToolConfig struct {
+	Password string `json:"password"`
+	URI      string `json:"uri,omitempty"`
+}
+
+// dumpArgs returns the mongodump flags selecting what is dumped and from
+// which member. --oplog only applies to whole deployments, so --db is left
+// out then and restores pick the database with --nsInclude.
+func (m *MongoDB) dumpArgs() []string {
+	args := []string{mongoVerbosity()}
+	if m.ReadPreference != "" {
+		args = append(args, "--readPreference="+m.ReadPreference)
+	}
+	if m.Oplog {
+		return append(args, "--oplog")
+	}
+	return append(args, "--db="+m.Database)
+}
+
+// collectionArgs returns the --excludeCollection flags leaving out the
+// collections that Collections does not select. mongodump takes a single
+// --collection, so includes are turned into excludes of the other
+// collections, listed from the server.
+func (m *MongoDB) collectionArgs() ([]string, error) {
+	if m.Collections.Empty() {
+		return nil, nil
+	}
+	out, err := m.mongosh(m.Host, m.Database, `db.getCollectionNames().forEach(function (c) { print(c); })`)
+	if err != nil {
+		return nil, fmt.Errorf("list collections: %w", err)
+	}
+	// one name per line; names may hold spaces
+	names := strings.FieldsFunc(out, func(r rune) bool { return r == '\n' || r == '\r' })
+	excluded, err := excludedCollections(names, m.Collections)
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, len(excluded))
+	for i, name := range excluded {
+		args[i] = "--excludeCollection=" + name
+	}
+	return args, nil
+}
+
+// excludedCollections returns the collections filter leaves out. It fails
+// when none would be dumped.
+func excludedCollections(collections []string, filter config.Filter) ([]string, error) {
+	var excluded []string
+	for _, name := range collections {
+		if !filter.Match(name) {
+			excluded = append(excluded, name)
+		}
+	}
+	if len(collections) > 0 && len(excluded) == len(collections) {
+		return nil, fmt.Errorf("collections: no collection of %d selected", len(collections))
+	}
+	return excluded, nil
+}
+
+// mongoVerbosity returns --verbose with bacli --verbose, and --quiet
+// otherwise: the tools log every collection they dump or restore.
+func mongoVerbosity() string {
+	if logger.Verbose() {
+		return "--verbose"
+	}
+	return "--quiet"
+}
+
+// mongoURIWithDatabase sets the database path of a connection string,
+// keeping its options (replicaSet, tls, ...).
+func mongoURIWithDatabase(uri, database string) (string, error) {
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		return "", fmt.Errorf("parse mongodb uri: %w", err)
+	}
+	parsed.Path = "/" + database
+	parsed.RawPath = ""
+	return parsed.String(), nil
+}
+
+// ListDatabases returns the databases on the server.
+func (m *MongoDB) ListDatabases() ([]string, error) {
+	out, err := m.mongosh(m.Host, "admin",
+		"db.adminCommand({listDatabases: 1, nameOnly: true}).databases.forEach(d => print(d.name))")
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(out), nil
+}
+
+// ServerVersion connects with the configured credentials and returns the
+// server version.
+func (m *MongoDB) ServerVersion() (string, error) {
+	out, err := m.mongosh(m.Host, m.Database, "print(db.version())")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out), nil
+}
+
 func (m *MongoDB) GetName() string {
 	return m.Database
 }
@@ -299,6 +981,31 @@ func (m *MongoDB) GetEngine() string {
 	return EngineMongoDB
 }
 
+// GetPath returns the directory holding the artifacts and catalog of the
+// database, <output dir>/<engine>/<backup.dir_template>.
 func (m *MongoDB) GetPath() string {
-	return filepath.Join(m.OutputDir, EngineMongoDB)
+	return artifactDir(m.OutputDir, m.DirTemplate, config.ArtifactDir{
+		Engine:   EngineMongoDB,
+		Instance: m.Instance,
+		Host:     m.Host,
+		Database: m.Database,
+	})
 }
+
+// GetInstance returns the name of the configured instance.
+func (m *MongoDB) GetInstance() string { return m.Instance }
+
+// ArtifactExt returns the extension of the single-file artifacts of the
+// configured method.
+func (m *MongoDB) ArtifactExt() (string, bool) {
+	if m.Method == MethodArchive || m.Method == MethodArchiveGzip {
+		return ".dump", true
+	}
+	return "", false
+}
+
+// GetHost returns the database host.
+func (m *MongoDB) GetHost() string { return m.Host }
+
+// GetLabels returns the labels recorded with every backup.
+func (m *MongoDB) GetLabels() map[string]string { return m.Labels }
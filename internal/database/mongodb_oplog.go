@@ -0,0 +1,193 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kebairia/backup/internal/config"
+)
+
+// ErrOplogGap indicates that the oplog no longer holds the changes made
+// since the backup an oplog capture applies on: a full backup is needed.
+var ErrOplogGap = errors.New("oplog does not reach back to the base backup")
+
+const (
+	// MongoOplogExt is the extension of oplog capture artifacts:
+	// directories holding the captured entries as oplog.bson, the layout
+	// mongorestore --oplogReplay reads.
+	MongoOplogExt  = ".oplog"
+	mongoOplogFile = "oplog.bson"
+)
+
+// IncrementalSupported reports whether oplog captures can be taken: the
+// full backups must be dumped with --oplog.
+func (m *MongoDB) IncrementalSupported() bool { return m.Oplog }
+
+// BackupIncremental captures the oplog entries written since since, the
+// start of the backup the capture applies on, into a directory artifact.
+// The entries are dumped from local.oplog.rs, which must still hold since.
+func (m *MongoDB) BackupIncremental(ctx context.Context, since time.Time) (backupPath string, err error) {
+	log := m.Logger
+	ctx, cancel := context.WithTimeoutCause(ctx, m.Timeout, ErrTimeout)
+	defer cancel()
+
+	first, err := m.oplogStart()
+	if err != nil {
+		return "", err
+	}
+	if first.After(since) {
+		return "", fmt.Errorf("%w: oldest entry %s, base backup %s",
+			ErrOplogGap, first.Format(time.RFC3339), since.Format(time.RFC3339))
+	}
+
+	now := time.Now()
+	name, err := config.ArtifactName{
+		Engine:    EngineMongoDB,
+		Database:  m.Database,
+		Host:      m.Host,
+		Method:    m.Method,
+		Timestamp: now.Format(m.TimestampFmt),
+		Time:      now,
+	}.Render(m.NameTemplate)
+	if err != nil {
+		return "", err
+	}
+	backupPath = filepath.Join(WorkPath(m.OutputDir, m.WorkDir, m.GetPath()), name+MongoOplogExt)
+	if err := os.MkdirAll(backupPath, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create backup directory: %w", err)
+	}
+	out, err := os.Create(filepath.Join(backupPath, mongoOplogFile))
+	if err != nil {
+		return backupPath, fmt.Errorf("create oplog file: %w", err)
+	}
+	defer out.Close()
+
+	conn, cleanup, err := m.connArgs(ctx, m.Host)
+	if err != nil {
+		return backupPath, err
+	}
+	defer cleanup()
+	args := append(conn, mongoVerbosity(),
+		"--db=local",
+		"--collection=oplog.rs",
+		fmt.Sprintf(`--query={"ts":{"$gt":{"$timestamp":{"t":%d,"i":0}}}}`, since.Unix()),
+		"--out=-", // a single collection is written to stdout as BSON
+	)
+	if m.ReadPreference != "" {
+		args = append(args, "--readPreference="+m.ReadPreference)
+	}
+	cmd, err := m.command(ctx, nil, "mongodump", args...)
+	if err != nil {
+		return backupPath, err
+	}
+	cmd.Stdout = out
+	cmd.Stderr = m.stderr()
+
+	log.Info("oplog capture started",
+		"database", m.Database,
+		"engine", EngineMongoDB,
+		"since", since.Format(time.RFC3339),
+		"path", backupPath,
+	)
+	if err := cmd.Run(); err != nil {
+		return backupPath, fmt.Errorf("mongodump oplog failed: %w", err)
+	}
+	if err := out.Close(); err != nil {
+		return backupPath, fmt.Errorf("write oplog file: %w", err)
+	}
+	log.Info("oplog capture completed",
+		"database", m.Database,
+		"engine", EngineMongoDB,
+		"path", backupPath,
+		"duration", time.Since(now).String(),
+	)
+	return backupPath, nil
+}
+
+// oplogStart returns the time of the oldest entry of the oplog.
+func (m *MongoDB) oplogStart() (time.Time, error) {
+	out, err := m.mongosh(m.Host, m.Database,
+		`print(db.getSiblingDB("local").oplog.rs.find().sort({$natural: 1}).limit(1).next().ts.getHighBits())`)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("read oplog start: %w", err)
+	}
+	seconds, err := strconv.ParseInt(strings.TrimSpace(out), 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("read oplog start: unexpected output %q", strings.TrimSpace(out))
+	}
+	return time.Unix(seconds, 0), nil
+}
+
+// RestoreIncremental replays the oplog capture at path with mongorestore
+// --oplogReplay, stopping before until when it is not zero. Entries name
+// the source namespaces, so they cannot be replayed into a renamed
+// database.
+func (m *MongoDB) RestoreIncremental(ctx context.Context, path string, until time.Time) error {
+	log := m.Logger
+	ctx, cancel := context.WithTimeoutCause(ctx, m.Timeout, ErrTimeout)
+	defer cancel()
+
+	if !isOplogCapture(path) {
+		return fmt.Errorf("%w: %s is not an oplog capture", ErrInvalidArtifact, path)
+	}
+	if m.remote() {
+		return fmt.Errorf("replay %q: %w", path, ErrExecUnsupported)
+	}
+	host, database := m.restoreTarget()
+	if database != m.Database {
+		return fmt.Errorf("%w: oplog entries cannot be replayed into renamed database %q",
+			ErrRestoreFailed, database)
+	}
+
+	conn, cleanup, err := m.connArgs(ctx, host)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	args := append(conn,
+		mongoVerbosity(),
+		"--nsInclude="+m.Database+".*",
+		"--oplogReplay",
+	)
+	if !until.IsZero() {
+		args = append(args, fmt.Sprintf("--oplogLimit=%d:0", until.Unix()))
+	}
+	args = append(args, "--dir="+path)
+	cmd, err := m.command(ctx, nil, "mongorestore", args...)
+	if err != nil {
+		return err
+	}
+	cmd.Stdout = io.Discard
+	cmd.Stderr = m.stderr()
+
+	log.Info("oplog replay started",
+		"database", m.Database,
+		"engine", EngineMongoDB,
+		"source", path,
+		"until", until.Format(time.RFC3339),
+		"target_host", host,
+	)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("mongorestore oplog replay failed: %w", err)
+	}
+	log.Info("oplog replay completed",
+		"database", m.Database,
+		"engine", EngineMongoDB,
+		"source", path,
+	)
+	return nil
+}
+
+// isOplogCapture reports whether path is an oplog capture, possibly
+// unpacked under another name.
+func isOplogCapture(path string) bool {
+	_, err := os.Stat(filepath.Join(path, mongoOplogFile))
+	return err == nil
+}
@@ -0,0 +1,155 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kebairia/backup/internal/config"
+)
+
+func TestMongoConnArgs(t *testing.T) {
+	m := &MongoDB{
+		Host:           "localhost",
+		Port:           "27017",
+		Database:       "app",
+		URI:            "mongodb://m1:27017,m2:27017/?replicaSet=rs0",
+		ReadPreference: "secondary",
+		Password:       "s3cr3t",
+	}
+	// the URI and password go in a --config file, off the command line
+	got, cleanup, err := m.connArgs(context.Background(), m.Host)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+	path, ok := strings.CutPrefix(got[len(got)-1], "--config=")
+	if !ok || slices.ContainsFunc(got, func(arg string) bool { return strings.Contains(arg, "s3cr3t") }) {
+		t.Fatalf("connArgs = %v, want credentials in a --config file", got)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var settings mongoToolConfig
+	if err := json.Unmarshal(data, &settings); err != nil || settings.URI != m.URI || settings.Password != "s3cr3t" {
+		t.Errorf("config file = %s (%v), want the URI and password", data, err)
+	}
+	cleanup()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("config file left behind: %v", err)
+	}
+
+	// TLS flags come before the credentials, in each tool's spelling
+	m.TLS = config.MongoTLSConfig{Enabled: true, CACert: "/etc/ssl/ca.pem", ClientCert: "/etc/ssl/client.pem"}
+	got, cleanup, err = m.connArgs(context.Background(), m.Host)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cleanup()
+	if want := []string{"--ssl", "--sslCAFile=/etc/ssl/ca.pem", "--sslPEMKeyFile=/etc/ssl/client.pem"}; !slices.Equal(got[:3], want) {
+		t.Errorf("connArgs with tls = %v, want %v first", got, want)
+	}
+	if got := m.tlsArgs(true); !slices.Contains(got, "--tlsCertificateKeyFile=/etc/ssl/client.pem") {
+		t.Errorf("mongosh tls flags = %v", got)
+	}
+
+	// a restore retargeted to another host does not go through the URI
+	if got, cleanup, _ := m.connArgs(context.Background(), "staging"); !slices.Contains(got, "--host=staging") {
+		t.Errorf("connArgs(staging) = %v, want --host", got)
+	} else {
+		cleanup()
+	}
+
+	if got := m.dumpArgs(); !slices.Contains(got, "--db=app") || !slices.Contains(got, "--readPreference=secondary") {
+		t.Errorf("dumpArgs = %v", got)
+	}
+	m.Oplog = true
+	if got := m.dumpArgs(); !slices.Contains(got, "--oplog") || slices.Contains(got, "--db=app") {
+		t.Errorf("dumpArgs with oplog = %v, want --oplog without --db", got)
+	}
+}
+
+func TestMongoURIWithDatabase(t *testing.T) {
+	got, err := mongoURIWithDatabase("mongodb://m1:27017,m2:27017/?replicaSet=rs0&tls=true", "app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "mongodb://m1:27017,m2:27017/app?replicaSet=rs0&tls=true"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestExcludedCollections(t *testing.T) {
+	collections := []string{"orders", "users", "audit_2024", "audit_2025", "cache"}
+	got, err := excludedCollections(collections, config.Filter{Exclude: []string{"audit_*", "cache"}})
+	if want := []string{"audit_2024", "audit_2025", "cache"}; err != nil || !slices.Equal(got, want) {
+		t.Errorf("exclude = %v, %v, want %v", got, err, want)
+	}
+	got, err = excludedCollections(collections, config.Filter{Include: []string{"orders", "audit_*"}, Exclude: []string{"audit_2024"}})
+	if want := []string{"users", "audit_2024", "cache"}; err != nil || !slices.Equal(got, want) {
+		t.Errorf("include = %v, %v, want %v", got, err, want)
+	}
+	if _, err := excludedCollections(collections, config.Filter{Include: []string{"missing"}}); err == nil {
+		t.Error("filter selecting no collection accepted")
+	}
+}
+
+func TestMongoOplogCaptureAndReplay(t *testing.T) {
+	var cfg config.Config
+	cfg.Backup.Directory = t.TempDir()
+	cfg.Backup.Timeout = time.Minute
+	// mongosh reports the oldest oplog entry at 100s; mongodump writes it
+	executor := &fakeExecutor{stdout: "100"}
+	m, err := NewMongoDB(cfg,
+		WithMongoHost("db.test"),
+		WithMongoPort("27017"),
+		WithMongoDatabase("app"),
+		WithMongoMethod(MethodArchive),
+		WithMongoOplog(true),
+		WithMongoExecutor(executor),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path, err := m.BackupIncremental(context.Background(), time.Unix(200, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(filepath.Join(path, "oplog.bson")); err != nil || string(data) != "100" {
+		t.Fatalf("oplog.bson = %q, %v; want the entries dumped to stdout", data, err)
+	}
+	dump := executor.calls[1]
+	if dump[0] != "mongodump" || !slices.Contains(dump, "--collection=oplog.rs") ||
+		!slices.Contains(dump, `--query={"ts":{"$gt":{"$timestamp":{"t":200,"i":0}}}}`) {
+		t.Errorf("capture command = %v", dump)
+	}
+	if check, err := m.CheckBackup(path); check != "" || err != nil {
+		t.Errorf("CheckBackup(capture) = %q, %v; want no check", check, err)
+	}
+
+	if err := m.RestoreIncremental(context.Background(), path, time.Unix(500, 0)); err != nil {
+		t.Fatal(err)
+	}
+	replay := executor.calls[len(executor.calls)-1]
+	if replay[0] != "mongorestore" || !slices.Contains(replay, "--oplogReplay") ||
+		!slices.Contains(replay, "--oplogLimit=500:0") || !slices.Contains(replay, "--dir="+path) {
+		t.Errorf("replay command = %v", replay)
+	}
+	m.Retarget("", "app_copy")
+	if err := m.RestoreIncremental(context.Background(), path, time.Time{}); !errors.Is(err, ErrRestoreFailed) {
+		t.Errorf("replay into a renamed database = %v, want ErrRestoreFailed", err)
+	}
+
+	// the oplog rolled over since the base backup
+	if _, err := m.BackupIncremental(context.Background(), time.Unix(50, 0)); !errors.Is(err, ErrOplogGap) {
+		t.Errorf("BackupIncremental past the oplog window = %v, want ErrOplogGap", err)
+	}
+}
@@ -1,12 +1,14 @@
 package database
 
 import (
+	"bufio"
 	"context"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/kebairia/backup/internal/config"
@@ -25,11 +27,32 @@ type MySQL struct {
 	Database     string
 	Host         string
 	Port         string
-	Method       string // e.g. "dump"
+	Method       string // "dump" (mysqldump), "xtrabackup" or "mariabackup"
 	OutputDir    string
+	WorkDir      string // dumps are written here first, see WorkPath
 	TimeStampFmt string
+	NameTemplate string // artifact name, see config.ArtifactName
+	Instance     string // instance name, see config.ArtifactDir
+	DirTemplate  string // artifact directory, see config.ArtifactDir
 	Timeout      time.Duration
+	DataDir      string // server data directory, for physical restores
+	Labels       map[string]string
 	Logger       logger.Logger
+	// AllowOverwrite lets restores replace a target that holds data
+	AllowOverwrite bool
+
+	// ctx cancels running client commands (see WithMySQLContext)
+	ctx context.Context
+	// Vault lease of the dynamic credentials (see LeaseHolder)
+	credentialLease
+	// stderr of client commands (see StderrSetter)
+	stderrSink
+	// runs client commands (see WithMySQLExecutor)
+	toolExec
+
+	// Restore target overrides (see Retarget)
+	RestoreHost     string
+	RestoreDatabase string
 }
 
 // NewMySQL returns a MySQL configured from cfg plus any overrides.
@@ -42,14 +65,19 @@ func NewMySQL(cfg config.Config, opts ...MySQLOption) (*MySQL, error) {
 		Host:         cfg.MySQL.EngineDefaults.Host,
 		Port:         cfg.MySQL.EngineDefaults.Port,
 		Method:       cfg.MySQL.EngineDefaults.Method,
+		DataDir:      cfg.MySQL.EngineDefaults.DataDir,
 		OutputDir:    cfg.Backup.Directory,
+		WorkDir:      cfg.Backup.WorkDir,
 		TimeStampFmt: cfg.Backup.TimestampFmt,
+		NameTemplate: cfg.Backup.NameTemplate,
+		DirTemplate:  cfg.Backup.DirTemplate,
 		Timeout:      cfg.Backup.Timeout,
 		Logger:       log,
 	}
 	for _, opt := range opts {
 		opt(m)
 	}
+	m.Logger = logger.FromContext(m.ctx, m.Logger)
 	return m, nil
 }
 
@@ -92,6 +120,24 @@ func WithMySQLDatabase(db string) MySQLOption {
 	}
 }
 
+// WithMySQLMethod overrides the backup method.
+func WithMySQLMethod(method string) MySQLOption {
+	return func(m *MySQL) {
+		if method != "" {
+			m.Method = method
+		}
+	}
+}
+
+// WithMySQLDataDir overrides the data directory used by physical restores.
+func WithMySQLDataDir(dir string) MySQLOption {
+	return func(m *MySQL) {
+		if dir != "" {
+			m.DataDir = dir
+		}
+	}
+}
+
 // WithMySQLOutputDir overrides where backups are written.
 func WithMySQLOutputDir(dir string) MySQLOption {
 	return func(m *MySQL) {
@@ -101,6 +147,43 @@ func WithMySQLOutputDir(dir string) MySQLOption {
 	}
 }
 
+// WithMySQLInstance sets the instance name, available to backup.dir_template.
+func WithMySQLInstance(name string) MySQLOption {
+	return func(m *MySQL) {
+		m.Instance = name
+	}
+}
+
+// WithMySQLContext makes ctx cancel the mysql queries behind checks and
+// verification, e.g. when the run is interrupted.
+func WithMySQLContext(ctx context.Context) MySQLOption {
+	return func(m *MySQL) {
+		m.ctx = ctx
+	}
+}
+
+// WithMySQLExecutor runs mysqldump and mysql through e, e.g. inside the
+// database's pod.
+func WithMySQLExecutor(e CommandRunner) MySQLOption {
+	return func(m *MySQL) {
+		m.executor = e
+	}
+}
+
+// WithMySQLLabels sets the labels recorded with every backup.
+func WithMySQLLabels(labels map[string]string) MySQLOption {
+	return func(m *MySQL) {
+		m.Labels = labels
+	}
+}
+
+// WithMySQLAllowOverwrite lets restores replace a target holding data.
+func WithMySQLAllowOverwrite(allow bool) MySQLOption {
+	return func(m *MySQL) {
+		m.AllowOverwrite = allow
+	}
+}
+
 // WithMySQLTimestampFormat overrides timestamp format.
 func WithMySQLTimestampFormat(format string) MySQLOption {
 	return func(m *MySQL) {
@@ -110,18 +193,42 @@ func WithMySQLTimestampFormat(format string) MySQLOption {
 	}
 }
 
-// Backup runs `mysqldump` to back up the database into a timestamped .sql file.
-func (m *MySQL) Backup() (string, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), m.Timeout)
-	defer cancel()
+// artifactName renders the configured name template for a backup taken now.
+func (m *MySQL) artifactName() (string, error) {
+	now := time.Now()
+	return config.ArtifactName{
+		Engine:    mysqlEngine,
+		Database:  m.Database,
+		Host:      m.Host,
+		Method:    m.Method,
+		Timestamp: now.Format(m.TimeStampFmt),
+		Time:      now,
+	}.Render(m.NameTemplate)
+}
 
-	fileName := fmt.Sprintf("%s-%s.sql", time.Now().Format(m.TimeStampFmt), m.Database)
-	backupsDir := filepath.Join(m.OutputDir, mysqlEngine, m.Database)
-	backupPath := filepath.Join(backupsDir, fileName)
+// Backup runs `mysqldump` to back up the database into a timestamped .sql file,
+// or streams a physical backup with xtrabackup/mariabackup (see Method).
+func (m *MySQL) Backup(ctx context.Context) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
 
+	backupsDir := WorkPath(m.OutputDir, m.WorkDir, m.GetPath())
 	if err := os.MkdirAll(backupsDir, 0o755); err != nil {
 		return "", fmt.Errorf("mkdir %q: %w", backupsDir, err)
 	}
+	if m.isPhysical() {
+		// xtrabackup must run next to the data directory
+		if m.remote() {
+			return "", fmt.Errorf("%s method: %w", m.Method, ErrExecUnsupported)
+		}
+		return m.physicalBackup(ctx, backupsDir)
+	}
+
+	name, err := m.artifactName()
+	if err != nil {
+		return "", err
+	}
+	backupPath := filepath.Join(backupsDir, name+".sql")
 
 	// Build mysqldump args
 	args := []string{
@@ -130,12 +237,26 @@ func (m *MySQL) Backup() (string, error) {
 		"-u", m.Username,
 		"--databases", m.Database,
 		"--single-transaction",
-		"--result-file=" + backupPath,
 	}
-	cmd := exec.CommandContext(ctx, "mysqldump", args...)
-	// Pass MYSQL_PWD for non-interactive auth
-	cmd.Env = append(os.Environ(), "MYSQL_PWD="+m.Password)
-	cmd.Stderr = os.Stderr
+	args = append(args, verboseArgs()...)
+	// a remote mysqldump writes to stdout instead of the backup host's files
+	if !m.remote() {
+		args = append(args, "--result-file="+backupPath)
+	}
+	cmd, cleanup, err := m.clientCommand(ctx, "mysqldump", args...)
+	if err != nil {
+		return "", err
+	}
+	defer cleanup()
+	cmd.Stderr = m.stderr()
+	if m.remote() {
+		out, err := os.Create(backupPath)
+		if err != nil {
+			return "", fmt.Errorf("create %q: %w", backupPath, err)
+		}
+		defer out.Close()
+		cmd.Stdout = out
+	}
 
 	m.Logger.Info("backup started",
 		"database", m.Database,
@@ -144,31 +265,42 @@ func (m *MySQL) Backup() (string, error) {
 	)
 	start := time.Now()
 	if err := cmd.Run(); err != nil {
-		return "", fmt.Errorf("mysqldump failed: %w", err)
+		return backupPath, fmt.Errorf("mysqldump failed: %w", err)
 	}
 	m.Logger.Info("backup completed", "duration", time.Since(start).String())
 
 	return backupPath, nil
 }
 
-// Restore runs `mysql` to restore from a .sql file.
-func (m *MySQL) Restore(backupFile string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), m.Timeout)
+// Restore runs `mysql` to restore from a .sql file. Physical .xbstream
+// backups are prepared and copied back instead (see physicalRestore).
+func (m *MySQL) Restore(ctx context.Context, backupFile string) error {
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
 	defer cancel()
 
+	if strings.HasSuffix(backupFile, xbstreamExt) {
+		if m.remote() {
+			return fmt.Errorf("restore %q: %w", backupFile, ErrExecUnsupported)
+		}
+		return m.physicalRestore(ctx, backupFile)
+	}
+
 	// Ensure file exists
 	if _, err := os.Stat(backupFile); err != nil {
 		return fmt.Errorf("backup file %q not found: %w", backupFile, err)
 	}
 
-	cmd := exec.CommandContext(ctx, "mysql",
-		"-h", m.Host,
+	host, database := m.restoreTarget()
+
+	cmd, cleanup, err := m.clientCommand(ctx, "mysql",
+		"-h", host,
 		"-P", m.Port,
 		"-u", m.Username,
-		m.Database,
 	)
-	// Pass MYSQL_PWD for non-interactive auth
-	cmd.Env = append(os.Environ(), "MYSQL_PWD="+m.Password)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
 
 	file, err := os.Open(backupFile)
 	if err != nil {
@@ -176,10 +308,19 @@ func (m *MySQL) Restore(backupFile string) error {
 	}
 	defer file.Close()
 	cmd.Stdin = file
+	if database != m.Database {
+		// The dump carries CREATE DATABASE/USE statements for the source name
+		cmd.Stdin = renameMySQLDatabase(file, m.Database, database)
+	}
 	cmd.Stdout = io.Discard
-	cmd.Stderr = os.Stderr
+	cmd.Stderr = m.stderr()
 
-	m.Logger.Info("restore started", "database", m.Database, "engine", mysqlEngine)
+	m.Logger.Info("restore started",
+		"database", m.Database,
+		"engine", mysqlEngine,
+		"target_host", host,
+		"target_database", database,
+	)
 	start := time.Now()
 	if err := cmd.Run(); err != nil {
 		return fmt.Errorf("mysql restore failed: %w", err)
@@ -188,11 +329,249 @@ func (m *MySQL) Restore(backupFile string) error {
 	return nil
 }
 
+// Retarget redirects subsequent restores to another host and/or database.
+func (m *MySQL) Retarget(host, database string) {
+	m.RestoreHost = host
+	m.RestoreDatabase = database
+}
+
+// restoreTarget returns the host and database a restore should write to.
+func (m *MySQL) restoreTarget() (host, database string) {
+	host, database = m.Host, m.Database
+	if m.RestoreHost != "" {
+		host = m.RestoreHost
+	}
+	if m.RestoreDatabase != "" {
+		database = m.RestoreDatabase
+	}
+	return host, database
+}
+
+// ValidateArtifact scans a mysqldump file for its header, completion marker,
+// and CREATE TABLE statements without connecting to a server. Physical
+// backups are extracted and their checkpoints checked instead.
+func (m *MySQL) ValidateArtifact(path string) ([]string, error) {
+	if strings.HasSuffix(path, xbstreamExt) {
+		return m.validatePhysical(path)
+	}
+	return scanSQLDump(path, "MySQL dump", "Dump completed")
+}
+
+// PrepareTarget is a no-op: the dump creates the target database.
+func (m *MySQL) PrepareTarget() error { return nil }
+
+// CountObjects returns the row count of every table in the restore target.
+func (m *MySQL) CountObjects() (map[string]int64, error) {
+	_, database := m.restoreTarget()
+	tables, err := m.TargetObjects()
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int64)
+	for _, table := range tables {
+		out, err := m.query(fmt.Sprintf("SELECT '%s', COUNT(*) FROM `%s`.`%s`", table, database, table))
+		if err != nil {
+			return nil, err
+		}
+		tableCounts, err := parseCounts(out)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range tableCounts {
+			counts[k] = v
+		}
+	}
+	return counts, nil
+}
+
+// TargetObjects returns the tables of the restore target database; none
+// when it does not exist.
+func (m *MySQL) TargetObjects() ([]string, error) {
+	_, database := m.restoreTarget()
+	out, err := m.query(fmt.Sprintf(
+		"SELECT table_name FROM information_schema.tables WHERE table_schema = '%s' AND table_type = 'BASE TABLE'",
+		database,
+	))
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(out), nil
+}
+
+// OverwriteAllowed reports whether the instance sets allow_overwrite.
+func (m *MySQL) OverwriteAllowed() bool { return m.AllowOverwrite }
+
+// DropTarget drops the restore target database.
+func (m *MySQL) DropTarget() error {
+	_, database := m.restoreTarget()
+	if database == m.Database {
+		return fmt.Errorf("%w: refusing to drop source database %q", ErrVerifyFailed, database)
+	}
+	_, err := m.query(fmt.Sprintf("DROP DATABASE IF EXISTS `%s`", database))
+	return err
+}
+
+// query runs sql with the mysql client against the restore host and returns
+// tab-separated output without column headers.
+func (m *MySQL) query(sql string) (string, error) {
+	host, _ := m.restoreTarget()
+	return m.mysql(host, sql)
+}
+
+// clientCommand returns a command running the MySQL client tool name with
+// the password in a temporary option file only the current user can read
+// (--defaults-extra-file, which must come first), or in MYSQL_PWD for
+// remote tools, which cannot read the backup host's files. Call the
+// returned func once the command ran.
+func (m *MySQL) clientCommand(ctx context.Context, name string, args ...string) (*exec.Cmd, func(), error) {
+	if m.remote() {
+		cmd, err := m.command(ctx, []string{"MYSQL_PWD=" + m.Password}, name, args...)
+		return cmd, func() {}, err
+	}
+	path, cleanup, err := mysqlOptionFile(m.Password, "client")
+	if err != nil {
+		return nil, nil, err
+	}
+	cmd, err := m.command(ctx, nil, name, append([]string{"--defaults-extra-file=" + path}, args...)...)
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	return cmd, cleanup, nil
+}
+
+// mysqlOptionFile writes a temporary option file setting password in each
+// of groups, and returns its path and a func removing it.
+func mysqlOptionFile(password string, groups ...string) (string, func(), error) {
+	quoted := `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(password) + `"`
+	var b strings.Builder
+	for _, group := range groups {
+		fmt.Fprintf(&b, "[%s]\npassword=%s\n", group, quoted)
+	}
+	return secretFile("bacli-mysql-*.cnf", b.String())
+}
+
+// mysql runs sql on host and returns the tab-separated output.
+func (m *MySQL) mysql(host, sql string) (string, error) {
+	ctx, cancel := context.WithTimeout(orBackground(m.ctx), m.Timeout)
+	defer cancel()
+
+	cmd, cleanup, err := m.clientCommand(ctx, "mysql",
+		"-h", host,
+		"-P", m.Port,
+		"-u", m.Username,
+		"--batch", "--skip-column-names",
+		"-e", sql,
+	)
+	if err != nil {
+		return "", err
+	}
+	defer cleanup()
+	cmd.Stderr = m.stderr()
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("mysql query failed: %w", err)
+	}
+	return string(out), nil
+}
+
+// EstimateSize returns the data and index size of the source database from
+// information_schema.
+func (m *MySQL) EstimateSize() (int64, error) {
+	out, err := m.mysql(m.Host, fmt.Sprintf(
+		"SELECT COALESCE(SUM(data_length + index_length), 0) "+
+			"FROM information_schema.tables WHERE table_schema = '%s'",
+		strings.ReplaceAll(m.Database, "'", "''"),
+	))
+	if err != nil {
+		return 0, err
+	}
+	return parseSize(out)
+}
+
+// renameMySQLDatabase rewrites the CREATE DATABASE and USE statements that
+// mysqldump --databases emits so the dump loads into target instead of source.
+func renameMySQLDatabase(r io.Reader, source, target string) io.Reader {
+	from := "`" + source + "`"
+	to := "`" + target + "`"
+	pr, pw := io.Pipe()
+	go func() {
+		reader := bufio.NewReader(r)
+		for {
+			line, err := reader.ReadString('\n')
+			if strings.HasPrefix(line, "CREATE DATABASE") ||
+				strings.HasPrefix(line, "USE ") {
+				line = strings.Replace(line, from, to, 1)
+			}
+			if _, werr := io.WriteString(pw, line); werr != nil {
+				pw.CloseWithError(werr)
+				return
+			}
+			if err != nil {
+				if err == io.EOF {
+					err = nil
+				}
+				pw.CloseWithError(err)
+				return
+			}
+		}
+	}()
+	return pr
+}
+
+// ListDatabases returns the databases on the server.
+func (m *MySQL) ListDatabases() ([]string, error) {
+	out, err := m.mysql(m.Host, "SHOW DATABASES")
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(out), nil
+}
+
+// ServerVersion connects with the configured credentials and returns the
+// server version.
+func (m *MySQL) ServerVersion() (string, error) {
+	out, err := m.mysql(m.Host, "SELECT VERSION()")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out), nil
+}
+
 // GetName returns database name.
 func (m *MySQL) GetName() string { return m.Database }
 
 // GetEngine returns engine name.
 func (m *MySQL) GetEngine() string { return mysqlEngine }
 
-// GetPath returns the base backup path.
-func (m *MySQL) GetPath() string { return filepath.Join(m.OutputDir, mysqlEngine) }
+// GetPath returns the directory holding the artifacts and catalog of the
+// database, <output dir>/<engine>/<backup.dir_template>.
+func (m *MySQL) GetPath() string {
+	return artifactDir(m.OutputDir, m.DirTemplate, config.ArtifactDir{
+		Engine:   mysqlEngine,
+		Instance: m.Instance,
+		Host:     m.Host,
+		Database: m.Database,
+	})
+}
+
+// GetInstance returns the name of the configured instance.
+func (m *MySQL) GetInstance() string { return m.Instance }
+
+// PlainSQL reports whether the method writes mysqldump SQL scripts.
+func (m *MySQL) PlainSQL() bool { return !m.isPhysical() }
+
+// ArtifactExt returns the extension of the single-file artifacts of the
+// configured method.
+func (m *MySQL) ArtifactExt() (string, bool) {
+	if m.isPhysical() {
+		return xbstreamExt, true
+	}
+	return ".sql", true
+}
+
+// GetHost returns the database host.
+func (m *MySQL) GetHost() string { return m.Host }
+
+// GetLabels returns the labels recorded with every backup.
+func (m *MySQL) GetLabels() map[string]string { return m.Labels }
@@ -0,0 +1,132 @@
+package database
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/kebairia/backup/internal/config"
+)
+
+// WithPostgresFlavor adapts dumps and restores to TimescaleDB or Citus
+// (config.FlavorTimescaleDB, config.FlavorCitus), and lists the extensions
+// the restore target must provide.
+func WithPostgresFlavor(flavor string, extensions []string) PostgresOption {
+	return func(p *Postgres) {
+		p.Flavor = flavor
+		p.Extensions = extensions
+	}
+}
+
+// citusNodesQuery lists the primary nodes of a Citus cluster that are not
+// active.
+const citusNodesQuery = `SELECT nodename || ':' || nodeport
+FROM pg_dist_node
+WHERE noderole = 'primary' AND NOT isactive
+ORDER BY 1`
+
+// beforeBackup prepares a Citus cluster for a dump of its coordinator: every
+// worker must be active, and a restore point is created on all nodes at
+// once, so that point-in-time recovery of the workers can meet the dump.
+func (p *Postgres) beforeBackup() error {
+	if p.Flavor != config.FlavorCitus {
+		return nil
+	}
+	if err := checkCitusNodes(p.psql(p.Host, p.Database, citusNodesQuery)); err != nil {
+		return err
+	}
+	point := "bacli-" + time.Now().UTC().Format("20060102T150405Z")
+	if _, err := p.psql(p.Host, p.Database, fmt.Sprintf("SELECT citus_create_restore_point('%s')", point)); err != nil {
+		return fmt.Errorf("citus restore point: %w", err)
+	}
+	p.Logger.Info("citus restore point created",
+		"database", p.Database,
+		"restore_point", point,
+	)
+	return nil
+}
+
+// beforeRestore checks that the restore target provides the required
+// extensions and, for Citus, active workers to distribute the tables to.
+// TimescaleDB targets are put in restoring mode, as timescaledb's catalog
+// cannot be loaded with its triggers active (see afterRestore).
+func (p *Postgres) beforeRestore() error {
+	if err := p.checkExtensions(); err != nil {
+		return err
+	}
+	_, database := p.restoreTarget()
+	switch p.Flavor {
+	case config.FlavorCitus:
+		return checkCitusNodes(p.query(database, citusNodesQuery))
+	case config.FlavorTimescaleDB:
+		for _, sql := range []string{
+			"CREATE EXTENSION IF NOT EXISTS timescaledb",
+			"SELECT timescaledb_pre_restore()",
+		} {
+			if _, err := p.query(database, sql); err != nil {
+				return fmt.Errorf("timescaledb pre-restore: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// afterRestore takes TimescaleDB targets out of restoring mode, whether the
+// restore succeeded or not.
+func (p *Postgres) afterRestore() error {
+	if p.Flavor != config.FlavorTimescaleDB {
+		return nil
+	}
+	_, database := p.restoreTarget()
+	if _, err := p.query(database, "SELECT timescaledb_post_restore()"); err != nil {
+		return fmt.Errorf("timescaledb post-restore: %w", err)
+	}
+	return nil
+}
+
+// requiredExtensions returns the configured extensions plus the flavor's.
+func (p *Postgres) requiredExtensions() []string {
+	required := slices.Clone(p.Extensions)
+	if p.Flavor != "" && !slices.Contains(required, p.Flavor) {
+		required = append(required, p.Flavor)
+	}
+	return required
+}
+
+// checkExtensions checks that the server of the restore target has the
+// required extensions available.
+func (p *Postgres) checkExtensions() error {
+	required := p.requiredExtensions()
+	if len(required) == 0 {
+		return nil
+	}
+	out, err := p.query("postgres", "SELECT name FROM pg_available_extensions")
+	if err != nil {
+		return fmt.Errorf("list extensions: %w", err)
+	}
+	available := strings.Fields(out)
+	var missing []string
+	for _, name := range required {
+		if !slices.Contains(available, name) {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		host, _ := p.restoreTarget()
+		return fmt.Errorf("%w: %s lacks extensions %s", ErrMissingExtension, host, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// checkCitusNodes fails when the output of citusNodesQuery lists inactive
+// nodes.
+func checkCitusNodes(out string, err error) error {
+	if err != nil {
+		return fmt.Errorf("list citus nodes: %w", err)
+	}
+	if inactive := strings.Fields(out); len(inactive) > 0 {
+		return fmt.Errorf("citus nodes inactive: %s", strings.Join(inactive, ", "))
+	}
+	return nil
+}
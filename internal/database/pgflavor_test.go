@@ -0,0 +1,60 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/kebairia/backup/internal/config"
+)
+
+// lastArgs returns the tool and the last argument of each call, e.g. the
+// SQL of psql -c.
+func lastArgs(calls [][]string) [][2]string {
+	steps := make([][2]string, len(calls))
+	for i, call := range calls {
+		steps[i] = [2]string{call[0], call[len(call)-1]}
+	}
+	return steps
+}
+
+func TestTimescaleRestoreHooks(t *testing.T) {
+	executor := &fakeExecutor{stdout: "plpgsql\ntimescaledb\n"}
+	p := fakePostgres(t, executor)
+	p.Flavor = config.FlavorTimescaleDB
+	backupFile := filepath.Join(t.TempDir(), "billing.dump")
+	if err := os.WriteFile(backupFile, []byte("PGDMP"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.Restore(context.Background(), backupFile); err != nil {
+		t.Fatal(err)
+	}
+	want := [][2]string{
+		{"psql", "SELECT name FROM pg_available_extensions"},
+		{"psql", "CREATE EXTENSION IF NOT EXISTS timescaledb"},
+		{"psql", "SELECT timescaledb_pre_restore()"},
+		{"pg_restore", backupFile},
+		{"psql", "SELECT timescaledb_post_restore()"},
+	}
+	if got := lastArgs(executor.calls); !slices.Equal(got, want) {
+		t.Errorf("commands = %v, want %v", got, want)
+	}
+}
+
+func TestRestoreRequiresExtensions(t *testing.T) {
+	executor := &fakeExecutor{stdout: "plpgsql\n"}
+	p := fakePostgres(t, executor)
+	p.Extensions = []string{"postgis"}
+
+	err := p.Restore(context.Background(), os.Args[0])
+	if !errors.Is(err, ErrMissingExtension) {
+		t.Fatalf("Restore() = %v, want ErrMissingExtension", err)
+	}
+	if len(executor.calls) != 1 {
+		t.Errorf("ran %d commands, want only the extension check", len(executor.calls))
+	}
+}
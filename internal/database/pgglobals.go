@@ -0,0 +1,189 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// PostgresGlobalsName names the globals artifact of an instance, in place
+// of a database name.
+const PostgresGlobalsName = "_globals"
+
+// PostgresGlobals backs up the cluster-wide objects of a Postgres instance
+// (roles, tablespaces and their grants), which pg_dump leaves out of
+// database dumps, with `pg_dumpall --globals-only`. Its artifact goes
+// through the same pipeline as the instance's databases and is restored
+// before them (see ClusterScoped).
+type PostgresGlobals struct {
+	pg *Postgres
+}
+
+// NewPostgresGlobals returns the globals of the instance p connects to.
+func NewPostgresGlobals(p *Postgres) *PostgresGlobals {
+	copied := *p
+	copied.Database = PostgresGlobalsName
+	copied.Method = "plain"
+	return &PostgresGlobals{pg: &copied}
+}
+
+// Backup writes the globals of the instance to a timestamped .sql file.
+func (g *PostgresGlobals) Backup(ctx context.Context) (backupPath string, err error) {
+	p := g.pg
+	ctx, cancel := context.WithTimeoutCause(ctx, p.Timeout, ErrTimeout)
+	defer cancel()
+
+	name, err := p.artifactName()
+	if err != nil {
+		return "", err
+	}
+	backupPath = filepath.Join(WorkPath(p.OutputDir, p.WorkDir, p.GetPath()), name+".sql")
+	if err := os.MkdirAll(filepath.Dir(backupPath), 0o755); err != nil {
+		return "", fmt.Errorf("mkdir %q: %w", filepath.Dir(backupPath), err)
+	}
+
+	env, cleanup, err := p.passwordEnv()
+	if err != nil {
+		return "", err
+	}
+	defer cleanup()
+	// written to stdout, so that remote executors work alike
+	cmd, err := p.command(ctx, env, "pg_dumpall",
+		"-h", p.Host,
+		"-p", p.Port,
+		"-U", p.Username,
+		"--globals-only",
+	)
+	if err != nil {
+		return "", err
+	}
+	out, err := os.Create(backupPath)
+	if err != nil {
+		return "", fmt.Errorf("create %q: %w", backupPath, err)
+	}
+	defer out.Close()
+	cmd.Stdout = out
+	cmd.Stderr = p.stderr()
+
+	p.Logger.Info("backup started",
+		"database", p.Database,
+		"engine", EnginePostgres,
+		"method", "globals",
+		"path", backupPath,
+	)
+	startTime := time.Now()
+	if err := cmd.Run(); err != nil {
+		p.Logger.Error("backup failed",
+			"database", p.Database,
+			"engine", EnginePostgres,
+			"path", backupPath,
+			"error", err.Error(),
+		)
+		return backupPath, fmt.Errorf("pg_dumpall failed: %w", err)
+	}
+	p.Logger.Info("backup completed",
+		"database", p.Database,
+		"engine", EnginePostgres,
+		"path", backupPath,
+		"duration", time.Since(startTime).String(),
+	)
+	return backupPath, nil
+}
+
+// Restore replays the globals with psql, connected to the postgres
+// database of the restore host. Statements that fail, e.g. CREATE ROLE for
+// a role the target already has, do not stop the replay.
+func (g *PostgresGlobals) Restore(ctx context.Context, backupFile string) error {
+	p := g.pg
+	ctx, cancel := context.WithTimeoutCause(ctx, p.Timeout, ErrTimeout)
+	defer cancel()
+
+	in, err := os.Open(backupFile)
+	if err != nil {
+		return fmt.Errorf("backup file %q not found: %w", backupFile, err)
+	}
+	defer in.Close()
+
+	host, _ := p.restoreTarget()
+	env, cleanup, err := p.passwordEnv()
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	cmd, err := p.command(ctx, env, "psql",
+		"-h", host,
+		"-p", p.Port,
+		"-U", p.Username,
+		"-d", "postgres",
+		"-X", "-q",
+	)
+	if err != nil {
+		return err
+	}
+	cmd.Stdin = in
+	cmd.Stdout = io.Discard
+	cmd.Stderr = p.stderr()
+
+	p.Logger.Info("restore started",
+		"database", p.Database,
+		"engine", EnginePostgres,
+		"method", "globals",
+		"source", backupFile,
+		"target_host", host,
+	)
+	startTime := time.Now()
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("restore failed (globals): %w", err)
+	}
+	p.Logger.Info("restore completed",
+		"database", p.Database,
+		"engine", EnginePostgres,
+		"source", backupFile,
+		"duration", time.Since(startTime).String(),
+	)
+	return nil
+}
+
+// Retarget redirects subsequent restores to another host; globals belong
+// to no database, so database is ignored.
+func (g *PostgresGlobals) Retarget(host, _ string) {
+	g.pg.RestoreHost = host
+}
+
+// ClusterScoped reports that the globals are restored before the databases
+// of the instance.
+func (g *PostgresGlobals) ClusterScoped() bool { return true }
+
+// DumpTool returns pg_dumpall.
+func (g *PostgresGlobals) DumpTool() string { return "pg_dumpall" }
+
+// RestoreTool returns psql.
+func (g *PostgresGlobals) RestoreTool() string { return "psql" }
+
+// SetStderr routes the stderr of subsequent client commands to w.
+func (g *PostgresGlobals) SetStderr(w io.Writer) { g.pg.SetStderr(w) }
+
+// GetName returns PostgresGlobalsName.
+func (g *PostgresGlobals) GetName() string { return g.pg.Database }
+
+// GetEngine returns the engine name.
+func (g *PostgresGlobals) GetEngine() string { return EnginePostgres }
+
+// GetHost returns the database host.
+func (g *PostgresGlobals) GetHost() string { return g.pg.Host }
+
+// GetPath returns the directory holding the globals artifacts, laid out as
+// those of a database named PostgresGlobalsName.
+func (g *PostgresGlobals) GetPath() string { return g.pg.GetPath() }
+
+// GetInstance returns the name of the configured instance.
+func (g *PostgresGlobals) GetInstance() string { return g.pg.Instance }
+
+// GetLeaseID returns the Vault lease of the instance's credentials.
+func (g *PostgresGlobals) GetLeaseID() string { return g.pg.GetLeaseID() }
+
+// GetLabels returns the labels recorded with every backup.
+func (g *PostgresGlobals) GetLabels() map[string]string { return g.pg.Labels }
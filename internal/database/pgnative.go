@@ -0,0 +1,450 @@
+package database
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// PostgresMethodNative backs up and restores table data over the wire
+// protocol (COPY) instead of running pg_dump/pg_restore, so no PostgreSQL
+// client binaries are needed. Only table data is captured: the schema must
+// already exist on the restore target (e.g. created by migrations).
+const PostgresMethodNative = "native"
+
+const (
+	// nativeExt is the extension of native COPY archives.
+	nativeExt = ".copy.tar"
+	// nativeManifest is the archive entry describing the dumped tables.
+	nativeManifest = "manifest.json"
+)
+
+// nativeArchive is the manifest of a native COPY archive. Tables are listed
+// in restore order: referenced tables before the tables referencing them.
+type nativeArchive struct {
+	Database      string        `json:"database"`
+	ServerVersion string        `json:"server_version"`
+	CreatedAt     time.Time     `json:"created_at"`
+	Tables        []nativeTable `json:"tables"`
+}
+
+// nativeTable is one table of a native COPY archive.
+type nativeTable struct {
+	Schema  string   `json:"schema"`
+	Name    string   `json:"name"`
+	Columns []string `json:"columns"`
+	Rows    int64    `json:"rows"`
+	File    string   `json:"file"` // archive entry holding the COPY text output
+}
+
+// ident returns the quoted, schema-qualified table name.
+func (t nativeTable) ident() string {
+	return pgx.Identifier{t.Schema, t.Name}.Sanitize()
+}
+
+// columnList returns the quoted column list of t.
+func (t nativeTable) columnList() string {
+	quoted := make([]string, len(t.Columns))
+	for i, column := range t.Columns {
+		quoted[i] = pgx.Identifier{column}.Sanitize()
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// nativeTablesQuery lists user tables with their copyable columns.
+const nativeTablesQuery = `SELECT n.nspname, c.relname,
+  array_agg(a.attname::text ORDER BY a.attnum)
+FROM pg_class c
+JOIN pg_namespace n ON n.oid = c.relnamespace
+JOIN pg_attribute a ON a.attrelid = c.oid AND a.attnum > 0 AND NOT a.attisdropped AND a.attgenerated = ''
+WHERE c.relkind = 'r'
+  AND n.nspname NOT IN ('pg_catalog', 'information_schema')
+  AND n.nspname NOT LIKE 'pg_toast%'
+  AND n.nspname NOT LIKE 'pg_temp%'
+GROUP BY n.nspname, c.relname
+ORDER BY n.nspname, c.relname`
+
+// nativeDependenciesQuery lists foreign keys between user tables.
+const nativeDependenciesQuery = `SELECT cn.nspname, c.relname, rn.nspname, r.relname
+FROM pg_constraint k
+JOIN pg_class c ON c.oid = k.conrelid
+JOIN pg_namespace cn ON cn.oid = c.relnamespace
+JOIN pg_class r ON r.oid = k.confrelid
+JOIN pg_namespace rn ON rn.oid = r.relnamespace
+WHERE k.contype = 'f' AND k.conrelid <> k.confrelid`
+
+// connect opens a connection to database on host with the configured
+// credentials. libpq environment variables (PGSSLMODE, ...) still apply.
+func (p *Postgres) connect(ctx context.Context, host, database string) (*pgx.Conn, error) {
+	if p.Port != "" {
+		host = net.JoinHostPort(host, p.Port)
+	}
+	dsn := url.URL{
+		Scheme: "postgres",
+		User:   url.UserPassword(p.Username, p.Password),
+		Host:   host,
+		Path:   "/" + database,
+	}
+	cfg, err := pgx.ParseConfig(dsn.String())
+	if err != nil {
+		return nil, fmt.Errorf("connection config: %w", err)
+	}
+	conn, err := pgx.ConnectConfig(ctx, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("connect to %s/%s: %w", host, database, err)
+	}
+	return conn, nil
+}
+
+// nativeBackup streams every user table with COPY ... TO STDOUT into a
+// timestamped tar archive, reading all tables from one repeatable-read
+// snapshot.
+func (p *Postgres) nativeBackup(ctx context.Context) (backupPath string, err error) {
+	backupsDir := WorkPath(p.OutputDir, p.WorkDir, p.GetPath())
+	if err := os.MkdirAll(backupsDir, 0o755); err != nil {
+		return "", fmt.Errorf("mkdir %q: %w", backupsDir, err)
+	}
+	name, err := p.artifactName()
+	if err != nil {
+		return "", err
+	}
+	backupPath = filepath.Join(backupsDir, name+nativeExt)
+
+	conn, err := p.connect(ctx, p.Host, p.Database)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close(context.WithoutCancel(ctx))
+
+	tx, err := conn.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return "", fmt.Errorf("begin snapshot: %w", err)
+	}
+	defer tx.Rollback(context.WithoutCancel(ctx))
+
+	archive := nativeArchive{Database: p.Database, CreatedAt: time.Now()}
+	if err := tx.QueryRow(ctx, "SHOW server_version").Scan(&archive.ServerVersion); err != nil {
+		return "", fmt.Errorf("server version: %w", err)
+	}
+	if archive.Tables, err = nativeTables(ctx, tx); err != nil {
+		return "", err
+	}
+
+	out, err := os.Create(backupPath)
+	if err != nil {
+		return "", fmt.Errorf("create %q: %w", backupPath, err)
+	}
+	defer out.Close()
+	tw := tar.NewWriter(out)
+
+	p.Logger.Info("backup started",
+		"database", p.Database,
+		"engine", EnginePostgres,
+		"method", p.Method,
+		"tables", len(archive.Tables),
+		"path", backupPath,
+	)
+	start := time.Now()
+	for i := range archive.Tables {
+		table := &archive.Tables[i]
+		table.File = fmt.Sprintf("data/%05d.copy", i)
+		sql := fmt.Sprintf("COPY %s (%s) TO STDOUT", table.ident(), table.columnList())
+		if table.Rows, err = copyToEntry(ctx, tx.Conn(), tw, backupsDir, table.File, sql); err != nil {
+			return backupPath, fmt.Errorf("copy %s: %w", table.ident(), err)
+		}
+	}
+
+	manifest, err := json.MarshalIndent(archive, "", "  ")
+	if err != nil {
+		return backupPath, fmt.Errorf("encode manifest: %w", err)
+	}
+	if err := writeEntry(tw, nativeManifest, manifest); err != nil {
+		return backupPath, err
+	}
+	if err := tw.Close(); err != nil {
+		return backupPath, fmt.Errorf("write %q: %w", backupPath, err)
+	}
+	if err := out.Sync(); err != nil {
+		return backupPath, fmt.Errorf("sync %q: %w", backupPath, err)
+	}
+	p.Logger.Info("backup completed",
+		"database", p.Database,
+		"engine", EnginePostgres,
+		"path", backupPath,
+		"duration", time.Since(start).String(),
+	)
+	return backupPath, nil
+}
+
+// nativeTables lists the user tables visible in tx, in restore order.
+func nativeTables(ctx context.Context, tx pgx.Tx) ([]nativeTable, error) {
+	rows, err := tx.Query(ctx, nativeTablesQuery)
+	if err != nil {
+		return nil, fmt.Errorf("list tables: %w", err)
+	}
+	tables, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (nativeTable, error) {
+		var t nativeTable
+		err := row.Scan(&t.Schema, &t.Name, &t.Columns)
+		return t, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list tables: %w", err)
+	}
+
+	rows, err = tx.Query(ctx, nativeDependenciesQuery)
+	if err != nil {
+		return nil, fmt.Errorf("list foreign keys: %w", err)
+	}
+	deps := make(map[string][]string)
+	var child, parent [2]string
+	_, err = pgx.ForEachRow(rows, []any{&child[0], &child[1], &parent[0], &parent[1]}, func() error {
+		key := child[0] + "." + child[1]
+		deps[key] = append(deps[key], parent[0]+"."+parent[1])
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list foreign keys: %w", err)
+	}
+	return restoreOrder(tables, deps), nil
+}
+
+// restoreOrder sorts tables so that every table follows the tables its
+// foreign keys reference. deps maps "schema.table" to the tables it
+// references. Tables in a reference cycle keep their original order.
+func restoreOrder(tables []nativeTable, deps map[string][]string) []nativeTable {
+	ordered := make([]nativeTable, 0, len(tables))
+	placed := make(map[string]bool, len(tables))
+	known := make(map[string]bool, len(tables))
+	for _, t := range tables {
+		known[t.Schema+"."+t.Name] = true
+	}
+	for len(ordered) < len(tables) {
+		progress := false
+		for _, t := range tables {
+			key := t.Schema + "." + t.Name
+			if placed[key] {
+				continue
+			}
+			ready := true
+			for _, dep := range deps[key] {
+				if known[dep] && !placed[dep] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				ordered = append(ordered, t)
+				placed[key] = true
+				progress = true
+			}
+		}
+		if !progress {
+			// break the cycle with the first remaining table
+			for _, t := range tables {
+				key := t.Schema + "." + t.Name
+				if !placed[key] {
+					ordered = append(ordered, t)
+					placed[key] = true
+					break
+				}
+			}
+		}
+	}
+	return ordered
+}
+
+// copyToEntry runs a COPY ... TO STDOUT statement and adds its output to tw
+// as name. The output is spooled to a hidden file in dir first because tar
+// headers need the size up front. It returns the number of rows copied.
+func copyToEntry(ctx context.Context, conn *pgx.Conn, tw *tar.Writer, dir, name, sql string) (int64, error) {
+	spool, err := os.CreateTemp(dir, ".copy-*")
+	if err != nil {
+		return 0, fmt.Errorf("create spool file: %w", err)
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+
+	tag, err := conn.PgConn().CopyTo(ctx, spool, sql)
+	if err != nil {
+		return 0, err
+	}
+	size, err := spool.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+	header := &tar.Header{Name: name, Mode: 0o644, Size: size, ModTime: time.Now()}
+	if err := tw.WriteHeader(header); err != nil {
+		return 0, fmt.Errorf("write %s: %w", name, err)
+	}
+	if _, err := io.Copy(tw, spool); err != nil {
+		return 0, fmt.Errorf("write %s: %w", name, err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// writeEntry adds data to tw as name.
+func writeEntry(tw *tar.Writer, name string, data []byte) error {
+	header := &tar.Header{Name: name, Mode: 0o644, Size: int64(len(data)), ModTime: time.Now()}
+	if err := tw.WriteHeader(header); err != nil {
+		return fmt.Errorf("write %s: %w", name, err)
+	}
+	if _, err := tw.Write(data); err != nil {
+		return fmt.Errorf("write %s: %w", name, err)
+	}
+	return nil
+}
+
+// readNativeManifest reads the manifest of a native COPY archive.
+func readNativeManifest(path string) (nativeArchive, error) {
+	var archive nativeArchive
+	file, err := os.Open(path)
+	if err != nil {
+		return archive, fmt.Errorf("backup file %q not found: %w", path, err)
+	}
+	defer file.Close()
+	tr := tar.NewReader(file)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return archive, fmt.Errorf("%w: %s has no %s", ErrInvalidArtifact, path, nativeManifest)
+		}
+		if err != nil {
+			return archive, fmt.Errorf("%w: read %s: %v", ErrInvalidArtifact, path, err)
+		}
+		if header.Name != nativeManifest {
+			continue
+		}
+		if err := json.NewDecoder(tr).Decode(&archive); err != nil {
+			return archive, fmt.Errorf("%w: decode %s: %v", ErrInvalidArtifact, nativeManifest, err)
+		}
+		return archive, nil
+	}
+}
+
+// nativeRestore loads a native COPY archive into the restore target in a
+// single transaction. Listed tables are truncated first, like pg_restore -c;
+// they must already exist with the archived columns.
+func (p *Postgres) nativeRestore(ctx context.Context, backupFile string) error {
+	archive, err := readNativeManifest(backupFile)
+	if err != nil {
+		return err
+	}
+	host, database := p.restoreTarget()
+	conn, err := p.connect(ctx, host, database)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.WithoutCancel(ctx))
+
+	p.Logger.Info("restore started",
+		"database", p.Database,
+		"engine", EnginePostgres,
+		"method", PostgresMethodNative,
+		"source", backupFile,
+		"target_host", host,
+		"target_database", database,
+	)
+	start := time.Now()
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin restore: %w", err)
+	}
+	defer tx.Rollback(context.WithoutCancel(ctx))
+
+	if len(archive.Tables) > 0 {
+		idents := make([]string, len(archive.Tables))
+		for i, table := range archive.Tables {
+			idents[i] = table.ident()
+		}
+		if _, err := tx.Exec(ctx, "TRUNCATE "+strings.Join(idents, ", ")); err != nil {
+			return fmt.Errorf("truncate tables: %w", err)
+		}
+	}
+
+	// tar entries follow manifest order, which is the restore order
+	file, err := os.Open(backupFile)
+	if err != nil {
+		return fmt.Errorf("backup file %q not found: %w", backupFile, err)
+	}
+	defer file.Close()
+	tr := tar.NewReader(file)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("%w: read %s: %v", ErrInvalidArtifact, backupFile, err)
+		}
+		i := slices.IndexFunc(archive.Tables, func(t nativeTable) bool { return t.File == header.Name })
+		if i < 0 {
+			continue
+		}
+		table := archive.Tables[i]
+		sql := fmt.Sprintf("COPY %s (%s) FROM STDIN", table.ident(), table.columnList())
+		if _, err := tx.Conn().PgConn().CopyFrom(ctx, tr, sql); err != nil {
+			return fmt.Errorf("restore %s: %w", table.ident(), err)
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit restore: %w", err)
+	}
+	p.Logger.Info("restore completed",
+		"database", p.Database,
+		"engine", EnginePostgres,
+		"source", backupFile,
+		"tables", len(archive.Tables),
+		"duration", time.Since(start).String(),
+	)
+	return nil
+}
+
+// validateNative checks that a native COPY archive has a manifest and one
+// entry per listed table. It returns the tables with their row counts.
+func validateNative(path string) ([]string, error) {
+	archive, err := readNativeManifest(path)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("backup file %q not found: %w", path, err)
+	}
+	defer file.Close()
+	present := make(map[string]bool)
+	tr := tar.NewReader(file)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: read %s: %v", ErrInvalidArtifact, path, err)
+		}
+		present[header.Name] = true
+	}
+
+	entries := make([]string, 0, len(archive.Tables))
+	for _, table := range archive.Tables {
+		if !present[table.File] {
+			return nil, fmt.Errorf("%w: %s has no data for %s.%s", ErrInvalidArtifact, path, table.Schema, table.Name)
+		}
+		entries = append(entries, fmt.Sprintf("%s.%s (%d rows)", table.Schema, table.Name, table.Rows))
+	}
+	return entries, nil
+}
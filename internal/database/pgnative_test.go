@@ -0,0 +1,32 @@
+package database
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestRestoreOrder(t *testing.T) {
+	tables := []nativeTable{
+		{Schema: "public", Name: "line_items"},
+		{Schema: "public", Name: "orders"},
+		{Schema: "public", Name: "users"},
+		{Schema: "public", Name: "a"},
+		{Schema: "public", Name: "b"},
+	}
+	deps := map[string][]string{
+		"public.line_items": {"public.orders"},
+		"public.orders":     {"public.users", "other.missing"},
+		// a cycle keeps the original order
+		"public.a": {"public.b"},
+		"public.b": {"public.a"},
+	}
+
+	var got []string
+	for _, table := range restoreOrder(tables, deps) {
+		got = append(got, table.Name)
+	}
+	want := []string{"users", "orders", "line_items", "a", "b"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("restoreOrder = %v, want %v", got, want)
+	}
+}
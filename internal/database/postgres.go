@@ -2,11 +2,13 @@ package database
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/kebairia/backup/internal/config"
@@ -25,12 +27,42 @@ type Postgres struct {
 	Database     string
 	Host         string
 	Port         string
-	Method       string // e.g. "custom", "plain", "directory"
+	Method       string // pg_dump format ("custom", "plain", "directory", ...) or "native"
 	OutputDir    string
+	WorkDir      string // dumps are written here first, see WorkPath
 	TimeStampFmt string
+	NameTemplate string // artifact name, see config.ArtifactName
+	Instance     string // instance name, see config.ArtifactDir
+	DirTemplate  string // artifact directory, see config.ArtifactDir
 	Timeout      time.Duration
-	Compress     bool
+	Compression  string // compression mode, see config.Compression
+	Jobs         int    // parallel pg_dump/pg_restore workers (directory format)
+	Labels       map[string]string
 	Logger       logger.Logger
+	// AllowOverwrite lets restores replace a target that holds data
+	AllowOverwrite bool
+	// Schemas, Tables and Content select what pg_dump dumps (see
+	// objectArgs).
+	Schemas config.Filter
+	Tables  config.Filter
+	Content string
+	// Flavor and Extensions adapt dumps and restores to TimescaleDB or
+	// Citus (see pgflavor.go).
+	Flavor     string
+	Extensions []string
+
+	// ctx cancels running client commands (see WithPostgresContext)
+	ctx context.Context
+	// Vault lease of the dynamic credentials (see LeaseHolder)
+	credentialLease
+	// stderr of client commands (see StderrSetter)
+	stderrSink
+	// runs client commands (see WithPostgresExecutor)
+	toolExec
+
+	// Restore target overrides (see Retarget)
+	RestoreHost     string
+	RestoreDatabase string
 }
 
 // NewPostgres returns a Postgres configured from cfg plus any overrides.
@@ -45,13 +77,18 @@ func NewPostgres(cfg config.Config, opts ...PostgresOption) (*Postgres, error) {
 		Port:         cfg.Postgres.EngineDefaults.Port,
 		Method:       cfg.Postgres.EngineDefaults.Method,
 		OutputDir:    cfg.Backup.Directory,
+		WorkDir:      cfg.Backup.WorkDir,
 		TimeStampFmt: cfg.Backup.TimestampFmt,
+		NameTemplate: cfg.Backup.NameTemplate,
+		DirTemplate:  cfg.Backup.DirTemplate,
 		Timeout:      cfg.Backup.Timeout,
+		Jobs:         cfg.Postgres.EngineDefaults.Jobs,
 		Logger:       log,
 	}
 	for _, opt := range opts {
 		opt(p)
 	}
+	p.Logger = logger.FromContext(p.ctx, p.Logger)
 	return p, nil
 }
 
@@ -94,7 +131,8 @@ func WithPostgresDatabase(db string) PostgresOption {
 	}
 }
 
-// WithPostgresMethod overrides output format (custom/plain/directory).
+// WithPostgresMethod overrides output format (custom/plain/directory), or
+// selects the native COPY method.
 func WithPostgresMethod(method string) PostgresOption {
 	return func(p *Postgres) {
 		if method != "" {
@@ -112,6 +150,75 @@ func WithPostgresOutputDir(dir string) PostgresOption {
 	}
 }
 
+// WithPostgresInstance sets the instance name, available to backup.dir_template.
+func WithPostgresInstance(name string) PostgresOption {
+	return func(p *Postgres) {
+		p.Instance = name
+	}
+}
+
+// WithPostgresJobs overrides the number of parallel dump/restore workers.
+func WithPostgresJobs(jobs int) PostgresOption {
+	return func(p *Postgres) {
+		if jobs > 0 {
+			p.Jobs = jobs
+		}
+	}
+}
+
+// isDirectoryFormat reports whether pg_dump writes a directory of per-table
+// files, the only format pg_dump can produce in parallel.
+func (p *Postgres) isDirectoryFormat() bool {
+	return p.Method == "directory" || p.Method == "d"
+}
+
+// WithPostgresExecutor runs pg_dump, pg_restore and psql through e, e.g.
+// inside the database's pod.
+func WithPostgresExecutor(e CommandRunner) PostgresOption {
+	return func(p *Postgres) {
+		p.executor = e
+	}
+}
+
+// WithPostgresObjects selects the schemas and tables dumped, with pg_dump
+// patterns.
+func WithPostgresObjects(schemas, tables config.Filter) PostgresOption {
+	return func(p *Postgres) {
+		p.Schemas = schemas
+		p.Tables = tables
+	}
+}
+
+// WithPostgresContent limits dumps to definitions or data
+// (config.ContentSchemaOnly, config.ContentDataOnly).
+func WithPostgresContent(content string) PostgresOption {
+	return func(p *Postgres) {
+		p.Content = content
+	}
+}
+
+// WithPostgresContext makes ctx cancel streamed dumps and restores,
+// artifact validation and psql queries, e.g. when the run is interrupted.
+func WithPostgresContext(ctx context.Context) PostgresOption {
+	return func(p *Postgres) {
+		p.ctx = ctx
+	}
+}
+
+// WithPostgresLabels sets the labels recorded with every backup.
+func WithPostgresLabels(labels map[string]string) PostgresOption {
+	return func(p *Postgres) {
+		p.Labels = labels
+	}
+}
+
+// WithPostgresAllowOverwrite lets restores replace a target holding data.
+func WithPostgresAllowOverwrite(allow bool) PostgresOption {
+	return func(p *Postgres) {
+		p.AllowOverwrite = allow
+	}
+}
+
 // WithTimestampFormat overrides timestamp format
 func WithPostgresTimestampFormat(timeStampFmt string) PostgresOption {
 	return func(p *Postgres) {
@@ -121,54 +228,81 @@ func WithPostgresTimestampFormat(timeStampFmt string) PostgresOption {
 	}
 }
 
-// WithPostgresCompress overrides the compress choice.
-func WithPostgresCompress(compress bool) PostgresOption {
+// WithPostgresCompression sets the compression mode (see
+// config.Compression): anything but native turns off the compression of
+// pg_dump's custom and directory formats.
+func WithPostgresCompression(mode string) PostgresOption {
 	return func(p *Postgres) {
-		if compress {
-			p.Compress = true
-		}
+		p.Compression = mode
 	}
 }
 
-// Backup runs `pg_dump` to back up the database into a timestamped .dump file.
-func (p *Postgres) Backup() (backupPath string, err error) {
+// Backup runs `pg_dump` to back up the database into a timestamped .dump file,
+// or a timestamped directory for the directory format (dumped with Jobs
+// parallel workers). The native method streams table data with COPY
+// instead (see PostgresMethodNative).
+func (p *Postgres) Backup(ctx context.Context) (backupPath string, err error) {
 	log := p.Logger
-	ctx, cancel := context.WithTimeoutCause(context.Background(), p.Timeout, ErrTimeout)
+	ctx, cancel := context.WithTimeoutCause(ctx, p.Timeout, ErrTimeout)
 
 	defer cancel()
+	// the native method connects from the backup host and the directory
+	// format cannot be streamed
+	if p.remote() && (p.Method == PostgresMethodNative || p.isDirectoryFormat()) {
+		return "", fmt.Errorf("%s method: %w", p.Method, ErrExecUnsupported)
+	}
+	if p.Method == PostgresMethodNative {
+		return p.nativeBackup(ctx)
+	}
+	if err := p.beforeBackup(); err != nil {
+		return "", err
+	}
 	// e.g. "./backups/postgres/2025-04-24_21-00-00-mydb.dump"
-	timestamp := time.Now().Format(p.TimeStampFmt)
-	backupPath = filepath.Join(
-		p.OutputDir,
-		EnginePostgres,
-		p.Database,
-		fmt.Sprintf("%s-%s.dump", timestamp, p.Database),
-	)
+	name, err := p.artifactName()
+	if err != nil {
+		return "", err
+	}
+	if !p.isDirectoryFormat() {
+		name += ".dump"
+	}
+	backupPath = filepath.Join(WorkPath(p.OutputDir, p.WorkDir, p.GetPath()), name)
 
 	// Ensure the parent directory exists
 	if err := os.MkdirAll(filepath.Dir(backupPath), 0o755); err != nil {
 		return "", fmt.Errorf("mkdir %q: %w", filepath.Dir(backupPath), err)
 	}
 
-	// Build pg_dump args
-	args := []string{
-		"-h", p.Host,
-		"-p", p.Port,
-		"-U", p.Username,
-		"-d", p.Database,
-		"-F", p.Method,
-		"-f", backupPath,
+	// a remote pg_dump cannot write the backup host's files: it writes
+	// to stdout instead
+	target := backupPath
+	if p.remote() {
+		target = ""
 	}
 
-	cmd := exec.CommandContext(ctx, "pg_dump", args...)
-	// Pass PGPASSWORD for non-interactive auth
-	cmd.Env = append(os.Environ(), "PGPASSWORD="+p.Password)
-	cmd.Stderr = os.Stderr
+	env, cleanup, err := p.passwordEnv()
+	if err != nil {
+		return "", err
+	}
+	defer cleanup()
+	cmd, err := p.command(ctx, env, "pg_dump", p.dumpArgs(target)...)
+	if err != nil {
+		return "", err
+	}
+	cmd.Stderr = p.stderr()
+	if p.remote() {
+		out, err := os.Create(backupPath)
+		if err != nil {
+			return "", fmt.Errorf("create %q: %w", backupPath, err)
+		}
+		defer out.Close()
+		cmd.Stdout = out
+	}
 
 	p.Logger.Info("backup started",
 		"database", p.Database,
 		"engine", EnginePostgres,
 		"method", p.Method,
+		"jobs", p.Jobs,
 		"path", backupPath,
 	)
 
@@ -180,7 +314,7 @@ func (p *Postgres) Backup() (backupPath string, err error) {
 			"path", backupPath,
 			"error", err.Error(),
 		)
-		return "", fmt.Errorf("pg_dump failed: %w", err)
+		return backupPath, fmt.Errorf("pg_dump failed: %w", err)
 	}
 	executionDuration := time.Since(startTime)
 
@@ -193,10 +327,109 @@ func (p *Postgres) Backup() (backupPath string, err error) {
 	return backupPath, nil
 }
 
-// Restore runs `pg_restore` to restore from a .dump file.
-func (p *Postgres) Restore(backupFile string) error {
+// dumpArgs returns the pg_dump arguments writing the dump to path, or to
+// stdout when path is empty.
+func (p *Postgres) dumpArgs(path string) []string {
+	args := []string{
+		"-h", p.Host,
+		"-p", p.Port,
+		"-U", p.Username,
+		"-d", p.Database,
+		"-F", p.Method,
+	}
+	if path != "" {
+		args = append(args, "-f", path)
+	}
+	if p.isDirectoryFormat() && p.Jobs > 1 {
+		args = append(args, "--jobs", strconv.Itoa(p.Jobs))
+	}
+	if p.Compression == config.CompressionZstd || p.Compression == config.CompressionNone {
+		// bacli compresses the artifact, or nothing should
+		args = append(args, "--compress=0")
+	}
+	args = append(args, p.objectArgs()...)
+	return append(args, verboseArgs()...)
+}
+
+// objectArgs returns the pg_dump flags selecting schemas, tables and
+// content.
+func (p *Postgres) objectArgs() []string {
+	var args []string
+	for _, f := range []struct {
+		filter           config.Filter
+		include, exclude string
+	}{
+		{p.Schemas, "--schema=", "--exclude-schema="},
+		{p.Tables, "--table=", "--exclude-table="},
+	} {
+		for _, pattern := range f.filter.Include {
+			args = append(args, f.include+pattern)
+		}
+		for _, pattern := range f.filter.Exclude {
+			args = append(args, f.exclude+pattern)
+		}
+	}
+	if p.Content != "" {
+		args = append(args, "--"+p.Content)
+	}
+	return args
+}
+
+// passwordEnv returns the environment authenticating the client tools
+// without a prompt: PGPASSFILE naming a temporary password file only the
+// current user can read, or PGPASSWORD for remote tools, which cannot read
+// the backup host's files. Call the returned func once the command ran.
+func (p *Postgres) passwordEnv() ([]string, func(), error) {
+	if p.remote() {
+		return []string{"PGPASSWORD=" + p.Password}, func() {}, nil
+	}
+	// hostname:port:database:username:password, with : and \ escaped
+	escaped := strings.NewReplacer(`\`, `\\`, ":", `\:`).Replace(p.Password)
+	path, cleanup, err := secretFile("bacli-pgpass-*", "*:*:*:*:"+escaped+"\n")
+	if err != nil {
+		return nil, nil, err
+	}
+	return []string{"PGPASSFILE=" + path}, cleanup, nil
+}
+
+// DumpTool returns pg_dump, or "" for the native method.
+func (p *Postgres) DumpTool() string {
+	if p.Method == PostgresMethodNative {
+		return ""
+	}
+	return "pg_dump"
+}
+
+// RestoreTool returns psql for plain SQL dumps, pg_restore for archives,
+// or "" for the native method.
+func (p *Postgres) RestoreTool() string {
+	switch p.Method {
+	case PostgresMethodNative:
+		return ""
+	case "plain":
+		return "psql"
+	}
+	return "pg_restore"
+}
+
+// artifactName renders the configured name template for a backup taken now.
+func (p *Postgres) artifactName() (string, error) {
+	now := time.Now()
+	return config.ArtifactName{
+		Engine:    EnginePostgres,
+		Database:  p.Database,
+		Host:      p.Host,
+		Method:    p.Method,
+		Timestamp: now.Format(p.TimeStampFmt),
+		Time:      now,
+	}.Render(p.NameTemplate)
+}
+
+// Restore runs `pg_restore` to restore from a .dump file, or loads a native
+// COPY archive.
+func (p *Postgres) Restore(ctx context.Context, backupFile string) (err error) {
 	log := p.Logger
-	ctx, cancel := context.WithTimeoutCause(context.Background(), p.Timeout, ErrTimeout)
+	ctx, cancel := context.WithTimeoutCause(ctx, p.Timeout, ErrTimeout)
 	defer cancel()
 
 	// Ensure the file exists
@@ -205,35 +438,45 @@ func (p *Postgres) Restore(backupFile string) error {
 		return fmt.Errorf("backup file %q not found: %w", backupFile, err)
 	}
 
-	// Build the right command based on p.Method
-	var cmd *exec.Cmd
-	switch p.Method {
-	case "plain":
-		// Plain SQL → use psql -f
-		cmd = exec.CommandContext(ctx, "psql",
-			"-h", p.Host,
-			"-p", p.Port,
-			"-U", p.Username,
-			"-d", p.Database,
-			"-f", backupFile,
-		)
-		// "custom", "directory", "tar":
-	default:
-		cmd = exec.CommandContext(ctx, "pg_restore",
-			"-h", p.Host,
-			"-p", p.Port,
-			"-U", p.Username,
-			"-d", p.Database,
-			"-c", // Clean existing objects
-			"-F", p.Method,
-			backupFile,
-		)
+	if p.remote() && (strings.HasSuffix(backupFile, nativeExt) || p.isDirectoryFormat()) {
+		return fmt.Errorf("restore %q: %w", backupFile, ErrExecUnsupported)
+	}
+	if strings.HasSuffix(backupFile, nativeExt) {
+		return p.nativeRestore(ctx, backupFile)
+	}
+	host, database := p.restoreTarget()
+	if err := p.beforeRestore(); err != nil {
+		return err
+	}
+	defer func() { err = errors.Join(err, p.afterRestore()) }()
+
+	// Remote tools read the artifact from stdin, as they cannot see
+	// backupFile.
+	source := backupFile
+	if p.remote() {
+		source = ""
 	}
+	name, args := p.restoreArgs(host, database, source)
 
-	// Handle non interactive authorization
-	cmd.Env = append(os.Environ(), "PGPASSWORD="+p.Password)
+	env, cleanup, err := p.passwordEnv()
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	cmd, err := p.command(ctx, env, name, args...)
+	if err != nil {
+		return err
+	}
+	if p.remote() {
+		in, err := os.Open(backupFile)
+		if err != nil {
+			return fmt.Errorf("open %q: %w", backupFile, err)
+		}
+		defer in.Close()
+		cmd.Stdin = in
+	}
 	cmd.Stdout = io.Discard // I don't want to see the restoring output of postgres
-	cmd.Stderr = os.Stderr
+	cmd.Stderr = p.stderr()
 
 	// Logging
 
@@ -242,6 +485,8 @@ func (p *Postgres) Restore(backupFile string) error {
 		"engine", EnginePostgres,
 		"method", p.Method,
 		"source", backupFile,
+		"target_host", host,
+		"target_database", database,
 	)
 
 	// Run and check for errors
@@ -260,11 +505,385 @@ func (p *Postgres) Restore(backupFile string) error {
 	return nil
 }
 
+// DumpTo runs pg_dump and writes the dump to w. Only the single-file
+// pg_dump formats can be piped.
+func (p *Postgres) DumpTo(w io.Writer) error {
+	if err := p.checkPipe(); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeoutCause(orBackground(p.ctx), p.Timeout, ErrTimeout)
+	defer cancel()
+	if err := p.beforeBackup(); err != nil {
+		return err
+	}
+	env, cleanup, err := p.passwordEnv()
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	cmd, err := p.command(ctx, env, "pg_dump", p.dumpArgs("")...)
+	if err != nil {
+		return err
+	}
+	cmd.Stdout = w
+	cmd.Stderr = p.stderr()
+	p.Logger.Info("dump started",
+		"database", p.Database,
+		"engine", EnginePostgres,
+		"method", p.Method,
+	)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("pg_dump failed: %w", err)
+	}
+	return nil
+}
+
+// RestoreFrom restores the dump read from r, in the configured format, into
+// the restore target.
+func (p *Postgres) RestoreFrom(r io.Reader) (err error) {
+	if err := p.checkPipe(); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeoutCause(orBackground(p.ctx), p.Timeout, ErrTimeout)
+	defer cancel()
+	host, database := p.restoreTarget()
+	if err := p.beforeRestore(); err != nil {
+		return err
+	}
+	defer func() { err = errors.Join(err, p.afterRestore()) }()
+
+	name, args := p.restoreArgs(host, database, "")
+	env, cleanup, err := p.passwordEnv()
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	cmd, err := p.command(ctx, env, name, args...)
+	if err != nil {
+		return err
+	}
+	cmd.Stdin = r
+	cmd.Stdout = io.Discard
+	cmd.Stderr = p.stderr()
+	p.Logger.Info("restore started",
+		"database", p.Database,
+		"engine", EnginePostgres,
+		"method", p.Method,
+		"source", "pipe",
+		"target_host", host,
+		"target_database", database,
+	)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("restore failed (%s): %w", p.Method, err)
+	}
+	return nil
+}
+
+// PlainSQL reports whether the plain format writes SQL scripts.
+func (p *Postgres) PlainSQL() bool { return p.Method == "plain" }
+
+// checkPipe rejects the methods DumpTo and RestoreFrom cannot pipe.
+func (p *Postgres) checkPipe() error {
+	if p.Method == PostgresMethodNative || p.isDirectoryFormat() {
+		return fmt.Errorf("%s method: %w", p.Method, ErrNotPipeable)
+	}
+	return nil
+}
+
+// restoreArgs returns the tool and arguments restoring the dump at path, or
+// read from stdin when path is empty, into database on host: psql -f for
+// plain SQL dumps, pg_restore for the archive formats.
+func (p *Postgres) restoreArgs(host, database, path string) (name string, args []string) {
+	args = []string{
+		"-h", host,
+		"-p", p.Port,
+		"-U", p.Username,
+		"-d", database,
+	}
+	if p.Method == "plain" {
+		if path != "" {
+			args = append(args, "-f", path)
+		}
+		return "psql", args
+	}
+	args = append(args,
+		"-c", // Clean existing objects
+		"-F", p.Method,
+	)
+	// tar archives cannot be restored in parallel, nor can stdin
+	if p.Jobs > 1 && p.Method != "tar" && p.Method != "t" && path != "" {
+		args = append(args, "--jobs", strconv.Itoa(p.Jobs))
+	}
+	args = append(args, verboseArgs()...)
+	if path != "" {
+		args = append(args, path)
+	}
+	return "pg_restore", args
+}
+
+// Retarget redirects subsequent restores to another host and/or database.
+// The target database must already exist.
+func (p *Postgres) Retarget(host, database string) {
+	p.RestoreHost = host
+	p.RestoreDatabase = database
+}
+
+// restoreTarget returns the host and database a restore should write to.
+func (p *Postgres) restoreTarget() (host, database string) {
+	host, database = p.Host, p.Database
+	if p.RestoreHost != "" {
+		host = p.RestoreHost
+	}
+	if p.RestoreDatabase != "" {
+		database = p.RestoreDatabase
+	}
+	return host, database
+}
+
+// ValidateArtifact lists the contents of a dump without connecting to a server.
+// Archive formats are listed with pg_restore --list; plain SQL dumps are
+// scanned for their header and CREATE TABLE statements, and native COPY
+// archives are checked against their manifest.
+func (p *Postgres) ValidateArtifact(path string) ([]string, error) {
+	if strings.HasSuffix(path, nativeExt) {
+		return validateNative(path)
+	}
+	if p.Method == "plain" {
+		return scanSQLDump(path, "PostgreSQL database dump", "PostgreSQL database dump complete")
+	}
+
+	ctx, cancel := context.WithTimeoutCause(orBackground(p.ctx), p.Timeout, ErrTimeout)
+	defer cancel()
+	args := []string{"--list", "-F", p.Method}
+	if !p.remote() {
+		args = append(args, path)
+	}
+	cmd, err := p.command(ctx, nil, "pg_restore", args...)
+	if err != nil {
+		return nil, err
+	}
+	cmd.Stderr = p.stderr()
+	if p.remote() {
+		in, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("open %q: %w", path, err)
+		}
+		defer in.Close()
+		cmd.Stdin = in
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%w: pg_restore --list: %v", ErrInvalidArtifact, err)
+	}
+	var entries []string
+	for _, line := range strings.Split(string(out), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, ";") {
+			continue
+		}
+		entries = append(entries, line)
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("%w: empty table of contents", ErrInvalidArtifact)
+	}
+	return entries, nil
+}
+
+// countRowsQuery returns an exact row count for every user table.
+const countRowsQuery = `SELECT table_schema || '.' || table_name,
+  (xpath('/row/c/text()', query_to_xml(
+    format('SELECT count(*) AS c FROM %I.%I', table_schema, table_name),
+    false, true, '')))[1]::text::bigint
+FROM information_schema.tables
+WHERE table_type = 'BASE TABLE'
+  AND table_schema NOT IN ('pg_catalog', 'information_schema')`
+
+// PrepareTarget (re)creates the restore target database.
+func (p *Postgres) PrepareTarget() error {
+	_, database := p.restoreTarget()
+	if database == p.Database {
+		return fmt.Errorf("%w: refusing to recreate source database %q", ErrVerifyFailed, database)
+	}
+	for _, stmt := range []string{
+		"DROP DATABASE IF EXISTS " + pgIdent(database),
+		"CREATE DATABASE " + pgIdent(database),
+	} {
+		if _, err := p.query("postgres", stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CountObjects returns the row count of every table in the restore target.
+func (p *Postgres) CountObjects() (map[string]int64, error) {
+	_, database := p.restoreTarget()
+	out, err := p.query(database, countRowsQuery)
+	if err != nil {
+		return nil, err
+	}
+	return parseCounts(out)
+}
+
+// listTablesQuery lists the user tables of a database.
+const listTablesQuery = `SELECT table_schema || '.' || table_name
+FROM information_schema.tables
+WHERE table_type = 'BASE TABLE'
+  AND table_schema NOT IN ('pg_catalog', 'information_schema')
+ORDER BY 1`
+
+// TargetObjects returns the user tables of the restore target database.
+func (p *Postgres) TargetObjects() ([]string, error) {
+	_, database := p.restoreTarget()
+	out, err := p.query("postgres", fmt.Sprintf("SELECT 1 FROM pg_database WHERE datname = '%s'",
+		strings.ReplaceAll(database, "'", "''")))
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(out) == "" {
+		return nil, nil
+	}
+	out, err = p.query(database, listTablesQuery)
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(out), nil
+}
+
+// OverwriteAllowed reports whether the instance sets allow_overwrite.
+func (p *Postgres) OverwriteAllowed() bool { return p.AllowOverwrite }
+
+// DropTarget drops the restore target database.
+func (p *Postgres) DropTarget() error {
+	_, database := p.restoreTarget()
+	if database == p.Database {
+		return fmt.Errorf("%w: refusing to drop source database %q", ErrVerifyFailed, database)
+	}
+	_, err := p.query("postgres", "DROP DATABASE IF EXISTS "+pgIdent(database))
+	return err
+}
+
+// pgIdent quotes an SQL identifier, as quote_ident does.
+func pgIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// query runs sql with psql against database on the restore host and returns
+// unaligned, tab-separated output.
+func (p *Postgres) query(database, sql string) (string, error) {
+	host, _ := p.restoreTarget()
+	return p.psql(host, database, sql)
+}
+
+// psql runs sql against database on host and returns the unaligned output.
+func (p *Postgres) psql(host, database, sql string) (string, error) {
+	ctx, cancel := context.WithTimeoutCause(orBackground(p.ctx), p.Timeout, ErrTimeout)
+	defer cancel()
+
+	env, cleanup, err := p.passwordEnv()
+	if err != nil {
+		return "", err
+	}
+	defer cleanup()
+	cmd, err := p.command(ctx, env, "psql",
+		"-h", host,
+		"-p", p.Port,
+		"-U", p.Username,
+		"-d", database,
+		"-X", "-A", "-t", "-F", "\t",
+		"-v", "ON_ERROR_STOP=1",
+		"-c", sql,
+	)
+	if err != nil {
+		return "", err
+	}
+	cmd.Stderr = p.stderr()
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("psql query failed: %w", err)
+	}
+	return string(out), nil
+}
+
+// EstimateSize returns the on-disk size of the source database as reported
+// by pg_database_size.
+func (p *Postgres) EstimateSize() (int64, error) {
+	out, err := p.psql(p.Host, p.Database, "SELECT pg_database_size(current_database())")
+	if err != nil {
+		return 0, err
+	}
+	return parseSize(out)
+}
+
+// ListDatabases returns the databases on the server that accept connections,
+// templates excluded.
+func (p *Postgres) ListDatabases() ([]string, error) {
+	out, err := p.psql(p.Host, "postgres",
+		"SELECT datname FROM pg_database WHERE datallowconn AND NOT datistemplate ORDER BY datname")
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(out), nil
+}
+
+// ServerVersion connects with the configured credentials and returns the
+// server version. The native method connects without psql.
+func (p *Postgres) ServerVersion() (string, error) {
+	if p.Method == PostgresMethodNative {
+		ctx, cancel := context.WithTimeoutCause(orBackground(p.ctx), p.Timeout, ErrTimeout)
+		defer cancel()
+		conn, err := p.connect(ctx, p.Host, p.Database)
+		if err != nil {
+			return "", err
+		}
+		defer conn.Close(ctx)
+		var version string
+		if err := conn.QueryRow(ctx, "SHOW server_version").Scan(&version); err != nil {
+			return "", fmt.Errorf("server version: %w", err)
+		}
+		return version, nil
+	}
+	out, err := p.psql(p.Host, p.Database, "SHOW server_version")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out), nil
+}
+
 // Getters
 func (p *Postgres) GetName() string { return p.Database }
 
 // Engine returns the engine name.
 func (p *Postgres) GetEngine() string { return EnginePostgres }
 
-// Path returns the base backup path.
-func (p *Postgres) GetPath() string { return filepath.Join(p.OutputDir, EnginePostgres) }
+// GetPath returns the directory holding the artifacts and catalog of the
+// database, <output dir>/<engine>/<backup.dir_template>.
+func (p *Postgres) GetPath() string {
+	return artifactDir(p.OutputDir, p.DirTemplate, config.ArtifactDir{
+		Engine:   EnginePostgres,
+		Instance: p.Instance,
+		Host:     p.Host,
+		Database: p.Database,
+	})
+}
+
+// GetInstance returns the name of the configured instance.
+func (p *Postgres) GetInstance() string { return p.Instance }
+
+// ArtifactExt returns the extension of the single-file artifacts of the
+// configured method.
+func (p *Postgres) ArtifactExt() (string, bool) {
+	switch {
+	case p.Method == PostgresMethodNative:
+		return nativeExt, true
+	case p.isDirectoryFormat():
+		return "", false
+	}
+	return ".dump", true
+}
+
+// GetHost returns the database host.
+func (p *Postgres) GetHost() string { return p.Host }
+
+// GetLabels returns the labels recorded with every backup.
+func (p *Postgres) GetLabels() map[string]string { return p.Labels }
@@ -0,0 +1,23 @@
+package database
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/kebairia/backup/internal/config"
+)
+
+func TestPostgresObjectArgs(t *testing.T) {
+	p := &Postgres{
+		Schemas: config.Filter{Include: []string{"public", "sales"}},
+		Tables:  config.Filter{Exclude: []string{"public.audit_*"}},
+		Content: config.ContentDataOnly,
+	}
+	want := []string{"--schema=public", "--schema=sales", "--exclude-table=public.audit_*", "--data-only"}
+	if got := p.objectArgs(); !slices.Equal(got, want) {
+		t.Errorf("objectArgs = %q, want %q", got, want)
+	}
+	if got := (&Postgres{}).objectArgs(); len(got) != 0 {
+		t.Errorf("objectArgs without selection = %q", got)
+	}
+}
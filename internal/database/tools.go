@@ -0,0 +1,119 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrIncompatible indicates that a client tool is too old for the server.
+var ErrIncompatible = errors.New("incompatible client version")
+
+// engineTools lists the client binaries each engine shells out to.
+var engineTools = map[string][]string{
+	EnginePostgres:   {"pg_dump", "pg_restore", "psql"},
+	EngineMongoDB:    {"mongodump", "mongorestore", "mongosh"},
+	mysqlEngine:      {"mysqldump", "mysql"},
+	"redis":          {"redis-cli"},
+	EngineClickHouse: {"clickhouse-client"},
+	EngineCassandra:  {"nodetool", "cqlsh", "sstableloader"},
+	EngineInfluxDB:   {"influx", "influxd"},
+}
+
+// toolVersionTimeout bounds `<tool> --version`.
+const toolVersionTimeout = 10 * time.Second
+
+var versionPattern = regexp.MustCompile(`\d+(\.\d+)+`)
+
+// Tool describes a client binary found (or not) on PATH.
+type Tool struct {
+	Name    string `json:"name"`
+	Path    string `json:"path,omitempty"`
+	Version string `json:"version,omitempty"`
+}
+
+// EngineTools returns the client binaries required by engine.
+func EngineTools(engine string) []string {
+	return engineTools[engine]
+}
+
+// LookupTool finds name on PATH and reports its version.
+func LookupTool(name string) (Tool, error) {
+	tool := Tool{Name: name}
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return tool, err
+	}
+	tool.Path = path
+
+	ctx, cancel := context.WithTimeoutCause(context.Background(), toolVersionTimeout, ErrTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, path, "--version").CombinedOutput()
+	if err != nil {
+		return tool, fmt.Errorf("%s --version: %w", name, err)
+	}
+	tool.Version = versionPattern.FindString(string(out))
+	return tool, nil
+}
+
+// FindTool reports the client tool name of db where db runs it: on the
+// backup host, or through its exec driver (see ToolLocator).
+func FindTool(db Database, name string) (Tool, error) {
+	if locator, ok := db.(ToolLocator); ok {
+		return locator.LookupTool(name)
+	}
+	return LookupTool(name)
+}
+
+// CheckRestoreCompatibility reports whether restoreTool can load an artifact
+// written by dumpTool. pg_restore rejects archives of a newer pg_dump, whose
+// format it does not know; the other engines' dumps are not checked.
+func CheckRestoreCompatibility(engine string, dumpTool, restoreTool Tool) error {
+	if engine != EnginePostgres || restoreTool.Name != "pg_restore" {
+		return nil
+	}
+	dump, derr := majorVersion(dumpTool.Version)
+	restore, rerr := majorVersion(restoreTool.Version)
+	if derr != nil || rerr != nil {
+		return nil // unknown versions are reported, not judged
+	}
+	if restore < dump {
+		return fmt.Errorf("%w: %s %s cannot read archives of %s %s",
+			ErrIncompatible, restoreTool.Name, restoreTool.Version, dumpTool.Name, dumpTool.Version)
+	}
+	return nil
+}
+
+// CheckCompatibility reports whether a client tool of clientVersion can work
+// against a server of serverVersion. pg_dump refuses servers with a newer
+// major version, and mysqldump is only supported against servers up to its
+// own major version. MongoDB database tools are versioned independently of
+// the server and are not checked.
+func CheckCompatibility(engine, tool, clientVersion, serverVersion string) error {
+	switch engine {
+	case EnginePostgres, mysqlEngine:
+	default:
+		return nil
+	}
+	client, cerr := majorVersion(clientVersion)
+	server, serr := majorVersion(serverVersion)
+	if cerr != nil || serr != nil {
+		return nil // unknown versions are reported, not judged
+	}
+	if client < server {
+		return fmt.Errorf("%w: %s %s is older than server %s",
+			ErrIncompatible, tool, clientVersion, serverVersion)
+	}
+	return nil
+}
+
+// majorVersion returns the leading number of a dotted version.
+func majorVersion(version string) (int, error) {
+	major, _, _ := strings.Cut(versionPattern.FindString(version), ".")
+	return strconv.Atoi(major)
+}
@@ -0,0 +1,46 @@
+package database
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCheckCompatibility(t *testing.T) {
+	tests := []struct {
+		engine, client, server string
+		wantErr                bool
+	}{
+		{EnginePostgres, "16.2", "15.6 (Debian 15.6-1.pgdg120+2)", false},
+		{EnginePostgres, "14.11", "16.2", true},
+		{mysqlEngine, "8.0.36", "8.0.36-0ubuntu0.22.04.1", false},
+		{EngineMongoDB, "100.9.4", "7.0.5", false},
+		{EnginePostgres, "", "16.2", false}, // unknown client version
+	}
+	for _, tt := range tests {
+		err := CheckCompatibility(tt.engine, "tool", tt.client, tt.server)
+		if got := errors.Is(err, ErrIncompatible); got != tt.wantErr {
+			t.Errorf("%s %s vs %s: got %v, want incompatible=%v", tt.engine, tt.client, tt.server, err, tt.wantErr)
+		}
+	}
+}
+
+func TestCheckRestoreCompatibility(t *testing.T) {
+	tests := []struct {
+		engine, dump, restore, version string
+		wantErr                        bool
+	}{
+		{EnginePostgres, "16.2", "pg_restore", "16.4", false},
+		{EnginePostgres, "17.0", "pg_restore", "16.4", true},
+		{EnginePostgres, "17.0", "psql", "16.4", false}, // plain SQL dumps
+		{EngineMongoDB, "100.9.4", "mongorestore", "100.7.0", false},
+		{EnginePostgres, "", "pg_restore", "16.4", false}, // unknown dump version
+	}
+	for _, tt := range tests {
+		dump := Tool{Name: "pg_dump", Version: tt.dump}
+		restore := Tool{Name: tt.restore, Version: tt.version}
+		err := CheckRestoreCompatibility(tt.engine, dump, restore)
+		if got := errors.Is(err, ErrIncompatible); got != tt.wantErr {
+			t.Errorf("%s %s vs %s %s: got %v, want incompatible=%v", tt.engine, tt.dump, tt.restore, tt.version, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package database
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/kebairia/backup/internal/config"
+)
+
+// systemDatabases are skipped when an instance covers config.AllDatabases.
+var systemDatabases = map[string][]string{
+	EnginePostgres:   {"template0", "template1"},
+	EngineMongoDB:    {"admin", "config", "local"},
+	mysqlEngine:      {"information_schema", "mysql", "performance_schema", "sys"},
+	EngineClickHouse: {"INFORMATION_SCHEMA", "information_schema", "system"},
+	EngineCassandra: {
+		"system", "system_auth", "system_distributed", "system_schema",
+		"system_traces", "system_views", "system_virtual_schema",
+	},
+	EngineInfluxDB: {"_internal", "_monitoring", "_tasks"},
+	EngineCouchDB:  {"_users", "_replicator", "_global_changes"},
+}
+
+// claimedDatabases returns the databases named explicitly by the instances of
+// a group. Wildcard instances never back them up, so explicit settings win.
+func claimedDatabases(group config.DBGroupConfig) map[string]bool {
+	claimed := make(map[string]bool)
+	for _, instance := range group.Instances {
+		if instance.Database != config.AllDatabases {
+			claimed[instance.Database] = true
+		}
+	}
+	return claimed
+}
+
+// expandDatabases returns the databases instance covers: its own database,
+// or for config.AllDatabases every database probe lists on the server minus
+// system databases, the instance's exclusions and databases already claimed.
+// Returned databases are added to claimed.
+func expandDatabases(
+	probe Database,
+	instance config.DBInstance,
+	claimed map[string]bool,
+) ([]string, error) {
+	if instance.Database != config.AllDatabases {
+		return []string{instance.Database}, nil
+	}
+	lister, ok := probe.(Lister)
+	if !ok {
+		return nil, fmt.Errorf("%s does not support %q instances", probe.GetEngine(), config.AllDatabases)
+	}
+	all, err := lister.ListDatabases()
+	if err != nil {
+		return nil, fmt.Errorf("list databases on %s: %w", probe.GetHost(), err)
+	}
+	var names []string
+	for _, name := range all {
+		if claimed[name] ||
+			slices.Contains(systemDatabases[probe.GetEngine()], name) ||
+			slices.Contains(instance.Exclude, name) {
+			continue
+		}
+		claimed[name] = true
+		names = append(names, name)
+	}
+	return names, nil
+}
@@ -0,0 +1,42 @@
+package database
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/kebairia/backup/internal/config"
+)
+
+// fakeServer lists a fixed set of databases.
+type fakeServer struct {
+	Postgres
+	databases []string
+}
+
+func (f *fakeServer) ListDatabases() ([]string, error) { return f.databases, nil }
+
+func TestExpandDatabases(t *testing.T) {
+	group := config.DBGroupConfig{Instances: []config.DBInstance{
+		{Name: "all", Database: config.AllDatabases, Exclude: []string{"scratch"}},
+		{Name: "billing", Database: "billing"},
+	}}
+	claimed := claimedDatabases(group)
+	probe := &fakeServer{databases: []string{"app", "billing", "postgres", "scratch", "template1"}}
+
+	names, err := expandDatabases(probe, group.Instances[0], claimed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"app", "postgres"}; !slices.Equal(names, want) {
+		t.Fatalf("got %v, want %v", names, want)
+	}
+
+	// a second wildcard instance on another host skips databases already taken
+	names, err = expandDatabases(probe, config.DBInstance{Database: config.AllDatabases}, claimed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"scratch"}; !slices.Equal(names, want) {
+		t.Fatalf("got %v, want %v", names, want)
+	}
+}
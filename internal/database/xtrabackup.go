@@ -0,0 +1,250 @@
+package database
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Physical MySQL backup methods. Both stream a hot copy of the whole server
+// in xbstream format; the configured database only names the artifact.
+const (
+	MySQLMethodDump        = "dump"
+	MySQLMethodXtraBackup  = "xtrabackup"  // Percona XtraBackup (MySQL, Percona Server)
+	MySQLMethodMariaBackup = "mariabackup" // MariaDB
+)
+
+// xbstreamExt is the extension of streamed physical backups.
+const xbstreamExt = ".xbstream"
+
+// isPhysical reports whether the configured method takes physical backups.
+func (m *MySQL) isPhysical() bool {
+	return m.Method == MySQLMethodXtraBackup || m.Method == MySQLMethodMariaBackup
+}
+
+// physicalTools returns the backup and stream extraction binaries for the
+// configured method.
+func (m *MySQL) physicalTools() (backup, stream string) {
+	if m.Method == MySQLMethodMariaBackup {
+		return "mariabackup", "mbstream"
+	}
+	return "xtrabackup", "xbstream"
+}
+
+// DumpTool returns mysqldump, or the physical backup tool.
+func (m *MySQL) DumpTool() string {
+	if m.isPhysical() {
+		backup, _ := m.physicalTools()
+		return backup
+	}
+	return "mysqldump"
+}
+
+// RestoreTool returns mysql, or the physical backup tool, which prepares
+// and copies back physical backups.
+func (m *MySQL) RestoreTool() string {
+	if m.isPhysical() {
+		backup, _ := m.physicalTools()
+		return backup
+	}
+	return "mysql"
+}
+
+// physicalBackup streams a hot physical copy of the server into a
+// timestamped .xbstream file.
+func (m *MySQL) physicalBackup(ctx context.Context, backupsDir string) (string, error) {
+	tool, _ := m.physicalTools()
+	name, err := m.artifactName()
+	if err != nil {
+		return "", err
+	}
+	backupPath := filepath.Join(backupsDir, name+xbstreamExt)
+
+	// xtrabackup needs a scratch directory even when streaming
+	scratch, err := os.MkdirTemp(backupsDir, ".xtrabackup-*")
+	if err != nil {
+		return "", fmt.Errorf("create scratch directory: %w", err)
+	}
+	defer os.RemoveAll(scratch)
+
+	out, err := os.Create(backupPath)
+	if err != nil {
+		return "", fmt.Errorf("create %q: %w", backupPath, err)
+	}
+	defer out.Close()
+
+	// the option file must come first; the tools read their own group
+	options, cleanup, err := mysqlOptionFile(m.Password, "client", tool)
+	if err != nil {
+		return "", err
+	}
+	defer cleanup()
+	cmd, err := m.command(ctx, nil, tool,
+		"--defaults-extra-file="+options,
+		"--backup",
+		"--stream=xbstream",
+		"--host="+m.Host,
+		"--port="+m.Port,
+		"--user="+m.Username,
+		"--target-dir="+scratch,
+	)
+	if err != nil {
+		return backupPath, err
+	}
+	cmd.Stdout = out
+	cmd.Stderr = m.stderr()
+
+	m.Logger.Info("backup started",
+		"database", m.Database,
+		"engine", mysqlEngine,
+		"method", m.Method,
+		"path", backupPath,
+	)
+	start := time.Now()
+	if err := cmd.Run(); err != nil {
+		return backupPath, fmt.Errorf("%s failed: %w", tool, err)
+	}
+	if err := out.Sync(); err != nil {
+		return backupPath, fmt.Errorf("sync %q: %w", backupPath, err)
+	}
+	m.Logger.Info("backup completed", "duration", time.Since(start).String())
+	return backupPath, nil
+}
+
+// physicalRestore extracts and prepares a streamed physical backup, then
+// copies it back into the configured data directory. The server must be
+// stopped and the data directory empty; it has to run on the database host,
+// so restore targets on other hosts are rejected.
+func (m *MySQL) physicalRestore(ctx context.Context, backupFile string) error {
+	if m.DataDir == "" {
+		return fmt.Errorf("%w: %s restore needs datadir", ErrUnsupportedRestoreMethod, m.Method)
+	}
+	if m.RestoreHost != "" || m.RestoreDatabase != "" {
+		return fmt.Errorf("%w: %s restores cannot be retargeted", ErrUnsupportedRestoreMethod, m.Method)
+	}
+	tool, _ := m.physicalTools()
+
+	prepared, err := m.extractPhysical(ctx, backupFile)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(prepared)
+
+	m.Logger.Info("restore started",
+		"database", m.Database,
+		"engine", mysqlEngine,
+		"method", m.Method,
+		"datadir", m.DataDir,
+	)
+	start := time.Now()
+	if err := m.run(ctx, tool, "--prepare", "--target-dir="+prepared); err != nil {
+		return fmt.Errorf("%s --prepare failed: %w", tool, err)
+	}
+	if err := m.run(ctx, tool, "--copy-back", "--target-dir="+prepared, "--datadir="+m.DataDir); err != nil {
+		return fmt.Errorf("%s --copy-back failed: %w", tool, err)
+	}
+	m.Logger.Info("restore completed",
+		"duration", time.Since(start).String(),
+		"note", "fix ownership of datadir (e.g. chown -R mysql:mysql) before starting the server",
+	)
+	return nil
+}
+
+// validatePhysical extracts a streamed backup and checks that the backup
+// tool recorded a complete full backup. It returns the files found.
+func (m *MySQL) validatePhysical(path string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(orBackground(m.ctx), m.Timeout)
+	defer cancel()
+
+	dir, err := m.extractPhysical(ctx, path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidArtifact, err)
+	}
+	defer os.RemoveAll(dir)
+
+	backupType, err := checkpointValue(filepath.Join(dir, "xtrabackup_checkpoints"), "backup_type")
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrInvalidArtifact, path, err)
+	}
+	if backupType != "full-backuped" {
+		return nil, fmt.Errorf("%w: %s has backup_type %q", ErrInvalidArtifact, path, backupType)
+	}
+
+	var files []string
+	err = filepath.WalkDir(dir, func(file string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, file)
+		files = append(files, rel)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidArtifact, err)
+	}
+	return files, nil
+}
+
+// extractPhysical unpacks a .xbstream file into a temporary directory next to
+// it and returns the directory. The caller removes it.
+func (m *MySQL) extractPhysical(ctx context.Context, backupFile string) (string, error) {
+	_, stream := m.physicalTools()
+	in, err := os.Open(backupFile)
+	if err != nil {
+		return "", fmt.Errorf("backup file %q not found: %w", backupFile, err)
+	}
+	defer in.Close()
+
+	dir, err := os.MkdirTemp(filepath.Dir(backupFile), ".extract-*")
+	if err != nil {
+		return "", fmt.Errorf("create extraction directory: %w", err)
+	}
+	cmd, err := m.command(ctx, nil, stream, "-x", "-C", dir)
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+	cmd.Stdin = in
+	cmd.Stderr = m.stderr()
+	if err := cmd.Run(); err != nil {
+		os.RemoveAll(dir)
+		return "", fmt.Errorf("%s -x failed: %w", stream, err)
+	}
+	return dir, nil
+}
+
+// checkpointValue reads key from an xtrabackup_checkpoints file.
+func checkpointValue(path, key string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		k, v, ok := strings.Cut(scanner.Text(), "=")
+		if ok && strings.TrimSpace(k) == key {
+			return strings.TrimSpace(v), nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("no %s in %s", key, filepath.Base(path))
+}
+
+// run runs name with args, streaming its output to stderr.
+func (m *MySQL) run(ctx context.Context, name string, args ...string) error {
+	cmd, err := m.command(ctx, nil, name, args...)
+	if err != nil {
+		return err
+	}
+	cmd.Stdout = m.stderr()
+	cmd.Stderr = m.stderr()
+	return cmd.Run()
+}
@@ -0,0 +1,282 @@
+// Package encryption encrypts backup artifacts at rest with envelope
+// encryption: every artifact gets a fresh AES-256 data key generated locally,
+// the data is sealed with AES-GCM in fixed-size chunks, and only the data key
+// is sent to a KeyWrapper (e.g. Vault Transit) to be wrapped. The wrapped key
+// is stored in the artifact header, so key management stays outside bacli.
+package encryption
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Suffix is appended to the path of encrypted artifacts.
+const Suffix = ".enc"
+
+const (
+	magic     = "BACLIENC"
+	version   = 1
+	keySize   = 32        // AES-256
+	chunkSize = 64 * 1024 // plaintext bytes per sealed chunk
+)
+
+var (
+	// ErrEncryption indicates that an artifact could not be encrypted.
+	ErrEncryption = errors.New("encryption failed")
+	// ErrDecryption indicates that an artifact could not be decrypted, either
+	// because the data key could not be unwrapped or the data was tampered with.
+	ErrDecryption = errors.New("decryption failed")
+)
+
+// KeyWrapper wraps and unwraps data keys with a key that never leaves the
+// key management service.
+type KeyWrapper interface {
+	WrapKey(ctx context.Context, key []byte) (string, error)
+	UnwrapKey(ctx context.Context, wrapped string) ([]byte, error)
+}
+
+// EncryptFile encrypts path into path+Suffix and removes the original.
+// On failure the partial output is removed and the original is kept.
+func EncryptFile(ctx context.Context, wrapper KeyWrapper, path string) (outputPath string, err error) {
+	key := make([]byte, keySize)
+	if _, err := rand.Read(key); err != nil {
+		return "", fmt.Errorf("%w: generate data key: %v", ErrEncryption, err)
+	}
+	wrapped, err := wrapper.WrapKey(ctx, key)
+	if err != nil {
+		return "", fmt.Errorf("%w: wrap data key: %w", ErrEncryption, err)
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrEncryption, err)
+	}
+
+	in, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrEncryption, err)
+	}
+	defer in.Close()
+
+	outputPath = path + Suffix
+	out, err := os.Create(outputPath)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrEncryption, err)
+	}
+	defer func() {
+		if err != nil {
+			out.Close()
+			os.Remove(outputPath)
+		}
+	}()
+
+	w := bufio.NewWriter(out)
+	if err := writeHeader(w, wrapped); err != nil {
+		return "", fmt.Errorf("%w: write header: %v", ErrEncryption, err)
+	}
+	if err := seal(aead, bufio.NewReaderSize(in, chunkSize), w); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrEncryption, err)
+	}
+	if err := w.Flush(); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrEncryption, err)
+	}
+	if err := out.Sync(); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrEncryption, err)
+	}
+	if err := out.Close(); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrEncryption, err)
+	}
+	if err := os.Remove(path); err != nil {
+		return "", fmt.Errorf("%w: remove original: %v", ErrEncryption, err)
+	}
+	return outputPath, nil
+}
+
+// DecryptFile decrypts path (which must end in Suffix) next to it, with the
+// suffix stripped, and returns the plaintext path. The encrypted file is kept.
+func DecryptFile(ctx context.Context, wrapper KeyWrapper, path string) (string, error) {
+	if !strings.HasSuffix(path, Suffix) {
+		return "", fmt.Errorf("%w: %q does not end in %s", ErrDecryption, path, Suffix)
+	}
+	return DecryptTo(ctx, wrapper, path, strings.TrimSuffix(path, Suffix))
+}
+
+// DecryptTo decrypts path into outputPath. On failure the partial output is
+// removed.
+func DecryptTo(ctx context.Context, wrapper KeyWrapper, path, outputPath string) (_ string, err error) {
+	in, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrDecryption, err)
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(outputPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrDecryption, err)
+	}
+	defer func() {
+		if err != nil {
+			out.Close()
+			os.Remove(outputPath)
+		}
+	}()
+
+	w := bufio.NewWriter(out)
+	if err := Decrypt(ctx, wrapper, in, w); err != nil {
+		return "", fmt.Errorf("%w (%q)", err, path)
+	}
+	if err := w.Flush(); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrDecryption, err)
+	}
+	if err := out.Close(); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrDecryption, err)
+	}
+	return outputPath, nil
+}
+
+// Decrypt decrypts the artifact read from r into w as it reads it, e.g.
+// to restore it without writing the plaintext to disk. Chunks are written
+// as they are authenticated: a truncated or damaged artifact fails only
+// once its plaintext up to the damage has been written.
+func Decrypt(ctx context.Context, wrapper KeyWrapper, r io.Reader, w io.Writer) error {
+	br := bufio.NewReaderSize(r, chunkSize+16)
+	wrapped, err := readHeader(br)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrDecryption, err)
+	}
+	key, err := wrapper.UnwrapKey(ctx, wrapped)
+	if err != nil {
+		return fmt.Errorf("%w: unwrap data key: %w", ErrDecryption, err)
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrDecryption, err)
+	}
+	if err := open(aead, br, w); err != nil {
+		return fmt.Errorf("%w: %v", ErrDecryption, err)
+	}
+	return nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// writeHeader writes the magic, format version and the wrapped data key.
+func writeHeader(w io.Writer, wrapped string) error {
+	if len(wrapped) > 0xffff {
+		return fmt.Errorf("wrapped key too long (%d bytes)", len(wrapped))
+	}
+	header := make([]byte, 0, len(magic)+3+len(wrapped))
+	header = append(header, magic...)
+	header = append(header, version)
+	header = binary.BigEndian.AppendUint16(header, uint16(len(wrapped)))
+	header = append(header, wrapped...)
+	_, err := w.Write(header)
+	return err
+}
+
+// Encrypted reports whether head, the first bytes of a file, opens an
+// artifact encrypted by this package.
+func Encrypted(head []byte) bool {
+	return bytes.HasPrefix(head, []byte(magic))
+}
+
+// readHeader reads the header written by writeHeader and returns the wrapped key.
+func readHeader(r io.Reader) (string, error) {
+	fixed := make([]byte, len(magic)+3)
+	if _, err := io.ReadFull(r, fixed); err != nil {
+		return "", fmt.Errorf("read header: %w", err)
+	}
+	if string(fixed[:len(magic)]) != magic {
+		return "", errors.New("not a bacli encrypted artifact")
+	}
+	if v := fixed[len(magic)]; v != version {
+		return "", fmt.Errorf("unsupported format version %d", v)
+	}
+	wrapped := make([]byte, binary.BigEndian.Uint16(fixed[len(magic)+1:]))
+	if _, err := io.ReadFull(r, wrapped); err != nil {
+		return "", fmt.Errorf("read wrapped key: %w", err)
+	}
+	return string(wrapped), nil
+}
+
+// seal encrypts r into w chunk by chunk. Each chunk uses its index as nonce
+// (the data key is never reused) and is authenticated together with a flag
+// marking the final chunk, so truncation and reordering are detected.
+func seal(aead cipher.AEAD, r *bufio.Reader, w io.Writer) error {
+	buf := make([]byte, chunkSize)
+	out := make([]byte, 0, chunkSize+aead.Overhead())
+	for counter := uint64(0); ; counter++ {
+		n, err := io.ReadFull(r, buf)
+		if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+			return err
+		}
+		final := n < chunkSize
+		if !final {
+			if _, err := r.Peek(1); errors.Is(err, io.EOF) {
+				final = true
+			}
+		}
+		out = aead.Seal(out[:0], nonce(aead, counter), buf[:n], chunkAAD(final))
+		if _, err := w.Write(out); err != nil {
+			return err
+		}
+		if final {
+			return nil
+		}
+	}
+}
+
+// open reverses seal.
+func open(aead cipher.AEAD, r *bufio.Reader, w io.Writer) error {
+	buf := make([]byte, chunkSize+aead.Overhead())
+	for counter := uint64(0); ; counter++ {
+		n, err := io.ReadFull(r, buf)
+		if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+			return err
+		}
+		final := n < len(buf)
+		if !final {
+			if _, err := r.Peek(1); errors.Is(err, io.EOF) {
+				final = true
+			}
+		}
+		plain, err := aead.Open(buf[:0], nonce(aead, counter), buf[:n], chunkAAD(final))
+		if err != nil {
+			return fmt.Errorf("chunk %d: %w", counter, err)
+		}
+		if _, err := w.Write(plain); err != nil {
+			return err
+		}
+		if final {
+			return nil
+		}
+	}
+}
+
+func nonce(aead cipher.AEAD, counter uint64) []byte {
+	n := make([]byte, aead.NonceSize())
+	binary.BigEndian.PutUint64(n[len(n)-8:], counter)
+	return n
+}
+
+func chunkAAD(final bool) []byte {
+	if final {
+		return []byte{1}
+	}
+	return []byte{0}
+}
@@ -0,0 +1,91 @@
+package encryption
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// xorWrapper is a stand-in for a KMS that never leaves the test.
+type xorWrapper struct{}
+
+func (xorWrapper) WrapKey(_ context.Context, key []byte) (string, error) {
+	out := make([]byte, len(key))
+	for i, b := range key {
+		out[i] = b ^ 0x5a
+	}
+	return string(out), nil
+}
+
+func (w xorWrapper) UnwrapKey(ctx context.Context, wrapped string) ([]byte, error) {
+	key, err := w.WrapKey(ctx, []byte(wrapped))
+	return []byte(key), err
+}
+
+func TestRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	for _, size := range []int{0, 10, chunkSize, 2*chunkSize + 5} {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "db.dump")
+		data := make([]byte, size)
+		rand.Read(data)
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		encPath, err := EncryptFile(ctx, xorWrapper{}, path)
+		if err != nil {
+			t.Fatalf("size %d: encrypt: %v", size, err)
+		}
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Fatalf("size %d: original was not removed", size)
+		}
+		decPath, err := DecryptFile(ctx, xorWrapper{}, encPath)
+		if err != nil {
+			t.Fatalf("size %d: decrypt: %v", size, err)
+		}
+		got, err := os.ReadFile(decPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Fatalf("size %d: round trip mismatch", size)
+		}
+	}
+}
+
+func TestDecryptDetectsTampering(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "db.dump")
+	if err := os.WriteFile(path, bytes.Repeat([]byte("x"), 2*chunkSize), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	encPath, err := EncryptFile(ctx, xorWrapper{}, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := os.ReadFile(encPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, data := range map[string][]byte{
+		"flipped":   append(append([]byte{}, sealed[:len(sealed)-1]...), sealed[len(sealed)-1]^1),
+		"truncated": sealed[:len(sealed)-(chunkSize/2)],
+	} {
+		if err := os.WriteFile(encPath, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := DecryptFile(ctx, xorWrapper{}, encPath); !errors.Is(err, ErrDecryption) {
+			t.Errorf("%s: got %v, want ErrDecryption", name, err)
+		}
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s: partial plaintext left behind", name)
+		}
+	}
+}
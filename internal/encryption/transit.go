@@ -0,0 +1,51 @@
+package encryption
+
+import (
+	"context"
+	"fmt"
+)
+
+// TypeVaultTransit selects envelope encryption with keys wrapped by Vault's
+// transit secrets engine.
+const TypeVaultTransit = "vault-transit"
+
+// TransitClient encrypts and decrypts with the keys of a Vault transit
+// mount. It is implemented by *vault.Client; tests substitute fakes.
+type TransitClient interface {
+	TransitEncrypt(ctx context.Context, mount, key string, plaintext []byte) (string, error)
+	TransitDecrypt(ctx context.Context, mount, key, ciphertext string) ([]byte, error)
+}
+
+// Transit wraps data keys with a named key of a Vault transit mount.
+type Transit struct {
+	Client TransitClient
+	Mount  string // transit mount, e.g. "transit"
+	Key    string // transit key name
+}
+
+// Ensure Transit satisfies KeyWrapper.
+var _ KeyWrapper = (*Transit)(nil)
+
+// NewTransit returns a Transit key wrapper.
+func NewTransit(client TransitClient, mount, key string) (*Transit, error) {
+	if client == nil {
+		return nil, fmt.Errorf("%w: vault-transit requires a vault client", ErrEncryption)
+	}
+	if key == "" {
+		return nil, fmt.Errorf("%w: vault-transit requires a key name", ErrEncryption)
+	}
+	if mount == "" {
+		mount = "transit"
+	}
+	return &Transit{Client: client, Mount: mount, Key: key}, nil
+}
+
+// WrapKey encrypts key with the transit key.
+func (t *Transit) WrapKey(ctx context.Context, key []byte) (string, error) {
+	return t.Client.TransitEncrypt(ctx, t.Mount, t.Key, key)
+}
+
+// UnwrapKey decrypts a key wrapped by WrapKey.
+func (t *Transit) UnwrapKey(ctx context.Context, wrapped string) ([]byte, error) {
+	return t.Client.TransitDecrypt(ctx, t.Mount, t.Key, wrapped)
+}
@@ -0,0 +1,129 @@
+// Package events streams the lifecycle events of a run (run_started,
+// db_backup_completed, upload_completed, prune_deleted, ...) as JSON lines
+// to a file or a Unix socket, so that orchestrators can react to a run as
+// it goes instead of parsing its logs.
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kebairia/backup/internal/logger"
+	"github.com/kebairia/backup/internal/redact"
+)
+
+// Event types.
+const (
+	RunStarted       = "run_started"
+	RunCompleted     = "run_completed"
+	BackupStarted    = "db_backup_started"
+	BackupCompleted  = "db_backup_completed"
+	UploadCompleted  = "upload_completed"
+	RestoreStarted   = "db_restore_started"
+	RestoreCompleted = "db_restore_completed"
+	PruneDeleted     = "prune_deleted"
+)
+
+// socketPrefix selects a Unix socket rather than a file in Open.
+const socketPrefix = "unix:"
+
+// ErrEvents indicates that the events stream could not be opened.
+var ErrEvents = errors.New("events stream")
+
+// Event is one line of the stream. Data holds the fields specific to the
+// type, e.g. status, size_bytes and error for db_backup_completed.
+type Event struct {
+	Time     time.Time      `json:"time"`
+	Type     string         `json:"type"`
+	RunID    string         `json:"run_id,omitempty"`
+	Engine   string         `json:"engine,omitempty"`
+	Database string         `json:"database,omitempty"`
+	Data     map[string]any `json:"data,omitempty"`
+}
+
+var (
+	mu     sync.Mutex
+	sink   io.WriteCloser // nil when no stream is open
+	target string
+)
+
+// Open starts streaming events to target: "unix:/path" connects to a Unix
+// socket, anything else is a file events are appended to. Open replaces a
+// stream opened before.
+func Open(to string) error {
+	var (
+		w   io.WriteCloser
+		err error
+	)
+	if path, ok := strings.CutPrefix(to, socketPrefix); ok {
+		w, err = net.Dial("unix", path)
+	} else {
+		w, err = os.OpenFile(to, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: open %s: %w", ErrEvents, to, err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if sink != nil {
+		_ = sink.Close()
+	}
+	sink, target = w, to
+	return nil
+}
+
+// Emit writes event to the stream, stamped with the current time, unless
+// no stream is open. Empty strings are left out of Data and errors are
+// redacted. A stream that fails is closed with a warning: events never fail
+// the run.
+func Emit(event Event) {
+	mu.Lock()
+	defer mu.Unlock()
+	if sink == nil {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+	for key, value := range event.Data {
+		text, ok := value.(string)
+		switch {
+		case !ok:
+		case text == "":
+			delete(event.Data, key) // e.g. the error of a success
+		case key == "error":
+			event.Data[key] = redact.String(text)
+		}
+	}
+	line, err := json.Marshal(event)
+	if err == nil {
+		_, err = sink.Write(append(line, '\n'))
+	}
+	if err != nil {
+		logger.Global().Warn("events stream closed",
+			"target", target,
+			"error", err.Error(),
+		)
+		_ = sink.Close()
+		sink = nil
+	}
+}
+
+// Close closes the stream, if one is open.
+func Close() error {
+	mu.Lock()
+	defer mu.Unlock()
+	if sink == nil {
+		return nil
+	}
+	err := sink.Close()
+	sink = nil
+	return err
+}
@@ -0,0 +1,79 @@
+package events
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kebairia/backup/internal/redact"
+)
+
+func TestEmitToFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	Emit(Event{Type: RunStarted}) // no stream: dropped
+	if err := Open(path); err != nil {
+		t.Fatal(err)
+	}
+	redact.Register("s3cr3t")
+	Emit(Event{Type: RunStarted, RunID: "r1"})
+	Emit(Event{Type: BackupCompleted, RunID: "r1", Engine: "postgres", Database: "billing",
+		Data: map[string]any{"status": "failed", "error": "password s3cr3t rejected"}})
+	if err := Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []Event
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		var event Event
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatalf("line %q: %v", scanner.Text(), err)
+		}
+		got = append(got, event)
+	}
+	if len(got) != 2 || got[0].Type != RunStarted || got[1].Database != "billing" || got[0].Time.IsZero() {
+		t.Fatalf("events = %+v", got)
+	}
+	if message := got[1].Data["error"].(string); message == "password s3cr3t rejected" {
+		t.Errorf("error %q was not redacted", message)
+	}
+}
+
+func TestEmitToSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.sock")
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		t.Skipf("unix sockets unavailable: %v", err)
+	}
+	defer listener.Close()
+	lines := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		scanner := bufio.NewScanner(conn)
+		if scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+
+	if err := Open("unix:" + path); err != nil {
+		t.Fatal(err)
+	}
+	defer Close()
+	Emit(Event{Type: UploadCompleted, Data: map[string]any{"key": "postgres/billing/billing.dump"}})
+	var event Event
+	if err := json.Unmarshal([]byte(<-lines), &event); err != nil || event.Type != UploadCompleted {
+		t.Errorf("received %+v, %v", event, err)
+	}
+}
@@ -1,15 +1,82 @@
 package logger
 
 import (
+	"context"
+	"io"
+	"os"
+
+	"github.com/kebairia/backup/internal/redact"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
 
+// Options controls how log output is rendered.
+type Options struct {
+	Quiet   bool // only log errors
+	Verbose bool // log debug messages too
+	NoColor bool // never emit ANSI color codes
+	Stderr  bool // write logs to stderr, keeping stdout for command output
+	// Wrap, when set, wraps the log output, e.g. to keep logs from
+	// running into progress lines on the same terminal.
+	Wrap func(io.Writer) io.Writer
+}
+
+// options holds the settings applied by every subsequent Init call.
+var options Options
+
+// Configure sets the output options used by Init. Call it before Init.
+func Configure(opts Options) {
+	options = opts
+}
+
+// Quiet reports whether only errors are logged. Engines also keep their
+// client tools quiet then.
+func Quiet() bool { return options.Quiet }
+
+// Verbose reports whether debug messages are logged. Engines also run
+// their client tools verbosely then.
+func Verbose() bool { return options.Verbose }
+
+// isTerminal reports whether f is attached to an interactive terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
 type Logger interface {
 	Debug(msg string, keysAndValues ...any)
 	Info(msg string, keysAndValues ...any)
 	Warn(msg string, keysAndValues ...any)
 	Error(msg string, keysAndValues ...any)
+	// With returns a Logger adding keysAndValues to every entry.
+	With(keysAndValues ...any) Logger
+}
+
+// fieldsKey is the context key of the fields set by WithFields.
+type fieldsKey struct{}
+
+// WithFields returns a copy of ctx carrying keysAndValues, which the
+// loggers returned by FromContext add to every entry; e.g. the ID of a
+// run, to correlate the entries of the operator and of the engines.
+func WithFields(ctx context.Context, keysAndValues ...any) context.Context {
+	fields, _ := ctx.Value(fieldsKey{}).([]any)
+	return context.WithValue(ctx, fieldsKey{}, append(fields[:len(fields):len(fields)], keysAndValues...))
+}
+
+// FromContext returns l adding the fields carried by ctx (see WithFields),
+// or l itself when ctx is nil or carries none.
+func FromContext(ctx context.Context, l Logger) Logger {
+	if ctx == nil {
+		return l
+	}
+	fields, _ := ctx.Value(fieldsKey{}).([]any)
+	if len(fields) == 0 {
+		return l
+	}
+	return l.With(fields...)
 }
 
 // zapLogger wraps a *zap.SugaredLogger and implements Logger.
@@ -40,6 +107,49 @@ func (l *zapLogger) Error(msg string, keysAndValues ...any) {
 	l.sugar.Errorw(msg, keysAndValues...)
 }
 
+// With returns a logger adding keysAndValues to every entry.
+func (l *zapLogger) With(keysAndValues ...any) Logger {
+	return &zapLogger{sugar: l.sugar.With(keysAndValues...)}
+}
+
+// redactCore removes credentials from the messages and string and error
+// fields of log entries (see redact).
+type redactCore struct {
+	zapcore.Core
+}
+
+func (c redactCore) With(fields []zapcore.Field) zapcore.Core {
+	return redactCore{c.Core.With(redactFields(fields))}
+}
+
+func (c redactCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+	return checked
+}
+
+func (c redactCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	entry.Message = redact.String(entry.Message)
+	return c.Core.Write(entry, redactFields(fields))
+}
+
+func redactFields(fields []zapcore.Field) []zapcore.Field {
+	out := make([]zapcore.Field, len(fields))
+	for i, field := range fields {
+		switch field.Type {
+		case zapcore.StringType:
+			field.String = redact.String(field.String)
+		case zapcore.ErrorType:
+			if err, ok := field.Interface.(error); ok {
+				field = zap.String(field.Key, redact.String(err.Error()))
+			}
+		}
+		out[i] = field
+	}
+	return out
+}
+
 // ----------------------------------------------------------------------------
 // globalSugar holds the SugaredLogger for easy global use (optional).
 var globalSugar *zap.SugaredLogger
@@ -61,12 +171,47 @@ func Init() (Logger, error) {
 	cfg.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
 	cfg.EncoderConfig.EncodeLevel = zapcore.CapitalLevelEncoder
 	cfg.OutputPaths = []string{"stdout"} // write logs to stdout
+	out := os.Stdout
+	if options.Stderr {
+		cfg.OutputPaths = []string{"stderr"}
+		out = os.Stderr
+	}
+
+	// Interactive terminals get human-friendly console output; pipes, cron
+	// and CI keep structured JSON without escape codes.
+	if isTerminal(out) {
+		cfg.Encoding = "console"
+		if !options.NoColor && os.Getenv("NO_COLOR") == "" {
+			cfg.EncoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
+		}
+	}
+	if options.Quiet {
+		cfg.Level = zap.NewAtomicLevelAt(zapcore.ErrorLevel)
+	}
+	if options.Verbose {
+		cfg.Level = zap.NewAtomicLevelAt(zapcore.DebugLevel)
+	}
 
 	// 3) Build the zap.Logger
-	zapLog, err := cfg.Build(
+	buildOpts := []zap.Option{
 		zap.AddCaller(),      // include file:line
 		zap.AddCallerSkip(1), // skip this Init frame
-	)
+	}
+	if options.Wrap != nil {
+		// same encoder and level, through the wrapped output
+		buildOpts = append(buildOpts, zap.WrapCore(func(zapcore.Core) zapcore.Core {
+			encoder := zapcore.NewJSONEncoder(cfg.EncoderConfig)
+			if cfg.Encoding == "console" {
+				encoder = zapcore.NewConsoleEncoder(cfg.EncoderConfig)
+			}
+			return zapcore.NewCore(encoder, zapcore.Lock(zapcore.AddSync(options.Wrap(out))), cfg.Level)
+		}))
+	}
+	// applied last, so that it wraps the output core
+	buildOpts = append(buildOpts, zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return redactCore{core}
+	}))
+	zapLog, err := cfg.Build(buildOpts...)
 	if err != nil {
 		return nil, err
 	}
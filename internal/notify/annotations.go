@@ -0,0 +1,205 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Annotation formats.
+const (
+	AnnotationsGrafana = "grafana" // POST <url>/api/annotations
+	AnnotationsGeneric = "generic" // POST <url> with an AnnotationEvent
+)
+
+// AnnotationsOption defines a functional option for configuring an
+// Annotations notifier.
+type AnnotationsOption func(*Annotations)
+
+// Annotations posts backup events to Grafana, or to a generic events API,
+// so that backup windows show on database dashboards: one annotation when
+// a run starts, and one spanning each database's operation when it ends.
+type Annotations struct {
+	URL          string
+	Format       string
+	Token        string // sent as a bearer token when set
+	DashboardUID string // Grafana: attach to this dashboard instead of the organization
+	Tags         []string
+	Client       *http.Client
+}
+
+// Ensure Annotations satisfies Notifier and StartNotifier.
+var (
+	_ Notifier      = (*Annotations)(nil)
+	_ StartNotifier = (*Annotations)(nil)
+)
+
+// AnnotationEvent is the body posted to generic events APIs.
+type AnnotationEvent struct {
+	Event     string    `json:"event"` // "start", or the status of a finished operation
+	Operation string    `json:"operation"`
+	RunID     string    `json:"run_id,omitempty"`
+	Engine    string    `json:"engine,omitempty"`
+	Database  string    `json:"database,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	StartedAt time.Time `json:"started_at"`
+	EndedAt   time.Time `json:"ended_at,omitzero"`
+	Tags      []string  `json:"tags"`
+	Text      string    `json:"text"`
+}
+
+// grafanaAnnotation is the body of Grafana's POST /api/annotations.
+type grafanaAnnotation struct {
+	DashboardUID string   `json:"dashboardUID,omitempty"`
+	Time         int64    `json:"time"`
+	TimeEnd      int64    `json:"timeEnd,omitempty"`
+	Tags         []string `json:"tags"`
+	Text         string   `json:"text"`
+}
+
+// NewAnnotations creates an Annotations notifier posting to url.
+func NewAnnotations(url string, opts ...AnnotationsOption) (*Annotations, error) {
+	if url == "" {
+		return nil, fmt.Errorf("%w: annotations url is required", ErrNotify)
+	}
+	a := &Annotations{
+		URL:    strings.TrimRight(url, "/"),
+		Format: AnnotationsGrafana,
+		Client: &http.Client{Timeout: 10 * time.Second},
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	switch a.Format {
+	case AnnotationsGrafana, AnnotationsGeneric:
+	default:
+		return nil, fmt.Errorf("%w: unknown annotations format %q", ErrNotify, a.Format)
+	}
+	return a, nil
+}
+
+// WithAnnotationsFormat selects the API posted to; empty keeps grafana.
+func WithAnnotationsFormat(format string) AnnotationsOption {
+	return func(a *Annotations) {
+		if format != "" {
+			a.Format = format
+		}
+	}
+}
+
+// WithAnnotationsToken authenticates with a bearer token (e.g. a Grafana
+// service account token).
+func WithAnnotationsToken(token string) AnnotationsOption {
+	return func(a *Annotations) { a.Token = token }
+}
+
+// WithAnnotationsDashboard attaches Grafana annotations to one dashboard.
+func WithAnnotationsDashboard(uid string) AnnotationsOption {
+	return func(a *Annotations) { a.DashboardUID = uid }
+}
+
+// WithAnnotationsTags adds tags to every annotation.
+func WithAnnotationsTags(tags []string) AnnotationsOption {
+	return func(a *Annotations) { a.Tags = tags }
+}
+
+// Name returns the notifier name.
+func (a *Annotations) Name() string { return "annotations" }
+
+// NotifyStart posts a point annotation for the start of a run.
+func (a *Annotations) NotifyStart(ctx context.Context, operation string) error {
+	return a.post(ctx, AnnotationEvent{
+		Event:     "start",
+		Operation: operation,
+		StartedAt: time.Now(),
+		Tags:      a.tags(operation, "start"),
+		Text:      fmt.Sprintf("bacli %s started", operation),
+	})
+}
+
+// Notify posts one annotation per result, spanning its operation and
+// tagged with its engine, database, status and run.
+func (a *Annotations) Notify(ctx context.Context, report Report) error {
+	for _, result := range report.Results {
+		started := result.StartedAt
+		if started.IsZero() {
+			started = report.StartedAt
+		}
+		text := fmt.Sprintf("bacli %s of %s/%s: %s", report.Operation, result.Engine, result.Database, result.Status)
+		if result.Error != "" {
+			text += ": " + result.Error
+		}
+		tags := []string{report.Operation, result.Status, "engine:" + result.Engine, "database:" + result.Database}
+		if report.RunID != "" {
+			tags = append(tags, "run:"+report.RunID)
+		}
+		err := a.post(ctx, AnnotationEvent{
+			Event:     result.Status,
+			Operation: report.Operation,
+			RunID:     report.RunID,
+			Engine:    result.Engine,
+			Database:  result.Database,
+			Error:     result.Error,
+			StartedAt: started,
+			EndedAt:   started.Add(result.Duration),
+			Tags:      a.tags(tags...),
+			Text:      text,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tags returns the tags of an annotation: "bacli", the configured ones and
+// the given ones.
+func (a *Annotations) tags(extra ...string) []string {
+	tags := append([]string{"bacli"}, a.Tags...)
+	return append(tags, extra...)
+}
+
+func (a *Annotations) post(ctx context.Context, event AnnotationEvent) error {
+	url := a.URL
+	var body any = event
+	if a.Format == AnnotationsGrafana {
+		url += "/api/annotations"
+		annotation := grafanaAnnotation{
+			DashboardUID: a.DashboardUID,
+			Time:         event.StartedAt.UnixMilli(),
+			Tags:         event.Tags,
+			Text:         event.Text,
+		}
+		if !event.EndedAt.IsZero() {
+			annotation.TimeEnd = event.EndedAt.UnixMilli()
+		}
+		body = annotation
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("%w: encode annotation: %v", ErrNotify, err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("%w: build annotation request: %v", ErrNotify, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if a.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+a.Token)
+	}
+	resp, err := a.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: post annotation: %v", ErrNotify, err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%w: post annotation to %s: %s", ErrNotify, url, resp.Status)
+	}
+	return nil
+}
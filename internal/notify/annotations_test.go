@@ -0,0 +1,48 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestAnnotationsGrafana(t *testing.T) {
+	var got []grafanaAnnotation
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/annotations" || r.Header.Get("Authorization") != "Bearer secret" {
+			t.Errorf("got %s with %q", r.URL.Path, r.Header.Get("Authorization"))
+		}
+		var annotation grafanaAnnotation
+		if err := json.NewDecoder(r.Body).Decode(&annotation); err != nil {
+			t.Error(err)
+		}
+		got = append(got, annotation)
+	}))
+	defer server.Close()
+
+	a, err := NewAnnotations(server.URL, WithAnnotationsToken("secret"), WithAnnotationsTags([]string{"prod"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2025, 4, 24, 21, 0, 0, 0, time.UTC)
+	report := Report{Operation: "backup", RunID: "0196acc3-4300", Results: []Result{
+		{Engine: "postgres", Database: "app", Status: StatusSuccess, StartedAt: start, Duration: time.Minute},
+	}}
+	if err := a.Notify(context.Background(), report); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 {
+		t.Fatalf("got %d annotations, want 1", len(got))
+	}
+	want := []string{"bacli", "prod", "backup", "success", "engine:postgres", "database:app", "run:0196acc3-4300"}
+	if !slices.Equal(got[0].Tags, want) {
+		t.Errorf("tags = %v, want %v", got[0].Tags, want)
+	}
+	if got[0].Time != start.UnixMilli() || got[0].TimeEnd != start.Add(time.Minute).UnixMilli() {
+		t.Errorf("region = %d..%d, want the backup's", got[0].Time, got[0].TimeEnd)
+	}
+}
@@ -0,0 +1,292 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	htmltemplate "html/template"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+const (
+	TLSNone     = "none"
+	TLSStartTLS = "starttls"
+	TLSImplicit = "tls"
+)
+
+const textTemplate = `bacli {{.Operation}} report
+{{if .RunID}}Run:       {{.RunID}}
+{{end}}Started:   {{.StartedAt.Format "2006-01-02 15:04:05 MST"}}
+Completed: {{.CompletedAt.Format "2006-01-02 15:04:05 MST"}}
+Duration:  {{.Duration}}
+Failed:    {{.Failed}}/{{len .Results}}
+
+{{range .Results}}- [{{.Status}}] {{.Engine}}/{{.Database}} ({{.Duration}}, {{.SizeBytes}} bytes){{if .Error}}
+    error: {{.Error}}{{end}}{{if .Stderr}}
+    stderr:
+{{.Stderr}}{{end}}
+{{end}}`
+
+const htmlTemplate = `<html><body>
+<h2>bacli {{.Operation}} report</h2>
+<p>{{if .RunID}}Run: {{.RunID}}<br>
+{{end}}Started: {{.StartedAt.Format "2006-01-02 15:04:05 MST"}}<br>
+Completed: {{.CompletedAt.Format "2006-01-02 15:04:05 MST"}}<br>
+Duration: {{.Duration}}<br>
+Failed: {{.Failed}}/{{len .Results}}</p>
+<table border="1" cellpadding="4" cellspacing="0">
+<tr><th>Status</th><th>Engine</th><th>Database</th><th>Duration</th><th>Size (bytes)</th><th>Error</th></tr>
+{{range .Results}}<tr><td>{{.Status}}</td><td>{{.Engine}}</td><td>{{.Database}}</td><td>{{.Duration}}</td><td>{{.SizeBytes}}</td><td>{{.Error}}{{if .Stderr}}<pre>{{.Stderr}}</pre>{{end}}</td></tr>
+{{end}}</table>
+</body></html>`
+
+var (
+	textTmpl = texttemplate.Must(texttemplate.New("text").Parse(textTemplate))
+	htmlTmpl = htmltemplate.Must(htmltemplate.New("html").Parse(htmlTemplate))
+)
+
+// EmailOption defines a functional option for configuring an Email notifier.
+type EmailOption func(*Email)
+
+// Email sends run summaries over SMTP.
+type Email struct {
+	Host        string
+	Port        int
+	TLS         string
+	From        string
+	Username    string
+	Password    string
+	All         []string // receive every report
+	Failures    []string // receive reports with failures only
+	TLSConfig   *tls.Config
+	DialTimeout time.Duration
+}
+
+// Ensure Email satisfies Notifier.
+var _ Notifier = (*Email)(nil)
+
+// NewEmail creates an Email notifier with sane defaults and the supplied options.
+func NewEmail(opts ...EmailOption) (*Email, error) {
+	e := &Email{
+		Port:        587,
+		TLS:         TLSStartTLS,
+		DialTimeout: 30 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	if e.Host == "" {
+		return nil, fmt.Errorf("%w: email host is required", ErrNotify)
+	}
+	if e.From == "" {
+		return nil, fmt.Errorf("%w: email sender is required", ErrNotify)
+	}
+	switch e.TLS {
+	case TLSNone, TLSStartTLS, TLSImplicit:
+	default:
+		return nil, fmt.Errorf("%w: unsupported email tls mode %q", ErrNotify, e.TLS)
+	}
+	if e.TLSConfig == nil {
+		e.TLSConfig = &tls.Config{ServerName: e.Host}
+	}
+	return e, nil
+}
+
+// WithEmailServer sets the SMTP host and port.
+func WithEmailServer(host string, port int) EmailOption {
+	return func(e *Email) {
+		if host != "" {
+			e.Host = host
+		}
+		if port != 0 {
+			e.Port = port
+		}
+	}
+}
+
+// WithEmailTLS sets the transport security mode (none, starttls, tls).
+func WithEmailTLS(mode string) EmailOption {
+	return func(e *Email) {
+		if mode != "" {
+			e.TLS = strings.ToLower(mode)
+		}
+	}
+}
+
+// WithEmailSender sets the From address.
+func WithEmailSender(from string) EmailOption {
+	return func(e *Email) {
+		if from != "" {
+			e.From = from
+		}
+	}
+}
+
+// WithEmailCredentials sets the SMTP AUTH username and password.
+func WithEmailCredentials(username, password string) EmailOption {
+	return func(e *Email) {
+		if username != "" {
+			e.Username = username
+		}
+		if password != "" {
+			e.Password = password
+		}
+	}
+}
+
+// WithEmailRecipients sets who receives every report and who receives failures only.
+func WithEmailRecipients(all, failures []string) EmailOption {
+	return func(e *Email) {
+		e.All = all
+		e.Failures = failures
+	}
+}
+
+// Name returns the notifier name.
+func (e *Email) Name() string { return "email" }
+
+// Notify renders the report and sends it to the matching recipients.
+func (e *Email) Notify(ctx context.Context, report Report) error {
+	recipients := e.recipients(report)
+	if len(recipients) == 0 {
+		return nil
+	}
+	msg, err := e.message(report, recipients)
+	if err != nil {
+		return fmt.Errorf("%w: render email: %v", ErrNotify, err)
+	}
+	if err := e.send(ctx, recipients, msg); err != nil {
+		return fmt.Errorf("%w: send email: %v", ErrNotify, err)
+	}
+	return nil
+}
+
+// recipients returns the de-duplicated recipient list for the report.
+func (e *Email) recipients(report Report) []string {
+	seen := make(map[string]bool)
+	var out []string
+	add := func(list []string) {
+		for _, addr := range list {
+			if addr != "" && !seen[addr] {
+				seen[addr] = true
+				out = append(out, addr)
+			}
+		}
+	}
+	add(e.All)
+	if !report.Succeeded() {
+		add(e.Failures)
+	}
+	return out
+}
+
+// message builds a multipart/alternative MIME message with text and HTML parts.
+func (e *Email) message(report Report, recipients []string) ([]byte, error) {
+	var text, html bytes.Buffer
+	if err := textTmpl.Execute(&text, report); err != nil {
+		return nil, err
+	}
+	if err := htmlTmpl.Execute(&html, report); err != nil {
+		return nil, err
+	}
+
+	status := "OK"
+	if !report.Succeeded() {
+		status = "FAILED"
+	}
+	subject := fmt.Sprintf("[bacli] %s %s (%d/%d failed)",
+		report.Operation, status, report.Failed(), len(report.Results))
+
+	boundary, err := randomBoundary()
+	if err != nil {
+		return nil, err
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", e.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(recipients, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", subject)
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&msg, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", boundary)
+
+	fmt.Fprintf(&msg, "--%s\r\n", boundary)
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.Write(text.Bytes())
+	fmt.Fprintf(&msg, "\r\n--%s\r\n", boundary)
+	msg.WriteString("Content-Type: text/html; charset=utf-8\r\n\r\n")
+	msg.Write(html.Bytes())
+	fmt.Fprintf(&msg, "\r\n--%s--\r\n", boundary)
+
+	return msg.Bytes(), nil
+}
+
+// send delivers msg over SMTP honoring the configured TLS mode.
+func (e *Email) send(ctx context.Context, recipients []string, msg []byte) error {
+	addr := net.JoinHostPort(e.Host, strconv.Itoa(e.Port))
+	dialer := &net.Dialer{Timeout: e.DialTimeout}
+
+	var conn net.Conn
+	var err error
+	if e.TLS == TLSImplicit {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: e.TLSConfig}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return fmt.Errorf("dial %s: %w", addr, err)
+	}
+
+	client, err := smtp.NewClient(conn, e.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("smtp handshake: %w", err)
+	}
+	defer client.Close()
+
+	if e.TLS == TLSStartTLS {
+		if err := client.StartTLS(e.TLSConfig); err != nil {
+			return fmt.Errorf("starttls: %w", err)
+		}
+	}
+	if e.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", e.Username, e.Password, e.Host)); err != nil {
+			return fmt.Errorf("auth: %w", err)
+		}
+	}
+	if err := client.Mail(e.From); err != nil {
+		return fmt.Errorf("mail from: %w", err)
+	}
+	for _, rcpt := range recipients {
+		if err := client.Rcpt(rcpt); err != nil {
+			return fmt.Errorf("rcpt %s: %w", rcpt, err)
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("data: %w", err)
+	}
+	if _, err := w.Write(msg); err != nil {
+		return fmt.Errorf("write body: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("close body: %w", err)
+	}
+	return client.Quit()
+}
+
+func randomBoundary() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
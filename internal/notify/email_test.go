@@ -0,0 +1,101 @@
+package notify
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEmailRecipients(t *testing.T) {
+	e := &Email{
+		All:      []string{"dba@example.com", "ops@example.com"},
+		Failures: []string{"oncall@example.com", "dba@example.com", ""},
+	}
+	tests := []struct {
+		name   string
+		status string
+		want   []string
+	}{
+		{"success", StatusSuccess, []string{"dba@example.com", "ops@example.com"}},
+		{"failure", StatusFailed, []string{"dba@example.com", "ops@example.com", "oncall@example.com"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := Report{Results: []Result{{Database: "app", Status: tt.status}}}
+			if got := e.recipients(report); !slices.Equal(got, tt.want) {
+				t.Errorf("recipients = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	failuresOnly := &Email{Failures: []string{"oncall@example.com"}}
+	if got := failuresOnly.recipients(Report{}); len(got) != 0 {
+		t.Errorf("clean run went to %v", got)
+	}
+}
+
+func TestEmailMessage(t *testing.T) {
+	e := &Email{From: "bacli@example.com"}
+	start := time.Date(2025, 4, 24, 21, 0, 0, 0, time.UTC)
+	report := Report{
+		Operation:   "backup",
+		RunID:       "0196acc3-4300",
+		StartedAt:   start,
+		CompletedAt: start.Add(time.Minute),
+		Results: []Result{
+			{Engine: "postgres", Database: "app", Status: StatusSuccess},
+			{Engine: "mysql", Database: "shop", Status: StatusFailed, Error: "mysqldump <exit 2>"},
+		},
+	}
+	raw, err := e.message(report, []string{"dba@example.com", "oncall@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := msg.Header.Get("Subject"); got != "[bacli] backup FAILED (1/2 failed)" {
+		t.Errorf("Subject = %q", got)
+	}
+	if got := msg.Header.Get("To"); got != "dba@example.com, oncall@example.com" {
+		t.Errorf("To = %q", got)
+	}
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("Content-Type = %q, %v", mediaType, err)
+	}
+
+	parts := map[string]string{}
+	reader := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(part)
+		if err != nil {
+			t.Fatal(err)
+		}
+		parts[part.Header.Get("Content-Type")] = string(body)
+	}
+	text := parts["text/plain; charset=utf-8"]
+	for _, want := range []string{"Run:       0196acc3-4300", "Failed:    1/2", "[failed] mysql/shop", "error: mysqldump <exit 2>"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("text part lacks %q:\n%s", want, text)
+		}
+	}
+	html := parts["text/html; charset=utf-8"]
+	if !strings.Contains(html, "mysqldump &lt;exit 2&gt;") {
+		t.Errorf("html part does not escape the error:\n%s", html)
+	}
+}
@@ -0,0 +1,85 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/kebairia/backup/internal/redact"
+)
+
+// ErrNotify indicates that a notification could not be delivered.
+var ErrNotify = errors.New("notification failed")
+
+const (
+	StatusSuccess = "success"
+	StatusFailed  = "failed"
+)
+
+// Result describes the outcome of a single database operation.
+type Result struct {
+	Engine   string `json:"engine"`
+	Database string `json:"database"`
+	FilePath string `json:"file_path,omitempty"`
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	// Stderr is the tail of the client tools' stderr of a failed result.
+	Stderr    string        `json:"stderr,omitempty"`
+	StartedAt time.Time     `json:"started_at,omitzero"`
+	Duration  time.Duration `json:"duration_ms"`
+	SizeBytes int64         `json:"size_bytes,omitempty"`
+}
+
+// Report summarizes a whole backup or restore run.
+type Report struct {
+	Operation string `json:"operation"` // "backup", "restore", "verify" or "drill"
+	// RunID identifies the run in logs, metadata records and events.
+	RunID       string    `json:"run_id,omitempty"`
+	StartedAt   time.Time `json:"started_at"`
+	CompletedAt time.Time `json:"completed_at"`
+	Results     []Result  `json:"results"`
+}
+
+// Failed returns the number of failed results in the report.
+func (r Report) Failed() int {
+	failed := 0
+	for _, res := range r.Results {
+		if res.Status != StatusSuccess {
+			failed++
+		}
+	}
+	return failed
+}
+
+// Succeeded reports whether every result in the report succeeded.
+func (r Report) Succeeded() bool {
+	return r.Failed() == 0
+}
+
+// Redacted returns a copy of the report with credentials removed from the
+// errors and stderr of its results.
+func (r Report) Redacted() Report {
+	r.Results = slices.Clone(r.Results)
+	for i := range r.Results {
+		r.Results[i].Error = redact.String(r.Results[i].Error)
+		r.Results[i].Stderr = redact.String(r.Results[i].Stderr)
+	}
+	return r
+}
+
+// Duration returns the wall-clock duration of the run.
+func (r Report) Duration() time.Duration {
+	return r.CompletedAt.Sub(r.StartedAt)
+}
+
+// Notifier delivers a run report to an external channel.
+type Notifier interface {
+	Name() string
+	Notify(ctx context.Context, report Report) error
+}
+
+// StartNotifier is implemented by notifiers that also signal when a run begins.
+type StartNotifier interface {
+	NotifyStart(ctx context.Context, operation string) error
+}
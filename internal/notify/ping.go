@@ -0,0 +1,90 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// PingOption defines a functional option for configuring a Ping notifier.
+type PingOption func(*Ping)
+
+// Ping hits a dead man's switch URL (Healthchecks.io, Dead Man's Snitch, ...)
+// so that missed or failed runs raise alerts outside of bacli.
+type Ping struct {
+	URL          string
+	SignalStart  bool // ping <url>/start when a run begins
+	SignalFailed bool // ping <url>/fail instead of staying silent on failure
+	Client       *http.Client
+}
+
+// Ensure Ping satisfies Notifier and StartNotifier.
+var (
+	_ Notifier      = (*Ping)(nil)
+	_ StartNotifier = (*Ping)(nil)
+)
+
+// NewPing creates a Ping notifier for url.
+func NewPing(url string, opts ...PingOption) (*Ping, error) {
+	if url == "" {
+		return nil, fmt.Errorf("%w: ping url is required", ErrNotify)
+	}
+	p := &Ping{
+		URL:    strings.TrimRight(url, "/"),
+		Client: &http.Client{Timeout: 10 * time.Second},
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p, nil
+}
+
+// WithPingSignals enables the /start and /fail signals.
+func WithPingSignals(start, failed bool) PingOption {
+	return func(p *Ping) {
+		p.SignalStart = start
+		p.SignalFailed = failed
+	}
+}
+
+// Name returns the notifier name.
+func (p *Ping) Name() string { return "ping" }
+
+// NotifyStart pings <url>/start when enabled.
+func (p *Ping) NotifyStart(ctx context.Context, operation string) error {
+	if !p.SignalStart {
+		return nil
+	}
+	return p.ping(ctx, p.URL+"/start")
+}
+
+// Notify pings the base URL on success and <url>/fail on failure.
+func (p *Ping) Notify(ctx context.Context, report Report) error {
+	if report.Succeeded() {
+		return p.ping(ctx, p.URL)
+	}
+	if p.SignalFailed {
+		return p.ping(ctx, p.URL+"/fail")
+	}
+	return nil
+}
+
+func (p *Ping) ping(ctx context.Context, url string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("%w: build ping request: %v", ErrNotify, err)
+	}
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: ping %s: %v", ErrNotify, url, err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%w: ping %s: %s", ErrNotify, url, resp.Status)
+	}
+	return nil
+}
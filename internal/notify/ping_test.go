@@ -0,0 +1,66 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func TestPing(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+	}))
+	defer server.Close()
+
+	succeeded := Report{Operation: "backup", Results: []Result{{Database: "app", Status: StatusSuccess}}}
+	failed := Report{Operation: "backup", Results: []Result{{Database: "app", Status: StatusFailed}}}
+	tests := []struct {
+		name          string
+		start, failed bool
+		report        Report
+		want          []string
+	}{
+		{"success", false, false, succeeded, []string{"/check"}},
+		{"failure without signal", false, false, failed, nil},
+		{"failure", false, true, failed, []string{"/check/fail"}},
+		{"start", true, false, succeeded, []string{"/check/start", "/check"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			paths = nil
+			p, err := NewPing(server.URL+"/check/", WithPingSignals(tt.start, tt.failed))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := p.NotifyStart(context.Background(), "backup"); err != nil {
+				t.Fatal(err)
+			}
+			if err := p.Notify(context.Background(), tt.report); err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(paths, tt.want) {
+				t.Errorf("pinged %v, want %v", paths, tt.want)
+			}
+		})
+	}
+}
+
+func TestPingStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unknown check", http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	p, err := NewPing(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = p.Notify(context.Background(), Report{Operation: "backup"})
+	if !errors.Is(err, ErrNotify) {
+		t.Errorf("error = %v, want ErrNotify for a 404", err)
+	}
+}
@@ -0,0 +1,93 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// ArtifactBackedUp is the event of an ArtifactEvent.
+const ArtifactBackedUp = "artifact_backed_up"
+
+// WebhookOption defines a functional option for configuring a Webhook.
+type WebhookOption func(*Webhook)
+
+// Webhook posts an ArtifactEvent as soon as a database is backed up, so
+// that downstream jobs (e.g. an analytics refresh) can pick up each dataset
+// without waiting for the run to finish.
+type Webhook struct {
+	URL    string
+	Token  string // sent as a bearer token when set
+	Client *http.Client
+}
+
+// ArtifactEvent describes one successful database backup.
+type ArtifactEvent struct {
+	Event    string `json:"event"`
+	RunID    string `json:"run_id,omitempty"`
+	Engine   string `json:"engine"`
+	Instance string `json:"instance,omitempty"`
+	Database string `json:"database"`
+	FilePath string `json:"file_path"`
+	// URI locates the stored artifact, e.g. "s3://bucket/prefix/key";
+	// empty without a storage backend.
+	URI        string            `json:"uri,omitempty"`
+	Checksum   string            `json:"checksum,omitempty"`
+	SizeBytes  int64             `json:"size_bytes"`
+	StartedAt  time.Time         `json:"started_at"`
+	DurationMS int64             `json:"duration_ms"`
+	Labels     map[string]string `json:"labels,omitempty"`
+}
+
+// NewWebhook creates a Webhook posting to url.
+func NewWebhook(url string, opts ...WebhookOption) (*Webhook, error) {
+	if url == "" {
+		return nil, fmt.Errorf("%w: webhook url is required", ErrNotify)
+	}
+	w := &Webhook{
+		URL:    url,
+		Client: &http.Client{Timeout: 10 * time.Second},
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	return w, nil
+}
+
+// WithWebhookToken authenticates with a bearer token.
+func WithWebhookToken(token string) WebhookOption {
+	return func(w *Webhook) { w.Token = token }
+}
+
+// Send posts event as JSON.
+func (w *Webhook) Send(ctx context.Context, event ArtifactEvent) error {
+	if event.Event == "" {
+		event.Event = ArtifactBackedUp
+	}
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("%w: encode webhook event: %v", ErrNotify, err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("%w: build webhook request: %v", ErrNotify, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if w.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+w.Token)
+	}
+	resp, err := w.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: post webhook: %v", ErrNotify, err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%w: post webhook to %s: %s", ErrNotify, w.URL, resp.Status)
+	}
+	return nil
+}
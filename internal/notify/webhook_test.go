@@ -0,0 +1,69 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWebhookSend(t *testing.T) {
+	var got ArtifactEvent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Authorization") != "Bearer secret" {
+			t.Errorf("got %s with %q", r.Method, r.Header.Get("Authorization"))
+		}
+		if r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("Content-Type = %q", r.Header.Get("Content-Type"))
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Error(err)
+		}
+	}))
+	defer server.Close()
+
+	w, err := NewWebhook(server.URL, WithWebhookToken("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	event := ArtifactEvent{
+		RunID:      "0196acc3-4300",
+		Engine:     "postgres",
+		Database:   "app",
+		FilePath:   "/backups/postgres/app/app.dump",
+		URI:        "s3://backups/postgres/app/app.dump",
+		SizeBytes:  2048,
+		StartedAt:  time.Date(2025, 4, 24, 21, 0, 0, 0, time.UTC),
+		DurationMS: 1500,
+	}
+	if err := w.Send(context.Background(), event); err != nil {
+		t.Fatal(err)
+	}
+	if got.Event != ArtifactBackedUp {
+		t.Errorf("event = %q, want %q by default", got.Event, ArtifactBackedUp)
+	}
+	got.Event = ""
+	if got.RunID != event.RunID || got.URI != event.URI || got.SizeBytes != event.SizeBytes ||
+		!got.StartedAt.Equal(event.StartedAt) || got.DurationMS != event.DurationMS {
+		t.Errorf("payload = %+v, want %+v", got, event)
+	}
+}
+
+func TestWebhookStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	w, err := NewWebhook(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = w.Send(context.Background(), ArtifactEvent{Engine: "postgres", Database: "app"})
+	if !errors.Is(err, ErrNotify) {
+		t.Errorf("error = %v, want ErrNotify for a 503", err)
+	}
+}
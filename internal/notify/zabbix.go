@@ -0,0 +1,116 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// zabbixHeader starts every Zabbix protocol message, followed by the
+// little-endian length of the JSON payload.
+var zabbixHeader = []byte("ZBXD\x01")
+
+// ZabbixItem is one value sent to a Zabbix trapper item.
+type ZabbixItem struct {
+	Host  string `json:"host"`
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// ZabbixSender sends trapper item values to a Zabbix server or proxy, as
+// zabbix_sender does.
+type ZabbixSender struct {
+	Address string // host:port, port 10051 when omitted
+	Timeout time.Duration
+}
+
+// NewZabbixSender creates a ZabbixSender for the server at address.
+func NewZabbixSender(address string) (*ZabbixSender, error) {
+	if address == "" {
+		return nil, fmt.Errorf("%w: zabbix server address is required", ErrNotify)
+	}
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		address = net.JoinHostPort(address, "10051")
+	}
+	return &ZabbixSender{Address: address, Timeout: 10 * time.Second}, nil
+}
+
+// Send sends items in one request and returns the server's summary, e.g.
+// "processed: 3; failed: 0; total: 3; seconds spent: 0.000055". Items the
+// server failed to process, e.g. for want of a matching trapper item, fail
+// the send even though the server reports success.
+func (z *ZabbixSender) Send(ctx context.Context, items []ZabbixItem) (string, error) {
+	payload, err := json.Marshal(struct {
+		Request string       `json:"request"`
+		Data    []ZabbixItem `json:"data"`
+	}{"sender data", items})
+	if err != nil {
+		return "", fmt.Errorf("%w: encode zabbix items: %v", ErrNotify, err)
+	}
+	dialer := net.Dialer{Timeout: z.Timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", z.Address)
+	if err != nil {
+		return "", fmt.Errorf("%w: connect to zabbix %s: %v", ErrNotify, z.Address, err)
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(z.Timeout))
+
+	var msg bytes.Buffer
+	msg.Write(zabbixHeader)
+	_ = binary.Write(&msg, binary.LittleEndian, uint64(len(payload)))
+	msg.Write(payload)
+	if _, err := conn.Write(msg.Bytes()); err != nil {
+		return "", fmt.Errorf("%w: send to zabbix %s: %v", ErrNotify, z.Address, err)
+	}
+
+	reply, err := io.ReadAll(conn)
+	if err != nil {
+		return "", fmt.Errorf("%w: read zabbix reply: %v", ErrNotify, err)
+	}
+	if len(reply) < len(zabbixHeader)+8 || !bytes.HasPrefix(reply, zabbixHeader) {
+		return "", fmt.Errorf("%w: malformed zabbix reply", ErrNotify)
+	}
+	var response struct {
+		Response string `json:"response"`
+		Info     string `json:"info"`
+	}
+	if err := json.Unmarshal(reply[len(zabbixHeader)+8:], &response); err != nil {
+		return "", fmt.Errorf("%w: decode zabbix reply: %v", ErrNotify, err)
+	}
+	if response.Response != "success" {
+		return response.Info, fmt.Errorf("%w: zabbix %s: %s", ErrNotify, response.Response, response.Info)
+	}
+	var processed, failed, total int
+	if _, err := fmt.Sscanf(response.Info, "processed: %d; failed: %d; total: %d",
+		&processed, &failed, &total); err == nil && failed > 0 {
+		return response.Info, fmt.Errorf("%w: zabbix failed %d of %d items: %s", ErrNotify, failed, total, response.Info)
+	}
+	return response.Info, nil
+}
+
+// WriteZabbixItems writes items in the input file format of zabbix_sender
+// (`zabbix_sender -i -`), one "<host> <key> <value>" line each.
+func WriteZabbixItems(w io.Writer, items []ZabbixItem) error {
+	for _, item := range items {
+		if _, err := fmt.Fprintf(w, "%s %s %s\n",
+			zabbixQuote(item.Host), zabbixQuote(item.Key), zabbixQuote(item.Value)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// zabbixQuote quotes s for the zabbix_sender input format when it holds
+// spaces or quotes.
+func zabbixQuote(s string) string {
+	if s != "" && !strings.ContainsAny(s, " \t\"\\") {
+		return s
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
@@ -0,0 +1,100 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"slices"
+	"testing"
+)
+
+// zabbixServer accepts one sender connection, decodes its frame into the
+// request it returns, and answers with info.
+func zabbixServer(t *testing.T, info string) (string, <-chan zabbixRequest) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	requests := make(chan zabbixRequest, 1)
+	go func() {
+		defer close(requests)
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		header := make([]byte, len(zabbixHeader)+8)
+		if _, err := io.ReadFull(conn, header); err != nil {
+			t.Error(err)
+			return
+		}
+		if !bytes.HasPrefix(header, zabbixHeader) {
+			t.Errorf("header = %q", header)
+			return
+		}
+		payload := make([]byte, binary.LittleEndian.Uint64(header[len(zabbixHeader):]))
+		if _, err := io.ReadFull(conn, payload); err != nil {
+			t.Error(err)
+			return
+		}
+		var request zabbixRequest
+		if err := json.Unmarshal(payload, &request); err != nil {
+			t.Error(err)
+		}
+		requests <- request
+
+		reply, _ := json.Marshal(map[string]string{"response": "success", "info": info})
+		var msg bytes.Buffer
+		msg.Write(zabbixHeader)
+		_ = binary.Write(&msg, binary.LittleEndian, uint64(len(reply)))
+		msg.Write(reply)
+		_, _ = conn.Write(msg.Bytes())
+	}()
+	return listener.Addr().String(), requests
+}
+
+type zabbixRequest struct {
+	Request string       `json:"request"`
+	Data    []ZabbixItem `json:"data"`
+}
+
+func TestZabbixSend(t *testing.T) {
+	items := []ZabbixItem{
+		{Host: "db1", Key: "bacli.state[postgres/app]", Value: "0"},
+		{Host: "db1", Key: "bacli.age[postgres/app]", Value: "3600"},
+	}
+	tests := []struct {
+		name    string
+		info    string
+		wantErr bool
+	}{
+		{"processed", "processed: 2; failed: 0; total: 2; seconds spent: 0.000055", false},
+		{"failed items", "processed: 1; failed: 1; total: 2; seconds spent: 0.000061", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			address, requests := zabbixServer(t, tt.info)
+			sender, err := NewZabbixSender(address)
+			if err != nil {
+				t.Fatal(err)
+			}
+			info, err := sender.Send(context.Background(), items)
+			if (err != nil) != tt.wantErr || (err != nil && !errors.Is(err, ErrNotify)) {
+				t.Errorf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if info != tt.info {
+				t.Errorf("info = %q, want %q", info, tt.info)
+			}
+			request := <-requests
+			if request.Request != "sender data" || !slices.Equal(request.Data, items) {
+				t.Errorf("request = %+v", request)
+			}
+		})
+	}
+}
@@ -0,0 +1,267 @@
+package operations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/kebairia/backup/internal/config"
+	"github.com/kebairia/backup/internal/storage"
+)
+
+// ErrAudit indicates that an audit found problems it did not repair.
+var ErrAudit = errors.New("audit found problems")
+
+// Kinds of AuditFinding.
+const (
+	AuditMissing          = "missing"           // referenced by the catalog, not stored
+	AuditSizeMismatch     = "size_mismatch"     // stored with another size than recorded
+	AuditChecksumMismatch = "checksum_mismatch" // stored with another checksum than recorded
+	AuditOrphan           = "orphan"            // stored without a catalog entry
+)
+
+// AuditOptions controls an audit.
+type AuditOptions struct {
+	ReadData bool // download remote copies to verify their checksum
+	Repair   bool // re-upload intact local copies over missing or damaged remote ones
+}
+
+// AuditFinding is a problem found by Audit.
+type AuditFinding struct {
+	Kind     string `json:"kind"`
+	Location string `json:"location"` // LocationLocal or LocationRemote
+	Engine   string `json:"engine,omitempty"`
+	Database string `json:"database,omitempty"`
+	Path     string `json:"path"` // local path, or storage key of remote copies
+	Detail   string `json:"detail,omitempty"`
+	Repaired bool   `json:"repaired,omitempty"`
+}
+
+// AuditResult describes an audit.
+type AuditResult struct {
+	Records  int            `json:"records"` // restorable catalog entries checked
+	Objects  int            `json:"objects"` // objects listed in storage
+	Findings []AuditFinding `json:"findings"`
+}
+
+// Audit cross-checks the catalog against the local backup directory and the
+// storage backend. It reports restorable backups with no copy left,
+// artifacts whose size or checksum differs from the catalog, and artifacts
+// in storage that no catalog entry references. With Repair, remote copies
+// found missing or damaged are uploaded again from intact local copies.
+//
+// Orphans are only reported: `bacli prune` decides what may be deleted.
+// Repository snapshots are checked by `bacli repo check`.
+func Audit(ctx context.Context, configPath string, opts AuditOptions) (AuditResult, error) {
+	operator, err := NewOperator(ctx, configPath)
+	if err != nil {
+		return AuditResult{Findings: []AuditFinding{}}, err
+	}
+	return operator.audit(opts)
+}
+
+// audit runs Audit with the operator's configuration.
+func (operator *Operator) audit(opts AuditOptions) (AuditResult, error) {
+	result := AuditResult{Findings: []AuditFinding{}}
+	var objects []storage.Object
+	var err error
+	if operator.storage != nil {
+		if objects, err = operator.storage.List(operator.ctx, ""); err != nil {
+			return result, fmt.Errorf("list storage: %w", err)
+		}
+		result.Objects = len(objects)
+	}
+
+	referenced := make(map[string]bool)
+	err = walkDatabases(operator.config.Backup.Directory, func(engine, db, dbDir string) error {
+		history, err := LoadHistory(dbDir)
+		if err != nil {
+			return err
+		}
+		for _, record := range history {
+			if record.RemotePath != "" {
+				referenced[record.RemotePath] = true
+				if record.Signature != "" {
+					if key, err := operator.storageKey(record.Signature); err == nil {
+						referenced[key] = true
+					}
+				}
+			}
+			if !record.Restorable() || record.Snapshot != "" {
+				continue
+			}
+			if err := operator.ctx.Err(); err != nil {
+				return err
+			}
+			result.Records++
+			for _, finding := range operator.auditRecord(dbDir, record, objects, opts) {
+				finding.Engine, finding.Database = engine, db
+				result.Findings = append(result.Findings, finding)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return result, err
+	}
+	result.Findings = append(result.Findings, orphanObjects(objects, referenced)...)
+
+	for _, finding := range result.Findings {
+		if !finding.Repaired {
+			return result, fmt.Errorf("%w: %d findings", ErrAudit, len(result.Findings))
+		}
+	}
+	return result, nil
+}
+
+// auditRecord checks the local and remote copies of a restorable record.
+func (operator *Operator) auditRecord(
+	dbDir string,
+	record Metadata,
+	objects []storage.Object,
+	opts AuditOptions,
+) []AuditFinding {
+	var findings []AuditFinding
+	// the local copy is intact when present and matching the catalog
+	local := false
+	if info, err := os.Stat(record.FilePath); err == nil {
+		local = true
+		if finding := checkCopy(record, info.Size(), record.FilePath, info.IsDir()); finding != nil {
+			finding.Location, finding.Path = LocationLocal, record.FilePath
+			findings = append(findings, *finding)
+			local = false
+		}
+	} else if record.RemotePath == "" {
+		findings = append(findings, AuditFinding{
+			Kind:     AuditMissing,
+			Location: LocationLocal,
+			Path:     record.FilePath,
+			Detail:   "no local or remote copy",
+		})
+	}
+	if record.RemotePath == "" || operator.storage == nil {
+		return findings
+	}
+
+	finding := operator.auditRemote(dbDir, record, artifactObjects(objects, record.RemotePath), opts)
+	if finding == nil {
+		return findings
+	}
+	finding.Location, finding.Path = LocationRemote, record.RemotePath
+	if opts.Repair && local {
+		ctx := withRunID(operator.ctx, record.RunID)
+		if err := uploadTo(ctx, operator.storage, record.FilePath, record.RemotePath, record.Labels); err != nil {
+			finding.Detail += "; repair failed: " + err.Error()
+		} else {
+			finding.Repaired = true
+			operator.log.Info("remote copy repaired",
+				"database", record.Database,
+				"engine", record.Engine,
+				"key", record.RemotePath,
+			)
+		}
+	}
+	return append(findings, *finding)
+}
+
+// auditRemote checks the stored objects of the remote copy of record, and
+// with ReadData downloads a file artifact to compare its checksum.
+func (operator *Operator) auditRemote(
+	dbDir string,
+	record Metadata,
+	stored []storage.Object,
+	opts AuditOptions,
+) *AuditFinding {
+	if len(stored) == 0 {
+		return &AuditFinding{Kind: AuditMissing, Detail: "not in storage"}
+	}
+	var size int64
+	for _, object := range stored {
+		size += object.Size
+	}
+	if record.SizeBytes > 0 && size != record.SizeBytes {
+		return &AuditFinding{
+			Kind:   AuditSizeMismatch,
+			Detail: fmt.Sprintf("size %d, recorded %d", size, record.SizeBytes),
+		}
+	}
+	if !opts.ReadData || record.Checksum == "" || len(stored) != 1 || stored[0].Key != record.RemotePath {
+		return nil
+	}
+	scratch, err := os.MkdirTemp(dbDir, ".audit-*")
+	if err != nil {
+		return &AuditFinding{Kind: AuditChecksumMismatch, Detail: err.Error()}
+	}
+	defer os.RemoveAll(scratch)
+	downloaded := filepath.Join(scratch, path.Base(record.RemotePath))
+	if err := operator.storage.Download(operator.ctx, record.RemotePath, downloaded); err != nil {
+		return &AuditFinding{Kind: AuditChecksumMismatch, Detail: "download: " + err.Error()}
+	}
+	return checkCopy(record, size, downloaded, false)
+}
+
+// checkCopy compares a copy of the artifact of record, of the given size, at
+// path with the catalog. Directory artifacts have no checksum.
+func checkCopy(record Metadata, size int64, path string, dir bool) *AuditFinding {
+	if dir {
+		return nil
+	}
+	if record.SizeBytes > 0 && size != record.SizeBytes {
+		return &AuditFinding{
+			Kind:   AuditSizeMismatch,
+			Detail: fmt.Sprintf("size %d, recorded %d", size, record.SizeBytes),
+		}
+	}
+	if record.Checksum == "" {
+		return nil
+	}
+	checksum, err := fileChecksum(path)
+	if err != nil {
+		return &AuditFinding{Kind: AuditChecksumMismatch, Detail: err.Error()}
+	}
+	if checksum != record.Checksum {
+		return &AuditFinding{
+			Kind:   AuditChecksumMismatch,
+			Detail: fmt.Sprintf("%s, recorded %s", checksum, record.Checksum),
+		}
+	}
+	return nil
+}
+
+// orphanObjects returns the artifacts among objects, stored as
+// <engine>/<database>/<artifact>, that no catalog entry references. Catalog
+// files, run manifests and tenants' objects are not artifacts.
+func orphanObjects(objects []storage.Object, referenced map[string]bool) []AuditFinding {
+	var findings []AuditFinding
+	for _, object := range objects {
+		parts := strings.Split(object.Key, "/")
+		if len(parts) < 3 || parts[0] == RunsDirname || parts[0] == config.TenantsDirname ||
+			isCatalogFile(parts[len(parts)-1]) || referencedKey(referenced, object.Key) {
+			continue
+		}
+		findings = append(findings, AuditFinding{
+			Kind:     AuditOrphan,
+			Location: LocationRemote,
+			Engine:   parts[0],
+			Database: parts[1],
+			Path:     object.Key,
+			Detail:   fmt.Sprintf("%d bytes without catalog entry", object.Size),
+		})
+	}
+	return findings
+}
+
+// referencedKey reports whether key, or a directory artifact holding it, is
+// referenced.
+func referencedKey(referenced map[string]bool, key string) bool {
+	for ; key != "." && key != "/"; key = path.Dir(key) {
+		if referenced[key] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,59 @@
+package operations
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kebairia/backup/internal/storage"
+)
+
+func TestOrphanObjects(t *testing.T) {
+	referenced := map[string]bool{
+		"postgres/app/1-app.dump.zst": true,
+		"postgres/app/2-app.dir":      true, // directory artifact
+	}
+	objects := []storage.Object{
+		{Key: "postgres/app/1-app.dump.zst"},
+		{Key: "postgres/app/2-app.dir/toc.dat"},
+		{Key: "postgres/app/metadata.json"},
+		{Key: "runs/20250424T210000Z-3fa2c1.json"},
+		{Key: "tenants/acme/postgres/app/1-app.dump.zst"},
+		{Key: "postgres/app/0-app.dump.zst"},
+		{Key: "mongodb/ghost/1-ghost.archive"},
+	}
+	findings := orphanObjects(objects, referenced)
+	want := []string{"postgres/app/0-app.dump.zst", "mongodb/ghost/1-ghost.archive"}
+	if len(findings) != len(want) {
+		t.Fatalf("got %d orphans, want %d: %+v", len(findings), len(want), findings)
+	}
+	for i, f := range findings {
+		if f.Path != want[i] || f.Kind != AuditOrphan {
+			t.Errorf("finding %d = %s %s, want orphan %s", i, f.Kind, f.Path, want[i])
+		}
+	}
+}
+
+func TestCheckCopy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "1-app.dump.zst")
+	if err := os.WriteFile(path, []byte("artifact"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	checksum, err := fileChecksum(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	record := Metadata{FilePath: path, SizeBytes: 8, Checksum: checksum}
+	if f := checkCopy(record, 8, path, false); f != nil {
+		t.Errorf("intact copy: got %+v", f)
+	}
+	if f := checkCopy(record, 7, path, false); f == nil || f.Kind != AuditSizeMismatch {
+		t.Errorf("truncated copy: got %+v, want size mismatch", f)
+	}
+	if err := os.WriteFile(path, []byte("artefact"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if f := checkCopy(record, 8, path, false); f == nil || f.Kind != AuditChecksumMismatch {
+		t.Errorf("damaged copy: got %+v, want checksum mismatch", f)
+	}
+}
@@ -1,62 +1,494 @@
 package operations
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"maps"
+	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/kebairia/backup/internal/config"
 	"github.com/kebairia/backup/internal/database"
-	"github.com/kebairia/backup/internal/logger"
+	"github.com/kebairia/backup/internal/encryption"
+	"github.com/kebairia/backup/internal/events"
+	"github.com/kebairia/backup/internal/notify"
+	"github.com/kebairia/backup/internal/progress"
+	"github.com/kebairia/backup/internal/state"
+	"github.com/kebairia/backup/internal/telemetry"
+	"go.opentelemetry.io/otel/attribute"
 )
 
-func (operator *Operator) BackupDatabase(db database.Database) error {
+// ErrSkipped indicates a backup was skipped because another run holds the
+// database lock.
+var ErrSkipped = errors.New("backup skipped")
+
+// ErrNoDatabase indicates that no configured database matches a selection.
+var ErrNoDatabase = errors.New("no matching database")
+
+// BackupDatabase runs a single backup against one Database and returns
+// the metadata record describing the outcome.
+// The database is locked in the state store for the duration of the run, and
+// the outcome is recorded there as its last run. An in-progress marker is
+// kept next to the metadata until the run is finalized; a marker left by a
+// killed run is recovered first (see recoverInterrupted). A nil record with
+// ErrSkipped is returned when another run holds the lock; any other failure
+// comes with a failed record.
+func (operator *Operator) BackupDatabase(db database.Database) (record *Metadata, err error) {
+	ctx, span := telemetry.Start(operator.ctx, "backup.database",
+		attribute.String("db.system", db.GetEngine()),
+		attribute.String("db.name", db.GetName()),
+	)
+	defer func() { telemetry.End(span, err) }()
+
+	start := time.Now()
+	unlock, err := operator.lockDatabase(db)
+	if err != nil {
+		if errors.Is(err, state.ErrLocked) {
+			return nil, fmt.Errorf("%w: %w", ErrSkipped, err)
+		}
+		err = fmt.Errorf("lock %q: %w", db.GetName(), err)
+		return operator.failedRecord(db, start, err), err
+	}
+	defer unlock()
+
+	metadataDir := db.GetPath()
+	interrupted, err := operator.recoverInterrupted(metadataDir)
+	if err != nil {
+		operator.log.Warn("failed to recover interrupted run",
+			"database", db.GetName(),
+			"error", err.Error(),
+		)
+	}
+	if interrupted != nil {
+		operator.recordInterrupted(interrupted)
+	}
+	done, err := operator.markInProgress(db, metadataDir)
+	if err != nil {
+		return operator.failedRecord(db, start, err), err
+	}
+	defer done()
+
+	record, err = operator.backupDatabase(ctx, db)
+	if err != nil && ctx.Err() != nil {
+		err = fmt.Errorf("%w: %w", ErrCancelled, err)
+	}
+	operator.recordRun(db, record)
+	operator.emit(events.BackupCompleted, db.GetEngine(), db.GetName(), map[string]any{
+		"status":      record.Status,
+		"file_path":   record.FilePath,
+		"remote_path": record.RemotePath,
+		"size_bytes":  record.SizeBytes,
+		"duration_ms": record.Duration.Milliseconds(),
+		"error":       record.Error,
+	})
+	if err == nil && record.Status == StatusSuccess {
+		operator.notifyArtifact(db, record)
+	}
+	span.SetAttributes(attribute.Int64("backup.size_bytes", record.SizeBytes))
+	return record, err
+}
+
+// failedRecord writes and returns the record of a backup of db that failed
+// with err before anything was dumped.
+func (operator *Operator) failedRecord(db database.Database, start time.Time, err error) *Metadata {
+	record := NewMetadata(db, start, time.Now(), "", err)
+	record.RunID = operator.runID
+	record.FilePath = "N/A"
+	record.Labels = operator.labelsFor(db)
+	_ = record.Write(db.GetPath())
+	return record
+}
+
+func (operator *Operator) backupDatabase(ctx context.Context, db database.Database) (*Metadata, error) {
+	metadataDir := db.GetPath()
+	labels := operator.labelsFor(db)
+	system := NewSystemInfo(operator.config.Backup.Directory)
+	// dump step: preflight, dump and check
+	operator.pipeline.dump.enter(db.GetEngine())
 	start := time.Now()
-	backupPath, err := db.Backup()
+	operator.emit(events.BackupStarted, db.GetEngine(), db.GetName(), nil)
+	estimate, err := operator.preflight(ctx, db, operator.workDir(metadataDir))
+	if err == nil {
+		err = operator.checkQuota(db, metadataDir, estimate)
+	}
+	if err != nil {
+		operator.pipeline.dump.leave()
+		record := NewMetadata(db, start, time.Now(), "", err)
+		record.RunID = operator.runID
+		record.FilePath = "N/A"
+		record.Labels = labels
+		record.EstimatedBytes = estimate
+		record.System = system
+		_ = record.Write(metadataDir)
+		return record, fmt.Errorf("preflight failed for %q: %w", db.GetName(), err)
+	}
+	dumpCtx, dumpSpan := telemetry.Start(ctx, "dump")
+	stderr := operator.captureStderr(db, operator.stderrLogPath(metadataDir, start))
+	stopProgress := dumpProgress(db, estimate)
+	backupPath, base, err := operator.dump(dumpCtx, db, metadataDir)
+	stopProgress()
+	err = stderr.finish(err)
+	telemetry.End(dumpSpan, err)
 	complete := time.Now()
+	system.Finish(operator.config.Backup.Directory)
 	record := NewMetadata(db, start, complete, backupPath, err)
+	record.RunID = operator.runID
+	record.StderrLog = stderr.path
+	record.System = system
+	record.Labels = labels
+	record.EstimatedBytes = estimate
+	record.Base = base
 	if err != nil {
+		operator.pipeline.dump.leave()
 		// still write failed metadata
+		operator.cleanupPartial(record, backupPath)
 		record.FilePath = "N/A"
-		_ = record.Write(filepath.Dir(backupPath))
-		return fmt.Errorf("backup failed for %q: %w", db.GetName(), err)
+		_ = record.Write(metadataDir)
+		return record, fmt.Errorf("backup failed for %q: %w", db.GetName(), err)
+	}
+	operator.recordVersions(db, record)
+
+	// Read the fresh artifact back with the engine's own tooling
+	err = operator.checkBackup(ctx, db, record, backupPath)
+	operator.pipeline.dump.leave()
+	if err != nil {
+		record.Status = StatusFailed
+		record.Error = err.Error()
+		_ = record.Write(metadataDir)
+		return record, fmt.Errorf("check backup file: %w", err)
 	}
 
-	// Compress the backup file if needed
-	if operator.config.Backup.Compression {
-		comPath, err := CompressZstd(backupPath)
+	// Store file artifacts in the chunk repository, as dumped, so that
+	// data unchanged since the last backup deduplicates
+	if operator.repoBackend != nil && !isDir(backupPath) {
+		operator.pipeline.upload.enter()
+		err = operator.saveToRepository(ctx, record, backupPath)
+		operator.pipeline.upload.leave()
 		if err != nil {
-			return fmt.Errorf("compress backup file: %w", err)
+			record.Status = StatusFailed
+			record.Error = err.Error()
+			_ = record.Write(metadataDir)
+			return record, fmt.Errorf("store backup file in repository: %w", err)
+		}
+	}
+
+	// compress step: compression and encryption
+	operator.pipeline.compress.enter()
+	record, err = operator.sealArtifact(ctx, db, record, metadataDir, backupPath)
+	operator.pipeline.compress.leave()
+	if err != nil {
+		return record, err
+	}
+
+	// Move the finished artifact out of backup.work_dir
+	if err := operator.publishArtifact(record, metadataDir); err != nil {
+		record.Status = StatusFailed
+		record.Error = err.Error()
+		operator.cleanupPartial(record, record.FilePath)
+		record.FilePath = "N/A"
+		_ = record.Write(metadataDir)
+		return record, fmt.Errorf("publish backup file: %w", err)
+	}
+
+	// upload step: artifact and metadata
+	operator.pipeline.upload.enter()
+	defer operator.pipeline.upload.leave()
+
+	// Copy the artifact to remote storage, unless the repository holds it
+	if operator.storage != nil && record.Snapshot == "" {
+		bar := progress.New("upload "+db.GetName(), record.SizeBytes)
+		remotePath, err := operator.upload(progress.WithBar(ctx, bar), record.FilePath, labels)
+		bar.Finish()
+		if err != nil {
+			record.Status = StatusFailed
+			record.Error = err.Error()
+			_ = record.Write(metadataDir)
+			return record, fmt.Errorf("upload backup file: %w", err)
+		}
+		record.RemotePath = remotePath
+		operator.replicate(ctx, record, record.FilePath, operator.replicas)
+		if record.Signature != "" {
+			if _, err := operator.upload(ctx, record.Signature, labels); err != nil {
+				record.Status = StatusFailed
+				record.Error = err.Error()
+				_ = record.Write(metadataDir)
+				return record, fmt.Errorf("upload signature: %w", err)
+			}
+			operator.replicateFile(ctx, record.Signature, operator.replicas)
 		}
-		record.FilePath = comPath
 	}
 
 	// Write metadata
-	record.Write(filepath.Dir(backupPath))
-	return nil
+	record.Write(metadataDir)
+	if operator.storage != nil {
+		metadataPath := filepath.Join(metadataDir, MetadataFilename)
+		if _, err := operator.upload(ctx, metadataPath, nil); err != nil {
+			return record, fmt.Errorf("upload metadata: %w", err)
+		}
+		operator.replicateFile(ctx, metadataPath, operator.replicas)
+	}
+	return record, nil
+}
+
+// sealArtifact compresses and encrypts the artifact of record as configured
+// for db and records its final path and size. Only the zstd compression mode
+// is applied here: native compression happened in the dump, and directory
+// artifacts (e.g. parallel pg_dump output) are kept as is.
+func (operator *Operator) sealArtifact(
+	ctx context.Context,
+	db database.Database,
+	record *Metadata,
+	metadataDir, backupPath string,
+) (*Metadata, error) {
+	if operator.compression(db) == config.CompressionZstd && !isDir(backupPath) {
+		_, compressSpan := telemetry.Start(ctx, "compress")
+		bar := progress.New("compress "+record.Database, artifactSize(backupPath))
+		comPath, err := compressZstd(backupPath, bar)
+		bar.Finish()
+		telemetry.End(compressSpan, err)
+		if err != nil {
+			record.Status = StatusFailed
+			record.Error = err.Error()
+			operator.cleanupPartial(record, backupPath)
+			record.FilePath = "N/A"
+			_ = record.Write(metadataDir)
+			return record, fmt.Errorf("compress backup file: %w", err)
+		}
+		record.FilePath = comPath
+	}
+
+	// Encrypt the artifact with a fresh data key wrapped by the configured KMS
+	if operator.encryption != nil {
+		_, encryptSpan := telemetry.Start(ctx, "encrypt")
+		encPath, err := operator.encryptArtifact(ctx, record.FilePath)
+		telemetry.End(encryptSpan, err)
+		if err != nil {
+			record.Status = StatusFailed
+			record.Error = err.Error()
+			operator.cleanupPartial(record, record.FilePath)
+			record.FilePath = "N/A"
+			_ = record.Write(metadataDir)
+			return record, fmt.Errorf("encrypt backup file: %w", err)
+		}
+		record.FilePath = encPath
+		record.Encryption = operator.encryptionKey()
+	}
+	record.SizeBytes = artifactSize(record.FilePath)
+	if !isDir(record.FilePath) {
+		checksum, err := fileChecksum(record.FilePath)
+		if err != nil {
+			record.Status = StatusFailed
+			record.Error = err.Error()
+			_ = record.Write(metadataDir)
+			return record, fmt.Errorf("checksum backup file: %w", err)
+		}
+		record.Checksum = checksum
+		if err := operator.signArtifact(ctx, record); err != nil {
+			record.Status = StatusFailed
+			record.Error = err.Error()
+			_ = record.Write(metadataDir)
+			return record, fmt.Errorf("sign backup file: %w", err)
+		}
+	}
+	return record, nil
+}
+
+// writeRunManifest records the run in <backup.directory>/runs and copies the
+// manifest to remote storage. Failures are logged: the per-database
+// metadata already describes every backup.
+func (operator *Operator) writeRunManifest(start time.Time, records []*Metadata) {
+	manifest := newRunManifest(operator.runID, operator.config, operator.labels, start, records)
+	manifest.Cancelled = operator.ctx.Err() != nil
+	path, err := manifest.Write(operator.config.Backup.Directory)
+	if err != nil {
+		operator.log.Warn("failed to write run manifest", "run", manifest.ID, "error", err.Error())
+		return
+	}
+	if operator.storage == nil || manifest.Cancelled {
+		return
+	}
+	if _, err := operator.upload(operator.ctx, path, nil); err != nil {
+		operator.log.Warn("failed to upload run manifest", "run", manifest.ID, "error", err.Error())
+	}
+	operator.replicateFile(operator.ctx, path, operator.replicas)
 }
 
-// BackupAll runs backups for all configured databases in parallel.
-func BackupAll(configPath string) error {
-	log := logger.Global()
-	operator, err := NewOperator(configPath)
+// labelsFor returns the labels of db with the run's labels applied over them.
+func (operator *Operator) labelsFor(db database.Database) map[string]string {
+	var labels map[string]string
+	if labeler, ok := db.(database.Labeler); ok && len(labeler.GetLabels()) > 0 {
+		labels = maps.Clone(labeler.GetLabels())
+	}
+	if len(operator.labels) > 0 {
+		if labels == nil {
+			labels = make(map[string]string, len(operator.labels))
+		}
+		maps.Copy(labels, operator.labels)
+	}
+	return labels
+}
+
+// dump backs up db. With --incremental, engines that support it back up
+// the changes made since the latest restorable backup in metadataDir, whose
+// start is returned as the base of the new backup; the first backup of a
+// database is a full one.
+func (operator *Operator) dump(ctx context.Context, db database.Database, metadataDir string) (string, time.Time, error) {
+	incrementer, ok := db.(database.Incrementer)
+	if !operator.incremental || !ok || !incrementer.IncrementalSupported() {
+		path, err := db.Backup(ctx)
+		return path, time.Time{}, err
+	}
+	base, err := LoadLatestRestorable(metadataDir)
 	if err != nil {
+		operator.log.Info("no backup to apply an incremental backup on, taking a full backup",
+			"database", db.GetName(),
+			"engine", db.GetEngine(),
+		)
+		path, err := db.Backup(ctx)
+		return path, time.Time{}, err
+	}
+	path, err := incrementer.BackupIncremental(ctx, base.StartedAt)
+	return path, base.StartedAt, err
+}
+
+// checkBackup runs the engine's post-backup check, if any, and records the
+// result in the metadata record. The artifact is kept when the check fails
+// so it can be inspected.
+func (operator *Operator) checkBackup(
+	ctx context.Context,
+	db database.Database,
+	record *Metadata,
+	backupPath string,
+) (err error) {
+	checker, ok := db.(database.BackupChecker)
+	if !ok {
+		return nil
+	}
+	_, span := telemetry.Start(ctx, "check")
+	defer func() { telemetry.End(span, err) }()
+
+	start := time.Now()
+	check, err := checker.CheckBackup(backupPath)
+	if check == "" {
+		return err
+	}
+	record.Check = &ArtifactCheck{
+		Check:     check,
+		Status:    StatusSuccess,
+		CheckedAt: start,
+		Duration:  time.Since(start),
+	}
+	if err != nil {
+		record.Check.Status = StatusFailed
+		record.Check.Error = err.Error()
 		return err
 	}
+	operator.log.Info("backup checked",
+		"database", db.GetName(),
+		"engine", db.GetEngine(),
+		"check", check,
+		"duration", record.Check.Duration.String(),
+	)
+	return nil
+}
+
+// cleanupPartial removes the artifact of a failed backup, including any
+// partial compressed or encrypted copy, unless --keep-partial was requested.
+// Removed paths are recorded in the metadata record.
+func (operator *Operator) cleanupPartial(record *Metadata, backupPath string) {
+	if backupPath == "" || operator.keepPartial {
+		return
+	}
+	for _, path := range []string{backupPath, backupPath + ".zst", backupPath + encryption.Suffix} {
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		if err := os.RemoveAll(path); err != nil {
+			operator.log.Warn("failed to remove partial artifact",
+				"path", path,
+				"error", err.Error(),
+			)
+			continue
+		}
+		record.CleanedUp = append(record.CleanedUp, path)
+		operator.log.Info("partial artifact removed",
+			"database", record.Database,
+			"engine", record.Engine,
+			"path", path,
+		)
+	}
+}
+
+// BackupOptions controls a backup run.
+type BackupOptions struct {
+	Engine       string            // back up only databases of this engine (empty backs up all)
+	Instance     string            // back up only this instance, "name" or "engine:name" (see matchDatabase)
+	Database     string            // back up only this database (empty backs up all)
+	KeepPartial  bool              // keep artifacts of failed backups for debugging
+	IgnoreWindow bool              // back up outside backup windows and on blackout dates
+	Labels       map[string]string // added to every backup, overriding instance labels
+	Incremental  bool              // back up the changes since the latest backup where the engine supports it
+}
+
+// BackupAll runs backups for all configured databases in parallel and
+// returns the run report sent to notifiers.
+func BackupAll(ctx context.Context, configPath string, opts BackupOptions) (notify.Report, error) {
+	operator, err := NewOperator(ctx, configPath)
+	if err != nil {
+		return notify.Report{}, err
+	}
+	operator.keepPartial = opts.KeepPartial
+	operator.ignoreWindow = opts.IgnoreWindow
+	operator.labels = opts.Labels
+	operator.incremental = opts.Incremental
+
+	ctx, span := telemetry.Start(operator.ctx, "backup.run")
+	defer span.End()
+	operator.ctx = ctx
+
 	// 1) Initialize DB instances
 	databases, err := database.InitializeDatabases(
 		operator.ctx,
-		operator.config,
+		targetConfig(operator.config, opts.Engine, opts.Instance),
 		operator.vaultClient,
 	)
 	if err != nil {
-		return fmt.Errorf("initialize databases: %w", err)
+		return notify.Report{}, fmt.Errorf("initialize databases: %w", err)
+	}
+	if opts.Engine != "" || opts.Instance != "" || opts.Database != "" {
+		databases = slices.DeleteFunc(databases, func(db database.Database) bool {
+			return !matchDatabase(db, opts.Engine, opts.Instance, opts.Database)
+		})
+		if len(databases) == 0 {
+			return notify.Report{}, fmt.Errorf("%w: engine %q, instance %q and name %q",
+				ErrNoDatabase, opts.Engine, opts.Instance, opts.Database)
+		}
 	}
 
+	span.SetAttributes(attribute.Int("backup.databases", len(databases)))
+	span.SetAttributes(attribute.String("backup.run_id", operator.runID))
+	return operator.backupAll(databases)
+}
+
+// backupAll backs up databases in parallel, notifies about the run and
+// returns its report, with ErrRunFailed when any of the backups failed.
+func (operator *Operator) backupAll(databases []database.Database) (notify.Report, error) {
+	log := operator.log
 	var (
-		wg   sync.WaitGroup
-		errs = make(chan error, len(databases)) // buffered to avoid deadlock
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		errs    = make(chan error, len(databases)) // buffered to avoid deadlock
+		records []*Metadata
 	)
+	report := notify.Report{Operation: "backup", RunID: operator.runID, StartedAt: time.Now()}
+	operator.notifyStart(report.Operation)
+	operator.emitRun(events.RunStarted, report, len(databases))
+	operator.leases.hold(databases)
 
 	for _, db := range databases {
 
@@ -65,8 +497,31 @@ func BackupAll(configPath string) error {
 		go func(db database.Database) {
 			// mark this goroutine  as DONE (finished) once this function finish(exit)
 			defer wg.Done()
+			defer operator.releaseLease(db)
 
-			err := operator.BackupDatabase(db)
+			err := operator.awaitWindow(db)
+			if errors.Is(err, ErrCancelled) {
+				return
+			}
+			var record *Metadata
+			if err == nil {
+				record, err = operator.BackupDatabase(db)
+			} else if !errors.Is(err, ErrSkipped) {
+				record = operator.failedRecord(db, time.Now(), err)
+			}
+			if errors.Is(err, ErrSkipped) {
+				log.Info("backup skipped",
+					"database", db.GetName(),
+					"reason", err.Error(),
+				)
+				return
+			}
+			if record != nil {
+				mu.Lock()
+				report.Results = append(report.Results, newResult(record))
+				records = append(records, record)
+				mu.Unlock()
+			}
 			// in case of error, add this error to the error channel
 			if err != nil {
 				log.Error("backup failed",
@@ -81,10 +536,148 @@ func BackupAll(configPath string) error {
 	wg.Wait()
 	close(errs)
 
-	// Check for errors
-	// for err := range errs {
-	// 	return err // return first error
-	// }
+	report.CompletedAt = time.Now()
+	operator.emitRun(events.RunCompleted, report, len(databases))
+	operator.writeRunManifest(report.StartedAt, records)
+	operator.notify(report)
+	operator.notifyInterrupted()
+	if err := operator.cancelled(); err != nil {
+		return report, err
+	}
+	var failures []error
+	for err := range errs {
+		failures = append(failures, err)
+	}
+	if len(failures) > 0 {
+		return report, fmt.Errorf("%w: %w", ErrRunFailed, errors.Join(failures...))
+	}
+	return report, nil
+}
 
-	return nil
+// matchDatabase reports whether db matches the selection; empty fields match
+// everything. instance is an instance name, optionally qualified with its
+// engine ("postgres:main") when several engines use the same name.
+func matchDatabase(db database.Database, engine, instance, name string) bool {
+	if engine != "" && db.GetEngine() != engine {
+		return false
+	}
+	if name != "" && db.GetName() != name {
+		return false
+	}
+	if instance == "" {
+		return true
+	}
+	if qualifier, rest, ok := strings.Cut(instance, ":"); ok {
+		if db.GetEngine() != qualifier {
+			return false
+		}
+		instance = rest
+	}
+	named, ok := db.(database.Instancer)
+	return ok && named.GetInstance() == instance
+}
+
+// targetConfig returns cfg keeping only the instances that databases
+// selected by engine and instance (see matchDatabase) can come from, so that
+// a one-off backup initializes them, and requests their credentials, without
+// the rest of the fleet. Groups where a selected instance covers every
+// database ("*") keep all their instances, as those claim databases out of
+// it.
+func targetConfig(cfg config.Config, engine, instance string) config.Config {
+	if engine == "" && instance == "" {
+		return cfg
+	}
+	qualifier, name, ok := strings.Cut(instance, ":")
+	if !ok {
+		qualifier, name = "", instance
+	}
+	groups := map[string]*config.DBGroupConfig{
+		"postgres":   &cfg.Postgres,
+		"mongodb":    &cfg.MongoDB,
+		"mysql":      &cfg.MySQL,
+		"redis":      &cfg.Redis,
+		"clickhouse": &cfg.ClickHouse,
+		"cassandra":  &cfg.Cassandra,
+		"influxdb":   &cfg.InfluxDB,
+		"couchdb":    &cfg.CouchDB,
+	}
+	for groupEngine, group := range groups {
+		if (engine != "" && groupEngine != engine) || (qualifier != "" && groupEngine != qualifier) {
+			group.Instances = nil
+			continue
+		}
+		if name == "" {
+			continue
+		}
+		var selected []config.DBInstance
+		wildcard := false
+		for _, candidate := range group.Instances {
+			if candidate.Name == name {
+				selected = append(selected, candidate)
+				wildcard = wildcard || candidate.Database == config.AllDatabases
+			}
+		}
+		if !wildcard {
+			group.Instances = selected
+		}
+	}
+	return cfg
+}
+
+// dumpInterval is how often the progress of a dump is sampled.
+const dumpInterval = time.Second
+
+// dumpProgress shows the progress of the dump of db, whose client tools
+// report none, as the growth of its database directory against the size
+// estimated by preflight. The returned func stops it.
+func dumpProgress(db database.Database, estimate int64) func() {
+	bar := progress.New("dump "+db.GetName(), estimate)
+	if bar == nil {
+		return func() {}
+	}
+	base, _ := dirSize(db.GetPath())
+	stop, done := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(dumpInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if size, err := dirSize(db.GetPath()); err == nil {
+					bar.Set(max(size-base, 0))
+				}
+			}
+		}
+	}()
+	return func() {
+		close(stop)
+		<-done
+		bar.Finish()
+	}
+}
+
+// recordVersions records the server version and the dump tool of db in
+// record. They are informational: failing to read them is only logged.
+func (operator *Operator) recordVersions(db database.Database, record *Metadata) {
+	if versioner, ok := db.(database.ServerVersioner); ok {
+		version, err := versioner.ServerVersion()
+		if err != nil {
+			operator.log.Warn("cannot read server version", "database", db.GetName(), "error", err)
+		} else {
+			record.ServerVersion = version
+		}
+	}
+	user, ok := db.(database.ToolUser)
+	if !ok || user.DumpTool() == "" {
+		return
+	}
+	tool, err := database.FindTool(db, user.DumpTool())
+	if err != nil {
+		operator.log.Warn("cannot read dump tool version", "database", db.GetName(), "tool", user.DumpTool(), "error", err)
+		return
+	}
+	record.DumpTool = &tool
 }
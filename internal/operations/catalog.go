@@ -0,0 +1,72 @@
+package operations
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+
+	"github.com/kebairia/backup/internal/config"
+)
+
+// CatalogEntry is a metadata record together with the file it was read from.
+type CatalogEntry struct {
+	Path   string
+	Record Metadata
+}
+
+// LoadCatalog walks the backup directory and returns every metadata record found.
+// The catalog is the set of metadata.json files written next to each artifact.
+func LoadCatalog(dir string) ([]CatalogEntry, error) {
+	var entries []CatalogEntry
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() && path == filepath.Join(dir, config.TenantsDirname) {
+			return filepath.SkipDir // each tenant has its own catalog
+		}
+		if d.IsDir() || d.Name() != MetadataFilename {
+			return nil
+		}
+		var record Metadata
+		if err := record.Load(path); err != nil {
+			return err
+		}
+		entries = append(entries, CatalogEntry{Path: path, Record: record})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("load catalog %q: %w", dir, err)
+	}
+	return entries, nil
+}
+
+// CatalogDatabases returns the sorted, unique database names found in the
+// catalog of the given configuration. It does not contact Vault, so it is
+// cheap enough for shell completion.
+func CatalogDatabases(configPath string) ([]string, error) {
+	var cfg config.Config
+	if err := cfg.Load(configPath); err != nil {
+		return nil, err
+	}
+	entries, err := LoadCatalog(cfg.Backup.Directory)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	var names []string
+	for _, entry := range entries {
+		if entry.Record.Database == "" || seen[entry.Record.Database] {
+			continue
+		}
+		seen[entry.Record.Database] = true
+		names = append(names, entry.Record.Database)
+	}
+	sort.Strings(names)
+	return names, nil
+}
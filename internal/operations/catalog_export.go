@@ -0,0 +1,463 @@
+package operations
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kebairia/backup/internal/config"
+	"github.com/kebairia/backup/internal/storage"
+)
+
+// CatalogExportKey is the storage key of the catalog exported to "remote".
+const CatalogExportKey = "catalog/catalog.json"
+
+// ErrNoStorage indicates that an operation needs the storage backend but
+// none is configured.
+var ErrNoStorage = errors.New("no storage backend configured")
+
+// CatalogExport is the whole catalog of a backup directory in one document:
+// the history of every database and the run manifests.
+type CatalogExport struct {
+	ExportedAt time.Time `json:"exported_at"`
+	Host       string    `json:"host,omitempty"`
+	// Directory is the backup directory the paths of the records are
+	// under; imports move them under the local backup directory.
+	Directory string            `json:"directory"`
+	Databases []CatalogDatabase `json:"databases"`
+	Runs      []RunManifest     `json:"runs,omitempty"`
+}
+
+// CatalogDatabase is the history of the database directory Dir, relative
+// to the backup directory and slash-separated.
+type CatalogDatabase struct {
+	Dir     string     `json:"dir"`
+	Records []Metadata `json:"records"`
+}
+
+// CatalogResult counts what a catalog export, import or rebuild covered.
+// Records and runs already in the local catalog are not counted by imports.
+type CatalogResult struct {
+	Databases int `json:"databases"`
+	Records   int `json:"records"`
+	Runs      int `json:"runs"`
+	// Skipped counts successful records whose artifact is in neither the
+	// storage backend nor the backup directory (rebuild only).
+	Skipped int `json:"skipped,omitempty"`
+}
+
+// ExportCatalog writes the catalog of the given configuration to dest: a
+// file, "-" for stdout, or "remote" for CatalogExportKey on the storage
+// backend, so that the catalog survives the loss of the backup host.
+func ExportCatalog(ctx context.Context, configPath, dest string) (CatalogResult, error) {
+	if dest != "remote" {
+		var cfg config.Config
+		if err := cfg.Load(configPath); err != nil {
+			return CatalogResult{}, err
+		}
+		export, result, err := exportCatalog(cfg.Backup.Directory)
+		if err != nil {
+			return result, err
+		}
+		return result, writeExport(dest, export)
+	}
+
+	operator, err := NewOperator(ctx, configPath)
+	if err != nil {
+		return CatalogResult{}, err
+	}
+	if operator.storage == nil {
+		return CatalogResult{}, ErrNoStorage
+	}
+	export, result, err := exportCatalog(operator.config.Backup.Directory)
+	if err != nil {
+		return result, err
+	}
+	scratch, err := os.MkdirTemp("", "bacli-catalog-*")
+	if err != nil {
+		return result, fmt.Errorf("create scratch directory: %w", err)
+	}
+	defer os.RemoveAll(scratch)
+	local := filepath.Join(scratch, path.Base(CatalogExportKey))
+	if err := writeExport(local, export); err != nil {
+		return result, err
+	}
+	if err := operator.storage.Upload(operator.ctx, local, CatalogExportKey); err != nil {
+		return result, fmt.Errorf("upload catalog: %w", err)
+	}
+	operator.log.Info("catalog exported",
+		"key", CatalogExportKey,
+		"backend", operator.storage.Name(),
+		"databases", result.Databases,
+		"records", result.Records,
+		"runs", result.Runs,
+	)
+	return result, nil
+}
+
+// exportCatalog collects the catalog of the backup directory dir.
+func exportCatalog(dir string) (CatalogExport, CatalogResult, error) {
+	host, _ := os.Hostname()
+	export := CatalogExport{ExportedAt: time.Now().UTC(), Host: host, Directory: dir}
+	var result CatalogResult
+
+	entries, err := LoadCatalog(dir)
+	if err != nil {
+		return export, result, err
+	}
+	for _, entry := range entries {
+		entryDir := filepath.Dir(entry.Path)
+		records, err := LoadHistory(entryDir)
+		if err != nil {
+			return export, result, err
+		}
+		rel, err := filepath.Rel(dir, entryDir)
+		if err != nil {
+			return export, result, fmt.Errorf("export %q: %w", entryDir, err)
+		}
+		export.Databases = append(export.Databases, CatalogDatabase{Dir: filepath.ToSlash(rel), Records: records})
+		result.Records += len(records)
+	}
+	result.Databases = len(export.Databases)
+
+	if export.Runs, err = LoadRuns(dir, 0); err != nil {
+		return export, result, err
+	}
+	result.Runs = len(export.Runs)
+	return export, result, nil
+}
+
+// writeExport writes export as JSON to the file dest, or to stdout for "-".
+func writeExport(dest string, export CatalogExport) error {
+	if dest == "-" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(export)
+	}
+	if err := WriteJSONAtomic(dest, export); err != nil {
+		return fmt.Errorf("write catalog export: %w", err)
+	}
+	return nil
+}
+
+// ImportCatalog merges a catalog written by ExportCatalog into the catalog
+// of the given configuration. source is a file, "-" for stdin, or "remote"
+// for the export on the storage backend. Paths are moved from the exported
+// backup directory to the local one; records and runs the local catalog
+// already holds are kept.
+func ImportCatalog(ctx context.Context, configPath, source string) (CatalogResult, error) {
+	var (
+		dir    string
+		reader io.Reader
+	)
+	switch source {
+	case "remote":
+		operator, err := NewOperator(ctx, configPath)
+		if err != nil {
+			return CatalogResult{}, err
+		}
+		if operator.storage == nil {
+			return CatalogResult{}, ErrNoStorage
+		}
+		scratch, err := os.MkdirTemp("", "bacli-catalog-*")
+		if err != nil {
+			return CatalogResult{}, fmt.Errorf("create scratch directory: %w", err)
+		}
+		defer os.RemoveAll(scratch)
+		local := filepath.Join(scratch, path.Base(CatalogExportKey))
+		if err := operator.storage.Download(operator.ctx, CatalogExportKey, local); err != nil {
+			return CatalogResult{}, fmt.Errorf("download catalog: %w", err)
+		}
+		file, err := os.Open(local)
+		if err != nil {
+			return CatalogResult{}, err
+		}
+		defer file.Close()
+		dir, reader = operator.config.Backup.Directory, file
+	default:
+		var cfg config.Config
+		if err := cfg.Load(configPath); err != nil {
+			return CatalogResult{}, err
+		}
+		reader = os.Stdin
+		if source != "-" {
+			file, err := os.Open(source)
+			if err != nil {
+				return CatalogResult{}, fmt.Errorf("open catalog export: %w", err)
+			}
+			defer file.Close()
+			reader = file
+		}
+		dir = cfg.Backup.Directory
+	}
+
+	var export CatalogExport
+	if err := json.NewDecoder(reader).Decode(&export); err != nil {
+		return CatalogResult{}, fmt.Errorf("decode catalog export: %w", err)
+	}
+	return importCatalog(dir, export)
+}
+
+// importCatalog merges export into the catalog of the backup directory dir.
+func importCatalog(dir string, export CatalogExport) (CatalogResult, error) {
+	var result CatalogResult
+	for _, db := range export.Databases {
+		if !filepath.IsLocal(filepath.FromSlash(db.Dir)) {
+			return result, fmt.Errorf("import catalog: directory %q is outside the backup directory", db.Dir)
+		}
+		records := make([]Metadata, len(db.Records))
+		for i, record := range db.Records {
+			records[i] = rebaseRecord(record, export.Directory, dir)
+		}
+		added, err := mergeHistory(filepath.Join(dir, filepath.FromSlash(db.Dir)), records)
+		result.Records += added
+		if err != nil {
+			return result, err
+		}
+		if added > 0 {
+			result.Databases++
+		}
+	}
+	for _, run := range export.Runs {
+		added, err := mergeRun(dir, rebaseRun(run, export.Directory, dir))
+		if err != nil {
+			return result, err
+		}
+		if added {
+			result.Runs++
+		}
+	}
+	return result, nil
+}
+
+// RebuildCatalog reconstructs the catalog of the given configuration from
+// the metadata stored next to the artifacts on the storage backend: the
+// last record of every database and the run manifests, which hold the
+// earlier runs. Records of successful runs whose artifact is gone are
+// skipped. Records the local catalog already holds are kept.
+func RebuildCatalog(ctx context.Context, configPath string) (CatalogResult, error) {
+	operator, err := NewOperator(ctx, configPath)
+	if err != nil {
+		return CatalogResult{}, err
+	}
+	if operator.storage == nil {
+		return CatalogResult{}, ErrNoStorage
+	}
+	return operator.rebuildCatalog()
+}
+
+func (operator *Operator) rebuildCatalog() (CatalogResult, error) {
+	var result CatalogResult
+	dir := operator.config.Backup.Directory
+	objects, err := operator.storage.List(operator.ctx, "")
+	if err != nil {
+		return result, fmt.Errorf("list storage: %w", err)
+	}
+	scratch, err := os.MkdirTemp("", "bacli-catalog-*")
+	if err != nil {
+		return result, fmt.Errorf("create scratch directory: %w", err)
+	}
+	defer os.RemoveAll(scratch)
+
+	// records by database directory; the backup directory of the host that
+	// wrote them is found from where their metadata is stored
+	records := make(map[string][]Metadata)
+	var (
+		origin string
+		runs   []RunManifest
+	)
+	for _, object := range objects {
+		local := filepath.Join(scratch, filepath.FromSlash(object.Key))
+		switch {
+		case path.Base(object.Key) == MetadataFilename:
+			if err := operator.storage.Download(operator.ctx, object.Key, local); err != nil {
+				return result, fmt.Errorf("download %s: %w", object.Key, err)
+			}
+			var record Metadata
+			if err := record.Load(local); err != nil {
+				return result, err
+			}
+			rel := filepath.FromSlash(path.Dir(object.Key))
+			if recordDir := filepath.Dir(record.FilePath); origin == "" && strings.HasSuffix(recordDir, string(filepath.Separator)+rel) {
+				origin = strings.TrimSuffix(recordDir, string(filepath.Separator)+rel)
+			}
+			local := filepath.Join(dir, rel)
+			records[local] = append(records[local], record)
+		case path.Dir(object.Key) == RunsDirname && path.Ext(object.Key) == ".json":
+			if err := operator.storage.Download(operator.ctx, object.Key, local); err != nil {
+				return result, fmt.Errorf("download %s: %w", object.Key, err)
+			}
+			data, err := os.ReadFile(local)
+			if err != nil {
+				return result, err
+			}
+			var run RunManifest
+			if err := json.Unmarshal(data, &run); err != nil {
+				return result, fmt.Errorf("decode run manifest %s: %w", object.Key, err)
+			}
+			runs = append(runs, run)
+		}
+	}
+
+	for _, run := range runs {
+		run = rebaseRun(run, origin, dir)
+		added, err := mergeRun(dir, run)
+		if err != nil {
+			return result, err
+		}
+		if added {
+			result.Runs++
+		}
+		for _, artifact := range run.Artifacts {
+			if !filepath.IsAbs(artifact.FilePath) {
+				continue // failed before writing anything
+			}
+			// artifacts are written in their database directory
+			local := filepath.Dir(artifact.FilePath)
+			records[local] = append(records[local], Metadata{
+				Engine:     artifact.Engine,
+				Database:   artifact.Database,
+				FilePath:   artifact.FilePath,
+				RemotePath: artifact.RemotePath,
+				Status:     artifact.Status,
+				Error:      artifact.Error,
+				StartedAt:  artifact.StartedAt,
+				SizeBytes:  artifact.SizeBytes,
+				Snapshot:   artifact.Snapshot,
+				RunID:      run.ID,
+			})
+		}
+	}
+
+	for local, found := range records {
+		var kept []Metadata
+		for _, record := range found {
+			record = rebaseRecord(record, origin, dir)
+			if record.Restorable() && !operator.artifactStored(objects, record) {
+				result.Skipped++
+				continue
+			}
+			kept = append(kept, record)
+		}
+		added, err := mergeHistory(local, kept)
+		result.Records += added
+		if err != nil {
+			return result, err
+		}
+		if added > 0 {
+			result.Databases++
+		}
+	}
+	operator.log.Info("catalog rebuilt",
+		"backend", operator.storage.Name(),
+		"databases", result.Databases,
+		"records", result.Records,
+		"runs", result.Runs,
+		"skipped", result.Skipped,
+	)
+	return result, nil
+}
+
+// artifactStored reports whether the artifact of record is among objects,
+// held by the chunk repository, or still in the backup directory.
+func (operator *Operator) artifactStored(objects []storage.Object, record Metadata) bool {
+	if record.Snapshot != "" {
+		return true
+	}
+	if _, err := os.Stat(record.FilePath); err == nil {
+		return true
+	}
+	key, err := operator.storageKey(record.FilePath)
+	return err == nil && len(artifactObjects(objects, key)) > 0
+}
+
+// mergeHistory writes the records missing from the history of dir, oldest
+// first, and returns how many it wrote. Records are matched by start time,
+// as in LoadHistory.
+func mergeHistory(dir string, records []Metadata) (int, error) {
+	existing, err := LoadHistory(dir)
+	if err != nil {
+		return 0, err
+	}
+	seen := make(map[string]bool, len(existing))
+	for _, record := range existing {
+		seen[record.StartedAt.UTC().String()] = true
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].StartedAt.Before(records[j].StartedAt)
+	})
+	added := 0
+	for _, record := range records {
+		key := record.StartedAt.UTC().String()
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		if err := record.Write(dir); err != nil {
+			return added, err
+		}
+		added++
+	}
+	return added, nil
+}
+
+// mergeRun writes the manifest of run unless the backup directory dir
+// already holds it.
+func mergeRun(dir string, run RunManifest) (bool, error) {
+	if _, err := LoadRunManifest(dir, run.ID); !errors.Is(err, ErrUnknownRun) {
+		return false, err
+	}
+	if _, err := run.Write(dir); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// rebasePath moves p from the backup directory from to the backup
+// directory to. Paths outside from are returned as is.
+func rebasePath(p, from, to string) string {
+	if from == "" || p == "" {
+		return p
+	}
+	rel, err := filepath.Rel(from, p)
+	if err != nil || !filepath.IsLocal(rel) {
+		return p
+	}
+	return filepath.Join(to, rel)
+}
+
+// rebaseRecord moves the paths of record from the backup directory from to
+// the backup directory to.
+func rebaseRecord(record Metadata, from, to string) Metadata {
+	record.FilePath = rebasePath(record.FilePath, from, to)
+	record.Signature = rebasePath(record.Signature, from, to)
+	record.StderrLog = rebasePath(record.StderrLog, from, to)
+	cleanedUp := make([]string, 0, len(record.CleanedUp))
+	for _, cleaned := range record.CleanedUp {
+		cleanedUp = append(cleanedUp, rebasePath(cleaned, from, to))
+	}
+	if len(cleanedUp) > 0 {
+		record.CleanedUp = cleanedUp
+	}
+	return record
+}
+
+// rebaseRun moves the artifact paths of run from the backup directory from
+// to the backup directory to.
+func rebaseRun(run RunManifest, from, to string) RunManifest {
+	artifacts := make([]RunArtifact, len(run.Artifacts))
+	for i, artifact := range run.Artifacts {
+		artifact.FilePath = rebasePath(artifact.FilePath, from, to)
+		artifacts[i] = artifact
+	}
+	run.Artifacts = artifacts
+	return run
+}
@@ -0,0 +1,68 @@
+package operations
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCatalogRebuildAndImport(t *testing.T) {
+	backend := &memStorage{}
+	operator, db := flowOperator(t, backend)
+
+	// the first run is only known remotely from its manifest, as the
+	// second overwrites the stored metadata.json
+	first, err := operator.backupDatabase(context.Background(), db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	run := newRunManifest("20250101T000000Z-abcdef", operator.config, nil, first.StartedAt, []*Metadata{first})
+	manifestPath, err := run.Write(operator.config.Backup.Directory)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := backend.Upload(context.Background(), manifestPath, RunsDirname+"/"+run.ID+".json"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := operator.backupDatabase(context.Background(), db); err != nil {
+		t.Fatal(err)
+	}
+
+	// a fresh host with another backup directory
+	fresh, _ := flowOperator(t, backend)
+	result, err := fresh.rebuildCatalog()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Records != 2 || result.Runs != 1 || result.Databases != 1 {
+		t.Fatalf("rebuild = %+v, want 2 records of 1 database and 1 run", result)
+	}
+	dir := filepath.Join(fresh.config.Backup.Directory, "postgres", "billing")
+	records, err := LoadHistory(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, record := range records {
+		if !record.Restorable() || !strings.HasPrefix(record.FilePath, dir) {
+			t.Errorf("rebuilt record %+v, want a restorable artifact under %s", record, dir)
+		}
+	}
+	if result, err := fresh.rebuildCatalog(); err != nil || result.Records != 0 || result.Runs != 0 {
+		t.Errorf("second rebuild = %+v, %v, want nothing new", result, err)
+	}
+
+	export, exported, err := exportCatalog(fresh.config.Backup.Directory)
+	if err != nil || exported.Records != 2 || exported.Runs != 1 {
+		t.Fatalf("export = %+v, %v", exported, err)
+	}
+	target := t.TempDir()
+	imported, err := importCatalog(target, export)
+	if err != nil || imported.Records != 2 || imported.Runs != 1 {
+		t.Fatalf("import = %+v, %v", imported, err)
+	}
+	latest, err := LoadLatestRestorable(filepath.Join(target, "postgres", "billing"))
+	if err != nil || !strings.HasPrefix(latest.FilePath, target) {
+		t.Errorf("LoadLatestRestorable() = %+v, %v, want an artifact under %s", latest, err, target)
+	}
+}
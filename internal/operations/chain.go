@@ -0,0 +1,218 @@
+package operations
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/kebairia/backup/internal/config"
+)
+
+// ChainLink is one backup of a chain.
+type ChainLink struct {
+	StartedAt   time.Time `json:"started_at"`
+	CompletedAt time.Time `json:"completed_at"`
+	FilePath    string    `json:"file_path"`
+	SizeBytes   int64     `json:"size_bytes"`
+	Incremental bool      `json:"incremental"`
+	// Base is when the backup the link applies on started.
+	Base time.Time `json:"base,omitzero"`
+}
+
+// Chain is a full backup and the incremental backups that apply on it, in
+// the order they are restored.
+type Chain struct {
+	Engine   string      `json:"engine"`
+	Database string      `json:"database"`
+	Links    []ChainLink `json:"links"`
+	// Broken explains why the chain cannot be restored, e.g. when the
+	// backup its first incremental applies on failed or was pruned.
+	Broken string `json:"broken,omitempty"`
+}
+
+// SizeBytes returns the size of every link of the chain.
+func (c Chain) SizeBytes() int64 {
+	var size int64
+	for _, link := range c.Links {
+		size += link.SizeBytes
+	}
+	return size
+}
+
+// historyKey identifies a record of a history log by its start time, as
+// LoadHistory does.
+func historyKey(t time.Time) string {
+	return t.UTC().String()
+}
+
+// BuildChains groups the restorable records of history (oldest first) into
+// chains: every full backup starts one, and an incremental backup joins the
+// chain of its base. An incremental whose base is not restorable starts a
+// broken chain.
+func BuildChains(history []Metadata) []Chain {
+	var (
+		chains []Chain
+		owner  = make(map[string]int) // history key -> index in chains
+	)
+	for _, record := range history {
+		if !record.Restorable() {
+			continue
+		}
+		link := ChainLink{
+			StartedAt:   record.StartedAt,
+			CompletedAt: record.CompletedAt,
+			FilePath:    record.FilePath,
+			SizeBytes:   record.SizeBytes,
+			Incremental: !record.Base.IsZero(),
+			Base:        record.Base,
+		}
+		i, ok := owner[historyKey(record.Base)]
+		if !link.Incremental || !ok {
+			chain := Chain{Engine: record.Engine, Database: record.Database}
+			if link.Incremental {
+				chain.Broken = fmt.Sprintf("base backup of %s is missing", record.Base.Format(time.RFC3339))
+			}
+			chains = append(chains, chain)
+			i = len(chains) - 1
+		}
+		chains[i].Links = append(chains[i].Links, link)
+		owner[historyKey(record.StartedAt)] = i
+	}
+	return chains
+}
+
+// Chains returns the backup chains of every database in the catalog of the
+// given configuration, or of database alone when it is not empty. It reads
+// the local backup directory only.
+func Chains(configPath, database string) ([]Chain, error) {
+	var cfg config.Config
+	if err := cfg.Load(configPath); err != nil {
+		return nil, err
+	}
+	entries, err := LoadCatalog(cfg.Backup.Directory)
+	if err != nil {
+		return nil, err
+	}
+	var chains []Chain
+	for _, entry := range entries {
+		if database != "" && entry.Record.Database != database {
+			continue
+		}
+		history, err := LoadHistory(filepath.Dir(entry.Path))
+		if err != nil {
+			return nil, err
+		}
+		chains = append(chains, BuildChains(history)...)
+	}
+	return chains, nil
+}
+
+// keepBases removes from expired (newest first, as expiredCopies returns
+// them) the records that a restorable incremental backup of history still
+// depends on, directly or through other incrementals, so that retention
+// never breaks a chain it keeps.
+func keepBases(history []Metadata, expired []*Metadata) []*Metadata {
+	gone := make(map[string]bool, len(expired))
+	for _, record := range expired {
+		gone[historyKey(record.StartedAt)] = true
+	}
+	needed := make(map[string]bool)
+	for _, record := range history {
+		if record.Restorable() && !record.Base.IsZero() && !gone[historyKey(record.StartedAt)] {
+			needed[historyKey(record.Base)] = true
+		}
+	}
+	// bases are older than their incrementals, so a kept incremental is
+	// seen before its own base
+	kept := make(map[*Metadata]bool)
+	for _, record := range expired {
+		if !needed[historyKey(record.StartedAt)] {
+			continue
+		}
+		kept[record] = true
+		if !record.Base.IsZero() {
+			needed[historyKey(record.Base)] = true
+		}
+	}
+	if len(kept) == 0 {
+		return expired
+	}
+	var remaining []*Metadata
+	for _, record := range expired {
+		if !kept[record] {
+			remaining = append(remaining, record)
+		}
+	}
+	return remaining
+}
+
+// LoadPointInTime returns the backups recorded in dirPath that restore the
+// database as of target, in the order they are restored: the latest full
+// backup completed by target, then the incremental backups of its chain up
+// to the first one taken at or after target.
+func LoadPointInTime(dirPath string, target time.Time) ([]Metadata, error) {
+	history, err := LoadHistory(dirPath)
+	if err != nil {
+		return nil, err
+	}
+	records := make(map[string]Metadata, len(history))
+	for _, record := range history {
+		records[historyKey(record.StartedAt)] = record
+	}
+	chains := BuildChains(history)
+	for i := len(chains) - 1; i >= 0; i-- {
+		chain := chains[i]
+		if chain.Broken != "" || chain.Links[0].CompletedAt.After(target) {
+			continue
+		}
+		var (
+			points  []Metadata
+			covered time.Time // changes up to then are in points
+		)
+		for _, link := range chain.Links {
+			points = append(points, records[historyKey(link.StartedAt)])
+			covered = link.StartedAt
+			if !link.Incremental {
+				covered = link.CompletedAt
+			}
+			if !covered.Before(target) {
+				return points, nil
+			}
+		}
+		return nil, fmt.Errorf("%w covering %s in %s: the latest backup reaches %s",
+			ErrNoRestorePoint, target.Format(time.RFC3339), dirPath, covered.Format(time.RFC3339))
+	}
+	return nil, fmt.Errorf("%w completed by %s in %s", ErrNoRestorePoint, target.Format(time.RFC3339), dirPath)
+}
+
+// LoadChainTo returns the backups recorded in dirPath that restore record,
+// in the order they are restored: record alone for a full backup, and for
+// an incremental one the full backup and incrementals it applies on first.
+func LoadChainTo(dirPath string, record Metadata) ([]Metadata, error) {
+	if record.Base.IsZero() {
+		return []Metadata{record}, nil
+	}
+	history, err := LoadHistory(dirPath)
+	if err != nil {
+		return nil, err
+	}
+	restorable := make(map[string]Metadata, len(history))
+	for _, r := range history {
+		if r.Restorable() {
+			restorable[historyKey(r.StartedAt)] = r
+		}
+	}
+	chain := []Metadata{record}
+	for link := record; !link.Base.IsZero(); {
+		base, ok := restorable[historyKey(link.Base)]
+		if !ok {
+			return nil, fmt.Errorf("%w: the backup of %s that %s applies on is missing in %s",
+				ErrNoRestorePoint, link.Base.Format(time.RFC3339), link.StartedAt.Format(time.RFC3339), dirPath)
+		}
+		chain = append(chain, base)
+		link = base
+	}
+	slices.Reverse(chain)
+	return chain, nil
+}
@@ -0,0 +1,52 @@
+package operations
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLoadPointInTime(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	hour := func(h int) time.Time { return start.Add(time.Duration(h) * time.Hour) }
+	records := []Metadata{
+		{StartedAt: hour(0), CompletedAt: hour(1), FilePath: "/b/full.dump"},
+		{StartedAt: hour(2), CompletedAt: hour(2), FilePath: "/b/1.oplog", Base: hour(0)},
+		{StartedAt: hour(4), CompletedAt: hour(4), FilePath: "/b/2.oplog", Base: hour(2)},
+		{StartedAt: hour(6), CompletedAt: hour(6), FilePath: "/b/3.oplog", Base: hour(4)},
+	}
+	for _, record := range records {
+		record.Engine, record.Database, record.Status = "mongodb", "app", StatusSuccess
+		if err := record.Write(dir); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	paths := func(chain []Metadata) []string {
+		var out []string
+		for _, r := range chain {
+			out = append(out, r.FilePath)
+		}
+		return out
+	}
+	chain, err := LoadPointInTime(dir, hour(3))
+	if got := paths(chain); err != nil || len(got) != 3 || got[2] != "/b/2.oplog" {
+		t.Errorf("LoadPointInTime(3h) = %v, %v, want the full backup and two captures", got, err)
+	}
+	if _, err := LoadPointInTime(dir, hour(7)); !errors.Is(err, ErrNoRestorePoint) {
+		t.Errorf("LoadPointInTime past the last capture = %v, want ErrNoRestorePoint", err)
+	}
+	if _, err := LoadPointInTime(dir, start.Add(30*time.Minute)); !errors.Is(err, ErrNoRestorePoint) {
+		t.Errorf("LoadPointInTime before the full backup completed = %v, want ErrNoRestorePoint", err)
+	}
+
+	latest, err := LoadLatestRestorable(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain, err = LoadChainTo(dir, latest)
+	if got := paths(chain); err != nil || len(got) != 4 || got[0] != "/b/full.dump" {
+		t.Errorf("LoadChainTo(latest) = %v, %v", got, err)
+	}
+}
@@ -6,11 +6,30 @@ import (
 	"os"
 	"strings"
 
+	"github.com/kebairia/backup/internal/database"
+	"github.com/kebairia/backup/internal/progress"
 	"github.com/klauspost/compress/zstd"
 )
 
-func CompressZstd(inputPath string) (string, error) {
-	outputPath := inputPath + ".zst"
+// compression returns the compression mode of db's instance (see
+// config.Compression).
+func (operator *Operator) compression(db database.Database) string {
+	instance := ""
+	if named, ok := db.(database.Instancer); ok {
+		instance = named.GetInstance()
+	}
+	return operator.config.Compression(db.GetEngine(), instance)
+}
+
+// CompressZstd compresses inputPath into inputPath.zst and removes the original.
+// On failure the partial .zst file is removed and the original is kept.
+func CompressZstd(inputPath string) (outputPath string, err error) {
+	return compressZstd(inputPath, nil)
+}
+
+// compressZstd is CompressZstd, counting the bytes compressed on bar.
+func compressZstd(inputPath string, bar *progress.Bar) (outputPath string, err error) {
+	outputPath = inputPath + ".zst"
 
 	inFile, err := os.Open(inputPath)
 	if err != nil {
@@ -22,19 +41,30 @@ func CompressZstd(inputPath string) (string, error) {
 	if err != nil {
 		return "", fmt.Errorf("failed to create output file: %w", err)
 	}
-	defer outFile.Close()
+	defer func() {
+		if err != nil {
+			outFile.Close()
+			os.Remove(outputPath)
+		}
+	}()
 
 	// Create a Zstandard writer
 	encoder, err := zstd.NewWriter(outFile)
 	if err != nil {
 		return "", fmt.Errorf("failed to create Zstandard writer: %w", err)
 	}
-	defer encoder.Close()
 	// Copy the input file to the Zstandard writer
-	if _, err := io.Copy(encoder, inFile); err != nil {
+	if _, err := io.Copy(encoder, bar.Reader(inFile)); err != nil {
+		encoder.Close()
 		return "", fmt.Errorf("failed to compress file: %w", err)
 	}
-	defer encoder.Close()
+	// Flush the encoder and the file before dropping the original
+	if err := encoder.Close(); err != nil {
+		return "", fmt.Errorf("failed to flush Zstandard writer: %w", err)
+	}
+	if err := outFile.Close(); err != nil {
+		return "", fmt.Errorf("failed to close output file: %w", err)
+	}
 
 	if err := os.Remove(inputPath); err != nil {
 		return "", fmt.Errorf("failed to remove original file: %w", err)
@@ -45,8 +75,11 @@ func CompressZstd(inputPath string) (string, error) {
 
 func DecompressZstd(inputPath string) (string, error) {
 	// 2) Prepare output path (strip “.zst”)
-	outputPath := strings.TrimSuffix(inputPath, ".zst")
+	return decompressZstdTo(inputPath, strings.TrimSuffix(inputPath, ".zst"))
+}
 
+// decompressZstdTo decompresses inputPath into outputPath.
+func decompressZstdTo(inputPath, outputPath string) (string, error) {
 	// open the input file
 	in, err := os.Open(inputPath)
 	if err != nil {
@@ -0,0 +1,185 @@
+package operations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/kebairia/backup/internal/config"
+	"github.com/kebairia/backup/internal/database"
+)
+
+// Doctor check kinds and outcomes.
+const (
+	CheckTool       = "tool"
+	CheckVault      = "vault"
+	CheckConnection = "connection"
+
+	DoctorOK   = "ok"
+	DoctorWarn = "warn"
+	DoctorFail = "fail"
+)
+
+// ErrDoctor indicates that at least one doctor check failed.
+var ErrDoctor = errors.New("environment check failed")
+
+// DoctorCheck is the outcome of one doctor check.
+type DoctorCheck struct {
+	Check   string `json:"check"`
+	Engine  string `json:"engine,omitempty"`
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+	Status  string `json:"status"`
+	Detail  string `json:"detail,omitempty"`
+}
+
+// Doctor checks that the client binaries of every configured engine are
+// installed, then logs in to Vault and connects to every instance with its
+// dynamic credentials, comparing client and server versions.
+// Checks that fail are reported, not returned as errors; later checks that
+// depend on a failed one are skipped.
+func Doctor(ctx context.Context, configPath string) ([]DoctorCheck, error) {
+	var cfg config.Config
+	if err := cfg.Load(configPath); err != nil {
+		return nil, err
+	}
+
+	var checks []DoctorCheck
+	versions := make(map[string]string) // tool -> client version
+	for _, engine := range configuredEngines(cfg) {
+		if engine == database.EnginePostgres && nativeOnly(cfg.Postgres) {
+			continue // no client binaries needed
+		}
+		if group, _ := cfg.EngineGroup(engine); remoteOnly(group) {
+			continue // client binaries run in the database pods
+		}
+		names := database.EngineTools(engine)
+		if engine == database.EngineInfluxDB && cfg.InfluxDB.Version != "" {
+			names = []string{database.InfluxDBTool(cfg.InfluxDB.Version)}
+		}
+		for _, name := range names {
+			check := DoctorCheck{Check: CheckTool, Engine: engine, Name: name, Status: DoctorOK}
+			tool, err := database.LookupTool(name)
+			check.Version = tool.Version
+			check.Detail = tool.Path
+			if err != nil {
+				check.Status = DoctorFail
+				check.Detail = err.Error()
+			}
+			versions[name] = tool.Version
+			checks = append(checks, check)
+		}
+	}
+
+	vaultCheck := DoctorCheck{Check: CheckVault, Name: cfg.Vault.Address, Status: DoctorOK}
+	if cfg.Vault.Address == "" {
+		vaultCheck.Detail = "not configured, using static credentials"
+	}
+	operator, err := NewOperator(ctx, configPath)
+	if err != nil {
+		vaultCheck.Status = DoctorFail
+		vaultCheck.Detail = err.Error()
+		return append(checks, vaultCheck), nil
+	}
+	checks = append(checks, vaultCheck)
+
+	databases, err := database.InitializeDatabases(
+		operator.ctx,
+		operator.config,
+		operator.vaultClient,
+	)
+	if err != nil {
+		return append(checks, DoctorCheck{
+			Check:  CheckConnection,
+			Name:   "credentials",
+			Status: DoctorFail,
+			Detail: err.Error(),
+		}), nil
+	}
+	for _, db := range databases {
+		checks = append(checks, checkConnection(db, versions))
+	}
+	return checks, nil
+}
+
+// checkConnection connects to db and compares its server version with the
+// client tools of its engine.
+func checkConnection(db database.Database, versions map[string]string) DoctorCheck {
+	check := DoctorCheck{
+		Check:  CheckConnection,
+		Engine: db.GetEngine(),
+		Name:   fmt.Sprintf("%s@%s", db.GetName(), db.GetHost()),
+		Status: DoctorOK,
+	}
+	versioner, ok := db.(database.ServerVersioner)
+	if !ok {
+		check.Status = DoctorWarn
+		check.Detail = fmt.Sprintf("%s does not support connection checks", db.GetEngine())
+		return check
+	}
+	version, err := versioner.ServerVersion()
+	if err != nil {
+		check.Status = DoctorFail
+		check.Detail = err.Error()
+		return check
+	}
+	check.Version = version
+	for _, tool := range database.EngineTools(db.GetEngine()) {
+		if err := database.CheckCompatibility(db.GetEngine(), tool, versions[tool], version); err != nil {
+			check.Status = DoctorFail
+			check.Detail = err.Error()
+			return check
+		}
+	}
+	return check
+}
+
+// configuredEngines returns the engines with at least one instance.
+func configuredEngines(cfg config.Config) []string {
+	groups := []struct {
+		engine string
+		group  config.DBGroupConfig
+	}{
+		{database.EnginePostgres, cfg.Postgres},
+		{database.EngineMongoDB, cfg.MongoDB},
+		{"mysql", cfg.MySQL},
+		{"redis", cfg.Redis},
+		{database.EngineClickHouse, cfg.ClickHouse},
+		{database.EngineCassandra, cfg.Cassandra},
+		{database.EngineInfluxDB, cfg.InfluxDB},
+		{database.EngineCouchDB, cfg.CouchDB},
+	}
+	var engines []string
+	for _, g := range groups {
+		if len(g.group.Instances) > 0 {
+			engines = append(engines, g.engine)
+		}
+	}
+	return engines
+}
+
+// nativeOnly reports whether every Postgres instance uses the native COPY
+// method, which needs no client binaries.
+func nativeOnly(group config.DBGroupConfig) bool {
+	for _, instance := range group.Instances {
+		method := instance.Method
+		if method == "" {
+			method = group.EngineDefaults.Method
+		}
+		if method != database.PostgresMethodNative {
+			return false
+		}
+	}
+	return true
+}
+
+// remoteOnly reports whether every instance of group runs its client tools
+// through a remote exec driver.
+func remoteOnly(group config.DBGroupConfig) bool {
+	for _, instance := range group.Instances {
+		if !instance.Exec.Remote() {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,286 @@
+package operations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/kebairia/backup/internal/database"
+	"github.com/kebairia/backup/internal/notify"
+)
+
+// DrillsDirname is the directory under the backup directory holding one
+// report per restore drill, named <drill id>.json.
+const DrillsDirname = "drills"
+
+const defaultDrillSuffix = "_drill"
+
+// ErrDrillFailed indicates that a restore drill failed or missed its RTO.
+var ErrDrillFailed = errors.New("restore drill failed")
+
+// DrillOptions selects the databases of a drill.
+type DrillOptions struct {
+	Engine   string // drill only databases of this engine (empty drills all)
+	Instance string // drill only this instance (see matchDatabase)
+	Database string // drill only this database
+}
+
+// DrillReport is the outcome of a restore drill: for every database, how
+// long restoring its latest backup onto the staging instance took, and
+// whether that met its recovery time objective. It is kept as evidence that
+// recovery objectives are met.
+type DrillReport struct {
+	ID          string        `json:"id"`
+	StartedAt   time.Time     `json:"started_at"`
+	CompletedAt time.Time     `json:"completed_at"`
+	Host        string        `json:"host,omitempty"`
+	ConfigHash  string        `json:"config_hash"`
+	Tenant      string        `json:"tenant,omitempty"`
+	Results     []DrillResult `json:"results"`
+}
+
+// DrillResult is the rehearsal of one database.
+type DrillResult struct {
+	Engine          string    `json:"engine"`
+	Database        string    `json:"database"`
+	TargetHost      string    `json:"target_host,omitempty"`
+	TargetDatabase  string    `json:"target_database"`
+	BackupStartedAt time.Time `json:"backup_started_at,omitzero"`
+	SizeBytes       int64     `json:"size_bytes,omitempty"`
+	// Duration covers fetching, decrypting, decompressing and restoring
+	// the artifact: the time to recover the database.
+	Duration time.Duration    `json:"duration_ns"`
+	RTO      time.Duration    `json:"rto_ns,omitempty"` // zero when none is set
+	Counts   map[string]int64 `json:"counts,omitempty"`
+	Status   string           `json:"status"`
+	Error    string           `json:"error,omitempty"`
+}
+
+// MetRTO reports whether the restore succeeded within its RTO, if any.
+func (r DrillResult) MetRTO() bool {
+	return r.Status == StatusSuccess && (r.RTO == 0 || r.Duration <= r.RTO)
+}
+
+// Passed reports whether every database was restored within its RTO.
+func (r DrillReport) Passed() bool {
+	for _, result := range r.Results {
+		if !result.MetRTO() {
+			return false
+		}
+	}
+	return true
+}
+
+// Drill rehearses a recovery: the latest backup of every selected database
+// is restored onto the staging instance (drill.host), timed, counted and
+// dropped again. The report is written to <backup.directory>/drills and
+// sent to the notifiers; ErrDrillFailed is returned along with it when a
+// restore failed or took longer than its RTO.
+func Drill(ctx context.Context, configPath string, opts DrillOptions) (DrillReport, error) {
+	operator, err := NewOperator(ctx, configPath)
+	if err != nil {
+		return DrillReport{}, err
+	}
+	databases, err := database.InitializeDatabases(operator.ctx, operator.config, operator.vaultClient)
+	if err != nil {
+		return DrillReport{}, fmt.Errorf("initialize databases: %w", err)
+	}
+	var selected []database.Database
+	for _, db := range databases {
+		if matchDatabase(db, opts.Engine, opts.Instance, opts.Database) {
+			selected = append(selected, db)
+		}
+	}
+	if len(selected) == 0 {
+		return DrillReport{}, fmt.Errorf("%w: engine %q, instance %q and name %q",
+			ErrNoDatabase, opts.Engine, opts.Instance, opts.Database)
+	}
+
+	host, _ := os.Hostname()
+	report := DrillReport{
+		ID:         operator.runID,
+		StartedAt:  time.Now(),
+		Host:       host,
+		ConfigHash: configHash(operator.config),
+		Tenant:     operator.config.Tenant,
+		Results:    []DrillResult{},
+	}
+	// one at a time, so that restores do not slow each other down
+	for _, db := range selected {
+		if operator.ctx.Err() != nil {
+			break
+		}
+		result := operator.drillDatabase(db)
+		if result.MetRTO() {
+			operator.log.Info("restore drill passed",
+				"database", db.GetName(),
+				"engine", db.GetEngine(),
+				"duration", result.Duration.String(),
+				"rto", result.RTO.String(),
+			)
+		} else {
+			operator.log.Error("restore drill failed",
+				"database", db.GetName(),
+				"engine", db.GetEngine(),
+				"duration", result.Duration.String(),
+				"rto", result.RTO.String(),
+				"error", result.Error,
+			)
+		}
+		report.Results = append(report.Results, result)
+	}
+	report.CompletedAt = time.Now()
+
+	if err := operator.cancelled(); err != nil {
+		return report, err
+	}
+	path := filepath.Join(operator.config.Backup.Directory, DrillsDirname, report.ID+".json")
+	if err := EnsureDirectoryExist(filepath.Dir(path)); err != nil {
+		return report, err
+	}
+	if err := WriteJSONAtomic(path, report); err != nil {
+		return report, fmt.Errorf("write drill report: %w", err)
+	}
+	operator.notify(report.notifyReport())
+	if !report.Passed() {
+		return report, ErrDrillFailed
+	}
+	return report, nil
+}
+
+// drillDatabase restores the latest backup of db onto the staging target.
+func (operator *Operator) drillDatabase(db database.Database) DrillResult {
+	cfg := operator.config.Drill
+	instance := ""
+	if named, ok := db.(database.Instancer); ok {
+		instance = named.GetInstance()
+	}
+	result := DrillResult{
+		Engine:     db.GetEngine(),
+		Database:   db.GetName(),
+		TargetHost: cfg.Host,
+		RTO:        operator.config.RTO(db.GetEngine(), instance),
+		Status:     StatusSuccess,
+	}
+	if result.TargetHost == "" {
+		result.TargetHost = operator.config.Verify.Host
+	}
+	suffix := cfg.Suffix
+	if suffix == "" {
+		suffix = defaultDrillSuffix
+	}
+	result.TargetDatabase = db.GetName() + suffix
+
+	err := operator.rehearse(db, &result, cfg.Keep)
+	if err != nil {
+		result.Status = StatusFailed
+		result.Error = err.Error()
+	} else if !result.MetRTO() {
+		result.Error = fmt.Sprintf("restore took %s, over its RTO of %s",
+			result.Duration.Round(time.Second), result.RTO)
+	}
+	return result
+}
+
+// rehearse restores the latest backup of db into the target of result,
+// timing the restore, and drops the target afterwards unless keep is set.
+// Incremental backups cannot be replayed into the renamed target, so the
+// latest full backup is restored instead of them.
+func (operator *Operator) rehearse(db database.Database, result *DrillResult, keep bool) error {
+	latest, err := LoadLatestRestorable(db.GetPath())
+	if err != nil {
+		return err
+	}
+	chain, err := LoadChainTo(db.GetPath(), latest)
+	if err != nil {
+		return err
+	}
+	record := chain[0]
+	result.BackupStartedAt = record.StartedAt
+	result.SizeBytes = record.SizeBytes
+
+	verifier, ok := db.(database.Verifier)
+	if !ok {
+		return fmt.Errorf("%s does not support staging restores", db.GetEngine())
+	}
+	verifier.Retarget(result.TargetHost, result.TargetDatabase)
+	if err := verifier.PrepareTarget(); err != nil {
+		return fmt.Errorf("prepare staging target: %w", err)
+	}
+	if !keep {
+		defer func() {
+			if err := verifier.DropTarget(); err != nil {
+				operator.log.Warn("failed to drop staging target",
+					"database", result.TargetDatabase,
+					"error", err.Error(),
+				)
+			}
+		}()
+	}
+
+	stderr := operator.captureStderr(db, "")
+	start := time.Now()
+	err = operator.RestoreDatabase(db, record)
+	result.Duration = time.Since(start)
+	if err = stderr.finish(err); err != nil {
+		return err
+	}
+	counts, err := verifier.CountObjects()
+	if err != nil {
+		return fmt.Errorf("count restored objects: %w", err)
+	}
+	result.Counts = counts
+	if len(counts) == 0 {
+		return fmt.Errorf("%w: no tables or collections restored", database.ErrVerifyFailed)
+	}
+	return nil
+}
+
+// notifyReport converts the drill to a run report for the notifiers; a
+// missed RTO counts as a failure.
+func (r DrillReport) notifyReport() notify.Report {
+	report := notify.Report{Operation: "drill", RunID: r.ID, StartedAt: r.StartedAt, CompletedAt: r.CompletedAt}
+	for _, result := range r.Results {
+		status := notify.StatusSuccess
+		if !result.MetRTO() {
+			status = notify.StatusFailed
+		}
+		report.Results = append(report.Results, notify.Result{
+			Engine:    result.Engine,
+			Database:  result.Database,
+			Status:    status,
+			Error:     result.Error,
+			Duration:  result.Duration,
+			SizeBytes: result.SizeBytes,
+		})
+	}
+	return report
+}
+
+const drillHTML = `<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>bacli restore drill {{.ID}}</title></head><body>
+<h2>bacli restore drill {{.ID}}</h2>
+<p>Started: {{.StartedAt.Format "2006-01-02 15:04:05 MST"}}<br>
+Completed: {{.CompletedAt.Format "2006-01-02 15:04:05 MST"}}<br>
+Host: {{.Host}}{{if .Tenant}}<br>
+Tenant: {{.Tenant}}{{end}}<br>
+Configuration: {{.ConfigHash}}<br>
+Result: <strong>{{if .Passed}}all recovery time objectives met{{else}}FAILED{{end}}</strong></p>
+<table border="1" cellpadding="4" cellspacing="0">
+<tr><th>Engine</th><th>Database</th><th>Backup</th><th>Size (bytes)</th><th>Restored to</th><th>Restore time</th><th>RTO</th><th>Objects</th><th>Result</th></tr>
+{{range .Results}}<tr><td>{{.Engine}}</td><td>{{.Database}}</td><td>{{if not .BackupStartedAt.IsZero}}{{.BackupStartedAt.Format "2006-01-02 15:04:05 MST"}}{{end}}</td><td>{{.SizeBytes}}</td><td>{{.TargetDatabase}}{{if .TargetHost}}@{{.TargetHost}}{{end}}</td><td>{{.Duration}}</td><td>{{if .RTO}}{{.RTO}}{{else}}-{{end}}</td><td>{{len .Counts}}</td><td>{{if .MetRTO}}passed{{else}}failed: {{.Error}}{{end}}</td></tr>
+{{end}}</table>
+</body></html>
+`
+
+var drillTmpl = template.Must(template.New("drill").Parse(drillHTML))
+
+// WriteHTML renders the report as a standalone HTML page.
+func (r DrillReport) WriteHTML(w io.Writer) error {
+	return drillTmpl.Execute(w, r)
+}
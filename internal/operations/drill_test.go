@@ -0,0 +1,38 @@
+package operations
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDrillReportPassed(t *testing.T) {
+	report := DrillReport{ID: "20250424T210000Z-3fa2c1", Results: []DrillResult{
+		{Engine: "postgres", Database: "billing", Status: StatusSuccess, Duration: 4 * time.Minute, RTO: 10 * time.Minute},
+		{Engine: "mongodb", Database: "events", Status: StatusSuccess, Duration: time.Hour},
+	}}
+	if !report.Passed() {
+		t.Errorf("restores within their RTO (or without one) must pass")
+	}
+	report.Results[0].Duration = 11 * time.Minute
+	if report.Passed() {
+		t.Errorf("a restore over its RTO must fail the drill")
+	}
+	report.Results[0].Duration = time.Minute
+	report.Results[1].Status = StatusFailed
+	if report.Passed() {
+		t.Errorf("a failed restore must fail the drill")
+	}
+	if got := report.notifyReport().Failed(); got != 1 {
+		t.Errorf("notify report failures = %d, want 1", got)
+	}
+
+	var html strings.Builder
+	report.Results[1].Error = "<script>"
+	if err := report.WriteHTML(&html); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(html.String(), "<script>") || !strings.Contains(html.String(), "FAILED") {
+		t.Errorf("unexpected HTML report:\n%s", html.String())
+	}
+}
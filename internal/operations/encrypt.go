@@ -0,0 +1,217 @@
+package operations
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kebairia/backup/internal/config"
+	"github.com/kebairia/backup/internal/encryption"
+	"github.com/klauspost/compress/zstd"
+)
+
+// buildEncryption creates the key wrapper selected in the configuration.
+// It returns nil when artifacts are stored in clear.
+func buildEncryption(
+	cfg config.EncryptionConfig,
+	vaultClient Vault,
+) (encryption.KeyWrapper, error) {
+	switch cfg.Type {
+	case "":
+		return nil, nil
+	case encryption.TypeVaultTransit:
+		return encryption.NewTransit(vaultClient, cfg.Mount, cfg.Key)
+	default:
+		return nil, fmt.Errorf("%w: unsupported type %q", encryption.ErrEncryption, cfg.Type)
+	}
+}
+
+// encryptArtifact encrypts a backup artifact and returns its new path.
+// Directory artifacts keep their path and have every file encrypted in place.
+func (operator *Operator) encryptArtifact(ctx context.Context, path string) (string, error) {
+	if !isDir(path) {
+		return encryption.EncryptFile(ctx, operator.encryption, path)
+	}
+	err := filepath.WalkDir(path, func(file string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || strings.HasSuffix(file, encryption.Suffix) {
+			return err
+		}
+		_, err = encryption.EncryptFile(ctx, operator.encryption, file)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// encryptionKey describes the configured wrapping key for metadata records.
+func (operator *Operator) encryptionKey() string {
+	cfg := operator.config.Backup.Encryption
+	if transit, ok := operator.encryption.(*encryption.Transit); ok {
+		return cfg.Type + ":" + transit.Mount + "/" + transit.Key
+	}
+	return cfg.Type
+}
+
+// openArtifact prepares an artifact for an engine to read: it is decrypted
+// and decompressed into temporary files as needed, next to it or under
+// backup.work_dir. The returned func removes those temporary files.
+func (operator *Operator) openArtifact(path string) (string, func(), error) {
+	var temps []string
+	cleanup := func() {
+		for i := len(temps) - 1; i >= 0; i-- {
+			os.RemoveAll(temps[i])
+		}
+	}
+
+	dir := filepath.Dir(path)
+	if work := operator.workDir(dir); work != dir && (encrypted(path) || strings.HasSuffix(path, ".zst")) {
+		if err := os.MkdirAll(work, 0o755); err != nil {
+			return "", nil, fmt.Errorf("create work directory: %w", err)
+		}
+		scratch, err := os.MkdirTemp(work, ".open-*")
+		if err != nil {
+			return "", nil, fmt.Errorf("create work directory: %w", err)
+		}
+		temps = append(temps, scratch)
+		dir = scratch
+	}
+	if encrypted(path) {
+		if operator.encryption == nil {
+			cleanup()
+			return "", nil, fmt.Errorf("%w: %q is encrypted but backup.encryption is not configured",
+				encryption.ErrDecryption, path)
+		}
+		decPath, err := operator.decryptArtifact(path, dir)
+		if err != nil {
+			cleanup()
+			return "", nil, err
+		}
+		temps = append(temps, decPath)
+		path = decPath
+	}
+	if strings.HasSuffix(path, ".zst") {
+		decPath, err := decompressZstdTo(path, filepath.Join(dir, strings.TrimSuffix(filepath.Base(path), ".zst")))
+		if err != nil {
+			cleanup()
+			return "", nil, err
+		}
+		temps = append(temps, decPath)
+		path = decPath
+	}
+	return path, cleanup, nil
+}
+
+// decryptArtifact decrypts a file artifact into dir, or a directory
+// artifact into a hidden directory in dir, and returns the plaintext path.
+func (operator *Operator) decryptArtifact(path, dir string) (string, error) {
+	if !isDir(path) {
+		return encryption.DecryptTo(operator.ctx, operator.encryption, path,
+			filepath.Join(dir, strings.TrimSuffix(filepath.Base(path), encryption.Suffix)))
+	}
+	out, err := os.MkdirTemp(dir, "."+filepath.Base(path)+".dec-*")
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", encryption.ErrDecryption, err)
+	}
+	err = filepath.WalkDir(path, func(file string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(path, file)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(out, strings.TrimSuffix(rel, encryption.Suffix))
+		if d.IsDir() {
+			return os.MkdirAll(target, 0o700)
+		}
+		_, err = encryption.DecryptTo(operator.ctx, operator.encryption, file, target)
+		return err
+	})
+	if err != nil {
+		os.RemoveAll(out)
+		return "", err
+	}
+	return out, nil
+}
+
+// encrypted reports whether path is an encrypted file artifact or a
+// directory artifact holding encrypted files.
+func encrypted(path string) bool {
+	if strings.HasSuffix(path, encryption.Suffix) {
+		return true
+	}
+	if !isDir(path) {
+		return false
+	}
+	found := false
+	filepath.WalkDir(path, func(file string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() && strings.HasSuffix(file, encryption.Suffix) {
+			found = true
+			return fs.SkipAll
+		}
+		return err
+	})
+	return found
+}
+
+// openStream opens the file artifact at path for an engine to read from a
+// pipe: decrypted and decompressed as it is read, without writing the
+// plaintext to disk. With gunzip, gzip artifacts (".gz") are decompressed
+// too; tools reading gzip on their own (mongorestore --gzip) must not ask
+// for it. The returned func closes it; it must be called even when the
+// reader was not read to the end.
+func (operator *Operator) openStream(path string, gunzip bool) (io.Reader, func(), error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("open %s: %w", path, err)
+	}
+	closers := []func(){func() { file.Close() }}
+	closeAll := func() {
+		for i := len(closers) - 1; i >= 0; i-- {
+			closers[i]()
+		}
+	}
+
+	var r io.Reader = file
+	name := path
+	if encrypted(path) {
+		if operator.encryption == nil {
+			closeAll()
+			return nil, nil, fmt.Errorf("%w: %q is encrypted but backup.encryption is not configured",
+				encryption.ErrDecryption, path)
+		}
+		plain, w := io.Pipe()
+		go func() {
+			w.CloseWithError(encryption.Decrypt(operator.ctx, operator.encryption, file, w))
+		}()
+		// unblocks the decryption when the engine stops reading early
+		closers = append(closers, func() { plain.Close() })
+		r, name = plain, strings.TrimSuffix(name, encryption.Suffix)
+	}
+	if strings.HasSuffix(name, ".zst") {
+		decoder, err := zstd.NewReader(r)
+		if err != nil {
+			closeAll()
+			return nil, nil, fmt.Errorf("zstd.NewReader: %w", err)
+		}
+		closers = append(closers, decoder.Close)
+		r = decoder
+	}
+	if gunzip && strings.HasSuffix(name, ".gz") {
+		decoder, err := gzip.NewReader(r)
+		if err != nil {
+			closeAll()
+			return nil, nil, fmt.Errorf("gzip.NewReader: %w", err)
+		}
+		closers = append(closers, func() { decoder.Close() })
+		r = decoder
+	}
+	return r, closeAll, nil
+}
@@ -0,0 +1,205 @@
+package operations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kebairia/backup/internal/config"
+	"github.com/kebairia/backup/internal/database"
+	"github.com/kebairia/backup/internal/state"
+	"github.com/kebairia/backup/internal/storage"
+)
+
+// fileDB is a database whose dump is a file with fixed content; restores
+// record the content they read.
+type fileDB struct {
+	dir      string
+	content  string
+	err      error // returned by Backup
+	restored string
+}
+
+func (f *fileDB) GetName() string   { return "billing" }
+func (f *fileDB) GetEngine() string { return "postgres" }
+func (f *fileDB) GetHost() string   { return "db.test" }
+func (f *fileDB) GetPath() string   { return f.dir }
+
+func (f *fileDB) Backup(context.Context) (string, error) {
+	path := filepath.Join(f.dir, "billing.dump")
+	if err := os.MkdirAll(f.dir, 0o755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, []byte(f.content), 0o644); err != nil {
+		return "", err
+	}
+	return path, f.err
+}
+
+func (f *fileDB) Restore(_ context.Context, path string) error {
+	data, err := os.ReadFile(path)
+	f.restored = string(data)
+	return err
+}
+
+// memStorage is an in-memory storage backend.
+type memStorage struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	err     error // returned by Upload
+}
+
+func (m *memStorage) Name() string { return "memory" }
+
+func (m *memStorage) Upload(_ context.Context, localPath, key string) error {
+	if m.err != nil {
+		return m.err
+	}
+	data, err := os.ReadFile(localPath)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.objects == nil {
+		m.objects = make(map[string][]byte)
+	}
+	m.objects[key] = data
+	return nil
+}
+
+func (m *memStorage) Download(_ context.Context, key, localPath string) error {
+	m.mu.Lock()
+	data, ok := m.objects[key]
+	m.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("%w: %s", storage.ErrNotFound, key)
+	}
+	if err := os.MkdirAll(filepath.Dir(localPath), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(localPath, data, 0o644)
+}
+
+func (m *memStorage) List(_ context.Context, prefix string) ([]storage.Object, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var objects []storage.Object
+	for key, data := range m.objects {
+		if strings.HasPrefix(key, prefix) {
+			objects = append(objects, storage.Object{Key: key, Size: int64(len(data))})
+		}
+	}
+	return objects, nil
+}
+
+func (m *memStorage) Delete(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.objects, key)
+	return nil
+}
+
+func flowOperator(t *testing.T, backend storage.Backend) (*Operator, *fileDB) {
+	t.Helper()
+	var cfg config.Config
+	cfg.Backup.Directory = t.TempDir()
+	cfg.Backup.Compression = true
+	operator := &Operator{ctx: context.Background(), config: cfg, log: nopLogger{}, storage: backend}
+	db := &fileDB{dir: filepath.Join(cfg.Backup.Directory, "postgres", "billing"), content: "dump of billing"}
+	return operator, db
+}
+
+func TestBackupRestoreFlow(t *testing.T) {
+	backend := &memStorage{}
+	operator, db := flowOperator(t, backend)
+
+	record, err := operator.backupDatabase(context.Background(), db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.Status != StatusSuccess || !strings.HasSuffix(record.FilePath, ".zst") || record.Checksum == "" {
+		t.Fatalf("record = %+v, want a successful compressed and checksummed artifact", record)
+	}
+	if record.RemotePath != "postgres/billing/billing.dump.zst" {
+		t.Errorf("RemotePath = %q", record.RemotePath)
+	}
+	if _, ok := backend.objects["postgres/billing/"+MetadataFilename]; !ok {
+		t.Error("metadata was not uploaded")
+	}
+	latest, err := LoadLatestRestorable(db.GetPath())
+	if err != nil || latest.FilePath != record.FilePath {
+		t.Fatalf("LoadLatestRestorable() = %+v, %v", latest, err)
+	}
+
+	// the restore downloads the artifact once its local copy is gone
+	if err := os.Remove(record.FilePath); err != nil {
+		t.Fatal(err)
+	}
+	if err := operator.RestoreDatabase(db, latest); err != nil {
+		t.Fatal(err)
+	}
+	if db.restored != db.content {
+		t.Errorf("restored %q, want %q", db.restored, db.content)
+	}
+}
+
+func TestBackupFlowFailures(t *testing.T) {
+	operator, db := flowOperator(t, &memStorage{err: errors.New("bucket unreachable")})
+	record, err := operator.backupDatabase(context.Background(), db)
+	if err == nil || record.Status != StatusFailed || !strings.Contains(record.Error, "bucket unreachable") {
+		t.Errorf("failed upload: record %+v, error %v", record, err)
+	}
+
+	operator, db = flowOperator(t, nil)
+	db.err = errors.New("pg_dump failed")
+	record, err = operator.backupDatabase(context.Background(), db)
+	if err == nil || record.Status != StatusFailed || record.FilePath != "N/A" {
+		t.Errorf("failed dump: record %+v, error %v", record, err)
+	}
+	if _, statErr := os.Stat(filepath.Join(db.dir, "billing.dump")); !os.IsNotExist(statErr) {
+		t.Error("partial artifact of the failed dump was kept")
+	}
+	if _, err := LoadLatestRestorable(db.GetPath()); err == nil {
+		t.Error("failed dump is restorable")
+	}
+}
+
+func TestBackupAllReturnsFailures(t *testing.T) {
+	operator, db := flowOperator(t, nil)
+	operator.state = state.NewLocal(t.TempDir())
+	db.err = errors.New("pg_dump failed")
+	report, err := operator.backupAll([]database.Database{db})
+	if !errors.Is(err, ErrRunFailed) || !strings.Contains(err.Error(), "pg_dump failed") {
+		t.Errorf("error = %v, want the failed backup", err)
+	}
+	if report.Failed() != 1 {
+		t.Errorf("report has %d failures, want 1", report.Failed())
+	}
+
+	operator, db = flowOperator(t, nil)
+	operator.state = brokenStore{state.NewLocal(t.TempDir())}
+	report, err = operator.backupAll([]database.Database{db})
+	if !errors.Is(err, ErrRunFailed) || report.Failed() != 1 || report.Succeeded() {
+		t.Errorf("unreachable lock: report %+v, error %v", report, err)
+	}
+
+	operator, db = flowOperator(t, nil)
+	operator.state = state.NewLocal(t.TempDir())
+	if _, err := operator.backupAll([]database.Database{db}); err != nil {
+		t.Errorf("successful run returned %v", err)
+	}
+}
+
+// brokenStore is a state store that cannot be reached to take locks.
+type brokenStore struct{ state.Store }
+
+func (brokenStore) Lock(context.Context, string, string, time.Duration) error {
+	return errors.New("state store unreachable")
+}
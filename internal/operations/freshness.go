@@ -0,0 +1,172 @@
+package operations
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kebairia/backup/internal/notify"
+	"github.com/kebairia/backup/internal/state"
+)
+
+// Freshness states, ordered by severity as Nagios plugins report them (the
+// value is the plugin exit status).
+const (
+	FreshnessOK       = 0
+	FreshnessWarning  = 1
+	FreshnessCritical = 2
+	FreshnessUnknown  = 3
+)
+
+// FreshnessStateName returns the Nagios name of a freshness state.
+func FreshnessStateName(code int) string {
+	switch code {
+	case FreshnessOK:
+		return "OK"
+	case FreshnessWarning:
+		return "WARNING"
+	case FreshnessCritical:
+		return "CRITICAL"
+	}
+	return "UNKNOWN"
+}
+
+// Freshness is the state of a database's backups against age thresholds.
+type Freshness struct {
+	Engine   string `json:"engine"`
+	Database string `json:"database"`
+	State    int    `json:"state"`
+	// Reason explains a state other than OK, e.g. "last run failed".
+	Reason string `json:"reason,omitempty"`
+	// Age is the time since the latest successful backup; negative when
+	// there is none.
+	Age       time.Duration `json:"age"`
+	SizeBytes int64         `json:"size_bytes"`
+	// Duration is the time the last run took.
+	Duration time.Duration `json:"duration"`
+}
+
+// CheckFreshness rates every database of statuses at now: CRITICAL without
+// a successful backup younger than critical, WARNING past warning or when
+// the last run failed, OK otherwise.
+func CheckFreshness(statuses []state.Status, now time.Time, warning, critical time.Duration) []Freshness {
+	checks := make([]Freshness, 0, len(statuses))
+	for _, s := range statuses {
+		check := Freshness{
+			Engine:    s.Engine,
+			Database:  s.Database,
+			SizeBytes: s.SizeBytes,
+			Duration:  s.CompletedAt.Sub(s.StartedAt),
+			Age:       -1,
+		}
+		success := s.LastSuccessAt
+		if success.IsZero() && s.Status == StatusSuccess {
+			success = s.CompletedAt // recorded before last_success_at
+		}
+		if !success.IsZero() {
+			check.Age = now.Sub(success)
+		}
+		switch {
+		case success.IsZero():
+			check.State, check.Reason = FreshnessCritical, "no successful backup"
+		case critical > 0 && check.Age > critical:
+			check.State, check.Reason = FreshnessCritical, "older than "+critical.String()
+		case warning > 0 && check.Age > warning:
+			check.State, check.Reason = FreshnessWarning, "older than "+warning.String()
+		case s.Status != StatusSuccess:
+			check.State, check.Reason = FreshnessWarning, "last run "+s.Status
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+// WorstFreshness returns the most severe state of checks, UNKNOWN when
+// there are none.
+func WorstFreshness(checks []Freshness) int {
+	if len(checks) == 0 {
+		return FreshnessUnknown
+	}
+	worst := FreshnessOK
+	for _, check := range checks {
+		worst = max(worst, check.State)
+	}
+	return worst
+}
+
+// NagiosReport renders checks as a Nagios plugin output line, the status
+// and a summary of the databases that are not OK followed by age, size and
+// duration perfdata for each database.
+func NagiosReport(checks []Freshness, warning, critical time.Duration) string {
+	worst := WorstFreshness(checks)
+	var problems []string
+	for _, check := range checks {
+		if check.State != FreshnessOK {
+			problems = append(problems, check.Engine+"/"+check.Database+" "+check.Reason)
+		}
+	}
+	var line strings.Builder
+	fmt.Fprintf(&line, "BACKUP %s - ", FreshnessStateName(worst))
+	switch {
+	case len(checks) == 0:
+		line.WriteString("no backup runs recorded")
+	case len(problems) == 0:
+		fmt.Fprintf(&line, "%d databases fresh", len(checks))
+	default:
+		fmt.Fprintf(&line, "%d of %d databases: %s", len(problems), len(checks), strings.Join(problems, ", "))
+	}
+	for i, check := range checks {
+		if i == 0 {
+			line.WriteString(" |")
+		}
+		label := check.Engine + "/" + check.Database
+		age := "U"
+		if check.Age >= 0 {
+			age = fmt.Sprintf("%ds", int64(check.Age.Seconds()))
+		}
+		fmt.Fprintf(&line, " '%s_age'=%s;%s;%s;0 '%s_size'=%dB;;;0 '%s_duration'=%ds;;;0",
+			label, age, nagiosThreshold(warning), nagiosThreshold(critical),
+			label, check.SizeBytes,
+			label, int64(check.Duration.Seconds()))
+	}
+	return line.String()
+}
+
+// nagiosThreshold renders an age threshold in seconds, empty when unset.
+func nagiosThreshold(d time.Duration) string {
+	if d <= 0 {
+		return ""
+	}
+	return strconv.FormatInt(int64(d.Seconds()), 10)
+}
+
+// ZabbixItems returns the trapper items reporting checks for host, keyed
+// bacli.<metric>[<engine>/<database>] with metric one of state, age, size
+// and duration (ages and durations in seconds, age -1 without a backup).
+func ZabbixItems(host string, checks []Freshness) []notify.ZabbixItem {
+	items := make([]notify.ZabbixItem, 0, 4*len(checks))
+	for _, check := range checks {
+		label := check.Engine + "/" + check.Database
+		age := int64(-1)
+		if check.Age >= 0 {
+			age = int64(check.Age.Seconds())
+		}
+		for _, metric := range []struct {
+			name  string
+			value int64
+		}{
+			{"state", int64(check.State)},
+			{"age", age},
+			{"size", check.SizeBytes},
+			{"duration", int64(check.Duration.Seconds())},
+		} {
+			items = append(items, notify.ZabbixItem{
+				Host:  host,
+				Key:   "bacli." + metric.name + "[" + label + "]",
+				Value: strconv.FormatInt(metric.value, 10),
+			})
+		}
+	}
+	return items
+}
@@ -0,0 +1,52 @@
+package operations
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kebairia/backup/internal/state"
+)
+
+func TestCheckFreshness(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	run := func(db, status string, lastSuccess time.Time) state.Status {
+		return state.Status{LastRun: state.LastRun{
+			Engine: "postgres", Database: db, Status: status,
+			StartedAt: now.Add(-2 * time.Hour), CompletedAt: now.Add(-2*time.Hour + 30*time.Second),
+			LastSuccessAt: lastSuccess, SizeBytes: 1024,
+		}}
+	}
+	statuses := []state.Status{
+		run("fresh", StatusSuccess, now.Add(-2*time.Hour)),
+		run("stale", StatusSuccess, now.Add(-30*time.Hour)),
+		run("failed", StatusFailed, now.Add(-3*time.Hour)),
+		run("never", StatusFailed, time.Time{}),
+	}
+	checks := CheckFreshness(statuses, now, 26*time.Hour, 50*time.Hour)
+	want := []int{FreshnessOK, FreshnessWarning, FreshnessWarning, FreshnessCritical}
+	for i, check := range checks {
+		if check.State != want[i] {
+			t.Errorf("%s: state = %s (%s), want %s", check.Database,
+				FreshnessStateName(check.State), check.Reason, FreshnessStateName(want[i]))
+		}
+	}
+	if checks[0].Age != 2*time.Hour || checks[0].Duration != 30*time.Second || checks[3].Age >= 0 {
+		t.Errorf("checks = %+v", checks)
+	}
+	if worst := WorstFreshness(checks); worst != FreshnessCritical {
+		t.Errorf("WorstFreshness() = %d", worst)
+	}
+
+	report := NagiosReport(checks, 26*time.Hour, 50*time.Hour)
+	if !strings.HasPrefix(report, "BACKUP CRITICAL - 3 of 4 databases: ") ||
+		!strings.Contains(report, "| 'postgres/fresh_age'=7200s;93600;180000;0 'postgres/fresh_size'=1024B;;;0 'postgres/fresh_duration'=30s;;;0") ||
+		!strings.Contains(report, "'postgres/never_age'=U;") {
+		t.Errorf("NagiosReport() = %q", report)
+	}
+
+	items := ZabbixItems("db1", checks[:1])
+	if len(items) != 4 || items[1].Key != "bacli.age[postgres/fresh]" || items[1].Value != "7200" || items[1].Host != "db1" {
+		t.Errorf("ZabbixItems() = %+v", items)
+	}
+}
@@ -1,8 +1,13 @@
 package operations
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
 )
 
 func EnsureDirectoryExist(dirPath string) error {
@@ -12,9 +17,62 @@ func EnsureDirectoryExist(dirPath string) error {
 	return nil
 }
 
+// isDir reports whether path is an existing directory.
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
 func RemoveFile(path string) error {
 	if err := os.Remove(path); err != nil {
 		return fmt.Errorf("failed to remove file %q: %w", path, err)
 	}
 	return nil
 }
+
+// WriteJSONAtomic writes v as indented JSON to path through a temporary file
+// that is synced and renamed into place, so a process killed mid-write never
+// leaves a truncated file behind.
+func WriteJSONAtomic(path string, v any) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("create temp file for %q: %w", path, err)
+	}
+	defer os.Remove(tmp.Name()) // no-op after a successful rename
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		return fmt.Errorf("chmod %q: %w", path, err)
+	}
+
+	encoder := json.NewEncoder(tmp)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		tmp.Close()
+		return fmt.Errorf("encode %q: %w", path, err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("sync %q: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("close %q: %w", path, err)
+	}
+	if err := replaceFile(tmp.Name(), path); err != nil {
+		return fmt.Errorf("rename into %q: %w", path, err)
+	}
+	return nil
+}
+
+// fileChecksum returns the SHA-256 of the file at path, as "sha256:<hex>".
+func fileChecksum(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("open %q: %w", path, err)
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", fmt.Errorf("read %q: %w", path, err)
+	}
+	return "sha256:" + hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,175 @@
+package operations
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kebairia/backup/internal/config"
+)
+
+// HistoryFilename is the append-only log of every record written for a
+// database, one JSON document per line. metadata.json mirrors its latest run.
+const HistoryFilename = "history.jsonl"
+
+// ErrNoRestorePoint indicates that a database has no successful backup.
+var ErrNoRestorePoint = errors.New("no successful backup found")
+
+// isCatalogFile reports whether name is bacli bookkeeping (metadata, history,
+// markers and temporary files) rather than a backup artifact.
+func isCatalogFile(name string) bool {
+	return name == MetadataFilename || name == HistoryFilename || strings.HasPrefix(name, ".")
+}
+
+// appendHistory appends m to the history log in dirPath and syncs it.
+func (m *Metadata) appendHistory(dirPath string) error {
+	line, err := json.Marshal(m)
+	if err != nil {
+		return fmt.Errorf("encode history entry: %w", err)
+	}
+	path := filepath.Join(dirPath, HistoryFilename)
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("open history %q: %w", path, err)
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return fmt.Errorf("append history %q: %w", path, err)
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return fmt.Errorf("sync history %q: %w", path, err)
+	}
+	return file.Close()
+}
+
+// LoadHistory returns every run recorded in dirPath, oldest first.
+//
+// A record rewritten later (e.g. after verification) appears once, with its
+// last written content. A truncated final line left by a crash is ignored.
+// Directories written before the history log existed yield their
+// metadata.json record.
+func LoadHistory(dirPath string) ([]Metadata, error) {
+	path := filepath.Join(dirPath, HistoryFilename)
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		var record Metadata
+		if err := record.Load(filepath.Join(dirPath, MetadataFilename)); err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil, nil
+			}
+			return nil, err
+		}
+		return []Metadata{record}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("open history %q: %w", path, err)
+	}
+	defer file.Close()
+
+	var (
+		records []Metadata
+		index   = make(map[string]int) // run key -> position in records
+		pending error
+	)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if pending != nil {
+			// only the final line may be damaged
+			return nil, pending
+		}
+		var record Metadata
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			pending = fmt.Errorf("decode history %q line %d: %w", path, line, err)
+			continue
+		}
+		key := record.StartedAt.UTC().String()
+		if i, ok := index[key]; ok {
+			records[i] = record
+			continue
+		}
+		index[key] = len(records)
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read history %q: %w", path, err)
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].StartedAt.Before(records[j].StartedAt)
+	})
+	return records, nil
+}
+
+// LoadLatestRestorable returns the most recent successful run recorded in
+// dirPath, skipping failed runs that came after it.
+func LoadLatestRestorable(dirPath string) (Metadata, error) {
+	records, err := LoadHistory(dirPath)
+	if err != nil {
+		return Metadata{}, err
+	}
+	for i := len(records) - 1; i >= 0; i-- {
+		if records[i].Restorable() {
+			return records[i], nil
+		}
+	}
+	return Metadata{}, fmt.Errorf("%w in %s", ErrNoRestorePoint, dirPath)
+}
+
+// LoadRestorePoint returns the successful run recorded in dirPath that
+// started at at, compared to the second.
+func LoadRestorePoint(dirPath string, at time.Time) (Metadata, error) {
+	records, err := LoadHistory(dirPath)
+	if err != nil {
+		return Metadata{}, err
+	}
+	at = at.Truncate(time.Second)
+	for i := len(records) - 1; i >= 0; i-- {
+		record := records[i]
+		if record.StartedAt.Truncate(time.Second).Equal(at) && record.Restorable() {
+			return record, nil
+		}
+	}
+	return Metadata{}, fmt.Errorf("%w started at %s in %s", ErrNoRestorePoint, at.Format(time.RFC3339), dirPath)
+}
+
+// History returns the recorded runs of every database in the catalog of the
+// given configuration, newest first. database narrows the result to one
+// database and limit, when positive, keeps that many runs per database.
+// It reads the local backup directory only and does not contact Vault.
+func History(configPath, database string, limit int) ([]Metadata, error) {
+	var cfg config.Config
+	if err := cfg.Load(configPath); err != nil {
+		return nil, err
+	}
+	entries, err := LoadCatalog(cfg.Backup.Directory)
+	if err != nil {
+		return nil, err
+	}
+
+	var runs []Metadata
+	for _, entry := range entries {
+		if database != "" && entry.Record.Database != database {
+			continue
+		}
+		records, err := LoadHistory(filepath.Dir(entry.Path))
+		if err != nil {
+			return nil, err
+		}
+		if limit > 0 && len(records) > limit {
+			records = records[len(records)-limit:]
+		}
+		runs = append(runs, records...)
+	}
+	sort.SliceStable(runs, func(i, j int) bool {
+		return runs[i].StartedAt.After(runs[j].StartedAt)
+	})
+	return runs, nil
+}
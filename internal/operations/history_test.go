@@ -0,0 +1,78 @@
+package operations
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadLatestRestorable(t *testing.T) {
+	dir := t.TempDir()
+	base := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+	ok := Metadata{Engine: "postgres", Database: "app", Status: StatusSuccess,
+		FilePath: filepath.Join(dir, "a.dump"), StartedAt: base}
+	failed := Metadata{Engine: "postgres", Database: "app", Status: StatusFailed,
+		FilePath: "N/A", StartedAt: base.Add(time.Minute)}
+	for _, record := range []Metadata{ok, failed} {
+		if err := record.Write(dir); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// rewriting a run (e.g. after verification) must not duplicate it
+	ok.RemotePath = "s3://bucket/a.dump"
+	if err := ok.Write(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := LoadHistory(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("got %d history entries, want 2", len(records))
+	}
+
+	latest, err := LoadLatestRestorable(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest.FilePath != ok.FilePath || latest.RemotePath != ok.RemotePath {
+		t.Fatalf("got %+v, want the rewritten successful run", latest)
+	}
+
+	// metadata.json keeps showing the newest run
+	var current Metadata
+	if err := current.Load(filepath.Join(dir, MetadataFilename)); err != nil {
+		t.Fatal(err)
+	}
+	if current.Status != StatusFailed {
+		t.Fatalf("metadata.json status = %q, want %q", current.Status, StatusFailed)
+	}
+}
+
+func TestLoadHistoryFallsBackToMetadata(t *testing.T) {
+	dir := t.TempDir()
+	record := Metadata{Database: "app", Status: StatusFailed, StartedAt: time.Now()}
+	if err := WriteJSONAtomic(filepath.Join(dir, MetadataFilename), record); err != nil {
+		t.Fatal(err)
+	}
+	records, err := LoadHistory(dir)
+	if err != nil || len(records) != 1 {
+		t.Fatalf("got (%d, %v), want one record", len(records), err)
+	}
+	if _, err := LoadLatestRestorable(dir); !errors.Is(err, ErrNoRestorePoint) {
+		t.Fatalf("got %v, want ErrNoRestorePoint", err)
+	}
+
+	// a truncated final line is ignored
+	if err := os.WriteFile(filepath.Join(dir, HistoryFilename), []byte(`{"database":"app","status":"success","file_path":"x","started_at":"2026-01-01T00:00:00Z"}
+{"database":`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadLatestRestorable(dir); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,415 @@
+package operations
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/kebairia/backup/internal/database"
+	"github.com/kebairia/backup/internal/encryption"
+	"github.com/kebairia/backup/internal/storage"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Artifact formats detected by Inspect.
+const (
+	FormatPgCustom       = "pg_dump custom"
+	FormatPgPlain        = "pg_dump plain"
+	FormatPgTar          = "pg_dump tar"
+	FormatPgDirectory    = "pg_dump directory"
+	FormatPgNative       = "postgres native"
+	FormatMongoArchive   = "mongodump archive"
+	FormatMongoDirectory = "mongodump directory"
+	FormatMySQLDump      = "mysqldump"
+	FormatXtraBackup     = "xtrabackup"
+	FormatRedisRDB       = "redis rdb"
+	FormatUnknown        = "unknown"
+)
+
+// Checksum states of an Inspection.
+const (
+	ChecksumOK       = "ok"
+	ChecksumMismatch = "mismatch"
+	// ChecksumUnknown means no catalog record holds a checksum to compare
+	// with, e.g. for directory artifacts.
+	ChecksumUnknown = "unknown"
+)
+
+// ErrChecksumMismatch indicates that an inspected artifact is not the one
+// its catalog record describes: it is damaged or was altered.
+var ErrChecksumMismatch = errors.New("artifact checksum does not match the catalog")
+
+var (
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	gzipMagic = []byte{0x1f, 0x8b}
+)
+
+// Inspection is everything known about one artifact; see Inspect.
+type Inspection struct {
+	Artifact  string `json:"artifact"` // the path or URI inspected
+	SizeBytes int64  `json:"size_bytes"`
+	Directory bool   `json:"directory,omitempty"`
+	Encrypted bool   `json:"encrypted,omitempty"`
+	// Compression is "zstd" (bacli) or "gzip" (the dump tool); empty for
+	// uncompressed artifacts.
+	Compression string `json:"compression,omitempty"`
+	Format      string `json:"format"`
+	Engine      string `json:"engine,omitempty"`
+	// Checksum is the SHA-256 of a file artifact as found; ChecksumStatus
+	// compares it to the catalog's.
+	Checksum       string    `json:"checksum,omitempty"`
+	ChecksumStatus string    `json:"checksum_status"`
+	Record         *Metadata `json:"record,omitempty"`
+	// RestoreCommand restores the artifact with the client tools, without
+	// bacli; empty for encrypted artifacts and unknown formats.
+	RestoreCommand string `json:"restore_command,omitempty"`
+	// BacliCommand restores the artifact's backup with bacli, when the
+	// catalog knows it.
+	BacliCommand string `json:"bacli_command,omitempty"`
+	// Note explains what could not be inspected, e.g. an encrypted
+	// artifact without backup.encryption.
+	Note string `json:"note,omitempty"`
+}
+
+// Inspect describes the artifact at target: a local file or directory, or
+// an object on storage as "s3://bucket/key" or "rclone:remote/key", which
+// is downloaded first. The artifact is matched against the catalog by path,
+// storage URI or checksum, its format is detected from its content
+// (decrypted with backup.encryption if needed), and the commands restoring
+// it are estimated. Nothing is restored. A damaged artifact is described
+// and reported as ErrChecksumMismatch.
+func Inspect(ctx context.Context, configPath, target string) (Inspection, error) {
+	operator, err := NewOperator(ctx, configPath)
+	if err != nil {
+		return Inspection{}, err
+	}
+	return operator.inspect(target)
+}
+
+func (operator *Operator) inspect(target string) (Inspection, error) {
+	in := Inspection{Artifact: target, Format: FormatUnknown, ChecksumStatus: ChecksumUnknown}
+	local, cleanup, err := operator.inspectedArtifact(target)
+	if err != nil {
+		return in, err
+	}
+	defer cleanup()
+	info, err := os.Stat(local)
+	if err != nil {
+		return in, fmt.Errorf("inspect %s: %w", target, err)
+	}
+	in.Directory = info.IsDir()
+	in.SizeBytes = artifactSize(local)
+	if !in.Directory {
+		if in.Checksum, err = fileChecksum(local); err != nil {
+			return in, err
+		}
+	}
+
+	in.Record, err = operator.findRecord(target, in.Checksum)
+	if err != nil {
+		return in, err
+	}
+	if in.Record != nil && in.Record.Checksum != "" && !in.Directory {
+		in.ChecksumStatus = ChecksumMismatch
+		if in.Record.Checksum == in.Checksum {
+			in.ChecksumStatus = ChecksumOK
+		}
+	}
+
+	if in.Directory {
+		err = detectDirectory(&in, local)
+	} else {
+		err = operator.detectFile(&in, local)
+	}
+	if err != nil {
+		return in, err
+	}
+	if in.Engine == "" && in.Record != nil {
+		in.Engine = in.Record.Engine
+	}
+	in.RestoreCommand = restoreCommand(in, target)
+	if in.Record != nil {
+		in.BacliCommand = fmt.Sprintf("bacli restore --engine %s --database %s --at %s",
+			in.Record.Engine, shellQuote(in.Record.Database), in.Record.StartedAt.UTC().Format(time.RFC3339))
+	}
+	if in.ChecksumStatus == ChecksumMismatch {
+		return in, fmt.Errorf("%w: %s has %s, the catalog records %s",
+			ErrChecksumMismatch, target, in.Checksum, in.Record.Checksum)
+	}
+	return in, nil
+}
+
+// inspectedArtifact returns a local path to target, downloading it when it
+// is a storage URI. The returned func removes the download.
+func (operator *Operator) inspectedArtifact(target string) (string, func(), error) {
+	noop := func() {}
+	cfg := operator.config.Storage
+	var key string
+	switch {
+	case strings.HasPrefix(target, "s3://"):
+		u, err := url.Parse(target)
+		if err != nil {
+			return "", nil, fmt.Errorf("parse %q: %w", target, err)
+		}
+		cfg.Type = storage.TypeS3
+		cfg.S3.Bucket, cfg.S3.Prefix = u.Host, ""
+		key = strings.Trim(u.Path, "/")
+	case strings.HasPrefix(target, "rclone:"):
+		remote := strings.TrimPrefix(target, "rclone:")
+		cfg.Type = storage.TypeRclone
+		cfg.Rclone.Remote, key = path.Dir(remote), path.Base(remote)
+	default:
+		return target, noop, nil
+	}
+	backend, err := buildStorage(operator.ctx, cfg, operator.vaultClient)
+	if err != nil {
+		return "", nil, err
+	}
+	listed, err := backend.List(operator.ctx, key)
+	if err != nil {
+		return "", nil, fmt.Errorf("list %s: %w", target, err)
+	}
+	objects := artifactObjects(listed, key)
+	if len(objects) == 0 {
+		return "", nil, fmt.Errorf("%w: %s", storage.ErrNotFound, target)
+	}
+	scratch, err := os.MkdirTemp("", "bacli-inspect-*")
+	if err != nil {
+		return "", nil, fmt.Errorf("create download directory: %w", err)
+	}
+	cleanup := func() { os.RemoveAll(scratch) }
+	local := filepath.Join(scratch, path.Base(key))
+	if err := operator.downloadObjects(backend, objects, key, local); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	return local, cleanup, nil
+}
+
+// findRecord returns the newest catalog record of the artifact at target:
+// the record of that local path or storage URI, or else the record with
+// that checksum, e.g. for a copy handed over outside bacli. It returns nil
+// when the catalog does not know the artifact.
+func (operator *Operator) findRecord(target, checksum string) (*Metadata, error) {
+	entries, err := LoadCatalog(operator.config.Backup.Directory)
+	if err != nil {
+		return nil, err
+	}
+	abs, _ := filepath.Abs(target)
+	var byChecksum *Metadata
+	for _, entry := range entries {
+		records, err := LoadHistory(filepath.Dir(entry.Path))
+		if err != nil {
+			return nil, err
+		}
+		for i := len(records) - 1; i >= 0; i-- {
+			record := records[i]
+			local, _ := filepath.Abs(record.FilePath)
+			if local == abs || (record.RemotePath != "" && operator.artifactURI(record) == target) {
+				return &record, nil
+			}
+			if byChecksum == nil && checksum != "" && record.Checksum == checksum {
+				byChecksum = &record
+			}
+		}
+	}
+	return byChecksum, nil
+}
+
+// detectFile detects the format of the file artifact at path from its
+// first bytes, decrypting and decompressing them as needed.
+func (operator *Operator) detectFile(in *Inspection, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open %s: %w", path, err)
+	}
+	defer file.Close()
+	r := bufio.NewReader(file)
+
+	head, _ := r.Peek(16)
+	if encryption.Encrypted(head) {
+		in.Encrypted = true
+		if operator.encryption == nil {
+			in.Note = "encrypted, and backup.encryption is not configured to decrypt it"
+			return nil
+		}
+		plain, w := io.Pipe()
+		go func() {
+			w.CloseWithError(encryption.Decrypt(operator.ctx, operator.encryption, r, w))
+		}()
+		// unblocks the decryption once the head is read
+		defer plain.Close()
+		r = bufio.NewReader(plain)
+		head, _ = r.Peek(len(zstdMagic))
+	}
+	switch {
+	case bytes.HasPrefix(head, zstdMagic):
+		in.Compression = "zstd"
+		decoder, err := zstd.NewReader(r)
+		if err != nil {
+			return fmt.Errorf("zstd.NewReader: %w", err)
+		}
+		defer decoder.Close()
+		r = bufio.NewReader(decoder)
+	case bytes.HasPrefix(head, gzipMagic):
+		in.Compression = "gzip"
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return fmt.Errorf("inspect %s: gzip: %w", path, err)
+		}
+		defer gz.Close()
+		r = bufio.NewReader(gz)
+	}
+	head, err = r.Peek(512)
+	if err != nil && !errors.Is(err, io.EOF) {
+		// e.g. a truncated artifact, or one encrypted with another key
+		in.Note = "content unreadable: " + err.Error()
+		return nil
+	}
+	in.Format, in.Engine = fileFormat(head)
+	return nil
+}
+
+// fileFormat returns the format and engine of a plaintext artifact opening
+// with head.
+func fileFormat(head []byte) (string, string) {
+	switch {
+	case bytes.HasPrefix(head, []byte("PGDMP")):
+		return FormatPgCustom, database.EnginePostgres
+	case len(head) >= 4 && binary.LittleEndian.Uint32(head) == database.MongoArchiveMagic:
+		return FormatMongoArchive, database.EngineMongoDB
+	case len(head) >= 262 && string(head[257:262]) == "ustar":
+		// pg_dump opens its tar archives with the table of contents, native
+		// COPY archives hold table data and their manifest
+		switch name := string(bytes.TrimRight(head[:100], "\x00")); {
+		case name == "toc.dat":
+			return FormatPgTar, database.EnginePostgres
+		case name == "manifest.json" || strings.HasPrefix(name, "data/"):
+			return FormatPgNative, database.EnginePostgres
+		}
+	case bytes.HasPrefix(head, []byte("REDIS")):
+		return FormatRedisRDB, "redis"
+	case bytes.Contains(head, []byte("PostgreSQL database dump")):
+		return FormatPgPlain, database.EnginePostgres
+	case bytes.Contains(head, []byte("MySQL dump")) || bytes.Contains(head, []byte("MariaDB dump")):
+		return FormatMySQLDump, "mysql"
+	}
+	return FormatUnknown, ""
+}
+
+// detectDirectory detects the format of the directory artifact at dir from
+// the names of its files.
+func detectDirectory(in *Inspection, dir string) error {
+	var names []string
+	err := filepath.WalkDir(dir, func(file string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		name, ok := strings.CutSuffix(d.Name(), encryption.Suffix)
+		in.Encrypted = in.Encrypted || ok
+		names = append(names, name)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("inspect %s: %w", dir, err)
+	}
+	has := func(match func(name string) bool) bool {
+		for _, name := range names {
+			if match(name) {
+				return true
+			}
+		}
+		return false
+	}
+	gzipped := has(func(name string) bool { return strings.HasSuffix(name, ".gz") })
+	switch {
+	case has(func(name string) bool { return name == "toc.dat" }):
+		in.Format, in.Engine = FormatPgDirectory, database.EnginePostgres
+	case has(func(name string) bool { return strings.HasSuffix(strings.TrimSuffix(name, ".gz"), ".bson") }):
+		in.Format, in.Engine = FormatMongoDirectory, database.EngineMongoDB
+	case has(func(name string) bool { return name == "xtrabackup_checkpoints" }):
+		in.Format, in.Engine = FormatXtraBackup, "mysql"
+		return nil
+	default:
+		return nil
+	}
+	if gzipped {
+		in.Compression = "gzip"
+	}
+	return nil
+}
+
+// restoreCommand estimates the client tool command restoring the artifact
+// inspected as in, found at target, into the database it was dumped from.
+func restoreCommand(in Inspection, target string) string {
+	if in.Encrypted {
+		return "" // only bacli can decrypt it
+	}
+	db := "<database>"
+	if in.Record != nil {
+		db = shellQuote(in.Record.Database)
+	}
+	file := shellQuote(target)
+	// the tools read file, or stdin when it must be decompressed first
+	pipe, source := "", " "+file
+	switch {
+	case in.Compression == "zstd":
+		pipe, source = "zstd -dc "+file+" | ", ""
+	case in.Compression == "gzip" && in.Engine != database.EngineMongoDB && !in.Directory:
+		pipe, source = "gzip -dc "+file+" | ", ""
+	}
+	gzipFlag := ""
+	if in.Compression == "gzip" && in.Engine == database.EngineMongoDB {
+		gzipFlag = " --gzip"
+	}
+	switch in.Format {
+	case FormatPgCustom:
+		return pipe + "pg_restore --clean --if-exists -d " + db + source
+	case FormatPgTar:
+		return pipe + "pg_restore -F t --clean --if-exists -d " + db + source
+	case FormatPgDirectory:
+		return "pg_restore --clean --if-exists -j 4 -d " + db + " " + file
+	case FormatPgPlain:
+		if source != "" {
+			source = " -f" + source
+		}
+		return pipe + "psql -d " + db + source
+	case FormatMongoArchive:
+		if source != "" {
+			source = "=" + file
+		}
+		return pipe + "mongorestore --drop" + gzipFlag + " --archive" + source
+	case FormatMongoDirectory:
+		return "mongorestore --drop" + gzipFlag + " --dir=" + file
+	case FormatMySQLDump:
+		if source != "" {
+			source = " <" + source
+		}
+		return pipe + "mysql " + db + source
+	}
+	return ""
+}
+
+// shellSafe matches words the shell takes literally.
+var shellSafe = regexp.MustCompile(`^[A-Za-z0-9_./:=@%+-]+$`)
+
+// shellQuote quotes s for a POSIX shell, unless it needs no quoting.
+func shellQuote(s string) string {
+	if shellSafe.MatchString(s) {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
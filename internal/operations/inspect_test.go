@@ -0,0 +1,69 @@
+package operations
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestInspect(t *testing.T) {
+	operator, db := flowOperator(t, nil)
+	db.content = "PGDMP\x01\x0e\x00 custom dump of billing"
+	record, err := operator.backupDatabase(context.Background(), db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	in, err := operator.inspect(record.FilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if in.Format != FormatPgCustom || in.Compression != "zstd" || in.ChecksumStatus != ChecksumOK || in.Record == nil {
+		t.Fatalf("inspect() = %+v, want a zstd-compressed custom dump matching the catalog", in)
+	}
+	if want := "zstd -dc " + record.FilePath + " | pg_restore --clean --if-exists -d billing"; in.RestoreCommand != want {
+		t.Errorf("RestoreCommand = %q, want %q", in.RestoreCommand, want)
+	}
+
+	// a copy handed over elsewhere is recognized by its checksum
+	data, err := os.ReadFile(record.FilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handed := filepath.Join(t.TempDir(), "mystery dump")
+	if err := os.WriteFile(handed, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if in, err := operator.inspect(handed); err != nil || in.Record == nil || !strings.Contains(in.RestoreCommand, "'"+handed+"'") {
+		t.Errorf("inspect(copy) = %+v, %v", in, err)
+	}
+
+	if err := os.WriteFile(record.FilePath, append(data, 0), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if in, err := operator.inspect(record.FilePath); !errors.Is(err, ErrChecksumMismatch) || in.Note == "" {
+		t.Errorf("inspect(damaged) = %+v, %v, want ErrChecksumMismatch", in, err)
+	}
+}
+
+func TestFileFormat(t *testing.T) {
+	tar := make([]byte, 512)
+	copy(tar, "toc.dat")
+	copy(tar[257:], "ustar")
+	for _, tc := range []struct {
+		head, format string
+	}{
+		{"--\n-- PostgreSQL database dump\n--\n", FormatPgPlain},
+		{"\x6d\xe2\x99\x81\x01\x00", FormatMongoArchive},
+		{string(tar), FormatPgTar},
+		{"-- MySQL dump 10.13  Distrib 8.0.36", FormatMySQLDump},
+		{"hello", FormatUnknown},
+	} {
+		if format, _ := fileFormat([]byte(tc.head)); format != tc.format {
+			t.Errorf("fileFormat(%q) = %q, want %q", tc.head[:5], format, tc.format)
+		}
+	}
+}
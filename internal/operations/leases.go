@@ -0,0 +1,76 @@
+package operations
+
+import (
+	"context"
+	"sync"
+
+	"github.com/kebairia/backup/internal/database"
+)
+
+// leaseCounter counts, by Vault lease, the databases of a run that have yet
+// to be backed up with its credentials. Several databases share a lease when
+// they belong to the same instance, or when credentials are reused.
+type leaseCounter struct {
+	mu    sync.Mutex
+	users map[string]int
+}
+
+// hold counts the leases of databases.
+func (l *leaseCounter) hold(databases []database.Database) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, db := range databases {
+		if id := leaseID(db); id != "" {
+			if l.users == nil {
+				l.users = make(map[string]int)
+			}
+			l.users[id]++
+		}
+	}
+}
+
+// release uncounts the lease of db, and returns it once no other database
+// holds it.
+func (l *leaseCounter) release(db database.Database) (id string, last bool) {
+	id = leaseID(db)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if id == "" || l.users[id] == 0 {
+		return "", false
+	}
+	l.users[id]--
+	if l.users[id] > 0 {
+		return id, false
+	}
+	delete(l.users, id)
+	return id, true
+}
+
+// leaseID returns the Vault lease of the credentials of db, if any.
+func leaseID(db database.Database) string {
+	if holder, ok := db.(database.LeaseHolder); ok {
+		return holder.GetLeaseID()
+	}
+	return ""
+}
+
+// releaseLease revokes the lease of the credentials of db once every
+// database using it is backed up, so that its database user does not linger
+// until the lease expires (unless vault.credentials.keep_leases). Failures
+// are logged: the backup is done either way.
+func (operator *Operator) releaseLease(db database.Database) {
+	id, last := operator.leases.release(db)
+	if !last || operator.vaultClient == nil || operator.config.Vault.Credentials.KeepLeases {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(operator.ctx), revokeTimeout)
+	defer cancel()
+	if err := operator.vaultClient.RevokeLease(ctx, id); err != nil {
+		operator.log.Warn("failed to revoke credential lease",
+			"database", db.GetName(),
+			"error", err.Error(),
+		)
+		return
+	}
+	operator.log.Info("credential lease revoked", "database", db.GetName())
+}
@@ -0,0 +1,74 @@
+package operations
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kebairia/backup/internal/database"
+)
+
+// leasedDB is a namedDB with dynamic credentials.
+type leasedDB struct {
+	namedDB
+	lease string
+}
+
+func (l leasedDB) GetLeaseID() string { return l.lease }
+
+func TestLeaseCounter(t *testing.T) {
+	billing := leasedDB{namedDB{name: "billing"}, "lease/main"}
+	users := leasedDB{namedDB{name: "users"}, "lease/main"}
+	events := leasedDB{namedDB{name: "events"}, "lease/events"}
+	static := namedDB{name: "static"}
+
+	var leases leaseCounter
+	leases.hold([]database.Database{billing, users, events, static})
+	if id, last := leases.release(billing); id != "lease/main" || last {
+		t.Errorf("release(billing) = %q, %v, want the main lease still held by users", id, last)
+	}
+	if id, last := leases.release(events); id != "lease/events" || !last {
+		t.Errorf("release(events) = %q, %v, want its lease released", id, last)
+	}
+	if id, last := leases.release(users); id != "lease/main" || !last {
+		t.Errorf("release(users) = %q, %v, want the main lease released", id, last)
+	}
+	if _, last := leases.release(static); last {
+		t.Error("release(static) released a lease")
+	}
+	if _, last := leases.release(users); last {
+		t.Error("second release(users) released the main lease again")
+	}
+}
+
+// fakeVault records the leases revoked through it.
+type fakeVault struct {
+	Vault
+	revoked []string
+}
+
+func (f *fakeVault) RevokeLease(_ context.Context, id string) error {
+	f.revoked = append(f.revoked, id)
+	return nil
+}
+
+func TestReleaseLeaseRevokes(t *testing.T) {
+	vault := &fakeVault{}
+	operator := &Operator{ctx: context.Background(), log: nopLogger{}, vaultClient: vault}
+	billing := leasedDB{namedDB{name: "billing"}, "lease/main"}
+	users := leasedDB{namedDB{name: "users"}, "lease/main"}
+	operator.leases.hold([]database.Database{billing, users})
+
+	operator.releaseLease(billing)
+	if len(vault.revoked) != 0 {
+		t.Fatalf("revoked %v while users still holds the lease", vault.revoked)
+	}
+	operator.releaseLease(users)
+	if len(vault.revoked) != 1 || vault.revoked[0] != "lease/main" {
+		t.Errorf("revoked = %v, want [lease/main]", vault.revoked)
+	}
+
+	// without Vault there is nothing to revoke
+	operator = &Operator{ctx: context.Background(), log: nopLogger{}}
+	operator.leases.hold([]database.Database{billing})
+	operator.releaseLease(billing)
+}
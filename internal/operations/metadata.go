@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/kebairia/backup/internal/database"
+	"github.com/kebairia/backup/internal/redact"
 )
 
 const (
@@ -22,12 +23,80 @@ type Metadata struct {
 	Engine      string        `json:"engine"`
 	Database    string        `json:"database"`
 	FilePath    string        `json:"file_path"`
+	RemotePath  string        `json:"remote_path,omitempty"`
 	Status      string        `json:"status"`
 	Error       string        `json:"error,omitempty"`
 	StartedAt   time.Time     `json:"started_at"`
 	CompletedAt time.Time     `json:"completed_at"`
 	Duration    time.Duration `json:"duration_ms"`
 	SizeBytes   int64         `json:"size_bytes"`
+	// Checksum is the SHA-256 of a file artifact as stored ("sha256:<hex>"),
+	// compressed and encrypted; see Audit.
+	Checksum string `json:"checksum,omitempty"`
+	// Signature is the detached signature of the checksum, stored next to
+	// the artifact; see package signing.
+	Signature string `json:"signature,omitempty"`
+	// Encryption names the key that wrapped the artifact's data key, e.g.
+	// "vault-transit:transit/bacli". Empty for artifacts stored in clear.
+	Encryption string `json:"encryption,omitempty"`
+	// EstimatedBytes is the source size reported by the engine before the dump.
+	EstimatedBytes int64 `json:"estimated_bytes,omitempty"`
+	// Labels classify the backup (env, team, compliance tier); they are also
+	// applied as object tags by storage backends that support them.
+	Labels map[string]string `json:"labels,omitempty"`
+	// Base is when the backup an incremental backup applies on started
+	// (its parent in the chain, see Chains); zero for full backups.
+	Base time.Time `json:"base,omitzero"`
+	// RunID is the backup run that produced the record (see RunManifest).
+	RunID string `json:"run_id,omitempty"`
+	// Stderr is the tail of the client tools' stderr when the dump failed.
+	Stderr string `json:"stderr,omitempty"`
+	// StderrLog is the full stderr of the dump (backup.stderr.log).
+	StderrLog string `json:"stderr_log,omitempty"`
+	// CleanedUp lists partial artifacts removed after a failure.
+	CleanedUp []string `json:"cleaned_up,omitempty"`
+	// PrunedAt is when retention removed the last copy of the artifact.
+	PrunedAt time.Time `json:"pruned_at,omitzero"`
+	// Snapshot is the chunk repository snapshot holding the artifact, in
+	// place of a copy on the storage backend.
+	Snapshot string `json:"snapshot,omitempty"`
+	// Backend is the storage RemotePath was moved to by `bacli
+	// migrate-storage`, as given there (e.g. "s3://bucket/prefix"); empty
+	// while the copy is on the configured storage backend.
+	Backend string `json:"backend,omitempty"`
+	// Replicas records the copies on storage.replicas, by replica name.
+	Replicas map[string]Replication `json:"replicas,omitempty"`
+	// ServerVersion is the version of the database server dumped, and
+	// DumpTool the client tool that dumped it, so that restores can warn
+	// about incompatible tools.
+	ServerVersion string         `json:"server_version,omitempty"`
+	DumpTool      *database.Tool `json:"dump_tool,omitempty"`
+
+	System       *SystemInfo    `json:"system,omitempty"`
+	Check        *ArtifactCheck `json:"check,omitempty"`
+	Verification *Verification  `json:"verification,omitempty"`
+}
+
+// ArtifactCheck records the engine-level check run right after a backup.
+type ArtifactCheck struct {
+	Check     string        `json:"check"` // e.g. "mongorestore --dryRun"
+	Status    string        `json:"status"`
+	Error     string        `json:"error,omitempty"`
+	CheckedAt time.Time     `json:"checked_at"`
+	Duration  time.Duration `json:"duration_ms"`
+}
+
+// Verification records the outcome of the last verification of a backup.
+type Verification struct {
+	Deep           bool             `json:"deep"`
+	Signature      bool             `json:"signature,omitempty"` // the signature was verified
+	Status         string           `json:"status"`
+	Error          string           `json:"error,omitempty"`
+	VerifiedAt     time.Time        `json:"verified_at"`
+	Duration       time.Duration    `json:"duration_ms"`
+	TargetHost     string           `json:"target_host,omitempty"`
+	TargetDatabase string           `json:"target_database,omitempty"`
+	Counts         map[string]int64 `json:"counts,omitempty"`
 }
 
 func NewMetadata(
@@ -39,26 +108,54 @@ func NewMetadata(
 ) *Metadata {
 	status := StatusSuccess
 	var msg string
-	var fileSize int64
 	if err != nil {
 		status = StatusFailed
 		msg = err.Error()
 	}
-
-	if info, statErr := os.Stat(filePath); statErr == nil {
-		fileSize = info.Size()
-	}
 	return &Metadata{
 		Engine:      db.GetEngine(),
 		Database:    db.GetName(),
 		FilePath:    filePath,
 		Status:      status,
 		Error:       msg,
+		Stderr:      stderrOf(err),
 		StartedAt:   startedAt,
 		CompletedAt: completedAt,
 		Duration:    time.Since(startedAt),
-		SizeBytes:   fileSize,
+		SizeBytes:   artifactSize(filePath),
+	}
+}
+
+// artifactSize returns the size of a file artifact, or the total size of the
+// files in a directory artifact. Missing artifacts have size 0.
+func artifactSize(path string) int64 {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+	if info.IsDir() {
+		size, _ := dirSize(path)
+		return size
 	}
+	return info.Size()
+}
+
+// redact removes credentials from the messages of m.
+func (m *Metadata) redact() {
+	m.Error = redact.String(m.Error)
+	m.Stderr = redact.String(m.Stderr)
+	if m.Check != nil {
+		m.Check.Error = redact.String(m.Check.Error)
+	}
+	if m.Verification != nil {
+		m.Verification.Error = redact.String(m.Verification.Error)
+	}
+}
+
+// Restorable reports whether the record describes a complete, usable artifact.
+// Failed and partial backups are never valid restore points.
+func (m *Metadata) Restorable() bool {
+	return m.Status == StatusSuccess && m.FilePath != "" && m.FilePath != "N/A" && m.PrunedAt.IsZero()
 }
 
 // metadata file
@@ -84,8 +181,14 @@ func (m *Metadata) Load(filePath string) error {
 	return nil
 }
 
-// Write metadata file
+// Write appends the record to the database's history log and refreshes
+// metadata.json (written atomically, see WriteJSONAtomic) unless it already
+// holds a newer run.
+//
+// Credentials in the error and stderr fields are redacted first.
 func (m *Metadata) Write(dirPath string) error {
+	m.redact()
+
 	// Build full path to metadata file
 	filePath := filepath.Join(dirPath, MetadataFilename)
 
@@ -94,18 +197,16 @@ func (m *Metadata) Write(dirPath string) error {
 		return fmt.Errorf("ensure metadata directory %q: %w", dirPath, err)
 	}
 
-	// Create the json file
-	jsonFile, err := os.Create(filePath)
-	if err != nil {
-		return fmt.Errorf("create metadata file %q: %w", filePath, err)
+	if err := m.appendHistory(dirPath); err != nil {
+		return err
 	}
-	defer jsonFile.Close()
 
-	encoder := json.NewEncoder(jsonFile)
-	encoder.SetIndent("", "  ")
-
-	if err := encoder.Encode(m); err != nil {
-		return fmt.Errorf("encode metadata JSON: %w", err)
+	var latest Metadata
+	if err := latest.Load(filePath); err == nil && latest.StartedAt.After(m.StartedAt) {
+		return nil
+	}
+	if err := WriteJSONAtomic(filePath, m); err != nil {
+		return fmt.Errorf("write metadata: %w", err)
 	}
 	return nil
 }
@@ -0,0 +1,163 @@
+package operations
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kebairia/backup/internal/storage"
+)
+
+// MigrateStorage copies every artifact and metadata file from one storage
+// backend to another, verifies each copy, and points the catalog at the new
+// location.
+//
+// Backends are given as "local" (the backup directory), "local:/path",
+// "remote" (the configured storage backend), "s3://bucket/prefix" (the
+// configured S3 settings with another bucket and prefix) or
+// "rclone:name:path" (the configured rclone settings with another remote).
+func MigrateStorage(ctx context.Context, configPath, from, to string) error {
+	operator, err := NewOperator(ctx, configPath)
+	if err != nil {
+		return err
+	}
+	log := operator.log
+
+	source, err := operator.backendFromURI(from)
+	if err != nil {
+		return fmt.Errorf("source backend: %w", err)
+	}
+	target, err := operator.backendFromURI(to)
+	if err != nil {
+		return fmt.Errorf("target backend: %w", err)
+	}
+
+	objects, err := source.List(operator.ctx, "")
+	if err != nil {
+		return fmt.Errorf("list source: %w", err)
+	}
+
+	scratch, err := os.MkdirTemp("", "bacli-migrate-*")
+	if err != nil {
+		return fmt.Errorf("create scratch directory: %w", err)
+	}
+	defer os.RemoveAll(scratch)
+
+	var copied int64
+	for i, object := range objects {
+		localPath := filepath.Join(scratch, filepath.FromSlash(object.Key))
+		if err := source.Download(operator.ctx, object.Key, localPath); err != nil {
+			return fmt.Errorf("download %s: %w", object.Key, err)
+		}
+		if err := target.Upload(operator.ctx, localPath, object.Key); err != nil {
+			return fmt.Errorf("upload %s: %w", object.Key, err)
+		}
+		if err := operator.verifyObject(target, object); err != nil {
+			return err
+		}
+		_ = os.Remove(localPath)
+
+		copied += object.Size
+		log.Info("object migrated",
+			"key", object.Key,
+			"size_bytes", object.Size,
+			"progress", fmt.Sprintf("%d/%d", i+1, len(objects)),
+		)
+	}
+
+	if err := operator.relocateCatalog(to); err != nil {
+		return err
+	}
+
+	log.Info("storage migration completed",
+		"from", from,
+		"to", to,
+		"objects", len(objects),
+		"size_bytes", copied,
+	)
+	return nil
+}
+
+// verifyObject checks that object exists on the target with the same size.
+func (operator *Operator) verifyObject(target storage.Backend, object storage.Object) error {
+	copies, err := target.List(operator.ctx, object.Key)
+	if err != nil {
+		return fmt.Errorf("verify %s: %w", object.Key, err)
+	}
+	for _, c := range copies {
+		if c.Key == object.Key {
+			if c.Size != object.Size {
+				return fmt.Errorf("verify %s: size mismatch (source %d, target %d)",
+					object.Key, object.Size, c.Size)
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("verify %s: %w on target", object.Key, storage.ErrNotFound)
+}
+
+// relocateCatalog records the new backend in every local history entry, so
+// older restore points follow the migration too. Keys are the same on both
+// backends, so RemotePath is kept.
+func (operator *Operator) relocateCatalog(to string) error {
+	entries, err := LoadCatalog(operator.config.Backup.Directory)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		dir := filepath.Dir(entry.Path)
+		records, err := LoadHistory(dir)
+		if err != nil {
+			return fmt.Errorf("update catalog %q: %w", dir, err)
+		}
+		for _, record := range records {
+			if !record.Restorable() {
+				continue
+			}
+			key, err := operator.storageKey(record.FilePath)
+			if err != nil {
+				continue
+			}
+			record.RemotePath, record.Backend = key, to
+			if err := record.Write(dir); err != nil {
+				return fmt.Errorf("update catalog %q: %w", dir, err)
+			}
+		}
+	}
+	return nil
+}
+
+// backendFromURI resolves a migration endpoint into a storage backend.
+func (operator *Operator) backendFromURI(uri string) (storage.Backend, error) {
+	switch {
+	case uri == "local":
+		return storage.NewLocal(operator.config.Backup.Directory)
+	case strings.HasPrefix(uri, "local:"):
+		return storage.NewLocal(strings.TrimPrefix(uri, "local:"))
+	case uri == "remote":
+		if operator.storage == nil {
+			return nil, fmt.Errorf("%w: no storage backend configured", storage.ErrUnsupportedBackend)
+		}
+		return operator.storage, nil
+	case strings.HasPrefix(uri, "s3://"):
+		u, err := url.Parse(uri)
+		if err != nil {
+			return nil, fmt.Errorf("parse %q: %w", uri, err)
+		}
+		cfg := operator.config.Storage
+		cfg.Type = storage.TypeS3
+		cfg.S3.Bucket = u.Host
+		cfg.S3.Prefix = strings.Trim(u.Path, "/")
+		return buildStorage(operator.ctx, cfg, operator.vaultClient)
+	case strings.HasPrefix(uri, "rclone:"):
+		cfg := operator.config.Storage
+		cfg.Type = storage.TypeRclone
+		cfg.Rclone.Remote = strings.TrimPrefix(uri, "rclone:")
+		return buildStorage(operator.ctx, cfg, operator.vaultClient)
+	default:
+		return nil, fmt.Errorf("%w: %q", storage.ErrUnsupportedBackend, uri)
+	}
+}
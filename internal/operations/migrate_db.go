@@ -0,0 +1,249 @@
+package operations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/kebairia/backup/internal/database"
+	"github.com/kebairia/backup/internal/state"
+)
+
+// ErrMigrate indicates a migration between two instances that cannot run
+// as requested.
+var ErrMigrate = errors.New("invalid migration")
+
+// MigrateOptions selects the source and target of a migration.
+type MigrateOptions struct {
+	From     string // source instance, "engine:name"
+	To       string // target instance, "engine:name"
+	Database string // source database, for instances backing up "*"
+	// TargetDatabase restores into this database of the target instance
+	// instead of its configured one.
+	TargetDatabase string
+	Force          bool // restore even into a target that holds data
+	// NoDisk pipes the dump into the restore instead of staging it in the
+	// source's artifact directory (see database.Piper).
+	NoDisk bool
+}
+
+// MigrateResult describes a migration.
+type MigrateResult struct {
+	Engine         string        `json:"engine"`
+	Source         string        `json:"source"` // host/database
+	Target         string        `json:"target"` // host/database
+	NoDisk         bool          `json:"no_disk,omitempty"`
+	Bytes          int64         `json:"bytes"`
+	Duration       time.Duration `json:"duration"`
+	DurationString string        `json:"duration_string"`
+}
+
+// Migrate dumps one database of the From instance and restores it into the
+// To instance of the same engine, e.g. to refresh staging from production.
+// The dump uses the source's configured method and is not recorded in the
+// catalog; the target's configured method must read the same artifacts.
+// Both databases are locked for the run, and the overwrite guard applies to
+// the target as in RestoreAll.
+func Migrate(ctx context.Context, configPath string, opts MigrateOptions) (MigrateResult, error) {
+	var result MigrateResult
+	engine, _, ok := strings.Cut(opts.From, ":")
+	toEngine, _, toOK := strings.Cut(opts.To, ":")
+	if !ok || !toOK {
+		return result, fmt.Errorf("%w: --from and --to take engine:instance", ErrMigrate)
+	}
+	if engine != toEngine {
+		return result, fmt.Errorf("%w: cannot restore a %s dump into %s", ErrMigrate, engine, toEngine)
+	}
+	operator, err := NewOperator(ctx, configPath)
+	if err != nil {
+		return result, err
+	}
+	source, err := operator.migrateEnd(opts.From, opts.Database)
+	if err != nil {
+		return result, fmt.Errorf("source: %w", err)
+	}
+	target, err := operator.migrateEnd(opts.To, "")
+	if err != nil {
+		return result, fmt.Errorf("target: %w", err)
+	}
+	if opts.TargetDatabase != "" {
+		retargeter, ok := target.(database.Retargeter)
+		if !ok {
+			return result, fmt.Errorf("%w: %s cannot restore into another database", ErrMigrate, engine)
+		}
+		retargeter.Retarget("", opts.TargetDatabase)
+	}
+	result = MigrateResult{
+		Engine: engine,
+		Source: source.GetHost() + "/" + source.GetName(),
+		Target: target.GetHost() + "/" + targetName(target, opts.TargetDatabase),
+		NoDisk: opts.NoDisk,
+	}
+	if result.Source == result.Target {
+		return result, fmt.Errorf("%w: source and target are both %s", ErrMigrate, result.Source)
+	}
+	if err := checkMigrateFormats(source, target, opts.NoDisk); err != nil {
+		return result, err
+	}
+	if err := checkOverwrite(target, opts.Force); err != nil {
+		return result, err
+	}
+
+	for _, db := range []database.Database{source, target} {
+		unlock, err := operator.lockDatabase(db)
+		if err != nil {
+			if errors.Is(err, state.ErrLocked) {
+				return result, fmt.Errorf("%w: %w", ErrSkipped, err)
+			}
+			return result, fmt.Errorf("lock %q: %w", db.GetName(), err)
+		}
+		defer unlock()
+	}
+
+	operator.log.Info("migration started",
+		"engine", engine,
+		"source", result.Source,
+		"target", result.Target,
+		"no_disk", opts.NoDisk,
+	)
+	start := time.Now()
+	if opts.NoDisk {
+		result.Bytes, err = operator.pipe(source, target)
+	} else {
+		result.Bytes, err = operator.stage(source, target)
+	}
+	result.Duration = time.Since(start)
+	result.DurationString = result.Duration.Round(time.Millisecond).String()
+	if err != nil {
+		if cancelled := operator.cancelled(); cancelled != nil {
+			err = fmt.Errorf("%w: %w", cancelled, err)
+		}
+		return result, fmt.Errorf("migrate %s to %s: %w", result.Source, result.Target, err)
+	}
+	operator.log.Info("migration completed",
+		"engine", engine,
+		"source", result.Source,
+		"target", result.Target,
+		"bytes", result.Bytes,
+		"duration", result.DurationString,
+	)
+	return result, nil
+}
+
+// migrateEnd initializes the instance "engine:name" alone and returns its
+// database name, or its only database.
+func (operator *Operator) migrateEnd(instance, name string) (database.Database, error) {
+	engine, _, _ := strings.Cut(instance, ":")
+	databases, err := database.InitializeDatabases(operator.ctx,
+		targetConfig(operator.config, engine, instance), operator.vaultClient)
+	if err != nil {
+		return nil, fmt.Errorf("initialize databases: %w", err)
+	}
+	return selectStream(databases, engine, instance, name)
+}
+
+// targetName returns the database a migration restores into.
+func targetName(target database.Database, override string) string {
+	if override != "" {
+		return override
+	}
+	return target.GetName()
+}
+
+// checkMigrateFormats checks that the artifacts of source can be restored
+// by target: both write the same single-file format, and with noDisk both
+// can pipe it.
+func checkMigrateFormats(source, target database.Database, noDisk bool) error {
+	sourceExt, err := artifactExt(source)
+	if err != nil {
+		return err
+	}
+	targetExt, err := artifactExt(target)
+	if err != nil {
+		return err
+	}
+	if sourceExt != targetExt {
+		return fmt.Errorf("%w: the source writes %s artifacts, the target restores %s", ErrMigrate, sourceExt, targetExt)
+	}
+	if !noDisk {
+		return nil
+	}
+	for _, db := range []database.Database{source, target} {
+		if _, ok := db.(database.Piper); !ok {
+			return fmt.Errorf("%w: %s dumps cannot be piped, migrate without --no-disk", ErrMigrate, db.GetEngine())
+		}
+	}
+	return nil
+}
+
+// stage dumps source into its artifact directory, restores the artifact
+// into target and removes it.
+func (operator *Operator) stage(source, target database.Database) (int64, error) {
+	stderr := operator.captureStderr(source, "")
+	backupPath, err := source.Backup(operator.ctx)
+	err = stderr.finish(err)
+	if backupPath != "" {
+		defer os.RemoveAll(backupPath)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("dump: %w", err)
+	}
+	info, err := os.Stat(backupPath)
+	if err != nil {
+		return 0, fmt.Errorf("dump: %w", err)
+	}
+	stderr = operator.captureStderr(target, "")
+	if err := stderr.finish(target.Restore(operator.ctx, backupPath)); err != nil {
+		return info.Size(), fmt.Errorf("restore: %w", err)
+	}
+	return info.Size(), nil
+}
+
+// pipe streams the dump of source into the restore of target. A failure of
+// either side stops the other.
+func (operator *Operator) pipe(source, target database.Database) (int64, error) {
+	reader, writer := io.Pipe()
+	counter := &countingReader{r: reader}
+	dumped := make(chan error, 1)
+	go func() {
+		stderr := operator.captureStderr(source, "")
+		err := stderr.finish(source.(database.Piper).DumpTo(writer))
+		writer.CloseWithError(err)
+		dumped <- err
+	}()
+	stderr := operator.captureStderr(target, "")
+	restoreErr := stderr.finish(target.(database.Piper).RestoreFrom(counter))
+	// a dump that already failed made the restore fail, not the reverse
+	select {
+	case dumpErr := <-dumped:
+		if dumpErr != nil {
+			return counter.n, fmt.Errorf("dump: %w", dumpErr)
+		}
+	default:
+		// unblock the dump when the restore stopped reading early
+		reader.CloseWithError(restoreErr)
+		if dumpErr := <-dumped; dumpErr != nil && restoreErr == nil {
+			return counter.n, fmt.Errorf("dump: %w", dumpErr)
+		}
+	}
+	if restoreErr != nil {
+		return counter.n, fmt.Errorf("restore: %w", restoreErr)
+	}
+	return counter.n, nil
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,60 @@
+package operations
+
+import (
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// pipeDB is a fileDB that can also pipe its dump.
+type pipeDB struct {
+	fileDB
+	restoreErr error // returned by RestoreFrom after reading a little
+}
+
+func (p *pipeDB) DumpTo(w io.Writer) error {
+	_, err := io.Copy(w, strings.NewReader(p.content))
+	return err
+}
+
+func (p *pipeDB) RestoreFrom(r io.Reader) error {
+	if p.restoreErr != nil {
+		_, _ = r.Read(make([]byte, 1))
+		return p.restoreErr
+	}
+	data, err := io.ReadAll(r)
+	p.restored = string(data)
+	return err
+}
+
+func TestMigrateStageAndPipe(t *testing.T) {
+	operator, _ := flowOperator(t, nil)
+	dir := operator.config.Backup.Directory
+	source := &pipeDB{fileDB: fileDB{dir: filepath.Join(dir, "prod"), content: strings.Repeat("row\n", 50000)}}
+	target := &pipeDB{fileDB: fileDB{dir: filepath.Join(dir, "staging")}}
+
+	n, err := operator.stage(source, target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if target.restored != source.content || n != int64(len(source.content)) {
+		t.Errorf("stage restored %d bytes (%d reported), want %d", len(target.restored), n, len(source.content))
+	}
+
+	target.restored = ""
+	n, err = operator.pipe(source, target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if target.restored != source.content || n != int64(len(source.content)) {
+		t.Errorf("pipe restored %d bytes (%d reported), want %d", len(target.restored), n, len(source.content))
+	}
+
+	// a restore failing early must not leave the dump blocked on the pipe
+	target.restoreErr = errors.New("role does not exist")
+	if _, err := operator.pipe(source, target); err == nil || !strings.HasPrefix(err.Error(), "restore: role does not exist") {
+		t.Errorf("pipe() = %v, want the restore error", err)
+	}
+}
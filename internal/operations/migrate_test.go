@@ -0,0 +1,47 @@
+package operations
+
+import (
+	"context"
+	"os"
+	"testing"
+)
+
+func TestRelocateCatalog(t *testing.T) {
+	backend := &memStorage{}
+	operator, db := flowOperator(t, backend)
+	record, err := operator.backupDatabase(context.Background(), db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := record.RemotePath
+
+	if err := operator.relocateCatalog("s3://archive/bacli/"); err != nil {
+		t.Fatal(err)
+	}
+	latest, err := LoadLatestRestorable(db.GetPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest.RemotePath != key || latest.Backend != "s3://archive/bacli/" {
+		t.Errorf("migrated record: RemotePath %q, Backend %q, want %q on the new backend",
+			latest.RemotePath, latest.Backend, key)
+	}
+	if got, want := operator.artifactURI(latest), "s3://archive/bacli/"+key; got != want {
+		t.Errorf("artifactURI = %q, want %q", got, want)
+	}
+
+	// the configured storage now stands for the migration target
+	result, err := operator.audit(AuditOptions{ReadData: true})
+	if err != nil || result.Records != 1 {
+		t.Errorf("audit of the migrated catalog = %+v, %v", result, err)
+	}
+	if err := os.Remove(latest.FilePath); err != nil {
+		t.Fatal(err)
+	}
+	if err := operator.RestoreDatabase(db, latest); err != nil {
+		t.Fatal(err)
+	}
+	if db.restored != db.content {
+		t.Errorf("restored %q, want %q", db.restored, db.content)
+	}
+}
@@ -0,0 +1,191 @@
+package operations
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/kebairia/backup/internal/config"
+	"github.com/kebairia/backup/internal/database"
+	"github.com/kebairia/backup/internal/events"
+	"github.com/kebairia/backup/internal/notify"
+	"github.com/kebairia/backup/internal/vault"
+)
+
+// buildNotifiers creates the notifiers enabled in the configuration.
+func buildNotifiers(
+	ctx context.Context,
+	cfg config.NotifyConfig,
+	vaultClient Vault,
+) ([]notify.Notifier, error) {
+	var notifiers []notify.Notifier
+
+	if cfg.Email.Enabled {
+		email := cfg.Email
+		username, password := email.Username, email.Password
+		if email.VaultPath != "" {
+			if vaultClient == nil {
+				return nil, fmt.Errorf("%w: notify.email.vault_path needs it", vault.ErrNotConfigured)
+			}
+			secret, err := vaultClient.GetSecret(ctx, email.VaultPath)
+			if err != nil {
+				return nil, fmt.Errorf("vault read smtp credentials: %w", err)
+			}
+			username, _ = secret["username"].(string)
+			password, _ = secret["password"].(string)
+		}
+		notifier, err := notify.NewEmail(
+			notify.WithEmailServer(email.Host, email.Port),
+			notify.WithEmailTLS(email.TLS),
+			notify.WithEmailSender(email.From),
+			notify.WithEmailCredentials(username, password),
+			notify.WithEmailRecipients(email.Recipients.All, email.Recipients.Failures),
+		)
+		if err != nil {
+			return nil, err
+		}
+		notifiers = append(notifiers, notifier)
+	}
+
+	if cfg.Ping.Enabled {
+		notifier, err := notify.NewPing(cfg.Ping.URL,
+			notify.WithPingSignals(cfg.Ping.Start, cfg.Ping.Failures),
+		)
+		if err != nil {
+			return nil, err
+		}
+		notifiers = append(notifiers, notifier)
+	}
+
+	if cfg.Annotations.Enabled {
+		annotations := cfg.Annotations
+		token := annotations.Token
+		if annotations.VaultPath != "" {
+			if vaultClient == nil {
+				return nil, fmt.Errorf("%w: notify.annotations.vault_path needs it", vault.ErrNotConfigured)
+			}
+			secret, err := vaultClient.GetSecret(ctx, annotations.VaultPath)
+			if err != nil {
+				return nil, fmt.Errorf("vault read annotations token: %w", err)
+			}
+			token, _ = secret["token"].(string)
+		}
+		notifier, err := notify.NewAnnotations(annotations.URL,
+			notify.WithAnnotationsFormat(annotations.Format),
+			notify.WithAnnotationsToken(token),
+			notify.WithAnnotationsDashboard(annotations.DashboardUID),
+			notify.WithAnnotationsTags(annotations.Tags),
+		)
+		if err != nil {
+			return nil, err
+		}
+		notifiers = append(notifiers, notifier)
+	}
+
+	return notifiers, nil
+}
+
+// notifyStart signals the start of a run to notifiers that support it.
+func (operator *Operator) notifyStart(operation string) {
+	for _, notifier := range operator.notifiers {
+		starter, ok := notifier.(notify.StartNotifier)
+		if !ok {
+			continue
+		}
+		if err := starter.NotifyStart(operator.ctx, operation); err != nil {
+			operator.log.Error("notification failed",
+				"notifier", notifier.Name(),
+				"error", err.Error(),
+			)
+		}
+	}
+}
+
+// notify sends the report, with credentials redacted, to every configured
+// notifier. Delivery failures are logged and never fail the run itself.
+// Reports of a cancelled run are still delivered.
+func (operator *Operator) notify(report notify.Report) {
+	ctx := context.WithoutCancel(operator.ctx)
+	report = report.Redacted()
+	for _, notifier := range operator.notifiers {
+		if err := notifier.Notify(ctx, report); err != nil {
+			operator.log.Error("notification failed",
+				"notifier", notifier.Name(),
+				"error", err.Error(),
+			)
+		}
+	}
+}
+
+// notifyArtifact posts the successful backup of db recorded in record to
+// the webhook of db's instance, if it has one. Failures are logged and never
+// fail the backup.
+func (operator *Operator) notifyArtifact(db database.Database, record *Metadata) {
+	instance := ""
+	if named, ok := db.(database.Instancer); ok {
+		instance = named.GetInstance()
+	}
+	hook := operator.config.Webhook(db.GetEngine(), instance)
+	if hook.URL == "" {
+		return
+	}
+	webhook, err := notify.NewWebhook(hook.URL, notify.WithWebhookToken(hook.Token))
+	if err == nil {
+		err = webhook.Send(context.WithoutCancel(operator.ctx), notify.ArtifactEvent{
+			RunID:      operator.runID,
+			Engine:     record.Engine,
+			Instance:   instance,
+			Database:   record.Database,
+			FilePath:   record.FilePath,
+			URI:        operator.artifactURI(*record),
+			Checksum:   record.Checksum,
+			SizeBytes:  record.SizeBytes,
+			StartedAt:  record.StartedAt,
+			DurationMS: record.Duration.Milliseconds(),
+			Labels:     record.Labels,
+		})
+	}
+	if err != nil {
+		operator.log.Error("webhook failed",
+			"database", record.Database,
+			"engine", record.Engine,
+			"error", err.Error(),
+		)
+	}
+}
+
+// emit streams an event of the run to the events stream, if one is open
+// (see package events).
+func (operator *Operator) emit(kind, engine, database string, data map[string]any) {
+	events.Emit(events.Event{
+		Type:     kind,
+		RunID:    operator.runID,
+		Engine:   engine,
+		Database: database,
+		Data:     data,
+	})
+}
+
+// emitRun streams the start or the end of a run with its counts.
+func (operator *Operator) emitRun(kind string, report notify.Report, databases int) {
+	data := map[string]any{"operation": report.Operation, "databases": databases}
+	if kind == events.RunCompleted {
+		data["failed"] = report.Failed()
+		data["duration_ms"] = report.Duration().Milliseconds()
+	}
+	operator.emit(kind, "", "", data)
+}
+
+// newResult converts a metadata record into a notification result.
+func newResult(record *Metadata) notify.Result {
+	return notify.Result{
+		Engine:    record.Engine,
+		Database:  record.Database,
+		FilePath:  record.FilePath,
+		Status:    record.Status,
+		Error:     record.Error,
+		Stderr:    record.Stderr,
+		StartedAt: record.StartedAt,
+		Duration:  record.Duration,
+		SizeBytes: record.SizeBytes,
+	}
+}
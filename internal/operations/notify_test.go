@@ -0,0 +1,45 @@
+package operations
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kebairia/backup/internal/config"
+	"github.com/kebairia/backup/internal/notify"
+)
+
+func TestNotifyArtifact(t *testing.T) {
+	var (
+		event         notify.ArtifactEvent
+		authorization string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			t.Error(err)
+		}
+	}))
+	defer server.Close()
+
+	operator, db := flowOperator(t, &memStorage{})
+	operator.config.Storage = config.StorageConfig{Type: "s3", S3: config.S3Config{Bucket: "backups", Prefix: "prod"}}
+	operator.config.Postgres.Instances = []config.DBInstance{
+		{Webhook: config.WebhookConfig{URL: server.URL, Token: "secret"}},
+	}
+	record, err := operator.backupDatabase(context.Background(), db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	operator.notifyArtifact(db, record)
+
+	if authorization != "Bearer secret" {
+		t.Errorf("Authorization = %q", authorization)
+	}
+	if event.Event != notify.ArtifactBackedUp || event.Database != "billing" || event.Checksum != record.Checksum ||
+		event.URI != "s3://backups/prod/postgres/billing/billing.dump.zst" {
+		t.Errorf("event = %+v", event)
+	}
+}
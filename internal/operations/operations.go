@@ -2,10 +2,21 @@ package operations
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sync"
+	"time"
 
+	"github.com/kebairia/backup/internal/chunkstore"
 	"github.com/kebairia/backup/internal/config"
+	"github.com/kebairia/backup/internal/database"
+	"github.com/kebairia/backup/internal/encryption"
 	"github.com/kebairia/backup/internal/logger"
+	"github.com/kebairia/backup/internal/notify"
+	"github.com/kebairia/backup/internal/signing"
+	"github.com/kebairia/backup/internal/state"
+	"github.com/kebairia/backup/internal/storage"
+	"github.com/kebairia/backup/internal/telemetry"
 	"github.com/kebairia/backup/internal/vault"
 )
 
@@ -13,12 +24,79 @@ import (
 // and other !operations!
 // It holds the execution context, configuration, Vault client, and logger.
 type Operator struct {
-	ctx         context.Context
-	config      config.Config
-	vaultClient *vault.Client
-	log         logger.Logger
+	ctx          context.Context
+	config       config.Config
+	vaultClient  Vault // nil without a vault block
+	log          logger.Logger
+	notifiers    []notify.Notifier
+	storage      storage.Backend   // nil when artifacts stay local
+	replicas     []replica         // copies of every upload (storage.replicas)
+	repoBackend  storage.Backend   // backend of the chunk repository; nil without one
+	state        state.Store       // run markers and per-database locks
+	keepPartial  bool              // keep artifacts of failed backups
+	ignoreWindow bool              // back up outside backup windows (--ignore-window)
+	incremental  bool              // take incremental backups where supported (--incremental)
+	labels       map[string]string // run labels applied over instance labels
+	// encryption wraps artifact data keys; nil stores artifacts in clear
+	encryption encryption.KeyWrapper
+	// signer signs artifact checksums; nil leaves artifacts unsigned
+	signer signing.Signer
+	// pipeline bounds the backups in each step (backup.pipeline)
+	pipeline pipeline
+	// runID identifies the run in logs, metadata records, uploaded objects,
+	// events and notifications
+	runID string
+
+	repoOnce sync.Once // opens repo, see repository
+	repo     *chunkstore.Repository
+	repoErr  error
+
+	mu          sync.Mutex  // guards interrupted
+	interrupted []*Metadata // runs recovered from a previous crash
+
+	// leases counts the databases still to back up with each Vault lease
+	leases leaseCounter
+}
+
+// Vault is the Vault client of a run: dynamic credentials for the engines,
+// secrets for notifiers and storage, transit keys, shared state, and the
+// revocation of the run's leases. It is implemented by *vault.Client; tests
+// substitute fakes.
+type Vault interface {
+	database.CredentialSource
+	encryption.TransitClient
+	state.KVClient
+	RevokeLease(ctx context.Context, id string) error
+	RevokeLeases(ctx context.Context) error
 }
 
+// ErrCancelled indicates that a run was interrupted (SIGINT/SIGTERM) before
+// it finished. Backups still running were stopped and recorded as failed.
+var ErrCancelled = errors.New("run cancelled")
+
+// ErrRunFailed indicates that a backup or restore run finished with some of
+// its databases failed; the run report tells which.
+var ErrRunFailed = errors.New("run failed")
+
+// cancelled reports whether the run was interrupted. The dynamic database
+// credentials issued for it are revoked so they do not outlive the run.
+func (operator *Operator) cancelled() error {
+	if operator.ctx.Err() == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(operator.ctx), revokeTimeout)
+	defer cancel()
+	if operator.vaultClient != nil {
+		if err := operator.vaultClient.RevokeLeases(ctx); err != nil {
+			operator.log.Warn("failed to revoke credential leases", "error", err.Error())
+		}
+	}
+	return fmt.Errorf("%w: %w", ErrCancelled, context.Cause(operator.ctx))
+}
+
+// revokeTimeout bounds lease revocation after an interrupted run.
+const revokeTimeout = 10 * time.Second
+
 // Operator methods:
 //
 // InitializeDatabases prepares and configures the target databases before any
@@ -42,31 +120,96 @@ type Operator struct {
 // Decrypt makes encrypted backups readable by removing encryption layers.
 
 // NewOperator creates a new Operator with the given context, configuration,
-// Vault client, and logger.
+// Vault client, and logger. Cancelling ctx (e.g. on SIGINT/SIGTERM) stops
+// running client commands and uploads; see ErrCancelled.
 
-func NewOperator(configPath string) (*Operator, error) {
+func NewOperator(ctx context.Context, configPath string) (*Operator, error) {
 	var config config.Config
-	ctx := context.Background()
 	if err := config.Load(configPath); err != nil {
 		return nil, err
 	}
-	//  Build Vault options
-	vaultOpts := []vault.Option{
-		vault.WithAddress(config.Vault.Address),
-		vault.WithAppRole(config.Vault.Approle),
+	if err := telemetry.Init(ctx, config.Tracing); err != nil {
+		return nil, err
+	}
+	initCtx, span := telemetry.Start(ctx, "operator.init")
+	defer span.End()
+	// Init Vault client; without a vault block bacli runs standalone with
+	// static credentials
+	var vaultClient Vault
+	if config.Vault.Address != "" {
+		vaultOpts := []vault.Option{
+			vault.WithAddress(config.Vault.Address),
+			vault.WithAppRole(config.Vault.Approle),
+			vault.WithNamespace(config.Vault.Namespace),
+			vault.WithTLS(vault.TLSOptions{
+				CACert:     config.Vault.TLS.CACert,
+				ClientCert: config.Vault.TLS.ClientCert,
+				ClientKey:  config.Vault.TLS.ClientKey,
+				ServerName: config.Vault.TLS.ServerName,
+				SkipVerify: config.Vault.TLS.SkipVerify,
+			}),
+			vault.WithCredentialReuse(config.Vault.Credentials.Reuse),
+		}
+		client, err := vault.NewClient(initCtx, vaultOpts...)
+		if err != nil {
+			return nil, fmt.Errorf("vault client init: %w", err)
+		}
+		vaultClient = client
+	}
+
+	notifiers, err := buildNotifiers(initCtx, config.Notify, vaultClient)
+	if err != nil {
+		return nil, fmt.Errorf("notifiers init: %w", err)
 	}
-	// Init Vault client
-	vaultClient, err := vault.NewClient(ctx, vaultOpts...)
+
+	backend, err := buildStorage(initCtx, config.Storage, vaultClient)
+	if err != nil {
+		return nil, fmt.Errorf("storage init: %w", err)
+	}
+
+	replicas, err := buildReplicas(initCtx, config.Storage, vaultClient)
+	if err != nil {
+		return nil, fmt.Errorf("storage init: %w", err)
+	}
+
+	repoBackend, err := buildStorage(initCtx, config.Repository.Backend(), vaultClient)
+	if err != nil {
+		return nil, fmt.Errorf("repository init: %w", err)
+	}
+
+	store, err := buildState(config, vaultClient)
+	if err != nil {
+		return nil, fmt.Errorf("state init: %w", err)
+	}
+
+	wrapper, err := buildEncryption(config.Backup.Encryption, vaultClient)
+	if err != nil {
+		return nil, fmt.Errorf("encryption init: %w", err)
+	}
+
+	signer, err := signing.New(config.Backup.Signing)
 	if err != nil {
-		return nil, fmt.Errorf("vault client init: %w", err)
+		return nil, fmt.Errorf("signing init: %w", err)
 	}
 
-	log := logger.Global()
+	// every log entry of the run, the engines' included, carries its ID
+	runID := newRunID()
+	ctx = logger.WithFields(ctx, "run_id", runID)
+	log := logger.FromContext(ctx, logger.Global())
 
 	return &Operator{
 		ctx:         ctx,
 		config:      config,
 		vaultClient: vaultClient,
 		log:         log,
+		notifiers:   notifiers,
+		storage:     backend,
+		replicas:    replicas,
+		repoBackend: repoBackend,
+		state:       store,
+		encryption:  wrapper,
+		signer:      signer,
+		pipeline:    newPipeline(config.Backup.Pipeline),
+		runID:       runID,
 	}, nil
 }
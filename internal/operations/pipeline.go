@@ -0,0 +1,144 @@
+package operations
+
+import (
+	"sync"
+
+	"github.com/kebairia/backup/internal/config"
+)
+
+// stage bounds how many backups run one step of the pipeline at once.
+// A nil stage has no limit.
+type stage chan struct{}
+
+func newStage(limit int) stage {
+	if limit <= 0 {
+		return nil
+	}
+	return make(stage, limit)
+}
+
+// enter waits until a slot of the stage is free.
+func (s stage) enter() {
+	if s != nil {
+		s <- struct{}{}
+	}
+}
+
+// leave frees the slot taken by enter.
+func (s stage) leave() {
+	if s != nil {
+		<-s
+	}
+}
+
+// fairStage is a stage whose slots are shared between engines: a free slot
+// goes to the waiting engine that got the fewest slots for its weight, so
+// each engine progresses in proportion to its weight however many databases
+// the others have. A nil fairStage has no limit.
+type fairStage struct {
+	mu      sync.Mutex
+	free    int
+	weights map[string]int
+	waiting map[string][]chan struct{} // by engine, first come first served
+	served  map[string]float64         // slots taken / weight, by engine
+}
+
+func newFairStage(limit int, weights map[string]int) *fairStage {
+	if limit <= 0 {
+		return nil
+	}
+	return &fairStage{
+		free:    limit,
+		weights: weights,
+		waiting: make(map[string][]chan struct{}),
+		served:  make(map[string]float64),
+	}
+}
+
+// enter waits until a slot of the stage is given to engine.
+func (s *fairStage) enter(engine string) {
+	if s == nil {
+		return
+	}
+	ready := make(chan struct{})
+	s.mu.Lock()
+	if len(s.waiting[engine]) == 0 {
+		// an engine that was idle catches up with the waiting ones instead
+		// of taking every slot until its count reaches theirs
+		if least, ok := s.leastServed(); ok && s.served[engine] < least {
+			s.served[engine] = least
+		}
+	}
+	s.waiting[engine] = append(s.waiting[engine], ready)
+	s.dispatch()
+	s.mu.Unlock()
+	<-ready
+}
+
+// leave frees the slot taken by enter.
+func (s *fairStage) leave() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.free++
+	s.dispatch()
+	s.mu.Unlock()
+}
+
+// dispatch gives the free slots to the waiting engines. s.mu is held.
+func (s *fairStage) dispatch() {
+	for s.free > 0 {
+		engine, ok := "", false
+		for candidate, queue := range s.waiting {
+			if len(queue) == 0 {
+				continue
+			}
+			if !ok || s.served[candidate] < s.served[engine] ||
+				(s.served[candidate] == s.served[engine] && candidate < engine) {
+				engine, ok = candidate, true
+			}
+		}
+		if !ok {
+			return
+		}
+		queue := s.waiting[engine]
+		close(queue[0])
+		s.waiting[engine] = queue[1:]
+		s.free--
+		weight := s.weights[engine]
+		if weight <= 0 {
+			weight = 1
+		}
+		s.served[engine] += 1 / float64(weight)
+	}
+}
+
+// leastServed returns the lowest count of the engines waiting for a slot.
+// s.mu is held.
+func (s *fairStage) leastServed() (float64, bool) {
+	least, ok := 0.0, false
+	for engine, queue := range s.waiting {
+		if len(queue) > 0 && (!ok || s.served[engine] < least) {
+			least, ok = s.served[engine], true
+		}
+	}
+	return least, ok
+}
+
+// pipeline holds the bounded steps of a backup run (backup.pipeline).
+// Dumps are shared fairly between engines; the later steps follow the
+// order in which dumps end.
+type pipeline struct {
+	dump     *fairStage
+	compress stage // compression and encryption
+	upload   stage
+}
+
+func newPipeline(cfg config.PipelineConfig) pipeline {
+	return pipeline{
+		dump:     newFairStage(cfg.Dumps, cfg.Weights),
+		compress: newStage(cfg.Compress),
+		upload:   newStage(cfg.Uploads),
+	}
+}
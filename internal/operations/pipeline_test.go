@@ -0,0 +1,82 @@
+package operations
+
+import (
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestStageBoundsConcurrency(t *testing.T) {
+	s := newStage(2)
+	var (
+		wg            sync.WaitGroup
+		running, peak atomic.Int32
+	)
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.enter()
+			defer s.leave()
+			n := running.Add(1)
+			for {
+				m := peak.Load()
+				if n <= m || peak.CompareAndSwap(m, n) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			running.Add(-1)
+		}()
+	}
+	wg.Wait()
+	if got := peak.Load(); got > 2 {
+		t.Fatalf("stage ran %d at once, want at most 2", got)
+	}
+}
+
+func TestUnlimitedStage(t *testing.T) {
+	s := newStage(0)
+	if s != nil {
+		t.Fatal("newStage(0) should have no limit")
+	}
+	s.enter() // must not block
+	s.leave()
+}
+
+func TestFairStageSharesSlotsByWeight(t *testing.T) {
+	s := newFairStage(1, map[string]int{"postgres": 3})
+	s.enter("redis") // hold the slot until everyone waits
+
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		order []string
+	)
+	for _, engine := range []string{"postgres", "postgres", "postgres", "postgres", "postgres", "postgres", "mongodb", "mongodb"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.enter(engine)
+			mu.Lock()
+			order = append(order, engine)
+			mu.Unlock()
+			s.leave()
+		}()
+	}
+	for waiting := 0; waiting < 8; {
+		time.Sleep(time.Millisecond)
+		s.mu.Lock()
+		waiting = len(s.waiting["postgres"]) + len(s.waiting["mongodb"])
+		s.mu.Unlock()
+	}
+	s.leave()
+	wg.Wait()
+
+	want := []string{"mongodb", "postgres", "postgres", "postgres", "mongodb", "postgres", "postgres", "postgres"}
+	if !slices.Equal(order, want) {
+		t.Errorf("slots went to %v, want %v", order, want)
+	}
+}
@@ -0,0 +1,17 @@
+package operations
+
+import (
+	"github.com/kebairia/backup/internal/config"
+	"github.com/kebairia/backup/internal/policy"
+)
+
+// CheckPolicies evaluates the configured backup policies against the
+// effective configuration (profile and includes applied). It does not
+// contact Vault or any database, so it is safe to run in CI.
+func CheckPolicies(configPath string) (int, []policy.Violation, error) {
+	var cfg config.Config
+	if err := cfg.Load(configPath); err != nil {
+		return 0, nil, err
+	}
+	return len(cfg.Policies), policy.Evaluate(cfg), nil
+}
@@ -0,0 +1,64 @@
+package operations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/kebairia/backup/internal/database"
+	"github.com/kebairia/backup/internal/telemetry"
+)
+
+// ErrInsufficientSpace indicates that the backup directory cannot hold the
+// estimated dump.
+var ErrInsufficientSpace = errors.New("insufficient disk space")
+
+// defaultPreflightMargin is used when backup.preflight.margin is unset.
+const defaultPreflightMargin = 1.0
+
+// preflight compares the estimated size of db with the free space in dir
+// and fails early instead of letting the dump fill the disk.
+// It returns the estimate, or 0 when no estimate is available. Estimation
+// errors are logged and never block the backup.
+func (operator *Operator) preflight(ctx context.Context, db database.Database, dir string) (estimate int64, err error) {
+	cfg := operator.config.Backup.Preflight
+	if !cfg.Enabled {
+		return 0, nil
+	}
+	estimator, ok := db.(database.SizeEstimator)
+	if !ok {
+		return 0, nil
+	}
+	_, span := telemetry.Start(ctx, "preflight")
+	defer func() { telemetry.End(span, err) }()
+
+	estimate, err = estimator.EstimateSize()
+	if err != nil {
+		operator.log.Warn("size estimation failed, skipping preflight",
+			"database", db.GetName(),
+			"error", err.Error(),
+		)
+		return 0, nil
+	}
+	free := diskFree(dir)
+	if free < 0 {
+		return estimate, nil
+	}
+
+	margin := cfg.Margin
+	if margin <= 0 {
+		margin = defaultPreflightMargin
+	}
+	if need := int64(float64(estimate) * margin); free < need {
+		return estimate, fmt.Errorf("%w: %s needs about %d bytes (estimate %d × margin %.2f), %d free in %s",
+			ErrInsufficientSpace, db.GetName(), need, estimate, margin, free, dir)
+	}
+	if cfg.WarnMargin > 0 && free < int64(float64(estimate)*cfg.WarnMargin) {
+		operator.log.Warn("backup directory is running low on space",
+			"database", db.GetName(),
+			"estimated_bytes", estimate,
+			"free_bytes", free,
+		)
+	}
+	return estimate, nil
+}
@@ -0,0 +1,483 @@
+package operations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/kebairia/backup/internal/chunkstore"
+	"github.com/kebairia/backup/internal/config"
+	"github.com/kebairia/backup/internal/events"
+	"github.com/kebairia/backup/internal/logger"
+)
+
+// ErrNothingToPrune indicates that no prune mode was selected.
+var ErrNothingToPrune = errors.New("nothing to prune")
+
+// PruneOptions selects what Prune removes.
+type PruneOptions struct {
+	Orphans   bool // remove artifacts without a successful catalog entry
+	Retention bool // remove copies beyond retention.local and retention.remote
+	DryRun    bool // only report what would be removed
+}
+
+// Locations of a PruneCandidate.
+const (
+	LocationLocal  = "local"  // a file or directory under backup.directory
+	LocationRemote = "remote" // an object (or objects) of the storage backend
+	// LocationRepository is a snapshot of the chunk repository; Path is its id.
+	LocationRepository = "repository"
+)
+
+// PruneCandidate is an artifact selected for removal.
+type PruneCandidate struct {
+	Engine    string `json:"engine"`
+	Database  string `json:"database"`
+	Location  string `json:"location"`
+	Path      string `json:"path"` // local path, or storage key of remote copies
+	Reason    string `json:"reason"`
+	SizeBytes int64  `json:"size_bytes"`
+	// CreatedAt is when the backup started, or the file's modification
+	// time for orphans.
+	CreatedAt time.Time `json:"created_at"`
+	// Replicas names the replicas whose copies of a remote candidate are
+	// removed with it.
+	Replicas []string `json:"replicas,omitempty"`
+
+	record *Metadata // catalog entry of retention candidates
+	dir    string    // database directory holding the record
+}
+
+// PruneResult describes a prune run.
+type PruneResult struct {
+	DryRun     bool             `json:"dry_run"`
+	Candidates []PruneCandidate `json:"candidates"`
+	// ReclaimBytes is the space the candidates take, replica copies
+	// included. Repository snapshots are not counted: they only free the
+	// chunks no other snapshot uses.
+	ReclaimBytes int64 `json:"reclaim_bytes"`
+	Removed      int   `json:"removed"`
+	FreedBytes   int64 `json:"freed_bytes"`
+}
+
+// Reclaim returns the space candidate c takes, replica copies included.
+func (c PruneCandidate) Reclaim() int64 {
+	return c.SizeBytes * int64(1+len(c.Replicas))
+}
+
+// Prune removes artifacts from the local backup directory and, with
+// Retention, from the storage backend and its replicas. Orphans are found from the catalog
+// only; retention needs the storage backend and so may contact Vault.
+func Prune(ctx context.Context, configPath string, opts PruneOptions) (PruneResult, error) {
+	if !opts.Orphans && !opts.Retention {
+		return PruneResult{}, fmt.Errorf("%w: select a mode such as --orphans or --retention", ErrNothingToPrune)
+	}
+	var cfg config.Config
+	if err := cfg.Load(configPath); err != nil {
+		return PruneResult{}, err
+	}
+	log := logger.Global()
+
+	var candidates []PruneCandidate
+	if opts.Orphans {
+		found, err := findOrphans(cfg.Backup.Directory)
+		if err != nil {
+			return PruneResult{}, err
+		}
+		candidates = append(candidates, found...)
+	}
+	var operator *Operator
+	if opts.Retention {
+		var err error
+		if operator, err = NewOperator(ctx, configPath); err != nil {
+			return PruneResult{}, err
+		}
+		found, err := operator.findExpired()
+		if err != nil {
+			return PruneResult{}, err
+		}
+		candidates = append(candidates, found...)
+	}
+	result := PruneResult{DryRun: opts.DryRun, Candidates: candidates}
+	if result.Candidates == nil {
+		result.Candidates = []PruneCandidate{}
+	}
+	for _, c := range candidates {
+		result.ReclaimBytes += c.Reclaim()
+	}
+	if opts.DryRun {
+		return result, nil
+	}
+
+	var (
+		errs    []error
+		touched []*PruneCandidate // retention candidates removed, in order
+	)
+	for i, c := range candidates {
+		var err error
+		switch c.Location {
+		case LocationRemote:
+			// replicas first, so that a failure leaves the record as it was
+			if err = operator.deleteReplicas(ctx, c.record, c.Path); err == nil {
+				err = operator.deleteRemote(ctx, c.Path)
+			}
+			if err == nil && c.record.Signature != "" {
+				err = operator.deleteSignature(ctx, c.record)
+			}
+		case LocationRepository:
+			var repo *chunkstore.Repository
+			if repo, err = operator.repository(); err == nil {
+				err = repo.Forget(ctx, c.Path)
+			}
+		default:
+			err = os.RemoveAll(c.Path)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("remove %q: %w", c.Path, err))
+			continue
+		}
+		result.Removed++
+		result.FreedBytes += c.Reclaim()
+		if c.record != nil {
+			touched = append(touched, &candidates[i])
+			if c.Location == LocationLocal && c.record.StderrLog != "" {
+				_ = os.Remove(c.record.StderrLog)
+			}
+		}
+		events.Emit(events.Event{
+			Type:     events.PruneDeleted,
+			Engine:   c.Engine,
+			Database: c.Database,
+			Data: map[string]any{
+				"location":   c.Location,
+				"path":       c.Path,
+				"reason":     c.Reason,
+				"size_bytes": c.Reclaim(),
+			},
+		})
+		log.Info("artifact pruned",
+			"database", c.Database,
+			"engine", c.Engine,
+			"location", c.Location,
+			"path", c.Path,
+			"reason", c.Reason,
+		)
+	}
+	errs = append(errs, recordPruned(touched))
+	// forgotten snapshots only free the chunks no other snapshot uses
+	if slices.ContainsFunc(touched, func(c *PruneCandidate) bool { return c.Location == LocationRepository }) {
+		freed, err := operator.pruneRepository(ctx)
+		result.FreedBytes += freed
+		errs = append(errs, err)
+	}
+	return result, errors.Join(errs...)
+}
+
+// pruneRepository deletes the packs of the chunk repository no snapshot
+// uses any more and returns the bytes freed.
+func (operator *Operator) pruneRepository(ctx context.Context) (int64, error) {
+	repo, err := operator.repository()
+	if err != nil {
+		return 0, err
+	}
+	pruned, err := repo.Prune(ctx)
+	if err != nil {
+		return pruned.FreedBytes, fmt.Errorf("prune repository: %w", err)
+	}
+	operator.log.Info("repository pruned",
+		"packs_deleted", pruned.PacksDeleted,
+		"freed_bytes", pruned.FreedBytes,
+	)
+	return pruned.FreedBytes, nil
+}
+
+// recordPruned updates the catalog entries of removed retention candidates:
+// a removed remote copy clears RemotePath, a forgotten repository snapshot
+// clears Snapshot, and an entry left without any copy is marked pruned so it is no longer offered as a restore point.
+func recordPruned(removed []*PruneCandidate) error {
+	var changed []*PruneCandidate
+	for _, c := range removed {
+		record := c.record
+		switch c.Location {
+		case LocationRemote:
+			record.RemotePath = ""
+		case LocationRepository:
+			record.Snapshot = ""
+		}
+		if _, err := os.Stat(record.FilePath); record.RemotePath == "" && record.Snapshot == "" &&
+			errors.Is(err, fs.ErrNotExist) {
+			record.PrunedAt = time.Now()
+		}
+		if (c.Location != LocationLocal || !record.PrunedAt.IsZero()) &&
+			!slices.ContainsFunc(changed, func(o *PruneCandidate) bool { return o.record == record }) {
+			changed = append(changed, c)
+		}
+	}
+	var errs []error
+	for _, c := range changed {
+		if err := c.record.Write(c.dir); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// findExpired returns the local and remote copies of successful backups that
+// fall outside retention, see expiredCopies. Repository snapshots count as
+// remote copies; their size is only known once the repository is pruned.
+func (operator *Operator) findExpired() ([]PruneCandidate, error) {
+	var candidates []PruneCandidate
+	err := walkDatabases(operator.config.Backup.Directory, func(engine, db, dbDir string) error {
+		if _, err := os.Stat(filepath.Join(dbDir, InProgressFilename)); err == nil {
+			return nil
+		}
+		history, err := LoadHistory(dbDir)
+		if err != nil {
+			return err
+		}
+		local, remote := expiredCopies(history, operator.config.Retention,
+			operator.storage != nil || operator.repoBackend != nil)
+		for _, record := range local {
+			if _, err := os.Stat(record.FilePath); err != nil {
+				continue // already pruned locally
+			}
+			candidates = append(candidates, PruneCandidate{
+				Engine:    engine,
+				Database:  db,
+				Location:  LocationLocal,
+				Path:      record.FilePath,
+				Reason:    "beyond local retention",
+				SizeBytes: artifactSize(record.FilePath),
+				CreatedAt: record.StartedAt,
+				record:    record,
+				dir:       dbDir,
+			})
+		}
+		for _, record := range remote {
+			if record.Snapshot != "" && operator.repoBackend != nil {
+				candidates = append(candidates, PruneCandidate{
+					Engine:    engine,
+					Database:  db,
+					Location:  LocationRepository,
+					Path:      record.Snapshot,
+					Reason:    "beyond remote retention",
+					CreatedAt: record.StartedAt,
+					record:    record,
+					dir:       dbDir,
+				})
+			}
+			if record.RemotePath == "" || operator.storage == nil {
+				continue
+			}
+			key, err := operator.storageKey(record.FilePath)
+			if err != nil {
+				return err
+			}
+			candidates = append(candidates, PruneCandidate{
+				Engine:    engine,
+				Database:  db,
+				Location:  LocationRemote,
+				Path:      key,
+				Reason:    "beyond remote retention",
+				SizeBytes: record.SizeBytes,
+				CreatedAt: record.StartedAt,
+				Replicas:  operator.replicasOf(record),
+				record:    record,
+				dir:       dbDir,
+			})
+		}
+		return nil
+	})
+	return candidates, err
+}
+
+// replicasOf returns the configured replicas holding a copy of record.
+func (operator *Operator) replicasOf(record *Metadata) []string {
+	var names []string
+	for _, r := range operator.replicas {
+		if _, ok := record.Replicas[r.name]; ok {
+			names = append(names, r.name)
+		}
+	}
+	return names
+}
+
+// expiredCopies returns the restorable records of history (oldest first)
+// whose local and whose remote copy fall outside retention, counting from
+// the newest backup. A zero keep never expires anything.
+//
+// With remote storage, local copies past retention.local.keep are dropped
+// only when a remote copy exists; a backup that was never uploaded keeps its
+// local copy as long as either retention would keep it. A copy of a backup
+// that kept incremental backups apply on is never expired (see keepBases).
+func expiredCopies(history []Metadata, retention config.RetentionConfig, remoteStorage bool) (local, remote []*Metadata) {
+	beyond := func(rank, keep int) bool { return keep > 0 && rank > keep }
+	rank := 0
+	for i := len(history) - 1; i >= 0; i-- {
+		record := &history[i]
+		if !record.Restorable() {
+			continue
+		}
+		rank++
+		uploaded := remoteStorage && (record.RemotePath != "" || record.Snapshot != "")
+		if uploaded && beyond(rank, retention.RemoteKeep()) {
+			remote = append(remote, record)
+		}
+		localKeep := retention.LocalKeep()
+		if remoteStorage && !uploaded && localKeep > 0 {
+			// the local copy is the only one
+			if remoteKeep := retention.RemoteKeep(); remoteKeep == 0 {
+				localKeep = 0
+			} else {
+				localKeep = max(localKeep, remoteKeep)
+			}
+		}
+		if beyond(rank, localKeep) {
+			local = append(local, record)
+		}
+	}
+	return keepBases(history, local), keepBases(history, remote)
+}
+
+// findOrphans returns the artifacts under dir (laid out as
+// <engine>/<database>/<artifact>) that no successful run accounts for:
+//
+//   - every artifact of a database directory without any recorded run;
+//   - artifacts not referenced by a successful entry of the history log,
+//     i.e. leftovers of failed or interrupted runs.
+//
+// Artifacts older than the first recorded run predate the history log and
+// are kept.
+func findOrphans(dir string) ([]PruneCandidate, error) {
+	var candidates []PruneCandidate
+	err := walkDatabases(dir, func(engine, db, dbDir string) error {
+		found, err := orphansIn(dbDir)
+		if err != nil {
+			return err
+		}
+		for _, c := range found {
+			c.Engine, c.Database = engine, db
+			candidates = append(candidates, c)
+		}
+		return nil
+	})
+	return candidates, err
+}
+
+// walkDatabases calls fn for every database directory under dir, laid out
+// as <engine>/<database>.
+func walkDatabases(dir string, fn func(engine, db, dbDir string) error) error {
+	engines, err := readDirs(dir)
+	if err != nil {
+		return err
+	}
+	for _, engine := range engines {
+		if engine == config.TenantsDirname {
+			continue // tenants are pruned one at a time
+		}
+		databases, err := readDirs(filepath.Join(dir, engine))
+		if err != nil {
+			return err
+		}
+		for _, db := range databases {
+			if err := fn(engine, db, filepath.Join(dir, engine, db)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// orphansIn applies the findOrphans rules to one database directory.
+func orphansIn(dbDir string) ([]PruneCandidate, error) {
+	if _, err := os.Stat(filepath.Join(dbDir, InProgressFilename)); err == nil {
+		// a run is in progress or awaiting recovery; leave it alone
+		return nil, nil
+	}
+	history, err := LoadHistory(dbDir)
+	if err != nil {
+		return nil, err
+	}
+	var cutoff time.Time
+	referenced := make(map[string]bool)
+	if len(history) > 0 {
+		cutoff = history[0].StartedAt
+	}
+	for _, record := range history {
+		if record.Restorable() {
+			referenced[filepath.Clean(record.FilePath)] = true
+			if record.Signature != "" {
+				referenced[filepath.Clean(record.Signature)] = true
+			}
+		}
+		if record.StderrLog != "" {
+			referenced[filepath.Clean(record.StderrLog)] = true
+		}
+	}
+
+	entries, err := os.ReadDir(dbDir)
+	if err != nil {
+		return nil, fmt.Errorf("read %q: %w", dbDir, err)
+	}
+	var candidates []PruneCandidate
+	for _, entry := range entries {
+		name := entry.Name()
+		if isCatalogFile(name) {
+			continue
+		}
+		path := filepath.Join(dbDir, name)
+		info, err := entry.Info()
+		if err != nil {
+			return nil, fmt.Errorf("stat %q: %w", path, err)
+		}
+
+		reason := ""
+		switch {
+		case len(history) == 0:
+			reason = "no catalog entry"
+		case referenced[path], info.ModTime().Before(cutoff):
+			continue
+		default:
+			reason = "not referenced by any successful run"
+		}
+
+		size := info.Size()
+		if entry.IsDir() {
+			if size, err = dirSize(path); err != nil {
+				return nil, err
+			}
+		}
+		candidates = append(candidates, PruneCandidate{
+			Location:  LocationLocal,
+			Path:      path,
+			Reason:    reason,
+			SizeBytes: size,
+			CreatedAt: info.ModTime(),
+		})
+	}
+	return candidates, nil
+}
+
+// readDirs returns the names of the visible subdirectories of dir.
+func readDirs(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read %q: %w", dir, err)
+	}
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") {
+			names = append(names, entry.Name())
+		}
+	}
+	return names, nil
+}
@@ -0,0 +1,170 @@
+package operations
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/kebairia/backup/internal/config"
+)
+
+func TestFindOrphans(t *testing.T) {
+	dir := t.TempDir()
+	touch := func(path string, age time.Duration) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("data"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		when := time.Now().Add(-age)
+		if err := os.Chtimes(path, when, when); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Successful run: its artifact and an older backup are kept, a stray
+	// file written during the run is an orphan.
+	okDir := filepath.Join(dir, "postgres", "app")
+	older := filepath.Join(okDir, "1-app.dump.zst")
+	latest := filepath.Join(okDir, "2-app.dump.zst")
+	stray := filepath.Join(okDir, "2-app.dump")
+	touch(older, 48*time.Hour)
+	touch(latest, time.Minute)
+	touch(stray, time.Minute)
+	ok := Metadata{Engine: "postgres", Database: "app", Status: StatusSuccess,
+		FilePath: latest, StartedAt: time.Now().Add(-time.Hour)}
+	if err := ok.Write(okDir); err != nil {
+		t.Fatal(err)
+	}
+
+	// Failed run: the partial artifact it left is an orphan.
+	failedDir := filepath.Join(dir, "postgres", "billing")
+	partial := filepath.Join(failedDir, "2-billing.dump")
+	touch(filepath.Join(failedDir, "1-billing.dump"), 48*time.Hour)
+	touch(partial, time.Minute)
+	failed := Metadata{Engine: "postgres", Database: "billing", Status: StatusFailed,
+		FilePath: "N/A", StartedAt: time.Now().Add(-time.Hour)}
+	if err := failed.Write(failedDir); err != nil {
+		t.Fatal(err)
+	}
+
+	// No catalog entry at all.
+	unknown := filepath.Join(dir, "mongodb", "ghost", "1-ghost.archive")
+	touch(unknown, time.Hour)
+
+	candidates, err := findOrphans(dir)
+	if err != nil {
+		t.Fatalf("findOrphans: %v", err)
+	}
+	got := make(map[string]bool)
+	for _, c := range candidates {
+		got[c.Path] = true
+		if c.CreatedAt.IsZero() || time.Since(c.CreatedAt) > 2*time.Hour {
+			t.Errorf("%s: created at %v, want its modification time", c.Path, c.CreatedAt)
+		}
+	}
+	for _, want := range []string{stray, partial, unknown} {
+		if !got[want] {
+			t.Errorf("%s not reported as orphan", want)
+		}
+	}
+	if len(candidates) != 3 {
+		t.Errorf("got %d orphans, want 3: %+v", len(candidates), candidates)
+	}
+}
+
+func TestExpiredCopies(t *testing.T) {
+	start := time.Now().Add(-30 * 24 * time.Hour)
+	var history []Metadata
+	for i := range 6 {
+		record := Metadata{Status: StatusSuccess, FilePath: fmt.Sprintf("/b/%d.dump", i),
+			StartedAt: start.Add(time.Duration(i) * 24 * time.Hour)}
+		// the oldest backup was never uploaded
+		if i > 0 {
+			record.RemotePath = fmt.Sprintf("postgres/app/%d.dump", i)
+		}
+		history = append(history, record)
+	}
+	history = append(history, Metadata{Status: StatusFailed, FilePath: "N/A", StartedAt: time.Now()})
+	paths := func(records []*Metadata) []string {
+		var out []string
+		for _, r := range records {
+			out = append(out, filepath.Base(r.FilePath))
+		}
+		return out
+	}
+
+	retention := config.RetentionConfig{Keep: 6, Local: config.RetentionTier{Keep: 2}}
+	local, remote := expiredCopies(history, retention, true)
+	// 0.dump was never uploaded, so its local copy follows the remote retention
+	if got, want := paths(local), []string{"3.dump", "2.dump", "1.dump"}; !slices.Equal(got, want) {
+		t.Errorf("local = %v, want %v", got, want)
+	}
+	if len(remote) != 0 {
+		t.Errorf("remote = %v, want none", paths(remote))
+	}
+
+	retention.Remote.Keep = 4
+	local, remote = expiredCopies(history, retention, true)
+	if got, want := paths(local), []string{"3.dump", "2.dump", "1.dump", "0.dump"}; !slices.Equal(got, want) {
+		t.Errorf("local = %v, want %v", got, want)
+	}
+	if got, want := paths(remote), []string{"1.dump"}; !slices.Equal(got, want) {
+		t.Errorf("remote = %v, want %v", got, want)
+	}
+
+	// a repository snapshot is a remote copy
+	history[0].Snapshot = "snapshot"
+	local, remote = expiredCopies(history, retention, true)
+	if got, want := paths(remote), []string{"1.dump", "0.dump"}; !slices.Equal(got, want) {
+		t.Errorf("remote with a snapshot = %v, want %v", got, want)
+	}
+	history[0].Snapshot = ""
+
+	// without storage the local retention applies as is
+	local, _ = expiredCopies(history, retention, false)
+	if len(local) != 4 {
+		t.Errorf("local without storage = %v, want 4 records", paths(local))
+	}
+}
+
+func TestExpiredCopiesKeepsBases(t *testing.T) {
+	start := time.Now().Add(-30 * 24 * time.Hour)
+	var history []Metadata
+	for i := range 5 {
+		record := Metadata{Status: StatusSuccess, FilePath: fmt.Sprintf("/b/%d.dump", i),
+			StartedAt: start.Add(time.Duration(i) * 24 * time.Hour)}
+		// 0 is a full backup, 1 and 2 apply on it in turn; 3 and 4 are full
+		if i == 1 || i == 2 {
+			record.Base = history[i-1].StartedAt
+		}
+		history = append(history, record)
+	}
+
+	retention := config.RetentionConfig{Keep: 3}
+	local, _ := expiredCopies(history, retention, false)
+	if len(local) != 0 {
+		t.Errorf("local = %v, want the chain of 2.dump kept", local)
+	}
+
+	retention.Keep = 2
+	local, _ = expiredCopies(history, retention, false)
+	if len(local) != 3 {
+		t.Errorf("local = %d records, want the whole chain expired", len(local))
+	}
+
+	chains := BuildChains(history)
+	if len(chains) != 3 || len(chains[0].Links) != 3 || chains[0].Broken != "" {
+		t.Errorf("BuildChains() = %+v", chains)
+	}
+	history[1].PrunedAt = time.Now()
+	chains = BuildChains(history)
+	if len(chains) != 4 || chains[1].Broken == "" || chains[1].Links[0].FilePath != "/b/2.dump" {
+		t.Errorf("BuildChains() with a pruned link = %+v", chains)
+	}
+}
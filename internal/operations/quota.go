@@ -0,0 +1,233 @@
+package operations
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/kebairia/backup/internal/config"
+	"github.com/kebairia/backup/internal/database"
+)
+
+// ErrQuotaExceeded indicates that a backup would take the storage used by
+// a database, or by the backup directory, over its quota.
+var ErrQuotaExceeded = errors.New("storage quota exceeded")
+
+// Quota states of a DatabaseUsage or UsageReport; empty without a quota.
+const (
+	QuotaOK       = "ok"
+	QuotaWarning  = "warning"
+	QuotaExceeded = "exceeded"
+)
+
+// DatabaseUsage is the storage used by the backups of one database.
+type DatabaseUsage struct {
+	Engine   string `json:"engine"`
+	Database string `json:"database"`
+	Instance string `json:"instance,omitempty"`
+	// Backups counts the restorable backups in the catalog.
+	Backups int `json:"backups"`
+	// LocalBytes is the space used by the artifacts on disk, and
+	// RemoteBytes the size of the backups with a copy on the storage
+	// backend.
+	LocalBytes  int64 `json:"local_bytes"`
+	RemoteBytes int64 `json:"remote_bytes"`
+	// WarnBytes and LimitBytes are the quota of the instance; zero when
+	// unset.
+	WarnBytes  int64  `json:"warn_bytes,omitempty"`
+	LimitBytes int64  `json:"limit_bytes,omitempty"`
+	Quota      string `json:"quota,omitempty"`
+}
+
+// UsageReport is the result of Usage.
+type UsageReport struct {
+	GeneratedAt time.Time       `json:"generated_at"`
+	Databases   []DatabaseUsage `json:"databases"`
+	LocalBytes  int64           `json:"local_bytes"`
+	RemoteBytes int64           `json:"remote_bytes"`
+	// WarnBytes, LimitBytes and Quota apply to the whole backup directory.
+	WarnBytes  int64  `json:"warn_bytes,omitempty"`
+	LimitBytes int64  `json:"limit_bytes,omitempty"`
+	Quota      string `json:"quota,omitempty"`
+}
+
+// quotaBytes returns the warning threshold and the limit of q in bytes.
+func quotaBytes(q config.QuotaConfig) (warn, limit int64) {
+	return q.WarnMB << 20, q.LimitMB << 20
+}
+
+// quotaState returns the state of used bytes against q.
+func quotaState(q config.QuotaConfig, used int64) string {
+	if !q.Enabled() {
+		return ""
+	}
+	warn, limit := quotaBytes(q)
+	switch {
+	case limit > 0 && used > limit:
+		return QuotaExceeded
+	case warn > 0 && used > warn:
+		return QuotaWarning
+	}
+	return QuotaOK
+}
+
+// Usage reports the storage used by the backups of every database in the
+// catalog of the given configuration, against the quotas it sets. Local
+// usage is measured on disk; remote usage is taken from the catalog, so the
+// storage backend is never contacted.
+func Usage(configPath string) (UsageReport, error) {
+	var cfg config.Config
+	if err := cfg.Load(configPath); err != nil {
+		return UsageReport{}, err
+	}
+	entries, err := LoadCatalog(cfg.Backup.Directory)
+	if err != nil {
+		return UsageReport{}, err
+	}
+
+	report := UsageReport{GeneratedAt: time.Now()}
+	for _, entry := range entries {
+		dir := filepath.Dir(entry.Path)
+		usage := DatabaseUsage{
+			Engine:   entry.Record.Engine,
+			Database: entry.Record.Database,
+			Instance: instanceOf(&cfg, entry.Record.Engine, entry.Record.Database),
+		}
+		if usage.LocalBytes, err = localUsage(dir); err != nil {
+			return UsageReport{}, err
+		}
+		history, err := LoadHistory(dir)
+		if err != nil {
+			return UsageReport{}, err
+		}
+		for _, record := range history {
+			if !record.Restorable() {
+				continue
+			}
+			usage.Backups++
+			if record.RemotePath != "" {
+				usage.RemoteBytes += record.SizeBytes
+			}
+		}
+		quota := cfg.InstanceQuota(usage.Engine, usage.Instance)
+		usage.WarnBytes, usage.LimitBytes = quotaBytes(quota)
+		usage.Quota = quotaState(quota, usage.LocalBytes)
+
+		report.LocalBytes += usage.LocalBytes
+		report.RemoteBytes += usage.RemoteBytes
+		report.Databases = append(report.Databases, usage)
+	}
+	report.WarnBytes, report.LimitBytes = quotaBytes(cfg.Quota)
+	report.Quota = quotaState(cfg.Quota, report.LocalBytes)
+	sort.Slice(report.Databases, func(i, j int) bool {
+		a, b := report.Databases[i], report.Databases[j]
+		if a.Engine != b.Engine {
+			return a.Engine < b.Engine
+		}
+		return a.Database < b.Database
+	})
+	return report, nil
+}
+
+// instanceOf returns the name of the instance of engine that backs up
+// database: the one naming it, else one backing up every database.
+func instanceOf(cfg *config.Config, engine, database string) string {
+	group, _ := cfg.EngineGroup(engine)
+	name := ""
+	for _, inst := range group.Instances {
+		switch inst.Database {
+		case database:
+			return inst.Name
+		case config.AllDatabases:
+			name = inst.Name
+		}
+	}
+	return name
+}
+
+// localUsage returns the space used by the artifacts in the database
+// directory dir; a directory not created yet uses none.
+func localUsage(dir string) (int64, error) {
+	samples, err := artifactSamples(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	var total int64
+	for _, s := range samples {
+		total += s.SizeBytes
+	}
+	return total, nil
+}
+
+// directoryUsage returns the space used by the artifacts of every database
+// in the backup directory dir, tenants aside.
+func directoryUsage(dir string) (int64, error) {
+	var total int64
+	err := walkDatabases(dir, func(_, _, dbDir string) error {
+		used, err := localUsage(dbDir)
+		total += used
+		return err
+	})
+	return total, err
+}
+
+// checkQuota fails the backup of db when the artifacts already in dir plus
+// the estimated size of the new one exceed the quota of its instance, or
+// those of the whole backup directory exceed the directory's quota. Over a
+// warning threshold it only logs. Without an estimate (preflight disabled
+// or unsupported) the stored artifacts alone are checked. Usage that cannot
+// be measured is logged and never blocks the backup.
+func (operator *Operator) checkQuota(db database.Database, dir string, estimate int64) error {
+	instance := ""
+	if named, ok := db.(database.Instancer); ok {
+		instance = named.GetInstance()
+	}
+	checks := []struct {
+		scope string
+		quota config.QuotaConfig
+		usage func() (int64, error)
+	}{
+		{"database", operator.config.InstanceQuota(db.GetEngine(), instance), func() (int64, error) {
+			return localUsage(dir)
+		}},
+		{"backup directory", operator.config.Quota, func() (int64, error) {
+			return directoryUsage(operator.config.Backup.Directory)
+		}},
+	}
+	for _, check := range checks {
+		if !check.quota.Enabled() {
+			continue
+		}
+		used, err := check.usage()
+		if err != nil {
+			operator.log.Warn("storage usage unavailable, skipping quota",
+				"database", db.GetName(),
+				"scope", check.scope,
+				"error", err.Error(),
+			)
+			continue
+		}
+		warn, limit := quotaBytes(check.quota)
+		switch quotaState(check.quota, used+estimate) {
+		case QuotaExceeded:
+			return fmt.Errorf("%w: backing up %s would take the %s to about %d bytes (%d stored + estimate %d), limit %d",
+				ErrQuotaExceeded, db.GetName(), check.scope, used+estimate, used, estimate, limit)
+		case QuotaWarning:
+			operator.log.Warn("storage quota is running low",
+				"database", db.GetName(),
+				"scope", check.scope,
+				"used_bytes", used,
+				"estimated_bytes", estimate,
+				"warn_bytes", warn,
+				"limit_bytes", limit,
+			)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package operations
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kebairia/backup/internal/config"
+)
+
+func TestQuotaState(t *testing.T) {
+	quota := config.QuotaConfig{WarnMB: 1, LimitMB: 2}
+	for used, want := range map[int64]string{
+		0:       QuotaOK,
+		1 << 20: QuotaOK,
+		3 << 19: QuotaWarning,
+		3 << 20: QuotaExceeded,
+	} {
+		if got := quotaState(quota, used); got != want {
+			t.Errorf("quotaState(%d) = %q, want %q", used, got, want)
+		}
+	}
+	if got := quotaState(config.QuotaConfig{}, 1<<40); got != "" {
+		t.Errorf("quotaState without quota = %q, want none", got)
+	}
+}
+
+func TestBackupQuota(t *testing.T) {
+	operator, db := flowOperator(t, &memStorage{})
+	if err := os.MkdirAll(db.dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	old := make([]byte, 3<<19) // 1.5 MiB of older backups
+	if err := os.WriteFile(filepath.Join(db.dir, "billing-old.dump"), old, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	operator.config.Quota = config.QuotaConfig{WarnMB: 1, LimitMB: 2}
+	if _, err := operator.backupDatabase(context.Background(), db); err != nil {
+		t.Fatalf("a backup over the warning threshold only must succeed: %v", err)
+	}
+
+	operator.config.Quota = config.QuotaConfig{LimitMB: 1}
+	record, err := operator.backupDatabase(context.Background(), db)
+	if !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("err = %v, want ErrQuotaExceeded", err)
+	}
+	if record.Status == StatusSuccess {
+		t.Errorf("the backup over quota was recorded as %q", record.Status)
+	}
+}
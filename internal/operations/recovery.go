@@ -0,0 +1,153 @@
+package operations
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/kebairia/backup/internal/database"
+	"github.com/kebairia/backup/internal/notify"
+)
+
+// InProgressFilename is the marker written next to the metadata record while
+// a backup runs. A marker left behind means the run never finished.
+const InProgressFilename = ".inprogress.json"
+
+// RunMarker identifies the process that started a backup.
+type RunMarker struct {
+	Engine    string    `json:"engine"`
+	Database  string    `json:"database"`
+	Host      string    `json:"host"`
+	PID       int       `json:"pid"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+// markInProgress writes the in-progress marker for db. The returned func
+// removes it once the run has been finalized.
+func (operator *Operator) markInProgress(db database.Database, metadataDir string) (func(), error) {
+	if err := EnsureDirectoryExist(metadataDir); err != nil {
+		return nil, err
+	}
+	host, _ := os.Hostname()
+	marker := RunMarker{
+		Engine:    db.GetEngine(),
+		Database:  db.GetName(),
+		Host:      host,
+		PID:       os.Getpid(),
+		StartedAt: time.Now(),
+	}
+	path := filepath.Join(metadataDir, InProgressFilename)
+	if err := WriteJSONAtomic(path, marker); err != nil {
+		return nil, fmt.Errorf("write in-progress marker: %w", err)
+	}
+	return func() {
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			operator.log.Warn("failed to remove in-progress marker",
+				"path", path,
+				"error", err.Error(),
+			)
+		}
+	}, nil
+}
+
+// recoverInterrupted finalizes a run of db that was killed before it could
+// write its metadata: the record is marked failed, artifacts written by the
+// dead run are cleaned up, and the marker is removed.
+// It returns nil when there is nothing to recover. Callers must hold the
+// database lock, so a marker found here cannot belong to a live run.
+func (operator *Operator) recoverInterrupted(metadataDir string) (*Metadata, error) {
+	path := filepath.Join(metadataDir, InProgressFilename)
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read in-progress marker: %w", err)
+	}
+	var marker RunMarker
+	if err := json.Unmarshal(raw, &marker); err != nil {
+		return nil, fmt.Errorf("decode in-progress marker %q: %w", path, err)
+	}
+
+	now := time.Now()
+	record := &Metadata{
+		Engine:   marker.Engine,
+		Database: marker.Database,
+		FilePath: "N/A",
+		Status:   StatusFailed,
+		Error: fmt.Sprintf("run interrupted: process %d on %s did not finish",
+			marker.PID, marker.Host),
+		StartedAt:   marker.StartedAt,
+		CompletedAt: now,
+		Duration:    now.Sub(marker.StartedAt),
+	}
+
+	// Anything written to the directory since the run started is partial.
+	entries, err := os.ReadDir(metadataDir)
+	if err != nil {
+		return nil, fmt.Errorf("read %q: %w", metadataDir, err)
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if isCatalogFile(name) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || info.ModTime().Before(marker.StartedAt) {
+			continue
+		}
+		operator.cleanupPartial(record, filepath.Join(metadataDir, name))
+	}
+	// Anything left in the work directory never finished.
+	if work := operator.workDir(metadataDir); work != metadataDir {
+		entries, err := os.ReadDir(work)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("read %q: %w", work, err)
+		}
+		for _, entry := range entries {
+			operator.cleanupPartial(record, filepath.Join(work, entry.Name()))
+		}
+	}
+
+	if err := record.Write(metadataDir); err != nil {
+		return nil, err
+	}
+	if err := os.Remove(path); err != nil {
+		return nil, fmt.Errorf("remove in-progress marker: %w", err)
+	}
+	operator.log.Warn("interrupted run recovered",
+		"database", record.Database,
+		"engine", record.Engine,
+		"started_at", marker.StartedAt.Format(time.RFC3339),
+		"host", marker.Host,
+		"pid", marker.PID,
+	)
+	return record, nil
+}
+
+// recordInterrupted remembers a recovered run for notifyInterrupted.
+func (operator *Operator) recordInterrupted(record *Metadata) {
+	operator.mu.Lock()
+	defer operator.mu.Unlock()
+	operator.interrupted = append(operator.interrupted, record)
+}
+
+// notifyInterrupted reports recovered runs as a separate "recovery" report
+// when backup.notify_interrupted is set.
+func (operator *Operator) notifyInterrupted() {
+	operator.mu.Lock()
+	defer operator.mu.Unlock()
+	if !operator.config.Backup.NotifyInterrupted || len(operator.interrupted) == 0 {
+		return
+	}
+	now := time.Now()
+	report := notify.Report{Operation: "recovery", RunID: operator.runID, StartedAt: now, CompletedAt: now}
+	for _, record := range operator.interrupted {
+		report.Results = append(report.Results, newResult(record))
+	}
+	operator.notify(report)
+}
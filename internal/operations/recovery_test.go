@@ -0,0 +1,84 @@
+package operations
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kebairia/backup/internal/database"
+	"github.com/kebairia/backup/internal/logger"
+)
+
+// nopLogger discards every log entry.
+type nopLogger struct{}
+
+func (nopLogger) Debug(string, ...any) {}
+func (nopLogger) Info(string, ...any)  {}
+func (nopLogger) Warn(string, ...any)  {}
+func (nopLogger) Error(string, ...any) {}
+
+func (l nopLogger) With(...any) logger.Logger { return l }
+
+func TestRecoverInterrupted(t *testing.T) {
+	dir := t.TempDir()
+	operator := &Operator{log: nopLogger{}}
+
+	// Nothing to recover without a marker.
+	if record, err := operator.recoverInterrupted(dir); err != nil || record != nil {
+		t.Fatalf("got (%v, %v), want (nil, nil)", record, err)
+	}
+
+	old := filepath.Join(dir, "old.dump")
+	if err := os.WriteFile(old, []byte("complete"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	past := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(old, past, past); err != nil {
+		t.Fatal(err)
+	}
+	marker := RunMarker{Engine: "postgres", Database: "app", Host: "db1", PID: 42,
+		StartedAt: time.Now().Add(-time.Minute)}
+	if err := WriteJSONAtomic(filepath.Join(dir, InProgressFilename), marker); err != nil {
+		t.Fatal(err)
+	}
+	partial := filepath.Join(dir, "new.dump")
+	if err := os.WriteFile(partial, []byte("trunc"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	unpublished := filepath.Join(dir, database.PartialDirname, "newer.dump")
+	if err := os.MkdirAll(filepath.Dir(unpublished), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(unpublished, []byte("trunc"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	record, err := operator.recoverInterrupted(dir)
+	if err != nil {
+		t.Fatalf("recover: %v", err)
+	}
+	if record == nil || record.Status != StatusFailed || record.Database != "app" {
+		t.Fatalf("unexpected record %+v", record)
+	}
+	if _, err := os.Stat(partial); !os.IsNotExist(err) {
+		t.Errorf("partial artifact was not removed")
+	}
+	if _, err := os.Stat(unpublished); !os.IsNotExist(err) {
+		t.Errorf("unpublished artifact was not removed")
+	}
+	if _, err := os.Stat(old); err != nil {
+		t.Errorf("older artifact was removed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, InProgressFilename)); !os.IsNotExist(err) {
+		t.Errorf("marker was not removed")
+	}
+
+	var written Metadata
+	if err := written.Load(filepath.Join(dir, MetadataFilename)); err != nil {
+		t.Fatalf("load metadata: %v", err)
+	}
+	if written.Status != StatusFailed || len(written.CleanedUp) != 2 {
+		t.Errorf("unexpected metadata %+v", written)
+	}
+}
@@ -0,0 +1,34 @@
+//go:build windows
+
+package operations
+
+import (
+	"errors"
+	"os"
+	"time"
+
+	"golang.org/x/sys/windows"
+)
+
+// replaceAttempts bounds the retries of replaceFile.
+const replaceAttempts = 10
+
+// replaceFile renames from over to. Windows refuses to replace a file another
+// process has open (e.g. `bacli serve` reading the catalog), so the rename is
+// retried for a short while.
+func replaceFile(from, to string) error {
+	var err error
+	for attempt := range replaceAttempts {
+		if err = os.Rename(from, to); err == nil || !inUse(err) {
+			return err
+		}
+		time.Sleep(time.Duration(attempt+1) * 20 * time.Millisecond)
+	}
+	return err
+}
+
+// inUse reports whether err is Windows refusing access to an open file.
+func inUse(err error) bool {
+	return errors.Is(err, windows.ERROR_SHARING_VIOLATION) ||
+		errors.Is(err, windows.ERROR_ACCESS_DENIED)
+}
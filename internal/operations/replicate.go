@@ -0,0 +1,264 @@
+package operations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/kebairia/backup/internal/config"
+	"github.com/kebairia/backup/internal/storage"
+	"github.com/kebairia/backup/internal/telemetry"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// ErrNoReplicas indicates that no storage replica is configured.
+var ErrNoReplicas = errors.New("no storage replicas configured")
+
+// ErrUnknownReplica indicates that a selected replica is not configured.
+var ErrUnknownReplica = errors.New("unknown storage replica")
+
+// replica is a secondary backend receiving a copy of every uploaded artifact
+// (storage.replicas).
+type replica struct {
+	name    string
+	backend storage.Backend
+}
+
+// Replication records the copy of an artifact on one replica.
+type Replication struct {
+	Status       string    `json:"status"`
+	Error        string    `json:"error,omitempty"`
+	ReplicatedAt time.Time `json:"replicated_at"`
+}
+
+// buildReplicas creates the replica backends of the configuration.
+func buildReplicas(
+	ctx context.Context,
+	cfg config.StorageConfig,
+	vaultClient Vault,
+) ([]replica, error) {
+	replicas := make([]replica, 0, len(cfg.Replicas))
+	for _, r := range cfg.Replicas {
+		backend, err := buildStorage(ctx, r.Backend(), vaultClient)
+		if err != nil {
+			return nil, fmt.Errorf("replica %s: %w", r.Name, err)
+		}
+		replicas = append(replicas, replica{name: r.Name, backend: backend})
+	}
+	return replicas, nil
+}
+
+// replicate copies the artifact of record, found at localPath, to replicas
+// and records each outcome in record.Replicas. A failed copy is logged and
+// left for `bacli replicate --missing`: the primary copy is intact, so the
+// backup itself still succeeds.
+func (operator *Operator) replicate(
+	ctx context.Context,
+	record *Metadata,
+	localPath string,
+	replicas []replica,
+) {
+	key, keyErr := operator.storageKey(record.FilePath)
+	for _, r := range replicas {
+		err := keyErr
+		if err == nil {
+			_, span := telemetry.Start(ctx, "replicate",
+				attribute.String("storage.replica", r.name),
+				attribute.String("storage.key", key),
+			)
+			err = uploadTo(withRunID(ctx, record.RunID), r.backend, localPath, key, record.Labels)
+			telemetry.End(span, err)
+		}
+		status := Replication{Status: StatusSuccess, ReplicatedAt: time.Now()}
+		if err != nil {
+			status.Status, status.Error = StatusFailed, err.Error()
+			operator.log.Warn("artifact replication failed",
+				"database", record.Database,
+				"engine", record.Engine,
+				"replica", r.name,
+				"error", err.Error(),
+			)
+		} else {
+			operator.log.Info("artifact replicated",
+				"database", record.Database,
+				"engine", record.Engine,
+				"replica", r.name,
+				"key", key,
+			)
+		}
+		if record.Replicas == nil {
+			record.Replicas = make(map[string]Replication, len(replicas))
+		}
+		record.Replicas[r.name] = status
+	}
+}
+
+// replicateFile copies a catalog file (metadata, run manifest) to replicas.
+// Failures are only logged.
+func (operator *Operator) replicateFile(ctx context.Context, localPath string, replicas []replica) {
+	key, err := operator.storageKey(localPath)
+	if err != nil {
+		return
+	}
+	for _, r := range replicas {
+		if err := uploadObject(ctx, r.backend, localPath, key, nil); err != nil {
+			operator.log.Warn("failed to replicate file", "replica", r.name, "key", key, "error", err.Error())
+		}
+	}
+}
+
+// deleteReplicas removes the copies of the artifact stored under key from
+// every replica that holds one, forgetting them in record.Replicas.
+func (operator *Operator) deleteReplicas(ctx context.Context, record *Metadata, key string) error {
+	var errs []error
+	for _, r := range operator.replicas {
+		if _, ok := record.Replicas[r.name]; !ok {
+			continue
+		}
+		if err := deleteFrom(ctx, r.backend, key); err != nil {
+			errs = append(errs, fmt.Errorf("replica %s: %w", r.name, err))
+			continue
+		}
+		delete(record.Replicas, r.name)
+	}
+	return errors.Join(errs...)
+}
+
+// ReplicateOptions selects what Replicate copies.
+type ReplicateOptions struct {
+	Missing bool   // skip copies already replicated successfully
+	Replica string // copy to this replica only (default: all)
+	DryRun  bool   // only report what would be copied
+}
+
+// ReplicaCopy is an artifact copied, or to be copied, to a replica.
+type ReplicaCopy struct {
+	Engine    string    `json:"engine"`
+	Database  string    `json:"database"`
+	Replica   string    `json:"replica"`
+	Key       string    `json:"key"`
+	StartedAt time.Time `json:"started_at"` // of the backup
+	SizeBytes int64     `json:"size_bytes"`
+	Status    string    `json:"status,omitempty"` // empty on dry runs
+	Error     string    `json:"error,omitempty"`
+}
+
+// ReplicateResult describes a replicate run.
+type ReplicateResult struct {
+	DryRun bool          `json:"dry_run"`
+	Copies []ReplicaCopy `json:"copies"`
+	Failed int           `json:"failed"`
+}
+
+// Replicate copies the uploaded artifacts of the catalog to the storage
+// replicas, downloading them from the primary backend when the local copy
+// is gone. With Missing, only copies not yet recorded as replicated are
+// made, which fills the gaps left by failed or disabled replication.
+func Replicate(ctx context.Context, configPath string, opts ReplicateOptions) (ReplicateResult, error) {
+	result := ReplicateResult{DryRun: opts.DryRun, Copies: []ReplicaCopy{}}
+	operator, err := NewOperator(ctx, configPath)
+	if err != nil {
+		return result, err
+	}
+	replicas := operator.replicas
+	if len(replicas) == 0 {
+		return result, ErrNoReplicas
+	}
+	if opts.Replica != "" {
+		replicas = nil
+		for _, r := range operator.replicas {
+			if r.name == opts.Replica {
+				replicas = append(replicas, r)
+			}
+		}
+		if len(replicas) == 0 {
+			return result, fmt.Errorf("%w: %q", ErrUnknownReplica, opts.Replica)
+		}
+	}
+
+	var errs []error
+	err = walkDatabases(operator.config.Backup.Directory, func(engine, db, dbDir string) error {
+		if _, err := os.Stat(filepath.Join(dbDir, InProgressFilename)); err == nil {
+			return nil
+		}
+		history, err := LoadHistory(dbDir)
+		if err != nil {
+			return err
+		}
+		changed := false
+		for i := range history {
+			if err := operator.cancelled(); err != nil {
+				return err
+			}
+			record := &history[i]
+			if !record.Restorable() || record.RemotePath == "" {
+				continue
+			}
+			var targets []replica
+			for _, r := range replicas {
+				if !opts.Missing || record.Replicas[r.name].Status != StatusSuccess {
+					targets = append(targets, r)
+				}
+			}
+			if len(targets) == 0 {
+				continue
+			}
+			key, err := operator.storageKey(record.FilePath)
+			if err != nil {
+				return err
+			}
+			var fetchErr error
+			if !opts.DryRun {
+				var (
+					localPath string
+					cleanup   func()
+				)
+				localPath, cleanup, fetchErr = operator.fetchArtifact(*record)
+				if fetchErr == nil {
+					operator.replicate(operator.ctx, record, localPath, targets)
+					cleanup()
+					if err := record.Write(dbDir); err != nil {
+						errs = append(errs, err)
+					}
+					changed = true
+				}
+			}
+			for _, r := range targets {
+				copied := ReplicaCopy{
+					Engine:    engine,
+					Database:  db,
+					Replica:   r.name,
+					Key:       key,
+					StartedAt: record.StartedAt,
+					SizeBytes: record.SizeBytes,
+				}
+				switch {
+				case opts.DryRun:
+				case fetchErr != nil:
+					copied.Status, copied.Error = StatusFailed, fetchErr.Error()
+				default:
+					copied.Status = record.Replicas[r.name].Status
+					copied.Error = record.Replicas[r.name].Error
+				}
+				if copied.Status == StatusFailed {
+					result.Failed++
+				}
+				result.Copies = append(result.Copies, copied)
+			}
+		}
+		if changed {
+			operator.replicateFile(operator.ctx, filepath.Join(dbDir, MetadataFilename), replicas)
+		}
+		return nil
+	})
+	if err != nil {
+		return result, err
+	}
+	if result.Failed > 0 {
+		errs = append(errs, fmt.Errorf("%d copies failed", result.Failed))
+	}
+	return result, errors.Join(errs...)
+}
@@ -0,0 +1,56 @@
+package operations
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kebairia/backup/internal/config"
+	"github.com/kebairia/backup/internal/storage"
+)
+
+func TestReplicate(t *testing.T) {
+	dir := t.TempDir()
+	artifact := filepath.Join(dir, "postgres", "billing", "billing.dump")
+	if err := os.MkdirAll(filepath.Dir(artifact), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(artifact, []byte("dump"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	dr, err := storage.NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a replica rooted at a regular file cannot store anything
+	blocked := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(blocked, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	broken, err := storage.NewLocal(blocked)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var cfg config.Config
+	cfg.Backup.Directory = dir
+	operator := &Operator{config: cfg, log: nopLogger{}}
+	record := &Metadata{Engine: "postgres", Database: "billing", FilePath: artifact}
+	operator.replicate(context.Background(), record, artifact,
+		[]replica{{name: "dr", backend: dr}, {name: "broken", backend: broken}})
+
+	if got := record.Replicas["dr"].Status; got != StatusSuccess {
+		t.Errorf("dr status = %q, want %q", got, StatusSuccess)
+	}
+	if got := record.Replicas["broken"]; got.Status != StatusFailed || got.Error == "" {
+		t.Errorf("broken replication = %+v, want a failure with its error", got)
+	}
+	objects, err := dr.List(context.Background(), "postgres/billing/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 1 || objects[0].Key != "postgres/billing/billing.dump" {
+		t.Errorf("dr objects = %+v, want the artifact under its storage key", objects)
+	}
+}
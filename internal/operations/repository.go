@@ -0,0 +1,156 @@
+package operations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kebairia/backup/internal/chunkstore"
+	"github.com/kebairia/backup/internal/telemetry"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// ErrNoRepository indicates that no chunk repository is configured.
+var ErrNoRepository = errors.New("no chunk repository configured")
+
+// repository opens the chunk repository on first use. It is opened lazily
+// so that `bacli repo init` can run against a backend that holds none yet.
+func (operator *Operator) repository() (*chunkstore.Repository, error) {
+	if operator.repoBackend == nil {
+		return nil, ErrNoRepository
+	}
+	operator.repoOnce.Do(func() {
+		operator.repo, operator.repoErr = chunkstore.Open(operator.ctx, operator.repoBackend,
+			chunkstore.WithPackSize(operator.config.Repository.PackSizeMB<<20))
+		if operator.repoErr != nil {
+			operator.repoErr = fmt.Errorf("open repository: %w", operator.repoErr)
+		}
+	})
+	return operator.repo, operator.repoErr
+}
+
+// saveToRepository stores the artifact at path, as the engine wrote it, in
+// the chunk repository and records the snapshot in record. Compression
+// happens per chunk in the repository: compressing first would keep
+// unchanged data from deduplicating.
+func (operator *Operator) saveToRepository(ctx context.Context, record *Metadata, path string) (err error) {
+	repo, err := operator.repository()
+	if err != nil {
+		return err
+	}
+	name, err := operator.storageKey(path)
+	if err != nil {
+		return err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open artifact: %w", err)
+	}
+	defer file.Close()
+
+	ctx, span := telemetry.Start(ctx, "repository.save", attribute.String("repository.snapshot.name", name))
+	defer func() { telemetry.End(span, err) }()
+	snapshot, err := repo.Save(ctx, name, file)
+	if err != nil {
+		return err
+	}
+	record.Snapshot = snapshot.ID
+	operator.log.Info("artifact stored in repository",
+		"database", record.Database,
+		"engine", record.Engine,
+		"snapshot", snapshot.ID,
+		"bytes", snapshot.Size,
+		"added_bytes", snapshot.Added,
+	)
+	return nil
+}
+
+// fetchSnapshot restores the repository snapshot of record into a hidden
+// directory of its database directory, as fetchArtifact does for storage.
+// The artifact is written as the engine produced it, uncompressed.
+func (operator *Operator) fetchSnapshot(record Metadata) (string, func(), error) {
+	repo, err := operator.repository()
+	if err != nil {
+		return "", nil, err
+	}
+	scratch, err := os.MkdirTemp(filepath.Dir(record.FilePath), ".fetch-*")
+	if err != nil {
+		return "", nil, fmt.Errorf("create download directory: %w", err)
+	}
+	cleanup := func() { os.RemoveAll(scratch) }
+	path := filepath.Join(scratch, strings.TrimSuffix(filepath.Base(record.FilePath), ".zst"))
+	file, err := os.Create(path)
+	if err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("create %q: %w", path, err)
+	}
+	err = repo.Restore(operator.ctx, record.Snapshot, file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("restore snapshot %s: %w", record.Snapshot, err)
+	}
+	operator.log.Info("artifact fetched from repository",
+		"database", record.Database,
+		"engine", record.Engine,
+		"snapshot", record.Snapshot,
+	)
+	return path, cleanup, nil
+}
+
+// RepoInit creates the chunk repository configured under repository.
+func RepoInit(ctx context.Context, configPath string) error {
+	operator, err := NewOperator(ctx, configPath)
+	if err != nil {
+		return err
+	}
+	if operator.repoBackend == nil {
+		return ErrNoRepository
+	}
+	if _, err := chunkstore.Init(operator.ctx, operator.repoBackend); err != nil {
+		return err
+	}
+	operator.log.Info("repository initialized", "backend", operator.repoBackend.Name())
+	return nil
+}
+
+// RepoCheck checks the chunk repository, reading every pack with readData.
+func RepoCheck(ctx context.Context, configPath string, readData bool) (chunkstore.CheckResult, error) {
+	repo, err := openRepository(ctx, configPath)
+	if err != nil {
+		return chunkstore.CheckResult{}, err
+	}
+	return repo.Check(ctx, readData)
+}
+
+// RepoStats returns the statistics of the chunk repository.
+func RepoStats(ctx context.Context, configPath string) (chunkstore.Stats, error) {
+	repo, err := openRepository(ctx, configPath)
+	if err != nil {
+		return chunkstore.Stats{}, err
+	}
+	return repo.Stats(ctx)
+}
+
+// RepoPrune deletes the packs of the chunk repository that no snapshot
+// uses any more. Snapshots are forgotten by retention (see Prune).
+func RepoPrune(ctx context.Context, configPath string) (chunkstore.PruneResult, error) {
+	repo, err := openRepository(ctx, configPath)
+	if err != nil {
+		return chunkstore.PruneResult{}, err
+	}
+	return repo.Prune(ctx)
+}
+
+func openRepository(ctx context.Context, configPath string) (*chunkstore.Repository, error) {
+	operator, err := NewOperator(ctx, configPath)
+	if err != nil {
+		return nil, err
+	}
+	return operator.repository()
+}
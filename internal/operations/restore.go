@@ -1,12 +1,18 @@
 package operations
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"path/filepath"
+	"strings"
 	"sync"
+	"time"
 
+	"github.com/kebairia/backup/internal/config"
 	"github.com/kebairia/backup/internal/database"
-	"github.com/kebairia/backup/internal/logger"
+	"github.com/kebairia/backup/internal/events"
+	"github.com/kebairia/backup/internal/notify"
 )
 
 // RestoreDatabase runs a single restore against one Database.
@@ -14,29 +20,235 @@ import (
 // NOTE: Check for metadata.json in the backup directory,
 // NOTE: if not exist, return an error
 func (operator *Operator) RestoreDatabase(db database.Database, record Metadata) error {
-	// decompress the file if the compress=true
-	if operator.config.Backup.Compression {
-		decPath, err := DecompressZstd(record.FilePath)
+	if streamed, err := operator.streamRestore(db, record); streamed {
+		return err
+	}
+	// download, decrypt and decompress the artifact if needed
+	path, cleanup, err := operator.openRecord(record)
+	if err != nil {
+		return err
+	}
+	// Remove the temporary plaintext files
+	defer cleanup()
+	path, cleanupTransform, err := operator.transformArtifact(db, path)
+	if err != nil {
+		return err
+	}
+	defer cleanupTransform()
+
+	operator.checkRestoreTool(db, record)
+	if err := db.Restore(operator.ctx, path); err != nil {
+		return fmt.Errorf("restore failed: %w", err)
+	}
+	return nil
+}
+
+// replayIncrementals applies records, the incremental backups of a chain
+// in order, over the restored full backup of db, stopping before until.
+func (operator *Operator) replayIncrementals(db database.Database, records []Metadata, until time.Time) error {
+	incrementer, ok := db.(database.Incrementer)
+	if !ok {
+		return fmt.Errorf("%s does not support incremental backups", db.GetEngine())
+	}
+	for _, record := range records {
+		path, cleanup, err := operator.openRecord(record)
 		if err != nil {
 			return err
 		}
-		// Remove the decompressed files
-		defer RemoveFile(decPath)
+		err = incrementer.RestoreIncremental(operator.ctx, path, until)
+		cleanup()
+		if err != nil {
+			return fmt.Errorf("replay incremental backup of %s: %w",
+				record.StartedAt.Format(time.RFC3339), err)
+		}
+	}
+	return nil
+}
 
-		record.FilePath = decPath
+// streamRestore restores the artifact of record by piping it, decrypted and
+// decompressed on the fly, into an engine that restores from a reader
+// (restore.stream), so that the plaintext never needs disk space next to
+// the artifact. Plain SQL dumps, compressed with zstd or gzip, are always
+// piped: their tool reads them front to back either way. It reports false,
+// with nothing restored, when the artifact has nothing to unpack or the
+// engine's method cannot be piped; the restore then goes through disk.
+func (operator *Operator) streamRestore(db database.Database, record Metadata) (bool, error) {
+	piper, ok := db.(database.Piper)
+	dumper, sql := db.(database.SQLDumper)
+	plain := sql && dumper.PlainSQL()
+	if !ok || (!operator.config.Restore.Stream && !plain) {
+		return false, nil
 	}
-	if err := db.Restore(record.FilePath); err != nil {
-		return fmt.Errorf("restore failed: %w", err)
+	instance := ""
+	if named, ok := db.(database.Instancer); ok {
+		instance = named.GetInstance()
 	}
-	return nil
+	if operator.config.Transform(db.GetEngine(), instance).Enabled() {
+		return false, nil // transforms rewrite a file
+	}
+	fetched, release, err := operator.fetchArtifact(record)
+	if err != nil {
+		return true, err
+	}
+	defer release()
+	if isDir(fetched) || (!encrypted(fetched) && !strings.HasSuffix(fetched, ".zst") &&
+		!(plain && strings.HasSuffix(fetched, ".gz"))) {
+		return false, nil
+	}
+	if operator.config.Backup.Signing.VerifyRestore {
+		if err := operator.verifySignature(record, fetched); err != nil {
+			return true, err
+		}
+	}
+	r, closeStream, err := operator.openStream(fetched, plain)
+	if err != nil {
+		return true, err
+	}
+	defer closeStream()
+
+	operator.checkRestoreTool(db, record)
+	err = piper.RestoreFrom(r)
+	if errors.Is(err, database.ErrNotPipeable) {
+		operator.log.Warn("restore cannot be streamed, unpacking the artifact on disk",
+			"database", db.GetName(),
+			"engine", db.GetEngine(),
+			"error", err.Error(),
+		)
+		return false, nil
+	}
+	if err != nil {
+		return true, fmt.Errorf("restore failed: %w", err)
+	}
+	operator.log.Info("restore streamed",
+		"database", db.GetName(),
+		"engine", db.GetEngine(),
+		"path", fetched,
+	)
+	return true, nil
 }
 
-func RestoreAll(configPath string) error {
-	log := logger.Global()
-	operator, err := NewOperator(configPath)
+// checkRestoreTool warns when the restore tool of db is known not to read
+// artifacts of the dump tool recorded in record. The restore still runs:
+// the tool reports the actual failure.
+func (operator *Operator) checkRestoreTool(db database.Database, record Metadata) {
+	user, ok := db.(database.ToolUser)
+	if !ok || record.DumpTool == nil || user.RestoreTool() == "" {
+		return
+	}
+	tool, err := database.FindTool(db, user.RestoreTool())
 	if err != nil {
-		return err
+		return // the restore reports a missing tool
+	}
+	if err := database.CheckRestoreCompatibility(record.Engine, *record.DumpTool, tool); err != nil {
+		operator.log.Warn("restore tool may not read this backup",
+			"database", record.Database,
+			"engine", record.Engine,
+			"error", err,
+		)
 	}
+}
+
+// RestoreOptions narrows and redirects a restore run.
+type RestoreOptions struct {
+	Engine         string    // restore only databases of this engine (empty restores all)
+	Instance       string    // restore only this instance (see matchDatabase)
+	Database       string    // restore only this database (empty restores all)
+	At             time.Time // restore the run started at this second instead of the latest
+	TargetTime     time.Time // restore as of this time, replaying incremental backups (see LoadPointInTime)
+	Run            string    // restore every successful backup of this run (see RunManifest)
+	TargetDatabase string    // restore into this database name instead of the original
+	TargetHost     string    // restore onto this host instead of the original
+	VerifyOnly     bool      // validate artifacts without touching any database
+	Force          bool      // replace targets that hold data (see checkOverwrite)
+}
+
+var (
+	// ErrRestoreTarget indicates invalid restore target options.
+	ErrRestoreTarget = errors.New("invalid restore target")
+	// ErrTargetNotEmpty indicates that a restore would drop and replace a
+	// target database that already holds data.
+	ErrTargetNotEmpty = errors.New("restore target is not empty")
+	// ErrDependencyFailed indicates a restore skipped because a database
+	// it depends on (depends_on) was not restored.
+	ErrDependencyFailed = errors.New("dependency not restored")
+)
+
+// checkOverwrite refuses to restore db into a target that holds data, since
+// the restore drops what it replaces, unless force is set or the instance
+// allows it with allow_overwrite.
+func checkOverwrite(db database.Database, force bool) error {
+	guard, ok := db.(database.OverwriteGuard)
+	if !ok || force || guard.OverwriteAllowed() {
+		return nil
+	}
+	objects, err := guard.TargetObjects()
+	if err != nil {
+		return fmt.Errorf("inspect restore target: %w", err)
+	}
+	if len(objects) == 0 {
+		return nil
+	}
+	shown := objects
+	if len(shown) > 3 {
+		shown = append(shown[:3:3], "...")
+	}
+	return fmt.Errorf("%w: it holds %d tables or collections (%s); use --force or set allow_overwrite: true",
+		ErrTargetNotEmpty, len(objects), strings.Join(shown, ", "))
+}
+
+// ValidateDatabase checks a backup artifact end to end (decryption,
+// decompression and engine-level structural listing) without touching any
+// database.
+func (operator *Operator) ValidateDatabase(db database.Database, record Metadata) ([]string, error) {
+	validator, ok := db.(database.Validator)
+	if !ok {
+		return nil, fmt.Errorf("%s does not support artifact validation", db.GetEngine())
+	}
+	path, cleanup, err := operator.openRecord(record)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+	return validator.ValidateArtifact(path)
+}
+
+// openRecord opens the artifact of record with openArtifact, downloading it
+// first when retention pruned its local copy. With signing.verify_restore,
+// file artifacts must carry a valid signature.
+func (operator *Operator) openRecord(record Metadata) (string, func(), error) {
+	fetched, release, err := operator.fetchArtifact(record)
+	if err != nil {
+		return "", nil, err
+	}
+	if operator.config.Backup.Signing.VerifyRestore && !isDir(fetched) {
+		if err := operator.verifySignature(record, fetched); err != nil {
+			release()
+			return "", nil, err
+		}
+	}
+	path, cleanup, err := operator.openArtifact(fetched)
+	if err != nil {
+		release()
+		return "", nil, err
+	}
+	return path, func() {
+		cleanup()
+		release()
+	}, nil
+}
+
+// RestoreAll restores (or with VerifyOnly, validates) the latest backup of
+// every selected database and returns a report with one result per database.
+// An incremental backup is restored over the backups it applies on; with
+// TargetTime the chain is replayed up to that time.
+// Restores run in parallel, except that a database waits for those it
+// depends on (depends_on) and is skipped when one of them fails.
+func RestoreAll(ctx context.Context, configPath string, opts RestoreOptions) (notify.Report, error) {
+	operator, err := NewOperator(ctx, configPath)
+	if err != nil {
+		return notify.Report{}, err
+	}
+	log := operator.log
 
 	// 1) Initialize DB instances
 	databases, err := database.InitializeDatabases(
@@ -45,27 +257,198 @@ func RestoreAll(configPath string) error {
 		operator.vaultClient,
 	)
 	if err != nil {
-		return fmt.Errorf("initialize databases: %w", err)
+		return notify.Report{}, fmt.Errorf("initialize databases: %w", err)
+	}
+
+	// 2) Select and retarget instances
+	if !opts.TargetTime.IsZero() && (!opts.At.IsZero() || opts.Run != "") {
+		return notify.Report{}, fmt.Errorf("%w: --target-time excludes --at and --run", ErrRestoreTarget)
+	}
+	var points map[string]time.Time
+	if opts.Run != "" {
+		if !opts.At.IsZero() {
+			return notify.Report{}, fmt.Errorf("%w: --run and --at are exclusive", ErrRestoreTarget)
+		}
+		manifest, err := LoadRunManifest(operator.config.Backup.Directory, opts.Run)
+		if err != nil {
+			return notify.Report{}, err
+		}
+		databases, points, err = selectRunTargets(databases, manifest)
+		if err != nil {
+			return notify.Report{}, err
+		}
+	}
+	databases, err = selectRestoreTargets(databases, opts)
+	if err != nil {
+		return notify.Report{}, err
 	}
 
+	report := notify.Report{Operation: "restore", RunID: operator.runID, StartedAt: time.Now()}
+	if opts.VerifyOnly {
+		report.Operation = "verify"
+	}
 	record := Metadata{}
-	var wg sync.WaitGroup
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		failures []error
+	)
+	operator.emitRun(events.RunStarted, report, len(databases))
+	limiter := newHostLimiter(operator.config.Restore.MaxPerHost)
+	dependencies, err := restoreDependencies(operator.config, databases)
+	if err != nil {
+		return notify.Report{}, err
+	}
+	// done[i] is closed once databases[i] is restored; failed[i] is set
+	// before then when it was not
+	done := make([]chan struct{}, len(databases))
+	failed := make([]bool, len(databases))
+	for i := range done {
+		done[i] = make(chan struct{})
+	}
 
-	for _, db := range databases {
+	for i, db := range databases {
 		wg.Add(1)
 
-		go func(db database.Database, record Metadata) {
+		go func(i int, db database.Database, record Metadata) {
 			defer wg.Done()
-			// increament my waiting list by one since I'm doing a new backup
-			metadataFile := filepath.Join(
-				operator.config.Backup.Directory,
-				db.GetEngine(),
-				db.GetName(),
-				"metadata.json",
-			)
-			record.Load(metadataFile)
-
-			err := operator.RestoreDatabase(db, record)
+			defer close(done[i])
+
+			start := time.Now()
+			result := notify.Result{
+				Engine:   db.GetEngine(),
+				Database: db.GetName(),
+				Status:   notify.StatusSuccess,
+			}
+			var err error
+			defer func() {
+				result.Duration = time.Since(start)
+				result.FilePath = record.FilePath
+				if err != nil {
+					result.Status = notify.StatusFailed
+					result.Error = err.Error()
+					failed[i] = true
+				}
+				mu.Lock()
+				report.Results = append(report.Results, result)
+				if err != nil {
+					failures = append(failures, fmt.Errorf("restore failed for %q: %w", db.GetName(), err))
+				}
+				mu.Unlock()
+			}()
+
+			// wait for the databases this one depends on
+			if len(dependencies[i]) > 0 {
+				log.Info("restore waiting for dependencies", "database", db.GetName())
+			}
+			for _, dep := range dependencies[i] {
+				<-done[dep]
+				if failed[dep] {
+					err = fmt.Errorf("%w: %s", ErrDependencyFailed, databases[dep].GetName())
+					log.Error("restore skipped",
+						"database", db.GetName(),
+						"error", err.Error(),
+					)
+					return
+				}
+			}
+
+			// queue behind other restores against the same host
+			host := db.GetHost()
+			if opts.TargetHost != "" {
+				host = opts.TargetHost
+			}
+			if !limiter.tryAcquire(host) {
+				log.Info("restore queued",
+					"database", db.GetName(),
+					"host", host,
+				)
+				limiter.acquire(host)
+			}
+			defer limiter.release(host)
+
+			// pick the newest successful backup, skipping failed runs after it
+			metadataDir := db.GetPath()
+			at := opts.At
+			if points != nil {
+				at = points[runKey(db.GetPath())]
+			}
+			var incrementals []Metadata // replayed over record with --target-time
+			switch {
+			case !opts.TargetTime.IsZero():
+				var chain []Metadata
+				if chain, err = LoadPointInTime(metadataDir, opts.TargetTime); err == nil {
+					record, incrementals = chain[0], chain[1:]
+				}
+			case at.IsZero():
+				record, err = LoadLatestRestorable(metadataDir)
+			default:
+				record, err = LoadRestorePoint(metadataDir, at)
+			}
+			if err == nil && opts.TargetTime.IsZero() && !record.Base.IsZero() {
+				// an incremental backup is restored over its chain
+				var chain []Metadata
+				if chain, err = LoadChainTo(metadataDir, record); err == nil {
+					record, incrementals = chain[0], chain[1:]
+				}
+			}
+			if err != nil {
+				log.Error("restore failed",
+					"database", db.GetName(),
+					"error", err.Error(),
+				)
+				return
+			}
+
+			if opts.VerifyOnly {
+				var entries []string
+				entries, err = operator.ValidateDatabase(db, record)
+				for _, incremental := range incrementals {
+					if err != nil {
+						break
+					}
+					_, err = operator.ValidateDatabase(db, incremental)
+				}
+				if err != nil {
+					log.Error("artifact verification failed",
+						"database", db.GetName(),
+						"error", err.Error(),
+					)
+					return
+				}
+				log.Info("artifact verified",
+					"database", db.GetName(),
+					"engine", db.GetEngine(),
+					"path", record.FilePath,
+					"objects", len(entries),
+				)
+				return
+			}
+
+			if err = checkOverwrite(db, opts.Force); err == nil {
+				operator.emit(events.RestoreStarted, db.GetEngine(), db.GetName(), map[string]any{
+					"file_path":  record.FilePath,
+					"started_at": record.StartedAt,
+				})
+				defer func() {
+					status, message := StatusSuccess, ""
+					if err != nil {
+						status, message = StatusFailed, err.Error()
+					}
+					operator.emit(events.RestoreCompleted, db.GetEngine(), db.GetName(), map[string]any{
+						"status":      status,
+						"duration_ms": time.Since(start).Milliseconds(),
+						"error":       message,
+					})
+				}()
+				stderr := operator.captureStderr(db, "")
+				err = operator.RestoreDatabase(db, record)
+				if err == nil && len(incrementals) > 0 {
+					err = operator.replayIncrementals(db, incrementals, opts.TargetTime)
+				}
+				err = stderr.finish(err)
+				result.Stderr = stderrOf(err)
+			}
 			// in case of error, add this error to the error channel
 			if err != nil {
 				log.Error("restore failed",
@@ -73,8 +456,172 @@ func RestoreAll(configPath string) error {
 					"error", err.Error(),
 				)
 			}
-		}(db, record)
+		}(i, db, record)
 	}
 	wg.Wait()
-	return nil
+
+	report.CompletedAt = time.Now()
+	operator.emitRun(events.RunCompleted, report, len(databases))
+	if err := operator.cancelled(); err != nil {
+		return report, err
+	}
+	if len(failures) > 0 {
+		return report, fmt.Errorf("%w: %w", ErrRunFailed, errors.Join(failures...))
+	}
+	return report, nil
+}
+
+// restoreDependencies returns, for every database, the indices of the
+// databases it waits for: the cluster-scoped artifacts of its own instance
+// (see database.ClusterScoped) and the databases of the instances it
+// depends on (see config.Dependencies). Dependencies outside the selection
+// are not waited for.
+func restoreDependencies(cfg config.Config, databases []database.Database) ([][]int, error) {
+	byInstance := make(map[config.InstanceRef][]int)
+	clusterScoped := make(map[config.InstanceRef][]int)
+	for i, db := range databases {
+		if named, ok := db.(database.Instancer); ok {
+			ref := config.InstanceRef{Engine: db.GetEngine(), Name: named.GetInstance()}
+			byInstance[ref] = append(byInstance[ref], i)
+			if scoped, ok := db.(database.ClusterScoped); ok && scoped.ClusterScoped() {
+				clusterScoped[ref] = append(clusterScoped[ref], i)
+			}
+		}
+	}
+	dependencies := make([][]int, len(databases))
+	for i, db := range databases {
+		named, ok := db.(database.Instancer)
+		if !ok {
+			continue
+		}
+		ref := config.InstanceRef{Engine: db.GetEngine(), Name: named.GetInstance()}
+		if scoped, ok := db.(database.ClusterScoped); !ok || !scoped.ClusterScoped() {
+			dependencies[i] = append(dependencies[i], clusterScoped[ref]...)
+		}
+		refs, err := cfg.Dependencies(db.GetEngine(), named.GetInstance())
+		if err != nil {
+			return nil, err
+		}
+		for _, ref := range refs {
+			dependencies[i] = append(dependencies[i], byInstance[ref]...)
+		}
+	}
+	return dependencies, nil
+}
+
+// selectRestoreTargets filters databases by name and applies target overrides.
+func selectRestoreTargets(
+	databases []database.Database,
+	opts RestoreOptions,
+) ([]database.Database, error) {
+	if opts.Engine != "" || opts.Instance != "" || opts.Database != "" {
+		var selected []database.Database
+		for _, db := range databases {
+			if matchDatabase(db, opts.Engine, opts.Instance, opts.Database) {
+				selected = append(selected, db)
+			}
+		}
+		if len(selected) == 0 {
+			return nil, fmt.Errorf("%w: no database matches engine %q, instance %q and name %q",
+				ErrRestoreTarget, opts.Engine, opts.Instance, opts.Database)
+		}
+		databases = selected
+	}
+
+	if opts.TargetDatabase == "" && opts.TargetHost == "" {
+		return databases, nil
+	}
+	if opts.TargetDatabase != "" && len(databases) != 1 {
+		return nil, fmt.Errorf(
+			"%w: --target-database needs exactly one database, %d selected (use --database)",
+			ErrRestoreTarget, len(databases),
+		)
+	}
+	for _, db := range databases {
+		retargeter, ok := db.(database.Retargeter)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s does not support restore targets", ErrRestoreTarget, db.GetEngine())
+		}
+		retargeter.Retarget(opts.TargetHost, opts.TargetDatabase)
+	}
+	return databases, nil
+}
+
+// selectRunTargets keeps the databases backed up successfully in run and
+// returns the start time of each one's backup, keyed by runKey.
+func selectRunTargets(
+	databases []database.Database,
+	run RunManifest,
+) ([]database.Database, map[string]time.Time, error) {
+	points := make(map[string]time.Time)
+	for _, artifact := range run.Artifacts {
+		if artifact.Status == StatusSuccess {
+			points[runKey(filepath.Dir(artifact.FilePath))] = artifact.StartedAt
+		}
+	}
+	var selected []database.Database
+	for _, db := range databases {
+		if _, ok := points[runKey(db.GetPath())]; ok {
+			selected = append(selected, db)
+		}
+	}
+	if len(selected) == 0 {
+		return nil, nil, fmt.Errorf("%w: run %s has no successful backup of a configured database",
+			ErrRestoreTarget, run.ID)
+	}
+	return selected, points, nil
+}
+
+// runKey identifies a database by its artifact directory, which tells apart
+// instances holding databases of the same name (see config.ArtifactDir).
+func runKey(dir string) string {
+	return filepath.Clean(dir)
+}
+
+// defaultRestoresPerHost is used when restore.max_per_host is unset.
+const defaultRestoresPerHost = 1
+
+// hostLimiter caps the number of concurrent operations per host.
+type hostLimiter struct {
+	mu    sync.Mutex
+	limit int
+	slots map[string]chan struct{}
+}
+
+func newHostLimiter(limit int) *hostLimiter {
+	if limit <= 0 {
+		limit = defaultRestoresPerHost
+	}
+	return &hostLimiter{limit: limit, slots: make(map[string]chan struct{})}
+}
+
+func (l *hostLimiter) slot(host string) chan struct{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	ch, ok := l.slots[host]
+	if !ok {
+		ch = make(chan struct{}, l.limit)
+		l.slots[host] = ch
+	}
+	return ch
+}
+
+// tryAcquire takes a slot for host without waiting and reports success.
+func (l *hostLimiter) tryAcquire(host string) bool {
+	select {
+	case l.slot(host) <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// acquire waits until a slot for host is free.
+func (l *hostLimiter) acquire(host string) {
+	l.slot(host) <- struct{}{}
+}
+
+// release frees a slot for host.
+func (l *hostLimiter) release(host string) {
+	<-l.slot(host)
 }
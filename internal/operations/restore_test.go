@@ -0,0 +1,189 @@
+package operations
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/kebairia/backup/internal/config"
+	"github.com/kebairia/backup/internal/database"
+)
+
+// guardedDB is a database whose restore target holds objects.
+type guardedDB struct {
+	database.Database
+	objects []string
+	allow   bool
+}
+
+func (g guardedDB) TargetObjects() ([]string, error) { return g.objects, nil }
+func (g guardedDB) OverwriteAllowed() bool           { return g.allow }
+
+func TestCheckOverwrite(t *testing.T) {
+	busy := guardedDB{objects: []string{"public.users", "public.orders"}}
+	if err := checkOverwrite(busy, false); !errors.Is(err, ErrTargetNotEmpty) {
+		t.Errorf("non-empty target: err = %v, want ErrTargetNotEmpty", err)
+	}
+	if err := checkOverwrite(busy, true); err != nil {
+		t.Errorf("--force: %v", err)
+	}
+	busy.allow = true
+	if err := checkOverwrite(busy, false); err != nil {
+		t.Errorf("allow_overwrite: %v", err)
+	}
+	if err := checkOverwrite(guardedDB{}, false); err != nil {
+		t.Errorf("empty target: %v", err)
+	}
+}
+
+func TestRestoreDependencies(t *testing.T) {
+	var cfg config.Config
+	cfg.Postgres.Instances = []config.DBInstance{
+		{Name: "auth"},
+		{Name: "app", DependsOn: []string{"auth", "mongodb:events"}},
+	}
+	cfg.MongoDB.Instances = []config.DBInstance{{Name: "events"}}
+	databases := []database.Database{
+		namedDB{engine: "postgres", instance: "app", name: "app"},
+		namedDB{engine: "postgres", instance: "auth", name: "users"},
+		namedDB{engine: "postgres", instance: "auth", name: "sessions"},
+	}
+	got, err := restoreDependencies(cfg, databases)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// mongodb:events is not selected, so app does not wait for it
+	if !slices.Equal(got[0], []int{1, 2}) || len(got[1]) != 0 || len(got[2]) != 0 {
+		t.Errorf("dependencies = %v, want app waiting for users and sessions", got)
+	}
+}
+
+// globalsDB is a namedDB holding the globals of its instance.
+type globalsDB struct{ namedDB }
+
+func (globalsDB) ClusterScoped() bool { return true }
+
+func TestRestoreDependenciesGlobals(t *testing.T) {
+	var cfg config.Config
+	cfg.Postgres.Instances = []config.DBInstance{{Name: "main"}, {Name: "other"}}
+	databases := []database.Database{
+		namedDB{engine: "postgres", instance: "main", name: "app"},
+		globalsDB{namedDB{engine: "postgres", instance: "main", name: database.PostgresGlobalsName}},
+		namedDB{engine: "postgres", instance: "other", name: "app"},
+	}
+	got, err := restoreDependencies(cfg, databases)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(got[0], []int{1}) || len(got[1]) != 0 || len(got[2]) != 0 {
+		t.Errorf("dependencies = %v, want only main's app waiting for main's globals", got)
+	}
+}
+
+// streamOnlyDB is a pipeDB whose restores from disk fail.
+type streamOnlyDB struct{ pipeDB }
+
+func (s *streamOnlyDB) Restore(context.Context, string) error {
+	return errors.New("restored from disk")
+}
+
+// xorKeys wraps data keys without a KMS.
+type xorKeys struct{}
+
+func (xorKeys) WrapKey(_ context.Context, key []byte) (string, error) {
+	out := make([]byte, len(key))
+	for i, b := range key {
+		out[i] = b ^ 0x5a
+	}
+	return string(out), nil
+}
+
+func (x xorKeys) UnwrapKey(ctx context.Context, wrapped string) ([]byte, error) {
+	key, err := x.WrapKey(ctx, []byte(wrapped))
+	return []byte(key), err
+}
+
+func TestStreamRestore(t *testing.T) {
+	operator, _ := flowOperator(t, nil)
+	operator.encryption = xorKeys{}
+	operator.config.Restore.Stream = true
+	db := &streamOnlyDB{pipeDB{fileDB: fileDB{
+		dir:     filepath.Join(operator.config.Backup.Directory, "postgres", "billing"),
+		content: strings.Repeat("row\n", 100000),
+	}}}
+
+	record, err := operator.backupDatabase(context.Background(), db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(record.FilePath, ".zst.enc") {
+		t.Fatalf("artifact %s is not compressed and encrypted", record.FilePath)
+	}
+	if err := operator.RestoreDatabase(db, *record); err != nil {
+		t.Fatal(err)
+	}
+	if db.restored != db.content {
+		t.Errorf("restored %d bytes, want %d", len(db.restored), len(db.content))
+	}
+	entries, err := os.ReadDir(db.dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if entry.Name() == "billing.dump" || entry.Name() == "billing.dump.zst" {
+			t.Errorf("plaintext %s written to disk", entry.Name())
+		}
+	}
+}
+
+// plainSQLDB is a streamOnlyDB whose dumps are plain SQL scripts.
+type plainSQLDB struct{ streamOnlyDB }
+
+func (*plainSQLDB) PlainSQL() bool { return true }
+
+func TestStreamRestorePlainSQL(t *testing.T) {
+	operator, _ := flowOperator(t, nil)
+	db := &plainSQLDB{streamOnlyDB{pipeDB{fileDB: fileDB{
+		dir:     filepath.Join(operator.config.Backup.Directory, "postgres", "billing"),
+		content: strings.Repeat("INSERT INTO t VALUES (1);\n", 10000),
+	}}}}
+
+	// zstd, without restore.stream
+	record, err := operator.backupDatabase(context.Background(), db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := operator.RestoreDatabase(db, *record); err != nil {
+		t.Fatal(err)
+	}
+	if db.restored != db.content {
+		t.Errorf("restored %d bytes, want %d", len(db.restored), len(db.content))
+	}
+
+	// gzip
+	db.restored = ""
+	gzipped := filepath.Join(db.dir, "billing.sql.gz")
+	file, err := os.Create(gzipped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := gzip.NewWriter(file)
+	if _, err := w.Write([]byte(db.content)); err != nil {
+		t.Fatal(err)
+	}
+	if err := errors.Join(w.Close(), file.Close()); err != nil {
+		t.Fatal(err)
+	}
+	record.FilePath = gzipped
+	if err := operator.RestoreDatabase(db, *record); err != nil {
+		t.Fatal(err)
+	}
+	if db.restored != db.content {
+		t.Errorf("restored %d bytes of the gzip dump, want %d", len(db.restored), len(db.content))
+	}
+}
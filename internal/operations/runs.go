@@ -0,0 +1,191 @@
+package operations
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kebairia/backup/internal/config"
+)
+
+// RunsDirname is the directory under the backup directory holding one
+// manifest per backup run, named <run id>.json.
+const RunsDirname = "runs"
+
+// ErrUnknownRun indicates that no manifest exists for a run ID.
+var ErrUnknownRun = errors.New("unknown run")
+
+// RunManifest summarizes a whole backup run: when it ran, with which
+// configuration, and the outcome of every database it covered. It is the
+// audit trail of the run and selects the artifacts of whole-run restores.
+type RunManifest struct {
+	ID          string    `json:"id"`
+	StartedAt   time.Time `json:"started_at"`
+	CompletedAt time.Time `json:"completed_at"`
+	Host        string    `json:"host,omitempty"`
+	// ConfigHash is the SHA-256 of the effective configuration (includes
+	// and profile applied), to tell which settings produced the run.
+	ConfigHash string            `json:"config_hash"`
+	Profile    string            `json:"profile,omitempty"`
+	Tenant     string            `json:"tenant,omitempty"`
+	Labels     map[string]string `json:"labels,omitempty"`
+	Cancelled  bool              `json:"cancelled,omitempty"`
+	Artifacts  []RunArtifact     `json:"artifacts"`
+}
+
+// RunArtifact is the outcome of one database in a run.
+type RunArtifact struct {
+	Engine     string    `json:"engine"`
+	Database   string    `json:"database"`
+	Status     string    `json:"status"`
+	Error      string    `json:"error,omitempty"`
+	StartedAt  time.Time `json:"started_at"`
+	FilePath   string    `json:"file_path"`
+	RemotePath string    `json:"remote_path,omitempty"`
+	Snapshot   string    `json:"snapshot,omitempty"` // chunk repository snapshot
+	SizeBytes  int64     `json:"size_bytes"`
+}
+
+// Failed returns the number of failed artifacts in the run.
+func (m RunManifest) Failed() int {
+	failed := 0
+	for _, artifact := range m.Artifacts {
+		if artifact.Status != StatusSuccess {
+			failed++
+		}
+	}
+	return failed
+}
+
+// newRunID returns a unique ID for a run: a version 7 UUID, which starts
+// with the time it was created.
+func newRunID() string {
+	id, err := uuid.NewV7()
+	if err != nil {
+		return uuid.NewString()
+	}
+	return id.String()
+}
+
+// configHash returns the SHA-256 of cfg in its JSON form.
+func configHash(cfg config.Config) string {
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// newRunManifest builds the manifest of a run from its records, sorted by
+// engine and database.
+func newRunManifest(id string, cfg config.Config, labels map[string]string, start time.Time, records []*Metadata) RunManifest {
+	host, _ := os.Hostname()
+	manifest := RunManifest{
+		ID:          id,
+		StartedAt:   start,
+		CompletedAt: time.Now(),
+		Host:        host,
+		ConfigHash:  configHash(cfg),
+		Profile:     cfg.Profile,
+		Tenant:      cfg.Tenant,
+		Labels:      labels,
+		Artifacts:   make([]RunArtifact, 0, len(records)),
+	}
+	for _, record := range records {
+		manifest.Artifacts = append(manifest.Artifacts, RunArtifact{
+			Engine:     record.Engine,
+			Database:   record.Database,
+			Status:     record.Status,
+			Error:      record.Error,
+			StartedAt:  record.StartedAt,
+			FilePath:   record.FilePath,
+			RemotePath: record.RemotePath,
+			Snapshot:   record.Snapshot,
+			SizeBytes:  record.SizeBytes,
+		})
+	}
+	sort.Slice(manifest.Artifacts, func(i, j int) bool {
+		a, b := manifest.Artifacts[i], manifest.Artifacts[j]
+		if a.Engine != b.Engine {
+			return a.Engine < b.Engine
+		}
+		return a.Database < b.Database
+	})
+	return manifest
+}
+
+// Write stores the manifest as <dir>/runs/<id>.json and returns its path.
+func (m RunManifest) Write(dir string) (string, error) {
+	runsDir := filepath.Join(dir, RunsDirname)
+	if err := EnsureDirectoryExist(runsDir); err != nil {
+		return "", err
+	}
+	path := filepath.Join(runsDir, m.ID+".json")
+	if err := WriteJSONAtomic(path, m); err != nil {
+		return "", fmt.Errorf("write run manifest: %w", err)
+	}
+	return path, nil
+}
+
+// LoadRunManifest reads the manifest of run id from the backup directory.
+func LoadRunManifest(dir, id string) (RunManifest, error) {
+	var manifest RunManifest
+	if id == "" || strings.ContainsAny(id, `/\`) {
+		return manifest, fmt.Errorf("%w: %q", ErrUnknownRun, id)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, RunsDirname, id+".json"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return manifest, fmt.Errorf("%w: %q", ErrUnknownRun, id)
+	}
+	if err != nil {
+		return manifest, fmt.Errorf("read run manifest: %w", err)
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return manifest, fmt.Errorf("decode run manifest %q: %w", id, err)
+	}
+	return manifest, nil
+}
+
+// LoadRuns returns the run manifests in the backup directory, newest first.
+// At most limit runs are returned when limit is positive.
+func LoadRuns(dir string, limit int) ([]RunManifest, error) {
+	files, err := filepath.Glob(filepath.Join(dir, RunsDirname, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	runs := make([]RunManifest, 0, len(files))
+	for _, file := range files {
+		manifest, err := LoadRunManifest(dir, strings.TrimSuffix(filepath.Base(file), ".json"))
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, manifest)
+	}
+	// by start time: IDs of older releases do not sort like UUIDs
+	sort.SliceStable(runs, func(i, j int) bool {
+		return runs[i].StartedAt.After(runs[j].StartedAt)
+	})
+	if limit > 0 && len(runs) > limit {
+		runs = runs[:limit]
+	}
+	return runs, nil
+}
+
+// Runs returns the run manifests of the given configuration, newest first.
+func Runs(configPath string, limit int) ([]RunManifest, error) {
+	var cfg config.Config
+	if err := cfg.Load(configPath); err != nil {
+		return nil, err
+	}
+	return LoadRuns(cfg.Backup.Directory, limit)
+}
@@ -0,0 +1,34 @@
+package operations
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRunManifests(t *testing.T) {
+	dir := t.TempDir()
+	base := time.Date(2025, 4, 24, 21, 0, 0, 0, time.UTC)
+	// the first ID has the format of older releases
+	for i, id := range []string{"20250424T210000Z-3fa2c1", newRunID(), newRunID()} {
+		run := RunManifest{
+			ID:        id,
+			StartedAt: base.Add(time.Duration(i) * time.Hour),
+			Artifacts: []RunArtifact{{Engine: "postgres", Database: "app", Status: StatusSuccess}},
+		}
+		if _, err := run.Write(dir); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	runs, err := LoadRuns(dir, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 2 || !runs[0].StartedAt.Equal(base.Add(2*time.Hour)) || !runs[1].StartedAt.Equal(base.Add(time.Hour)) {
+		t.Fatalf("LoadRuns returned %+v, want the two newest runs, newest first", runs)
+	}
+	if _, err := LoadRunManifest(dir, "../metadata"); !errors.Is(err, ErrUnknownRun) {
+		t.Fatalf("LoadRunManifest accepted a path, err = %v", err)
+	}
+}
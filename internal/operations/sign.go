@@ -0,0 +1,77 @@
+package operations
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/kebairia/backup/internal/signing"
+	"github.com/kebairia/backup/internal/telemetry"
+)
+
+// signArtifact signs the checksum of the artifact of record, when signing
+// is configured, and records the signature written next to it.
+func (operator *Operator) signArtifact(ctx context.Context, record *Metadata) (err error) {
+	if operator.signer == nil {
+		return nil
+	}
+	ctx, span := telemetry.Start(ctx, "sign")
+	defer func() { telemetry.End(span, err) }()
+	path := record.FilePath + operator.signer.Ext()
+	message := signing.Message(record.Checksum, filepath.Base(record.FilePath))
+	if err := operator.signer.Sign(ctx, message, path); err != nil {
+		return err
+	}
+	record.Signature = path
+	return nil
+}
+
+// deleteSignature removes the remote copy of the signature of record.
+func (operator *Operator) deleteSignature(ctx context.Context, record *Metadata) error {
+	key, err := operator.storageKey(record.Signature)
+	if err != nil {
+		return err
+	}
+	return operator.deleteRemote(ctx, key)
+}
+
+// verifySignature checks that the artifact at path, a copy of the artifact
+// of record as stored, matches the recorded checksum and that the checksum
+// carries a valid signature. A signature whose local copy was pruned is
+// downloaded from storage.
+func (operator *Operator) verifySignature(record Metadata, path string) error {
+	if operator.signer == nil {
+		return fmt.Errorf("%w: backup.signing is not configured", signing.ErrSignature)
+	}
+	if record.Signature == "" || record.Checksum == "" {
+		return fmt.Errorf("%w: %s is not signed", signing.ErrSignature, record.FilePath)
+	}
+	checksum, err := fileChecksum(path)
+	if err != nil {
+		return err
+	}
+	if checksum != record.Checksum {
+		return fmt.Errorf("%w: %s has checksum %s, signed %s",
+			signing.ErrSignature, record.FilePath, checksum, record.Checksum)
+	}
+
+	sigPath := record.Signature
+	if _, err := os.Stat(sigPath); err != nil && operator.storage != nil {
+		key, err := operator.storageKey(record.Signature)
+		if err != nil {
+			return err
+		}
+		scratch, err := os.MkdirTemp(filepath.Dir(record.FilePath), ".fetch-*")
+		if err != nil {
+			return fmt.Errorf("create download directory: %w", err)
+		}
+		defer os.RemoveAll(scratch)
+		sigPath = filepath.Join(scratch, filepath.Base(record.Signature))
+		if err := operator.storage.Download(operator.ctx, key, sigPath); err != nil {
+			return fmt.Errorf("%w: download %s: %w", signing.ErrSignature, key, err)
+		}
+	}
+	message := signing.Message(record.Checksum, filepath.Base(record.FilePath))
+	return operator.signer.Verify(operator.ctx, message, sigPath)
+}
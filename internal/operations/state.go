@@ -0,0 +1,111 @@
+package operations
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/kebairia/backup/internal/config"
+	"github.com/kebairia/backup/internal/database"
+	"github.com/kebairia/backup/internal/state"
+)
+
+// defaultLockTTL bounds how long a crashed run can block other hosts.
+const defaultLockTTL = 6 * time.Hour
+
+// buildState creates the run state store selected in the configuration.
+func buildState(
+	cfg config.Config,
+	vaultClient Vault,
+) (state.Store, error) {
+	switch cfg.State.Backend {
+	case "", state.BackendLocal:
+		dir := cfg.State.Path
+		if dir == "" {
+			dir = filepath.Join(cfg.Backup.Directory, ".state")
+		}
+		return state.NewLocal(dir), nil
+	case state.BackendVault:
+		return state.NewVault(vaultClient, cfg.State.Mount, cfg.State.Prefix)
+	default:
+		return nil, fmt.Errorf("%w: %q", state.ErrUnsupportedBackend, cfg.State.Backend)
+	}
+}
+
+// lockDatabase takes the fleet-wide lock for db. The returned func releases it.
+func (operator *Operator) lockDatabase(db database.Database) (func(), error) {
+	ttl := operator.config.State.LockTTL
+	if ttl <= 0 {
+		ttl = defaultLockTTL
+	}
+	key := state.Key(db.GetEngine(), catalogName(db))
+	owner := state.Owner()
+	if err := operator.state.Lock(operator.ctx, key, owner, ttl); err != nil {
+		return nil, err
+	}
+	return func() {
+		if err := operator.state.Unlock(context.WithoutCancel(operator.ctx), key, owner); err != nil {
+			operator.log.Warn("failed to release lock",
+				"database", db.GetName(),
+				"error", err.Error(),
+			)
+		}
+	}, nil
+}
+
+// catalogName returns the name of db's artifact directory, which identifies
+// it in locks and run markers: the database name, unless backup.dir_template
+// adds the instance or host.
+func catalogName(db database.Database) string {
+	return filepath.Base(db.GetPath())
+}
+
+// recordRun stores the last-run marker for a backup of db.
+// Failures are logged and never fail the backup itself.
+func (operator *Operator) recordRun(db database.Database, record *Metadata) {
+	host, _ := os.Hostname()
+	run := state.LastRun{
+		Engine:      record.Engine,
+		Database:    catalogName(db),
+		Status:      record.Status,
+		Error:       record.Error,
+		Host:        host,
+		FilePath:    record.FilePath,
+		StartedAt:   record.StartedAt,
+		CompletedAt: record.CompletedAt,
+	}
+	if record.Status == StatusSuccess {
+		run.LastSuccessAt, run.SizeBytes = record.CompletedAt, record.SizeBytes
+	} else if latest, err := LoadLatestRestorable(db.GetPath()); err == nil {
+		run.LastSuccessAt, run.SizeBytes = latest.CompletedAt, latest.SizeBytes
+	}
+	if err := operator.state.SetLastRun(context.WithoutCancel(operator.ctx), run); err != nil {
+		operator.log.Warn("failed to record run state",
+			"database", record.Database,
+			"error", err.Error(),
+		)
+	}
+}
+
+// Status returns the last run and lock of every database known to the
+// configured state store, sorted by engine and database.
+func Status(ctx context.Context, configPath string) ([]state.Status, error) {
+	operator, err := NewOperator(ctx, configPath)
+	if err != nil {
+		return nil, err
+	}
+	statuses, err := operator.state.Statuses(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		if statuses[i].Engine != statuses[j].Engine {
+			return statuses[i].Engine < statuses[j].Engine
+		}
+		return statuses[i].Database < statuses[j].Database
+	})
+	return statuses, nil
+}
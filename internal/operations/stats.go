@@ -0,0 +1,187 @@
+package operations
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/kebairia/backup/internal/config"
+)
+
+// defaultStatsDays is how many days ahead Stats projects storage needs.
+const defaultStatsDays = 90
+
+// SizeSample is the size of one backup artifact at the time it was written.
+type SizeSample struct {
+	Time      time.Time `json:"time"`
+	SizeBytes int64     `json:"size_bytes"`
+}
+
+// DatabaseStats summarizes the backup history of one database.
+type DatabaseStats struct {
+	Engine      string    `json:"engine"`
+	Database    string    `json:"database"`
+	Backups     int       `json:"backups"`
+	FirstBackup time.Time `json:"first_backup"`
+	LastBackup  time.Time `json:"last_backup"`
+	LatestBytes int64     `json:"latest_bytes"`
+	// TotalBytes is the space used by all artifacts currently on disk.
+	TotalBytes int64 `json:"total_bytes"`
+	// GrowthPerDay is the least-squares trend of artifact size, in bytes/day.
+	GrowthPerDay float64 `json:"growth_bytes_per_day"`
+	// ProjectedBytes is the space needed at the end of the horizon to keep
+	// retention.local.keep artifacts of the projected size.
+	ProjectedBytes int64        `json:"projected_bytes"`
+	Samples        []SizeSample `json:"samples"`
+}
+
+// StatsReport is the result of Stats.
+type StatsReport struct {
+	GeneratedAt    time.Time       `json:"generated_at"`
+	HorizonDays    int             `json:"horizon_days"`
+	Keep           int             `json:"retention_keep"`
+	Databases      []DatabaseStats `json:"databases"`
+	TotalBytes     int64           `json:"total_bytes"`
+	ProjectedBytes int64           `json:"projected_bytes"`
+}
+
+// Stats computes per-database growth trends from the artifacts stored next
+// to each catalog entry and projects storage needs days ahead at the
+// configured retention. It reads the local backup directory only and does
+// not contact Vault.
+func Stats(configPath string, days int) (StatsReport, error) {
+	var cfg config.Config
+	if err := cfg.Load(configPath); err != nil {
+		return StatsReport{}, err
+	}
+	if days <= 0 {
+		days = defaultStatsDays
+	}
+	entries, err := LoadCatalog(cfg.Backup.Directory)
+	if err != nil {
+		return StatsReport{}, err
+	}
+
+	keep := cfg.Retention.LocalKeep()
+	report := StatsReport{GeneratedAt: time.Now(), HorizonDays: days, Keep: keep}
+	for _, entry := range entries {
+		samples, err := artifactSamples(filepath.Dir(entry.Path))
+		if err != nil {
+			return StatsReport{}, err
+		}
+		stats := DatabaseStats{
+			Engine:   entry.Record.Engine,
+			Database: entry.Record.Database,
+			Backups:  len(samples),
+			Samples:  samples,
+		}
+		if len(samples) > 0 {
+			first, last := samples[0], samples[len(samples)-1]
+			stats.FirstBackup = first.Time
+			stats.LastBackup = last.Time
+			stats.LatestBytes = last.SizeBytes
+			for _, s := range samples {
+				stats.TotalBytes += s.SizeBytes
+			}
+			stats.GrowthPerDay = growthPerDay(samples)
+			projected := float64(last.SizeBytes) + stats.GrowthPerDay*float64(days)
+			if projected < 0 {
+				projected = 0
+			}
+			copies := keep
+			if copies <= 0 {
+				copies = len(samples)
+			}
+			stats.ProjectedBytes = int64(projected) * int64(copies)
+		}
+		report.TotalBytes += stats.TotalBytes
+		report.ProjectedBytes += stats.ProjectedBytes
+		report.Databases = append(report.Databases, stats)
+	}
+	sort.Slice(report.Databases, func(i, j int) bool {
+		a, b := report.Databases[i], report.Databases[j]
+		if a.Engine != b.Engine {
+			return a.Engine < b.Engine
+		}
+		return a.Database < b.Database
+	})
+	return report, nil
+}
+
+// artifactSamples lists every artifact in dir (files and directory dumps,
+// excluding the metadata record and hidden bookkeeping files) ordered by
+// modification time.
+func artifactSamples(dir string) ([]SizeSample, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("read %q: %w", dir, err)
+	}
+	var samples []SizeSample
+	for _, entry := range entries {
+		if isCatalogFile(entry.Name()) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, fmt.Errorf("stat %q: %w", entry.Name(), err)
+		}
+		size := info.Size()
+		if entry.IsDir() {
+			if size, err = dirSize(filepath.Join(dir, entry.Name())); err != nil {
+				return nil, err
+			}
+		}
+		samples = append(samples, SizeSample{Time: info.ModTime(), SizeBytes: size})
+	}
+	sort.Slice(samples, func(i, j int) bool {
+		return samples[i].Time.Before(samples[j].Time)
+	})
+	return samples, nil
+}
+
+// dirSize returns the total size of the regular files under dir.
+func dirSize(dir string) (int64, error) {
+	var total int64
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		total += info.Size()
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("size of %q: %w", dir, err)
+	}
+	return total, nil
+}
+
+// growthPerDay fits a least-squares line through the samples and returns
+// its slope in bytes per day. Fewer than two samples give no trend.
+func growthPerDay(samples []SizeSample) float64 {
+	n := float64(len(samples))
+	if n < 2 {
+		return 0
+	}
+	origin := samples[0].Time
+	var sumX, sumY, sumXY, sumXX float64
+	for _, s := range samples {
+		x := s.Time.Sub(origin).Hours() / 24
+		y := float64(s.SizeBytes)
+		sumX += x
+		sumY += y
+		sumXY += x * y
+		sumXX += x * x
+	}
+	denom := n*sumXX - sumX*sumX
+	if denom == 0 {
+		return 0
+	}
+	return (n*sumXY - sumX*sumY) / denom
+}
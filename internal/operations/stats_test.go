@@ -0,0 +1,25 @@
+package operations
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestGrowthPerDay(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+
+	samples := []SizeSample{
+		{Time: start, SizeBytes: 1000},
+		{Time: start.Add(day), SizeBytes: 1100},
+		{Time: start.Add(2 * day), SizeBytes: 1200},
+		{Time: start.Add(4 * day), SizeBytes: 1400},
+	}
+	if got := growthPerDay(samples); math.Abs(got-100) > 1e-9 {
+		t.Errorf("growthPerDay = %v, want 100", got)
+	}
+	if got := growthPerDay(samples[:1]); got != 0 {
+		t.Errorf("growthPerDay of one sample = %v, want 0", got)
+	}
+}
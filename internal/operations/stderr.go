@@ -0,0 +1,152 @@
+package operations
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kebairia/backup/internal/database"
+	"github.com/kebairia/backup/internal/logger"
+	"github.com/kebairia/backup/internal/progress"
+	"github.com/kebairia/backup/internal/redact"
+)
+
+// defaultStderrTailKB is the stderr kept per command when
+// backup.stderr.tail_kb is unset.
+const defaultStderrTailKB = 8
+
+// stderrLogSuffix names the full stderr logs kept with backup.stderr.log.
+const stderrLogSuffix = ".stderr.log"
+
+// tailBuffer keeps the last max bytes written to it.
+type tailBuffer struct {
+	mu  sync.Mutex
+	max int
+	buf []byte
+}
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.buf = append(t.buf, p...)
+	if over := len(t.buf) - t.max; over > 0 {
+		t.buf = append(t.buf[:0], t.buf[over:]...)
+	}
+	return len(p), nil
+}
+
+// String returns the kept bytes, starting at a line boundary when the
+// beginning was dropped.
+func (t *tailBuffer) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := string(t.buf)
+	if len(t.buf) == t.max {
+		if i := strings.IndexByte(out, '\n'); i >= 0 {
+			out = out[i+1:]
+		}
+	}
+	return strings.TrimSpace(out)
+}
+
+// StderrError is the error of a failed dump or restore, carrying the tail of
+// the stderr its client tools wrote.
+type StderrError struct {
+	Err    error
+	Stderr string
+}
+
+// Error returns the wrapped error followed by the last line of stderr, which
+// usually names the cause; the whole tail is in Stderr.
+func (e *StderrError) Error() string {
+	lines := strings.Split(e.Stderr, "\n")
+	return fmt.Sprintf("%v: %s", e.Err, strings.TrimSpace(lines[len(lines)-1]))
+}
+
+func (e *StderrError) Unwrap() error { return e.Err }
+
+// stderrCapture collects the stderr of an engine's client tools while still
+// echoing it to the terminal, unless --quiet is set.
+type stderrCapture struct {
+	db   database.StderrSetter // nil when the engine runs no client tools
+	tail *tailBuffer
+	log  *os.File
+	path string // full log, see backup.stderr.log
+}
+
+// captureStderr starts capturing the stderr of db. With logPath set, the
+// full stderr is also written there; the log is removed again if it stays
+// empty. Call finish when the commands are done.
+func (operator *Operator) captureStderr(db database.Database, logPath string) *stderrCapture {
+	setter, ok := db.(database.StderrSetter)
+	if !ok {
+		return &stderrCapture{}
+	}
+	kb := operator.config.Backup.Stderr.TailKB
+	if kb <= 0 {
+		kb = defaultStderrTailKB
+	}
+	c := &stderrCapture{db: setter, tail: &tailBuffer{max: kb << 10}}
+	writers := []io.Writer{c.tail}
+	if !logger.Quiet() {
+		writers = append(writers, progress.Writer(os.Stderr))
+	}
+	if logPath != "" {
+		file, err := os.Create(logPath)
+		if err != nil {
+			operator.log.Warn("failed to create stderr log", "path", logPath, "error", err.Error())
+		} else {
+			c.log, c.path = file, logPath
+			writers = append(writers, file)
+		}
+	}
+	setter.SetStderr(redact.Writer(io.MultiWriter(writers...)))
+	return c
+}
+
+// finish stops capturing and returns err, wrapped in a StderrError with the
+// captured tail when err is set and the tools wrote to stderr.
+func (c *stderrCapture) finish(err error) error {
+	if c.db == nil {
+		return err
+	}
+	c.db.SetStderr(nil)
+	if c.log != nil {
+		info, statErr := c.log.Stat()
+		c.log.Close()
+		if statErr == nil && info.Size() == 0 {
+			os.Remove(c.path)
+			c.path = ""
+		}
+	}
+	if err == nil {
+		return nil
+	}
+	if tail := c.tail.String(); tail != "" {
+		return &StderrError{Err: err, Stderr: tail}
+	}
+	return err
+}
+
+// stderrOf returns the stderr tail carried by err, if any.
+func stderrOf(err error) string {
+	var stderrErr *StderrError
+	if errors.As(err, &stderrErr) {
+		return stderrErr.Stderr
+	}
+	return ""
+}
+
+// stderrLogPath returns where the full stderr of a backup started at start
+// is kept, or "" when backup.stderr.log is off.
+func (operator *Operator) stderrLogPath(metadataDir string, start time.Time) string {
+	if !operator.config.Backup.Stderr.Log {
+		return ""
+	}
+	return filepath.Join(metadataDir, start.UTC().Format("20060102T150405Z")+stderrLogSuffix)
+}
@@ -0,0 +1,34 @@
+package operations
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestTailBuffer(t *testing.T) {
+	tail := &tailBuffer{max: 64}
+	for i := range 20 {
+		fmt.Fprintf(tail, "line %02d\n", i)
+	}
+	got := tail.String()
+	if !strings.HasPrefix(got, "line ") || !strings.HasSuffix(got, "line 19") {
+		t.Errorf("tail = %q, want whole lines ending with line 19", got)
+	}
+	if len(got) > 64 {
+		t.Errorf("tail holds %d bytes, want at most 64", len(got))
+	}
+
+	cause := errors.New("exit status 1")
+	err := error(&StderrError{Err: cause, Stderr: got})
+	if !errors.Is(err, cause) {
+		t.Error("StderrError does not unwrap to its cause")
+	}
+	if want := "exit status 1: line 19"; err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err.Error(), want)
+	}
+	if stderrOf(fmt.Errorf("backup failed: %w", err)) != got {
+		t.Error("stderrOf does not find the wrapped tail")
+	}
+}
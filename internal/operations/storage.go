@@ -0,0 +1,283 @@
+package operations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/kebairia/backup/internal/config"
+	"github.com/kebairia/backup/internal/events"
+	"github.com/kebairia/backup/internal/storage"
+	"github.com/kebairia/backup/internal/telemetry"
+	"github.com/kebairia/backup/internal/vault"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// buildStorage creates the remote backend selected in the configuration.
+// It returns nil when artifacts should stay on local disk only.
+func buildStorage(
+	ctx context.Context,
+	cfg config.StorageConfig,
+	vaultClient Vault,
+) (storage.Backend, error) {
+	switch cfg.Type {
+	case "":
+		return nil, nil
+	case storage.TypeLocal:
+		return storage.NewLocal(cfg.Local.Path)
+	case storage.TypeS3:
+		accessKey, secretKey := cfg.S3.AccessKey, cfg.S3.SecretKey
+		if cfg.S3.VaultPath != "" {
+			if vaultClient == nil {
+				return nil, fmt.Errorf("%w: storage.s3.vault_path needs it", vault.ErrNotConfigured)
+			}
+			secret, err := vaultClient.GetSecret(ctx, cfg.S3.VaultPath)
+			if err != nil {
+				return nil, fmt.Errorf("vault read s3 credentials: %w", err)
+			}
+			accessKey, _ = secret["access_key"].(string)
+			secretKey, _ = secret["secret_key"].(string)
+		}
+		return storage.NewS3(
+			storage.WithS3Bucket(cfg.S3.Bucket, cfg.S3.Prefix),
+			storage.WithS3Region(cfg.S3.Region),
+			storage.WithS3Endpoint(cfg.S3.Endpoint, cfg.S3.ForcePathStyle),
+			storage.WithS3Credentials(accessKey, secretKey),
+			storage.WithS3TLS(cfg.S3.CABundle, cfg.S3.SkipVerify),
+			storage.WithS3Multipart(int64(cfg.S3.MultipartThresholdMB)<<20,
+				int64(cfg.S3.PartSizeMB)<<20, cfg.S3.UploadConcurrency),
+		)
+	case storage.TypeRclone:
+		return storage.NewRclone(
+			storage.WithRcloneRemote(cfg.Rclone.Remote),
+			storage.WithRcloneConfig(cfg.Rclone.Config),
+			storage.WithRcloneBandwidthLimit(cfg.Rclone.BWLimit),
+			storage.WithRcloneFlags(cfg.Rclone.Flags...),
+			storage.WithRcloneBinary(cfg.Rclone.Binary),
+		)
+	default:
+		return nil, fmt.Errorf("%w: %q", storage.ErrUnsupportedBackend, cfg.Type)
+	}
+}
+
+// storageKey returns the backend key for a local path under the backup directory.
+func (operator *Operator) storageKey(localPath string) (string, error) {
+	rel, err := filepath.Rel(operator.config.Backup.Directory, localPath)
+	if err != nil {
+		return "", fmt.Errorf("storage key for %q: %w", localPath, err)
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// artifactURI returns where the remote copy of record is stored, in the
+// form migrations take (see backendFromURI): "s3://bucket/prefix/key",
+// "rclone:remote/key", or a path for local storage.
+func (operator *Operator) artifactURI(record Metadata) string {
+	key := record.RemotePath
+	if key == "" {
+		return ""
+	}
+	switch backend := strings.TrimSuffix(record.Backend, "/"); {
+	case backend == "local":
+		return filepath.Join(operator.config.Backup.Directory, filepath.FromSlash(key))
+	case strings.HasPrefix(backend, "local:"):
+		return filepath.Join(strings.TrimPrefix(backend, "local:"), filepath.FromSlash(key))
+	case backend != "" && backend != "remote":
+		return backend + "/" + key
+	}
+	cfg := operator.config.Storage
+	switch cfg.Type {
+	case storage.TypeS3:
+		return "s3://" + path.Join(cfg.S3.Bucket, cfg.S3.Prefix, key)
+	case storage.TypeRclone:
+		return "rclone:" + path.Join(cfg.Rclone.Remote, key)
+	case storage.TypeLocal:
+		return filepath.Join(cfg.Local.Path, filepath.FromSlash(key))
+	}
+	return key
+}
+
+// upload copies a file, or every file of a directory artifact, to the backend.
+// Uploaded objects are tagged with labels when the backend supports tags.
+func (operator *Operator) upload(
+	ctx context.Context,
+	localPath string,
+	labels map[string]string,
+) (_ string, err error) {
+	ctx, span := telemetry.Start(ctx, "upload",
+		attribute.String("storage.backend", operator.storage.Name()),
+		attribute.String("storage.path", localPath),
+	)
+	defer func() { telemetry.End(span, err) }()
+
+	key, err := operator.storageKey(localPath)
+	if err != nil {
+		return "", err
+	}
+	if err := uploadTo(withRunID(ctx, operator.runID), operator.storage, localPath, key, labels); err != nil {
+		return "", err
+	}
+	operator.emit(events.UploadCompleted, "", "", map[string]any{
+		"key":        key,
+		"backend":    operator.storage.Name(),
+		"size_bytes": artifactSize(localPath),
+	})
+	return key, nil
+}
+
+// RunIDMetadata is the object metadata naming the run that uploaded an
+// artifact, on backends that store metadata (see storage.WithMetadata).
+const RunIDMetadata = "bacli-run-id"
+
+// withRunID returns a copy of ctx whose uploads record runID as the
+// RunIDMetadata of the objects they create.
+func withRunID(ctx context.Context, runID string) context.Context {
+	if runID == "" {
+		return ctx
+	}
+	return storage.WithMetadata(ctx, map[string]string{RunIDMetadata: runID})
+}
+
+// uploadTo copies a file to backend under key, or every file of a directory
+// artifact under key/, and tags the objects with labels.
+func uploadTo(
+	ctx context.Context,
+	backend storage.Backend,
+	localPath, key string,
+	labels map[string]string,
+) error {
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return fmt.Errorf("stat %q: %w", localPath, err)
+	}
+	if !info.IsDir() {
+		return uploadObject(ctx, backend, localPath, key, labels)
+	}
+	return filepath.WalkDir(localPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(localPath, path)
+		if err != nil {
+			return err
+		}
+		return uploadObject(ctx, backend, path, key+"/"+filepath.ToSlash(rel), labels)
+	})
+}
+
+// uploadObject uploads one file and tags it with labels.
+func uploadObject(
+	ctx context.Context,
+	backend storage.Backend,
+	localPath, key string,
+	labels map[string]string,
+) error {
+	if err := backend.Upload(ctx, localPath, key); err != nil {
+		return err
+	}
+	tagger, ok := backend.(storage.Tagger)
+	if !ok || len(labels) == 0 {
+		return nil
+	}
+	return tagger.Tag(ctx, key, labels)
+}
+
+// artifactObjects returns the objects of the artifact stored under key: the
+// object itself, or every file of a directory artifact.
+func artifactObjects(objects []storage.Object, key string) []storage.Object {
+	var matched []storage.Object
+	for _, object := range objects {
+		if object.Key == key || strings.HasPrefix(object.Key, key+"/") {
+			matched = append(matched, object)
+		}
+	}
+	return matched
+}
+
+// downloadObjects downloads objects, the artifact stored under key (see
+// artifactObjects), from backend to path.
+func (operator *Operator) downloadObjects(backend storage.Backend, objects []storage.Object, key, path string) error {
+	for _, object := range objects {
+		target := filepath.Join(path, filepath.FromSlash(strings.TrimPrefix(object.Key, key)))
+		if err := backend.Download(operator.ctx, object.Key, target); err != nil {
+			return fmt.Errorf("download %s: %w", object.Key, err)
+		}
+	}
+	return nil
+}
+
+// fetchArtifact returns a local path to the artifact of record. An artifact
+// whose local copy was pruned by retention is downloaded from storage, or
+// restored from the chunk repository, into a hidden directory of its
+// database directory; the returned func removes it.
+func (operator *Operator) fetchArtifact(record Metadata) (string, func(), error) {
+	noop := func() {}
+	if _, err := os.Stat(record.FilePath); err == nil {
+		return record.FilePath, noop, nil
+	}
+	if record.Snapshot != "" && operator.repoBackend != nil {
+		return operator.fetchSnapshot(record)
+	}
+	if operator.storage == nil || record.RemotePath == "" {
+		return record.FilePath, noop, nil
+	}
+	key, err := operator.storageKey(record.FilePath)
+	if err != nil {
+		return "", nil, err
+	}
+	listed, err := operator.storage.List(operator.ctx, key)
+	if err != nil {
+		return "", nil, fmt.Errorf("list %s: %w", key, err)
+	}
+	objects := artifactObjects(listed, key)
+	if len(objects) == 0 {
+		return "", nil, fmt.Errorf("%w: %s", storage.ErrNotFound, key)
+	}
+
+	work := operator.workDir(filepath.Dir(record.FilePath))
+	if err := os.MkdirAll(work, 0o755); err != nil {
+		return "", nil, fmt.Errorf("create download directory: %w", err)
+	}
+	scratch, err := os.MkdirTemp(work, ".fetch-*")
+	if err != nil {
+		return "", nil, fmt.Errorf("create download directory: %w", err)
+	}
+	cleanup := func() { os.RemoveAll(scratch) }
+	path := filepath.Join(scratch, filepath.Base(record.FilePath))
+	if err := operator.downloadObjects(operator.storage, objects, key, path); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	operator.log.Info("artifact fetched from storage",
+		"database", record.Database,
+		"engine", record.Engine,
+		"key", key,
+		"backend", operator.storage.Name(),
+	)
+	return path, cleanup, nil
+}
+
+// deleteRemote removes the artifact stored under key from the backend.
+func (operator *Operator) deleteRemote(ctx context.Context, key string) error {
+	return deleteFrom(ctx, operator.storage, key)
+}
+
+// deleteFrom removes the artifact stored under key from backend.
+func deleteFrom(ctx context.Context, backend storage.Backend, key string) error {
+	listed, err := backend.List(ctx, key)
+	if err != nil {
+		return fmt.Errorf("list %s: %w", key, err)
+	}
+	var errs []error
+	for _, object := range artifactObjects(listed, key) {
+		if err := backend.Delete(ctx, object.Key); err != nil {
+			errs = append(errs, fmt.Errorf("delete %s: %w", object.Key, err))
+		}
+	}
+	return errors.Join(errs...)
+}
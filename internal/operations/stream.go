@@ -0,0 +1,200 @@
+package operations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/kebairia/backup/internal/database"
+	"github.com/kebairia/backup/internal/state"
+)
+
+var (
+	// ErrAmbiguousStream indicates that a streamed backup or restore selects
+	// more than one database.
+	ErrAmbiguousStream = errors.New("streaming needs exactly one database")
+	// ErrNotStreamable indicates that the configured method of a database
+	// does not write a single-file artifact that could be piped.
+	ErrNotStreamable = errors.New("artifact cannot be streamed")
+)
+
+// BackupTo dumps the one database selected by opts and writes the artifact
+// to w, e.g. stdout for `bacli backup --stdout`. It returns the bytes
+// written.
+//
+// The dump is staged in the database's artifact directory, under the usual
+// lock, and removed once streamed. It is written as the engine produced it:
+// compression, encryption, upload and the catalog are left to the pipeline
+// reading w, so the database's history and last run are not touched.
+func BackupTo(ctx context.Context, configPath string, opts BackupOptions, w io.Writer) (int64, error) {
+	operator, err := NewOperator(ctx, configPath)
+	if err != nil {
+		return 0, err
+	}
+	operator.keepPartial = opts.KeepPartial
+	operator.ignoreWindow = opts.IgnoreWindow
+	databases, err := database.InitializeDatabases(operator.ctx,
+		targetConfig(operator.config, opts.Engine, opts.Instance), operator.vaultClient)
+	if err != nil {
+		return 0, fmt.Errorf("initialize databases: %w", err)
+	}
+	db, err := selectStream(databases, opts.Engine, opts.Instance, opts.Database)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := artifactExt(db); err != nil {
+		return 0, err
+	}
+	if err := operator.awaitWindow(db); err != nil {
+		return 0, err
+	}
+
+	unlock, err := operator.lockDatabase(db)
+	if err != nil {
+		if errors.Is(err, state.ErrLocked) {
+			return 0, fmt.Errorf("%w: %w", ErrSkipped, err)
+		}
+		return 0, fmt.Errorf("lock %q: %w", db.GetName(), err)
+	}
+	defer unlock()
+
+	stderr := operator.captureStderr(db, "")
+	backupPath, err := db.Backup(operator.ctx)
+	err = stderr.finish(err)
+	if backupPath != "" && (err == nil || !operator.keepPartial) {
+		defer os.RemoveAll(backupPath)
+	}
+	if err != nil {
+		if cancelled := operator.cancelled(); cancelled != nil {
+			err = fmt.Errorf("%w: %w", cancelled, err)
+		}
+		return 0, fmt.Errorf("backup failed for %q: %w", db.GetName(), err)
+	}
+
+	file, err := os.Open(backupPath)
+	if err != nil {
+		return 0, fmt.Errorf("open artifact: %w", err)
+	}
+	defer file.Close()
+	n, err := io.Copy(w, file)
+	if err != nil {
+		return n, fmt.Errorf("stream artifact: %w", err)
+	}
+	operator.log.Info("backup streamed",
+		"database", db.GetName(),
+		"engine", db.GetEngine(),
+		"bytes", n,
+	)
+	return n, nil
+}
+
+// RestoreFrom restores the one database selected by opts from the artifact
+// read from r, e.g. stdin for `bacli restore --stdin`. The artifact must be
+// in the format the instance's configured method produces, as written by
+// BackupTo; it is staged next to the database's artifacts and removed after
+// the restore. Target overrides and the overwrite guard apply as in
+// RestoreAll.
+func RestoreFrom(ctx context.Context, configPath string, opts RestoreOptions, r io.Reader) error {
+	if opts.Run != "" || !opts.At.IsZero() || opts.VerifyOnly {
+		return fmt.Errorf("%w: a streamed restore reads its artifact, --run, --at and --verify-only do not apply",
+			ErrRestoreTarget)
+	}
+	operator, err := NewOperator(ctx, configPath)
+	if err != nil {
+		return err
+	}
+	databases, err := database.InitializeDatabases(operator.ctx,
+		targetConfig(operator.config, opts.Engine, opts.Instance), operator.vaultClient)
+	if err != nil {
+		return fmt.Errorf("initialize databases: %w", err)
+	}
+	db, err := selectStream(databases, opts.Engine, opts.Instance, opts.Database)
+	if err != nil {
+		return err
+	}
+	selected, err := selectRestoreTargets([]database.Database{db}, opts)
+	if err != nil {
+		return err
+	}
+	db = selected[0]
+	ext, err := artifactExt(db)
+	if err != nil {
+		return err
+	}
+	if err := checkOverwrite(db, opts.Force); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(db.GetPath(), 0o755); err != nil {
+		return fmt.Errorf("mkdir %q: %w", db.GetPath(), err)
+	}
+	file, err := os.CreateTemp(db.GetPath(), ".stdin-*"+ext)
+	if err != nil {
+		return fmt.Errorf("stage artifact: %w", err)
+	}
+	defer os.Remove(file.Name())
+	n, err := io.Copy(file, r)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("stage artifact: %w", err)
+	}
+	if n == 0 {
+		return fmt.Errorf("%w: no artifact on stdin", database.ErrInvalidArtifact)
+	}
+
+	path, cleanup, err := operator.transformArtifact(db, file.Name())
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	stderr := operator.captureStderr(db, "")
+	if err := stderr.finish(db.Restore(operator.ctx, path)); err != nil {
+		if cancelled := operator.cancelled(); cancelled != nil {
+			err = fmt.Errorf("%w: %w", cancelled, err)
+		}
+		return fmt.Errorf("restore failed for %q: %w", db.GetName(), err)
+	}
+	operator.log.Info("streamed backup restored",
+		"database", db.GetName(),
+		"engine", db.GetEngine(),
+		"bytes", n,
+	)
+	return nil
+}
+
+// selectStream returns the only database matching the selection.
+func selectStream(databases []database.Database, engine, instance, name string) (database.Database, error) {
+	var selected []database.Database
+	for _, db := range databases {
+		if matchDatabase(db, engine, instance, name) {
+			selected = append(selected, db)
+		}
+	}
+	switch len(selected) {
+	case 0:
+		return nil, fmt.Errorf("%w: engine %q, instance %q and name %q",
+			ErrNoDatabase, engine, instance, name)
+	case 1:
+		return selected[0], nil
+	}
+	return nil, fmt.Errorf("%w: %d selected (use --instance or --database)",
+		ErrAmbiguousStream, len(selected))
+}
+
+// artifactExt returns the extension of db's single-file artifacts.
+func artifactExt(db database.Database) (string, error) {
+	streamer, ok := db.(database.Streamer)
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrNotStreamable, db.GetEngine())
+	}
+	ext, ok := streamer.ArtifactExt()
+	if !ok {
+		return "", fmt.Errorf("%w: the %s method of %q writes a directory or a server-side backup",
+			ErrNotStreamable, db.GetEngine(), db.GetName())
+	}
+	return ext, nil
+}
@@ -0,0 +1,62 @@
+package operations
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/kebairia/backup/internal/config"
+	"github.com/kebairia/backup/internal/database"
+)
+
+// namedDB is a database of a named instance.
+type namedDB struct {
+	database.Database
+	engine, instance, name string
+}
+
+func (n namedDB) GetEngine() string   { return n.engine }
+func (n namedDB) GetInstance() string { return n.instance }
+func (n namedDB) GetName() string     { return n.name }
+
+func TestSelectStream(t *testing.T) {
+	databases := []database.Database{
+		namedDB{engine: "postgres", instance: "main", name: "billing"},
+		namedDB{engine: "postgres", instance: "main", name: "users"},
+		namedDB{engine: "mysql", instance: "main", name: "shop"},
+	}
+	db, err := selectStream(databases, "", "mysql:main", "")
+	if err != nil || db.GetName() != "shop" {
+		t.Errorf("mysql:main = %v, %v, want shop", db, err)
+	}
+	db, err = selectStream(databases, "", "postgres:main", "users")
+	if err != nil || db.GetName() != "users" {
+		t.Errorf("postgres:main users = %v, %v, want users", db, err)
+	}
+	if _, err := selectStream(databases, "", "main", ""); !errors.Is(err, ErrAmbiguousStream) {
+		t.Errorf("main: err = %v, want ErrAmbiguousStream", err)
+	}
+	if _, err := selectStream(databases, "", "postgres:replica", ""); !errors.Is(err, ErrNoDatabase) {
+		t.Errorf("postgres:replica: err = %v, want ErrNoDatabase", err)
+	}
+}
+
+func TestTargetConfig(t *testing.T) {
+	var cfg config.Config
+	cfg.Postgres.Instances = []config.DBInstance{{Name: "main"}, {Name: "billing"}}
+	cfg.MySQL.Instances = []config.DBInstance{{Name: "billing"}, {Name: "all", Database: config.AllDatabases}, {Name: "app"}}
+
+	got := targetConfig(cfg, "", "postgres:billing")
+	if len(got.Postgres.Instances) != 1 || got.Postgres.Instances[0].Name != "billing" || len(got.MySQL.Instances) != 0 {
+		t.Errorf("targetConfig(postgres:billing) = %+v, %+v", got.Postgres.Instances, got.MySQL.Instances)
+	}
+	if len(cfg.Postgres.Instances) != 2 {
+		t.Error("targetConfig modified its argument")
+	}
+	// wildcard instances keep the instances claiming databases out of them
+	if got := targetConfig(cfg, "mysql", "all"); len(got.MySQL.Instances) != 3 || len(got.Postgres.Instances) != 0 {
+		t.Errorf("targetConfig(mysql, all) = %+v, %+v", got.MySQL.Instances, got.Postgres.Instances)
+	}
+	if got := targetConfig(cfg, "", "billing"); len(got.Postgres.Instances) != 1 || len(got.MySQL.Instances) != 1 {
+		t.Errorf("targetConfig(billing) = %+v, %+v", got.Postgres.Instances, got.MySQL.Instances)
+	}
+}
@@ -0,0 +1,36 @@
+package operations
+
+import (
+	"os"
+	"os/user"
+	"runtime"
+)
+
+// SystemInfo captures the host context a backup ran in, for forensics.
+type SystemInfo struct {
+	Hostname           string `json:"hostname"`
+	OS                 string `json:"os"`
+	Arch               string `json:"arch"`
+	User               string `json:"user"`
+	DiskFreeStartBytes int64  `json:"disk_free_start_bytes"`
+	DiskFreeEndBytes   int64  `json:"disk_free_end_bytes"`
+}
+
+// NewSystemInfo collects the host context and free space in dir.
+func NewSystemInfo(dir string) *SystemInfo {
+	info := &SystemInfo{
+		OS:   runtime.GOOS,
+		Arch: runtime.GOARCH,
+	}
+	info.Hostname, _ = os.Hostname()
+	if u, err := user.Current(); err == nil {
+		info.User = u.Username
+	}
+	info.DiskFreeStartBytes = diskFree(dir)
+	return info
+}
+
+// Finish records free space in dir at the end of the backup.
+func (s *SystemInfo) Finish(dir string) {
+	s.DiskFreeEndBytes = diskFree(dir)
+}
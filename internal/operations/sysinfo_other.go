@@ -0,0 +1,8 @@
+//go:build !unix && !windows
+
+package operations
+
+// diskFree is not implemented on this platform and always returns -1.
+func diskFree(dir string) int64 {
+	return -1
+}
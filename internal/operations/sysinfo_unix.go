@@ -0,0 +1,15 @@
+//go:build unix
+
+package operations
+
+import "syscall"
+
+// diskFree returns the bytes available to unprivileged users in dir,
+// or -1 when it cannot be determined.
+func diskFree(dir string) int64 {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(dir, &stat); err != nil {
+		return -1
+	}
+	return int64(stat.Bavail) * int64(stat.Bsize)
+}
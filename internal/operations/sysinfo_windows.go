@@ -0,0 +1,19 @@
+//go:build windows
+
+package operations
+
+import "golang.org/x/sys/windows"
+
+// diskFree returns the bytes available to the current user on the volume
+// holding dir, or -1 when it cannot be determined.
+func diskFree(dir string) int64 {
+	name, err := windows.UTF16PtrFromString(dir)
+	if err != nil {
+		return -1
+	}
+	var available uint64
+	if err := windows.GetDiskFreeSpaceEx(name, &available, nil, nil); err != nil {
+		return -1
+	}
+	return int64(available)
+}
@@ -0,0 +1,187 @@
+package operations
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/kebairia/backup/internal/config"
+	"github.com/kebairia/backup/internal/database"
+)
+
+// ErrTransform indicates that a dump could not be transformed before its
+// restore.
+var ErrTransform = errors.New("restore transform failed")
+
+// transformArtifact rewrites the plain SQL dump at path with the transform
+// of db's instance (see config.TransformConfig) into a temporary file next
+// to it, and returns that file and its cleanup. Without a transform, path
+// is returned as is. Other artifacts are refused rather than restored
+// unscrubbed.
+func (operator *Operator) transformArtifact(db database.Database, path string) (string, func(), error) {
+	instance := ""
+	if named, ok := db.(database.Instancer); ok {
+		instance = named.GetInstance()
+	}
+	transform := operator.config.Transform(db.GetEngine(), instance)
+	if !transform.Enabled() {
+		return path, func() {}, nil
+	}
+	if dumper, ok := db.(database.SQLDumper); !ok || !dumper.PlainSQL() {
+		return "", nil, fmt.Errorf("%w: %s of %q is not a plain SQL dump", ErrTransform, path, db.GetName())
+	}
+
+	out, err := os.CreateTemp(filepath.Dir(path), ".transform-*"+filepath.Ext(path))
+	if err != nil {
+		return "", nil, fmt.Errorf("%w: %v", ErrTransform, err)
+	}
+	cleanup := func() { os.Remove(out.Name()) }
+	err = operator.transform(transform, path, out)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("%w: %w", ErrTransform, err)
+	}
+	operator.log.Info("dump transformed",
+		"database", db.GetName(),
+		"engine", db.GetEngine(),
+		"masks", len(transform.Mask),
+		"filters", len(transform.Filters),
+	)
+	return out.Name(), cleanup, nil
+}
+
+// transform streams the dump at path through the masks, then the filters,
+// into w.
+func (operator *Operator) transform(transform config.TransformConfig, path string, w io.Writer) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	var r io.Reader = in
+	if len(transform.Mask) > 0 {
+		masked, writer := io.Pipe()
+		// unblock the masking when a filter stops reading early
+		defer masked.Close()
+		go func() { writer.CloseWithError(maskCopy(in, writer, transform.Mask)) }()
+		r = masked
+	}
+	var filters []*exec.Cmd
+	for _, filter := range transform.Filters {
+		cmd := exec.CommandContext(operator.ctx, "sh", "-c", filter)
+		cmd.Stdin = r
+		cmd.Stderr = os.Stderr
+		stdout, err := cmd.StdoutPipe()
+		if err != nil {
+			return err
+		}
+		if err := cmd.Start(); err != nil {
+			return fmt.Errorf("filter %q: %w", filter, err)
+		}
+		filters = append(filters, cmd)
+		r = stdout
+	}
+	_, copyErr := io.Copy(w, r)
+	for i, cmd := range filters {
+		if err := cmd.Wait(); err != nil {
+			return fmt.Errorf("filter %q: %w", transform.Filters[i], err)
+		}
+	}
+	return copyErr
+}
+
+// copyStatement matches the COPY statement starting the data of a table in
+// a plain pg_dump script.
+var copyStatement = regexp.MustCompile(`^COPY (\S+) \((.*)\) FROM stdin;$`)
+
+// maskCopy copies a plain pg_dump script from r to w, replacing the values
+// of the masked columns in the rows of COPY blocks (text format: fields
+// separated by tabs, rows ended by "\.").
+func maskCopy(r io.Reader, w io.Writer, rules []config.MaskRule) error {
+	reader := bufio.NewReaderSize(r, 1<<20)
+	writer := bufio.NewWriterSize(w, 1<<20)
+	var masks map[int]string // field index -> mask value, inside a masked COPY block
+	for {
+		line, err := reader.ReadString('\n')
+		if len(line) > 0 {
+			body := strings.TrimSuffix(line, "\n")
+			switch {
+			case masks != nil && body == `\.`:
+				masks = nil
+			case masks != nil:
+				fields := strings.Split(body, "\t")
+				for i, value := range masks {
+					if i < len(fields) {
+						fields[i] = maskValue(fields[i], value)
+					}
+				}
+				line = strings.Join(fields, "\t") + strings.TrimPrefix(line, body)
+			default:
+				if m := copyStatement.FindStringSubmatch(body); m != nil {
+					masks = copyMasks(m[1], m[2], rules)
+				}
+			}
+			if _, werr := writer.WriteString(line); werr != nil {
+				return werr
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return writer.Flush()
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// copyMasks returns the masks of the columns of a COPY statement of table,
+// by field index, or nil when no rule applies to the table.
+func copyMasks(table, columns string, rules []config.MaskRule) map[int]string {
+	var masks map[int]string
+	for i, column := range strings.Split(columns, ", ") {
+		for _, rule := range rules {
+			if unquote(table) == rule.Table && unquote(column) == rule.Column {
+				if masks == nil {
+					masks = make(map[int]string)
+				}
+				masks[i] = rule.Value
+			}
+		}
+	}
+	return masks
+}
+
+// unquote removes the double quotes of (possibly qualified) identifiers.
+func unquote(ident string) string {
+	return strings.ReplaceAll(ident, `"`, "")
+}
+
+// maskValue returns the replacement of a COPY field by mask. NULLs stay
+// NULL.
+func maskValue(field, mask string) string {
+	switch {
+	case field == `\N`:
+		return field
+	case mask == config.MaskNull:
+		return `\N`
+	case mask == config.MaskHash:
+		sum := sha256.Sum256([]byte(field))
+		return hex.EncodeToString(sum[:8])
+	}
+	return copyEscaper.Replace(mask)
+}
+
+// copyEscaper escapes literals for the COPY text format.
+var copyEscaper = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`)
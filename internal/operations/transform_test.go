@@ -0,0 +1,57 @@
+package operations
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kebairia/backup/internal/config"
+)
+
+const plainDump = `CREATE TABLE public.users (id integer, email text, phone text);
+COPY public.users (id, email, "phone") FROM stdin;
+1	alice@example.com	555-0100
+2	\N	555-0101
+\.
+COPY public.orders (id, email) FROM stdin;
+7	bob@example.com
+\.
+`
+
+func TestTransformMasksAndFilters(t *testing.T) {
+	operator, _ := flowOperator(t, nil)
+	path := filepath.Join(t.TempDir(), "app.sql")
+	if err := os.WriteFile(path, []byte(plainDump), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	transform := config.TransformConfig{
+		Mask: []config.MaskRule{
+			{Table: "public.users", Column: "email", Value: config.MaskHash},
+			{Table: "public.users", Column: "phone", Value: "000\t0"},
+		},
+		Filters: []string{"sed 's/CREATE TABLE/CREATE UNLOGGED TABLE/'"},
+	}
+
+	var out strings.Builder
+	if err := operator.transform(transform, path, &out); err != nil {
+		t.Fatal(err)
+	}
+	want := `CREATE UNLOGGED TABLE public.users (id integer, email text, phone text);
+COPY public.users (id, email, "phone") FROM stdin;
+1	` + maskValue("alice@example.com", config.MaskHash) + `	000\t0
+2	\N	000\t0
+\.
+COPY public.orders (id, email) FROM stdin;
+7	bob@example.com
+\.
+`
+	if out.String() != want {
+		t.Errorf("transform() =\n%s\nwant\n%s", out.String(), want)
+	}
+
+	transform.Filters = []string{"false"}
+	if err := operator.transform(transform, path, &out); err == nil {
+		t.Error("transform() with a failing filter succeeded")
+	}
+}
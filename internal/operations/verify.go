@@ -0,0 +1,212 @@
+package operations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/kebairia/backup/internal/database"
+	"github.com/kebairia/backup/internal/notify"
+	"github.com/kebairia/backup/internal/signing"
+)
+
+const defaultVerifySuffix = "_verify"
+
+// VerifyOptions controls a verification run.
+type VerifyOptions struct {
+	Database string // verify only this database (empty verifies all)
+	Deep     bool   // restore into a throwaway database and run sanity queries
+	// Signatures checks the artifact against its signed checksum.
+	Signatures bool
+}
+
+// ErrVerify indicates that at least one backup failed verification.
+var ErrVerify = errors.New("backup verification failed")
+
+// VerifyAll checks the latest backup of every configured database.
+//
+// A shallow check confirms the artifact exists and matches the recorded size.
+// A deep check restores it into a throwaway database on the verification
+// instance and counts the restored tables/collections.
+// Results are stored in each database's metadata record and returned as a
+// report with one result per database.
+func VerifyAll(ctx context.Context, configPath string, opts VerifyOptions) (notify.Report, error) {
+	operator, err := NewOperator(ctx, configPath)
+	if err != nil {
+		return notify.Report{}, err
+	}
+
+	databases, err := database.InitializeDatabases(
+		operator.ctx,
+		operator.config,
+		operator.vaultClient,
+	)
+	if err != nil {
+		return notify.Report{}, fmt.Errorf("initialize databases: %w", err)
+	}
+
+	report := notify.Report{Operation: "verify", RunID: operator.runID, StartedAt: time.Now()}
+	var errs []error
+	for _, db := range databases {
+		if opts.Database != "" && db.GetName() != opts.Database {
+			continue
+		}
+		if operator.ctx.Err() != nil {
+			break
+		}
+		start := time.Now()
+		err := operator.VerifyDatabase(db, opts)
+		result := notify.Result{
+			Engine:   db.GetEngine(),
+			Database: db.GetName(),
+			Status:   notify.StatusSuccess,
+			Duration: time.Since(start),
+		}
+		if err != nil {
+			result.Status = notify.StatusFailed
+			result.Error = err.Error()
+		}
+		report.Results = append(report.Results, result)
+		if err != nil {
+			operator.log.Error("verification failed",
+				"database", db.GetName(),
+				"engine", db.GetEngine(),
+				"error", err.Error(),
+			)
+			errs = append(errs, err)
+		}
+	}
+	report.CompletedAt = time.Now()
+	if err := operator.cancelled(); err != nil {
+		return report, err
+	}
+	if len(errs) > 0 {
+		return report, fmt.Errorf("%w: %w", ErrVerify, errors.Join(errs...))
+	}
+	return report, nil
+}
+
+// VerifyDatabase verifies the latest successful backup of db and records
+// the result in its history entry.
+func (operator *Operator) VerifyDatabase(db database.Database, opts VerifyOptions) error {
+	metadataDir := db.GetPath()
+	record, err := LoadLatestRestorable(metadataDir)
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	result := &Verification{Deep: opts.Deep, VerifiedAt: start}
+	err = operator.verifyArtifact(record)
+	if err == nil && opts.Signatures {
+		err = operator.verifyRecordSignature(record)
+		result.Signature = err == nil
+	}
+	if err == nil && opts.Deep {
+		err = operator.verifyRestore(db, record, result)
+	}
+	result.Duration = time.Since(start)
+	result.Status = StatusSuccess
+	if err != nil {
+		result.Status = StatusFailed
+		result.Error = err.Error()
+	}
+
+	record.Verification = result
+	if werr := record.Write(metadataDir); werr != nil {
+		return errors.Join(err, werr)
+	}
+	if err == nil {
+		operator.log.Info("verification completed",
+			"database", db.GetName(),
+			"engine", db.GetEngine(),
+			"deep", opts.Deep,
+			"duration", result.Duration.String(),
+		)
+	}
+	return err
+}
+
+// verifyArtifact checks that the artifact exists and has the recorded size.
+func (operator *Operator) verifyArtifact(record Metadata) error {
+	info, err := os.Stat(record.FilePath)
+	if err != nil {
+		return fmt.Errorf("artifact %q: %w", record.FilePath, err)
+	}
+	if !info.IsDir() && record.SizeBytes > 0 && info.Size() != record.SizeBytes {
+		return fmt.Errorf("artifact %q: size %d does not match recorded %d",
+			record.FilePath, info.Size(), record.SizeBytes)
+	}
+	return nil
+}
+
+// verifyRecordSignature verifies the signature of the artifact of record,
+// downloading the artifact when its local copy was pruned.
+func (operator *Operator) verifyRecordSignature(record Metadata) error {
+	path, release, err := operator.fetchArtifact(record)
+	if err != nil {
+		return err
+	}
+	defer release()
+	if isDir(path) {
+		return fmt.Errorf("%w: directory artifacts are not signed", signing.ErrSignature)
+	}
+	return operator.verifySignature(record, path)
+}
+
+// verifyRestore restores the artifact into a throwaway database and counts
+// what was restored.
+func (operator *Operator) verifyRestore(
+	db database.Database,
+	record Metadata,
+	result *Verification,
+) error {
+	verifier, ok := db.(database.Verifier)
+	if !ok {
+		return fmt.Errorf("%s does not support deep verification", db.GetEngine())
+	}
+
+	suffix := operator.config.Verify.Suffix
+	if suffix == "" {
+		suffix = defaultVerifySuffix
+	}
+	result.TargetHost = operator.config.Verify.Host
+	result.TargetDatabase = db.GetName() + suffix
+	verifier.Retarget(result.TargetHost, result.TargetDatabase)
+
+	if err := verifier.PrepareTarget(); err != nil {
+		return fmt.Errorf("prepare verification target: %w", err)
+	}
+	if !operator.config.Verify.Keep {
+		defer func() {
+			if err := verifier.DropTarget(); err != nil {
+				operator.log.Warn("failed to drop verification target",
+					"database", result.TargetDatabase,
+					"error", err.Error(),
+				)
+			}
+		}()
+	}
+
+	// incremental backups cannot be replayed into a renamed target: restore
+	// the full backup of the chain
+	chain, err := LoadChainTo(db.GetPath(), record)
+	if err != nil {
+		return err
+	}
+	if err := operator.RestoreDatabase(db, chain[0]); err != nil {
+		return err
+	}
+
+	counts, err := verifier.CountObjects()
+	if err != nil {
+		return fmt.Errorf("count restored objects: %w", err)
+	}
+	result.Counts = counts
+	if len(counts) == 0 {
+		return fmt.Errorf("%w: no tables or collections restored", database.ErrVerifyFailed)
+	}
+	return nil
+}
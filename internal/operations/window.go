@@ -0,0 +1,56 @@
+package operations
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/kebairia/backup/internal/config"
+	"github.com/kebairia/backup/internal/database"
+)
+
+// awaitWindow returns nil once db may be backed up. Outside its backup
+// window, the run waits for the window to open with backup.outside_window:
+// wait and skips db otherwise; blackout dates always skip. Skips are
+// returned as ErrSkipped wrapping config.ErrOutsideWindow.
+func (operator *Operator) awaitWindow(db database.Database) error {
+	if operator.ignoreWindow {
+		return nil
+	}
+	instance := ""
+	if named, ok := db.(database.Instancer); ok {
+		instance = named.GetInstance()
+	}
+	window, err := operator.config.BackupWindow(db.GetEngine(), instance)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	err = window.Check(now)
+	if err == nil {
+		return nil
+	}
+	if operator.config.Backup.OutsideWindow != config.OutsideWindowWait || window.Blackout(now) {
+		return fmt.Errorf("%w: %w", ErrSkipped, err)
+	}
+
+	opens := window.Opens(now)
+	operator.log.Info("waiting for backup window",
+		"database", db.GetName(),
+		"engine", db.GetEngine(),
+		"window", window.String(),
+		"opens", opens.Format(time.RFC3339),
+	)
+	timer := time.NewTimer(time.Until(opens))
+	defer timer.Stop()
+	select {
+	case <-operator.ctx.Done():
+		return fmt.Errorf("%w: %w", ErrCancelled, context.Cause(operator.ctx))
+	case <-timer.C:
+	}
+	// the window may open on a blackout date
+	if err := window.Check(time.Now()); err != nil {
+		return fmt.Errorf("%w: %w", ErrSkipped, err)
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package operations
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kebairia/backup/internal/database"
+)
+
+// workDir returns where dumps and scratch files for the artifacts of the
+// database directory dir are written (see database.WorkPath). Hidden
+// directories are scratch space already and are returned as is.
+func (operator *Operator) workDir(dir string) string {
+	if strings.HasPrefix(filepath.Base(dir), ".") {
+		return dir
+	}
+	return database.WorkPath(operator.config.Backup.Directory, operator.config.Backup.WorkDir, dir)
+}
+
+// publishArtifact moves the sealed artifact of record and its signature
+// from the work directory into dir, the database directory under
+// backup.directory, and records where they landed. This happens once the
+// dump, compression, encryption and checksum succeeded, and within one
+// file system it is a rename. Artifacts written in dir already are left
+// in place.
+func (operator *Operator) publishArtifact(record *Metadata, dir string) error {
+	if filepath.Dir(record.FilePath) == filepath.Clean(dir) {
+		return nil
+	}
+	path, err := moveInto(record.FilePath, dir)
+	if err != nil {
+		return fmt.Errorf("move %s into %s: %w", record.FilePath, dir, err)
+	}
+	record.FilePath = path
+	if record.Signature != "" {
+		signature, err := moveInto(record.Signature, dir)
+		if err != nil {
+			return fmt.Errorf("move %s into %s: %w", record.Signature, dir, err)
+		}
+		record.Signature = signature
+	}
+	return nil
+}
+
+// moveInto moves the file or directory at path into dir under the same
+// name and returns its new path. Across file systems it is copied under a
+// hidden temporary name first and renamed into place once complete, so
+// that dir never holds a partial artifact under its final name.
+func moveInto(path, dir string) (string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	target := filepath.Join(dir, filepath.Base(path))
+	if err := os.Rename(path, target); err == nil {
+		return target, nil
+	}
+
+	staged, err := os.MkdirTemp(dir, "."+filepath.Base(path)+".move-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(staged)
+	copied := filepath.Join(staged, filepath.Base(path))
+	if err := copyTree(path, copied); err != nil {
+		return "", err
+	}
+	if err := os.Rename(copied, target); err != nil {
+		return "", err
+	}
+	return target, os.RemoveAll(path)
+}
+
+// copyTree copies the file or directory src to dst.
+func copyTree(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return os.MkdirAll(target, info.Mode().Perm())
+		}
+		in, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+		out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(out, in); err != nil {
+			out.Close()
+			return err
+		}
+		return out.Close()
+	})
+}
@@ -0,0 +1,66 @@
+package operations
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kebairia/backup/internal/database"
+)
+
+// workFileDB is a fileDB dumping into the work directory, as engines do
+// with backup.work_dir.
+type workFileDB struct {
+	*fileDB
+	work string
+}
+
+func (w workFileDB) Backup(context.Context) (string, error) {
+	path := filepath.Join(w.work, "billing.dump")
+	if err := os.MkdirAll(w.work, 0o755); err != nil {
+		return "", err
+	}
+	return path, os.WriteFile(path, []byte(w.content), 0o644)
+}
+
+func TestBackupWorkDir(t *testing.T) {
+	for _, name := range []string{"partial", "work_dir"} {
+		t.Run(name, func(t *testing.T) {
+			operator, db := flowOperator(t, nil)
+			want := filepath.Join(db.dir, database.PartialDirname)
+			if name == "work_dir" {
+				operator.config.Backup.WorkDir = t.TempDir()
+				want = filepath.Join(operator.config.Backup.WorkDir, "postgres", "billing")
+			}
+			work := operator.workDir(db.dir)
+			if work != want {
+				t.Fatalf("workDir(%s) = %s, want %s", db.dir, work, want)
+			}
+
+			record, err := operator.backupDatabase(context.Background(), workFileDB{fileDB: db, work: work})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if filepath.Dir(record.FilePath) != db.dir {
+				t.Errorf("FilePath = %s, want the artifact moved into %s", record.FilePath, db.dir)
+			}
+			if left, _ := os.ReadDir(work); len(left) != 0 {
+				t.Errorf("work directory still holds %d entries", len(left))
+			}
+
+			path, cleanup, err := operator.openArtifact(record.FilePath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer cleanup()
+			if !strings.HasPrefix(path, work) {
+				t.Errorf("artifact unpacked to %s, want it under %s", path, work)
+			}
+			if data, _ := os.ReadFile(path); string(data) != db.content {
+				t.Errorf("unpacked artifact = %q, want %q", data, db.content)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/kebairia/backup/internal/config"
+)
+
+// ErrViolation indicates that at least one instance breaks a policy.
+var ErrViolation = errors.New("backup policy violated")
+
+// Violation describes one requirement an instance does not meet.
+type Violation struct {
+	Policy   string `json:"policy"`
+	Engine   string `json:"engine"`
+	Instance string `json:"instance"`
+	Rule     string `json:"rule"`
+	Message  string `json:"message"`
+}
+
+// Error implements error.
+func (v Violation) Error() string {
+	return fmt.Sprintf("%s: %s/%s: %s", v.Policy, v.Engine, v.Instance, v.Message)
+}
+
+// Evaluate checks every configured policy against the effective
+// configuration and returns the violations found, in config order.
+func Evaluate(cfg config.Config) []Violation {
+	groups := []struct {
+		engine string
+		group  config.DBGroupConfig
+	}{
+		{"postgres", cfg.Postgres},
+		{"mongodb", cfg.MongoDB},
+		{"mysql", cfg.MySQL},
+		{"redis", cfg.Redis},
+		{"clickhouse", cfg.ClickHouse},
+		{"cassandra", cfg.Cassandra},
+		{"influxdb", cfg.InfluxDB},
+		{"couchdb", cfg.CouchDB},
+	}
+
+	var violations []Violation
+	for _, p := range cfg.Policies {
+		for _, g := range groups {
+			if p.Engine != "" && p.Engine != g.engine {
+				continue
+			}
+			for _, instance := range g.group.Instances {
+				if !hasTags(instance.Tags, p.Tags) {
+					continue
+				}
+				name := instance.Name
+				if name == "" {
+					name = instance.Database
+				}
+				for _, v := range check(cfg, g.engine, instance.Name, p.Require) {
+					v.Policy, v.Engine, v.Instance = p.Name, g.engine, name
+					violations = append(violations, v)
+				}
+			}
+		}
+	}
+	return violations
+}
+
+// check returns the requirements the effective configuration of the
+// instance named instance of engine does not meet. Retention and storage
+// are global settings today, compression is per instance.
+func check(cfg config.Config, engine, instance string, req config.PolicyRequirements) []Violation {
+	var violations []Violation
+	add := func(rule, format string, args ...any) {
+		violations = append(violations, Violation{Rule: rule, Message: fmt.Sprintf(format, args...)})
+	}
+
+	// the longest-lived copy counts: local disk may keep fewer backups
+	keep := cfg.Retention.MaxKeep()
+	if req.MinKeep > 0 && keep < req.MinKeep {
+		add("min_keep", "retention keeps %d backups, policy requires at least %d",
+			keep, req.MinKeep)
+	}
+	if req.MinRetention > 0 {
+		retention := time.Duration(keep) * cfg.Retention.Interval
+		if retention < req.MinRetention {
+			add("min_retention", "retention covers %s, policy requires at least %s",
+				retention, req.MinRetention)
+		}
+	}
+	if req.Compression && cfg.Compression(engine, instance) == config.CompressionNone {
+		add("compression", "compression is disabled")
+	}
+	if req.Encryption && cfg.Backup.Encryption.Type == "" {
+		add("encryption", "encryption is not configured")
+	}
+	if req.RemoteStorage && cfg.Storage.Type == "" {
+		add("remote_storage", "no remote storage backend is configured")
+	}
+	return violations
+}
+
+// hasTags reports whether have contains every tag in want.
+func hasTags(have, want []string) bool {
+	for _, tag := range want {
+		if !slices.Contains(have, tag) {
+			return false
+		}
+	}
+	return true
+}
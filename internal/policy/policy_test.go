@@ -0,0 +1,45 @@
+package policy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kebairia/backup/internal/config"
+)
+
+func TestEvaluate(t *testing.T) {
+	cfg := config.Config{
+		Retention: config.RetentionConfig{Keep: 7, Interval: 24 * time.Hour},
+		Backup:    config.BackupConfig{Compression: true},
+		Postgres: config.DBGroupConfig{Instances: []config.DBInstance{
+			{Name: "billing", Tags: []string{"prod"}},
+			{Name: "scratch", Tags: []string{"dev"}},
+		}},
+		MongoDB: config.DBGroupConfig{Instances: []config.DBInstance{
+			{Name: "events", Tags: []string{"prod"}},
+		}},
+		Policies: []config.PolicyConfig{{
+			Name:   "prod-postgres",
+			Engine: "postgres",
+			Tags:   []string{"prod"},
+			Require: config.PolicyRequirements{
+				MinRetention: 30 * 24 * time.Hour,
+				Compression:  true,
+			},
+		}},
+	}
+
+	violations := Evaluate(cfg)
+	if len(violations) != 1 {
+		t.Fatalf("got %d violations, want 1: %v", len(violations), violations)
+	}
+	v := violations[0]
+	if v.Instance != "billing" || v.Rule != "min_retention" {
+		t.Errorf("unexpected violation %+v", v)
+	}
+
+	cfg.Retention.Keep = 30
+	if violations := Evaluate(cfg); len(violations) != 0 {
+		t.Errorf("expected no violations, got %v", violations)
+	}
+}
@@ -0,0 +1,253 @@
+// Package progress renders the progress of dumps, compression and uploads
+// on interactive terminals: one line per transfer with the bytes done, the
+// speed and, when the total is known, the percentage and time left.
+//
+// Rendering is off until Enable is called; Bars created while it is off are
+// nil, and all Bar methods are no-ops on nil, so callers need no checks.
+package progress
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// refresh is how often progress lines are redrawn.
+const refresh = 250 * time.Millisecond
+
+// display draws the active bars below the other terminal output.
+type display struct {
+	mu    sync.Mutex
+	out   io.Writer
+	bars  []*Bar
+	lines int // progress lines currently drawn
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+var active atomic.Pointer[display]
+
+// Enable renders progress on f when it is an interactive terminal, and
+// reports whether it does. Other output to the same terminal must go
+// through Writer so that it is not drawn over.
+func Enable(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+	d := &display{out: f, stop: make(chan struct{}), done: make(chan struct{})}
+	if !active.CompareAndSwap(nil, d) {
+		return true
+	}
+	go d.run()
+	return true
+}
+
+// Stop stops rendering and clears the progress lines.
+func Stop() {
+	d := active.Swap(nil)
+	if d == nil {
+		return
+	}
+	close(d.stop)
+	<-d.done
+	d.mu.Lock()
+	d.clear()
+	d.mu.Unlock()
+}
+
+// Writer returns w, wrapped so that writes clear the progress lines first
+// and redraw them after, when progress is rendered.
+func Writer(w io.Writer) io.Writer {
+	d := active.Load()
+	if d == nil {
+		return w
+	}
+	return &passthrough{d: d, w: w}
+}
+
+type passthrough struct {
+	d *display
+	w io.Writer
+}
+
+func (p *passthrough) Write(b []byte) (int, error) {
+	p.d.mu.Lock()
+	defer p.d.mu.Unlock()
+	p.d.clear()
+	n, err := p.w.Write(b)
+	if len(b) > 0 && b[len(b)-1] == '\n' {
+		p.d.draw()
+	}
+	return n, err
+}
+
+func (d *display) run() {
+	defer close(d.done)
+	ticker := time.NewTicker(refresh)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-d.stop:
+			return
+		case <-ticker.C:
+			d.mu.Lock()
+			d.clear()
+			d.draw()
+			d.mu.Unlock()
+		}
+	}
+}
+
+// clear erases the drawn progress lines; d.mu must be held.
+func (d *display) clear() {
+	if d.lines > 0 {
+		fmt.Fprintf(d.out, "\x1b[%dA\x1b[J", d.lines)
+		d.lines = 0
+	}
+}
+
+// draw renders every active bar, one per line; d.mu must be held.
+func (d *display) draw() {
+	var b strings.Builder
+	for _, bar := range d.bars {
+		b.WriteString(bar.String())
+		b.WriteString("\x1b[K\n")
+	}
+	io.WriteString(d.out, b.String())
+	d.lines = len(d.bars)
+}
+
+func (d *display) remove(bar *Bar) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for i, b := range d.bars {
+		if b == bar {
+			d.bars = append(d.bars[:i], d.bars[i+1:]...)
+			break
+		}
+	}
+	d.clear()
+	d.draw()
+}
+
+// Bar tracks one transfer.
+type Bar struct {
+	label string
+	total int64 // zero when unknown
+	done  atomic.Int64
+	start time.Time
+	d     *display
+}
+
+// New returns a bar labelled label for a transfer of total bytes (zero when
+// unknown), or nil when progress is not rendered. Call Finish when the
+// transfer ends.
+func New(label string, total int64) *Bar {
+	d := active.Load()
+	if d == nil {
+		return nil
+	}
+	bar := &Bar{label: label, total: total, start: time.Now(), d: d}
+	d.mu.Lock()
+	d.bars = append(d.bars, bar)
+	d.mu.Unlock()
+	return bar
+}
+
+// Add records n more bytes transferred.
+func (b *Bar) Add(n int64) {
+	if b != nil {
+		b.done.Add(n)
+	}
+}
+
+// Set records n bytes transferred in all.
+func (b *Bar) Set(n int64) {
+	if b != nil {
+		b.done.Store(n)
+	}
+}
+
+// Finish removes the bar.
+func (b *Bar) Finish() {
+	if b != nil {
+		b.d.remove(b)
+	}
+}
+
+// Reader returns r, counting the bytes read from it on b.
+func (b *Bar) Reader(r io.Reader) io.Reader {
+	if b == nil {
+		return r
+	}
+	return &reader{r: r, bar: b}
+}
+
+type reader struct {
+	r   io.Reader
+	bar *Bar
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.bar.Add(int64(n))
+	return n, err
+}
+
+// String renders the bar, e.g.
+// "upload app  12.0 MiB / 40.0 MiB  30%  4.0 MiB/s  ETA 7s".
+func (b *Bar) String() string {
+	done := b.done.Load()
+	elapsed := time.Since(b.start)
+	var speed float64
+	if elapsed > 0 {
+		speed = float64(done) / elapsed.Seconds()
+	}
+	line := fmt.Sprintf("%-24s %s", b.label, formatBytes(done))
+	if b.total > 0 {
+		line += fmt.Sprintf(" / %s  %3d%%", formatBytes(b.total), min(done*100/b.total, 100))
+	}
+	line += fmt.Sprintf("  %s/s", formatBytes(int64(speed)))
+	if b.total > done && speed > 0 {
+		eta := time.Duration(float64(b.total-done) / speed * float64(time.Second))
+		line += "  ETA " + eta.Round(time.Second).String()
+	}
+	return line
+}
+
+// formatBytes renders n with a binary unit, e.g. "1.5 MiB", as in cmd.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+type contextKey struct{}
+
+// WithBar returns a context carrying bar, for code that moves the bytes
+// without knowing about the caller's transfer (e.g. storage backends).
+func WithBar(ctx context.Context, bar *Bar) context.Context {
+	if bar == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, contextKey{}, bar)
+}
+
+// FromContext returns the bar carried by ctx, or nil.
+func FromContext(ctx context.Context) *Bar {
+	bar, _ := ctx.Value(contextKey{}).(*Bar)
+	return bar
+}
@@ -0,0 +1,32 @@
+package progress
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBarString(t *testing.T) {
+	bar := &Bar{label: "upload app", total: 4 << 20, start: time.Now().Add(-time.Second)}
+	bar.Set(1 << 20)
+	got := bar.String()
+	for _, want := range []string{"upload app", "1.0 MiB / 4.0 MiB", " 25%", "ETA"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("String() = %q, want %q in it", got, want)
+		}
+	}
+}
+
+func TestDisabled(t *testing.T) {
+	// without Enable, bars are nil and every method is a no-op
+	bar := New("dump app", 0)
+	if bar != nil {
+		t.Fatal("New returned a bar while progress is off")
+	}
+	bar.Add(1)
+	bar.Finish()
+	r := strings.NewReader("data")
+	if bar.Reader(r) != r {
+		t.Error("nil bar wrapped the reader")
+	}
+}
@@ -0,0 +1,36 @@
+package redact
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestString(t *testing.T) {
+	Register("hunter22")
+	tests := []struct{ in, want string }{
+		{"mongodump --username=app --password=s3cr3t --db=app", "mongodump --username=app --password=*** --db=app"},
+		{"nodetool -u cassandra -pw s3cr3t snapshot", "nodetool -u cassandra -pw *** snapshot"},
+		{"env PGPASSWORD=s3cr3t pg_dump", "env PGPASSWORD=*** pg_dump"},
+		{"dial mongodb://app:s3cr3t@db:27017/app failed", "dial mongodb://app:***@db:27017/app failed"},
+		{"Authorization: Bearer abc.def", "Authorization: Bearer ***"},
+		{"authentication failed for hunter22", "authentication failed for ***"},
+		{"postgres://db:5432/app --port=5432", "postgres://db:5432/app --port=5432"},
+	}
+	for _, tt := range tests {
+		if got := String(tt.in); got != tt.want {
+			t.Errorf("String(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestError(t *testing.T) {
+	sentinel := errors.New("dump failed")
+	err := Error(fmt.Errorf("%w: mysql -u root --password=s3cr3t", sentinel))
+	if got, want := err.Error(), "dump failed: mysql -u root --password=***"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+	if !errors.Is(err, sentinel) {
+		t.Error("redacted error no longer matches its cause")
+	}
+}
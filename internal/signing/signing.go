@@ -0,0 +1,178 @@
+// Package signing signs the checksums of backup artifacts with detached
+// signatures, so that an artifact's provenance can be confirmed before it is
+// restored. The signed message is the artifact's checksum line (see
+// Message), which is cheap to sign however large the artifact; verifying it
+// against a fresh checksum of the artifact covers the data.
+//
+// Signatures are made with external tools holding keys managed outside
+// bacli: minisign or GnuPG. age only encrypts and cannot sign.
+package signing
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/kebairia/backup/internal/config"
+)
+
+// Signing types.
+const (
+	TypeMinisign = "minisign"
+	TypeGPG      = "gpg"
+)
+
+var (
+	// ErrSigning indicates that an artifact could not be signed.
+	ErrSigning = errors.New("signing failed")
+	// ErrSignature indicates a missing or invalid signature.
+	ErrSignature = errors.New("signature verification failed")
+)
+
+// Signer makes and checks detached signatures of messages.
+type Signer interface {
+	// Type returns the signing type, e.g. "minisign".
+	Type() string
+	// Ext returns the suffix of signature files, e.g. ".minisig".
+	Ext() string
+	// Sign writes the signature of message to sigPath.
+	Sign(ctx context.Context, message []byte, sigPath string) error
+	// Verify checks the signature at sigPath against message.
+	Verify(ctx context.Context, message []byte, sigPath string) error
+}
+
+// Message returns the message signed for an artifact named name with
+// checksum, in the format of sha256sum(1): "<hex>  <name>\n".
+func Message(checksum, name string) []byte {
+	return []byte(strings.TrimPrefix(checksum, "sha256:") + "  " + name + "\n")
+}
+
+// New returns the signer selected in cfg, or nil when artifacts are not
+// signed.
+func New(cfg config.SigningConfig) (Signer, error) {
+	if cfg.Type == "" {
+		return nil, nil
+	}
+	passphrase, err := cfg.Passphrase()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrSigning, err)
+	}
+	switch cfg.Type {
+	case TypeMinisign:
+		return &Minisign{SecretKey: cfg.Key, PublicKey: cfg.PublicKey, passphrase: passphrase}, nil
+	case TypeGPG:
+		return &GPG{KeyID: cfg.Key, Home: cfg.GPGHome, passphrase: passphrase}, nil
+	}
+	return nil, fmt.Errorf("%w: unsupported type %q", ErrSigning, cfg.Type)
+}
+
+// Minisign signs with minisign(1).
+type Minisign struct {
+	SecretKey  string // secret key file, for signing
+	PublicKey  string // public key file, for verifying
+	passphrase string // of the secret key; empty for keys made with -W
+}
+
+func (m *Minisign) Type() string { return TypeMinisign }
+func (m *Minisign) Ext() string  { return ".minisig" }
+
+// Sign runs `minisign -S`, passing the passphrase on stdin.
+func (m *Minisign) Sign(ctx context.Context, message []byte, sigPath string) error {
+	if m.SecretKey == "" {
+		return fmt.Errorf("%w: no minisign secret key", ErrSigning)
+	}
+	return withMessage(message, func(path string) error {
+		cmd := exec.CommandContext(ctx, "minisign", "-S", "-s", m.SecretKey, "-m", path, "-x", sigPath)
+		cmd.Stdin = strings.NewReader(m.passphrase + "\n")
+		return run(cmd, ErrSigning)
+	})
+}
+
+// Verify runs `minisign -V` with the public key.
+func (m *Minisign) Verify(ctx context.Context, message []byte, sigPath string) error {
+	if m.PublicKey == "" {
+		return fmt.Errorf("%w: no minisign public key", ErrSignature)
+	}
+	return withMessage(message, func(path string) error {
+		cmd := exec.CommandContext(ctx, "minisign", "-V", "-q", "-p", m.PublicKey, "-m", path, "-x", sigPath)
+		return run(cmd, ErrSignature)
+	})
+}
+
+// GPG signs with gpg(1), making ASCII-armored signatures.
+type GPG struct {
+	KeyID      string // --local-user; empty for gpg's default key
+	Home       string // GNUPGHOME; empty for gpg's default
+	passphrase string // of the secret key; empty when gpg-agent holds it
+}
+
+func (g *GPG) Type() string { return TypeGPG }
+func (g *GPG) Ext() string  { return ".asc" }
+
+// Sign runs `gpg --detach-sign`, passing the passphrase on stdin when set.
+func (g *GPG) Sign(ctx context.Context, message []byte, sigPath string) error {
+	args := g.args("--yes", "--armor", "--detach-sign", "--output", sigPath)
+	if g.KeyID != "" {
+		args = append(args, "--local-user", g.KeyID)
+	}
+	if g.passphrase != "" {
+		args = append(args, "--pinentry-mode", "loopback", "--passphrase-fd", "0")
+	}
+	return withMessage(message, func(path string) error {
+		cmd := exec.CommandContext(ctx, "gpg", append(args, path)...)
+		cmd.Stdin = strings.NewReader(g.passphrase + "\n")
+		return run(cmd, ErrSigning)
+	})
+}
+
+// Verify runs `gpg --verify` against the keyring.
+func (g *GPG) Verify(ctx context.Context, message []byte, sigPath string) error {
+	return withMessage(message, func(path string) error {
+		cmd := exec.CommandContext(ctx, "gpg", g.args("--verify", sigPath, path)...)
+		return run(cmd, ErrSignature)
+	})
+}
+
+func (g *GPG) args(args ...string) []string {
+	global := []string{"--batch", "--quiet"}
+	if g.Home != "" {
+		global = append(global, "--homedir", g.Home)
+	}
+	return append(global, args...)
+}
+
+// withMessage writes message to a temporary file for fn.
+func withMessage(message []byte, fn func(path string) error) error {
+	file, err := os.CreateTemp("", "bacli-message-*")
+	if err != nil {
+		return fmt.Errorf("create message file: %w", err)
+	}
+	defer os.Remove(file.Name())
+	_, err = file.Write(message)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("write message file: %w", err)
+	}
+	return fn(file.Name())
+}
+
+// run runs cmd, wrapping a failure in sentinel with the tool's stderr.
+func run(cmd *exec.Cmd, sentinel error) error {
+	var stderr bytes.Buffer
+	cmd.Stdout = io.Discard
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("%w: %s: %v: %s", sentinel, cmd.Args[0], err, msg)
+		}
+		return fmt.Errorf("%w: %s: %v", sentinel, cmd.Args[0], err)
+	}
+	return nil
+}
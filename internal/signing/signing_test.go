@@ -0,0 +1,28 @@
+package signing
+
+import (
+	"testing"
+
+	"github.com/kebairia/backup/internal/config"
+)
+
+func TestMessage(t *testing.T) {
+	got := string(Message("sha256:abc123", "app.dump.gz"))
+	if want := "abc123  app.dump.gz\n"; got != want {
+		t.Errorf("Message() = %q, want %q", got, want)
+	}
+}
+
+func TestNew(t *testing.T) {
+	signer, err := New(config.SigningConfig{})
+	if err != nil || signer != nil {
+		t.Fatalf("New(empty) = %v, %v; want nil, nil", signer, err)
+	}
+	signer, err = New(config.SigningConfig{Type: TypeMinisign, Key: "k", PublicKey: "p"})
+	if err != nil || signer.Ext() != ".minisig" {
+		t.Fatalf("New(minisign) = %v, %v", signer, err)
+	}
+	if _, err := New(config.SigningConfig{Type: "age"}); err == nil {
+		t.Error("New(age) succeeded, want an error")
+	}
+}
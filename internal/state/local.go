@@ -0,0 +1,137 @@
+package state
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Local keeps run state as JSON files on this host.
+type Local struct {
+	Dir string
+}
+
+// Ensure Local satisfies Store.
+var _ Store = (*Local)(nil)
+
+// NewLocal returns a Local store rooted at dir.
+func NewLocal(dir string) *Local {
+	return &Local{Dir: dir}
+}
+
+// Lock creates the lock file exclusively, replacing it when expired.
+func (l *Local) Lock(ctx context.Context, key, owner string, ttl time.Duration) error {
+	path := l.path("locks", key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("%w: %v", ErrState, err)
+	}
+	data, err := json.Marshal(Lock{Owner: owner, ExpiresAt: time.Now().Add(ttl)})
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrState, err)
+	}
+
+	for attempt := 0; attempt < 2; attempt++ {
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if err == nil {
+			_, werr := file.Write(data)
+			cerr := file.Close()
+			if werr != nil || cerr != nil {
+				return fmt.Errorf("%w: write lock %s: %v", ErrState, key, errors.Join(werr, cerr))
+			}
+			return nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return fmt.Errorf("%w: %v", ErrState, err)
+		}
+		var current Lock
+		if err := readJSON(path, &current); err == nil && current.Held(time.Now()) && current.Owner != owner {
+			return fmt.Errorf("%w: %s held by %s until %s",
+				ErrLocked, key, current.Owner, current.ExpiresAt.Format(time.RFC3339))
+		}
+		// stale or unreadable lock: remove and retry once
+		_ = os.Remove(path)
+	}
+	return fmt.Errorf("%w: %s", ErrLocked, key)
+}
+
+// Unlock removes the lock file if owner holds it.
+func (l *Local) Unlock(ctx context.Context, key, owner string) error {
+	path := l.path("locks", key)
+	var current Lock
+	if err := readJSON(path, &current); err != nil {
+		return nil
+	}
+	if current.Owner != owner {
+		return nil
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%w: %v", ErrState, err)
+	}
+	return nil
+}
+
+// SetLastRun writes the run marker.
+func (l *Local) SetLastRun(ctx context.Context, run LastRun) error {
+	path := l.path("runs", Key(run.Engine, run.Database))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("%w: %v", ErrState, err)
+	}
+	data, err := json.MarshalIndent(run, "", "  ")
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrState, err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("%w: %v", ErrState, err)
+	}
+	return nil
+}
+
+// Statuses reads every run marker and its lock.
+func (l *Local) Statuses(ctx context.Context) ([]Status, error) {
+	root := filepath.Join(l.Dir, "runs")
+	var statuses []Status
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(path, ".json") {
+			return nil
+		}
+		var status Status
+		if err := readJSON(path, &status.LastRun); err != nil {
+			return err
+		}
+		var lock Lock
+		if err := readJSON(l.path("locks", Key(status.Engine, status.Database)), &lock); err == nil &&
+			lock.Held(time.Now()) {
+			status.LockedBy = lock.Owner
+		}
+		statuses = append(statuses, status)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrState, err)
+	}
+	return statuses, nil
+}
+
+func (l *Local) path(kind, key string) string {
+	return filepath.Join(l.Dir, kind, filepath.FromSlash(key)+".json")
+}
+
+func readJSON(path string, v any) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,60 @@
+package state
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLocalLock(t *testing.T) {
+	ctx := context.Background()
+	store := NewLocal(t.TempDir())
+	key := Key("postgres", "app")
+
+	if err := store.Lock(ctx, key, "a:1", time.Hour); err != nil {
+		t.Fatalf("first lock: %v", err)
+	}
+	if err := store.Lock(ctx, key, "b:2", time.Hour); !errors.Is(err, ErrLocked) {
+		t.Fatalf("second lock: got %v, want ErrLocked", err)
+	}
+	if err := store.Unlock(ctx, key, "a:1"); err != nil {
+		t.Fatalf("unlock: %v", err)
+	}
+	if err := store.Lock(ctx, key, "b:2", time.Hour); err != nil {
+		t.Fatalf("lock after unlock: %v", err)
+	}
+
+	// An expired lock is taken over.
+	other := Key("postgres", "billing")
+	if err := store.Lock(ctx, other, "a:1", -time.Second); err != nil {
+		t.Fatalf("expired lock: %v", err)
+	}
+	if err := store.Lock(ctx, other, "b:2", time.Hour); err != nil {
+		t.Fatalf("take over expired lock: %v", err)
+	}
+}
+
+func TestLocalStatuses(t *testing.T) {
+	ctx := context.Background()
+	store := NewLocal(t.TempDir())
+
+	run := LastRun{Engine: "mysql", Database: "shop", Status: "success", Host: "db1"}
+	if err := store.SetLastRun(ctx, run); err != nil {
+		t.Fatalf("set last run: %v", err)
+	}
+	if err := store.Lock(ctx, Key("mysql", "shop"), "db2:7", time.Hour); err != nil {
+		t.Fatalf("lock: %v", err)
+	}
+
+	statuses, err := store.Statuses(ctx)
+	if err != nil {
+		t.Fatalf("statuses: %v", err)
+	}
+	if len(statuses) != 1 {
+		t.Fatalf("got %d statuses, want 1", len(statuses))
+	}
+	if s := statuses[0]; s.Database != "shop" || s.Host != "db1" || s.LockedBy != "db2:7" {
+		t.Fatalf("unexpected status %+v", s)
+	}
+}
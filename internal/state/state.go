@@ -0,0 +1,81 @@
+package state
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+const (
+	BackendLocal = "local"
+	BackendVault = "vault"
+)
+
+var (
+	ErrState              = errors.New("run state operation failed")
+	ErrLocked             = errors.New("database is locked by another run")
+	ErrUnsupportedBackend = errors.New("unsupported state backend")
+)
+
+// LastRun is the marker left behind by the most recent backup of a database.
+type LastRun struct {
+	Engine      string    `json:"engine"`
+	Database    string    `json:"database"`
+	Status      string    `json:"status"`
+	Error       string    `json:"error,omitempty"`
+	Host        string    `json:"host"`
+	FilePath    string    `json:"file_path,omitempty"`
+	StartedAt   time.Time `json:"started_at"`
+	CompletedAt time.Time `json:"completed_at"`
+	// LastSuccessAt and SizeBytes describe the latest successful backup,
+	// this run or an earlier one, for freshness checks.
+	LastSuccessAt time.Time `json:"last_success_at,omitzero"`
+	SizeBytes     int64     `json:"size_bytes,omitempty"`
+}
+
+// Lock is held by a run while it backs up a database.
+type Lock struct {
+	Owner     string    `json:"owner"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Held reports whether the lock is still in force at now.
+func (l Lock) Held(now time.Time) bool {
+	return l.Owner != "" && now.Before(l.ExpiresAt)
+}
+
+// Status combines a database's last run with its current lock, if any.
+type Status struct {
+	LastRun
+	LockedBy string `json:"locked_by,omitempty"`
+}
+
+// Store persists run markers and per-database locks.
+// Keys have the form "<engine>/<database>".
+type Store interface {
+	// Lock takes the lock for key for ttl, failing with ErrLocked when
+	// another owner holds an unexpired lock.
+	Lock(ctx context.Context, key, owner string, ttl time.Duration) error
+	// Unlock releases a lock held by owner.
+	Unlock(ctx context.Context, key, owner string) error
+	// SetLastRun records the outcome of a run.
+	SetLastRun(ctx context.Context, run LastRun) error
+	// Statuses returns the last run and lock of every known database.
+	Statuses(ctx context.Context) ([]Status, error)
+}
+
+// Key returns the store key for a database.
+func Key(engine, database string) string {
+	return engine + "/" + database
+}
+
+// Owner identifies this process as a lock holder ("<hostname>:<pid>").
+func Owner() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s:%d", host, os.Getpid())
+}
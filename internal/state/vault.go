@@ -0,0 +1,171 @@
+package state
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/kebairia/backup/internal/vault"
+)
+
+// KVClient reads, writes and lists the secrets of a Vault KV v2 mount. It is
+// implemented by *vault.Client; tests substitute fakes.
+type KVClient interface {
+	ReadKV(ctx context.Context, mount, path string) (map[string]any, int, error)
+	WriteKV(ctx context.Context, mount, path string, data map[string]any, cas int) error
+	ListKV(ctx context.Context, mount, path string) ([]string, error)
+}
+
+// Vault keeps run state in a Vault KV v2 mount so several bacli hosts can
+// share responsibility for the same databases. Locks rely on KV v2
+// check-and-set writes.
+type Vault struct {
+	Client KVClient
+	Mount  string // KV v2 mount, e.g. "secret"
+	Prefix string // path under the mount, e.g. "bacli/state"
+}
+
+// Ensure Vault satisfies Store.
+var _ Store = (*Vault)(nil)
+
+// NewVault returns a Vault store.
+func NewVault(client KVClient, mount, prefix string) (*Vault, error) {
+	if client == nil {
+		return nil, fmt.Errorf("%w: vault state requires a vault client", ErrState)
+	}
+	if mount == "" {
+		mount = "secret"
+	}
+	if prefix == "" {
+		prefix = "bacli/state"
+	}
+	return &Vault{Client: client, Mount: mount, Prefix: strings.Trim(prefix, "/")}, nil
+}
+
+// Lock writes the lock with check-and-set so only one host can win.
+func (v *Vault) Lock(ctx context.Context, key, owner string, ttl time.Duration) error {
+	lockPath := v.path("locks", key)
+	data, version, err := v.Client.ReadKV(ctx, v.Mount, lockPath)
+	if err != nil {
+		return fmt.Errorf("%w: read lock %s: %v", ErrState, key, err)
+	}
+	var current Lock
+	if err := decode(data, &current); err != nil {
+		return fmt.Errorf("%w: decode lock %s: %v", ErrState, key, err)
+	}
+	if current.Held(time.Now()) && current.Owner != owner {
+		return fmt.Errorf("%w: %s held by %s until %s",
+			ErrLocked, key, current.Owner, current.ExpiresAt.Format(time.RFC3339))
+	}
+
+	lock, err := encode(Lock{Owner: owner, ExpiresAt: time.Now().Add(ttl)})
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrState, err)
+	}
+	if err := v.Client.WriteKV(ctx, v.Mount, lockPath, lock, version); err != nil {
+		if errors.Is(err, vault.ErrCASMismatch) {
+			return fmt.Errorf("%w: %s taken by another host", ErrLocked, key)
+		}
+		return fmt.Errorf("%w: write lock %s: %v", ErrState, key, err)
+	}
+	return nil
+}
+
+// Unlock expires the lock if owner holds it.
+func (v *Vault) Unlock(ctx context.Context, key, owner string) error {
+	lockPath := v.path("locks", key)
+	data, version, err := v.Client.ReadKV(ctx, v.Mount, lockPath)
+	if err != nil {
+		return fmt.Errorf("%w: read lock %s: %v", ErrState, key, err)
+	}
+	var current Lock
+	if err := decode(data, &current); err != nil || current.Owner != owner {
+		return nil
+	}
+	released, err := encode(Lock{Owner: owner, ExpiresAt: time.Now()})
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrState, err)
+	}
+	if err := v.Client.WriteKV(ctx, v.Mount, lockPath, released, version); err != nil {
+		return fmt.Errorf("%w: release lock %s: %v", ErrState, key, err)
+	}
+	return nil
+}
+
+// SetLastRun writes the run marker.
+func (v *Vault) SetLastRun(ctx context.Context, run LastRun) error {
+	data, err := encode(run)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrState, err)
+	}
+	if err := v.Client.WriteKV(ctx, v.Mount, v.path("runs", Key(run.Engine, run.Database)), data, -1); err != nil {
+		return fmt.Errorf("%w: write run marker: %v", ErrState, err)
+	}
+	return nil
+}
+
+// Statuses lists every run marker ("runs/<engine>/<database>") and its lock.
+func (v *Vault) Statuses(ctx context.Context) ([]Status, error) {
+	engines, err := v.Client.ListKV(ctx, v.Mount, path.Join(v.Prefix, "runs"))
+	if err != nil {
+		return nil, fmt.Errorf("%w: list engines: %v", ErrState, err)
+	}
+	var statuses []Status
+	for _, engine := range engines {
+		engine = strings.TrimSuffix(engine, "/")
+		databases, err := v.Client.ListKV(ctx, v.Mount, path.Join(v.Prefix, "runs", engine))
+		if err != nil {
+			return nil, fmt.Errorf("%w: list %s: %v", ErrState, engine, err)
+		}
+		for _, database := range databases {
+			key := Key(engine, database)
+			data, _, err := v.Client.ReadKV(ctx, v.Mount, v.path("runs", key))
+			if err != nil {
+				return nil, fmt.Errorf("%w: read %s: %v", ErrState, key, err)
+			}
+			var status Status
+			if err := decode(data, &status.LastRun); err != nil {
+				return nil, fmt.Errorf("%w: decode %s: %v", ErrState, key, err)
+			}
+			lockData, _, err := v.Client.ReadKV(ctx, v.Mount, v.path("locks", key))
+			if err == nil {
+				var lock Lock
+				if decode(lockData, &lock) == nil && lock.Held(time.Now()) {
+					status.LockedBy = lock.Owner
+				}
+			}
+			statuses = append(statuses, status)
+		}
+	}
+	return statuses, nil
+}
+
+func (v *Vault) path(kind, key string) string {
+	return path.Join(v.Prefix, kind, key)
+}
+
+// encode converts v into the generic map stored in KV.
+func encode(v any) (map[string]any, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var data map[string]any
+	return data, json.Unmarshal(raw, &data)
+}
+
+// decode converts KV data back into v. Nil data leaves v untouched.
+func decode(data map[string]any, v any) error {
+	if data == nil {
+		return nil
+	}
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, v)
+}
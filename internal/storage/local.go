@@ -0,0 +1,118 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kebairia/backup/internal/progress"
+)
+
+// Local stores artifacts in a directory on a locally mounted filesystem.
+type Local struct {
+	Root string
+}
+
+// Ensure Local satisfies Backend.
+var _ Backend = (*Local)(nil)
+
+// NewLocal returns a Local backend rooted at root.
+func NewLocal(root string) (*Local, error) {
+	if root == "" {
+		return nil, fmt.Errorf("%w: local storage path is required", ErrStorage)
+	}
+	return &Local{Root: root}, nil
+}
+
+// Name returns the backend name.
+func (l *Local) Name() string { return TypeLocal }
+
+// Upload copies localPath to key under the root directory.
+func (l *Local) Upload(ctx context.Context, localPath, key string) error {
+	return copyFile(localPath, l.path(key), progress.FromContext(ctx))
+}
+
+// Download copies key from the root directory to localPath.
+func (l *Local) Download(ctx context.Context, key, localPath string) error {
+	if _, err := os.Stat(l.path(key)); errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+	return copyFile(l.path(key), localPath, nil)
+}
+
+// List returns every object whose key starts with prefix.
+func (l *Local) List(ctx context.Context, prefix string) ([]Object, error) {
+	var objects []Object
+	err := filepath.WalkDir(l.Root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(l.Root, path)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		objects = append(objects, Object{Key: key, Size: info.Size(), ModTime: info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: list %s: %v", ErrStorage, l.Root, err)
+	}
+	return objects, nil
+}
+
+// Delete removes key from the root directory.
+func (l *Local) Delete(ctx context.Context, key string) error {
+	if err := os.Remove(l.path(key)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%w: delete %s: %v", ErrStorage, key, err)
+	}
+	return nil
+}
+
+func (l *Local) path(key string) string {
+	return filepath.Join(l.Root, filepath.FromSlash(key))
+}
+
+// copyFile copies src to dst, creating dst's parent directories, and
+// counts the bytes copied on bar.
+func copyFile(src, dst string, bar *progress.Bar) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("%w: open %s: %v", ErrStorage, src, err)
+	}
+	defer in.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return fmt.Errorf("%w: mkdir %s: %v", ErrStorage, filepath.Dir(dst), err)
+	}
+	out, err := os.Create(dst)
+	if err != nil {
+		return fmt.Errorf("%w: create %s: %v", ErrStorage, dst, err)
+	}
+	if _, err := io.Copy(out, bar.Reader(in)); err != nil {
+		out.Close()
+		return fmt.Errorf("%w: copy %s: %v", ErrStorage, src, err)
+	}
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("%w: close %s: %v", ErrStorage, dst, err)
+	}
+	return nil
+}
@@ -0,0 +1,232 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"time"
+)
+
+// rclone exit codes, see https://rclone.org/docs/#exit-code
+const (
+	rcloneDirNotFound  = 3
+	rcloneFileNotFound = 4
+)
+
+// RcloneOption defines a functional option for configuring an Rclone backend.
+type RcloneOption func(*Rclone)
+
+// Rclone stores artifacts on any rclone remote (Google Drive, Azure Blob,
+// SFTP, B2, ...) by running the rclone binary.
+type Rclone struct {
+	Remote         string   // e.g. "gdrive:backups/bacli"
+	Config         string   // rclone.conf path, empty for rclone's default
+	BandwidthLimit string   // passed as --bwlimit, e.g. "10M" or "08:00,1M 19:00,off"
+	Flags          []string // extra flags passed to every rclone command
+	Binary         string   // rclone executable, "rclone" by default
+}
+
+// Ensure Rclone satisfies Backend.
+var _ Backend = (*Rclone)(nil)
+
+// NewRclone creates an Rclone backend.
+func NewRclone(opts ...RcloneOption) (*Rclone, error) {
+	r := &Rclone{Binary: "rclone"}
+	for _, opt := range opts {
+		opt(r)
+	}
+	if r.Remote == "" {
+		return nil, fmt.Errorf("%w: rclone remote is required", ErrStorage)
+	}
+	if !strings.Contains(r.Remote, ":") {
+		return nil, fmt.Errorf("%w: rclone remote %q must look like name:path", ErrStorage, r.Remote)
+	}
+	return r, nil
+}
+
+// WithRcloneRemote sets the remote and base path ("name:path").
+func WithRcloneRemote(remote string) RcloneOption {
+	return func(r *Rclone) {
+		r.Remote = remote
+	}
+}
+
+// WithRcloneConfig sets the rclone configuration file.
+func WithRcloneConfig(config string) RcloneOption {
+	return func(r *Rclone) {
+		r.Config = config
+	}
+}
+
+// WithRcloneBandwidthLimit limits transfer bandwidth (rclone --bwlimit syntax).
+func WithRcloneBandwidthLimit(limit string) RcloneOption {
+	return func(r *Rclone) {
+		r.BandwidthLimit = limit
+	}
+}
+
+// WithRcloneFlags appends extra flags to every rclone command.
+func WithRcloneFlags(flags ...string) RcloneOption {
+	return func(r *Rclone) {
+		r.Flags = append(r.Flags, flags...)
+	}
+}
+
+// WithRcloneBinary overrides the rclone executable.
+func WithRcloneBinary(binary string) RcloneOption {
+	return func(r *Rclone) {
+		if binary != "" {
+			r.Binary = binary
+		}
+	}
+}
+
+// Name returns the backend name.
+func (r *Rclone) Name() string { return TypeRclone }
+
+// Upload copies localPath to key and verifies the stored size. rclone also
+// compares checksums after the transfer when the remote supports them.
+func (r *Rclone) Upload(ctx context.Context, localPath, key string) error {
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return fmt.Errorf("%w: stat %s: %v", ErrStorage, localPath, err)
+	}
+	if _, err := r.run(ctx, "copyto", localPath, r.path(key)); err != nil {
+		return fmt.Errorf("%w: upload %s: %v", ErrStorage, key, err)
+	}
+	stored, err := r.stat(ctx, key)
+	if err != nil {
+		return fmt.Errorf("%w: verify %s: %v", ErrStorage, key, err)
+	}
+	if stored.Size != info.Size() {
+		return fmt.Errorf("%w: verify %s: stored %d bytes, expected %d", ErrStorage, key, stored.Size, info.Size())
+	}
+	return nil
+}
+
+// Download copies key to localPath.
+func (r *Rclone) Download(ctx context.Context, key, localPath string) error {
+	if _, err := r.run(ctx, "copyto", r.path(key), localPath); err != nil {
+		if isRcloneNotFound(err) {
+			return fmt.Errorf("%w: %s", ErrNotFound, key)
+		}
+		return fmt.Errorf("%w: download %s: %v", ErrStorage, key, err)
+	}
+	return nil
+}
+
+// List returns every object whose key starts with prefix.
+func (r *Rclone) List(ctx context.Context, prefix string) ([]Object, error) {
+	// list the deepest directory covering prefix, then filter
+	dir := ""
+	if i := strings.LastIndex(prefix, "/"); i >= 0 {
+		dir = prefix[:i]
+	}
+	out, err := r.run(ctx, "lsjson", "--recursive", "--files-only", "--no-mimetype", r.path(dir))
+	if err != nil {
+		if isRcloneNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("%w: list %s: %v", ErrStorage, prefix, err)
+	}
+	var entries []rcloneEntry
+	if err := json.Unmarshal(out, &entries); err != nil {
+		return nil, fmt.Errorf("%w: list %s: %v", ErrStorage, prefix, err)
+	}
+	var objects []Object
+	for _, entry := range entries {
+		key := path.Join(dir, entry.Path)
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		objects = append(objects, Object{Key: key, Size: entry.Size, ModTime: entry.ModTime})
+	}
+	return objects, nil
+}
+
+// Delete removes key. Missing keys are not an error.
+func (r *Rclone) Delete(ctx context.Context, key string) error {
+	if _, err := r.run(ctx, "deletefile", r.path(key)); err != nil && !isRcloneNotFound(err) {
+		return fmt.Errorf("%w: delete %s: %v", ErrStorage, key, err)
+	}
+	return nil
+}
+
+// rcloneEntry is one item of `rclone lsjson` output.
+type rcloneEntry struct {
+	Path    string    `json:"Path"`
+	Size    int64     `json:"Size"`
+	ModTime time.Time `json:"ModTime"`
+}
+
+// stat returns the stored object at key.
+func (r *Rclone) stat(ctx context.Context, key string) (rcloneEntry, error) {
+	var entry rcloneEntry
+	out, err := r.run(ctx, "lsjson", "--stat", "--no-mimetype", r.path(key))
+	if err != nil {
+		return entry, err
+	}
+	err = json.Unmarshal(out, &entry)
+	return entry, err
+}
+
+// path returns the rclone location of key.
+func (r *Rclone) path(key string) string {
+	if key == "" || strings.HasSuffix(r.Remote, ":") || strings.HasSuffix(r.Remote, "/") {
+		return r.Remote + key
+	}
+	return r.Remote + "/" + key
+}
+
+// run runs rclone with the backend's global flags and returns its stdout.
+// Errors include rclone's stderr.
+func (r *Rclone) run(ctx context.Context, args ...string) ([]byte, error) {
+	if r.Config != "" {
+		args = append(args, "--config", r.Config)
+	}
+	if r.BandwidthLimit != "" {
+		args = append(args, "--bwlimit", r.BandwidthLimit)
+	}
+	args = append(args, r.Flags...)
+
+	var stderr strings.Builder
+	cmd := exec.CommandContext(ctx, r.Binary, args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, &rcloneError{command: args[0], err: err, stderr: strings.TrimSpace(stderr.String())}
+	}
+	return out, nil
+}
+
+// rcloneError is a failed rclone command.
+type rcloneError struct {
+	command string
+	err     error
+	stderr  string
+}
+
+func (e *rcloneError) Error() string {
+	if e.stderr == "" {
+		return fmt.Sprintf("rclone %s: %v", e.command, e.err)
+	}
+	return fmt.Sprintf("rclone %s: %v: %s", e.command, e.err, e.stderr)
+}
+
+func (e *rcloneError) Unwrap() error { return e.err }
+
+// isRcloneNotFound reports whether err is rclone's "file/directory not
+// found" exit status.
+func isRcloneNotFound(err error) bool {
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return false
+	}
+	code := exitErr.ExitCode()
+	return code == rcloneDirNotFound || code == rcloneFileNotFound
+}
@@ -0,0 +1,20 @@
+package storage
+
+import "testing"
+
+func TestRclonePath(t *testing.T) {
+	tests := []struct {
+		remote, key, want string
+	}{
+		{"gdrive:", "postgres/db/a.dump", "gdrive:postgres/db/a.dump"},
+		{"gdrive:backups", "postgres/db/a.dump", "gdrive:backups/postgres/db/a.dump"},
+		{"gdrive:backups/", "postgres/db/a.dump", "gdrive:backups/postgres/db/a.dump"},
+		{"gdrive:backups", "", "gdrive:backups"},
+	}
+	for _, tt := range tests {
+		r := &Rclone{Remote: tt.remote}
+		if got := r.path(tt.key); got != tt.want {
+			t.Errorf("path(%q) on %q = %q, want %q", tt.key, tt.remote, got, tt.want)
+		}
+	}
+}
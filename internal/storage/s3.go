@@ -0,0 +1,526 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kebairia/backup/internal/progress"
+)
+
+const (
+	s3DefaultRegion   = "us-east-1"
+	s3UnsignedPayload = "UNSIGNED-PAYLOAD"
+	s3TimeFormat      = "20060102T150405Z"
+	s3DateFormat      = "20060102"
+)
+
+// S3Option defines a functional option for configuring an S3 backend.
+type S3Option func(*S3)
+
+// S3 stores artifacts in an S3-compatible object store (AWS, MinIO, Ceph RGW).
+type S3 struct {
+	Bucket         string
+	Prefix         string
+	Region         string
+	Endpoint       string // e.g. https://minio.hl.lan:9000, empty for AWS
+	ForcePathStyle bool   // https://endpoint/bucket/key instead of https://bucket.endpoint/key
+	AccessKey      string
+	SecretKey      string
+	SessionToken   string
+	CABundle       string // PEM file with additional trusted CAs
+	SkipVerify     bool
+	// Files of at least MultipartThreshold bytes (when positive) are
+	// uploaded in parts of PartSize bytes, UploadConcurrency at a time.
+	MultipartThreshold int64
+	PartSize           int64
+	UploadConcurrency  int
+
+	client *http.Client
+}
+
+// Ensure S3 satisfies Backend and Tagger.
+var (
+	_ Backend = (*S3)(nil)
+	_ Tagger  = (*S3)(nil)
+)
+
+// NewS3 creates an S3 backend. Credentials default to the standard
+// AWS_ACCESS_KEY_ID / AWS_SECRET_ACCESS_KEY / AWS_SESSION_TOKEN variables.
+func NewS3(opts ...S3Option) (*S3, error) {
+	s := &S3{
+		Region:       os.Getenv("AWS_REGION"),
+		AccessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+
+		MultipartThreshold: s3DefaultMultipartThreshold,
+		PartSize:           s3DefaultPartSize,
+		UploadConcurrency:  s3DefaultUploadConcurrency,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.Bucket == "" {
+		return nil, fmt.Errorf("%w: s3 bucket is required", ErrStorage)
+	}
+	if s.Region == "" {
+		s.Region = s3DefaultRegion
+	}
+	if s.Endpoint == "" {
+		s.Endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", s.Region)
+	}
+	if !strings.Contains(s.Endpoint, "://") {
+		s.Endpoint = "https://" + s.Endpoint
+	}
+	s.Endpoint = strings.TrimRight(s.Endpoint, "/")
+	s.Prefix = strings.Trim(s.Prefix, "/")
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: s.SkipVerify}
+	if s.CABundle != "" {
+		pem, err := os.ReadFile(s.CABundle)
+		if err != nil {
+			return nil, fmt.Errorf("%w: read ca bundle: %v", ErrStorage, err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%w: no certificates found in %s", ErrStorage, s.CABundle)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	s.client = &http.Client{Transport: transport}
+
+	return s, nil
+}
+
+// WithS3Bucket sets the bucket and optional key prefix.
+func WithS3Bucket(bucket, prefix string) S3Option {
+	return func(s *S3) {
+		s.Bucket = bucket
+		s.Prefix = prefix
+	}
+}
+
+// WithS3Region overrides the signing region.
+func WithS3Region(region string) S3Option {
+	return func(s *S3) {
+		if region != "" {
+			s.Region = region
+		}
+	}
+}
+
+// WithS3Endpoint sets a custom endpoint for S3-compatible stores.
+func WithS3Endpoint(endpoint string, forcePathStyle bool) S3Option {
+	return func(s *S3) {
+		if endpoint != "" {
+			s.Endpoint = endpoint
+		}
+		s.ForcePathStyle = forcePathStyle
+	}
+}
+
+// WithS3Credentials overrides the access and secret keys.
+func WithS3Credentials(accessKey, secretKey string) S3Option {
+	return func(s *S3) {
+		if accessKey != "" {
+			s.AccessKey = accessKey
+		}
+		if secretKey != "" {
+			s.SecretKey = secretKey
+		}
+	}
+}
+
+// WithS3TLS sets a CA bundle for self-signed endpoints and optionally disables verification.
+func WithS3TLS(caBundle string, skipVerify bool) S3Option {
+	return func(s *S3) {
+		s.CABundle = caBundle
+		s.SkipVerify = skipVerify
+	}
+}
+
+// Name returns the backend name.
+func (s *S3) Name() string { return TypeS3 }
+
+// Upload stores localPath at key. Files of at least MultipartThreshold
+// bytes are uploaded in parts, resuming an interrupted upload.
+func (s *S3) Upload(ctx context.Context, localPath, key string) error {
+	file, err := os.Open(localPath)
+	if err != nil {
+		return fmt.Errorf("%w: open %s: %v", ErrStorage, localPath, err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("%w: stat %s: %v", ErrStorage, localPath, err)
+	}
+	if s.MultipartThreshold > 0 && info.Size() >= s.MultipartThreshold {
+		return s.uploadMultipart(ctx, file, info, localPath, key)
+	}
+
+	body := progress.FromContext(ctx).Reader(file)
+	req, err := s.newRequest(ctx, http.MethodPut, s.objectKey(key), nil, body)
+	if err != nil {
+		return err
+	}
+	s.setMetadata(ctx, req)
+	req.ContentLength = info.Size()
+	resp, err := s.do(req)
+	if err != nil {
+		return fmt.Errorf("%w: upload %s: %v", ErrStorage, key, err)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// S3 object tag limits.
+const (
+	s3MaxTags        = 10
+	s3MaxTagKeyLen   = 128
+	s3MaxTagValueLen = 256
+)
+
+// Tag replaces the object tags of key with labels.
+func (s *S3) Tag(ctx context.Context, key string, labels map[string]string) error {
+	if len(labels) > s3MaxTags {
+		return fmt.Errorf("%w: %d labels exceed the s3 limit of %d tags", ErrStorage, len(labels), s3MaxTags)
+	}
+	type tag struct {
+		Key   string `xml:"Key"`
+		Value string `xml:"Value"`
+	}
+	var tagging struct {
+		XMLName xml.Name `xml:"Tagging"`
+		TagSet  []tag    `xml:"TagSet>Tag"`
+	}
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if len(name) > s3MaxTagKeyLen || len(labels[name]) > s3MaxTagValueLen {
+			return fmt.Errorf("%w: label %q exceeds s3 tag size limits", ErrStorage, name)
+		}
+		tagging.TagSet = append(tagging.TagSet, tag{Key: name, Value: labels[name]})
+	}
+	body, err := xml.Marshal(tagging)
+	if err != nil {
+		return fmt.Errorf("%w: encode tags: %v", ErrStorage, err)
+	}
+
+	query := url.Values{}
+	query.Set("tagging", "")
+	req, err := s.newRequest(ctx, http.MethodPut, s.objectKey(key), query, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	sum := md5.Sum(body)
+	req.Header.Set("Content-MD5", base64.StdEncoding.EncodeToString(sum[:]))
+	resp, err := s.do(req)
+	if err != nil {
+		return fmt.Errorf("%w: tag %s: %v", ErrStorage, key, err)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Download fetches key into localPath.
+func (s *S3) Download(ctx context.Context, key, localPath string) error {
+	req, err := s.newRequest(ctx, http.MethodGet, s.objectKey(key), nil, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(req)
+	if err != nil {
+		return fmt.Errorf("%w: download %s: %v", ErrStorage, key, err)
+	}
+	defer resp.Body.Close()
+
+	if err := os.MkdirAll(filepath.Dir(localPath), 0o755); err != nil {
+		return fmt.Errorf("%w: mkdir %s: %v", ErrStorage, filepath.Dir(localPath), err)
+	}
+	out, err := os.Create(localPath)
+	if err != nil {
+		return fmt.Errorf("%w: create %s: %v", ErrStorage, localPath, err)
+	}
+	if _, err := io.Copy(out, resp.Body); err != nil {
+		out.Close()
+		return fmt.Errorf("%w: download %s: %v", ErrStorage, key, err)
+	}
+	return out.Close()
+}
+
+// List returns every object whose key starts with prefix.
+func (s *S3) List(ctx context.Context, prefix string) ([]Object, error) {
+	var objects []Object
+	token := ""
+	for {
+		query := url.Values{}
+		query.Set("list-type", "2")
+		query.Set("prefix", s.objectKey(prefix))
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		req, err := s.newRequest(ctx, http.MethodGet, "", query, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := s.do(req)
+		if err != nil {
+			return nil, fmt.Errorf("%w: list %s: %v", ErrStorage, prefix, err)
+		}
+		var result struct {
+			Contents []struct {
+				Key          string    `xml:"Key"`
+				Size         int64     `xml:"Size"`
+				LastModified time.Time `xml:"LastModified"`
+			} `xml:"Contents"`
+			IsTruncated           bool   `xml:"IsTruncated"`
+			NextContinuationToken string `xml:"NextContinuationToken"`
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%w: decode list response: %v", ErrStorage, err)
+		}
+		for _, c := range result.Contents {
+			objects = append(objects, Object{
+				Key:     s.relativeKey(c.Key),
+				Size:    c.Size,
+				ModTime: c.LastModified,
+			})
+		}
+		if !result.IsTruncated {
+			return objects, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// Delete removes key from the bucket.
+func (s *S3) Delete(ctx context.Context, key string) error {
+	req, err := s.newRequest(ctx, http.MethodDelete, s.objectKey(key), nil, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(req)
+	if err != nil {
+		return fmt.Errorf("%w: delete %s: %v", ErrStorage, key, err)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// objectKey prepends the configured prefix to key.
+func (s *S3) objectKey(key string) string {
+	if s.Prefix == "" {
+		return key
+	}
+	return path.Join(s.Prefix, key)
+}
+
+// relativeKey strips the configured prefix from an object key.
+func (s *S3) relativeKey(key string) string {
+	if s.Prefix == "" {
+		return key
+	}
+	return strings.TrimPrefix(strings.TrimPrefix(key, s.Prefix), "/")
+}
+
+// newRequest builds a signed request for key (empty key addresses the bucket).
+func (s *S3) newRequest(
+	ctx context.Context,
+	method, key string,
+	query url.Values,
+	body io.Reader,
+) (*http.Request, error) {
+	endpoint, err := url.Parse(s.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("%w: parse endpoint %q: %v", ErrStorage, s.Endpoint, err)
+	}
+	u := *endpoint
+	if s.ForcePathStyle {
+		u.Path = "/" + s.Bucket
+		if key != "" {
+			u.Path += "/" + key
+		}
+	} else {
+		u.Host = s.Bucket + "." + u.Host
+		u.Path = "/" + key
+	}
+	u.RawPath = canonicalURI(u.Path)
+	if query != nil {
+		u.RawQuery = strings.ReplaceAll(query.Encode(), "+", "%20")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, fmt.Errorf("%w: build request: %v", ErrStorage, err)
+	}
+	s.sign(req, time.Now().UTC())
+	return req, nil
+}
+
+// setMetadata adds the object metadata of ctx (see WithMetadata) to req as
+// x-amz-meta-* headers and signs req again to cover them.
+func (s *S3) setMetadata(ctx context.Context, req *http.Request) {
+	metadata := metadataFrom(ctx)
+	if len(metadata) == 0 {
+		return
+	}
+	for name, value := range metadata {
+		req.Header.Set("x-amz-meta-"+name, value)
+	}
+	s.sign(req, time.Now().UTC())
+}
+
+// do executes req and converts non-2xx responses into errors.
+func (s *S3) do(req *http.Request) (*http.Response, error) {
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 == 2 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, req.URL.Path)
+	}
+	return nil, fmt.Errorf("%s %s: %s: %s", req.Method, req.URL.Path, resp.Status, strings.TrimSpace(string(msg)))
+}
+
+// sign applies AWS Signature Version 4 to req. The payload is left unsigned
+// unless req already carries its hash in x-amz-content-sha256.
+func (s *S3) sign(req *http.Request, now time.Time) {
+	amzDate := now.Format(s3TimeFormat)
+	date := now.Format(s3DateFormat)
+
+	req.Header.Set("x-amz-date", amzDate)
+	payload := req.Header.Get("x-amz-content-sha256")
+	if payload == "" {
+		payload = s3UnsignedPayload
+		req.Header.Set("x-amz-content-sha256", payload)
+	}
+	if s.SessionToken != "" {
+		req.Header.Set("x-amz-security-token", s.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name := range req.Header {
+		lower := strings.ToLower(name)
+		if strings.HasPrefix(lower, "x-amz-") {
+			headers[lower] = strings.TrimSpace(req.Header.Get(name))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalURI(req.URL.Path),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payload,
+	}, "\n")
+
+	scope := strings.Join([]string{date, s.Region, "s3", "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		hexSHA256([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.SecretKey), date)
+	key = hmacSHA256(key, s.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.AccessKey, scope, signedHeaders, signature,
+	))
+}
+
+// canonicalURI encodes each segment of an object path as SigV4 requires:
+// everything but unreserved characters (A-Z a-z 0-9 - . _ ~) is
+// percent-encoded, so keys holding characters such as "!*'()" or "=" sign
+// as S3 checks them.
+func canonicalURI(p string) string {
+	if p == "" {
+		return "/"
+	}
+	segments := strings.Split(p, "/")
+	for i, segment := range segments {
+		segments[i] = awsEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// canonicalQuery encodes query parameters sorted by key as SigV4 requires.
+func canonicalQuery(values url.Values) string {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		for _, v := range values[k] {
+			parts = append(parts, awsEscape(k)+"="+awsEscape(v))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+func awsEscape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func hexSHA256(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
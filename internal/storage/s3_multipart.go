@@ -0,0 +1,331 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/kebairia/backup/internal/progress"
+)
+
+// Multipart upload defaults and S3 limits.
+const (
+	s3DefaultMultipartThreshold = 100 << 20
+	s3DefaultPartSize           = 16 << 20
+	s3DefaultUploadConcurrency  = 4
+	s3MinPartSize               = 5 << 20
+	s3MaxParts                  = 10000
+	s3PartAttempts              = 3
+)
+
+// s3RetryDelay is the wait before the first retry of a failed part; it
+// doubles with every attempt.
+var s3RetryDelay = time.Second
+
+// WithS3Multipart uploads files of at least threshold bytes in parts of
+// partSize bytes, concurrency parts at a time. Zero values keep the
+// defaults (100 MiB, 16 MiB, 4); a negative threshold disables multipart
+// uploads.
+func WithS3Multipart(threshold, partSize int64, concurrency int) S3Option {
+	return func(s *S3) {
+		if threshold != 0 {
+			s.MultipartThreshold = threshold
+		}
+		if partSize > 0 {
+			s.PartSize = max(partSize, s3MinPartSize)
+		}
+		if concurrency > 0 {
+			s.UploadConcurrency = concurrency
+		}
+	}
+}
+
+// s3Upload is the state of a multipart upload, saved next to the uploaded
+// file so that an interrupted upload resumes with the parts it already sent.
+type s3Upload struct {
+	Key      string    `json:"key"`
+	UploadID string    `json:"upload_id"`
+	Size     int64     `json:"size"`
+	ModTime  time.Time `json:"mod_time"`
+	PartSize int64     `json:"part_size"`
+	// Parts maps the number of every part sent to its ETag.
+	Parts map[int]string `json:"parts"`
+
+	path string
+	mu   sync.Mutex
+}
+
+// uploadStatePath returns where the state of an upload of localPath is
+// kept: a dot file, which the catalog ignores, next to it.
+func uploadStatePath(localPath string) string {
+	return filepath.Join(filepath.Dir(localPath), "."+filepath.Base(localPath)+".upload")
+}
+
+// loadUpload returns the saved upload of info to key, or nil when there is
+// none or the file changed since.
+func loadUpload(path, key string, info os.FileInfo) *s3Upload {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var upload s3Upload
+	if json.Unmarshal(data, &upload) != nil || upload.Key != key || upload.UploadID == "" ||
+		upload.Size != info.Size() || !upload.ModTime.Equal(info.ModTime()) || upload.PartSize <= 0 {
+		return nil
+	}
+	if upload.Parts == nil {
+		upload.Parts = make(map[int]string)
+	}
+	upload.path = path
+	return &upload
+}
+
+// save writes the upload state, replacing the previous one atomically.
+func (u *s3Upload) save() error {
+	u.mu.Lock()
+	data, err := json.Marshal(u)
+	u.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	tmp := u.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, u.path)
+}
+
+// partCount returns the number of parts of the upload.
+func (u *s3Upload) partCount() int {
+	return int((u.Size + u.PartSize - 1) / u.PartSize)
+}
+
+// uploadMultipart uploads file in parts, resuming the upload saved for
+// localPath when the file has not changed since. The state is kept when the
+// upload fails, for the next attempt; uploads never resumed are left to the
+// bucket's AbortIncompleteMultipartUpload lifecycle rule.
+func (s *S3) uploadMultipart(ctx context.Context, file *os.File, info os.FileInfo, localPath, key string) error {
+	statePath := uploadStatePath(localPath)
+	upload := loadUpload(statePath, key, info)
+	resumed := upload != nil
+	if !resumed {
+		partSize := max(s.PartSize, (info.Size()+s3MaxParts-1)/s3MaxParts)
+		uploadID, err := s.createMultipartUpload(ctx, key)
+		if err != nil {
+			return err
+		}
+		upload = &s3Upload{
+			Key:      key,
+			UploadID: uploadID,
+			Size:     info.Size(),
+			ModTime:  info.ModTime(),
+			PartSize: partSize,
+			Parts:    make(map[int]string),
+			path:     statePath,
+		}
+		if err := upload.save(); err != nil {
+			return fmt.Errorf("%w: save upload state %s: %v", ErrStorage, statePath, err)
+		}
+	}
+
+	err := s.uploadParts(ctx, file, upload)
+	if err == nil {
+		err = s.completeMultipartUpload(ctx, upload)
+	}
+	if errors.Is(err, ErrNotFound) && resumed {
+		// the saved upload was aborted or expired: start over
+		_ = os.Remove(statePath)
+		return s.uploadMultipart(ctx, file, info, localPath, key)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: upload %s: %v", ErrStorage, key, err)
+	}
+	_ = os.Remove(statePath)
+	return nil
+}
+
+// uploadParts sends the parts of upload not sent yet, UploadConcurrency at
+// a time, saving the upload state after each one.
+func (s *S3) uploadParts(ctx context.Context, file *os.File, upload *s3Upload) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	bar := progress.FromContext(ctx)
+
+	parts := make(chan int)
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	fail := func(err error) {
+		errOnce.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+	for range max(s.UploadConcurrency, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for number := range parts {
+				offset := int64(number-1) * upload.PartSize
+				length := min(upload.PartSize, upload.Size-offset)
+				etag, err := s.uploadPart(ctx, file, upload.Key, upload.UploadID, number, offset, length)
+				if err != nil {
+					fail(err)
+					continue
+				}
+				bar.Add(length)
+				upload.mu.Lock()
+				upload.Parts[number] = etag
+				upload.mu.Unlock()
+				if err := upload.save(); err != nil {
+					fail(fmt.Errorf("save upload state %s: %v", upload.path, err))
+				}
+			}
+		}()
+	}
+	for number := 1; number <= upload.partCount(); number++ {
+		if _, sent := upload.Parts[number]; sent {
+			bar.Add(min(upload.PartSize, upload.Size-int64(number-1)*upload.PartSize))
+			continue
+		}
+		select {
+		case parts <- number:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	close(parts)
+	wg.Wait()
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
+
+// uploadPart sends one part, retrying it s3PartAttempts times, and returns
+// its ETag.
+func (s *S3) uploadPart(
+	ctx context.Context,
+	file *os.File,
+	key, uploadID string,
+	number int,
+	offset, length int64,
+) (string, error) {
+	query := url.Values{}
+	query.Set("partNumber", strconv.Itoa(number))
+	query.Set("uploadId", uploadID)
+	delay := s3RetryDelay
+	var err error
+	for attempt := 1; ; attempt++ {
+		var req *http.Request
+		req, err = s.newRequest(ctx, http.MethodPut, s.objectKey(key), query, io.NewSectionReader(file, offset, length))
+		if err != nil {
+			return "", err
+		}
+		req.ContentLength = length
+		var resp *http.Response
+		if resp, err = s.do(req); err == nil {
+			resp.Body.Close()
+			return resp.Header.Get("ETag"), nil
+		}
+		if errors.Is(err, ErrNotFound) || attempt == s3PartAttempts {
+			break
+		}
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+		delay *= 2
+	}
+	return "", fmt.Errorf("part %d: %w", number, err)
+}
+
+// createMultipartUpload starts a multipart upload of key and returns its id.
+func (s *S3) createMultipartUpload(ctx context.Context, key string) (string, error) {
+	query := url.Values{}
+	query.Set("uploads", "")
+	req, err := s.newRequest(ctx, http.MethodPost, s.objectKey(key), query, nil)
+	if err != nil {
+		return "", err
+	}
+	s.setMetadata(ctx, req)
+	resp, err := s.do(req)
+	if err != nil {
+		return "", fmt.Errorf("%w: start upload %s: %v", ErrStorage, key, err)
+	}
+	defer resp.Body.Close()
+	var result struct {
+		UploadID string `xml:"UploadId"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&result); err != nil || result.UploadID == "" {
+		return "", fmt.Errorf("%w: start upload %s: no upload id in response", ErrStorage, key)
+	}
+	return result.UploadID, nil
+}
+
+// completeMultipartUpload assembles the parts of upload into the object.
+func (s *S3) completeMultipartUpload(ctx context.Context, upload *s3Upload) error {
+	type part struct {
+		PartNumber int    `xml:"PartNumber"`
+		ETag       string `xml:"ETag"`
+	}
+	var complete struct {
+		XMLName xml.Name `xml:"CompleteMultipartUpload"`
+		Parts   []part   `xml:"Part"`
+	}
+	for number, etag := range upload.Parts {
+		complete.Parts = append(complete.Parts, part{PartNumber: number, ETag: etag})
+	}
+	sort.Slice(complete.Parts, func(i, j int) bool {
+		return complete.Parts[i].PartNumber < complete.Parts[j].PartNumber
+	})
+	if len(complete.Parts) != upload.partCount() {
+		return fmt.Errorf("%d of %d parts sent", len(complete.Parts), upload.partCount())
+	}
+	body, err := xml.Marshal(complete)
+	if err != nil {
+		return fmt.Errorf("encode parts: %v", err)
+	}
+
+	query := url.Values{}
+	query.Set("uploadId", upload.UploadID)
+	req, err := s.newRequest(ctx, http.MethodPost, s.objectKey(upload.Key), query, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(req)
+	if err != nil {
+		return fmt.Errorf("complete: %w", err)
+	}
+	defer resp.Body.Close()
+	// a failure can be reported after a 200 status, in the body
+	var result struct {
+		XMLName xml.Name
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&result); err == nil && result.XMLName.Local == "Error" {
+		if result.Code == "NoSuchUpload" {
+			return fmt.Errorf("complete: %w: %s", ErrNotFound, result.Message)
+		}
+		return fmt.Errorf("complete: %s: %s", result.Code, result.Message)
+	}
+	return nil
+}
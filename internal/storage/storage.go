@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+const (
+	TypeLocal  = "local"
+	TypeS3     = "s3"
+	TypeRclone = "rclone"
+)
+
+var (
+	ErrStorage            = errors.New("storage operation failed")
+	ErrNotFound           = errors.New("object not found")
+	ErrUnsupportedBackend = errors.New("unsupported storage backend")
+)
+
+// Object describes a stored artifact.
+type Object struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+}
+
+// Backend is a destination for backup artifacts.
+// Keys are slash-separated paths relative to the backend root.
+type Backend interface {
+	Name() string
+	Upload(ctx context.Context, localPath, key string) error
+	Download(ctx context.Context, key, localPath string) error
+	List(ctx context.Context, prefix string) ([]Object, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// metadataKey is the context key of the metadata set by WithMetadata.
+type metadataKey struct{}
+
+// WithMetadata returns a copy of ctx whose uploads store metadata with the
+// objects they create, on backends that support it (S3 user metadata,
+// x-amz-meta-<name>); e.g. the ID of the run that produced an artifact.
+// Other backends ignore it.
+func WithMetadata(ctx context.Context, metadata map[string]string) context.Context {
+	return context.WithValue(ctx, metadataKey{}, metadata)
+}
+
+// metadataFrom returns the metadata set on ctx by WithMetadata.
+func metadataFrom(ctx context.Context) map[string]string {
+	metadata, _ := ctx.Value(metadataKey{}).(map[string]string)
+	return metadata
+}
+
+// Tagger is implemented by backends that can attach key/value labels to a
+// stored object, e.g. S3 object tags used by lifecycle rules.
+type Tagger interface {
+	Tag(ctx context.Context, key string, labels map[string]string) error
+}
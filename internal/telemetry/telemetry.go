@@ -0,0 +1,105 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/kebairia/backup/internal/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName identifies bacli's spans.
+const instrumentationName = "github.com/kebairia/backup"
+
+// defaultServiceName is reported when tracing.service_name is unset.
+const defaultServiceName = "bacli"
+
+// shutdownTimeout bounds how long Shutdown waits to flush pending spans.
+const shutdownTimeout = 5 * time.Second
+
+// ErrTelemetry indicates that tracing could not be set up.
+var ErrTelemetry = errors.New("telemetry init failed")
+
+// provider is the tracer provider installed by Init, nil when tracing is off.
+var provider *sdktrace.TracerProvider
+
+// Init installs an OTLP/HTTP exporting tracer provider when tracing is enabled.
+// With tracing disabled the global no-op provider stays in place, so spans
+// cost next to nothing. The standard OTEL_EXPORTER_OTLP_* environment
+// variables are honored for anything not set in the configuration.
+// Init is idempotent; only the first enabled configuration takes effect.
+func Init(ctx context.Context, cfg config.TracingConfig) error {
+	if !cfg.Enabled || provider != nil {
+		return nil
+	}
+
+	var opts []otlptracehttp.Option
+	if cfg.Endpoint != "" {
+		opts = append(opts, otlptracehttp.WithEndpointURL(cfg.Endpoint))
+	}
+	if cfg.Insecure {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+	exporter, err := otlptracehttp.New(ctx, opts...)
+	if err != nil {
+		return fmt.Errorf("%w: otlp exporter: %v", ErrTelemetry, err)
+	}
+
+	serviceName := cfg.ServiceName
+	if serviceName == "" {
+		serviceName = defaultServiceName
+	}
+	res, err := resource.Merge(
+		resource.Default(),
+		resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(serviceName)),
+	)
+	if err != nil {
+		return fmt.Errorf("%w: resource: %v", ErrTelemetry, err)
+	}
+
+	sampler := sdktrace.AlwaysSample()
+	if cfg.SampleRatio > 0 && cfg.SampleRatio < 1 {
+		sampler = sdktrace.TraceIDRatioBased(cfg.SampleRatio)
+	}
+	provider = sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sampler)),
+	)
+	otel.SetTracerProvider(provider)
+	return nil
+}
+
+// Shutdown flushes pending spans. Call at program exit.
+func Shutdown() {
+	if provider == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	_ = provider.Shutdown(ctx)
+	provider = nil
+}
+
+// Start starts a span named name as a child of any span in ctx.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End records err (if any) on span and ends it.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -2,12 +2,19 @@ package vault
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
+	"slices"
+	"strings"
+	"sync"
 	"time"
 
 	vault "github.com/hashicorp/vault/api"
+	"github.com/kebairia/backup/internal/telemetry"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 const (
@@ -18,23 +25,49 @@ const (
 // ErrClientInit indicates failure to initialize the Vault API client.
 var ErrClientInit = errors.New("vault client initialization failed")
 
+// ErrNotConfigured indicates that a setting needs Vault but no vault block
+// is configured.
+var ErrNotConfigured = errors.New("vault is not configured")
+
 type Option func(*config)
 
 type config struct {
 	address     string
 	token       string
 	approleName string
+	namespace   string
+	tls         *TLSOptions
+	reuse       bool
+}
+
+// TLSOptions configures the TLS connection to Vault. Paths point to
+// PEM-encoded files. Unset, the VAULT_CACERT, VAULT_CLIENT_CERT,
+// VAULT_CLIENT_KEY, VAULT_TLS_SERVER_NAME and VAULT_SKIP_VERIFY environment
+// variables apply.
+type TLSOptions struct {
+	CACert     string // CA bundle verifying the server certificate
+	ClientCert string // client certificate, for the cert auth method or mTLS
+	ClientKey  string // key of ClientCert
+	ServerName string // SNI host, when it differs from the address
+	SkipVerify bool   // do not verify the server certificate (testing only)
 }
 
 type Client struct {
 	// The Vault Client
 	api    *vault.Client
 	config *config
+
+	mu     sync.Mutex // guards leases and cached
+	leases []string   // dynamic credential leases issued to this client
+	// cached holds dynamic credentials requested ahead of use, or kept for
+	// reuse, by role path
+	cached map[string][]cachedCredentials
 }
 type DynamicCredentials struct {
 	Username string
 	Password string
 	TTL      time.Duration
+	LeaseID  string
 }
 type StaticCredentials struct {
 	Host     string
@@ -78,6 +111,31 @@ func WithAppRole(approleName string) Option {
 	}
 }
 
+// WithNamespace sends every request to a Vault Enterprise namespace
+// (overrides VAULT_NAMESPACE).
+func WithNamespace(namespace string) Option {
+	return func(c *config) {
+		c.namespace = namespace
+	}
+}
+
+// WithTLS configures the TLS connection to Vault. Zero options keep the
+// environment defaults.
+func WithTLS(tls TLSOptions) Option {
+	return func(c *config) {
+		c.tls = &tls
+	}
+}
+
+// WithCredentialReuse makes GetDynamicCredentials return the credentials it
+// already issued for a role path, while their lease lasts, instead of
+// requesting a database user per call.
+func WithCredentialReuse(reuse bool) Option {
+	return func(c *config) {
+		c.reuse = reuse
+	}
+}
+
 // NewClient creates and initializes a Vault Client using provided options.
 // It will perform AppRole login if roleID and roleName are both set, otherwise
 // a static token (from env or WithToken) is used.
@@ -97,11 +155,26 @@ func NewClient(ctx context.Context, opts ...Option) (*Client, error) {
 	if cfg.address != "" {
 		apiCfg.Address = cfg.address
 	}
+	if cfg.tls != nil && *cfg.tls != (TLSOptions{}) {
+		err := apiCfg.ConfigureTLS(&vault.TLSConfig{
+			CACert:        cfg.tls.CACert,
+			ClientCert:    cfg.tls.ClientCert,
+			ClientKey:     cfg.tls.ClientKey,
+			TLSServerName: cfg.tls.ServerName,
+			Insecure:      cfg.tls.SkipVerify,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("%w: configure TLS: %v", ErrClientInit, err)
+		}
+	}
 
 	api, err := vault.NewClient(apiCfg)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Vault API client: %w", err)
 	}
+	if cfg.namespace != "" {
+		api.SetNamespace(cfg.namespace)
+	}
 
 	client := &Client{api: api, config: cfg}
 
@@ -122,7 +195,12 @@ func NewClient(ctx context.Context, opts ...Option) (*Client, error) {
 
 // loginAppRole performs AppRole login using only the configured roleName.
 // It fetches the role_id and generates a secret_id automatically.
-func (c *Client) loginAppRole(ctx context.Context) error {
+func (c *Client) loginAppRole(ctx context.Context) (err error) {
+	ctx, span := telemetry.Start(ctx, "vault.login",
+		attribute.String("vault.approle", c.config.approleName),
+	)
+	defer func() { telemetry.End(span, err) }()
+
 	// 1. Fetch RoleID
 	roleIDPath := fmt.Sprintf("auth/approle/role/%s/role-id", c.config.approleName)
 	roleIDSecret, err := c.api.Logical().ReadWithContext(ctx, roleIDPath)
@@ -202,11 +280,32 @@ func (c *Client) loginAppRole(ctx context.Context) error {
 // NOTE: I need to use a more generic way to get the role name
 
 // Get the dynamic credentials from the Vault
-// using the role name, [username, password]
+// using the role name, [username, password]. Credentials prefetched for the
+// role (see Prefetch) are used first.
 func (client *Client) GetDynamicCredentials(
 	ctx context.Context,
 	role string,
 ) (DynamicCredentials, error) {
+	if creds, ok := client.takeCached(role); ok {
+		return creds, nil
+	}
+	creds, err := client.readDynamicCredentials(ctx, role)
+	if err == nil && client.config.reuse {
+		client.putCached(role, creds)
+	}
+	return creds, err
+}
+
+// readDynamicCredentials requests new credentials from the role at path role.
+func (client *Client) readDynamicCredentials(
+	ctx context.Context,
+	role string,
+) (_ DynamicCredentials, err error) {
+	ctx, span := telemetry.Start(ctx, "vault.credentials",
+		attribute.String("vault.path", role),
+	)
+	defer func() { telemetry.End(span, err) }()
+
 	// Read the dynamic credentials from the Vault
 	secret, err := client.api.Logical().ReadWithContext(ctx, role)
 	if err != nil {
@@ -224,9 +323,211 @@ func (client *Client) GetDynamicCredentials(
 	dynamicCreds.Username = user
 	dynamicCreds.Password = pass
 	dynamicCreds.TTL = time.Duration(secret.LeaseDuration) * time.Second
+	dynamicCreds.LeaseID = secret.LeaseID
+	if secret.LeaseID != "" {
+		client.mu.Lock()
+		client.leases = append(client.leases, secret.LeaseID)
+		client.mu.Unlock()
+	}
 	return dynamicCreds, nil
 }
 
+// RevokeLeases revokes every dynamic credential lease issued to this client,
+// so database users created for an aborted run do not outlive it. A nil
+// client (Vault not configured) holds no leases.
+func (client *Client) RevokeLeases(ctx context.Context) (err error) {
+	if client == nil {
+		return nil
+	}
+	ctx, span := telemetry.Start(ctx, "vault.revoke")
+	defer func() { telemetry.End(span, err) }()
+
+	client.mu.Lock()
+	leases := client.leases
+	client.leases = nil
+	client.mu.Unlock()
+
+	var errs []error
+	for _, lease := range leases {
+		if err := client.api.Sys().RevokeWithContext(ctx, lease); err != nil {
+			errs = append(errs, fmt.Errorf("revoke lease %s: %w", lease, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// RevokeLease revokes the dynamic credential lease id as soon as the
+// databases using it are done with it, so that its database user does not
+// linger until the lease expires. The credentials are no longer handed out
+// for reuse. A nil client or an empty id is a no-op.
+func (client *Client) RevokeLease(ctx context.Context, id string) (err error) {
+	if client == nil || id == "" {
+		return nil
+	}
+	ctx, span := telemetry.Start(ctx, "vault.revoke")
+	defer func() { telemetry.End(span, err) }()
+
+	client.mu.Lock()
+	client.leases = slices.DeleteFunc(client.leases, func(lease string) bool { return lease == id })
+	for role, cached := range client.cached {
+		client.cached[role] = slices.DeleteFunc(cached, func(c cachedCredentials) bool {
+			return c.creds.LeaseID == id
+		})
+	}
+	client.mu.Unlock()
+
+	if err := client.api.Sys().RevokeWithContext(ctx, id); err != nil {
+		return fmt.Errorf("revoke lease %s: %w", id, err)
+	}
+	return nil
+}
+
+// GetSecret reads a KV secret at path and returns its data.
+// KV v2 responses are unwrapped so callers always see the flat key/value map.
+func (client *Client) GetSecret(ctx context.Context, path string) (_ map[string]any, err error) {
+	ctx, span := telemetry.Start(ctx, "vault.secret",
+		attribute.String("vault.path", path),
+	)
+	defer func() { telemetry.End(span, err) }()
+
+	secret, err := client.api.Logical().ReadWithContext(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	if secret == nil || secret.Data == nil {
+		return nil, fmt.Errorf("no data found at path: %s", path)
+	}
+	if data, ok := secret.Data["data"].(map[string]any); ok {
+		return data, nil
+	}
+	return secret.Data, nil
+}
+
+// ErrCASMismatch indicates that a check-and-set write lost a race.
+var ErrCASMismatch = errors.New("vault check-and-set mismatch")
+
+// ReadKV reads a KV v2 secret and returns its data and current version.
+// A missing secret returns nil data and version 0.
+func (client *Client) ReadKV(ctx context.Context, mount, path string) (map[string]any, int, error) {
+	secret, err := client.api.Logical().ReadWithContext(ctx, mount+"/data/"+path)
+	if err != nil {
+		return nil, 0, err
+	}
+	if secret == nil || secret.Data == nil {
+		return nil, 0, nil
+	}
+	version := 0
+	if meta, ok := secret.Data["metadata"].(map[string]any); ok {
+		if v, ok := meta["version"].(json.Number); ok {
+			n, _ := v.Int64()
+			version = int(n)
+		}
+	}
+	data, _ := secret.Data["data"].(map[string]any)
+	return data, version, nil
+}
+
+// WriteKV writes a KV v2 secret. When cas is >= 0 the write only succeeds if
+// the secret's current version equals cas (0 means "must not exist").
+func (client *Client) WriteKV(ctx context.Context, mount, path string, data map[string]any, cas int) error {
+	body := map[string]any{"data": data}
+	if cas >= 0 {
+		body["options"] = map[string]any{"cas": cas}
+	}
+	_, err := client.api.Logical().WriteWithContext(ctx, mount+"/data/"+path, body)
+	if err != nil {
+		var respErr *vault.ResponseError
+		if errors.As(err, &respErr) && respErr.StatusCode == 400 {
+			for _, msg := range respErr.Errors {
+				if strings.Contains(msg, "check-and-set") {
+					return fmt.Errorf("%w: %s", ErrCASMismatch, path)
+				}
+			}
+		}
+		return err
+	}
+	return nil
+}
+
+// ListKV lists the keys directly under a KV v2 path. Sub-directories end in "/".
+func (client *Client) ListKV(ctx context.Context, mount, path string) ([]string, error) {
+	secret, err := client.api.Logical().ListWithContext(ctx, mount+"/metadata/"+path)
+	if err != nil {
+		return nil, err
+	}
+	if secret == nil || secret.Data == nil {
+		return nil, nil
+	}
+	raw, _ := secret.Data["keys"].([]any)
+	keys := make([]string, 0, len(raw))
+	for _, k := range raw {
+		if key, ok := k.(string); ok {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+// TransitEncrypt encrypts plaintext with a transit key and returns the
+// Vault ciphertext ("vault:v1:...").
+func (client *Client) TransitEncrypt(
+	ctx context.Context,
+	mount, key string,
+	plaintext []byte,
+) (_ string, err error) {
+	ctx, span := telemetry.Start(ctx, "vault.transit.encrypt",
+		attribute.String("vault.path", mount+"/encrypt/"+key),
+	)
+	defer func() { telemetry.End(span, err) }()
+
+	path := mount + "/encrypt/" + key
+	secret, err := client.api.Logical().WriteWithContext(ctx, path, map[string]any{
+		"plaintext": base64.StdEncoding.EncodeToString(plaintext),
+	})
+	if err != nil {
+		return "", err
+	}
+	if secret == nil || secret.Data == nil {
+		return "", fmt.Errorf("no data returned from %s", path)
+	}
+	ciphertext, ok := secret.Data["ciphertext"].(string)
+	if !ok || ciphertext == "" {
+		return "", fmt.Errorf("invalid ciphertext format at %s", path)
+	}
+	return ciphertext, nil
+}
+
+// TransitDecrypt decrypts a ciphertext produced by TransitEncrypt.
+func (client *Client) TransitDecrypt(
+	ctx context.Context,
+	mount, key, ciphertext string,
+) (_ []byte, err error) {
+	ctx, span := telemetry.Start(ctx, "vault.transit.decrypt",
+		attribute.String("vault.path", mount+"/decrypt/"+key),
+	)
+	defer func() { telemetry.End(span, err) }()
+
+	path := mount + "/decrypt/" + key
+	secret, err := client.api.Logical().WriteWithContext(ctx, path, map[string]any{
+		"ciphertext": ciphertext,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if secret == nil || secret.Data == nil {
+		return nil, fmt.Errorf("no data returned from %s", path)
+	}
+	encoded, ok := secret.Data["plaintext"].(string)
+	if !ok {
+		return nil, fmt.Errorf("invalid plaintext format at %s", path)
+	}
+	plaintext, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("decode plaintext from %s: %w", path, err)
+	}
+	return plaintext, nil
+}
+
 // GetCredentials retrieves both static and dynamic credentials from the Vault
 // IDEA: I need something cleaner
 // func GetCredentials(address, token string) (*Credentials, error) {
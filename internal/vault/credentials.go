@@ -0,0 +1,102 @@
+package vault
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// cachedCredentials are dynamic credentials waiting to be used.
+type cachedCredentials struct {
+	creds   DynamicCredentials
+	expires time.Time // zero for credentials without a lease duration
+}
+
+func (c cachedCredentials) expired(now time.Time) bool {
+	return !c.expires.IsZero() && !now.Before(c.expires)
+}
+
+// Prefetch requests the dynamic credentials of roles, at most concurrency at
+// a time, and keeps them for GetDynamicCredentials. A role path listed n
+// times gets n users, or one when credentials are reused. Failed requests
+// are reported together; the credentials fetched are kept either way.
+func (client *Client) Prefetch(ctx context.Context, roles []string, concurrency int) error {
+	if client.config.reuse {
+		roles = uniqueRoles(roles)
+	}
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+		sem  = make(chan struct{}, concurrency)
+	)
+	for _, role := range roles {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			creds, err := client.readDynamicCredentials(ctx, role)
+			if err != nil {
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("%s: %w", role, err))
+				mu.Unlock()
+				return
+			}
+			client.putCached(role, creds)
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// takeCached returns unexpired cached credentials of role. Without reuse,
+// they are removed from the cache, so that each is handed out once.
+func (client *Client) takeCached(role string) (DynamicCredentials, bool) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	now := time.Now()
+	cached := client.cached[role]
+	for len(cached) > 0 && cached[0].expired(now) {
+		cached = cached[1:]
+	}
+	if len(cached) == 0 {
+		delete(client.cached, role)
+		return DynamicCredentials{}, false
+	}
+	if !client.config.reuse {
+		client.cached[role] = cached[1:]
+	}
+	return cached[0].creds, true
+}
+
+// putCached keeps creds of role for later calls.
+func (client *Client) putCached(role string, creds DynamicCredentials) {
+	entry := cachedCredentials{creds: creds}
+	if creds.TTL > 0 {
+		entry.expires = time.Now().Add(creds.TTL)
+	}
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	if client.cached == nil {
+		client.cached = make(map[string][]cachedCredentials)
+	}
+	client.cached[role] = append(client.cached[role], entry)
+}
+
+func uniqueRoles(roles []string) []string {
+	seen := make(map[string]bool, len(roles))
+	var unique []string
+	for _, role := range roles {
+		if !seen[role] {
+			seen[role] = true
+			unique = append(unique, role)
+		}
+	}
+	return unique
+}
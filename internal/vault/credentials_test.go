@@ -0,0 +1,35 @@
+package vault
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCachedCredentials(t *testing.T) {
+	client := &Client{config: &config{}}
+	client.putCached("db/creds/app", DynamicCredentials{Username: "u1"})
+	client.putCached("db/creds/app", DynamicCredentials{Username: "u2"})
+	client.putCached("db/creds/old", DynamicCredentials{Username: "u3", TTL: time.Nanosecond})
+	time.Sleep(time.Millisecond)
+
+	// without reuse, each prefetched user is handed out once
+	for _, want := range []string{"u1", "u2"} {
+		if creds, ok := client.takeCached("db/creds/app"); !ok || creds.Username != want {
+			t.Errorf("takeCached() = %q, %v; want %q", creds.Username, ok, want)
+		}
+	}
+	if _, ok := client.takeCached("db/creds/app"); ok {
+		t.Error("takeCached() returned credentials already handed out")
+	}
+	if _, ok := client.takeCached("db/creds/old"); ok {
+		t.Error("takeCached() returned expired credentials")
+	}
+
+	client.config.reuse = true
+	client.putCached("db/creds/app", DynamicCredentials{Username: "u4"})
+	for range 2 {
+		if creds, ok := client.takeCached("db/creds/app"); !ok || creds.Username != "u4" {
+			t.Errorf("takeCached() with reuse = %q, %v; want u4", creds.Username, ok)
+		}
+	}
+}